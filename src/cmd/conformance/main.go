@@ -0,0 +1,70 @@
+// Command conformance re-evaluates the formulas in XLSX workbooks and reports
+// each cell where the result differs from the value cached by Excel, grouped
+// by function.
+//
+// Usage:
+//
+//	conformance [-tolerance 1e-9] [-min-score 0.95] book.xlsx...
+//
+// Exits with status 1 if the score across all workbooks is below the minimum.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/mmihic/sheets/src/pkg/sheets/conformance"
+)
+
+func main() {
+	tolerance := flag.Float64("tolerance", conformance.DefaultTolerance,
+		"the relative difference allowed between numbers")
+	minScore := flag.Float64("min-score", 0, "the minimum fraction of formulas that must match")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] book.xlsx...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ok, err := run(context.Background(), flag.Args(), conformance.Options{Tolerance: *tolerance}, *minScore)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	if !ok {
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, names []string, opts conformance.Options, minScore float64) (bool, error) {
+	total := &conformance.Report{}
+	for _, name := range names {
+		report, err := conformance.CheckFile(ctx, name, opts)
+		if err != nil {
+			return false, fmt.Errorf("%s: %w", name, err)
+		}
+
+		fmt.Printf("== %s\n", name)
+		if err := report.WriteSummary(os.Stdout); err != nil {
+			return false, err
+		}
+
+		fmt.Println()
+		total.Add(report)
+	}
+
+	if len(names) > 1 {
+		fmt.Printf("== total: %d of %d formulas match (%.1f%%)\n",
+			total.Matched, total.Formulas-total.Volatile, total.Score()*100)
+	}
+
+	return total.Score() >= minScore, nil
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mmihic/sheets/src/pkg/sheets"
+	"github.com/mmihic/sheets/src/pkg/sheets/conformance"
+	"github.com/mmihic/sheets/src/pkg/sheets/xlsx"
+)
+
+func TestRun(t *testing.T) {
+	ctx := context.Background()
+	wb := sheets.NewWorkbook()
+	s, err := sheets.NewMutableSheet([][]sheets.Value{
+		{sheets.Float64Value(1), sheets.StringValue("=SUM(A1:A2)")},
+		{sheets.Float64Value(2), sheets.StringValue("=MAX(A1:A2)")},
+	}, sheets.WithFormulas())
+	require.NoError(t, err)
+	require.NoError(t, wb.AddSheet("Data", s))
+
+	name := filepath.Join(t.TempDir(), "book.xlsx")
+	require.NoError(t, xlsx.WriteFile(ctx, name, wb))
+
+	ok, err := run(ctx, []string{name, name}, conformance.Options{}, 1)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	_, err = run(ctx, []string{filepath.Join(t.TempDir(), "missing.xlsx")}, conformance.Options{}, 1)
+	assert.Error(t, err)
+}
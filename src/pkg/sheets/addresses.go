@@ -168,6 +168,27 @@ func (r Range) NumCells() int {
 	return numCols * numRows
 }
 
+// Resolve resolves the range against the dimensions of a sheet, replacing
+// open-ended bounds (MaxRow and MaxColumn) with the last populated row and
+// column, and clipping any portion of the range that extends past the data.
+// Returns false if the range does not overlap the populated area of the
+// sheet at all.
+func (r Range) Resolve(dims Dimensions) (Range, bool) {
+	if r.EndRow == MaxRow || r.EndRow > dims.EndRow {
+		r.EndRow = dims.EndRow
+	}
+
+	if r.EndCol == MaxColumn || r.EndCol > dims.EndCol {
+		r.EndCol = dims.EndCol
+	}
+
+	if r.StartRow > r.EndRow || r.StartCol > r.EndCol {
+		return Range{}, false
+	}
+
+	return r, true
+}
+
 // NextPos advances the given position within the range.
 func (r Range) NextPos(pos Pos) (nextPos Pos, insideRange bool) {
 	// Increment by column, spilling over to the next row if we've reached the
@@ -202,6 +202,31 @@ func TestRange_NumCells(t *testing.T) {
 	assert.Equal(t, 30, mustParseRange(t, "D4:H9").NumCells())
 }
 
+func TestRange_Resolve(t *testing.T) {
+	dims := Dimensions{EndRow: 99, EndCol: 4}
+	for _, tt := range []struct {
+		input    string
+		expected string
+		ok       bool
+	}{
+		{"A:C", "A1:C100", true},
+		{"3:7", "A3:E7", true},
+		{"B2:D10", "B2:D10", true},
+		{"C50:Z500", "C50:E100", true},
+		{"D23:45", "D23:E45", true},
+		{"A101:C200", "", false},
+		{"F:H", "", false},
+	} {
+		t.Run(tt.input, func(t *testing.T) {
+			r, ok := mustParseRange(t, tt.input).Resolve(dims)
+			require.Equal(t, tt.ok, ok)
+			if ok {
+				assert.Equal(t, tt.expected, r.String())
+			}
+		})
+	}
+}
+
 func mustParseRange(t *testing.T, s string) Range {
 	r, err := ParseRange(s)
 	require.NoError(t, err)
@@ -0,0 +1,352 @@
+package arrow
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// The metadata of Arrow IPC messages is encoded as flatbuffers. Rather than
+// generating code for the whole Arrow schema, tables are read by the slots of
+// their fields, and built from lists of fields.
+//
+// A table starts with the offset back to its vtable, which lists the offsets
+// of its fields from the start of the table; fields that are not set have an
+// offset of zero. Tables, strings and vectors refer to the objects they hold
+// by offsets forward from the reference.
+
+var errInvalidMetadata = errors.New("invalid metadata")
+
+// A fbuf is a flatbuffer being read. Reads outside the buffer read zeros and
+// mark the buffer as invalid, so that the fields of corrupt metadata can be
+// read without checking each one.
+type fbuf struct {
+	b   []byte
+	err error
+}
+
+func (f *fbuf) bytes(off, n int) []byte {
+	if f.err != nil || off < 0 || n < 0 || off > len(f.b)-n {
+		f.err = errInvalidMetadata
+		return nil
+	}
+
+	return f.b[off : off+n]
+}
+
+func (f *fbuf) u8(off int) uint8 {
+	if b := f.bytes(off, 1); b != nil {
+		return b[0]
+	}
+
+	return 0
+}
+
+func (f *fbuf) u16(off int) uint16 {
+	if b := f.bytes(off, 2); b != nil {
+		return binary.LittleEndian.Uint16(b)
+	}
+
+	return 0
+}
+
+func (f *fbuf) u32(off int) uint32 {
+	if b := f.bytes(off, 4); b != nil {
+		return binary.LittleEndian.Uint32(b)
+	}
+
+	return 0
+}
+
+func (f *fbuf) u64(off int) uint64 {
+	if b := f.bytes(off, 8); b != nil {
+		return binary.LittleEndian.Uint64(b)
+	}
+
+	return 0
+}
+
+// root returns the root table of the buffer.
+func (f *fbuf) root() fbTable {
+	return f.table(int(f.u32(0)))
+}
+
+func (f *fbuf) table(pos int) fbTable {
+	vt := pos - int(int32(f.u32(pos)))
+	return fbTable{f: f, pos: pos, vt: vt, vtLen: int(f.u16(vt))}
+}
+
+// A fbTable is a table within a flatbuffer being read. The zero fbTable is a
+// table without fields.
+type fbTable struct {
+	f     *fbuf
+	pos   int
+	vt    int
+	vtLen int
+}
+
+// field returns the offset of the field in a slot, or zero if it is not set.
+func (t fbTable) field(slot int) int {
+	o := 4 + 2*slot
+	if t.f == nil || o+2 > t.vtLen {
+		return 0
+	}
+
+	return int(t.f.u16(t.vt + o))
+}
+
+func (t fbTable) has(slot int) bool {
+	return t.field(slot) != 0
+}
+
+func (t fbTable) bool(slot int) bool {
+	return t.uint8(slot, 0) != 0
+}
+
+func (t fbTable) uint8(slot int, def uint8) uint8 {
+	if off := t.field(slot); off != 0 {
+		return t.f.u8(t.pos + off)
+	}
+
+	return def
+}
+
+func (t fbTable) int16(slot int, def int16) int16 {
+	if off := t.field(slot); off != 0 {
+		return int16(t.f.u16(t.pos + off))
+	}
+
+	return def
+}
+
+func (t fbTable) int32(slot int, def int32) int32 {
+	if off := t.field(slot); off != 0 {
+		return int32(t.f.u32(t.pos + off))
+	}
+
+	return def
+}
+
+func (t fbTable) int64(slot int, def int64) int64 {
+	if off := t.field(slot); off != 0 {
+		return int64(t.f.u64(t.pos + off))
+	}
+
+	return def
+}
+
+// ref returns the position of the object referred to by the field in a slot.
+func (t fbTable) ref(slot int) (int, bool) {
+	off := t.field(slot)
+	if off == 0 {
+		return 0, false
+	}
+
+	p := t.pos + off
+	return p + int(t.f.u32(p)), true
+}
+
+func (t fbTable) table(slot int) fbTable {
+	p, ok := t.ref(slot)
+	if !ok {
+		return fbTable{}
+	}
+
+	return t.f.table(p)
+}
+
+func (t fbTable) string(slot int) string {
+	p, ok := t.ref(slot)
+	if !ok {
+		return ""
+	}
+
+	return string(t.f.bytes(p+4, int(t.f.u32(p))))
+}
+
+// vector returns the position of the first element of the vector in a slot,
+// and the number of its elements of the given size.
+func (t fbTable) vector(slot, elemSize int) (int, int) {
+	p, ok := t.ref(slot)
+	if !ok {
+		return 0, 0
+	}
+
+	n := int(t.f.u32(p))
+	if t.f.bytes(p+4, n*elemSize) == nil {
+		return 0, 0
+	}
+
+	return p + 4, n
+}
+
+// tables returns the tables of the vector in a slot.
+func (t fbTable) tables(slot int) []fbTable {
+	start, n := t.vector(slot, 4)
+	tables := make([]fbTable, n)
+	for i := range tables {
+		p := start + 4*i
+		tables[i] = t.f.table(p + int(t.f.u32(p)))
+	}
+
+	return tables
+}
+
+// A fbfield is a field of a table being built, in a slot. Values are bools,
+// uint8s, int16s, int32s and int64s, which are held within the table, and
+// strings, tables ([]fbfield), vectors of tables ([][]fbfield) and vectors of
+// structs (fbstructs), which are referred to by the table.
+type fbfield struct {
+	slot  int
+	value any
+}
+
+// A fbstructs is a vector of structs, holding their encoded bytes.
+type fbstructs struct {
+	n    int
+	data []byte
+}
+
+// buildFlatbuffer builds a flatbuffer holding the given root table, padded to a
+// multiple of eight bytes. Objects are laid out after the tables that refer to
+// them, so that every reference is forward.
+func buildFlatbuffer(root []fbfield) ([]byte, error) {
+	b := &fbBuilder{buf: make([]byte, 8)}
+	pos, err := b.table(root)
+	if err != nil {
+		return nil, err
+	}
+
+	binary.LittleEndian.PutUint32(b.buf, uint32(pos))
+	b.align(8)
+	return b.buf, nil
+}
+
+type fbBuilder struct {
+	buf []byte
+}
+
+func (b *fbBuilder) align(n int) {
+	for len(b.buf)%n != 0 {
+		b.buf = append(b.buf, 0)
+	}
+}
+
+func (b *fbBuilder) table(fields []fbfield) (int, error) {
+	// Lay out the fields after the offset to the vtable, aligning each to its
+	// size within a table aligned to eight bytes
+	size, slots := 4, 0
+	offsets := make([]int, len(fields))
+	for i, f := range fields {
+		n := inlineSize(f.value)
+		size = (size + n - 1) / n * n
+		offsets[i] = size
+		size += n
+		if f.slot >= slots {
+			slots = f.slot + 1
+		}
+	}
+
+	b.align(2)
+	vt := len(b.buf)
+	b.buf = append(b.buf, make([]byte, 4+2*slots)...)
+	binary.LittleEndian.PutUint16(b.buf[vt:], uint16(4+2*slots))
+	binary.LittleEndian.PutUint16(b.buf[vt+2:], uint16(size))
+	for i, f := range fields {
+		binary.LittleEndian.PutUint16(b.buf[vt+4+2*f.slot:], uint16(offsets[i]))
+	}
+
+	b.align(8)
+	pos := len(b.buf)
+	b.buf = append(b.buf, make([]byte, size)...)
+	binary.LittleEndian.PutUint32(b.buf[pos:], uint32(pos-vt))
+	var refs []int
+	for i, f := range fields {
+		at := pos + offsets[i]
+		switch v := f.value.(type) {
+		case bool:
+			if v {
+				b.buf[at] = 1
+			}
+		case uint8:
+			b.buf[at] = v
+		case int16:
+			binary.LittleEndian.PutUint16(b.buf[at:], uint16(v))
+		case int32:
+			binary.LittleEndian.PutUint32(b.buf[at:], uint32(v))
+		case int64:
+			binary.LittleEndian.PutUint64(b.buf[at:], uint64(v))
+		default:
+			refs = append(refs, i)
+		}
+	}
+
+	for _, i := range refs {
+		obj, err := b.object(fields[i].value)
+		if err != nil {
+			return 0, err
+		}
+
+		b.refer(pos+offsets[i], obj)
+	}
+
+	return pos, nil
+}
+
+// refer sets the reference at a position to refer to an object.
+func (b *fbBuilder) refer(at, obj int) {
+	binary.LittleEndian.PutUint32(b.buf[at:], uint32(obj-at))
+}
+
+func (b *fbBuilder) object(v any) (int, error) {
+	switch tv := v.(type) {
+	case string:
+		b.align(4)
+		pos := len(b.buf)
+		b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(len(tv)))
+		b.buf = append(append(b.buf, tv...), 0)
+		return pos, nil
+	case []fbfield:
+		return b.table(tv)
+	case [][]fbfield:
+		b.align(4)
+		pos := len(b.buf)
+		b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(len(tv)))
+		b.buf = append(b.buf, make([]byte, 4*len(tv))...)
+		for i, t := range tv {
+			obj, err := b.table(t)
+			if err != nil {
+				return 0, err
+			}
+
+			b.refer(pos+4+4*i, obj)
+		}
+
+		return pos, nil
+	case fbstructs:
+		// Align the structs, which follow the length, to eight bytes
+		for (len(b.buf)+4)%8 != 0 {
+			b.buf = append(b.buf, 0)
+		}
+
+		pos := len(b.buf)
+		b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(tv.n))
+		b.buf = append(b.buf, tv.data...)
+		return pos, nil
+	default:
+		return 0, fmt.Errorf("unsupported flatbuffer object %T", v)
+	}
+}
+
+func inlineSize(v any) int {
+	switch v.(type) {
+	case bool, uint8:
+		return 1
+	case int16:
+		return 2
+	case int64:
+		return 8
+	default:
+		return 4
+	}
+}
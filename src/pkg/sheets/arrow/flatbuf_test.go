@@ -0,0 +1,104 @@
+package arrow
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mustBuildFlatbuffer builds a flatbuffer whose values are known to be
+// supported.
+func mustBuildFlatbuffer(root []fbfield) []byte {
+	buf, err := buildFlatbuffer(root)
+	if err != nil {
+		panic(err)
+	}
+
+	return buf
+}
+
+func TestFlatbufferRoundTrip(t *testing.T) {
+	var structs []byte
+	structs = binary.LittleEndian.AppendUint64(structs, 7)
+	structs = binary.LittleEndian.AppendUint64(structs, 9)
+
+	buf := mustBuildFlatbuffer([]fbfield{
+		{0, int16(-3)},
+		{2, true},
+		{3, uint8(200)},
+		{4, "hello"},
+		{5, []fbfield{{1, int64(-1 << 40)}}},
+		{6, [][]fbfield{{{0, "a"}}, {{0, "bc"}}, {}}},
+		{7, fbstructs{n: 2, data: structs}},
+		{8, int32(1 << 20)},
+	})
+
+	assert.Zero(t, len(buf)%8)
+
+	f := &fbuf{b: buf}
+	root := f.root()
+	assert.Equal(t, int16(-3), root.int16(0, 0))
+	assert.False(t, root.has(1))
+	assert.Equal(t, int16(42), root.int16(1, 42))
+	assert.True(t, root.bool(2))
+	assert.Equal(t, uint8(200), root.uint8(3, 0))
+	assert.Equal(t, "hello", root.string(4))
+	assert.Equal(t, int32(1<<20), root.int32(8, 0))
+	assert.Equal(t, int64(-1<<40), root.table(5).int64(1, 0))
+	assert.Equal(t, int64(5), root.table(5).int64(0, 5))
+
+	var names []string
+	for _, tbl := range root.tables(6) {
+		names = append(names, tbl.string(0))
+	}
+
+	assert.Equal(t, []string{"a", "bc", ""}, names)
+
+	start, n := root.vector(7, 8)
+	require.Equal(t, 2, n)
+	assert.Zero(t, start%8)
+	assert.Equal(t, uint64(7), f.u64(start))
+	assert.Equal(t, uint64(9), f.u64(start+8))
+
+	// Slots beyond the vtable are not set
+	assert.False(t, root.has(20))
+	assert.Empty(t, root.string(20))
+	assert.Empty(t, root.tables(20))
+	assert.Equal(t, fbTable{}, root.table(20))
+	assert.NoError(t, f.err)
+}
+
+func TestFlatbufferOutOfBounds(t *testing.T) {
+	buf := mustBuildFlatbuffer([]fbfield{{0, "hello"}, {1, [][]fbfield{{{0, int64(1)}}}}})
+
+	// Truncating the buffer leaves references pointing past its end
+	f := &fbuf{b: buf[:len(buf)-16]}
+	root := f.root()
+	_ = root.string(0)
+	_ = root.tables(1)
+	assert.Equal(t, errInvalidMetadata, f.err)
+
+	// Reads after an error return zeros
+	f = &fbuf{b: []byte{1, 2}}
+	assert.Zero(t, f.u32(0))
+	assert.Equal(t, errInvalidMetadata, f.err)
+	assert.Zero(t, f.u8(0))
+
+	// Vectors longer than the buffer are empty
+	buf = mustBuildFlatbuffer([]fbfield{{0, fbstructs{n: 1 << 20}}})
+	f = &fbuf{b: buf}
+	start, n := f.root().vector(0, 16)
+	assert.Zero(t, start)
+	assert.Zero(t, n)
+	assert.Equal(t, errInvalidMetadata, f.err)
+}
+
+func TestBuildFlatbuffer_Unsupported(t *testing.T) {
+	_, err := buildFlatbuffer([]fbfield{{0, "hello"}, {1, []fbfield{{0, 1.5}}}})
+	assert.EqualError(t, err, "unsupported flatbuffer object float64")
+
+	_, err = buildFlatbuffer([]fbfield{{0, [][]fbfield{{{0, []byte("data")}}}}})
+	assert.EqualError(t, err, "unsupported flatbuffer object []uint8")
+}
@@ -0,0 +1,312 @@
+package arrow
+
+import (
+	"errors"
+	"fmt"
+)
+
+// The versions of the metadata. Versions before V4 are not supported.
+const (
+	metadataV4 = 3
+	metadataV5 = 4
+)
+
+// The types of the headers of messages.
+const (
+	headerSchema          = 1
+	headerDictionaryBatch = 2
+	headerRecordBatch     = 3
+)
+
+// The types of the Type union.
+const (
+	typeNull            = 1
+	typeInt             = 2
+	typeFloatingPoint   = 3
+	typeBinary          = 4
+	typeUtf8            = 5
+	typeBool            = 6
+	typeDecimal         = 7
+	typeDate            = 8
+	typeTime            = 9
+	typeTimestamp       = 10
+	typeInterval        = 11
+	typeList            = 12
+	typeStruct          = 13
+	typeUnion           = 14
+	typeFixedSizeBinary = 15
+	typeFixedSizeList   = 16
+	typeMap             = 17
+	typeDuration        = 18
+	typeLargeBinary     = 19
+	typeLargeUtf8       = 20
+	typeLargeList       = 21
+)
+
+var typeNames = map[uint8]string{
+	typeInterval: "interval", typeList: "list", typeUnion: "union", typeFixedSizeList: "fixed size list",
+	typeMap: "map", typeDuration: "duration", typeLargeList: "large list",
+}
+
+// The precisions of floating point numbers.
+const (
+	precisionHalf   = 0
+	precisionSingle = 1
+	precisionDouble = 2
+)
+
+// The units of times, timestamps and durations.
+const (
+	unitSecond      = 0
+	unitMillisecond = 1
+	unitMicrosecond = 2
+	unitNanosecond  = 3
+)
+
+// The units of dates.
+const (
+	dateDay         = 0
+	dateMillisecond = 1
+)
+
+// The modes of unions.
+const (
+	unionSparse = 0
+	unionDense  = 1
+)
+
+// maxDepth limits the nesting of fields, so that corrupt metadata cannot
+// exhaust the stack.
+const maxDepth = 64
+
+// A dataType is the type of a field, along with the parameters of its type.
+type dataType struct {
+	id        uint8
+	bitWidth  int
+	signed    bool
+	precision int
+	scale     int
+	unit      int
+	byteWidth int
+	unionMode int
+}
+
+// A field is a field of a schema.
+type field struct {
+	name     string
+	nullable bool
+	typ      dataType
+	children []field
+
+	// The encoding of a dictionary-encoded field, whose values are indices
+	// into a dictionary of values of the field's type
+	dict *dictEncoding
+}
+
+type dictEncoding struct {
+	id    int64
+	index dataType
+}
+
+func decodeType(id uint8, t fbTable) dataType {
+	dt := dataType{id: id}
+	switch id {
+	case typeInt:
+		dt.bitWidth, dt.signed = int(t.int32(0, 0)), t.bool(1)
+	case typeFloatingPoint:
+		dt.precision = int(t.int16(0, precisionHalf))
+	case typeDecimal:
+		dt.scale, dt.bitWidth = int(t.int32(1, 0)), int(t.int32(2, 128))
+	case typeDate:
+		dt.unit = int(t.int16(0, dateMillisecond))
+	case typeTime:
+		dt.unit, dt.bitWidth = int(t.int16(0, unitMillisecond)), int(t.int32(1, 32))
+	case typeTimestamp, typeDuration:
+		dt.unit = int(t.int16(0, unitSecond))
+	case typeFixedSizeBinary:
+		dt.byteWidth = int(t.int32(0, 0))
+	case typeUnion:
+		dt.unionMode = int(t.int16(0, unionSparse))
+	}
+
+	return dt
+}
+
+func decodeFields(tables []fbTable, depth int) ([]field, error) {
+	if depth > maxDepth {
+		return nil, errors.New("fields are nested too deeply")
+	}
+
+	fields := make([]field, len(tables))
+	for i, t := range tables {
+		f := &fields[i]
+		f.name = t.string(0)
+		f.nullable = t.bool(1)
+		f.typ = decodeType(t.uint8(2, 0), t.table(3))
+		if d := t.table(4); d.f != nil {
+			f.dict = &dictEncoding{id: d.int64(0, 0), index: dataType{id: typeInt, bitWidth: 32, signed: true}}
+			if index := d.table(1); index.f != nil {
+				f.dict.index = decodeType(typeInt, index)
+			}
+		}
+
+		var err error
+		if f.children, err = decodeFields(t.tables(5), depth+1); err != nil {
+			return nil, err
+		}
+	}
+
+	return fields, nil
+}
+
+func decodeSchema(t fbTable) ([]field, error) {
+	if t.f == nil {
+		return nil, errors.New("missing schema")
+	}
+
+	if t.int16(0, 0) != 0 {
+		return nil, errors.New("big-endian data is not supported")
+	}
+
+	fields, err := decodeFields(t.tables(1), 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return fields, t.f.err
+}
+
+// A message is the metadata of an IPC message, which is followed by its body.
+type message struct {
+	version    int16
+	headerType uint8
+	header     fbTable
+	bodyLength int64
+}
+
+func decodeMessage(buf []byte) (*message, error) {
+	t := (&fbuf{b: buf}).root()
+	m := &message{
+		version:    t.int16(0, 0),
+		headerType: t.uint8(1, 0),
+		header:     t.table(2),
+		bodyLength: t.int64(3, 0),
+	}
+
+	if t.f.err != nil {
+		return nil, t.f.err
+	}
+
+	if m.version < metadataV4 {
+		return nil, fmt.Errorf("unsupported metadata version %d", m.version+1)
+	}
+
+	if m.bodyLength < 0 {
+		return nil, errInvalidMetadata
+	}
+
+	return m, nil
+}
+
+// A recordBatch is the metadata of a record batch: the lengths of the arrays
+// of each field, in depth-first order, and the buffers holding them within
+// the body of the message.
+type recordBatch struct {
+	length     int64
+	nodes      []fieldNode
+	buffers    []buffer
+	compressed bool
+}
+
+type fieldNode struct {
+	length    int64
+	nullCount int64
+}
+
+type buffer struct {
+	offset int64
+	length int64
+}
+
+func decodeRecordBatch(t fbTable, bodyLength int64) (*recordBatch, error) {
+	if t.f == nil {
+		return nil, errInvalidMetadata
+	}
+
+	rb := &recordBatch{length: t.int64(0, 0), compressed: t.has(3)}
+	start, n := t.vector(1, 16)
+	for i := 0; i < n; i++ {
+		p := start + 16*i
+		node := fieldNode{length: int64(t.f.u64(p)), nullCount: int64(t.f.u64(p + 8))}
+		if node.length < 0 || node.nullCount < 0 {
+			return nil, errInvalidMetadata
+		}
+
+		rb.nodes = append(rb.nodes, node)
+	}
+
+	start, n = t.vector(2, 16)
+	for i := 0; i < n; i++ {
+		p := start + 16*i
+		b := buffer{offset: int64(t.f.u64(p)), length: int64(t.f.u64(p + 8))}
+		if b.offset < 0 || b.length < 0 || b.offset > bodyLength-b.length {
+			return nil, errInvalidMetadata
+		}
+
+		rb.buffers = append(rb.buffers, b)
+	}
+
+	if rb.length < 0 {
+		return nil, errInvalidMetadata
+	}
+
+	return rb, t.f.err
+}
+
+// A block locates a message within a file.
+type block struct {
+	offset         int64
+	metaDataLength int32
+	bodyLength     int64
+}
+
+// A footer is the footer of a file, holding its schema and locating its
+// dictionary and record batches.
+type footer struct {
+	version       int16
+	schema        []field
+	dictionaries  []block
+	recordBatches []block
+}
+
+func decodeFooter(buf []byte) (*footer, error) {
+	t := (&fbuf{b: buf}).root()
+	schema, err := decodeSchema(t.table(1))
+	if err != nil {
+		return nil, err
+	}
+
+	ft := &footer{
+		version:       t.int16(0, 0),
+		schema:        schema,
+		dictionaries:  decodeBlocks(t, 2),
+		recordBatches: decodeBlocks(t, 3),
+	}
+
+	return ft, t.f.err
+}
+
+func decodeBlocks(t fbTable, slot int) []block {
+	start, n := t.vector(slot, 24)
+	blocks := make([]block, n)
+	for i := range blocks {
+		p := start + 24*i
+		blocks[i] = block{
+			offset:         int64(t.f.u64(p)),
+			metaDataLength: int32(t.f.u32(p + 8)),
+			bodyLength:     int64(t.f.u64(p + 16)),
+		}
+	}
+
+	return blocks
+}
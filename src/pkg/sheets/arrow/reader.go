@@ -0,0 +1,679 @@
+// Package arrow reads and writes Apache Arrow IPC files and streams as sheets,
+// so that formulas can be computed over columnar data.
+//
+// A file or stream is read as a sheet whose first row holds the names of its
+// fields, and whose following rows hold its records. The fields of structs
+// are named by their paths, such as "address.city". The buffers of a column
+// are read from a file when first needed, so reading a range within a single
+// column reads only that column.
+package arrow
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sync"
+
+	"github.com/mmihic/sheets/src/pkg/sheets"
+	"github.com/mmihic/sheets/src/pkg/sheets/internal/columnar"
+)
+
+// magic starts and ends every Arrow IPC file.
+const magic = "ARROW1"
+
+// continuation marks the start of an encapsulated message.
+const continuation = 0xffffffff
+
+// A Sheet is a sheet holding the records of an Arrow IPC file or stream.
+// Values are converted from the types of their fields: integers, floats and
+// decimals to Float64Values; booleans to BoolValues; strings and binary
+// values to StringValues; dates and timestamps to TimeValues in UTC; and nulls
+// to blanks. Times of day are TimeValues on 31 December 1899, so that they
+// are fractions of a day in formulas. Dictionary-encoded fields are read as
+// their values.
+type Sheet struct {
+	r       io.ReaderAt
+	size    int64
+	version int16
+	columns []column
+	batches []batch
+	dicts   []batch
+	table   *columnar.Table
+
+	mu         sync.Mutex
+	dictValues map[int][]sheets.Value
+}
+
+// A column is a field of the schema holding values, whose struct ancestors
+// are flattened.
+type column struct {
+	name string
+	typ  dataType
+	dict *dictEncoding
+
+	// The indices of the column's field node and its first buffer within
+	// record batches
+	node   int
+	buffer int
+
+	// The field nodes and validity buffers of the column's struct ancestors,
+	// which are null where any ancestor is null
+	parents []parent
+
+	// Set if the column's values cannot be read
+	err error
+}
+
+type parent struct {
+	node   int
+	buffer int
+}
+
+// A batch is a record batch, or the record batch of a dictionary batch,
+// whose body is at the given offset.
+type batch struct {
+	body int64
+	meta *recordBatch
+
+	// The dictionary batches for each dictionary, in effect for a record
+	// batch: a dictionary batch followed by its deltas
+	dicts map[int64][]int
+}
+
+var _ sheets.Sheet = &Sheet{}
+
+// A File is a Sheet read from a file, which must be closed once the sheet is
+// no longer used.
+type File struct {
+	*Sheet
+	f *os.File
+}
+
+// Close closes the file.
+func (f *File) Close() error {
+	return f.f.Close()
+}
+
+// Open opens the Arrow IPC file at the given path. The file is read as its
+// columns are used, and must be closed once the sheet is no longer used.
+func Open(name string) (*File, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	s, err := Read(f, info.Size())
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	return &File{Sheet: s, f: f}, nil
+}
+
+// Read reads an Arrow IPC file of the given size. Only the metadata of the
+// file and of its record batches is read immediately; the buffers of columns
+// are read from r as they are used.
+func Read(r io.ReaderAt, size int64) (*Sheet, error) {
+	s, err := read(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("invalid arrow file: %w", err)
+	}
+
+	return s, nil
+}
+
+// ReadStream reads an Arrow IPC stream, which is read completely since
+// streams cannot be read out of order.
+func ReadStream(r io.Reader) (*Sheet, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := readStream(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid arrow stream: %w", err)
+	}
+
+	return s, nil
+}
+
+func read(r io.ReaderAt, size int64) (*Sheet, error) {
+	// The file starts with the magic number, padded to eight bytes, and ends
+	// with the length of the footer followed by the magic number
+	if size < int64(8+4+len(magic)) {
+		return nil, errors.New("file is too short")
+	}
+
+	var head [len(magic)]byte
+	var tail [4 + len(magic)]byte
+	if _, err := r.ReadAt(head[:], 0); err != nil {
+		return nil, err
+	}
+
+	if _, err := r.ReadAt(tail[:], size-int64(len(tail))); err != nil {
+		return nil, err
+	}
+
+	if string(head[:]) != magic || string(tail[4:]) != magic {
+		return nil, errors.New("missing magic number")
+	}
+
+	footerLen := int64(int32(binary.LittleEndian.Uint32(tail[:])))
+	end := size - int64(len(tail)) - footerLen
+	if footerLen < 0 || end < 8 {
+		return nil, errors.New("invalid footer length")
+	}
+
+	buf := make([]byte, footerLen)
+	if _, err := r.ReadAt(buf, end); err != nil {
+		return nil, err
+	}
+
+	ft, err := decodeFooter(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Sheet{r: r, size: end, version: ft.version}
+	if err := s.addColumns(ft.schema); err != nil {
+		return nil, err
+	}
+
+	dicts := make(map[int64][]int)
+	for _, blk := range ft.dictionaries {
+		m, body, err := s.readBlock(blk)
+		if err != nil {
+			return nil, err
+		}
+
+		if m.headerType != headerDictionaryBatch {
+			return nil, errors.New("expected a dictionary batch")
+		}
+
+		if dicts, err = s.addDictionary(m, body, dicts); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, blk := range ft.recordBatches {
+		m, body, err := s.readBlock(blk)
+		if err != nil {
+			return nil, err
+		}
+
+		if m.headerType != headerRecordBatch {
+			return nil, errors.New("expected a record batch")
+		}
+
+		if err := s.addRecordBatch(m, body, dicts); err != nil {
+			return nil, err
+		}
+	}
+
+	s.newTable()
+	return s, nil
+}
+
+// readBlock reads the metadata of the message in a block of a file, returning
+// the message and the offset of its body.
+func (s *Sheet) readBlock(blk block) (*message, int64, error) {
+	if blk.offset < 8 || blk.metaDataLength <= 0 || blk.offset > s.size-int64(blk.metaDataLength) {
+		return nil, 0, errInvalidMetadata
+	}
+
+	buf := make([]byte, blk.metaDataLength)
+	if _, err := s.r.ReadAt(buf, blk.offset); err != nil {
+		return nil, 0, err
+	}
+
+	m, n, err := decodeEncapsulated(buf)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	body := blk.offset + int64(n)
+	if m == nil || body > s.size-m.bodyLength {
+		return nil, 0, errInvalidMetadata
+	}
+
+	return m, body, nil
+}
+
+func readStream(data []byte) (*Sheet, error) {
+	s := &Sheet{r: bytes.NewReader(data), size: int64(len(data))}
+	dicts := make(map[int64][]int)
+	schema := false
+	for pos := int64(0); pos < int64(len(data)); {
+		m, n, err := decodeEncapsulated(data[pos:])
+		if err != nil {
+			return nil, err
+		}
+
+		if m == nil {
+			break
+		}
+
+		body := pos + int64(n)
+		if body > s.size-m.bodyLength {
+			return nil, errTruncatedBuffer
+		}
+
+		if !schema && m.headerType != headerSchema {
+			return nil, errors.New("expected a schema")
+		}
+
+		switch m.headerType {
+		case headerSchema:
+			if schema {
+				return nil, errors.New("unexpected schema")
+			}
+
+			fields, err := decodeSchema(m.header)
+			if err != nil {
+				return nil, err
+			}
+
+			s.version, schema = m.version, true
+			if err := s.addColumns(fields); err != nil {
+				return nil, err
+			}
+		case headerDictionaryBatch:
+			if dicts, err = s.addDictionary(m, body, dicts); err != nil {
+				return nil, err
+			}
+		case headerRecordBatch:
+			if err := s.addRecordBatch(m, body, dicts); err != nil {
+				return nil, err
+			}
+		}
+
+		pos = body + m.bodyLength
+	}
+
+	if !schema {
+		return nil, errors.New("expected a schema")
+	}
+
+	s.newTable()
+	return s, nil
+}
+
+// decodeEncapsulated decodes the metadata of the encapsulated message at the
+// start of a buffer, returning the message and the length of its prefix and
+// metadata, which is followed by its body. Returns a nil message at the end
+// of a stream.
+func decodeEncapsulated(buf []byte) (*message, int, error) {
+	if len(buf) < 4 {
+		return nil, 0, errTruncatedBuffer
+	}
+
+	// Messages written before the continuation marker was introduced start
+	// with the length of their metadata
+	n, prefix := binary.LittleEndian.Uint32(buf), 4
+	if n == continuation {
+		if len(buf) < 8 {
+			return nil, 0, errTruncatedBuffer
+		}
+
+		n, prefix = binary.LittleEndian.Uint32(buf[4:]), 8
+	}
+
+	if n == 0 {
+		return nil, prefix, nil
+	}
+
+	if uint64(n) > uint64(len(buf)-prefix) {
+		return nil, 0, errTruncatedBuffer
+	}
+
+	m, err := decodeMessage(buf[prefix : prefix+int(n)])
+	return m, prefix + int(n), err
+}
+
+// addColumns adds the columns of a schema.
+func (s *Sheet) addColumns(fields []field) error {
+	var node, buf int
+	return s.flatten(fields, "", &node, &buf, nil)
+}
+
+func (s *Sheet) flatten(fields []field, prefix string, node, buf *int, parents []parent) error {
+	for _, f := range fields {
+		name := f.name
+		if prefix != "" {
+			name = prefix + "." + f.name
+		}
+
+		if f.typ.id == typeStruct && f.dict == nil {
+			p := parent{node: *node, buffer: *buf}
+			*node, *buf = *node+1, *buf+1
+			if err := s.flatten(f.children, name, node, buf, append(parents[:len(parents):len(parents)], p)); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		c := column{name: name, typ: f.typ, dict: f.dict, node: *node, buffer: *buf, parents: parents}
+		if !supported(f.typ) {
+			c.err = fmt.Errorf("unsupported type %s", typeNames[f.typ.id])
+		}
+
+		nodes, buffers, err := s.layout(f, 0)
+		if err != nil {
+			return fmt.Errorf("field '%s': %w", name, err)
+		}
+
+		*node, *buf = *node+nodes, *buf+buffers
+		s.columns = append(s.columns, c)
+	}
+
+	return nil
+}
+
+// layout returns the number of field nodes and buffers holding a field and
+// its children, so that the buffers of the fields that follow can be found
+// even if the field cannot be read.
+func (s *Sheet) layout(f field, depth int) (int, int, error) {
+	if f.dict != nil {
+		return 1, 2, nil
+	}
+
+	var buffers int
+	switch f.typ.id {
+	case typeNull:
+		buffers = 0
+	case typeInt, typeFloatingPoint, typeBool, typeDecimal, typeDate, typeTime, typeTimestamp,
+		typeInterval, typeDuration, typeFixedSizeBinary:
+		buffers = 2
+	case typeBinary, typeUtf8, typeLargeBinary, typeLargeUtf8:
+		buffers = 3
+	case typeStruct, typeFixedSizeList:
+		buffers = 1
+	case typeList, typeLargeList, typeMap:
+		buffers = 2
+	case typeUnion:
+		// Unions have no validity buffer since V5
+		buffers = 1
+		if f.typ.unionMode == unionDense {
+			buffers++
+		}
+
+		if s.version < metadataV5 {
+			buffers++
+		}
+	default:
+		return 0, 0, fmt.Errorf("unsupported type %d", f.typ.id)
+	}
+
+	nodes := 1
+	if depth > maxDepth {
+		return 0, 0, errors.New("fields are nested too deeply")
+	}
+
+	for _, child := range f.children {
+		n, b, err := s.layout(child, depth+1)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		nodes, buffers = nodes+n, buffers+b
+	}
+
+	return nodes, buffers, nil
+}
+
+// addDictionary adds a dictionary batch, returning the dictionaries in effect
+// after it.
+func (s *Sheet) addDictionary(m *message, body int64, dicts map[int64][]int) (map[int64][]int, error) {
+	rb, err := decodeRecordBatch(m.header.table(1), m.bodyLength)
+	if err != nil {
+		return nil, err
+	}
+
+	id := m.header.int64(0, 0)
+	s.dicts = append(s.dicts, batch{body: body, meta: rb})
+
+	next := make(map[int64][]int, len(dicts)+1)
+	for k, v := range dicts {
+		next[k] = v
+	}
+
+	if m.header.bool(2) {
+		next[id] = append(append([]int{}, dicts[id]...), len(s.dicts)-1)
+	} else {
+		next[id] = []int{len(s.dicts) - 1}
+	}
+
+	return next, nil
+}
+
+func (s *Sheet) addRecordBatch(m *message, body int64, dicts map[int64][]int) error {
+	rb, err := decodeRecordBatch(m.header, m.bodyLength)
+	if err != nil {
+		return err
+	}
+
+	if rb.length > math.MaxInt32 {
+		return errors.New("record batch is too long")
+	}
+
+	s.batches = append(s.batches, batch{body: body, meta: rb, dicts: dicts})
+	return nil
+}
+
+func (s *Sheet) newTable() {
+	names := make([]string, len(s.columns))
+	for i, c := range s.columns {
+		names[i] = c.name
+	}
+
+	chunkRows := make([]int, len(s.batches))
+	for i, b := range s.batches {
+		chunkRows[i] = int(b.meta.length)
+	}
+
+	s.table = columnar.NewTable(names, chunkRows, s.load)
+}
+
+// Columns returns the names of the columns of the sheet.
+func (s *Sheet) Columns() []string {
+	return s.table.Columns()
+}
+
+// Dimensions returns the dimensions of the sheet, including the header row.
+func (s *Sheet) Dimensions() sheets.Dimensions {
+	return s.table.Dimensions()
+}
+
+// Get returns the value of a cell, reading the buffers of its column within
+// the record batch holding it unless they were the most recently read.
+func (s *Sheet) Get(ctx context.Context, pos sheets.Pos) (sheets.Value, error) {
+	return s.table.Get(ctx, pos)
+}
+
+// Range returns the values of a range of cells, reading only the columns
+// within the range.
+func (s *Sheet) Range(ctx context.Context, r sheets.Range) (sheets.ValueRange, error) {
+	return s.table.Range(ctx, r)
+}
+
+// load reads the values of a column within a record batch.
+func (s *Sheet) load(ctx context.Context, chunk, col int) ([]sheets.Value, error) {
+	c := &s.columns[col]
+	values, err := s.loadColumn(ctx, &s.batches[chunk], c)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read column '%s' of record batch %d: %w", c.name, chunk, err)
+	}
+
+	return values, nil
+}
+
+func (s *Sheet) loadColumn(ctx context.Context, b *batch, c *column) ([]sheets.Value, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var (
+		values []sheets.Value
+		err    error
+	)
+
+	if c.dict != nil {
+		values, err = s.readDictionaryColumn(b, c)
+	} else {
+		values, err = s.readArray(b, c.node, c.buffer, c.typ)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(values)) != b.meta.length {
+		return nil, fmt.Errorf("found %d values, expected %d", len(values), b.meta.length)
+	}
+
+	// Values are null where any struct holding them is null
+	for _, p := range c.parents {
+		if p.node >= len(b.meta.nodes) || p.buffer >= len(b.meta.buffers) {
+			return nil, errInvalidMetadata
+		}
+
+		if b.meta.nodes[p.node].nullCount == 0 {
+			continue
+		}
+
+		validity, err := s.readBuffer(b, p.buffer)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(validity) < (len(values)+7)/8 {
+			return nil, errTruncatedBuffer
+		}
+
+		for i := range values {
+			if validity[i/8]&(1<<(i%8)) == 0 {
+				values[i] = sheets.BlankValue{}
+			}
+		}
+	}
+
+	return values, nil
+}
+
+// readArray reads the array of a field node of a supported type, starting at
+// the given buffer.
+func (s *Sheet) readArray(b *batch, node, first int, typ dataType) ([]sheets.Value, error) {
+	if b.meta.compressed {
+		return nil, errors.New("compressed record batches are not supported")
+	}
+
+	n := numBuffers(typ)
+	if node >= len(b.meta.nodes) || first+n > len(b.meta.buffers) {
+		return nil, errInvalidMetadata
+	}
+
+	bufs := make([][]byte, n)
+	for i := range bufs {
+		var err error
+		if bufs[i], err = s.readBuffer(b, first+i); err != nil {
+			return nil, err
+		}
+	}
+
+	return decodeArray(typ, b.meta.nodes[node], bufs)
+}
+
+func (s *Sheet) readBuffer(b *batch, i int) ([]byte, error) {
+	buf := b.meta.buffers[i]
+	if buf.length == 0 {
+		return nil, nil
+	}
+
+	data := make([]byte, buf.length)
+	if _, err := s.r.ReadAt(data, b.body+buf.offset); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// readDictionaryColumn reads a dictionary-encoded column, whose values are
+// indices into the dictionary in effect for the record batch.
+func (s *Sheet) readDictionaryColumn(b *batch, c *column) ([]sheets.Value, error) {
+	indices, err := s.readArray(b, c.node, c.buffer, c.dict.index)
+	if err != nil {
+		return nil, err
+	}
+
+	var dict []sheets.Value
+	refs, ok := b.dicts[c.dict.id]
+	if !ok {
+		return nil, fmt.Errorf("missing dictionary %d", c.dict.id)
+	}
+
+	for _, ref := range refs {
+		values, err := s.dictionary(ref, c.typ)
+		if err != nil {
+			return nil, err
+		}
+
+		dict = append(dict, values...)
+	}
+
+	values := make([]sheets.Value, len(indices))
+	for i, index := range indices {
+		n, ok := index.(sheets.Float64Value)
+		if !ok {
+			values[i] = sheets.BlankValue{}
+			continue
+		}
+
+		if n < 0 || int(n) >= len(dict) {
+			return nil, fmt.Errorf("invalid dictionary index %d", int64(n))
+		}
+
+		values[i] = dict[int(n)]
+	}
+
+	return values, nil
+}
+
+// dictionary returns the values of a dictionary batch, which are read once.
+func (s *Sheet) dictionary(i int, typ dataType) ([]sheets.Value, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if values, ok := s.dictValues[i]; ok {
+		return values, nil
+	}
+
+	values, err := s.readArray(&s.dicts[i], 0, 0, typ)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read dictionary: %w", err)
+	}
+
+	if s.dictValues == nil {
+		s.dictValues = make(map[int][]sheets.Value)
+	}
+
+	s.dictValues[i] = values
+	return values, nil
+}
@@ -0,0 +1,525 @@
+package arrow
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"math"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mmihic/sheets/src/pkg/sheets"
+	"github.com/mmihic/sheets/src/pkg/sheets/internal/columnar/columnartest"
+)
+
+// A testBatch is a record batch of a stream or file built by buildStream or
+// buildFile, holding the field nodes and buffers of its columns.
+type testBatch struct {
+	length  int64
+	nodes   []fieldNode
+	buffers [][]byte
+}
+
+// encode returns the RecordBatch header and body of the batch.
+func (b testBatch) encode() ([]fbfield, []byte) {
+	var nodes, buffers, body []byte
+	for _, n := range b.nodes {
+		nodes = binary.LittleEndian.AppendUint64(nodes, uint64(n.length))
+		nodes = binary.LittleEndian.AppendUint64(nodes, uint64(n.nullCount))
+	}
+
+	for _, data := range b.buffers {
+		buffers = binary.LittleEndian.AppendUint64(buffers, uint64(len(body)))
+		buffers = binary.LittleEndian.AppendUint64(buffers, uint64(len(data)))
+		body = append(body, data...)
+		for len(body)%8 != 0 {
+			body = append(body, 0)
+		}
+	}
+
+	return []fbfield{
+		{0, b.length},
+		{1, fbstructs{n: len(b.nodes), data: nodes}},
+		{2, fbstructs{n: len(b.buffers), data: buffers}},
+	}, body
+}
+
+// A testMessage is a record batch, or a dictionary batch if dict is set.
+type testMessage struct {
+	batch testBatch
+	dict  bool
+	id    int64
+	delta bool
+}
+
+func recordBatchMessage(b testBatch) testMessage {
+	return testMessage{batch: b}
+}
+
+func dictionaryMessage(id int64, delta bool, b testBatch) testMessage {
+	return testMessage{batch: b, dict: true, id: id, delta: delta}
+}
+
+// testField returns a nullable field of the given type.
+func testField(name string, id uint8, typ []fbfield, children ...[]fbfield) []fbfield {
+	if children == nil {
+		children = [][]fbfield{}
+	}
+
+	return []fbfield{{0, name}, {1, true}, {2, id}, {3, typ}, {5, children}}
+}
+
+// dictionaryField returns a field of the given type, encoded by the
+// dictionary with the given id with indices of the given width.
+func dictionaryField(name string, id uint8, typ []fbfield, dictID int64, indexWidth int32) []fbfield {
+	return append(testField(name, id, typ), fbfield{
+		4, []fbfield{{0, dictID}, {1, []fbfield{{0, indexWidth}, {1, true}}}},
+	})
+}
+
+// buildStream builds a stream with the given fields and messages.
+func buildStream(fields [][]fbfield, messages ...testMessage) []byte {
+	var buf bytes.Buffer
+	wr := &writer{w: &buf}
+	_, _ = wr.writeMessage(headerSchema, []fbfield{{1, fields}}, nil)
+	for _, m := range messages {
+		_, _ = m.write(wr)
+	}
+
+	_ = wr.write([]byte{0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0})
+	return buf.Bytes()
+}
+
+// buildFile builds a file with the given fields and messages.
+func buildFile(fields [][]fbfield, messages ...testMessage) []byte {
+	var buf bytes.Buffer
+	wr := &writer{w: &buf}
+	_ = wr.write([]byte(magic + "\x00\x00"))
+	_, _ = wr.writeMessage(headerSchema, []fbfield{{1, fields}}, nil)
+
+	var dicts, batches []byte
+	for _, m := range messages {
+		blk, _ := m.write(wr)
+		blocks := &batches
+		if m.dict {
+			blocks = &dicts
+		}
+
+		*blocks = binary.LittleEndian.AppendUint64(*blocks, uint64(blk.offset))
+		*blocks = binary.LittleEndian.AppendUint64(*blocks, uint64(blk.metaDataLength))
+		*blocks = binary.LittleEndian.AppendUint64(*blocks, uint64(blk.bodyLength))
+	}
+
+	_ = wr.write([]byte{0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0})
+	ft := mustBuildFlatbuffer([]fbfield{
+		{0, int16(metadataV5)},
+		{1, []fbfield{{1, fields}}},
+		{2, fbstructs{n: len(dicts) / 24, data: dicts}},
+		{3, fbstructs{n: len(batches) / 24, data: batches}},
+	})
+
+	ft = binary.LittleEndian.AppendUint32(ft, uint32(len(ft)))
+	_ = wr.write(append(ft, magic...))
+	return buf.Bytes()
+}
+
+func (m testMessage) write(wr *writer) (block, error) {
+	header, body := m.batch.encode()
+	if !m.dict {
+		return wr.writeMessage(headerRecordBatch, header, body)
+	}
+
+	return wr.writeMessage(headerDictionaryBatch, []fbfield{{0, m.id}, {1, header}, {2, m.delta}}, body)
+}
+
+// mixedFields are fields of several types: an int, a dictionary-encoded
+// string, a struct of a string and a short, a list, and a double.
+var mixedFields = [][]fbfield{
+	testField("id", typeInt, []fbfield{{0, int32(32)}, {1, true}}),
+	dictionaryField("color", typeUtf8, []fbfield{}, 0, 8),
+	testField("addr", typeStruct, []fbfield{},
+		testField("city", typeUtf8, []fbfield{}),
+		testField("zip", typeInt, []fbfield{{0, int32(16)}, {1, true}}),
+	),
+	testField("tags", typeList, []fbfield{},
+		testField("item", typeInt, []fbfield{{0, int32(32)}, {1, true}}),
+	),
+	testField("score", typeFloatingPoint, []fbfield{{0, int16(precisionDouble)}}),
+}
+
+func mixedBatch(length int64, nulls []int64, buffers ...[]byte) testBatch {
+	b := testBatch{length: length, buffers: buffers}
+	for _, n := range nulls {
+		b.nodes = append(b.nodes, fieldNode{length: length, nullCount: n})
+	}
+
+	// The list has no items
+	b.nodes[6].length = 0
+	return b
+}
+
+func float64s(values ...float64) []byte {
+	var b []byte
+	for _, v := range values {
+		b = binary.LittleEndian.AppendUint64(b, math.Float64bits(v))
+	}
+
+	return b
+}
+
+var mixedMessages = []testMessage{
+	dictionaryMessage(0, false, testBatch{
+		length: 2,
+		nodes:  []fieldNode{{length: 2}},
+		buffers: [][]byte{
+			nil, le32(0, 3, 8), []byte("redgreen"),
+		},
+	}),
+	recordBatchMessage(mixedBatch(2, []int64{1, 0, 1, 0, 1, 0, 0, 0},
+		[]byte{0b01}, le32(1, 0), // id
+		nil, []byte{1, 0}, // color
+		[]byte{0b01},                           // addr
+		nil, le32(0, 4, 8), []byte("OsloRome"), // addr.city
+		[]byte{0b10}, le16(0, 5), // addr.zip
+		nil, le32(0, 0, 0), nil, nil, // tags
+		nil, float64s(0.5, -1), // score
+	)),
+	dictionaryMessage(0, true, testBatch{
+		length:  1,
+		nodes:   []fieldNode{{length: 1}},
+		buffers: [][]byte{nil, le32(0, 4), []byte("blue")},
+	}),
+	recordBatchMessage(mixedBatch(1, []int64{0, 0, 0, 0, 0, 0, 0, 0},
+		nil, le32(3), // id
+		nil, []byte{2}, // color
+		nil,                             // addr
+		nil, le32(0, 4), []byte("Bern"), // addr.city
+		nil, le16(3000), // addr.zip
+		nil, le32(0, 0), nil, nil, // tags
+		nil, float64s(7), // score
+	)),
+}
+
+func TestReadMixed(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		read func() (*Sheet, error)
+	}{
+		{"stream", func() (*Sheet, error) {
+			return ReadStream(bytes.NewReader(buildStream(mixedFields, mixedMessages...)))
+		}},
+		{"file", func() (*Sheet, error) {
+			data := buildFile(mixedFields, mixedMessages...)
+			return Read(bytes.NewReader(data), int64(len(data)))
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := tt.read()
+			require.NoError(t, err)
+			assert.Equal(t, []string{"id", "color", "addr.city", "addr.zip", "tags", "score"}, s.Columns())
+			assert.Equal(t, sheets.Dimensions{EndRow: 3, EndCol: 5}, s.Dimensions())
+
+			assert.Equal(t, [][]sheets.Value{
+				{sheets.StringValue("id"), sheets.StringValue("color"), sheets.StringValue("addr.city"),
+					sheets.StringValue("addr.zip")},
+				{sheets.Float64Value(1), sheets.StringValue("green"), sheets.StringValue("Oslo"),
+					sheets.BlankValue{}},
+				{sheets.BlankValue{}, sheets.StringValue("red"), sheets.BlankValue{}, sheets.BlankValue{}},
+				{sheets.Float64Value(3), sheets.StringValue("blue"), sheets.StringValue("Bern"),
+					sheets.Float64Value(3000)},
+			}, columnartest.ReadRange(t, s, sheets.Range{EndRow: 3, EndCol: 3}))
+
+			// Columns following an unsupported column are read
+			assert.Equal(t, [][]sheets.Value{
+				{sheets.StringValue("score")},
+				{sheets.Float64Value(0.5)},
+				{sheets.Float64Value(-1)},
+				{sheets.Float64Value(7)},
+			}, columnartest.ReadRange(t, s, sheets.Range{EndRow: 3, StartCol: 5, EndCol: 5}))
+
+			_, err = s.Get(context.Background(), sheets.Pos{Row: 1, Col: 4})
+			assert.EqualError(t, err, "unable to read column 'tags' of record batch 0: unsupported type list")
+		})
+	}
+}
+
+func TestReadDictionaries(t *testing.T) {
+	fields := [][]fbfield{dictionaryField("v", typeUtf8, []fbfield{}, 7, 32)}
+	dict := func(delta bool, values string) testMessage {
+		offsets := []int32{0}
+		for i := range values {
+			offsets = append(offsets, int32(i+1))
+		}
+
+		return dictionaryMessage(7, delta, testBatch{
+			length:  int64(len(values)),
+			nodes:   []fieldNode{{length: int64(len(values))}},
+			buffers: [][]byte{nil, le32(offsets...), []byte(values)},
+		})
+	}
+
+	indices := func(validity []byte, nulls int64, values ...int32) testMessage {
+		return recordBatchMessage(testBatch{
+			length:  int64(len(values)),
+			nodes:   []fieldNode{{length: int64(len(values)), nullCount: nulls}},
+			buffers: [][]byte{validity, le32(values...)},
+		})
+	}
+
+	t.Run("replaced", func(t *testing.T) {
+		s, err := ReadStream(bytes.NewReader(buildStream(fields,
+			dict(false, "ab"),
+			indices([]byte{0b101}, 1, 1, 0, 0),
+			dict(true, "c"),
+			indices(nil, 0, 2),
+			dict(false, "d"),
+			indices(nil, 0, 0),
+		)))
+		require.NoError(t, err)
+
+		assert.Equal(t, [][]sheets.Value{
+			{sheets.StringValue("v")},
+			{sheets.StringValue("b")},
+			{sheets.BlankValue{}},
+			{sheets.StringValue("a")},
+			{sheets.StringValue("c")},
+			{sheets.StringValue("d")},
+		}, columnartest.ReadAll(t, s))
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		s, err := ReadStream(bytes.NewReader(buildStream(fields, indices(nil, 0, 0))))
+		require.NoError(t, err)
+
+		_, err = s.Get(context.Background(), sheets.Pos{Row: 1})
+		assert.EqualError(t, err, "unable to read column 'v' of record batch 0: missing dictionary 7")
+	})
+
+	t.Run("invalid index", func(t *testing.T) {
+		s, err := ReadStream(bytes.NewReader(buildStream(fields, dict(false, "ab"), indices(nil, 0, 2))))
+		require.NoError(t, err)
+
+		_, err = s.Get(context.Background(), sheets.Pos{Row: 1})
+		assert.EqualError(t, err, "unable to read column 'v' of record batch 0: invalid dictionary index 2")
+	})
+}
+
+func TestReadNestedStructs(t *testing.T) {
+	fields := [][]fbfield{
+		testField("a", typeStruct, []fbfield{},
+			testField("b", typeStruct, []fbfield{},
+				testField("c", typeBool, []fbfield{}),
+			),
+		),
+	}
+
+	s, err := ReadStream(bytes.NewReader(buildStream(fields, recordBatchMessage(testBatch{
+		length:  3,
+		nodes:   []fieldNode{{length: 3, nullCount: 1}, {length: 3, nullCount: 1}, {length: 3}},
+		buffers: [][]byte{{0b011}, {0b110}, nil, {0b111}},
+	}))))
+	require.NoError(t, err)
+
+	assert.Equal(t, [][]sheets.Value{
+		{sheets.StringValue("a.b.c")},
+		{sheets.BlankValue{}},
+		{sheets.BoolValue(true)},
+		{sheets.BlankValue{}},
+	}, columnartest.ReadAll(t, s))
+}
+
+// A countingReader counts the reads of each part of a file.
+type countingReader struct {
+	r     *bytes.Reader
+	mu    sync.Mutex
+	reads map[int64]int
+}
+
+func (r *countingReader) ReadAt(p []byte, off int64) (int, error) {
+	r.mu.Lock()
+	r.reads[off]++
+	r.mu.Unlock()
+	return r.r.ReadAt(p, off)
+}
+
+func TestReadColumnLazily(t *testing.T) {
+	rows := [][]sheets.Value{{sheets.StringValue("a"), sheets.StringValue("b"), sheets.StringValue("c")}}
+	for i := 0; i < 10; i++ {
+		rows = append(rows, []sheets.Value{
+			sheets.Float64Value(i), sheets.StringValue("x"), sheets.BoolValue(i%2 == 0),
+		})
+	}
+
+	ms, err := sheets.NewMutableSheet(rows)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, Write(context.Background(), &buf, ms, WithBatchSize(4)))
+
+	r := &countingReader{r: bytes.NewReader(buf.Bytes()), reads: make(map[int64]int)}
+	s, err := Read(r, int64(buf.Len()))
+	require.NoError(t, err)
+
+	footerReads := len(r.reads)
+	ctx := context.Background()
+	vr, err := s.Range(ctx, sheets.Range{StartRow: 1, EndRow: 10, StartCol: 2, EndCol: 2})
+	require.NoError(t, err)
+
+	var values []sheets.Value
+	for vr.Next(ctx) {
+		values = append(values, vr.Value())
+	}
+
+	require.NoError(t, vr.Err())
+	assert.Len(t, values, 10)
+	assert.Equal(t, sheets.BoolValue(true), values[0])
+	assert.Equal(t, sheets.BoolValue(false), values[9])
+
+	// Only the data buffer of the column in each of the three record batches
+	// was read, since the column has no nulls
+	assert.Len(t, r.reads, footerReads+3)
+	for _, b := range s.batches {
+		buf := b.meta.buffers[s.columns[2].buffer+1]
+		assert.Equal(t, 1, r.reads[b.body+buf.offset])
+	}
+}
+
+// encapsulate returns an encapsulated message holding the given metadata,
+// with or without the continuation marker.
+func encapsulate(meta []byte, marker bool) []byte {
+	var b []byte
+	if marker {
+		b = binary.LittleEndian.AppendUint32(b, continuation)
+	}
+
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(meta)))
+	return append(b, meta...)
+}
+
+func TestReadLegacyStream(t *testing.T) {
+	schema := mustBuildFlatbuffer([]fbfield{
+		{0, int16(metadataV4)},
+		{1, uint8(headerSchema)},
+		{2, []fbfield{{1, [][]fbfield{testField("a", typeNull, []fbfield{})}}}},
+	})
+
+	batch := mustBuildFlatbuffer([]fbfield{
+		{0, int16(metadataV4)},
+		{1, uint8(headerRecordBatch)},
+		{2, []fbfield{{0, int64(2)}, {1, fbstructs{n: 1, data: le64(2, 2)}}, {2, fbstructs{}}}},
+	})
+
+	data := append(encapsulate(schema, false), encapsulate(batch, false)...)
+	data = append(data, 0, 0, 0, 0)
+
+	s, err := ReadStream(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, [][]sheets.Value{
+		{sheets.StringValue("a")},
+		{sheets.BlankValue{}},
+		{sheets.BlankValue{}},
+	}, columnartest.ReadAll(t, s))
+}
+
+func TestReadCompressed(t *testing.T) {
+	fields := [][]fbfield{testField("a", typeBool, []fbfield{})}
+	batch := testBatch{length: 1, nodes: []fieldNode{{length: 1}}, buffers: [][]byte{nil, {1}}}
+	header, body := batch.encode()
+	// Compressed with LZ4 frames
+	header = append(header, fbfield{3, []fbfield{{0, uint8(0)}}})
+
+	var buf bytes.Buffer
+	wr := &writer{w: &buf}
+	_, _ = wr.writeMessage(headerSchema, []fbfield{{1, fields}}, nil)
+	_, _ = wr.writeMessage(headerRecordBatch, header, body)
+
+	s, err := ReadStream(&buf)
+	require.NoError(t, err)
+
+	_, err = s.Get(context.Background(), sheets.Pos{Row: 1})
+	assert.EqualError(t, err,
+		"unable to read column 'a' of record batch 0: compressed record batches are not supported")
+}
+
+func TestReadErrors(t *testing.T) {
+	fields := [][]fbfield{testField("a", typeBool, []fbfield{})}
+	stream := buildStream(fields, recordBatchMessage(testBatch{
+		length: 1, nodes: []fieldNode{{length: 1}}, buffers: [][]byte{nil, {1}},
+	}))
+
+	file := buildFile(fields)
+	var batchOnly bytes.Buffer
+	header, body := testBatch{}.encode()
+	_, _ = (&writer{w: &batchOnly}).writeMessage(headerRecordBatch, header, body)
+
+	v3 := mustBuildFlatbuffer([]fbfield{
+		{0, int16(2)},
+		{1, uint8(headerSchema)},
+		{2, []fbfield{{1, fields}}},
+	})
+
+	bigEndian := mustBuildFlatbuffer([]fbfield{
+		{0, int16(metadataV5)},
+		{1, uint8(headerSchema)},
+		{2, []fbfield{{0, int16(1)}, {1, fields}}},
+	})
+
+	unknownType := mustBuildFlatbuffer([]fbfield{
+		{0, int16(metadataV5)},
+		{1, uint8(headerSchema)},
+		{2, []fbfield{{1, [][]fbfield{testField("x", 99, []fbfield{})}}}},
+	})
+
+	for _, tt := range []struct {
+		name string
+		data []byte
+		err  string
+	}{
+		{"empty", nil, "invalid arrow stream: expected a schema"},
+		{"end of stream", []byte{0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0}, "invalid arrow stream: expected a schema"},
+		{"record batch first", batchOnly.Bytes(), "invalid arrow stream: expected a schema"},
+		{"truncated", stream[:len(stream)-16], "invalid arrow stream: truncated buffer"},
+		{"two schemas", append(append([]byte{}, stream[:len(stream)-8]...), stream...),
+			"invalid arrow stream: unexpected schema"},
+		{"old version", encapsulate(v3, true), "invalid arrow stream: unsupported metadata version 3"},
+		{"big-endian", encapsulate(bigEndian, true), "invalid arrow stream: big-endian data is not supported"},
+		{"unknown type", encapsulate(unknownType, true), "invalid arrow stream: field 'x': unsupported type 99"},
+		{"file", file, "invalid arrow stream: truncated buffer"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadStream(bytes.NewReader(tt.data))
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+
+	corrupt := func(f func(b []byte)) []byte {
+		b := append([]byte{}, file...)
+		f(b)
+		return b
+	}
+
+	for _, tt := range []struct {
+		name string
+		data []byte
+		err  string
+	}{
+		{"too short", []byte(magic + "\x00\x00" + magic), "invalid arrow file: file is too short"},
+		{"stream", stream, "invalid arrow file: missing magic number"},
+		{"missing trailing magic", corrupt(func(b []byte) { b[len(b)-1] = '2' }),
+			"invalid arrow file: missing magic number"},
+		{"invalid footer length", corrupt(func(b []byte) {
+			binary.LittleEndian.PutUint32(b[len(b)-10:], uint32(len(b)))
+		}), "invalid arrow file: invalid footer length"},
+		{"truncated footer", corrupt(func(b []byte) {
+			binary.LittleEndian.PutUint32(b[len(b)-10:], 8)
+		}), "invalid arrow file: missing schema"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Read(bytes.NewReader(tt.data), int64(len(tt.data)))
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+}
@@ -0,0 +1,156 @@
+//go:build ignore
+
+// Generates arrow_go.arrow and arrow_go.arrows with the IPC writers of the
+// Apache Arrow Go implementation, so that the reader is tested against files
+// and streams it did not write. Run from the package directory with:
+//
+//	go run testdata/generate.go
+package main
+
+import (
+	"log"
+	"os"
+	"time"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/array"
+	"github.com/apache/arrow/go/v14/arrow/decimal128"
+	"github.com/apache/arrow/go/v14/arrow/ipc"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+)
+
+var (
+	address = arrow.StructOf(
+		arrow.Field{Name: "city", Type: arrow.BinaryTypes.String, Nullable: true},
+		arrow.Field{Name: "zip", Type: arrow.PrimitiveTypes.Int32, Nullable: true},
+	)
+
+	schema = arrow.NewSchema([]arrow.Field{
+		{Name: "id", Type: arrow.PrimitiveTypes.Int64},
+		{Name: "name", Type: &arrow.DictionaryType{IndexType: arrow.PrimitiveTypes.Int8, ValueType: arrow.BinaryTypes.String}, Nullable: true},
+		{Name: "when", Type: &arrow.TimestampType{Unit: arrow.Microsecond, TimeZone: "America/New_York"}, Nullable: true},
+		{Name: "day", Type: arrow.FixedWidthTypes.Date32, Nullable: true},
+		{Name: "price", Type: &arrow.Decimal128Type{Precision: 9, Scale: 2}, Nullable: true},
+		{Name: "ok", Type: arrow.FixedWidthTypes.Boolean, Nullable: true},
+		{Name: "address", Type: address, Nullable: true},
+	}, nil)
+)
+
+// A recordWriter writes record batches to a file or stream.
+type recordWriter interface {
+	Write(arrow.Record) error
+	Close() error
+}
+
+type row struct {
+	name    string
+	when    time.Time
+	price   int64
+	ok      bool
+	city    string
+	zip     int32
+	address bool
+}
+
+// records returns the record batches to write. Each holds the same names in
+// the same order, so that they share the dictionary of the name column.
+func records() []arrow.Record {
+	batches := [][]*row{
+		{
+			{"apple", time.Date(2024, time.March, 5, 12, 30, 0, 0, time.UTC), 150, true, "Paris", 75001, true},
+			{"pear", time.Date(1969, time.July, 20, 20, 17, 40, 0, time.UTC), -225, false, "Rome", 0, true},
+			nil,
+		},
+		{
+			{"apple", time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC), 123456789, true, "", 10115, true},
+			{"pear", time.Date(2024, time.February, 29, 23, 59, 59, 999999000, time.UTC), 1, false, "", 0, false},
+		},
+	}
+
+	var records []arrow.Record
+	id := int64(0)
+	for _, rows := range batches {
+		b := array.NewRecordBuilder(memory.DefaultAllocator, schema)
+		st := b.Field(6).(*array.StructBuilder)
+		for _, r := range rows {
+			id++
+			b.Field(0).(*array.Int64Builder).Append(id)
+			if r == nil {
+				for f := 1; f < len(schema.Fields()); f++ {
+					b.Field(f).AppendNull()
+				}
+
+				continue
+			}
+
+			if err := b.Field(1).(*array.BinaryDictionaryBuilder).AppendString(r.name); err != nil {
+				log.Fatal(err)
+			}
+
+			b.Field(2).(*array.TimestampBuilder).Append(arrow.Timestamp(r.when.UnixMicro()))
+			b.Field(3).(*array.Date32Builder).Append(arrow.Date32FromTime(r.when))
+			b.Field(4).(*array.Decimal128Builder).Append(decimal128.FromI64(r.price))
+			b.Field(5).(*array.BooleanBuilder).Append(r.ok)
+			if !r.address {
+				st.AppendNull()
+				continue
+			}
+
+			st.Append(true)
+			if r.city == "" {
+				st.FieldBuilder(0).AppendNull()
+			} else {
+				st.FieldBuilder(0).(*array.StringBuilder).Append(r.city)
+			}
+
+			if r.zip == 0 {
+				st.FieldBuilder(1).AppendNull()
+			} else {
+				st.FieldBuilder(1).(*array.Int32Builder).Append(r.zip)
+			}
+		}
+
+		records = append(records, b.NewRecord())
+	}
+
+	return records
+}
+
+func main() {
+	recs := records()
+
+	write := func(name string, newWriter func(f *os.File) recordWriter) {
+		f, err := os.Create(name)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		w := newWriter(f)
+		for _, rec := range recs {
+			if err := w.Write(rec); err != nil {
+				log.Fatal(err)
+			}
+		}
+
+		if err := w.Close(); err != nil {
+			log.Fatal(err)
+		}
+
+		if err := f.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	write("testdata/arrow_go.arrow", func(f *os.File) recordWriter {
+		w, err := ipc.NewFileWriter(f, ipc.WithSchema(schema))
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		return w
+	})
+
+	write("testdata/arrow_go.arrows", func(f *os.File) recordWriter {
+		return ipc.NewWriter(f, ipc.WithSchema(schema))
+	})
+}
@@ -0,0 +1,245 @@
+package arrow
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"time"
+
+	"github.com/mmihic/sheets/src/pkg/sheets"
+	"github.com/mmihic/sheets/src/pkg/sheets/internal/columnar"
+)
+
+var errTruncatedBuffer = errors.New("truncated buffer")
+
+// supported returns whether the values of a type can be read.
+func supported(typ dataType) bool {
+	switch typ.id {
+	case typeNull, typeInt, typeFloatingPoint, typeBinary, typeUtf8, typeBool, typeDecimal, typeDate,
+		typeTime, typeTimestamp, typeFixedSizeBinary, typeLargeBinary, typeLargeUtf8:
+		return true
+	default:
+		return false
+	}
+}
+
+// numBuffers returns the number of buffers holding an array of a supported
+// type.
+func numBuffers(typ dataType) int {
+	switch typ.id {
+	case typeNull:
+		return 0
+	case typeBinary, typeUtf8, typeLargeBinary, typeLargeUtf8:
+		return 3
+	default:
+		return 2
+	}
+}
+
+// unitDuration returns the duration of a unit of a time or timestamp.
+func unitDuration(unit int) time.Duration {
+	switch unit {
+	case unitMillisecond:
+		return time.Millisecond
+	case unitMicrosecond:
+		return time.Microsecond
+	case unitNanosecond:
+		return time.Nanosecond
+	default:
+		return time.Second
+	}
+}
+
+// intAt returns an integer of the given width at an index of a buffer, which
+// must hold it.
+func intAt(data []byte, i, width int, signed bool) int64 {
+	b := data[i*width:]
+	switch width {
+	case 1:
+		if signed {
+			return int64(int8(b[0]))
+		}
+
+		return int64(b[0])
+	case 2:
+		if signed {
+			return int64(int16(binary.LittleEndian.Uint16(b)))
+		}
+
+		return int64(binary.LittleEndian.Uint16(b))
+	case 4:
+		if signed {
+			return int64(int32(binary.LittleEndian.Uint32(b)))
+		}
+
+		return int64(binary.LittleEndian.Uint32(b))
+	default:
+		return int64(binary.LittleEndian.Uint64(b))
+	}
+}
+
+// decodeArray decodes the values of an array of a supported type from its
+// buffers. Null values are blank.
+func decodeArray(typ dataType, node fieldNode, bufs [][]byte) ([]sheets.Value, error) {
+	n := int(node.length)
+	values := make([]sheets.Value, n)
+	if typ.id == typeNull {
+		for i := range values {
+			values[i] = sheets.BlankValue{}
+		}
+
+		return values, nil
+	}
+
+	validity := bufs[0]
+	if node.nullCount == 0 {
+		validity = nil
+	} else if len(validity) < (n+7)/8 {
+		return nil, errTruncatedBuffer
+	}
+
+	data := bufs[1]
+	width := 0
+	var value func(i int) sheets.Value
+	switch typ.id {
+	case typeBool:
+		if len(data) < (n+7)/8 {
+			return nil, errTruncatedBuffer
+		}
+
+		value = func(i int) sheets.Value {
+			return sheets.BoolValue(data[i/8]&(1<<(i%8)) != 0)
+		}
+	case typeInt:
+		width = typ.bitWidth / 8
+		if width != 1 && width != 2 && width != 4 && width != 8 {
+			return nil, fmt.Errorf("invalid integer width %d", typ.bitWidth)
+		}
+
+		value = func(i int) sheets.Value {
+			v := intAt(data, i, width, typ.signed)
+			if !typ.signed && width == 8 {
+				return sheets.Float64Value(uint64(v))
+			}
+
+			return sheets.Float64Value(v)
+		}
+	case typeFloatingPoint:
+		switch typ.precision {
+		case precisionHalf:
+			width = 2
+			value = func(i int) sheets.Value {
+				return columnar.Float16(binary.LittleEndian.Uint16(data[2*i:]))
+			}
+		case precisionSingle:
+			width = 4
+			value = func(i int) sheets.Value {
+				return columnar.Float32(math.Float32frombits(binary.LittleEndian.Uint32(data[4*i:])))
+			}
+		default:
+			width = 8
+			value = func(i int) sheets.Value {
+				return sheets.Float64Value(math.Float64frombits(binary.LittleEndian.Uint64(data[8*i:])))
+			}
+		}
+	case typeDecimal:
+		width = typ.bitWidth / 8
+		if width <= 0 {
+			return nil, fmt.Errorf("invalid decimal width %d", typ.bitWidth)
+		}
+
+		value = func(i int) sheets.Value {
+			return columnar.Decimal(decimalAt(data[i*width:(i+1)*width]), typ.scale)
+		}
+	case typeDate:
+		if typ.unit == dateDay {
+			width = 4
+			value = func(i int) sheets.Value {
+				return columnar.Date(intAt(data, i, 4, true))
+			}
+		} else {
+			width = 8
+			value = func(i int) sheets.Value {
+				return columnar.Timestamp(intAt(data, i, 8, true), time.Millisecond)
+			}
+		}
+	case typeTime:
+		width = typ.bitWidth / 8
+		if width != 4 && width != 8 {
+			return nil, fmt.Errorf("invalid time width %d", typ.bitWidth)
+		}
+
+		value = func(i int) sheets.Value {
+			return columnar.TimeOfDay(intAt(data, i, width, true), unitDuration(typ.unit))
+		}
+	case typeTimestamp:
+		width = 8
+		value = func(i int) sheets.Value {
+			return columnar.Timestamp(intAt(data, i, 8, true), unitDuration(typ.unit))
+		}
+	case typeFixedSizeBinary:
+		width = typ.byteWidth
+		if width <= 0 {
+			return nil, fmt.Errorf("invalid byte width %d", typ.byteWidth)
+		}
+
+		value = func(i int) sheets.Value {
+			return sheets.StringValue(data[i*width : (i+1)*width])
+		}
+	case typeBinary, typeUtf8, typeLargeBinary, typeLargeUtf8:
+		offsetWidth := 4
+		if typ.id == typeLargeBinary || typ.id == typeLargeUtf8 {
+			offsetWidth = 8
+		}
+
+		offsets, bytes := data, bufs[2]
+		if n > 0 && len(offsets) < (n+1)*offsetWidth {
+			return nil, errTruncatedBuffer
+		}
+
+		for i := 0; i < n; i++ {
+			start, end := intAt(offsets, i, offsetWidth, true), intAt(offsets, i+1, offsetWidth, true)
+			if start < 0 || start > end || end > int64(len(bytes)) {
+				return nil, errors.New("invalid offsets")
+			}
+		}
+
+		value = func(i int) sheets.Value {
+			start, end := intAt(offsets, i, offsetWidth, true), intAt(offsets, i+1, offsetWidth, true)
+			return sheets.StringValue(bytes[start:end])
+		}
+	}
+
+	if width > 0 && len(data)/width < n {
+		return nil, errTruncatedBuffer
+	}
+
+	for i := range values {
+		if validity != nil && validity[i/8]&(1<<(i%8)) == 0 {
+			values[i] = sheets.BlankValue{}
+			continue
+		}
+
+		values[i] = value(i)
+	}
+
+	return values, nil
+}
+
+// decimalAt returns the unscaled value of a decimal stored as a little-endian
+// two's complement integer.
+func decimalAt(b []byte) *big.Int {
+	be := make([]byte, len(b))
+	for i, c := range b {
+		be[len(b)-1-i] = c
+	}
+
+	n := new(big.Int).SetBytes(be)
+	if len(be) > 0 && be[0]&0x80 != 0 {
+		n.Sub(n, new(big.Int).Lsh(big.NewInt(1), uint(8*len(be))))
+	}
+
+	return n
+}
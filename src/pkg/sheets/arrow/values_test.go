@@ -0,0 +1,204 @@
+package arrow
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mmihic/sheets/src/pkg/sheets"
+)
+
+func le16(values ...uint16) []byte {
+	var b []byte
+	for _, v := range values {
+		b = binary.LittleEndian.AppendUint16(b, v)
+	}
+
+	return b
+}
+
+func le32(values ...int32) []byte {
+	var b []byte
+	for _, v := range values {
+		b = binary.LittleEndian.AppendUint32(b, uint32(v))
+	}
+
+	return b
+}
+
+func le64(values ...int64) []byte {
+	var b []byte
+	for _, v := range values {
+		b = binary.LittleEndian.AppendUint64(b, uint64(v))
+	}
+
+	return b
+}
+
+func TestDecodeArray(t *testing.T) {
+	blank := sheets.BlankValue{}
+	day := time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC)
+	ts := time.Date(2024, time.February, 29, 13, 14, 15, 0, time.UTC)
+
+	for _, tt := range []struct {
+		name     string
+		typ      dataType
+		node     fieldNode
+		bufs     [][]byte
+		expected []sheets.Value
+	}{
+		{
+			"null", dataType{id: typeNull}, fieldNode{length: 2, nullCount: 2}, nil,
+			[]sheets.Value{blank, blank},
+		},
+		{
+			"bool", dataType{id: typeBool}, fieldNode{length: 3, nullCount: 1}, [][]byte{{0b101}, {0b001}},
+			[]sheets.Value{sheets.BoolValue(true), blank, sheets.BoolValue(false)},
+		},
+		{
+			"int8", dataType{id: typeInt, bitWidth: 8, signed: true}, fieldNode{length: 2},
+			[][]byte{nil, {0xff, 0x7f}},
+			[]sheets.Value{sheets.Float64Value(-1), sheets.Float64Value(127)},
+		},
+		{
+			"uint16", dataType{id: typeInt, bitWidth: 16}, fieldNode{length: 1},
+			[][]byte{nil, le16(0xffff)},
+			[]sheets.Value{sheets.Float64Value(65535)},
+		},
+		{
+			"int32", dataType{id: typeInt, bitWidth: 32, signed: true}, fieldNode{length: 2, nullCount: 1},
+			[][]byte{{0b10}, le32(5, -7)},
+			[]sheets.Value{blank, sheets.Float64Value(-7)},
+		},
+		{
+			"uint64", dataType{id: typeInt, bitWidth: 64}, fieldNode{length: 1},
+			[][]byte{nil, le64(-1)},
+			[]sheets.Value{sheets.Float64Value(math.MaxUint64)},
+		},
+		{
+			"half float", dataType{id: typeFloatingPoint, precision: precisionHalf}, fieldNode{length: 2},
+			[][]byte{nil, le16(0x3e00, 0xc000)},
+			[]sheets.Value{sheets.Float64Value(1.5), sheets.Float64Value(-2)},
+		},
+		{
+			"float", dataType{id: typeFloatingPoint, precision: precisionSingle}, fieldNode{length: 1},
+			[][]byte{nil, le32(int32(math.Float32bits(0.1)))},
+			[]sheets.Value{sheets.Float64Value(0.1)},
+		},
+		{
+			"double", dataType{id: typeFloatingPoint, precision: precisionDouble}, fieldNode{length: 1},
+			[][]byte{nil, le64(int64(math.Float64bits(2.5)))},
+			[]sheets.Value{sheets.Float64Value(2.5)},
+		},
+		{
+			"decimal128", dataType{id: typeDecimal, bitWidth: 128, scale: 2}, fieldNode{length: 2},
+			[][]byte{nil, le64(12345, 0, -250, -1)},
+			[]sheets.Value{sheets.Float64Value(123.45), sheets.Float64Value(-2.5)},
+		},
+		{
+			"date32", dataType{id: typeDate, unit: dateDay}, fieldNode{length: 1},
+			[][]byte{nil, le32(int32(day.Unix() / 86400))},
+			[]sheets.Value{sheets.TimeValue(day)},
+		},
+		{
+			"date64", dataType{id: typeDate, unit: dateMillisecond}, fieldNode{length: 1},
+			[][]byte{nil, le64(day.UnixMilli())},
+			[]sheets.Value{sheets.TimeValue(day)},
+		},
+		{
+			"time32", dataType{id: typeTime, unit: unitSecond, bitWidth: 32}, fieldNode{length: 1},
+			[][]byte{nil, le32(6 * 3600)},
+			[]sheets.Value{sheets.TimeValue(time.Date(1899, time.December, 31, 6, 0, 0, 0, time.UTC))},
+		},
+		{
+			"time64", dataType{id: typeTime, unit: unitNanosecond, bitWidth: 64}, fieldNode{length: 1},
+			[][]byte{nil, le64(int64(90 * time.Minute))},
+			[]sheets.Value{sheets.TimeValue(time.Date(1899, time.December, 31, 1, 30, 0, 0, time.UTC))},
+		},
+		{
+			"timestamp", dataType{id: typeTimestamp, unit: unitMillisecond}, fieldNode{length: 1},
+			[][]byte{nil, le64(ts.UnixMilli())},
+			[]sheets.Value{sheets.TimeValue(ts)},
+		},
+		{
+			"fixed size binary", dataType{id: typeFixedSizeBinary, byteWidth: 2}, fieldNode{length: 2},
+			[][]byte{nil, []byte("abcd")},
+			[]sheets.Value{sheets.StringValue("ab"), sheets.StringValue("cd")},
+		},
+		{
+			"utf8", dataType{id: typeUtf8}, fieldNode{length: 3, nullCount: 1},
+			[][]byte{{0b101}, le32(0, 2, 2, 5), []byte("hiyou")},
+			[]sheets.Value{sheets.StringValue("hi"), blank, sheets.StringValue("you")},
+		},
+		{
+			"large binary", dataType{id: typeLargeBinary}, fieldNode{length: 2},
+			[][]byte{nil, le64(0, 0, 3), []byte("xyz")},
+			[]sheets.Value{sheets.StringValue(""), sheets.StringValue("xyz")},
+		},
+		{
+			"empty utf8", dataType{id: typeUtf8}, fieldNode{}, [][]byte{nil, nil, nil}, []sheets.Value{},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := decodeArray(tt.typ, tt.node, tt.bufs)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, values)
+		})
+	}
+}
+
+func TestDecodeArrayErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		typ  dataType
+		node fieldNode
+		bufs [][]byte
+		err  string
+	}{
+		{
+			"truncated validity", dataType{id: typeBool}, fieldNode{length: 9, nullCount: 1},
+			[][]byte{{0xff}, {0xff, 0xff}}, "truncated buffer",
+		},
+		{
+			"truncated bools", dataType{id: typeBool}, fieldNode{length: 9},
+			[][]byte{nil, {0xff}}, "truncated buffer",
+		},
+		{
+			"truncated ints", dataType{id: typeInt, bitWidth: 32}, fieldNode{length: 2},
+			[][]byte{nil, le32(1)}, "truncated buffer",
+		},
+		{
+			"invalid int width", dataType{id: typeInt, bitWidth: 24}, fieldNode{length: 1},
+			[][]byte{nil, nil}, "invalid integer width 24",
+		},
+		{
+			"invalid time width", dataType{id: typeTime, bitWidth: 16}, fieldNode{length: 1},
+			[][]byte{nil, nil}, "invalid time width 16",
+		},
+		{
+			"invalid byte width", dataType{id: typeFixedSizeBinary}, fieldNode{length: 1},
+			[][]byte{nil, nil}, "invalid byte width 0",
+		},
+		{
+			"truncated offsets", dataType{id: typeUtf8}, fieldNode{length: 2},
+			[][]byte{nil, le32(0, 1), []byte("ab")}, "truncated buffer",
+		},
+		{
+			"decreasing offsets", dataType{id: typeUtf8}, fieldNode{length: 2},
+			[][]byte{nil, le32(0, 2, 1), []byte("ab")}, "invalid offsets",
+		},
+		{
+			"offsets past data", dataType{id: typeUtf8}, fieldNode{length: 1},
+			[][]byte{nil, le32(0, 3), []byte("ab")}, "invalid offsets",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decodeArray(tt.typ, tt.node, tt.bufs)
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+}
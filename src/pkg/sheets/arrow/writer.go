@@ -0,0 +1,305 @@
+package arrow
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"time"
+
+	"github.com/mmihic/sheets/src/pkg/sheets"
+	"github.com/mmihic/sheets/src/pkg/sheets/internal/columnar"
+)
+
+// DefaultBatchSize is the number of rows in each record batch of a written
+// file or stream, unless set with WithBatchSize.
+const DefaultBatchSize = 1 << 16
+
+// A WriteOption is an option for writing an Arrow IPC file or stream.
+type WriteOption func(opts *writeOptions)
+
+type writeOptions struct {
+	batchSize int
+}
+
+// WithBatchSize sets the number of rows in each record batch.
+func WithBatchSize(rows int) WriteOption {
+	return func(opts *writeOptions) {
+		opts.batchSize = rows
+	}
+}
+
+// WriteFile writes a sheet to an Arrow IPC file at the given path.
+func WriteFile(ctx context.Context, name string, s sheets.Sheet, opts ...WriteOption) (err error) {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	return Write(ctx, f, s, opts...)
+}
+
+// Write writes a sheet as an Arrow IPC file. The first row of the sheet holds
+// the names of its columns, and formulas are computed as the sheet is read.
+//
+// Every field is nullable, with blanks written as nulls. Columns of numbers
+// are written as doubles, columns of booleans as booleans, and columns of
+// times as timestamps in microseconds in UTC. Every other column is written
+// as strings, with errors written as their error codes.
+func Write(ctx context.Context, w io.Writer, s sheets.Sheet, opts ...WriteOption) error {
+	return write(ctx, w, s, true, opts)
+}
+
+// WriteStream writes a sheet as an Arrow IPC stream, in the same way as Write.
+func WriteStream(ctx context.Context, w io.Writer, s sheets.Sheet, opts ...WriteOption) error {
+	return write(ctx, w, s, false, opts)
+}
+
+func write(ctx context.Context, w io.Writer, s sheets.Sheet, file bool, opts []WriteOption) error {
+	options := writeOptions{batchSize: DefaultBatchSize}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	if options.batchSize <= 0 {
+		return fmt.Errorf("invalid batch size %d", options.batchSize)
+	}
+
+	columns, err := columnar.ReadColumns(ctx, s)
+	if err != nil {
+		return err
+	}
+
+	wr := &writer{w: w}
+	if file {
+		if err := wr.write([]byte(magic + "\x00\x00")); err != nil {
+			return err
+		}
+	}
+
+	schema := schemaTable(columns)
+	if _, err := wr.writeMessage(headerSchema, schema, nil); err != nil {
+		return err
+	}
+
+	numRows := 0
+	if len(columns) > 0 {
+		numRows = len(columns[0].Values)
+	}
+
+	var batches []byte
+	for start := 0; start < numRows; start += options.batchSize {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		end := start + options.batchSize
+		if end > numRows {
+			end = numRows
+		}
+
+		header, body, err := recordBatchOf(columns, start, end)
+		if err != nil {
+			return err
+		}
+
+		blk, err := wr.writeMessage(headerRecordBatch, header, body)
+		if err != nil {
+			return err
+		}
+
+		batches = binary.LittleEndian.AppendUint64(batches, uint64(blk.offset))
+		batches = binary.LittleEndian.AppendUint64(batches, uint64(blk.metaDataLength))
+		batches = binary.LittleEndian.AppendUint64(batches, uint64(blk.bodyLength))
+	}
+
+	// The end of the stream
+	if err := wr.write([]byte{0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0}); err != nil || !file {
+		return err
+	}
+
+	ft, err := buildFlatbuffer([]fbfield{
+		{0, int16(metadataV5)},
+		{1, schema},
+		{2, fbstructs{}},
+		{3, fbstructs{n: len(batches) / 24, data: batches}},
+	})
+	if err != nil {
+		return fmt.Errorf("unable to encode footer: %w", err)
+	}
+
+	ft = binary.LittleEndian.AppendUint32(ft, uint32(len(ft)))
+	return wr.write(append(ft, magic...))
+}
+
+// schemaTable returns the Schema of the columns.
+func schemaTable(columns []columnar.Column) []fbfield {
+	fields := make([][]fbfield, len(columns))
+	for i, c := range columns {
+		var (
+			id  uint8
+			typ []fbfield
+		)
+
+		switch c.Kind {
+		case columnar.KindNumber:
+			id, typ = typeFloatingPoint, []fbfield{{0, int16(precisionDouble)}}
+		case columnar.KindBool:
+			id, typ = typeBool, []fbfield{}
+		case columnar.KindTime:
+			id, typ = typeTimestamp, []fbfield{{0, int16(unitMicrosecond)}, {1, "UTC"}}
+		default:
+			id, typ = typeUtf8, []fbfield{}
+		}
+
+		fields[i] = []fbfield{
+			{0, c.Name},
+			{1, true},
+			{2, id},
+			{3, typ},
+			{5, [][]fbfield{}},
+		}
+	}
+
+	return []fbfield{{0, int16(0)}, {1, fields}}
+}
+
+// recordBatchOf returns the RecordBatch header and body holding a range of
+// rows of the columns.
+func recordBatchOf(columns []columnar.Column, start, end int) ([]fbfield, []byte, error) {
+	var body, nodes, buffers []byte
+	addBuffer := func(data []byte) {
+		buffers = binary.LittleEndian.AppendUint64(buffers, uint64(len(body)))
+		buffers = binary.LittleEndian.AppendUint64(buffers, uint64(len(data)))
+		body = append(body, data...)
+		for len(body)%8 != 0 {
+			body = append(body, 0)
+		}
+	}
+
+	n := end - start
+	for _, c := range columns {
+		values := c.Values[start:end]
+		validity := make([]byte, (n+7)/8)
+		nulls := 0
+		for i, v := range values {
+			if _, blank := v.(sheets.BlankValue); blank {
+				nulls++
+			} else {
+				validity[i/8] |= 1 << (i % 8)
+			}
+		}
+
+		nodes = binary.LittleEndian.AppendUint64(nodes, uint64(n))
+		nodes = binary.LittleEndian.AppendUint64(nodes, uint64(nulls))
+		if nulls == 0 {
+			validity = nil
+		}
+
+		addBuffer(validity)
+		switch c.Kind {
+		case columnar.KindNumber:
+			data := make([]byte, 0, 8*n)
+			for _, v := range values {
+				f, _ := v.ToFloat64()
+				if _, blank := v.(sheets.BlankValue); blank {
+					f = 0
+				}
+
+				data = binary.LittleEndian.AppendUint64(data, math.Float64bits(f))
+			}
+
+			addBuffer(data)
+		case columnar.KindBool:
+			data := make([]byte, (n+7)/8)
+			for i, v := range values {
+				if b, ok := v.(sheets.BoolValue); ok && bool(b) {
+					data[i/8] |= 1 << (i % 8)
+				}
+			}
+
+			addBuffer(data)
+		case columnar.KindTime:
+			data := make([]byte, 0, 8*n)
+			for _, v := range values {
+				var micros int64
+				if t, ok := v.(sheets.TimeValue); ok {
+					micros = time.Time(t).UnixMicro()
+				}
+
+				data = binary.LittleEndian.AppendUint64(data, uint64(micros))
+			}
+
+			addBuffer(data)
+		default:
+			offsets := make([]byte, 4, 4*(n+1))
+			var data []byte
+			for _, v := range values {
+				if _, blank := v.(sheets.BlankValue); !blank {
+					data = append(data, columnar.Text(v)...)
+				}
+
+				if len(data) > math.MaxInt32 {
+					return nil, nil, errors.New("too much text for a record batch: use a smaller batch size")
+				}
+
+				offsets = binary.LittleEndian.AppendUint32(offsets, uint32(len(data)))
+			}
+
+			addBuffer(offsets)
+			addBuffer(data)
+		}
+	}
+
+	return []fbfield{
+		{0, int64(n)},
+		{1, fbstructs{n: len(nodes) / 16, data: nodes}},
+		{2, fbstructs{n: len(buffers) / 16, data: buffers}},
+	}, body, nil
+}
+
+// A writer writes encapsulated messages, tracking their offsets.
+type writer struct {
+	w      io.Writer
+	offset int64
+}
+
+func (wr *writer) write(b []byte) error {
+	n, err := wr.w.Write(b)
+	wr.offset += int64(n)
+	return err
+}
+
+// writeMessage writes a message with the given header and body, which must be
+// padded to eight bytes, returning the block locating it.
+func (wr *writer) writeMessage(headerType uint8, header []fbfield, body []byte) (block, error) {
+	meta, err := buildFlatbuffer([]fbfield{
+		{0, int16(metadataV5)},
+		{1, headerType},
+		{2, header},
+		{3, int64(len(body))},
+	})
+	if err != nil {
+		return block{}, fmt.Errorf("unable to encode message: %w", err)
+	}
+
+	blk := block{offset: wr.offset, metaDataLength: int32(8 + len(meta)), bodyLength: int64(len(body))}
+	prefix := binary.LittleEndian.AppendUint32(nil, continuation)
+	prefix = binary.LittleEndian.AppendUint32(prefix, uint32(len(meta)))
+	for _, b := range [][]byte{prefix, meta, body} {
+		if err := wr.write(b); err != nil {
+			return block{}, err
+		}
+	}
+
+	return blk, nil
+}
@@ -0,0 +1,131 @@
+package arrow
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mmihic/sheets/src/pkg/sheets"
+	"github.com/mmihic/sheets/src/pkg/sheets/internal/columnar/columnartest"
+)
+
+func TestWriteRoundTrip(t *testing.T) {
+	expected := [][]sheets.Value{
+		{
+			sheets.StringValue("name"), sheets.StringValue("price"), sheets.StringValue("when"),
+			sheets.StringValue("ok"), sheets.StringValue("total"), sheets.StringValue("F"),
+		},
+		{
+			sheets.StringValue("apple"), sheets.Float64Value(1.5),
+			sheets.TimeValue(time.Date(2024, time.March, 5, 12, 30, 0, 0, time.UTC)),
+			sheets.BoolValue(true), sheets.StringValue("3"), sheets.BlankValue{},
+		},
+		{
+			sheets.BlankValue{}, sheets.Float64Value(-2.25), sheets.BlankValue{},
+			sheets.BoolValue(false), sheets.StringValue("#DIV/0!"), sheets.BlankValue{},
+		},
+		{
+			sheets.StringValue("pear"), sheets.BlankValue{},
+			sheets.TimeValue(time.Date(1969, time.July, 20, 20, 17, 40, 0, time.UTC)),
+			sheets.BlankValue{}, sheets.StringValue("1"), sheets.BlankValue{},
+		},
+	}
+
+	for _, tt := range []struct {
+		name string
+		opts []WriteOption
+	}{
+		{"default", nil},
+		{"batches", []WriteOption{WithBatchSize(2)}},
+		{"single rows", []WriteOption{WithBatchSize(1)}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, Write(context.Background(), &buf, columnartest.NewSheet(t), tt.opts...))
+
+			s, err := Read(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+			require.NoError(t, err)
+			assert.Equal(t, []string{"name", "price", "when", "ok", "total", "F"}, s.Columns())
+			assert.Equal(t, expected, columnartest.ReadAll(t, s))
+
+			buf.Reset()
+			require.NoError(t, WriteStream(context.Background(), &buf, columnartest.NewSheet(t), tt.opts...))
+
+			s, err = ReadStream(&buf)
+			require.NoError(t, err)
+			assert.Equal(t, expected, columnartest.ReadAll(t, s))
+		})
+	}
+}
+
+func TestWriteSchema(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Write(context.Background(), &buf, columnartest.NewSheet(t), WithBatchSize(2)))
+	assert.Equal(t, magic+"\x00\x00", buf.String()[:8])
+	assert.Equal(t, magic, buf.String()[buf.Len()-len(magic):])
+
+	s, err := Read(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	assert.Equal(t, int16(metadataV5), s.version)
+
+	var types []uint8
+	for _, c := range s.columns {
+		types = append(types, c.typ.id)
+	}
+
+	assert.Equal(t, []uint8{typeUtf8, typeFloatingPoint, typeTimestamp, typeBool, typeUtf8, typeUtf8}, types)
+	assert.Equal(t, precisionDouble, s.columns[1].typ.precision)
+	assert.Equal(t, unitMicrosecond, s.columns[2].typ.unit)
+	require.Len(t, s.batches, 2)
+	assert.Equal(t, int64(2), s.batches[0].meta.length)
+	assert.Equal(t, int64(1), s.batches[1].meta.length)
+
+	// Buffers are aligned to eight bytes
+	for _, b := range s.batches {
+		assert.Zero(t, b.body%8)
+		for _, buf := range b.meta.buffers {
+			assert.Zero(t, buf.offset%8)
+		}
+	}
+}
+
+func TestWriteEmpty(t *testing.T) {
+	ms, err := sheets.NewMutableSheet([][]sheets.Value{{sheets.StringValue("a"), sheets.StringValue("b")}})
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, Write(context.Background(), &buf, ms))
+
+	s, err := Read(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, s.Columns())
+	assert.Equal(t, sheets.Dimensions{EndRow: 0, EndCol: 1}, s.Dimensions())
+}
+
+func TestWriteFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "data.arrow")
+	require.NoError(t, WriteFile(context.Background(), name, columnartest.NewSheet(t)))
+
+	f, err := Open(name)
+	require.NoError(t, err)
+	defer func() { _ = f.Close() }()
+
+	v, err := f.Get(context.Background(), sheets.Pos{Row: 3, Col: 0})
+	require.NoError(t, err)
+	assert.Equal(t, sheets.StringValue("pear"), v)
+}
+
+func TestWriteErrors(t *testing.T) {
+	ms, err := sheets.NewMutableSheet([][]sheets.Value{{sheets.StringValue("a"), sheets.StringValue("a")}})
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	assert.EqualError(t, Write(context.Background(), &buf, ms), "duplicate column name 'a'")
+	assert.EqualError(t, Write(context.Background(), &buf, columnartest.NewSheet(t), WithBatchSize(0)),
+		"invalid batch size 0")
+}
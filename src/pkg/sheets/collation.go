@@ -0,0 +1,126 @@
+package sheets
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+// A Collator compares text values. Collators are used by comparison operators,
+// lookup functions such as MATCH, and when sorting values.
+type Collator interface {
+	// Compare returns -1 if a sorts before b, 1 if a sorts after b, and 0
+	// if the two are considered equal.
+	Compare(a, b string) int
+}
+
+var (
+	// ExcelCollator compares text the way Excel does: case-insensitively,
+	// with accented letters sorting after their unaccented forms, and
+	// punctuation sorting before digits and letters. This is the default.
+	ExcelCollator = NewLocaleCollator(language.Und)
+
+	// BinaryCollator compares text byte-by-byte, so is case-sensitive.
+	BinaryCollator Collator = binaryCollator{}
+)
+
+type binaryCollator struct{}
+
+func (binaryCollator) Compare(a, b string) int {
+	return strings.Compare(a, b)
+}
+
+// NewLocaleCollator returns a case-insensitive Collator that follows the
+// sorting rules for the given language.
+func NewLocaleCollator(tag language.Tag) Collator {
+	return &localeCollator{
+		c: collate.New(tag, collate.IgnoreCase),
+	}
+}
+
+// localeCollator wraps a collate.Collator, which is not safe for concurrent use.
+type localeCollator struct {
+	mu sync.Mutex
+	c  *collate.Collator
+}
+
+func (lc *localeCollator) Compare(a, b string) int {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	return lc.c.CompareString(a, b)
+}
+
+// CompareValues compares two values using the same rules as the comparison
+// operators: numbers (including times) sort before text, which sorts before
+// booleans. Text is compared using the collator and numbers using the numeric
+// mode from the settings. Returns the error if either value is an error.
+func CompareValues(v1, v2 Value, settings CalcSettings) (int, error) {
+	return compareValues(v1, v2, settings)
+}
+
+// SortValues sorts values in ascending order the same way Excel does: numbers
+// first, then text, then booleans, then errors, with blank cells last. Values
+// of the same type are ordered using CompareValues. The sort is stable.
+func SortValues(values []Value, settings CalcSettings) {
+	sort.SliceStable(values, func(i, j int) bool {
+		return sortsBefore(values[i], values[j], settings)
+	})
+}
+
+// SortRows sorts rows in ascending order of the values in the given column,
+// using the same ordering as SortValues. Rows that are too short to have a
+// value in the column are treated as blank. The sort is stable.
+func SortRows(rows [][]Value, col int, settings CalcSettings) {
+	key := func(row []Value) Value {
+		if col < len(row) && row[col] != nil {
+			return row[col]
+		}
+
+		return BlankValue{}
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		return sortsBefore(key(rows[i]), key(rows[j]), settings)
+	})
+}
+
+func sortsBefore(v1, v2 Value, settings CalcSettings) bool {
+	r1, r2 := sortRank(v1), sortRank(v2)
+	if r1 != r2 {
+		return r1 < r2
+	}
+
+	if r1 == errorRank {
+		return false
+	}
+
+	cmp, err := compareValues(v1, v2, settings)
+	return err == nil && cmp < 0
+}
+
+const (
+	numberRank = iota
+	textRank
+	boolRank
+	errorRank
+	blankRank
+)
+
+func sortRank(v Value) int {
+	switch v.(type) {
+	case Float64Value, FormattedNumber, TimeValue:
+		return numberRank
+	case BoolValue:
+		return boolRank
+	case ErrorValue:
+		return errorRank
+	case StringValue:
+		return textRank
+	default:
+		return blankRank
+	}
+}
@@ -0,0 +1,103 @@
+package sheets
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/language"
+)
+
+func TestCollators(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		collator Collator
+		a, b     string
+		expected int
+	}{
+		{"excel ignores case", ExcelCollator, "apple", "APPLE", 0},
+		{"excel lower before upper letter", ExcelCollator, "a", "B", -1},
+		{"excel upper after lower letter", ExcelCollator, "b", "A", 1},
+		{"excel accent is secondary", ExcelCollator, "resume", "résumé", -1},
+		{"excel accent before next letter", ExcelCollator, "résumé", "rf", -1},
+		{"excel punctuation before digits", ExcelCollator, "-", "1", -1},
+		{"excel digits before letters", ExcelCollator, "9", "a", -1},
+		{"binary is case sensitive", BinaryCollator, "apple", "APPLE", 1},
+		{"binary upper before lower", BinaryCollator, "b", "A", 1},
+		{"binary lower after upper", BinaryCollator, "a", "B", 1},
+		{"swedish sorts ä after z", NewLocaleCollator(language.Swedish), "ä", "z", 1},
+		{"german sorts ä before z", NewLocaleCollator(language.German), "ä", "z", -1},
+		{"locale ignores case", NewLocaleCollator(language.German), "Straße", "STRASSE", 1},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.collator.Compare(tt.a, tt.b))
+		})
+	}
+}
+
+func TestCompareValues(t *testing.T) {
+	cmp, err := CompareValues(StringValue("Apple"), StringValue("apple"), CalcSettings{})
+	require.NoError(t, err)
+	assert.Equal(t, 0, cmp)
+
+	cmp, err = CompareValues(StringValue("Apple"), StringValue("apple"), CalcSettings{Collator: BinaryCollator})
+	require.NoError(t, err)
+	assert.Equal(t, -1, cmp)
+
+	_, err = CompareValues(ErrorValue{ErrDivideByZero}, StringValue("apple"), CalcSettings{})
+	require.Equal(t, ErrDivideByZero, err)
+}
+
+func TestSortValues(t *testing.T) {
+	values := []Value{
+		StringValue("banana"),
+		ErrorValue{ErrDivideByZero},
+		BoolValue(true),
+		BlankValue{},
+		StringValue("Apple"),
+		Float64Value(10),
+		BoolValue(false),
+		StringValue("apple"),
+		Float64Value(-1),
+		StringValue("Cherry"),
+	}
+
+	SortValues(values, CalcSettings{})
+	assert.Equal(t, []Value{
+		Float64Value(-1),
+		Float64Value(10),
+		StringValue("Apple"),
+		StringValue("apple"),
+		StringValue("banana"),
+		StringValue("Cherry"),
+		BoolValue(false),
+		BoolValue(true),
+		ErrorValue{ErrDivideByZero},
+		BlankValue{},
+	}, values)
+
+	SortValues(values, CalcSettings{Collator: BinaryCollator})
+	assert.Equal(t, []Value{
+		StringValue("Apple"),
+		StringValue("Cherry"),
+		StringValue("apple"),
+		StringValue("banana"),
+	}, values[2:6])
+}
+
+func TestSortRows(t *testing.T) {
+	rows := [][]Value{
+		{Float64Value(1), StringValue("pear")},
+		{Float64Value(2)},
+		{Float64Value(3), StringValue("Fig")},
+		{Float64Value(4), StringValue("apple")},
+	}
+
+	SortRows(rows, 1, CalcSettings{})
+	assert.Equal(t, [][]Value{
+		{Float64Value(4), StringValue("apple")},
+		{Float64Value(3), StringValue("Fig")},
+		{Float64Value(1), StringValue("pear")},
+		{Float64Value(2)},
+	}, rows)
+}
@@ -0,0 +1,53 @@
+package conformance
+
+import (
+	"context"
+	"path/filepath"
+)
+
+// TestingT is the subset of testing.TB used to report failures.
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...any)
+}
+
+// AssertConforms checks every XLSX file matching a glob pattern, such as
+// "testdata/*.xlsx", reporting each mismatch and unparsed formula as a test
+// failure. It is used to build regression suites from real workbooks. Returns
+// true if every formula matches.
+func AssertConforms(t TestingT, pattern string, opts Options) bool {
+	t.Helper()
+
+	names, err := filepath.Glob(pattern)
+	if err != nil {
+		t.Errorf("invalid pattern '%s': %s", pattern, err)
+		return false
+	}
+
+	if len(names) == 0 {
+		t.Errorf("no workbooks match '%s'", pattern)
+		return false
+	}
+
+	ok := true
+	for _, name := range names {
+		report, err := CheckFile(context.Background(), name, opts)
+		if err != nil {
+			t.Errorf("%s: %s", name, err)
+			ok = false
+			continue
+		}
+
+		for _, m := range report.Mismatches {
+			t.Errorf("%s: %s: =%s: %s", name, m.Address, m.Formula, m.outcome())
+			ok = false
+		}
+
+		for _, u := range report.Unparsed {
+			t.Errorf("%s: %s: %s", name, u.Address, u.Err)
+			ok = false
+		}
+	}
+
+	return ok
+}
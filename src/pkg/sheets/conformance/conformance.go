@@ -0,0 +1,414 @@
+// Package conformance measures how closely the formulas computed by the sheets
+// engine match Excel, by re-evaluating the formulas in XLSX workbooks and
+// comparing each result with the value Excel cached when the workbook was
+// saved.
+package conformance
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mmihic/sheets/src/pkg/sheets"
+	"github.com/mmihic/sheets/src/pkg/sheets/xlsx"
+)
+
+// DefaultTolerance is the relative difference allowed between numbers when no
+// tolerance is given.
+const DefaultTolerance = 1e-9
+
+// Options control how results are compared.
+type Options struct {
+	// Tolerance is the difference allowed between two numbers, relative to
+	// the larger of their magnitudes, or absolute for magnitudes below 1. If
+	// zero, the DefaultTolerance is used.
+	Tolerance float64
+}
+
+func (opts Options) tolerance() float64 {
+	if opts.Tolerance <= 0 {
+		return DefaultTolerance
+	}
+
+	return opts.Tolerance
+}
+
+// A Mismatch is a formula cell whose computed value differs from the value
+// cached by Excel, or whose cached or computed value could not be read.
+type Mismatch struct {
+	Address   sheets.CellAddress
+	Formula   string
+	Functions []string
+	Expected  sheets.Value
+	Actual    sheets.Value
+
+	// Err is the error reading the cached or computed value, in which case
+	// the values that were not read are nil.
+	Err error
+}
+
+// outcome describes how the computed value differs from the cached value.
+func (m Mismatch) outcome() string {
+	if m.Err != nil {
+		return m.Err.Error()
+	}
+
+	return fmt.Sprintf("expected %s, got %s", describeValue(m.Expected), describeValue(m.Actual))
+}
+
+// An Unparsed formula is a formula cell whose formula could not be parsed.
+type Unparsed struct {
+	Address sheets.CellAddress
+	Err     error
+}
+
+// A Report describes how the formulas in a workbook compare with the values
+// cached by Excel.
+type Report struct {
+	// Formulas is the number of formula cells in the workbook.
+	Formulas int
+
+	// Matched is the number of formula cells whose computed value matches
+	// the cached value.
+	Matched int
+
+	// Volatile is the number of formula cells that were not compared because
+	// they call volatile functions, such as NOW or RAND.
+	Volatile int
+
+	Mismatches []Mismatch
+	Unparsed   []Unparsed
+}
+
+// Score returns the fraction of the formulas that match, not counting those
+// that call volatile functions. A workbook without formulas has a score of 1.
+func (r *Report) Score() float64 {
+	compared := r.Formulas - r.Volatile
+	if compared == 0 {
+		return 1
+	}
+
+	return float64(r.Matched) / float64(compared)
+}
+
+// ByFunction returns the mismatches grouped by the name of each function the
+// formula calls, so that a mismatch appears once for each of its functions.
+// Mismatches in formulas that do not call any functions are grouped under "".
+func (r *Report) ByFunction() map[string][]Mismatch {
+	groups := make(map[string][]Mismatch)
+	for _, m := range r.Mismatches {
+		if len(m.Functions) == 0 {
+			groups[""] = append(groups[""], m)
+		}
+
+		for _, name := range m.Functions {
+			groups[name] = append(groups[name], m)
+		}
+	}
+
+	return groups
+}
+
+// Add adds the results of another report to the report.
+func (r *Report) Add(other *Report) {
+	r.Formulas += other.Formulas
+	r.Matched += other.Matched
+	r.Volatile += other.Volatile
+	r.Mismatches = append(r.Mismatches, other.Mismatches...)
+	r.Unparsed = append(r.Unparsed, other.Unparsed...)
+}
+
+// WriteSummary writes the score of the report, followed by the mismatches
+// grouped by function, with the functions with the most mismatches first, and
+// the formulas that could not be parsed.
+func (r *Report) WriteSummary(w io.Writer) error {
+	ew := &errWriter{w: w}
+	ew.printf("%d of %d formulas match (%.1f%%), %d volatile, %d mismatched, %d unparsed\n",
+		r.Matched, r.Formulas-r.Volatile, r.Score()*100, r.Volatile, len(r.Mismatches), len(r.Unparsed))
+
+	groups := r.ByFunction()
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+
+	sort.Slice(names, func(i, j int) bool {
+		if len(groups[names[i]]) != len(groups[names[j]]) {
+			return len(groups[names[i]]) > len(groups[names[j]])
+		}
+
+		return names[i] < names[j]
+	})
+
+	for _, name := range names {
+		label := name
+		if label == "" {
+			label = "(no functions)"
+		}
+
+		ew.printf("\n%s: %d mismatched\n", label, len(groups[name]))
+		for _, m := range groups[name] {
+			ew.printf("  %s: =%s: %s\n", m.Address, m.Formula, m.outcome())
+		}
+	}
+
+	if len(r.Unparsed) != 0 {
+		ew.printf("\n(unparsed): %d\n", len(r.Unparsed))
+		for _, u := range r.Unparsed {
+			ew.printf("  %s: %s\n", u.Address, u.Err)
+		}
+	}
+
+	return ew.err
+}
+
+// CheckFile re-evaluates the formulas in the XLSX file at the given path.
+func CheckFile(ctx context.Context, name string, opts Options) (*Report, error) {
+	wb, err := xlsx.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return Check(ctx, wb, opts)
+}
+
+// Check re-evaluates the formulas in a workbook read from an XLSX file and
+// compares each result with the value cached by Excel.
+func Check(ctx context.Context, wb *xlsx.Workbook, opts Options) (*Report, error) {
+	c := &checker{
+		ev:        wb.Evaluator(),
+		tolerance: opts.tolerance(),
+	}
+
+	report := &Report{}
+	for _, name := range wb.SheetNames() {
+		if live, ok := wb.Sheet(name).(sheets.FormulaSheet); ok {
+			c.checkSheet(ctx, report, name, live, wb.CachedSheet(name))
+		}
+	}
+
+	for addr, err := range wb.FormulaErrors() {
+		report.Formulas++
+		report.Unparsed = append(report.Unparsed, Unparsed{Address: addr, Err: err})
+	}
+
+	sort.Slice(report.Unparsed, func(i, j int) bool {
+		return addressLess(report.Unparsed[i].Address, report.Unparsed[j].Address)
+	})
+
+	return report, nil
+}
+
+// A checker compares computed and cached values.
+type checker struct {
+	ev        *sheets.Evaluator
+	tolerance float64
+}
+
+// checkSheet compares the formula cells of a sheet with the values cached in
+// another. Cells whose values cannot be read are reported as mismatches.
+func (c *checker) checkSheet(ctx context.Context, report *Report, name string, live sheets.FormulaSheet, cached sheets.Sheet) {
+	dims := live.Dimensions()
+	for row := 0; row <= dims.EndRow; row++ {
+		for col := 0; col <= dims.EndCol; col++ {
+			pos := sheets.Pos{Row: row, Col: col}
+			f, ok := live.Formula(pos)
+			if !ok {
+				continue
+			}
+
+			report.Formulas++
+			functions, volatile := c.functions(f)
+			if volatile {
+				report.Volatile++
+				continue
+			}
+
+			m := Mismatch{
+				Address:   sheets.CellAddress{Sheet: name, Pos: pos},
+				Formula:   f.String(),
+				Functions: functions,
+			}
+
+			var err error
+			if m.Expected, err = cached.Get(ctx, pos); err != nil {
+				m.Err = fmt.Errorf("unable to read cached value: %w", err)
+			} else if m.Actual, err = live.Get(ctx, pos); err != nil {
+				m.Err = fmt.Errorf("unable to compute value: %w", err)
+			} else if c.equal(m.Expected, m.Actual) {
+				report.Matched++
+				continue
+			}
+
+			report.Mismatches = append(report.Mismatches, m)
+		}
+	}
+}
+
+// functions returns the sorted names of the functions called by a formula,
+// and whether any of them are volatile.
+func (c *checker) functions(f sheets.Formula) ([]string, bool) {
+	var (
+		names    []string
+		volatile bool
+	)
+
+	visitFunctions(f, func(fc *sheets.FunctionCall) {
+		name := strings.ToUpper(fc.FunctionName)
+		for _, existing := range names {
+			if existing == name {
+				return
+			}
+		}
+
+		names = append(names, name)
+		if vf, ok := c.function(name).(sheets.VolatileFunction); ok && vf.Volatile() {
+			volatile = true
+		}
+	})
+
+	sort.Strings(names)
+	return names, volatile
+}
+
+func (c *checker) function(name string) sheets.Function {
+	if c.ev.Functions != nil {
+		return c.ev.Functions[name]
+	}
+
+	return sheets.BuiltinFunctions()[name]
+}
+
+func visitFunctions(f sheets.Formula, fn func(fc *sheets.FunctionCall)) {
+	switch ft := f.(type) {
+	case *sheets.Expression:
+		visitFunctions(ft.Left, fn)
+		visitFunctions(ft.Right, fn)
+	case *sheets.FunctionCall:
+		fn(ft)
+		for _, arg := range ft.Args {
+			visitFunctions(arg, fn)
+		}
+	}
+}
+
+// equal returns true if a computed value matches a cached value. Numbers and
+// times are compared as serial numbers within the tolerance, errors are
+// compared by type, and a blank on either side matches zero or empty text on
+// the other, as Excel caches for a formula that refers to an empty cell.
+func (c *checker) equal(expected, actual sheets.Value) bool {
+	if _, ok := expected.(sheets.BlankValue); ok {
+		return isEmpty(actual)
+	}
+
+	if _, ok := actual.(sheets.BlankValue); ok {
+		return isEmpty(expected)
+	}
+
+	if en, ok := c.number(expected); ok {
+		an, ok := c.number(actual)
+		return ok && c.withinTolerance(en, an)
+	}
+
+	switch ev := expected.(type) {
+	case sheets.StringValue:
+		av, ok := actual.(sheets.StringValue)
+		return ok && ev == av
+	case sheets.BoolValue:
+		av, ok := actual.(sheets.BoolValue)
+		return ok && ev == av
+	case sheets.ErrorValue:
+		av, ok := actual.(sheets.ErrorValue)
+		return ok && errorType(ev) == errorType(av)
+	default:
+		return expected.String() == actual.String()
+	}
+}
+
+func (c *checker) number(v sheets.Value) (float64, bool) {
+	switch tv := v.(type) {
+	case sheets.Float64Value, sheets.FormattedNumber:
+		n, err := tv.ToFloat64()
+		return n, err == nil
+	case sheets.TimeValue:
+		// Times are compared by their wall-clock time, since cached times
+		// have no time zone
+		tm := time.Time(tv)
+		return c.ev.DateSystem.ToExcelTimeIn(tm, tm.Location()), true
+	default:
+		return 0, false
+	}
+}
+
+func (c *checker) withinTolerance(expected, actual float64) bool {
+	scale := math.Max(1, math.Max(math.Abs(expected), math.Abs(actual)))
+	return math.Abs(expected-actual) <= c.tolerance*scale
+}
+
+// isEmpty returns true for the values a blank can stand for: a blank, empty
+// text or zero.
+func isEmpty(v sheets.Value) bool {
+	switch tv := v.(type) {
+	case sheets.BlankValue:
+		return true
+	case sheets.StringValue:
+		return tv == ""
+	case sheets.Float64Value:
+		return tv == 0
+	default:
+		return false
+	}
+}
+
+func errorType(v sheets.ErrorValue) string {
+	if sheetErr, ok := sheets.UnwrapError(v.Err); ok {
+		return sheetErr.TypeName()
+	}
+
+	return v.String()
+}
+
+// describeValue returns a value along with its type, so that values that
+// display the same but have different types can be told apart.
+func describeValue(v sheets.Value) string {
+	switch tv := v.(type) {
+	case sheets.StringValue:
+		return fmt.Sprintf("%q", string(tv))
+	case sheets.BlankValue:
+		return "blank"
+	case sheets.ErrorValue:
+		return errorType(tv)
+	case sheets.TimeValue:
+		return time.Time(tv).Format("2006-01-02 15:04:05")
+	default:
+		return v.String()
+	}
+}
+
+func addressLess(a, b sheets.CellAddress) bool {
+	if a.Sheet != b.Sheet {
+		return a.Sheet < b.Sheet
+	}
+
+	if a.Pos.Row != b.Pos.Row {
+		return a.Pos.Row < b.Pos.Row
+	}
+
+	return a.Pos.Col < b.Pos.Col
+}
+
+// An errWriter writes formatted text, remembering the first error.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (ew *errWriter) printf(format string, args ...any) {
+	if ew.err == nil {
+		_, ew.err = fmt.Fprintf(ew.w, format, args...)
+	}
+}
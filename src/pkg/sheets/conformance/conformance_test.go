@@ -0,0 +1,242 @@
+package conformance
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mmihic/sheets/src/pkg/sheets"
+	"github.com/mmihic/sheets/src/pkg/sheets/xlsx"
+)
+
+const testSheet = `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<sheetData>
+<row r="1"><c r="A1"><v>1</v></c><c r="B1"><f>SUM(A1:A3)</f><v>6</v></c></row>
+<row r="2"><c r="A2"><v>2</v></c><c r="B2"><f>AVERAGE(A1:A3)</f><v>2.0000000001</v></c></row>
+<row r="3"><c r="A3"><v>3</v></c><c r="B3"><f>MAX(A1:A3)</f><v>4</v></c></row>
+<row r="4"><c r="B4" t="e"><f>A1/0</f><v>#DIV/0!</v></c></row>
+<row r="5"><c r="B5" t="str"><f>_xlfn.CONCAT("a","b")</f><v>ab</v></c></row>
+<row r="6"><c r="B6"><f>VLOOKUP(1,A1:A3,1,0)</f><v>1</v></c></row>
+<row r="7"><c r="B7"><f>NOW()</f><v>45000</v></c></row>
+<row r="8"><c r="B8"><f>A1&gt;1</f><v>0</v></c></row>
+<row r="9"><c r="B9"><f>SUM(A1:A3)+MAX(A1,A2)</f><v>9</v></c></row>
+<row r="10"><c r="B10"><f>A1*0</f><v>0</v></c><c r="C10"><f>A1*2</f><v>3</v></c></row>
+</sheetData>
+</worksheet>`
+
+// writeTestWorkbook writes an XLSX file with a single sheet.
+func writeTestWorkbook(t *testing.T, dir, sheet string) string {
+	parts := map[string]string{
+		"_rels/.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+</Relationships>`,
+		"xl/workbook.xml": `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="Data" sheetId="1" r:id="rId1"/></sheets>
+</workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+</Relationships>`,
+		"xl/worksheets/sheet1.xml": sheet,
+	}
+
+	var buf bytes.Buffer
+	z := zip.NewWriter(&buf)
+	for name, content := range parts {
+		w, err := z.Create(name)
+		require.NoError(t, err)
+
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, z.Close())
+
+	name := filepath.Join(dir, "test.xlsx")
+	require.NoError(t, os.WriteFile(name, buf.Bytes(), 0o600))
+	return name
+}
+
+func TestCheckFile(t *testing.T) {
+	name := writeTestWorkbook(t, t.TempDir(), testSheet)
+	report, err := CheckFile(context.Background(), name, Options{})
+	require.NoError(t, err)
+
+	assert.Equal(t, 11, report.Formulas)
+	assert.Equal(t, 5, report.Matched)
+	assert.Equal(t, 1, report.Volatile)
+	assert.InDelta(t, 0.5, report.Score(), 1e-9)
+
+	var mismatched []string
+	for _, m := range report.Mismatches {
+		mismatched = append(mismatched, m.Address.Pos.String())
+	}
+
+	assert.Equal(t, []string{"B3", "B6", "B9", "C10"}, mismatched)
+	assert.Equal(t, Mismatch{
+		Address:   sheets.CellAddress{Sheet: "Data", Pos: sheets.Pos{Row: 8, Col: 1}},
+		Formula:   "SUM(A1:A3) + MAX(A1, A2)",
+		Functions: []string{"MAX", "SUM"},
+		Expected:  sheets.Float64Value(9),
+		Actual:    sheets.Float64Value(8),
+	}, report.Mismatches[2])
+
+	require.Len(t, report.Unparsed, 1)
+	assert.Equal(t, sheets.CellAddress{Sheet: "Data", Pos: sheets.Pos{Row: 7, Col: 1}}, report.Unparsed[0].Address)
+
+	groups := report.ByFunction()
+	assert.Len(t, groups, 4)
+	assert.Len(t, groups["MAX"], 2)
+	assert.Len(t, groups["SUM"], 1)
+	assert.Len(t, groups["VLOOKUP"], 1)
+	assert.Len(t, groups[""], 1)
+}
+
+func TestCheckFile_Tolerance(t *testing.T) {
+	name := writeTestWorkbook(t, t.TempDir(), testSheet)
+	report, err := CheckFile(context.Background(), name, Options{Tolerance: 1e-12})
+	require.NoError(t, err)
+
+	assert.Equal(t, 4, report.Matched)
+	assert.Equal(t, "B2", report.Mismatches[0].Address.Pos.String())
+}
+
+func TestReport_WriteSummary(t *testing.T) {
+	name := writeTestWorkbook(t, t.TempDir(), testSheet)
+	report, err := CheckFile(context.Background(), name, Options{})
+	require.NoError(t, err)
+
+	var sb strings.Builder
+	require.NoError(t, report.WriteSummary(&sb))
+	assert.Equal(t, strings.Join([]string{
+		"5 of 10 formulas match (50.0%), 1 volatile, 4 mismatched, 1 unparsed",
+		"",
+		"MAX: 2 mismatched",
+		"  `Data`!B3: =MAX(A1:A3): expected 4, got 3",
+		"  `Data`!B9: =SUM(A1:A3) + MAX(A1, A2): expected 9, got 8",
+		"",
+		"(no functions): 1 mismatched",
+		"  `Data`!C10: =A1 * 2: expected 3, got 2",
+		"",
+		"SUM: 1 mismatched",
+		"  `Data`!B9: =SUM(A1:A3) + MAX(A1, A2): expected 9, got 8",
+		"",
+		"VLOOKUP: 1 mismatched",
+		"  `Data`!B6: =VLOOKUP(1, A1:A3, 1, 0): expected 1, got #NAME",
+		"",
+		"(unparsed): 1",
+		fmt.Sprintf("  `Data`!B8: %s", report.Unparsed[0].Err),
+		"",
+	}, "\n"), sb.String())
+}
+
+func TestReport_Add(t *testing.T) {
+	total := &Report{}
+	total.Add(&Report{Formulas: 4, Matched: 2, Volatile: 1, Mismatches: []Mismatch{{}}})
+	total.Add(&Report{Formulas: 3, Matched: 3})
+
+	assert.Equal(t, 7, total.Formulas)
+	assert.Equal(t, 5, total.Matched)
+	assert.Len(t, total.Mismatches, 1)
+	assert.InDelta(t, 5.0/6.0, total.Score(), 1e-9)
+	assert.Equal(t, float64(1), (&Report{}).Score())
+}
+
+func TestAssertConforms(t *testing.T) {
+	dir := t.TempDir()
+
+	// Workbooks written by the engine match their own cached values
+	wb := sheets.NewWorkbook()
+	s, err := sheets.NewMutableSheet([][]sheets.Value{
+		{sheets.Float64Value(1), sheets.StringValue("=SUM(A1:A2)")},
+		{sheets.Float64Value(2), sheets.StringValue(`=CONCAT("n=", B1)`)},
+	}, sheets.WithFormulas())
+	require.NoError(t, err)
+	require.NoError(t, wb.AddSheet("Data", s))
+	require.NoError(t, xlsx.WriteFile(context.Background(), filepath.Join(dir, "engine.xlsx"), wb))
+
+	assert.True(t, AssertConforms(t, filepath.Join(dir, "*.xlsx"), Options{}))
+
+	rec := &recordingT{}
+	writeTestWorkbook(t, dir, testSheet)
+	assert.False(t, AssertConforms(rec, filepath.Join(dir, "*.xlsx"), Options{}))
+	assert.Len(t, rec.errors, 5)
+
+	rec = &recordingT{}
+	assert.False(t, AssertConforms(rec, filepath.Join(dir, "*.missing"), Options{}))
+	assert.Equal(t, []string{"no workbooks match '" + filepath.Join(dir, "*.missing") + "'"}, rec.errors)
+}
+
+type recordingT struct {
+	errors []string
+}
+
+func (rt *recordingT) Helper() {}
+
+func (rt *recordingT) Errorf(format string, args ...any) {
+	rt.errors = append(rt.errors, fmt.Sprintf(format, args...))
+}
+
+func TestChecker_Equal(t *testing.T) {
+	c := &checker{tolerance: Options{}.tolerance()}
+	for _, tt := range []struct {
+		expected, actual sheets.Value
+		equal            bool
+	}{
+		{sheets.Float64Value(0), sheets.BlankValue{}, true},
+		{sheets.StringValue(""), sheets.BlankValue{}, true},
+		{sheets.BlankValue{}, sheets.Float64Value(0), true},
+		{sheets.BlankValue{}, sheets.BlankValue{}, true},
+		{sheets.Float64Value(0), sheets.StringValue(""), false},
+		{sheets.StringValue(""), sheets.Float64Value(0), false},
+		{sheets.BlankValue{}, sheets.Float64Value(1), false},
+		{sheets.Float64Value(0), sheets.Float64Value(0), true},
+	} {
+		assert.Equal(t, tt.equal, c.equal(tt.expected, tt.actual), "%#v, %#v", tt.expected, tt.actual)
+	}
+}
+
+// A failingSheet fails to read the value of a cell.
+type failingSheet struct {
+	sheets.FormulaSheet
+	pos sheets.Pos
+}
+
+func (s failingSheet) Get(ctx context.Context, pos sheets.Pos) (sheets.Value, error) {
+	if pos == s.pos {
+		return nil, errors.New("failed")
+	}
+
+	return s.FormulaSheet.Get(ctx, pos)
+}
+
+func TestChecker_ReadErrors(t *testing.T) {
+	s, err := sheets.NewMutableSheet([][]sheets.Value{
+		{sheets.Float64Value(1), sheets.StringValue("=A1*2"), sheets.StringValue("=A1*3")},
+	}, sheets.WithFormulas())
+	require.NoError(t, err)
+
+	cached, err := sheets.NewInMemorySheet([][]sheets.Value{
+		{sheets.Float64Value(1), sheets.Float64Value(2), sheets.Float64Value(3)},
+	})
+	require.NoError(t, err)
+
+	c := &checker{tolerance: Options{}.tolerance()}
+	report := &Report{}
+	live := failingSheet{FormulaSheet: s.(sheets.FormulaSheet), pos: sheets.Pos{Col: 1}}
+	c.checkSheet(context.Background(), report, "Data", live, cached)
+
+	assert.Equal(t, 2, report.Formulas)
+	assert.Equal(t, 1, report.Matched)
+	require.Len(t, report.Mismatches, 1)
+	assert.Equal(t, "unable to compute value: failed", report.Mismatches[0].outcome())
+	assert.Nil(t, report.Mismatches[0].Actual)
+}
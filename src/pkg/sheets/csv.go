@@ -0,0 +1,143 @@
+package sheets
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ReadCSV reads a sheet from CSV data, converting each field into a Value with
+// StringToValue, or with the TypeInferrer passed WithTypeInferrer. Rows may
+// have differing numbers of fields. Empty fields are read as blank cells, unless
+// WithEmptyFieldsAsText is passed. Pass WithFormulas to treat fields that start
+// with "=" as formulas.
+func ReadCSV(r io.Reader, opts ...SheetOption) (MutableSheet, error) {
+	var options sheetOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	inferrer := options.inferrer
+	if inferrer == nil {
+		inferrer = &TypeInferrer{Locale: options.locale, Location: options.location}
+	}
+
+	values, err := inferrer.values(records, options.emptyAsText)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := newInMemorySheet(values, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// A CSVWriteOption is an option for writing a sheet as CSV.
+type CSVWriteOption func(opts *csvWriteOptions)
+
+type csvWriteOptions struct {
+	columnFormats map[int]string
+}
+
+// WithColumnFormat writes the values in a column using an Excel number format
+// code, such as "#,##0.00" or "yyyy-mm-dd", in the same way as the TEXT
+// function. Text values, such as headers, are only changed by formats with a
+// text section.
+func WithColumnFormat(col int, format string) CSVWriteOption {
+	return func(opts *csvWriteOptions) {
+		if opts.columnFormats == nil {
+			opts.columnFormats = make(map[int]string)
+		}
+
+		opts.columnFormats[col] = format
+	}
+}
+
+// WriteCSV writes the values of a sheet as CSV, with every row having a field
+// for each column in the sheet's dimensions. Values are written as Excel
+// displays them: FormattedNumbers use their own format, columns passed to
+// WithColumnFormat use that format, blank cells are empty, and errors are
+// written as their Excel error type, such as "#DIV/0!".
+func WriteCSV(ctx context.Context, w io.Writer, s Sheet, opts ...CSVWriteOption) error {
+	var options csvWriteOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	formats := make(map[int]*NumberFormat, len(options.columnFormats))
+	for col, code := range options.columnFormats {
+		nf, err := ParseNumberFormat(code)
+		if err != nil {
+			return fmt.Errorf("invalid format for column %s: %w", columnToString(col), err)
+		}
+
+		formats[col] = nf
+	}
+
+	writer := csv.NewWriter(w)
+	settings := calcSettingsOf(s)
+	dims := s.Dimensions()
+	for row := 0; row <= dims.EndRow; row++ {
+		record := make([]string, dims.EndCol+1)
+		for col := range record {
+			v, err := s.Get(ctx, Pos{Row: row, Col: col})
+			if err != nil {
+				var posErr InvalidPosError
+				if !errors.As(err, &posErr) {
+					return err
+				}
+
+				v = BlankValue{}
+			}
+
+			record[col] = displayValue(v, formats[col], settings)
+		}
+
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// displayValue returns a value as Excel displays it in a cell, using the given
+// format if it is not nil, and the settings of the sheet holding the value.
+func displayValue(v Value, nf *NumberFormat, settings CalcSettings) string {
+	switch tv := v.(type) {
+	case BlankValue:
+		return ""
+	case ErrorValue:
+		return ErrorCode(tv.Err)
+	}
+
+	if nf == nil {
+		if fn, ok := v.(FormattedNumber); ok {
+			if fnf, err := ParseNumberFormat(fn.Format); err == nil {
+				nf = fnf
+			}
+		}
+	}
+
+	if nf != nil {
+		if s, err := nf.FormatWith(v, settings); err == nil {
+			return s
+		}
+	}
+
+	return settings.text(v)
+}
@@ -0,0 +1,150 @@
+package sheets
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const csvWithFormulas = `item,quantity,price,total
+apples,3,0.5,=B2*C2
+pears,2,1.25,=B3*C3
+,,,=SUM(D2:D3)
+`
+
+func TestReadCSV(t *testing.T) {
+	s, err := ReadCSV(strings.NewReader(csvWithFormulas))
+	require.NoError(t, err)
+	assert.Equal(t, Dimensions{EndRow: 3, EndCol: 3}, s.Dimensions())
+	assertCells(t, s, map[string]Value{
+		"A1": StringValue("item"),
+		"B2": Float64Value(3),
+		"C3": Float64Value(1.25),
+		"D2": StringValue("=B2*C2"),
+	})
+
+	_, ok := s.Formula(mustParsePos(t, "D2"))
+	assert.False(t, ok)
+}
+
+func TestReadCSV_EmptyFields(t *testing.T) {
+	const data = "a,,c\n,\"\",3\n"
+
+	s, err := ReadCSV(strings.NewReader(data))
+	require.NoError(t, err)
+	assertCells(t, s, map[string]Value{
+		"B1": BlankValue{},
+		"A2": BlankValue{},
+		"B2": BlankValue{},
+	})
+
+	s, err = ReadCSV(strings.NewReader(data), WithEmptyFieldsAsText())
+	require.NoError(t, err)
+	assertCells(t, s, map[string]Value{
+		"B1": StringValue(""),
+		"A2": StringValue(""),
+		"B2": StringValue(""),
+	})
+}
+
+func TestReadCSV_WithFormulas(t *testing.T) {
+	s, err := ReadCSV(strings.NewReader(csvWithFormulas), WithFormulas())
+	require.NoError(t, err)
+	assertCells(t, s, map[string]Value{
+		"D2": Float64Value(1.5),
+		"D3": Float64Value(2.5),
+		"D4": Float64Value(4),
+	})
+
+	f, ok := s.Formula(mustParsePos(t, "D4"))
+	require.True(t, ok)
+	assert.Equal(t, "SUM(D2:D3)", f.String())
+
+	_, ok = s.Formula(mustParsePos(t, "B2"))
+	assert.False(t, ok)
+
+	// Formulas recompute as their inputs change
+	require.NoError(t, s.Set(context.TODO(), mustParsePos(t, "B2"), Float64Value(5)))
+	assertCells(t, s, map[string]Value{
+		"D2": Float64Value(2.5),
+		"D4": Float64Value(5),
+	})
+
+	// Text set into the sheet is treated as a formula as well
+	require.NoError(t, s.Set(context.TODO(), mustParsePos(t, "E1"), StringValue("=D4*2")))
+	assertCells(t, s, map[string]Value{
+		"E1": Float64Value(10),
+	})
+
+	err = s.Set(context.TODO(), mustParsePos(t, "E2"), StringValue("=SUM(("))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid formula at E2")
+}
+
+func TestReadCSV_InvalidFormula(t *testing.T) {
+	s, err := ReadCSV(strings.NewReader("=== Totals ===,b\n1,=+\n2,=A2+1\n"), WithFormulas())
+	require.NoError(t, err)
+	assertCells(t, s, map[string]Value{
+		"A1": StringValue("=== Totals ==="),
+		"B2": StringValue("=+"),
+		"B3": Float64Value(2),
+	})
+
+	_, ok := s.(FormulaSheet).Formula(mustParsePos(t, "A1"))
+	assert.False(t, ok)
+}
+
+func TestReadCSV_WithLocation(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	require.NoError(t, err)
+
+	s, err := ReadCSV(strings.NewReader(`2024-03-05 08:00:00,2024-03-05T08:00:00Z,"=TEXT(A1, ""yyyy-mm-dd hh:mm"")"
+"=TEXT(B1, ""yyyy-mm-dd hh:mm"")","=A1 >= ""2024-03-05 07:30:00""","=TEXT(TODAY(), ""hh:mm"")"
+`), WithFormulas(), WithLocation(tokyo))
+	require.NoError(t, err)
+
+	// Times without an offset are in Tokyo, as are the formulas in the sheet
+	assertCells(t, s, map[string]Value{
+		"A1": TimeValue(time.Date(2024, time.March, 5, 8, 0, 0, 0, tokyo)),
+		"B1": TimeValue(time.Date(2024, time.March, 5, 8, 0, 0, 0, time.UTC)),
+		"C1": StringValue("2024-03-05 08:00"),
+		"A2": StringValue("2024-03-05 17:00"),
+		"B2": BoolValue(true),
+		"C2": StringValue("00:00"),
+	})
+}
+
+func TestWriteCSV(t *testing.T) {
+	ctx := context.TODO()
+	s, err := ReadCSV(strings.NewReader(`item,quantity,price,total,sold
+apples,3,0.5,=B2*C2,2024-03-05
+pears,2,"$1,250.00",=B3*C3,2024-03-06
+`), WithFormulas(), WithTypeInferrer(&TypeInferrer{KeepNumberFormats: true}))
+	require.NoError(t, err)
+
+	require.NoError(t, s.Set(ctx, mustParsePos(t, "F4"), StringValue("=1/0")))
+
+	var sb strings.Builder
+	require.NoError(t, WriteCSV(ctx, &sb, s,
+		WithColumnFormat(3, "#,##0.00"),
+		WithColumnFormat(4, "dd/mm/yyyy")))
+
+	assert.Equal(t, `item,quantity,price,total,sold,
+apples,3,0.5,1.50,05/03/2024,
+pears,2,"$1,250.00","2,500.00",06/03/2024,
+,,,,,#DIV/0!
+`, sb.String())
+}
+
+func TestWriteCSV_InvalidFormat(t *testing.T) {
+	s, err := NewInMemorySheet([][]Value{{Float64Value(1)}})
+	require.NoError(t, err)
+
+	err = WriteCSV(context.TODO(), &strings.Builder{}, s, WithColumnFormat(1, `"unterminated`))
+	assert.EqualError(t, err,
+		`invalid format for column B: invalid number format '"unterminated': unterminated string`)
+}
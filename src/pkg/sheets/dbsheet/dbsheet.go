@@ -0,0 +1,537 @@
+// Package dbsheet provides Sheets backed by the results of database/sql
+// queries, so that formulas can refer to tables without exporting them.
+// Cells are fetched a page of rows at a time, rather than loading the whole
+// result.
+package dbsheet
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mmihic/sheets/src/pkg/sheets"
+)
+
+// DefaultPageSize is the number of rows fetched by each query when no page
+// size is given.
+const DefaultPageSize = 1000
+
+// An Option is an option for opening a Sheet.
+type Option func(opts *options)
+
+type options struct {
+	pageSize     int
+	orderBy      string
+	keyColumn    string
+	placeholders func(n int) string
+	quote        string
+}
+
+// applyOptions returns the default options with the given options applied.
+func applyOptions(opts []Option) options {
+	o := options{
+		pageSize:     DefaultPageSize,
+		placeholders: func(int) string { return "?" },
+		quote:        `"`,
+	}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// WithPageSize sets the number of rows fetched by each query.
+func WithPageSize(n int) Option {
+	return func(opts *options) {
+		opts.pageSize = n
+	}
+}
+
+// WithOrderBy orders the rows of the sheet by an SQL expression, such as
+// "name, id DESC". Since rows are fetched a page at a time, rows without an
+// order may appear in different positions from one query to the next unless
+// the query orders them itself.
+func WithOrderBy(expr string) Option {
+	return func(opts *options) {
+		opts.orderBy = expr
+	}
+}
+
+// WithKeyColumn orders the rows of the sheet by a column with unique values,
+// and fetches each page following the first by the last key of the previous
+// page (keyset paging), rather than by skipping rows with OFFSET, which is
+// slow for large tables.
+func WithKeyColumn(name string) Option {
+	return func(opts *options) {
+		opts.keyColumn = name
+	}
+}
+
+// WithDollarPlaceholders writes query parameters as $1, $2 and so on, as used
+// by PostgreSQL, rather than as "?".
+func WithDollarPlaceholders() Option {
+	return func(opts *options) {
+		opts.placeholders = func(n int) string { return "$" + strconv.Itoa(n) }
+	}
+}
+
+// WithIdentifierQuote quotes table and column names with the given character,
+// such as "`" for MySQL and MariaDB, rather than with the standard '"'. The
+// character is escaped within names by doubling it.
+func WithIdentifierQuote(quote rune) Option {
+	return func(opts *options) {
+		opts.quote = string(quote)
+	}
+}
+
+// A Sheet is a sheet holding the results of a query. The first row holds the
+// names of the result's columns, and each following row holds a row of the
+// result. Values are converted from their SQL types: numbers to
+// Float64Values, booleans to BoolValues, text and bytes to StringValues,
+// times to TimeValues, and NULL to blanks. Integers in BOOLEAN columns are
+// converted to BoolValues, for databases such as SQLite that store booleans
+// as integers.
+//
+// The dimensions of a sheet are those of the result when the sheet was opened
+// or last refreshed. Rows are not cached across calls to Range, so changes to
+// the underlying tables are seen as they happen.
+type Sheet struct {
+	db      *sql.DB
+	query   string
+	opts    options
+	columns []string
+	kinds   []columnKind
+
+	mu       sync.Mutex
+	rows     int
+	page     [][]sheets.Value
+	pageRow  int
+	hasCache bool
+}
+
+var _ sheets.Sheet = &Sheet{}
+
+// Table opens a sheet holding the rows of a table.
+func Table(ctx context.Context, db *sql.DB, table string, opts ...Option) (*Sheet, error) {
+	o := applyOptions(opts)
+	return Query(ctx, db, "SELECT * FROM "+o.quoteIdent(table), opts...)
+}
+
+// Query opens a sheet holding the results of a query, which must not end in a
+// semicolon since it is nested within the queries that fetch each page.
+func Query(ctx context.Context, db *sql.DB, query string, opts ...Option) (*Sheet, error) {
+	s := &Sheet{
+		db:    db,
+		query: query,
+		opts:  applyOptions(opts),
+	}
+
+	if s.opts.pageSize <= 0 {
+		return nil, fmt.Errorf("invalid page size %d", s.opts.pageSize)
+	}
+
+	if err := s.queryColumns(ctx); err != nil {
+		return nil, fmt.Errorf("unable to query columns: %w", err)
+	}
+
+	if s.opts.keyColumn != "" && s.columnIndex(s.opts.keyColumn) < 0 {
+		return nil, fmt.Errorf("no such key column '%s'", s.opts.keyColumn)
+	}
+
+	if err := s.Refresh(ctx); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// queryColumns reads the names and types of the columns of the result. The
+// rows are closed before returning, so that databases limited to a single
+// connection, such as an in-memory SQLite database, can run the next query.
+func (s *Sheet) queryColumns(ctx context.Context) error {
+	rows, err := s.db.QueryContext(ctx, "SELECT * FROM ("+s.query+") AS q LIMIT 0")
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = rows.Close() }()
+
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return err
+	}
+
+	for _, ct := range types {
+		s.columns = append(s.columns, ct.Name())
+		s.kinds = append(s.kinds, kindOf(ct.DatabaseTypeName()))
+	}
+
+	return rows.Close()
+}
+
+// Columns returns the names of the columns of the sheet.
+func (s *Sheet) Columns() []string {
+	return s.columns
+}
+
+// Refresh counts the rows of the result again, and discards any cached
+// rows.
+func (s *Sheet) Refresh(ctx context.Context) error {
+	var n int
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM ("+s.query+") AS q").Scan(&n); err != nil {
+		return fmt.Errorf("unable to count rows: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.rows = n
+	s.page, s.hasCache = nil, false
+	return nil
+}
+
+// Dimensions returns the dimensions of the sheet.
+func (s *Sheet) Dimensions() sheets.Dimensions {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return sheets.Dimensions{EndRow: s.rows, EndCol: len(s.columns) - 1}
+}
+
+// Get returns the value of a cell, fetching the page of rows holding it if
+// it is not the page most recently fetched by Get.
+func (s *Sheet) Get(ctx context.Context, pos sheets.Pos) (sheets.Value, error) {
+	dims := s.Dimensions()
+	if pos.Row < 0 || pos.Col < 0 || pos.Row > dims.EndRow || pos.Col > dims.EndCol {
+		return nil, sheets.InvalidPosError{Pos: pos}
+	}
+
+	if pos.Row == 0 {
+		return sheets.StringValue(s.columns[pos.Col]), nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.hasCache || pos.Row < s.pageRow || pos.Row >= s.pageRow+len(s.page) {
+		// Pages are aligned so that nearby cells share a page
+		start := (pos.Row-1)/s.opts.pageSize*s.opts.pageSize + 1
+		page, _, err := s.fetch(ctx, allColumns(len(s.columns)), start, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		s.page, s.pageRow, s.hasCache = page, start, true
+	}
+
+	if i := pos.Row - s.pageRow; i < len(s.page) {
+		return s.page[i][pos.Col], nil
+	}
+
+	// The result has fewer rows than when it was counted
+	return sheets.BlankValue{}, nil
+}
+
+// Range returns the values of a range of cells, fetching only the columns
+// within the range, a page of rows at a time.
+func (s *Sheet) Range(_ context.Context, r sheets.Range) (sheets.ValueRange, error) {
+	resolved, ok := r.Resolve(s.Dimensions())
+	if !ok {
+		return &valueRange{s: s, bounds: r, empty: true}, nil
+	}
+
+	cols := make([]int, 0, resolved.EndCol-resolved.StartCol+1)
+	for col := resolved.StartCol; col <= resolved.EndCol; col++ {
+		cols = append(cols, col)
+	}
+
+	return &valueRange{
+		s:      s,
+		bounds: resolved,
+		cols:   cols,
+		pos:    sheets.Pos{Row: resolved.StartRow, Col: resolved.StartCol - 1},
+		index:  -1,
+	}, nil
+}
+
+// fetch fetches a page of rows holding the given columns, starting at the
+// given row of the sheet. If after is not nil, the rows following that key
+// are fetched instead of skipping to the start row. Returns the rows and the
+// key of the last row.
+func (s *Sheet) fetch(ctx context.Context, cols []int, start int, after any) ([][]sheets.Value, any, error) {
+	keyIndex := -1
+	selected := make([]string, 0, len(cols)+1)
+	for _, col := range cols {
+		selected = append(selected, s.opts.quoteIdent(s.columns[col]))
+	}
+
+	if s.opts.keyColumn != "" {
+		keyIndex = len(selected)
+		selected = append(selected, s.opts.quoteIdent(s.opts.keyColumn))
+	}
+
+	var (
+		sb   strings.Builder
+		args []any
+	)
+
+	fmt.Fprintf(&sb, "SELECT %s FROM (%s) AS q", strings.Join(selected, ", "), s.query)
+	if after != nil {
+		fmt.Fprintf(&sb, " WHERE %s > %s", s.opts.quoteIdent(s.opts.keyColumn), s.opts.placeholders(1))
+		args = append(args, after)
+	}
+
+	switch {
+	case s.opts.keyColumn != "":
+		fmt.Fprintf(&sb, " ORDER BY %s", s.opts.quoteIdent(s.opts.keyColumn))
+	case s.opts.orderBy != "":
+		fmt.Fprintf(&sb, " ORDER BY %s", s.opts.orderBy)
+	}
+
+	fmt.Fprintf(&sb, " LIMIT %d", s.opts.pageSize)
+	if after == nil && start > 1 {
+		fmt.Fprintf(&sb, " OFFSET %d", start-1)
+	}
+
+	rows, err := s.db.QueryContext(ctx, sb.String(), args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to fetch rows: %w", err)
+	}
+
+	defer func() { _ = rows.Close() }()
+
+	var (
+		page    [][]sheets.Value
+		lastKey any
+	)
+
+	raw := make([]any, len(selected))
+	dest := make([]any, len(selected))
+	for i := range raw {
+		dest[i] = &raw[i]
+	}
+
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return nil, nil, fmt.Errorf("unable to fetch rows: %w", err)
+		}
+
+		row := make([]sheets.Value, len(s.columns))
+		for i, col := range cols {
+			v, err := toValue(raw[i], s.kinds[col])
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid value in column '%s': %w", s.columns[col], err)
+			}
+
+			row[col] = v
+		}
+
+		if keyIndex >= 0 {
+			lastKey = raw[keyIndex]
+		}
+
+		page = append(page, row)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("unable to fetch rows: %w", err)
+	}
+
+	return page, lastKey, nil
+}
+
+func (s *Sheet) columnIndex(name string) int {
+	for i, col := range s.columns {
+		if col == name {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func allColumns(n int) []int {
+	cols := make([]int, n)
+	for i := range cols {
+		cols[i] = i
+	}
+
+	return cols
+}
+
+// A valueRange iterates over a range of cells, fetching a page of rows at a
+// time.
+type valueRange struct {
+	s      *Sheet
+	bounds sheets.Range
+	cols   []int
+	empty  bool
+
+	pos     sheets.Pos
+	index   int
+	value   sheets.Value
+	page    [][]sheets.Value
+	pageRow int
+	lastKey any
+	done    bool
+	err     error
+}
+
+var _ sheets.ValueRange = &valueRange{}
+
+func (vr *valueRange) Next(ctx context.Context) bool {
+	if vr.empty || vr.err != nil {
+		return false
+	}
+
+	if vr.index < 0 {
+		vr.pos = vr.bounds.StartPos()
+	} else {
+		next, ok := vr.bounds.NextPos(vr.pos)
+		if !ok {
+			return false
+		}
+
+		vr.pos = next
+	}
+
+	vr.index++
+	if vr.pos.Row == 0 {
+		vr.value = sheets.StringValue(vr.s.columns[vr.pos.Col])
+		return true
+	}
+
+	if vr.pos.Row >= vr.pageRow+len(vr.page) && !vr.done {
+		if err := vr.nextPage(ctx); err != nil {
+			vr.err = err
+			return false
+		}
+	}
+
+	vr.value = sheets.BlankValue{}
+	if i := vr.pos.Row - vr.pageRow; i >= 0 && i < len(vr.page) {
+		vr.value = vr.page[i][vr.pos.Col]
+	}
+
+	return true
+}
+
+// nextPage fetches the page of rows following the current page, or starting
+// at the current row if there is no current page.
+func (vr *valueRange) nextPage(ctx context.Context) error {
+	start := vr.pos.Row
+	after := vr.lastKey
+	if vr.page == nil {
+		after = nil
+	}
+
+	page, lastKey, err := vr.s.fetch(ctx, vr.cols, start, after)
+	if err != nil {
+		return err
+	}
+
+	vr.page, vr.pageRow, vr.lastKey = page, start, lastKey
+	vr.done = len(page) < vr.s.opts.pageSize
+	return nil
+}
+
+func (vr *valueRange) Err() error {
+	return vr.err
+}
+
+func (vr *valueRange) Value() sheets.Value {
+	return vr.value
+}
+
+func (vr *valueRange) Index() int {
+	return vr.index
+}
+
+func (vr *valueRange) Len() int {
+	if vr.empty {
+		return 0
+	}
+
+	return vr.bounds.NumCells()
+}
+
+func (vr *valueRange) Pos() sheets.Pos {
+	return vr.pos
+}
+
+// A columnKind is the kind of values held by a column, for values whose
+// conversion depends on the type of their column.
+type columnKind int
+
+const (
+	otherColumn columnKind = iota
+	numericColumn
+	boolColumn
+)
+
+// kindOf returns the kind of a column with the given database type, such as
+// DECIMAL(10,2).
+func kindOf(dbType string) columnKind {
+	name, _, _ := strings.Cut(dbType, "(")
+	switch strings.ToUpper(strings.TrimSpace(name)) {
+	case "DECIMAL", "NUMERIC", "NUMBER", "REAL", "FLOAT", "DOUBLE", "INTEGER", "INT", "BIGINT", "SMALLINT":
+		return numericColumn
+	case "BOOLEAN", "BOOL":
+		return boolColumn
+	default:
+		return otherColumn
+	}
+}
+
+// toValue converts a value scanned from a database into a Value.
+func toValue(v any, kind columnKind) (sheets.Value, error) {
+	switch tv := v.(type) {
+	case nil:
+		return sheets.BlankValue{}, nil
+	case int64:
+		if kind == boolColumn {
+			return sheets.BoolValue(tv != 0), nil
+		}
+
+		return sheets.Float64Value(tv), nil
+	case float64:
+		return sheets.Float64Value(tv), nil
+	case bool:
+		return sheets.BoolValue(tv), nil
+	case time.Time:
+		return sheets.TimeValue(tv), nil
+	case []byte:
+		return textValue(string(tv), kind)
+	case string:
+		return textValue(tv, kind)
+	default:
+		return nil, fmt.Errorf("unsupported type %T", v)
+	}
+}
+
+// textValue converts text into a Value, parsing it as a number if it comes
+// from a numeric column, such as a DECIMAL returned as text.
+func textValue(s string, kind columnKind) (sheets.Value, error) {
+	if kind != numericColumn {
+		return sheets.StringValue(s), nil
+	}
+
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, errors.New("invalid number '" + s + "'")
+	}
+
+	return sheets.Float64Value(n), nil
+}
+
+// quoteIdent quotes an identifier, such as a table or column name.
+func (o *options) quoteIdent(name string) string {
+	return o.quote + strings.ReplaceAll(name, o.quote, o.quote+o.quote) + o.quote
+}
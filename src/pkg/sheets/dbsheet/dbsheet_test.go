@@ -0,0 +1,584 @@
+package dbsheet
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite"
+
+	"github.com/mmihic/sheets/src/pkg/sheets"
+)
+
+// The tests use a fake driver that answers the queries issued by a Sheet over
+// a single table, recording each query so that paging can be checked.
+
+type fakeTable struct {
+	columns []string
+	types   []string
+	rows    [][]driver.Value
+
+	mu      sync.Mutex
+	queries []string
+}
+
+func (ft *fakeTable) Open(string) (driver.Conn, error) {
+	return &fakeConn{table: ft}, nil
+}
+
+func (ft *fakeTable) recorded() []string {
+	ft.mu.Lock()
+	defer ft.mu.Unlock()
+
+	queries := ft.queries
+	ft.queries = nil
+	return queries
+}
+
+type fakeConn struct {
+	table *fakeTable
+}
+
+func (fc *fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("not supported")
+}
+
+func (fc *fakeConn) Close() error {
+	return nil
+}
+
+func (fc *fakeConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("not supported")
+}
+
+var (
+	countQuery = regexp.MustCompile(`^SELECT COUNT\(\*\) FROM \(SELECT \* FROM "items"\) AS q$`)
+	pageQuery  = regexp.MustCompile(`^SELECT (.+) FROM \(SELECT \* FROM "items"\) AS q` +
+		`(?: WHERE "(\w+)" > \?)?(?: ORDER BY [^L]+)? LIMIT (\d+)(?: OFFSET (\d+))?$`)
+)
+
+func (fc *fakeConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	ft := fc.table
+	ft.mu.Lock()
+	ft.queries = append(ft.queries, query)
+	ft.mu.Unlock()
+
+	if countQuery.MatchString(query) {
+		return &fakeRows{columns: []string{"count"}, types: []string{"INTEGER"},
+			rows: [][]driver.Value{{int64(len(ft.rows))}}}, nil
+	}
+
+	m := pageQuery.FindStringSubmatch(query)
+	if m == nil {
+		return nil, fmt.Errorf("unsupported query: %s", query)
+	}
+
+	var indexes []int
+	rows := &fakeRows{}
+	for _, col := range strings.Split(m[1], ", ") {
+		i := -1
+		for j, name := range ft.columns {
+			if col == "*" || `"`+name+`"` == col {
+				indexes = append(indexes, j)
+				rows.columns = append(rows.columns, name)
+				rows.types = append(rows.types, ft.types[j])
+				i = j
+			}
+		}
+
+		if i < 0 {
+			return nil, fmt.Errorf("no such column %s", col)
+		}
+	}
+
+	limit, _ := strconv.Atoi(m[3])
+	offset, _ := strconv.Atoi(m[4])
+	for _, row := range ft.rows {
+		if m[2] != "" && row[0].(int64) <= args[0].Value.(int64) {
+			continue
+		}
+
+		if offset > 0 {
+			offset--
+			continue
+		}
+
+		if len(rows.rows) == limit {
+			break
+		}
+
+		selected := make([]driver.Value, len(indexes))
+		for i, j := range indexes {
+			selected[i] = row[j]
+		}
+
+		rows.rows = append(rows.rows, selected)
+	}
+
+	return rows, nil
+}
+
+type fakeRows struct {
+	columns []string
+	types   []string
+	rows    [][]driver.Value
+}
+
+func (fr *fakeRows) Columns() []string {
+	return fr.columns
+}
+
+func (fr *fakeRows) ColumnTypeDatabaseTypeName(i int) string {
+	return fr.types[i]
+}
+
+func (fr *fakeRows) Close() error {
+	return nil
+}
+
+func (fr *fakeRows) Next(dest []driver.Value) error {
+	if len(fr.rows) == 0 {
+		return io.EOF
+	}
+
+	copy(dest, fr.rows[0])
+	fr.rows = fr.rows[1:]
+	return nil
+}
+
+var (
+	testTable = &fakeTable{
+		columns: []string{"id", "name", "price", "added", "active"},
+		types:   []string{"INTEGER", "TEXT", "DECIMAL", "TIMESTAMP", "BOOLEAN"},
+	}
+	registerOnce sync.Once
+)
+
+func openTestDB(t *testing.T) (*sql.DB, *fakeTable) {
+	registerOnce.Do(func() {
+		for i := 1; i <= 10; i++ {
+			var name driver.Value = fmt.Sprintf("item %d", i)
+			if i == 4 {
+				name = nil
+			}
+
+			testTable.rows = append(testTable.rows, []driver.Value{
+				int64(i * 10),
+				name,
+				[]byte(fmt.Sprintf("%d.25", i)),
+				time.Date(2024, time.March, i, 0, 0, 0, 0, time.UTC),
+				i%2 == 0,
+			})
+		}
+
+		sql.Register("dbsheet-test", testTable)
+	})
+
+	db, err := sql.Open("dbsheet-test", "")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	testTable.recorded()
+	return db, testTable
+}
+
+func TestTable(t *testing.T) {
+	ctx := context.Background()
+	db, ft := openTestDB(t)
+
+	s, err := Table(ctx, db, "items", WithPageSize(4), WithOrderBy("id"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"id", "name", "price", "added", "active"}, s.Columns())
+	assert.Equal(t, sheets.Dimensions{EndRow: 10, EndCol: 4}, s.Dimensions())
+	assert.Equal(t, []string{
+		`SELECT * FROM (SELECT * FROM "items") AS q LIMIT 0`,
+		`SELECT COUNT(*) FROM (SELECT * FROM "items") AS q`,
+	}, ft.recorded())
+
+	for posText, expected := range map[string]sheets.Value{
+		"A1": sheets.StringValue("id"),
+		"A2": sheets.Float64Value(10),
+		"B2": sheets.StringValue("item 1"),
+		"C2": sheets.Float64Value(1.25),
+		"D2": sheets.TimeValue(time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)),
+		"E2": sheets.BoolValue(false),
+		"B5": sheets.BlankValue{},
+		"E5": sheets.BoolValue(true),
+	} {
+		pos, err := sheets.ParsePos(posText)
+		require.NoError(t, err)
+
+		v, err := s.Get(ctx, pos)
+		require.NoError(t, err)
+		assert.Equal(t, expected, v, posText)
+	}
+
+	// Cells on the same page are fetched once
+	assert.Equal(t, []string{
+		`SELECT "id", "name", "price", "added", "active" FROM (SELECT * FROM "items") AS q ORDER BY id LIMIT 4`,
+	}, ft.recorded())
+
+	v, err := s.Get(ctx, sheets.Pos{Row: 9, Col: 0})
+	require.NoError(t, err)
+	assert.Equal(t, sheets.Float64Value(90), v)
+	assert.Equal(t, []string{
+		`SELECT "id", "name", "price", "added", "active" FROM (SELECT * FROM "items") AS q ORDER BY id LIMIT 4 OFFSET 8`,
+	}, ft.recorded())
+
+	_, err = s.Get(ctx, sheets.Pos{Row: 11, Col: 0})
+	assert.ErrorAs(t, err, &sheets.InvalidPosError{})
+}
+
+func TestSheet_Range(t *testing.T) {
+	ctx := context.Background()
+	db, ft := openTestDB(t)
+
+	s, err := Table(ctx, db, "items", WithPageSize(4))
+	require.NoError(t, err)
+	ft.recorded()
+
+	r, err := sheets.ParseRange("B:C")
+	require.NoError(t, err)
+
+	vr, err := s.Range(ctx, r)
+	require.NoError(t, err)
+	assert.Equal(t, 22, vr.Len())
+
+	var values []string
+	for vr.Next(ctx) {
+		values = append(values, vr.Pos().String()+"="+vr.Value().String())
+	}
+
+	require.NoError(t, vr.Err())
+	assert.Equal(t, "B1=name", values[0])
+	assert.Equal(t, "C1=price", values[1])
+	assert.Equal(t, "B2=item 1", values[2])
+	assert.Equal(t, "C11=10.25", values[21])
+	assert.Len(t, values, 22)
+
+	// Only the columns in the range are fetched, a page at a time
+	assert.Equal(t, []string{
+		`SELECT "name", "price" FROM (SELECT * FROM "items") AS q LIMIT 4`,
+		`SELECT "name", "price" FROM (SELECT * FROM "items") AS q LIMIT 4 OFFSET 4`,
+		`SELECT "name", "price" FROM (SELECT * FROM "items") AS q LIMIT 4 OFFSET 8`,
+	}, ft.recorded())
+}
+
+func TestSheet_RangeKeyset(t *testing.T) {
+	ctx := context.Background()
+	db, ft := openTestDB(t)
+
+	s, err := Table(ctx, db, "items", WithPageSize(3), WithKeyColumn("id"))
+	require.NoError(t, err)
+	ft.recorded()
+
+	r, err := sheets.ParseRange("B3:B9")
+	require.NoError(t, err)
+
+	vr, err := s.Range(ctx, r)
+	require.NoError(t, err)
+
+	var values []sheets.Value
+	for vr.Next(ctx) {
+		values = append(values, vr.Value())
+	}
+
+	require.NoError(t, vr.Err())
+	assert.Equal(t, []sheets.Value{
+		sheets.StringValue("item 2"), sheets.StringValue("item 3"), sheets.BlankValue{},
+		sheets.StringValue("item 5"), sheets.StringValue("item 6"), sheets.StringValue("item 7"),
+		sheets.StringValue("item 8"),
+	}, values)
+
+	// Pages after the first follow the last key
+	assert.Equal(t, []string{
+		`SELECT "name", "id" FROM (SELECT * FROM "items") AS q ORDER BY "id" LIMIT 3 OFFSET 1`,
+		`SELECT "name", "id" FROM (SELECT * FROM "items") AS q WHERE "id" > ? ORDER BY "id" LIMIT 3`,
+		`SELECT "name", "id" FROM (SELECT * FROM "items") AS q WHERE "id" > ? ORDER BY "id" LIMIT 3`,
+	}, ft.recorded())
+}
+
+func TestSheet_Formulas(t *testing.T) {
+	ctx := context.Background()
+	db, _ := openTestDB(t)
+
+	items, err := Table(ctx, db, "items", WithPageSize(4))
+	require.NoError(t, err)
+
+	wb := sheets.NewWorkbook()
+	require.NoError(t, wb.AddSheet("Items", items))
+
+	lookups, err := sheets.NewMutableSheet([][]sheets.Value{
+		{sheets.StringValue("=MATCH(70, 'Items'!A:A, 0)")},
+		{sheets.StringValue("=SUM('Items'!C2:C11)")},
+	}, sheets.WithFormulas())
+	require.NoError(t, err)
+	require.NoError(t, wb.AddSheet("Lookups", lookups))
+
+	v, err := lookups.Get(ctx, sheets.Pos{Row: 0, Col: 0})
+	require.NoError(t, err)
+	assert.Equal(t, sheets.Float64Value(8), v)
+
+	v, err = lookups.Get(ctx, sheets.Pos{Row: 1, Col: 0})
+	require.NoError(t, err)
+	assert.Equal(t, sheets.Float64Value(57.5), v)
+}
+
+func TestQuery_Invalid(t *testing.T) {
+	ctx := context.Background()
+	db, _ := openTestDB(t)
+
+	_, err := Table(ctx, db, "items", WithKeyColumn("missing"))
+	assert.EqualError(t, err, "no such key column 'missing'")
+
+	_, err = Table(ctx, db, "items", WithPageSize(0))
+	assert.EqualError(t, err, "invalid page size 0")
+
+	_, err = Table(ctx, db, "other")
+	assert.ErrorContains(t, err, "unable to query columns")
+}
+
+func TestToValue(t *testing.T) {
+	v, err := toValue([]byte("12.5"), numericColumn)
+	require.NoError(t, err)
+	assert.Equal(t, sheets.Float64Value(12.5), v)
+
+	v, err = toValue("12.5", otherColumn)
+	require.NoError(t, err)
+	assert.Equal(t, sheets.StringValue("12.5"), v)
+
+	_, err = toValue([]byte("x"), numericColumn)
+	assert.EqualError(t, err, "invalid number 'x'")
+
+	_, err = toValue(struct{}{}, otherColumn)
+	assert.EqualError(t, err, "unsupported type struct {}")
+
+	v, err = toValue(int64(1), boolColumn)
+	require.NoError(t, err)
+	assert.Equal(t, sheets.BoolValue(true), v)
+}
+
+func TestKindOf(t *testing.T) {
+	assert.Equal(t, numericColumn, kindOf("DECIMAL(10,2)"))
+	assert.Equal(t, numericColumn, kindOf("numeric"))
+	assert.Equal(t, boolColumn, kindOf("BOOLEAN"))
+	assert.Equal(t, otherColumn, kindOf("VARCHAR(20)"))
+}
+
+// The remaining tests run real queries against an in-memory SQLite database,
+// whose names need quoting and whose types differ from those of the fake
+// driver.
+
+const sqliteTable = `order "items"`
+
+func openSQLite(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite", ":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	// Each connection has its own in-memory database
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(`CREATE TABLE "order ""items""" (
+		"item id" INTEGER PRIMARY KEY,
+		"unit ""price""" DECIMAL(10,2),
+		name TEXT,
+		added TIMESTAMP,
+		active BOOLEAN,
+		ratio REAL,
+		data BLOB
+	)`)
+	require.NoError(t, err)
+
+	for i := 1; i <= 10; i++ {
+		var name any = fmt.Sprintf("item %d", i)
+		if i == 4 {
+			name = nil
+		}
+
+		// Keys are inserted out of order, so that rows are only in order when
+		// they are ordered by the query
+		_, err := db.Exec(`INSERT INTO "order ""items""" VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			(i*7)%10+1, float64(i)+0.25, name, time.Date(2024, time.March, i, 12, 30, 0, 0, time.UTC),
+			i%2 == 0, float64(i)/8, []byte(fmt.Sprintf("blob %d", i)))
+		require.NoError(t, err)
+	}
+
+	return db
+}
+
+func TestSQLite_Table(t *testing.T) {
+	ctx := context.Background()
+	db := openSQLite(t)
+
+	s, err := Table(ctx, db, sqliteTable, WithPageSize(3), WithOrderBy(`"item id"`))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"item id", `unit "price"`, "name", "added", "active", "ratio", "data"}, s.Columns())
+	assert.Equal(t, sheets.Dimensions{EndRow: 10, EndCol: 6}, s.Dimensions())
+
+	// Row 2 holds key 1, inserted by i = 10
+	for posText, expected := range map[string]sheets.Value{
+		"A1":  sheets.StringValue("item id"),
+		"B1":  sheets.StringValue(`unit "price"`),
+		"A2":  sheets.Float64Value(1),
+		"B2":  sheets.Float64Value(10.25),
+		"C2":  sheets.StringValue("item 10"),
+		"D2":  sheets.TimeValue(time.Date(2024, time.March, 10, 12, 30, 0, 0, time.UTC)),
+		"E2":  sheets.BoolValue(true),
+		"F2":  sheets.Float64Value(1.25),
+		"G2":  sheets.StringValue("blob 10"),
+		"A11": sheets.Float64Value(10),
+		"C11": sheets.StringValue("item 7"),
+		"E11": sheets.BoolValue(false),
+		"C10": sheets.BlankValue{},
+	} {
+		pos, err := sheets.ParsePos(posText)
+		require.NoError(t, err)
+
+		v, err := s.Get(ctx, pos)
+		require.NoError(t, err)
+		assert.Equal(t, expected, v, posText)
+	}
+}
+
+func TestSQLite_Paging(t *testing.T) {
+	ctx := context.Background()
+	db := openSQLite(t)
+
+	expected := []sheets.Value{sheets.StringValue("name")}
+	for key := 1; key <= 10; key++ {
+		// Key k is inserted by the i where (i*7)%10+1 == k
+		i := (key - 1) * 3 % 10
+		if i == 0 {
+			i = 10
+		}
+
+		if i == 4 {
+			expected = append(expected, sheets.BlankValue{})
+			continue
+		}
+
+		expected = append(expected, sheets.StringValue(fmt.Sprintf("item %d", i)))
+	}
+
+	r, err := sheets.ParseRange("C:C")
+	require.NoError(t, err)
+
+	for _, tt := range []struct {
+		name string
+		opts []Option
+	}{
+		{"offset", []Option{WithOrderBy(`"item id"`)}},
+		{"keyset", []Option{WithKeyColumn("item id")}},
+	} {
+		for _, pageSize := range []int{1, 3, 10, 20} {
+			t.Run(fmt.Sprintf("%s/%d", tt.name, pageSize), func(t *testing.T) {
+				s, err := Table(ctx, db, sqliteTable, append(tt.opts, WithPageSize(pageSize))...)
+				require.NoError(t, err)
+
+				vr, err := s.Range(ctx, r)
+				require.NoError(t, err)
+
+				var values []sheets.Value
+				for vr.Next(ctx) {
+					values = append(values, vr.Value())
+				}
+
+				require.NoError(t, vr.Err())
+				assert.Equal(t, expected, values)
+			})
+		}
+	}
+}
+
+func TestSQLite_Query(t *testing.T) {
+	ctx := context.Background()
+	db := openSQLite(t)
+
+	s, err := Query(ctx, db, `SELECT name AS "the ""name""", "unit ""price""" * 2 AS doubled `+
+		`FROM "order ""items""" WHERE active ORDER BY "item id"`, WithPageSize(2))
+	require.NoError(t, err)
+	assert.Equal(t, []string{`the "name"`, "doubled"}, s.Columns())
+	assert.Equal(t, sheets.Dimensions{EndRow: 5, EndCol: 1}, s.Dimensions())
+
+	wb := sheets.NewWorkbook()
+	require.NoError(t, wb.AddSheet("Active", s))
+
+	totals, err := sheets.NewMutableSheet([][]sheets.Value{
+		{sheets.StringValue("=SUM('Active'!B:B)")},
+		{sheets.StringValue("=COUNTBLANK('Active'!A2:A6)")},
+	}, sheets.WithFormulas())
+	require.NoError(t, err)
+	require.NoError(t, wb.AddSheet("Totals", totals))
+
+	v, err := totals.Get(ctx, sheets.Pos{Row: 0, Col: 0})
+	require.NoError(t, err)
+	assert.Equal(t, sheets.Float64Value(2*(2.25+4.25+6.25+8.25+10.25)), v)
+
+	v, err = totals.Get(ctx, sheets.Pos{Row: 1, Col: 0})
+	require.NoError(t, err)
+	assert.Equal(t, sheets.Float64Value(1), v)
+
+	// Rows added to the table are seen once the sheet is refreshed
+	_, err = db.Exec(`INSERT INTO "order ""items""" ("item id", "unit ""price""", name, active) ` +
+		`VALUES (11, 1, 'item 11', TRUE)`)
+	require.NoError(t, err)
+	assert.Equal(t, sheets.Dimensions{EndRow: 5, EndCol: 1}, s.Dimensions())
+
+	require.NoError(t, s.Refresh(ctx))
+	assert.Equal(t, sheets.Dimensions{EndRow: 6, EndCol: 1}, s.Dimensions())
+
+	v, err = s.Get(ctx, sheets.Pos{Row: 6, Col: 0})
+	require.NoError(t, err)
+	assert.Equal(t, sheets.StringValue("item 11"), v)
+
+	_, err = Query(ctx, db, "SELECT * FROM missing")
+	assert.ErrorContains(t, err, "unable to query columns")
+}
+
+func TestQuoteIdent(t *testing.T) {
+	ansi := applyOptions(nil)
+	assert.Equal(t, `"order ""items"""`, ansi.quoteIdent(`order "items"`))
+
+	mysql := applyOptions([]Option{WithIdentifierQuote('`')})
+	assert.Equal(t, "`order \"items\"`", mysql.quoteIdent(`order "items"`))
+	assert.Equal(t, "`it``s`", mysql.quoteIdent("it`s"))
+}
+
+func TestSQLite_IdentifierQuote(t *testing.T) {
+	ctx := context.Background()
+	db := openSQLite(t)
+
+	// SQLite accepts the backquotes used by MySQL as well as double quotes
+	s, err := Table(ctx, db, sqliteTable, WithPageSize(3), WithKeyColumn("item id"),
+		WithIdentifierQuote('`'))
+	require.NoError(t, err)
+	assert.Equal(t, sheets.Dimensions{EndRow: 10, EndCol: 6}, s.Dimensions())
+
+	r, err := sheets.ParseRange("A2:B11")
+	require.NoError(t, err)
+
+	vr, err := s.Range(ctx, r)
+	require.NoError(t, err)
+
+	var values []sheets.Value
+	for vr.Next(ctx) {
+		values = append(values, vr.Value())
+	}
+
+	require.NoError(t, vr.Err())
+	require.Len(t, values, 20)
+	assert.Equal(t, []sheets.Value{sheets.Float64Value(1), sheets.Float64Value(10.25)}, values[:2])
+	assert.Equal(t, []sheets.Value{sheets.Float64Value(10), sheets.Float64Value(7.25)}, values[18:])
+}
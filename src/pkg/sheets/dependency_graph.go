@@ -0,0 +1,422 @@
+package sheets
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// A CellAddress is the address of a cell in a named sheet.
+type CellAddress struct {
+	Sheet string
+	Pos   Pos
+}
+
+// String returns the string form of the address.
+func (a CellAddress) String() string {
+	if a.Sheet != "" {
+		return fmt.Sprintf("`%s`!%s", a.Sheet, a.Pos)
+	}
+
+	return a.Pos.String()
+}
+
+// A RangeAddress is the address of a range of cells in a named sheet.
+type RangeAddress struct {
+	Sheet string
+	Range Range
+}
+
+// String returns the string form of the address.
+func (a RangeAddress) String() string {
+	r := a.Range.String()
+	if a.Range.NumCells() == 1 {
+		r = a.Range.StartPos().String()
+	}
+
+	if a.Sheet != "" {
+		return fmt.Sprintf("`%s`!%s", a.Sheet, r)
+	}
+
+	return r
+}
+
+// Contains returns true if the range contains the given cell. Open-ended
+// ranges (such as "A:A") contain every cell in their rows or columns.
+func (a RangeAddress) Contains(addr CellAddress) bool {
+	if a.Sheet != addr.Sheet {
+		return false
+	}
+
+	r, pos := a.Range, addr.Pos
+	return pos.Row >= r.StartRow && (r.EndRow == MaxRow || pos.Row <= r.EndRow) &&
+		pos.Col >= r.StartCol && (r.EndCol == MaxColumn || pos.Col <= r.EndCol)
+}
+
+// Overlaps returns true if the two ranges have any cells in common.
+func (a RangeAddress) Overlaps(other RangeAddress) bool {
+	if a.Sheet != other.Sheet {
+		return false
+	}
+
+	overlaps := func(start1, end1, start2, end2 int) bool {
+		return (end1 == MaxRow || start2 <= end1) && (end2 == MaxRow || start1 <= end2)
+	}
+
+	r1, r2 := a.Range, other.Range
+	return overlaps(r1.StartRow, r1.EndRow, r2.StartRow, r2.EndRow) &&
+		overlaps(r1.StartCol, r1.EndCol, r2.StartCol, r2.EndCol)
+}
+
+// A dependencyGraph tracks the cells and ranges that each formula cell
+// depends on (its precedents), and allows the formula cells that depend on a
+// given cell (its dependents) to be found.
+type dependencyGraph struct {
+	precedents map[CellAddress][]RangeAddress
+
+	// cellDependents indexes precedents that are single cells.
+	cellDependents map[CellAddress]map[CellAddress]struct{}
+
+	// rangeDependents indexes precedents that are multi-cell ranges, by sheet.
+	// rangeEntries holds the entries for each formula cell, so that they can
+	// be removed.
+	rangeDependents map[string]*rangeIndex
+	rangeEntries    map[CellAddress][]*rangeEntry
+	nextEntryID     uint64
+
+	// names and nameDependents track the named ranges that each formula cell
+	// refers to, whether or not the names are defined.
+	names          map[CellAddress][]string
+	nameDependents map[string]map[CellAddress]struct{}
+}
+
+func newDependencyGraph() *dependencyGraph {
+	return &dependencyGraph{
+		precedents:      make(map[CellAddress][]RangeAddress),
+		cellDependents:  make(map[CellAddress]map[CellAddress]struct{}),
+		rangeDependents: make(map[string]*rangeIndex),
+		rangeEntries:    make(map[CellAddress][]*rangeEntry),
+		names:           make(map[CellAddress][]string),
+		nameDependents:  make(map[string]map[CellAddress]struct{}),
+	}
+}
+
+// set sets the precedents of a formula cell, along with the names of the named
+// ranges it refers to, replacing any existing precedents.
+func (g *dependencyGraph) set(addr CellAddress, precedents []RangeAddress, names []string) {
+	g.remove(addr)
+
+	if len(names) != 0 {
+		g.names[addr] = names
+		for _, name := range names {
+			if g.nameDependents[name] == nil {
+				g.nameDependents[name] = make(map[CellAddress]struct{})
+			}
+
+			g.nameDependents[name][addr] = struct{}{}
+		}
+	}
+
+	if len(precedents) == 0 {
+		return
+	}
+
+	g.precedents[addr] = precedents
+	for _, precedent := range precedents {
+		if precedent.Range.NumCells() == 1 {
+			cell := CellAddress{Sheet: precedent.Sheet, Pos: precedent.Range.StartPos()}
+			if g.cellDependents[cell] == nil {
+				g.cellDependents[cell] = make(map[CellAddress]struct{})
+			}
+
+			g.cellDependents[cell][addr] = struct{}{}
+			continue
+		}
+
+		index := g.rangeDependents[precedent.Sheet]
+		if index == nil {
+			index = &rangeIndex{columns: make(map[int]*intervalNode)}
+			g.rangeDependents[precedent.Sheet] = index
+		}
+
+		g.nextEntryID++
+		entry := &rangeEntry{id: g.nextEntryID, precedent: precedent, dependent: addr}
+		index.insert(entry)
+		g.rangeEntries[addr] = append(g.rangeEntries[addr], entry)
+	}
+}
+
+// remove removes a formula cell from the graph.
+func (g *dependencyGraph) remove(addr CellAddress) {
+	for _, precedent := range g.precedents[addr] {
+		if precedent.Range.NumCells() == 1 {
+			cell := CellAddress{Sheet: precedent.Sheet, Pos: precedent.Range.StartPos()}
+			delete(g.cellDependents[cell], addr)
+			if len(g.cellDependents[cell]) == 0 {
+				delete(g.cellDependents, cell)
+			}
+		}
+	}
+
+	for _, entry := range g.rangeEntries[addr] {
+		g.rangeDependents[entry.precedent.Sheet].remove(entry)
+	}
+
+	for _, name := range g.names[addr] {
+		delete(g.nameDependents[name], addr)
+		if len(g.nameDependents[name]) == 0 {
+			delete(g.nameDependents, name)
+		}
+	}
+
+	delete(g.precedents, addr)
+	delete(g.rangeEntries, addr)
+	delete(g.names, addr)
+}
+
+// precedentsOf returns the direct precedents of a formula cell.
+func (g *dependencyGraph) precedentsOf(addr CellAddress) []RangeAddress {
+	return g.precedents[addr]
+}
+
+// dependentsOf returns the formula cells that directly depend on a cell,
+// sorted by sheet and position.
+func (g *dependencyGraph) dependentsOf(addr CellAddress) []CellAddress {
+	var dependents []CellAddress
+	for dependent := range g.cellDependents[addr] {
+		dependents = append(dependents, dependent)
+	}
+
+	if index := g.rangeDependents[addr.Sheet]; index != nil {
+		seen := make(map[CellAddress]struct{})
+		index.find(addr.Pos, func(entry *rangeEntry) {
+			if _, ok := g.cellDependents[addr][entry.dependent]; ok {
+				return
+			}
+
+			if _, ok := seen[entry.dependent]; !ok {
+				seen[entry.dependent] = struct{}{}
+				dependents = append(dependents, entry.dependent)
+			}
+		})
+	}
+
+	sortCellAddresses(dependents)
+	return dependents
+}
+
+// nameDependentsOf returns the formula cells that refer to a named range.
+func (g *dependencyGraph) nameDependentsOf(name string) []CellAddress {
+	dependents := make([]CellAddress, 0, len(g.nameDependents[name]))
+	for dependent := range g.nameDependents[name] {
+		dependents = append(dependents, dependent)
+	}
+
+	sortCellAddresses(dependents)
+	return dependents
+}
+
+func sortCellAddresses(addrs []CellAddress) {
+	sort.Slice(addrs, func(i, j int) bool {
+		a, b := addrs[i], addrs[j]
+		if a.Sheet != b.Sheet {
+			return a.Sheet < b.Sheet
+		}
+
+		if a.Pos.Row != b.Pos.Row {
+			return a.Pos.Row < b.Pos.Row
+		}
+
+		return a.Pos.Col < b.Pos.Col
+	})
+}
+
+// A rangeEntry records that a formula cell depends on a multi-cell range.
+type rangeEntry struct {
+	id        uint64
+	precedent RangeAddress
+	dependent CellAddress
+}
+
+// maxIndexedColumns is the widest range that a rangeIndex indexes under each
+// of its columns. Wider ranges are indexed by their rows alone.
+const maxIndexedColumns = 8
+
+// A rangeIndex finds the ranges in a sheet that contain a given cell. Ranges
+// are indexed by their row interval, under each of the columns they cover;
+// ranges that are wider than maxIndexedColumns, or that are open-ended, are
+// indexed by their row interval alone and checked against the column of the
+// cell when found.
+type rangeIndex struct {
+	columns map[int]*intervalNode
+	wide    *intervalNode
+}
+
+func (idx *rangeIndex) insert(entry *rangeEntry) {
+	r := entry.precedent.Range
+	if r.EndCol == MaxColumn || r.EndCol-r.StartCol >= maxIndexedColumns {
+		idx.wide = idx.wide.insert(entry)
+		return
+	}
+
+	for col := r.StartCol; col <= r.EndCol; col++ {
+		idx.columns[col] = idx.columns[col].insert(entry)
+	}
+}
+
+func (idx *rangeIndex) remove(entry *rangeEntry) {
+	r := entry.precedent.Range
+	if r.EndCol == MaxColumn || r.EndCol-r.StartCol >= maxIndexedColumns {
+		idx.wide = idx.wide.remove(entry)
+		return
+	}
+
+	for col := r.StartCol; col <= r.EndCol; col++ {
+		if idx.columns[col] = idx.columns[col].remove(entry); idx.columns[col] == nil {
+			delete(idx.columns, col)
+		}
+	}
+}
+
+// find calls fn with each of the entries whose range contains the given position.
+func (idx *rangeIndex) find(pos Pos, fn func(entry *rangeEntry)) {
+	idx.columns[pos.Col].find(pos.Row, fn)
+	idx.wide.find(pos.Row, func(entry *rangeEntry) {
+		r := entry.precedent.Range
+		if pos.Col >= r.StartCol && (r.EndCol == MaxColumn || pos.Col <= r.EndCol) {
+			fn(entry)
+		}
+	})
+}
+
+// An intervalNode is a node in an interval tree of range entries, keyed by
+// the rows the ranges cover. The tree is a treap ordered by the first row and
+// id of each entry, with each node recording the last row covered by any
+// entry in its subtree, so that the entries covering a row can be found
+// without visiting the subtrees that end before it.
+type intervalNode struct {
+	entry       *rangeEntry
+	start, end  int
+	maxEnd      int
+	priority    uint64
+	left, right *intervalNode
+}
+
+func newIntervalNode(entry *rangeEntry) *intervalNode {
+	r := entry.precedent.Range
+	end := r.EndRow
+	if end == MaxRow {
+		end = math.MaxInt
+	}
+
+	// Derive the priority from the id, so that the shape of the tree does not
+	// depend on the order entries are inserted
+	priority := entry.id * 0x9e3779b97f4a7c15
+	priority ^= priority >> 31
+
+	return &intervalNode{entry: entry, start: r.StartRow, end: end, maxEnd: end, priority: priority}
+}
+
+// less returns true if the node sorts before the given entry.
+func (n *intervalNode) less(start int, id uint64) bool {
+	return n.start < start || (n.start == start && n.entry.id < id)
+}
+
+// update recomputes the last row covered by the subtree rooted at the node.
+func (n *intervalNode) update() {
+	n.maxEnd = n.end
+	if n.left != nil && n.left.maxEnd > n.maxEnd {
+		n.maxEnd = n.left.maxEnd
+	}
+
+	if n.right != nil && n.right.maxEnd > n.maxEnd {
+		n.maxEnd = n.right.maxEnd
+	}
+}
+
+// insert adds an entry to the subtree rooted at the node, returning the new
+// root of the subtree.
+func (n *intervalNode) insert(entry *rangeEntry) *intervalNode {
+	if n == nil {
+		return newIntervalNode(entry)
+	}
+
+	if n.less(entry.precedent.Range.StartRow, entry.id) {
+		n.right = n.right.insert(entry)
+		if n.right.priority > n.priority {
+			// Rotate left
+			root := n.right
+			n.right, root.left = root.left, n
+			n.update()
+			n = root
+		}
+	} else {
+		n.left = n.left.insert(entry)
+		if n.left.priority > n.priority {
+			// Rotate right
+			root := n.left
+			n.left, root.right = root.right, n
+			n.update()
+			n = root
+		}
+	}
+
+	n.update()
+	return n
+}
+
+// remove removes an entry from the subtree rooted at the node, returning the
+// new root of the subtree.
+func (n *intervalNode) remove(entry *rangeEntry) *intervalNode {
+	switch {
+	case n == nil:
+		return nil
+	case n.entry == entry:
+		return mergeIntervalNodes(n.left, n.right)
+	case n.less(entry.precedent.Range.StartRow, entry.id):
+		n.right = n.right.remove(entry)
+	default:
+		n.left = n.left.remove(entry)
+	}
+
+	n.update()
+	return n
+}
+
+// mergeIntervalNodes merges two subtrees, all of whose entries in left sort
+// before those in right.
+func mergeIntervalNodes(left, right *intervalNode) *intervalNode {
+	switch {
+	case left == nil:
+		return right
+	case right == nil:
+		return left
+	case left.priority > right.priority:
+		left.right = mergeIntervalNodes(left.right, right)
+		left.update()
+		return left
+	default:
+		right.left = mergeIntervalNodes(left, right.left)
+		right.update()
+		return right
+	}
+}
+
+// find calls fn with each of the entries in the subtree that cover the given row.
+func (n *intervalNode) find(row int, fn func(entry *rangeEntry)) {
+	if n == nil || n.maxEnd < row {
+		return
+	}
+
+	n.left.find(row, fn)
+	if n.start > row {
+		// Neither this entry nor any to its right start early enough
+		return
+	}
+
+	if row <= n.end {
+		fn(n.entry)
+	}
+
+	n.right.find(row, fn)
+}
@@ -0,0 +1,104 @@
+package sheets
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDependencyGraph_RangeDependents(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	randomRange := func() Range {
+		r := Range{StartRow: rnd.Intn(50), StartCol: rnd.Intn(20)}
+		r.EndRow, r.EndCol = r.StartRow+rnd.Intn(20), r.StartCol+rnd.Intn(12)
+		switch rnd.Intn(8) {
+		case 0:
+			r.EndRow = MaxRow
+		case 1:
+			r.EndCol = MaxColumn
+		}
+
+		return r
+	}
+
+	g := newDependencyGraph()
+	precedents := map[CellAddress][]RangeAddress{}
+	for i := 0; i < 500; i++ {
+		addr := CellAddress{Sheet: "Sheet1", Pos: Pos{Row: rnd.Intn(100), Col: 30 + rnd.Intn(5)}}
+		if rnd.Intn(4) == 0 {
+			g.remove(addr)
+			delete(precedents, addr)
+			continue
+		}
+
+		var ranges []RangeAddress
+		for j := rnd.Intn(3); j >= 0; j-- {
+			ranges = append(ranges, RangeAddress{Sheet: []string{"Sheet1", "Sheet2"}[rnd.Intn(2)], Range: randomRange()})
+		}
+
+		g.set(addr, ranges, nil)
+		precedents[addr] = ranges
+	}
+
+	for _, sheet := range []string{"Sheet1", "Sheet2"} {
+		for row := 0; row < 80; row++ {
+			for col := 0; col < 40; col++ {
+				cell := CellAddress{Sheet: sheet, Pos: Pos{Row: row, Col: col}}
+
+				var expected []CellAddress
+				for dependent, ranges := range precedents {
+					for _, r := range ranges {
+						if r.Contains(cell) {
+							expected = append(expected, dependent)
+							break
+						}
+					}
+				}
+
+				sortCellAddresses(expected)
+				assert.Equal(t, expected, g.dependentsOf(cell), "%s", cell)
+			}
+		}
+	}
+}
+
+func TestDependencyGraph_NameDependents(t *testing.T) {
+	a1, b1 := CellAddress{Pos: Pos{Row: 0, Col: 0}}, CellAddress{Pos: Pos{Row: 0, Col: 1}}
+
+	g := newDependencyGraph()
+	g.set(b1, nil, []string{"Rates"})
+	g.set(a1, nil, []string{"Rates", "Totals"})
+	assert.Equal(t, []CellAddress{a1, b1}, g.nameDependentsOf("Rates"))
+	assert.Equal(t, []CellAddress{a1}, g.nameDependentsOf("Totals"))
+
+	g.set(a1, nil, []string{"Totals"})
+	assert.Equal(t, []CellAddress{b1}, g.nameDependentsOf("Rates"))
+
+	g.remove(b1)
+	assert.Empty(t, g.nameDependentsOf("Rates"))
+}
+
+func TestRangeAddress_Overlaps(t *testing.T) {
+	for _, tt := range []struct {
+		r1, r2   string
+		expected bool
+	}{
+		{"A1:B2", "B2:C3", true},
+		{"A1:B2", "C1:D2", false},
+		{"A1:B2", "A3:B4", false},
+		{"A:A", "A100:A200", true},
+		{"A:A", "B1:B2", false},
+		{"3:3", "A3:Z3", true},
+		{"3:3", "A1:Z2", false},
+		{"C5:E", "D100:D101", true},
+	} {
+		r1 := RangeAddress{Range: mustParseRange(t, tt.r1)}
+		r2 := RangeAddress{Range: mustParseRange(t, tt.r2)}
+		assert.Equal(t, tt.expected, r1.Overlaps(r2), "%s %s", tt.r1, tt.r2)
+		assert.Equal(t, tt.expected, r2.Overlaps(r1), "%s %s", tt.r2, tt.r1)
+	}
+
+	assert.False(t, RangeAddress{Sheet: "Sheet1", Range: mustParseRange(t, "A1:B2")}.Overlaps(
+		RangeAddress{Sheet: "Sheet2", Range: mustParseRange(t, "A1:B2")}))
+}
@@ -3,6 +3,7 @@ package sheets
 import (
 	"fmt"
 	"math"
+	"strings"
 	"time"
 )
 
@@ -18,38 +19,184 @@ const (
 
 var (
 	epochStart = time.Date(1899, time.December, 30, 0, 0, 0, 0, time.UTC)
+	epoch1904  = time.Date(1904, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	// leapBugEnd is the first day after the phantom 1900-02-29.
+	leapBugEnd = time.Date(1900, time.March, 1, 0, 0, 0, 0, time.UTC)
 )
 
-// ParseTime parses a string as a time, using the support date and time formats.
+// ParseTime parses a string as a time, using the DefaultTimeParser. Times
+// without an explicit offset are in UTC.
 func ParseTime(s string) (time.Time, error) {
-	for _, layout := range supportedTimeLayouts {
-		if t, err := time.Parse(layout, s); err == nil {
-			return t, nil
+	return DefaultTimeParser.Parse(s)
+}
+
+// ParseTimeIn parses a string as a time, using the DefaultTimeParser. Times
+// with an explicit offset keep that offset, while times without one are
+// wall-clock times in the given location.
+func ParseTimeIn(s string, loc *time.Location) (time.Time, error) {
+	return DefaultTimeParser.ParseIn(s, loc)
+}
+
+// LoadLocation returns the time zone with the given IANA name, such as
+// "America/New_York" or "UTC", or with a fixed offset from UTC written as
+// "+05:30" or "-0800". The local time zone of the machine is not accepted, so
+// that formulas give the same results wherever they are computed.
+func LoadLocation(name string) (*time.Location, error) {
+	for _, layout := range []string{"-07:00", "-0700", "-07"} {
+		if t, err := time.Parse(layout, name); err == nil {
+			_, offset := t.Zone()
+			return time.FixedZone(name, offset), nil
 		}
 	}
 
-	return time.Time{}, fmt.Errorf("'%s' cannot be parsed as a date or time", s)
+	if name == "" || strings.EqualFold(name, "Local") {
+		return nil, ValueErrorf("unknown time zone '%s'", name)
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, ValueErrorf("unknown time zone '%s'", name)
+	}
+
+	return loc, nil
 }
 
-// FromExcelTime converts an Excel fractional datetime to the appropriate golang time.Time.
+// A DateSystem determines which dates Excel serial numbers represent.
+type DateSystem int
+
+// Various DateSystems
+const (
+	// Date1900 is the date system used by Windows versions of Excel, and is
+	// the default. Serial 1 is 1900-01-01. For compatibility with Lotus 1-2-3,
+	// serial 60 is the non-existent 1900-02-29, so serials 61 onwards are days
+	// since 1899-12-30. Serial 0 is displayed by Excel as 1900-01-00.
+	Date1900 DateSystem = iota
+
+	// Date1904 is the date system used by workbooks created with older Mac
+	// versions of Excel. Serial 0 is 1904-01-01.
+	Date1904
+)
+
+// String returns the name of the date system.
+func (ds DateSystem) String() string {
+	switch ds {
+	case Date1900:
+		return "1900"
+	case Date1904:
+		return "1904"
+	default:
+		return fmt.Sprintf("DateSystem(%d)", int(ds))
+	}
+}
+
+// FromExcelTime converts an Excel fractional datetime in the Date1900 system
+// to the appropriate golang time.Time, treating it as a UTC wall-clock time.
 func FromExcelTime(n float64) time.Time {
+	return Date1900.FromExcelTime(n)
+}
+
+// ToExcelTime converts a golang time.Time to the Excel fractional date
+// equivalent in the Date1900 system, using its UTC wall-clock time.
+func ToExcelTime(tm time.Time) float64 {
+	return Date1900.ToExcelTime(tm)
+}
+
+// FromExcelTime converts an Excel fractional datetime to the appropriate
+// golang time.Time, treating it as a UTC wall-clock time. In the Date1900
+// system, the phantom 1900-02-29 (serial 60) cannot be represented by a
+// time.Time, and is returned as 1900-03-01.
+func (ds DateSystem) FromExcelTime(n float64) time.Time {
+	return ds.FromExcelTimeIn(n, time.UTC)
+}
+
+// FromExcelTimeIn converts an Excel fractional datetime to the appropriate
+// golang time.Time, treating it as a wall-clock time in the given location.
+// Wall-clock times skipped by a daylight saving transition are normalized in
+// the same way as time.Date.
+func (ds DateSystem) FromExcelTimeIn(n float64, loc *time.Location) time.Time {
 	datePart := float64(int64(n))
-	day, month, year := fromExcelDate(datePart)
+	day, month, year := ds.fromExcelDate(datePart)
 
 	timePart := n - datePart + roundEpsilon
 	hour, minute, second, nano := fromExcelTimeOfDay(timePart)
-	return time.Date(year, month, day, hour, minute, second, nano, time.UTC).Truncate(time.Second)
+	return time.Date(year, month, day, hour, minute, second, nano, loc).Truncate(time.Second)
+}
+
+// ToExcelTime converts a golang time.Time to the Excel fractional date
+// equivalent, using its UTC wall-clock time.
+func (ds DateSystem) ToExcelTime(tm time.Time) float64 {
+	return ds.ToExcelTimeIn(tm, time.UTC)
+}
+
+// ToExcelTimeIn converts a golang time.Time to the Excel fractional date
+// equivalent, using its wall-clock time in the given location.
+func (ds DateSystem) ToExcelTimeIn(tm time.Time, loc *time.Location) float64 {
+	tm = tm.In(loc)
+
+	var (
+		dayFraction  = ds.toExcelDate(tm.Day(), tm.Month(), tm.Year())
+		timeFraction = toExcelTimeOfDay(tm.Hour(), tm.Minute(), tm.Second(), tm.Nanosecond())
+	)
+
+	return roundFloat(dayFraction+timeFraction, 15)
 }
 
 // fromExcelDate converts a fraction whose integer portion is an Excel numeric
-// date into the appropriate day, month, and year. Excel represents dates
-// as the number of days since Dec 31, 1899 (where Dec 31, 1899 is the value 1).
-func fromExcelDate(fraction float64) (day int, month time.Month, year int) {
-	diffSinceEpoch := time.Duration(int64(fraction) * daysInNanos)
-	date := epochStart.Add(diffSinceEpoch)
+// date into the appropriate day, month, and year. Serial 60 in the Date1900
+// system is returned as February 29, 1900.
+func (ds DateSystem) fromExcelDate(fraction float64) (day int, month time.Month, year int) {
+	days := int(fraction)
+	epoch := epochStart
+	switch {
+	case ds == Date1904:
+		epoch = epoch1904
+	case days == 60:
+		return 29, time.February, 1900
+	case days < 60:
+		// Serials before the phantom leap day are one day later than they
+		// would otherwise be
+		epoch = epoch.AddDate(0, 0, 1)
+	}
+
+	date := epoch.AddDate(0, 0, days)
 	return date.Day(), date.Month(), date.Year()
 }
 
+// toExcelDate converts a (day, month, year) into the internal numeric format
+// used by Excel to store dates.
+func (ds DateSystem) toExcelDate(day int, month time.Month, year int) float64 {
+	date := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	epoch := epochStart
+	switch {
+	case ds == Date1904:
+		epoch = epoch1904
+	case date.Before(leapBugEnd):
+		epoch = epoch.AddDate(0, 0, 1)
+	}
+
+	return float64((date.Unix() - epoch.Unix()) / int64(24*time.Hour/time.Second))
+}
+
+// excelWeekday returns the day of the week Excel gives a serial date. In the
+// Date1900 system, Excel's days of the week are wrong before 1900-03-01, since
+// they count the phantom leap day.
+// maxExcelDate returns the serial number of the last date Excel can
+// represent, 9999-12-31.
+func (ds DateSystem) maxExcelDate() float64 {
+	return ds.toExcelDate(31, time.December, 9999)
+}
+
+func (ds DateSystem) excelWeekday(serial float64) time.Weekday {
+	if ds == Date1904 {
+		day, month, year := ds.fromExcelDate(serial)
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC).Weekday()
+	}
+
+	// Serial 1 is a Sunday
+	return time.Weekday(((int(serial)-1)%7 + 7) % 7)
+}
+
 // fromExcelTimeOfDay whose decimal portion is an Excel numeric time into the appropriate hour,
 // minute, second, and nanosecond. Excel represents times as a percentage of the day.
 func fromExcelTimeOfDay(fraction float64) (hour, minute, second, nanosecond int) {
@@ -63,26 +210,6 @@ func fromExcelTimeOfDay(fraction float64) (hour, minute, second, nanosecond int)
 	return
 }
 
-// ToExcelTime converts a golang time.Time to the Excel fractional date equivalent.
-func ToExcelTime(tm time.Time) float64 {
-	tm = tm.UTC()
-
-	var (
-		dayFraction  = toExcelDate(tm.Day(), tm.Month(), tm.Year())
-		timeFraction = toExcelTimeOfDay(tm.Hour(), tm.Minute(), tm.Second(), tm.Nanosecond())
-	)
-
-	return roundFloat(dayFraction+timeFraction, 15)
-}
-
-// toExcelDate converts a (day, month, year) into the internal numeric format
-// used by Excel to store dates.
-func toExcelDate(day int, month time.Month, year int) float64 {
-	date := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
-	diffSinceEpoch := int64(date.Sub(epochStart))
-	return float64(diffSinceEpoch / daysInNanos)
-}
-
 // toExcelTimeOfDay converts the given (hour, minute, second, nanosecond) to a fraction
 // whose decimal value is the percentage of the day at that timestamp.
 func toExcelTimeOfDay(hour, minute, second, nanosecond int) float64 {
@@ -96,17 +223,3 @@ func roundFloat(val float64, precision uint) float64 {
 	ratio := math.Pow(10, float64(precision))
 	return math.Round(val*ratio) / ratio
 }
-
-var (
-	supportedTimeLayouts = []string{
-		time.RFC3339,
-		time.DateOnly,
-		time.DateTime,
-		time.UnixDate,
-		time.Kitchen,
-		"2006/01/02",
-		"01/02/2006",
-		"01/02/06",
-		"01/06",
-	}
-)
@@ -6,6 +6,7 @@ import (
 
 	"github.com/mmihic/golib/src/pkg/timex"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 const (
@@ -40,24 +41,139 @@ func TestDate(t *testing.T) {
 		fraction float64
 		expected time.Time
 	}{
-		{1.00000000000000, timex.MustParseTime(rfc3339Milli, "1899-12-31T12:34:56.987Z")},
-		{2.00000000000000, timex.MustParseTime(rfc3339Milli, "1900-01-01T12:34:56.987Z")},
+		{1.00000000000000, timex.MustParseTime(rfc3339Milli, "1900-01-01T12:34:56.987Z")},
+		{2.00000000000000, timex.MustParseTime(rfc3339Milli, "1900-01-02T12:34:56.987Z")},
+		{59.00000000000000, timex.MustParseTime(rfc3339Milli, "1900-02-28T12:34:56.987Z")},
 		{61.00000000000000, timex.MustParseTime(rfc3339Milli, "1900-03-01T12:34:56.987Z")},
-		{60.00000000000000, timex.MustParseTime(rfc3339Milli, "1900-02-28T12:34:56.987Z")},
 		{41_994.00000000000000, timex.MustParseTime(rfc3339Milli, "2014-12-21T12:34:56.987Z")},
 		{45_401.00000000000000, timex.MustParseTime(rfc3339Milli, "2024-04-19T12:34:56.987Z")},
 	} {
 		t.Run(tt.expected.String(), func(t *testing.T) {
-			day, month, year := fromExcelDate(tt.fraction)
+			day, month, year := Date1900.fromExcelDate(tt.fraction)
 			assert.Equal(t, tt.expected, time.Date(year, month, day,
 				12, 34, 56, 987000000, time.UTC))
 
-			fraction := toExcelDate(day, month, year)
+			fraction := Date1900.toExcelDate(day, month, year)
 			assert.Equal(t, tt.fraction, fraction)
 		})
 	}
 }
 
+func TestDate_PhantomLeapDay(t *testing.T) {
+	day, month, year := Date1900.fromExcelDate(60)
+	assert.Equal(t, 29, day)
+	assert.Equal(t, time.February, month)
+	assert.Equal(t, 1900, year)
+
+	// Excel counts the phantom day when finding the day of the week, so
+	// 1900-01-01 is a Sunday, while 1900-03-01 is correctly a Thursday
+	assert.Equal(t, time.Sunday, Date1900.excelWeekday(1))
+	assert.Equal(t, time.Wednesday, Date1900.excelWeekday(60))
+	assert.Equal(t, time.Thursday, Date1900.excelWeekday(61))
+}
+
+func TestDate1904(t *testing.T) {
+	for _, tt := range []struct {
+		fraction float64
+		expected time.Time
+	}{
+		{0.00000000000000, timex.MustParseTime(rfc3339Milli, "1904-01-01T00:00:00.00Z")},
+		{1.50000000000000, timex.MustParseTime(rfc3339Milli, "1904-01-02T12:00:00.00Z")},
+		{40_532.523784722230000, timex.MustParseTime(rfc3339Milli, "2014-12-21T12:34:15.00Z")},
+		{43_939.259826388888889, timex.MustParseTime(rfc3339Milli, "2024-04-19T06:14:09.00Z")},
+	} {
+		t.Run(tt.expected.String(), func(t *testing.T) {
+			tm := Date1904.FromExcelTime(tt.fraction)
+			if !assert.Equal(t, tt.expected, tm) {
+				return
+			}
+
+			assert.Equal(t, tt.fraction, Date1904.ToExcelTime(tm))
+			assert.Equal(t, tt.expected.Weekday(), Date1904.excelWeekday(tt.fraction))
+		})
+	}
+
+	// Serials in the two systems are 1462 days apart
+	tm := time.Date(2024, time.March, 5, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, Date1900.ToExcelTime(tm)-1462, Date1904.ToExcelTime(tm))
+}
+
+func TestToFromTimeIn(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+
+	// Serials are wall-clock times in the location, so the same instant has
+	// a different serial in each time zone
+	tm := time.Date(2024, time.March, 5, 2, 30, 0, 0, time.UTC)
+	assert.Equal(t, 45356.104166666667, Date1900.ToExcelTime(tm))
+	assert.Equal(t, 45355.895833333333, Date1900.ToExcelTimeIn(tm, newYork))
+	assert.True(t, tm.Equal(Date1900.FromExcelTimeIn(45355.895833333333, newYork)))
+	assert.Equal(t, newYork, Date1900.FromExcelTimeIn(45355.895833333333, newYork).Location())
+
+	// Daylight saving time changes the offset, but not the wall-clock time
+	summer := time.Date(2024, time.July, 1, 12, 0, 0, 0, newYork)
+	assert.Equal(t, 45474.5, Date1900.ToExcelTimeIn(summer, newYork))
+	assert.Equal(t, 45474.6666666666667, Date1900.ToExcelTime(summer))
+}
+
+func TestParseTimeIn(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	require.NoError(t, err)
+
+	for _, tt := range []struct {
+		input    string
+		expected time.Time
+	}{
+		{"2024-03-05", time.Date(2024, time.March, 5, 0, 0, 0, 0, tokyo)},
+		{"2024-03-05 23:30:00", time.Date(2024, time.March, 5, 23, 30, 0, 0, tokyo)},
+		{"2024-03-05T23:30:00", time.Date(2024, time.March, 5, 23, 30, 0, 0, tokyo)},
+		{"2024-03-05T23:30:00Z", time.Date(2024, time.March, 5, 23, 30, 0, 0, time.UTC)},
+		{"2024-03-05T23:30:00-05:00", time.Date(2024, time.March, 6, 4, 30, 0, 0, time.UTC)},
+		{"2024-03-05 23:30:00+01:00", time.Date(2024, time.March, 5, 22, 30, 0, 0, time.UTC)},
+		{"2024-03-05 23:30:00 -0800", time.Date(2024, time.March, 6, 7, 30, 0, 0, time.UTC)},
+	} {
+		t.Run(tt.input, func(t *testing.T) {
+			tm, err := ParseTimeIn(tt.input, tokyo)
+			require.NoError(t, err)
+			assert.True(t, tt.expected.Equal(tm), "expected %s, got %s", tt.expected, tm)
+		})
+	}
+
+	// ParseTime uses UTC for times without an offset
+	tm, err := ParseTime("2024-03-05 23:30:00")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, time.March, 5, 23, 30, 0, 0, time.UTC), tm)
+}
+
+func TestLoadLocation(t *testing.T) {
+	for _, tt := range []struct {
+		name        string
+		offset      int
+		expectedErr string
+	}{
+		{"UTC", 0, ""},
+		{"Asia/Kolkata", 5*3600 + 1800, ""},
+		{"+05:30", 5*3600 + 1800, ""},
+		{"-0800", -8 * 3600, ""},
+		{"+02", 2 * 3600, ""},
+		{"Local", 0, "unknown time zone 'Local'"},
+		{"", 0, "unknown time zone ''"},
+		{"Mars/Olympus", 0, "unknown time zone 'Mars/Olympus'"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			loc, err := LoadLocation(tt.name)
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+				return
+			}
+
+			require.NoError(t, err)
+			_, offset := time.Date(2024, time.January, 1, 0, 0, 0, 0, loc).Zone()
+			assert.Equal(t, tt.offset, offset)
+		})
+	}
+}
+
 func TestTimeOfDay(t *testing.T) {
 	for _, tt := range []struct {
 		fraction float64
@@ -0,0 +1,233 @@
+package sheets
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// A FixedWidthColumn describes where a column lies on each line of a
+// fixed-width file, in characters, and how its numbers are written.
+type FixedWidthColumn struct {
+	// Start is the offset of the first character of the column.
+	Start int
+
+	// Width is the number of characters in the column. If zero, the column
+	// extends to the end of the line.
+	Width int
+
+	// ImpliedDecimals is the number of digits of the column's numbers that
+	// follow an implied decimal point, so that 000012345 with 2 implied
+	// decimals is 123.45. Numbers written with a decimal point are read as
+	// written. Columns with implied decimals are always read as numbers.
+	ImpliedDecimals int
+
+	// Overpunch reads the last digit of the column's numbers as a signed
+	// overpunch, as written by COBOL for signed fields, where { and A to I are
+	// the digits 0 to 9 of a positive number and } and J to R are the digits
+	// of a negative number. Overpunched columns are always read as numbers.
+	Overpunch bool
+}
+
+func (c FixedWidthColumn) isNumber() bool {
+	return c.ImpliedDecimals > 0 || c.Overpunch
+}
+
+// A FixedWidthLayout describes the columns of a fixed-width file.
+type FixedWidthLayout struct {
+	// Columns are the columns of the file, in order. If empty, the columns
+	// are inferred from the ruler, if there is one, or the header.
+	Columns []FixedWidthColumn
+
+	// Header reads the first line as a header, which is the first row of the
+	// sheet.
+	Header bool
+
+	// Ruler skips the line following the header, or the first line if there
+	// is no header, which marks out the columns with runs of characters such
+	// as "-----".
+	Ruler bool
+}
+
+// InferFixedWidthColumns infers the columns of a fixed-width file from a
+// header or ruler line. Each run of characters other than spaces starts a
+// column, which extends to the start of the next. Since a header with names
+// containing spaces would be split at each space, prefer a ruler where there
+// is one.
+func InferFixedWidthColumns(line string) []FixedWidthColumn {
+	var columns []FixedWidthColumn
+	inRun := false
+	for i, r := range []rune(line) {
+		space := unicode.IsSpace(r)
+		if !space && !inRun {
+			if n := len(columns); n != 0 {
+				columns[n-1].Width = i - columns[n-1].Start
+			}
+
+			columns = append(columns, FixedWidthColumn{Start: i})
+		}
+
+		inRun = !space
+	}
+
+	return columns
+}
+
+// ReadFixedWidth reads a sheet from a fixed-width file with the given layout.
+// The text of each column is trimmed of spaces and then converted into a
+// Value in the same way as the fields of a CSV file (see ReadCSV), except for
+// columns with implied decimals or overpunched signs, which are read as
+// numbers. Blank lines are skipped, as are characters beyond the last column.
+func ReadFixedWidth(r io.Reader, layout FixedWidthLayout, opts ...SheetOption) (MutableSheet, error) {
+	var options sheetOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	var lines [][]rune
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		lines = append(lines, []rune(line))
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	headerRows := 0
+	if layout.Header {
+		headerRows = 1
+	}
+
+	columns := layout.Columns
+	if layout.Ruler && len(lines) > headerRows {
+		if columns == nil {
+			columns = InferFixedWidthColumns(string(lines[headerRows]))
+		}
+
+		lines = append(lines[:headerRows], lines[headerRows+1:]...)
+	}
+
+	if columns == nil && layout.Header && len(lines) != 0 {
+		columns = InferFixedWidthColumns(string(lines[0]))
+	}
+
+	if len(columns) == 0 && len(lines) != 0 {
+		return nil, errors.New("no columns in fixed-width layout: set Columns, Header or Ruler")
+	}
+
+	records := make([][]string, len(lines))
+	for i, line := range lines {
+		records[i] = make([]string, len(columns))
+		for j, col := range columns {
+			records[i][j] = strings.TrimSpace(fixedWidthField(line, col))
+		}
+	}
+
+	inferrer := options.inferrer
+	if inferrer == nil {
+		inferrer = &TypeInferrer{Locale: options.locale, Location: options.location}
+	}
+
+	values, err := inferrer.values(records, options.emptyAsText)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := headerRows; i < len(records); i++ {
+		for j, col := range columns {
+			if !col.isNumber() || records[i][j] == "" {
+				continue
+			}
+
+			n, err := parseFixedWidthNumber(records[i][j], col)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value at %s: %w", Pos{Row: i, Col: j}, err)
+			}
+
+			values[i][j] = Float64Value(n)
+		}
+	}
+
+	s, err := newInMemorySheet(values, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// fixedWidthField returns the characters of a line that lie within a column.
+func fixedWidthField(line []rune, col FixedWidthColumn) string {
+	if col.Start >= len(line) {
+		return ""
+	}
+
+	end := len(line)
+	if col.Width > 0 && col.Start+col.Width < end {
+		end = col.Start + col.Width
+	}
+
+	return string(line[col.Start:end])
+}
+
+// parseFixedWidthNumber parses a number that may have implied decimals, an
+// overpunched last digit, and a leading or trailing sign.
+func parseFixedWidthNumber(text string, col FixedWidthColumn) (float64, error) {
+	digits := text
+	negative := false
+	if rest, ok := strings.CutPrefix(digits, "-"); ok {
+		digits, negative = rest, true
+	} else if rest, ok := strings.CutSuffix(digits, "-"); ok {
+		digits, negative = rest, true
+	} else {
+		digits = strings.TrimSuffix(strings.TrimPrefix(digits, "+"), "+")
+	}
+
+	if col.Overpunch && digits != "" {
+		last := digits[len(digits)-1]
+		switch {
+		case last == '{':
+			digits = digits[:len(digits)-1] + "0"
+		case last == '}':
+			digits, negative = digits[:len(digits)-1]+"0", !negative
+		case 'A' <= last && last <= 'I':
+			digits = digits[:len(digits)-1] + string('1'+last-'A')
+		case 'J' <= last && last <= 'R':
+			digits, negative = digits[:len(digits)-1]+string('1'+last-'J'), !negative
+		}
+	}
+
+	if digits == "" || strings.Trim(digits, "0123456789.") != "" || strings.Count(digits, ".") > 1 {
+		return 0, fmt.Errorf("invalid number '%s'", text)
+	}
+
+	if !strings.Contains(digits, ".") && col.ImpliedDecimals > 0 {
+		if pad := col.ImpliedDecimals - len(digits); pad >= 0 {
+			digits = strings.Repeat("0", pad+1) + digits
+		}
+
+		split := len(digits) - col.ImpliedDecimals
+		digits = digits[:split] + "." + digits[split:]
+	}
+
+	n, err := strconv.ParseFloat(digits, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number '%s'", text)
+	}
+
+	if negative {
+		n = -n
+	}
+
+	return n, nil
+}
@@ -0,0 +1,135 @@
+package sheets
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const fixedWidthStatement = `ACCOUNT   OPENED      BALANCE   AMOUNT  NAME
+--------- ----------- --------- ------- ------------------
+000123    2024-03-05  000012345 00150{  Ann Smith
+
+000124    2024-03-06  000000050 00025J  Bob  Jones  
+000125                          12.5-   =C3
+`
+
+func TestReadFixedWidth_Columns(t *testing.T) {
+	s, err := ReadFixedWidth(strings.NewReader(fixedWidthStatement), FixedWidthLayout{
+		Columns: []FixedWidthColumn{
+			{Start: 0, Width: 10},
+			{Start: 10, Width: 12},
+			{Start: 22, Width: 10, ImpliedDecimals: 2},
+			{Start: 32, Width: 8, ImpliedDecimals: 2, Overpunch: true},
+			{Start: 40},
+		},
+		Header: true,
+		Ruler:  true,
+	}, WithTypeInferrer(&TypeInferrer{Strict: true, HeaderRows: 1}))
+	require.NoError(t, err)
+
+	assert.Equal(t, Dimensions{EndRow: 3, EndCol: 4}, s.Dimensions())
+	assertCells(t, s, map[string]Value{
+		"A1": StringValue("ACCOUNT"),
+		"C1": StringValue("BALANCE"),
+		"E1": StringValue("NAME"),
+		"A2": StringValue("000123"),
+		"C2": Float64Value(123.45),
+		"D2": Float64Value(15),
+		"E2": StringValue("Ann Smith"),
+		"C3": Float64Value(0.5),
+		"D3": Float64Value(-2.51),
+		"E3": StringValue("Bob  Jones"),
+		"B4": BlankValue{},
+		"C4": BlankValue{},
+		"D4": Float64Value(-12.5),
+		"E4": StringValue("=C3"),
+	})
+}
+
+func TestReadFixedWidth_InferredColumns(t *testing.T) {
+	// Columns are taken from the ruler, keeping names with spaces together
+	s, err := ReadFixedWidth(strings.NewReader(fixedWidthStatement), FixedWidthLayout{
+		Header: true,
+		Ruler:  true,
+	}, WithFormulas())
+	require.NoError(t, err)
+
+	assert.Equal(t, Dimensions{EndRow: 3, EndCol: 4}, s.Dimensions())
+	assertCells(t, s, map[string]Value{
+		"B1": StringValue("OPENED"),
+		"A2": Float64Value(123),
+		"C2": Float64Value(12345),
+		"D2": StringValue("00150{"),
+		"E3": StringValue("Bob  Jones"),
+		"E4": Float64Value(50),
+	})
+
+	// Columns are taken from the header
+	s, err = ReadFixedWidth(strings.NewReader("ID  NAME\n1   Ann\n22  Bob Jones\n"), FixedWidthLayout{Header: true})
+	require.NoError(t, err)
+	assertCells(t, s, map[string]Value{
+		"A1": StringValue("ID"),
+		"A3": Float64Value(22),
+		"B3": StringValue("Bob Jones"),
+	})
+}
+
+func TestReadFixedWidth_Invalid(t *testing.T) {
+	_, err := ReadFixedWidth(strings.NewReader("123\n"), FixedWidthLayout{})
+	assert.EqualError(t, err, "no columns in fixed-width layout: set Columns, Header or Ruler")
+
+	_, err = ReadFixedWidth(strings.NewReader("12X45\n"), FixedWidthLayout{
+		Columns: []FixedWidthColumn{{ImpliedDecimals: 2}},
+	})
+	assert.EqualError(t, err, "invalid value at A1: invalid number '12X45'")
+
+	s, err := ReadFixedWidth(strings.NewReader(""), FixedWidthLayout{})
+	require.NoError(t, err)
+	assert.Equal(t, Dimensions{EndRow: -1}, s.Dimensions())
+}
+
+func TestInferFixedWidthColumns(t *testing.T) {
+	assert.Equal(t, []FixedWidthColumn{
+		{Start: 0, Width: 4},
+		{Start: 4, Width: 7},
+		{Start: 11},
+	}, InferFixedWidthColumns("--- ------ --"))
+	assert.Equal(t, []FixedWidthColumn{
+		{Start: 2, Width: 4},
+		{Start: 6},
+	}, InferFixedWidthColumns("  ID  NAME  "))
+	assert.Empty(t, InferFixedWidthColumns("   "))
+}
+
+func TestParseFixedWidthNumber(t *testing.T) {
+	for _, tt := range []struct {
+		text     string
+		col      FixedWidthColumn
+		expected float64
+	}{
+		{"000012345", FixedWidthColumn{ImpliedDecimals: 2}, 123.45},
+		{"5", FixedWidthColumn{ImpliedDecimals: 3}, 0.005},
+		{"-12345", FixedWidthColumn{ImpliedDecimals: 2}, -123.45},
+		{"12345-", FixedWidthColumn{ImpliedDecimals: 2}, -123.45},
+		{"+1.5", FixedWidthColumn{ImpliedDecimals: 2}, 1.5},
+		{"1234{", FixedWidthColumn{Overpunch: true}, 12340},
+		{"1234A", FixedWidthColumn{Overpunch: true}, 12341},
+		{"1234I", FixedWidthColumn{Overpunch: true}, 12349},
+		{"1234}", FixedWidthColumn{Overpunch: true}, -12340},
+		{"1234J", FixedWidthColumn{Overpunch: true, ImpliedDecimals: 2}, -123.41},
+		{"1234R", FixedWidthColumn{Overpunch: true}, -12349},
+		{"12345", FixedWidthColumn{Overpunch: true}, 12345},
+	} {
+		n, err := parseFixedWidthNumber(tt.text, tt.col)
+		require.NoError(t, err, tt.text)
+		assert.InDelta(t, tt.expected, n, 1e-9, tt.text)
+	}
+
+	for _, text := range []string{"-", "1.2.3", "12S", "1 2"} {
+		_, err := parseFixedWidthNumber(text, FixedWidthColumn{Overpunch: true})
+		assert.Error(t, err, text)
+	}
+}
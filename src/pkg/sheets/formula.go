@@ -27,13 +27,61 @@ func (c *Constant) String() string {
 		return strconv.Quote(string(ct))
 	case Float64Value:
 		return strconv.FormatFloat(float64(ct), 'g', -1, 64)
+	case FormattedNumber:
+		return strconv.FormatFloat(ct.Number, 'g', -1, 64)
 	case TimeValue:
 		return fmt.Sprintf(`"%s"`, time.Time(ct).Format(time.RFC3339))
+	case ErrorValue:
+		if sheetErr, ok := UnwrapError(ct.Err); ok {
+			return sheetErr.TypeName()
+		}
+
+		return ct.String()
 	default:
 		return fmt.Sprintf("%s", ct)
 	}
 }
 
+// An ArrayConstant is a constant two-dimensional array of values, such as
+// {1,2;3,4}. Every row has the same number of values.
+type ArrayConstant struct {
+	Rows [][]Value
+}
+
+func (a *ArrayConstant) marker() {}
+
+// String returns the array in string form.
+func (a *ArrayConstant) String() string {
+	var sb strings.Builder
+	sb.WriteRune('{')
+	for i, row := range a.Rows {
+		if i != 0 {
+			sb.WriteRune(';')
+		}
+
+		for j, v := range row {
+			if j != 0 {
+				sb.WriteRune(',')
+			}
+
+			sb.WriteString((&Constant{Value: v}).String())
+		}
+	}
+
+	sb.WriteRune('}')
+	return sb.String()
+}
+
+// values returns the values in the array, row by row.
+func (a *ArrayConstant) values() []Value {
+	var values []Value
+	for _, row := range a.Rows {
+		values = append(values, row...)
+	}
+
+	return values
+}
+
 // A CellReference is a reference to a cell in a sheet.
 type CellReference struct {
 	Sheet string
@@ -121,5 +169,6 @@ var (
 	_ Formula = &CellRangeReference{}
 	_ Formula = &FunctionCall{}
 	_ Formula = &Constant{}
+	_ Formula = &ArrayConstant{}
 	_ Formula = &NamedRangeReference{}
 )
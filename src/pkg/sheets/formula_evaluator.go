@@ -0,0 +1,260 @@
+package sheets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// A Function is a function that can be called from within a formula. Arguments
+// that are references to cells or ranges are passed as ValueRanges; all other
+// arguments are evaluated and passed as single value iterators.
+type Function interface {
+	Call(ctx context.Context, ev *Evaluator, args []ValueIter) Value
+}
+
+// FunctionFunc adapts an ordinary func into a Function.
+type FunctionFunc func(ctx context.Context, ev *Evaluator, args []ValueIter) Value
+
+// Call calls the function.
+func (fn FunctionFunc) Call(ctx context.Context, ev *Evaluator, args []ValueIter) Value {
+	return fn(ctx, ev, args)
+}
+
+// A VolatileFunction is a Function whose result can change even when its
+// arguments do not, such as NOW or RAND. Formulas that call volatile functions
+// are recomputed on every recalculation.
+type VolatileFunction interface {
+	Function
+	Volatile() bool
+}
+
+// Volatile marks a function as volatile.
+func Volatile(fn Function) Function {
+	return volatileFunction{fn}
+}
+
+type volatileFunction struct {
+	Function
+}
+
+func (fn volatileFunction) Volatile() bool {
+	return true
+}
+
+// A NamedRange is a range of cells in a specific sheet that can be referred
+// to by name.
+type NamedRange struct {
+	Sheet string
+	Range Range
+}
+
+// A NamedRangeResolver is a DataSet that supports named ranges.
+type NamedRangeResolver interface {
+	NamedRange(name string) (NamedRange, bool)
+}
+
+// An Evaluator computes the values of formulas.
+type Evaluator struct {
+	// DataSet is used to resolve references to other sheets, and to named
+	// ranges if it implements NamedRangeResolver. May be nil, in which case
+	// only references to the current sheet can be resolved.
+	DataSet DataSet
+
+	// Functions are the functions that can be called by formulas, keyed by
+	// their upper-case name. If nil, the built-in functions are used.
+	Functions map[string]Function
+
+	// CalcSettings control how values are compared and computed.
+	CalcSettings
+}
+
+// Evaluate computes the value of a formula. References that do not name a
+// sheet are resolved against the given sheet. Errors are returned as an
+// ErrorValue.
+func (ev *Evaluator) Evaluate(ctx context.Context, sheet Sheet, f Formula) Value {
+	switch ft := f.(type) {
+	case *Constant:
+		return ft.Value
+	case *ArrayConstant:
+		// Arrays used where a single value is expected evaluate to their
+		// first value
+		if len(ft.Rows) == 0 || len(ft.Rows[0]) == 0 {
+			return ErrorValue{ValueErrorf("empty array")}
+		}
+
+		return ft.Rows[0][0]
+	case *CellReference:
+		return ev.evaluateCell(ctx, sheet, ft.Sheet, ft.Pos)
+	case *CellRangeReference:
+		return ev.evaluateRange(ctx, sheet, ft.Sheet, ft.Range)
+	case *NamedRangeReference:
+		named, err := ev.namedRange(ft.NamedRange)
+		if err != nil {
+			return ErrorValue{err}
+		}
+
+		return ev.evaluateRange(ctx, sheet, named.Sheet, named.Range)
+	case *Expression:
+		left := ev.Evaluate(ctx, sheet, ft.Left)
+		right := ev.Evaluate(ctx, sheet, ft.Right)
+		return ft.Operator.ApplyWith(left, right, ev.CalcSettings)
+	case *FunctionCall:
+		return ev.evaluateFunction(ctx, sheet, ft)
+	default:
+		// This is an internal coding error
+		panic(&ValueError{
+			fmt.Sprintf("unsupported formula type %s: '%s'", reflect.TypeOf(f), f),
+		})
+	}
+}
+
+func (ev *Evaluator) evaluateCell(ctx context.Context, current Sheet, sheetName string, pos Pos) Value {
+	sheet, err := ev.sheet(current, sheetName)
+	if err != nil {
+		return ErrorValue{err}
+	}
+
+	v, err := sheet.Get(ctx, pos)
+	if err != nil {
+		var posErr InvalidPosError
+		if errors.As(err, &posErr) {
+			// References outside of the populated area of the sheet are blank
+			return BlankValue{}
+		}
+
+		return ErrorValue{err}
+	}
+
+	return v
+}
+
+// evaluateRange evaluates a range that is used where a single value is
+// expected. This is only allowed if the range refers to a single cell.
+func (ev *Evaluator) evaluateRange(ctx context.Context, current Sheet, sheetName string, r Range) Value {
+	if r.EndRow == MaxRow || r.EndCol == MaxColumn || r.NumCells() != 1 {
+		return ErrorValue{ValueErrorf("range '%s' cannot be used as a single value", r)}
+	}
+
+	return ev.evaluateCell(ctx, current, sheetName, r.StartPos())
+}
+
+func (ev *Evaluator) evaluateFunction(ctx context.Context, sheet Sheet, fc *FunctionCall) Value {
+	fn, ok := ev.function(fc.FunctionName)
+	if !ok {
+		return ErrorValue{NameErrorf("unknown function '%s'", fc.FunctionName)}
+	}
+
+	args := make([]ValueIter, 0, len(fc.Args))
+	for _, arg := range fc.Args {
+		iter, err := ev.evaluateArg(ctx, sheet, arg)
+		if err != nil {
+			return ErrorValue{err}
+		}
+
+		args = append(args, iter)
+	}
+
+	return fn.Call(ctx, ev, args)
+}
+
+// evaluateArg evaluates a function argument, returning references as a
+// ValueRange so that functions can iterate over the referenced cells.
+func (ev *Evaluator) evaluateArg(ctx context.Context, current Sheet, arg Formula) (ValueIter, error) {
+	var (
+		sheetName string
+		r         Range
+	)
+
+	switch at := arg.(type) {
+	case *CellReference:
+		sheetName, r = at.Sheet, Range{
+			StartRow: at.Pos.Row, EndRow: at.Pos.Row,
+			StartCol: at.Pos.Col, EndCol: at.Pos.Col,
+		}
+	case *CellRangeReference:
+		sheetName, r = at.Sheet, at.Range
+	case *NamedRangeReference:
+		named, err := ev.namedRange(at.NamedRange)
+		if err != nil {
+			return nil, err
+		}
+
+		sheetName, r = named.Sheet, named.Range
+	case *ArrayConstant:
+		return SliceValueIter(at.values()), nil
+	default:
+		return SingleValueIter(ev.Evaluate(ctx, current, arg)), nil
+	}
+
+	sheet, err := ev.sheet(current, sheetName)
+	if err != nil {
+		return nil, err
+	}
+
+	vr, err := sheet.Range(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+
+	return &referenceRange{ValueRange: vr, ref: r}, nil
+}
+
+// A referenceRange is a ValueRange over a reference passed to a function. It
+// remembers the range that was referred to, since the ValueRange only covers
+// the part of it inside the populated area of the sheet.
+type referenceRange struct {
+	ValueRange
+	ref Range
+}
+
+func (ev *Evaluator) sheet(current Sheet, name string) (Sheet, error) {
+	if name == "" {
+		return current, nil
+	}
+
+	if ev.DataSet != nil {
+		if sheet := ev.DataSet.Sheet(name); sheet != nil {
+			return sheet, nil
+		}
+	}
+
+	return nil, RefErrorf("unknown sheet '%s'", name)
+}
+
+func (ev *Evaluator) namedRange(name string) (NamedRange, error) {
+	if resolver, ok := ev.DataSet.(NamedRangeResolver); ok {
+		if named, ok := resolver.NamedRange(name); ok {
+			return named, nil
+		}
+	}
+
+	return NamedRange{}, NameErrorf("unknown named range '%s'", name)
+}
+
+// isVolatile returns true if the formula calls any volatile functions.
+func (ev *Evaluator) isVolatile(f Formula) bool {
+	volatile := false
+	visitFormula(f, func(node Formula) {
+		if fc, ok := node.(*FunctionCall); ok {
+			fn, _ := ev.function(fc.FunctionName)
+			if vf, ok := fn.(VolatileFunction); ok && vf.Volatile() {
+				volatile = true
+			}
+		}
+	})
+
+	return volatile
+}
+
+func (ev *Evaluator) function(name string) (Function, bool) {
+	functions := ev.Functions
+	if functions == nil {
+		functions = builtinFunctions
+	}
+
+	fn, ok := functions[strings.ToUpper(name)]
+	return fn, ok
+}
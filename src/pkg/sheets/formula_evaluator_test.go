@@ -0,0 +1,95 @@
+package sheets
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testDataSet struct {
+	sheets      map[string]Sheet
+	namedRanges map[string]NamedRange
+}
+
+func (ds *testDataSet) Sheet(name string) Sheet {
+	return ds.sheets[name]
+}
+
+func (ds *testDataSet) NamedRange(name string) (NamedRange, bool) {
+	named, ok := ds.namedRanges[name]
+	return named, ok
+}
+
+func TestEvaluator_Evaluate(t *testing.T) {
+	current, err := NewInMemorySheet([][]Value{
+		{Float64Value(10), Float64Value(20), StringValue("thirty")},
+		{Float64Value(1.5), Float64Value(2.5), BoolValue(true)},
+	})
+	require.NoError(t, err)
+
+	other, err := NewInMemorySheet([][]Value{
+		{Float64Value(100), Float64Value(200)},
+	})
+	require.NoError(t, err)
+
+	ev := &Evaluator{
+		DataSet: &testDataSet{
+			sheets: map[string]Sheet{
+				"Other": other,
+			},
+			namedRanges: map[string]NamedRange{
+				"Totals": {Sheet: "Other", Range: mustParseRange(t, "A1:B1")},
+			},
+		},
+	}
+
+	for _, tt := range []struct {
+		formula  string
+		expected Value
+	}{
+		{"A1 + B1", Float64Value(30)},
+		{"A1 * (B2 - A2)", Float64Value(10)},
+		{"B1 >= A1", BoolValue(true)},
+		{"Other!B1 / A1", Float64Value(20)},
+		{"SUM(A1:C2)", Float64Value(34)},
+		{"SUM(A:A, 4)", Float64Value(15.5)},
+		{"sum(Totals)", Float64Value(300)},
+		{"AVERAGE(A1:B1, Other!A1)", Float64Value(130.0 / 3)},
+		{"Z99", BlankValue{}},
+		{"A1:B1", ErrorValue{ValueErrorf("range 'A1:B1' cannot be used as a single value")}},
+		{"Missing!A1", ErrorValue{RefErrorf("unknown sheet 'Missing'")}},
+		{"NOPE(A1)", ErrorValue{NameErrorf("unknown function 'NOPE'")}},
+		{"SUM(Unknown)", ErrorValue{NameErrorf("unknown named range 'Unknown'")}},
+		{"C1 + 1", ErrorValue{ValueErrorf("unable to convert 'thirty' to float")}},
+		{"SUM({1,2;3,4})", Float64Value(10)},
+		{"{5,6} + 1", Float64Value(6)},
+	} {
+		t.Run(tt.formula, func(t *testing.T) {
+			f, err := ParseFormula(tt.formula)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, ev.Evaluate(context.TODO(), current, f))
+		})
+	}
+}
+
+func TestEvaluator_CustomFunctions(t *testing.T) {
+	functions := BuiltinFunctions()
+	functions["DOUBLE"] = FunctionFunc(func(ctx context.Context, _ *Evaluator, args []ValueIter) Value {
+		if len(args) != 1 || !args[0].Next(ctx) {
+			return ErrorValue{ValueErrorf("DOUBLE takes a single argument")}
+		}
+
+		return Multiply.Apply(args[0].Value(), Float64Value(2))
+	})
+
+	sheet, err := NewInMemorySheet([][]Value{{Float64Value(21)}})
+	require.NoError(t, err)
+
+	f, err := ParseFormula("DOUBLE(A1) + SUM(A1)")
+	require.NoError(t, err)
+
+	ev := &Evaluator{Functions: functions}
+	assert.Equal(t, Float64Value(63), ev.Evaluate(context.TODO(), sheet, f))
+}
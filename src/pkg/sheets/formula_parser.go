@@ -2,7 +2,9 @@ package sheets
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/mmihic/sheets/src/pkg/sheets/internal/formula"
 )
@@ -19,9 +21,12 @@ const (
 // Formula 			:= <Expression> { ">" | "<" | ">=" | "<=" | "<>" <Expression> }
 // Expression 		:= <Term> { ("+" | "-") <Term> }*
 // Term       		:= <Factor>  { ("*"|"/") <Expression>}*
-// Factor     		:= <FunctionCall> | <Reference> | <Constant> | "(" <Formula> ")"
+// Factor     		:= <FunctionCall> | <Reference> | <Constant> | <Array> | "(" <Formula> ")"
 // FunctionCall		:= IDENTIFIER '(' <ArgList>? ')'
 // ArgList			:= <Formula> (',' <Formula>)*
+// Array			:= '{' <ArrayRow> (';' <ArrayRow>)* '}'
+// ArrayRow			:= <ArrayElement> (',' <ArrayElement>)*
+// ArrayElement		:= STRING | '-'? NUMBER | TRUE | FALSE
 // Reference		:= <Sheet>? (CELL | CELL_RANGE | NAMED_RANGE)
 // Constant			:= STRING | NUMBER | TRUE | FALSE
 // STRING			= QuotedString
@@ -30,13 +35,15 @@ const (
 // IDENTIFIER		= [A-Aa-z_][A-Za-z0-9_]*
 // CELL 			= ([A-Za-z]+)(0-9+)
 // CELL_RANGE		= ([A-Za-z]+)?([0-9]+)?\s*:\s*([A-Za-z]+)?([0-9]+)?
+//
+// The separators and names above are those of the EnglishLocale; use
+// ParseFormulaIn to parse formulas written for other locales.
 func ParseFormula(s string) (Formula, error) {
-	lex, err := formula.LexString(s)
-	if err != nil {
-		return nil, err
-	}
+	return ParseFormulaIn(s, EnglishLocale)
+}
 
-	f, err := parseFormula(lex, 0)
+func parseLexedFormula(lex *formula.Lexer, loc *Locale, tz *time.Location) (Formula, error) {
+	f, err := parseFormula(lex, loc, tz, 0)
 	if err != nil {
 		return nil, err
 	}
@@ -50,10 +57,10 @@ func ParseFormula(s string) (Formula, error) {
 	return f, nil
 }
 
-func parseFormula(lex *formula.Lexer, depth int) (Formula, error) {
+func parseFormula(lex *formula.Lexer, loc *Locale, tz *time.Location, depth int) (Formula, error) {
 	traceParse(depth, "parsing formula")
 
-	expr, err := parseExpression(lex, depth+1)
+	expr, err := parseExpression(lex, loc, tz, depth+1)
 	if err != nil {
 		return nil, err
 	}
@@ -65,7 +72,7 @@ func parseFormula(lex *formula.Lexer, depth int) (Formula, error) {
 
 	switch next.Type {
 	case ">", "<", ">=", "<=", "<>", "=":
-		nextExpr, err := parseExpression(lex, depth+1)
+		nextExpr, err := parseExpression(lex, loc, tz, depth+1)
 		if err != nil {
 			return nil, err
 		}
@@ -81,10 +88,10 @@ func parseFormula(lex *formula.Lexer, depth int) (Formula, error) {
 	}
 }
 
-func parseExpression(lex *formula.Lexer, depth int) (Formula, error) {
+func parseExpression(lex *formula.Lexer, loc *Locale, tz *time.Location, depth int) (Formula, error) {
 	traceParse(depth, "parsing expression")
 
-	term, err := parseTerm(lex, depth+1)
+	term, err := parseTerm(lex, loc, tz, depth+1)
 	if err != nil {
 		return nil, err
 	}
@@ -96,7 +103,7 @@ func parseExpression(lex *formula.Lexer, depth int) (Formula, error) {
 
 	switch next.Type {
 	case "+", "-":
-		nextTerm, err := parseTerm(lex, depth+1)
+		nextTerm, err := parseTerm(lex, loc, tz, depth+1)
 		if err != nil {
 			return nil, err
 		}
@@ -112,10 +119,10 @@ func parseExpression(lex *formula.Lexer, depth int) (Formula, error) {
 	}
 }
 
-func parseTerm(lex *formula.Lexer, depth int) (Formula, error) {
+func parseTerm(lex *formula.Lexer, loc *Locale, tz *time.Location, depth int) (Formula, error) {
 	traceParse(depth, "parsing term")
 
-	factor, err := parseFactor(lex, depth+1)
+	factor, err := parseFactor(lex, loc, tz, depth+1)
 	if err != nil {
 		return nil, err
 	}
@@ -127,7 +134,7 @@ func parseTerm(lex *formula.Lexer, depth int) (Formula, error) {
 
 	switch next.Type {
 	case "*", "/", "^":
-		nextFactor, err := parseFactor(lex, depth+1)
+		nextFactor, err := parseFactor(lex, loc, tz, depth+1)
 		if err != nil {
 			return nil, err
 		}
@@ -143,7 +150,7 @@ func parseTerm(lex *formula.Lexer, depth int) (Formula, error) {
 	}
 }
 
-func parseFactor(lex *formula.Lexer, depth int) (Formula, error) {
+func parseFactor(lex *formula.Lexer, loc *Locale, tz *time.Location, depth int) (Formula, error) {
 	traceParse(depth, "parsing factor")
 
 	tok, err := lex.Next()
@@ -162,7 +169,7 @@ func parseFactor(lex *formula.Lexer, depth int) (Formula, error) {
 
 		lex.Push(nextTok, tok)
 		if nextTok.Type == "(" {
-			return parseFunction(lex, depth+1)
+			return parseFunction(lex, loc, tz, depth+1)
 		}
 
 		return parseReference(lex, depth+1)
@@ -180,7 +187,7 @@ func parseFactor(lex *formula.Lexer, depth int) (Formula, error) {
 			return parseReference(lex, depth+1)
 		}
 
-		return parseConstant(lex, depth+1)
+		return parseConstant(lex, loc, tz, depth+1)
 
 	case formula.TokenTypeCellRange:
 		lex.Push(tok)
@@ -188,10 +195,14 @@ func parseFactor(lex *formula.Lexer, depth int) (Formula, error) {
 
 	case formula.TokenTypeNumber, formula.TokenTypeTrue, formula.TokenTypeFalse:
 		lex.Push(tok)
-		return parseConstant(lex, depth+1)
+		return parseConstant(lex, loc, tz, depth+1)
+
+	case "{":
+		lex.Push(tok)
+		return parseArray(lex, loc, tz, depth+1)
 
 	case "(":
-		f, err := parseFormula(lex, depth+1)
+		f, err := parseFormula(lex, loc, tz, depth+1)
 		if err != nil {
 			return nil, err
 		}
@@ -211,7 +222,7 @@ func parseFactor(lex *formula.Lexer, depth int) (Formula, error) {
 	}
 }
 
-func parseFunction(lex *formula.Lexer, depth int) (Formula, error) {
+func parseFunction(lex *formula.Lexer, loc *Locale, tz *time.Location, depth int) (Formula, error) {
 	traceParse(depth, "parsing function")
 
 	fnameToken, err := lex.Next()
@@ -223,7 +234,7 @@ func parseFunction(lex *formula.Lexer, depth int) (Formula, error) {
 		return nil, unexpectedTokenError(fnameToken, formula.TokenTypeIdent)
 	}
 
-	fname := strings.ToUpper(fnameToken.Value)
+	fname := loc.canonicalFunctionName(fnameToken.Value)
 
 	// Start the argument list
 	if startParen, err := lex.Next(); err != nil {
@@ -248,7 +259,7 @@ func parseFunction(lex *formula.Lexer, depth int) (Formula, error) {
 	var args []Formula
 argParsingLoop:
 	for {
-		arg, err := parseFormula(lex, depth+1)
+		arg, err := parseArgument(lex, loc, tz, depth+1)
 		if err != nil {
 			return nil, err
 		}
@@ -260,14 +271,14 @@ argParsingLoop:
 		}
 
 		switch next.Type {
-		case ",":
+		case string(loc.ArgumentSeparator):
 			// Move to the next parameter
 			continue argParsingLoop
 		case ")":
 			// This is the end of the argument list
 			break argParsingLoop
 		default:
-			return nil, unexpectedTokenError(next, ",", ")")
+			return nil, unexpectedTokenError(next, string(loc.ArgumentSeparator), ")")
 		}
 	}
 
@@ -277,7 +288,36 @@ argParsingLoop:
 	}, nil
 }
 
-func parseConstant(lex *formula.Lexer, depth int) (Formula, error) {
+// parseArgument parses a function argument. Unlike constants elsewhere, text
+// passed directly to a function is kept as text, as in Excel, so that
+// functions such as TEXT receive format codes like "000" as written.
+func parseArgument(lex *formula.Lexer, loc *Locale, tz *time.Location, depth int) (Formula, error) {
+	tok, err := lex.Next()
+	if err != nil {
+		return nil, err
+	}
+
+	if tok.Type == formula.TokenTypeString {
+		next, err := lex.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		if next.Type == string(loc.ArgumentSeparator) || next.Type == ")" {
+			lex.Push(next)
+			return &Constant{
+				Value: StringValue(tok.Value),
+			}, nil
+		}
+
+		lex.Push(next)
+	}
+
+	lex.Push(tok)
+	return parseFormula(lex, loc, tz, depth)
+}
+
+func parseConstant(lex *formula.Lexer, loc *Locale, tz *time.Location, depth int) (Formula, error) {
 	traceParse(depth, "parsing constant")
 
 	tok, err := lex.Next()
@@ -285,12 +325,31 @@ func parseConstant(lex *formula.Lexer, depth int) (Formula, error) {
 		return nil, err
 	}
 
+	v, err := constantValue(tok, loc, tz)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Constant{
+		Value: v,
+	}, nil
+}
+
+func constantValue(tok formula.Token, loc *Locale, tz *time.Location) (Value, error) {
 	switch tok.Type {
-	case formula.TokenTypeTrue, formula.TokenTypeFalse, formula.TokenTypeString, formula.TokenTypeNumber:
-		return &Constant{
-			Value: StringToValue(tok.Value),
-		}, nil
+	case formula.TokenTypeTrue:
+		return BoolValue(true), nil
+	case formula.TokenTypeFalse:
+		return BoolValue(false), nil
+	case formula.TokenTypeString:
+		return stringToValueIn(tok.Value, tz), nil
+	case formula.TokenTypeNumber:
+		n, err := strconv.ParseFloat(strings.Replace(tok.Value, string(loc.DecimalSeparator), ".", 1), 64)
+		if err != nil {
+			return nil, formula.WrapParseError(tok.Position, err)
+		}
 
+		return Float64Value(n), nil
 	default:
 		return nil, unexpectedTokenError(tok,
 			formula.TokenTypeString, formula.TokenTypeNumber,
@@ -298,6 +357,74 @@ func parseConstant(lex *formula.Lexer, depth int) (Formula, error) {
 	}
 }
 
+func parseArray(lex *formula.Lexer, loc *Locale, tz *time.Location, depth int) (Formula, error) {
+	traceParse(depth, "parsing array")
+
+	if startBrace, err := lex.Next(); err != nil {
+		return nil, err
+	} else if startBrace.Type != "{" {
+		return nil, unexpectedTokenError(startBrace, "{")
+	}
+
+	var (
+		rows = [][]Value{nil}
+		row  = 0
+	)
+
+	for {
+		tok, err := lex.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		negative := tok.Type == "-"
+		if negative {
+			if tok, err = lex.Next(); err != nil {
+				return nil, err
+			} else if tok.Type != formula.TokenTypeNumber {
+				return nil, unexpectedTokenError(tok, formula.TokenTypeNumber)
+			}
+		}
+
+		v, err := constantValue(tok, loc, tz)
+		if err != nil {
+			return nil, err
+		}
+
+		if negative {
+			v = -v.(Float64Value)
+		}
+
+		rows[row] = append(rows[row], v)
+
+		next, err := lex.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		switch next.Type {
+		case string(loc.ArrayColumnSeparator):
+			// Move to the next column
+		case string(loc.ArrayRowSeparator):
+			if len(rows[row]) != len(rows[0]) {
+				return nil, formula.ParseErrorf(next.Position, "array rows must all have the same length")
+			}
+
+			rows = append(rows, nil)
+			row++
+		case "}":
+			if len(rows[row]) != len(rows[0]) {
+				return nil, formula.ParseErrorf(next.Position, "array rows must all have the same length")
+			}
+
+			return &ArrayConstant{Rows: rows}, nil
+		default:
+			return nil, unexpectedTokenError(next,
+				string(loc.ArrayColumnSeparator), string(loc.ArrayRowSeparator), "}")
+		}
+	}
+}
+
 func parseReference(lex *formula.Lexer, depth int) (Formula, error) {
 	traceParse(depth, "parsing reference")
 
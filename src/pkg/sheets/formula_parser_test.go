@@ -213,6 +213,50 @@ func TestParseFormula(t *testing.T) {
 				NamedRange: "MyNamedRange",
 			}, "",
 		},
+		{
+			"{1,2;3,-4}", &ArrayConstant{
+				Rows: [][]Value{
+					{Float64Value(1), Float64Value(2)},
+					{Float64Value(3), Float64Value(-4)},
+				},
+			}, "",
+		},
+		{
+			`SUM({"a",TRUE}, 1)`, &FunctionCall{
+				FunctionName: "SUM",
+				Args: []Formula{
+					&ArrayConstant{
+						Rows: [][]Value{{StringValue("a"), BoolValue(true)}},
+					},
+					&Constant{Float64Value(1)},
+				},
+			}, "",
+		},
+		{
+			// Text passed directly to a function is kept as written
+			`TEXT("0.5", "000")`, &FunctionCall{
+				FunctionName: "TEXT",
+				Args: []Formula{
+					&Constant{StringValue("0.5")},
+					&Constant{StringValue("000")},
+				},
+			}, "",
+		},
+		{
+			`TEXT("0.5" + 1, "0")`, &FunctionCall{
+				FunctionName: "TEXT",
+				Args: []Formula{
+					&Expression{
+						Left:     &Constant{Float64Value(0.5)},
+						Right:    &Constant{Float64Value(1)},
+						Operator: "+",
+					},
+					&Constant{StringValue("0")},
+				},
+			}, "",
+		},
+		{"{1,2;3}", nil, "error at 1:7: array rows must all have the same length"},
+		{"{1,A1}", nil, "error at 1:4: expected one of [String, Number, True, False]: found 'A1' (Ident)"},
 		{
 			"YetAnotherSheet!B45", &CellReference{
 				Sheet: "YetAnotherSheet",
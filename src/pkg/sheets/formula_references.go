@@ -0,0 +1,179 @@
+package sheets
+
+// mapReferences returns a copy of a formula in which every cell and range
+// reference has been replaced by the result of calling fn on that reference.
+func mapReferences(f Formula, fn func(ref Formula) Formula) Formula {
+	switch ft := f.(type) {
+	case *CellReference, *CellRangeReference:
+		return fn(ft)
+	case *Expression:
+		return &Expression{
+			Left:     mapReferences(ft.Left, fn),
+			Right:    mapReferences(ft.Right, fn),
+			Operator: ft.Operator,
+		}
+	case *FunctionCall:
+		args := make([]Formula, len(ft.Args))
+		for i, arg := range ft.Args {
+			args[i] = mapReferences(arg, fn)
+		}
+
+		return &FunctionCall{
+			FunctionName: ft.FunctionName,
+			Args:         args,
+		}
+	default:
+		return f
+	}
+}
+
+// visitFormula calls fn for every node in a formula, parents before children.
+func visitFormula(f Formula, fn func(node Formula)) {
+	fn(f)
+
+	switch ft := f.(type) {
+	case *Expression:
+		visitFormula(ft.Left, fn)
+		visitFormula(ft.Right, fn)
+	case *FunctionCall:
+		for _, arg := range ft.Args {
+			visitFormula(arg, fn)
+		}
+	}
+}
+
+// An axis is the direction of a structural edit.
+type axis int
+
+const (
+	rowAxis axis = iota
+	colAxis
+)
+
+// A structuralEdit is the insertion or deletion of a set of rows or columns.
+type structuralEdit struct {
+	axis  axis
+	at    int // the first row or column inserted or deleted
+	count int // the number inserted if positive, or deleted if negative
+}
+
+// shiftedRange returns the range of cells that are moved or deleted by the edit.
+func (e structuralEdit) shiftedRange() Range {
+	if e.axis == rowAxis {
+		return Range{StartRow: e.at, EndRow: MaxRow, StartCol: 0, EndCol: MaxColumn}
+	}
+
+	return Range{StartRow: 0, EndRow: MaxRow, StartCol: e.at, EndCol: MaxColumn}
+}
+
+// adjustFormula rewrites the references in a formula to account for the
+// edit, in the same way as Excel: references after the edit point move,
+// ranges that span the edit point grow or shrink, and references to
+// deleted cells become #REF errors. Only references to sheets for which
+// isTarget returns true are adjusted.
+func (e structuralEdit) adjustFormula(f Formula, isTarget func(sheet string) bool) Formula {
+	return mapReferences(f, func(ref Formula) Formula {
+		switch rt := ref.(type) {
+		case *CellReference:
+			if !isTarget(rt.Sheet) {
+				return rt
+			}
+
+			pos, ok := e.adjustPos(rt.Pos)
+			if !ok {
+				return &Constant{Value: ErrorValue{RefErrorf("reference to deleted cell '%s'", rt)}}
+			}
+
+			return &CellReference{Sheet: rt.Sheet, Pos: pos}
+		case *CellRangeReference:
+			if !isTarget(rt.Sheet) {
+				return rt
+			}
+
+			r, ok := e.adjustRange(rt.Range)
+			if !ok {
+				return &Constant{Value: ErrorValue{RefErrorf("reference to deleted range '%s'", rt)}}
+			}
+
+			return &CellRangeReference{Sheet: rt.Sheet, Range: r}
+		default:
+			return ref
+		}
+	})
+}
+
+// adjustPos moves a position to account for the edit, returning false
+// if the position was deleted.
+func (e structuralEdit) adjustPos(pos Pos) (Pos, bool) {
+	var ok bool
+	if e.axis == rowAxis {
+		pos.Row, _, ok = e.adjustSpan(pos.Row, pos.Row)
+	} else {
+		pos.Col, _, ok = e.adjustSpan(pos.Col, pos.Col)
+	}
+
+	return pos, ok
+}
+
+// adjustRange moves and resizes a range to account for the edit, returning
+// false if the entire range was deleted.
+func (e structuralEdit) adjustRange(r Range) (Range, bool) {
+	var ok bool
+	if e.axis == rowAxis {
+		r.StartRow, r.EndRow, ok = e.adjustSpan(r.StartRow, r.EndRow)
+	} else {
+		r.StartCol, r.EndCol, ok = e.adjustSpan(r.StartCol, r.EndCol)
+	}
+
+	return r, ok
+}
+
+// adjustSpan adjusts the inclusive [start, end] span covered by a reference
+// along the axis of the edit. An end of -1 (MaxRow or MaxColumn) means the
+// span is open-ended, and is left as-is.
+func (e structuralEdit) adjustSpan(start, end int) (int, int, bool) {
+	const open = -1
+
+	if start == 0 && end == open {
+		// Covers the entire axis, so is unaffected by edits
+		return start, end, true
+	}
+
+	if e.count > 0 {
+		if start >= e.at {
+			start += e.count
+		}
+
+		if end != open && end >= e.at {
+			end += e.count
+		}
+
+		return start, end, true
+	}
+
+	var (
+		numDeleted  = -e.count
+		lastDeleted = e.at + numDeleted - 1
+	)
+
+	if start >= e.at && end != open && end <= lastDeleted {
+		return 0, 0, false
+	}
+
+	switch {
+	case start > lastDeleted:
+		start -= numDeleted
+	case start >= e.at:
+		start = e.at
+	}
+
+	switch {
+	case end == open:
+	case end > lastDeleted:
+		end -= numDeleted
+	case end >= e.at:
+		end = e.at - 1
+	}
+
+	return start, end, true
+}
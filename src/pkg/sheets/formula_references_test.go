@@ -0,0 +1,54 @@
+package sheets
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStructuralEdit_AdjustFormula(t *testing.T) {
+	isLocal := func(sheet string) bool { return sheet == "" }
+
+	for _, tt := range []struct {
+		name     string
+		edit     structuralEdit
+		formula  string
+		expected string
+	}{
+		{"insert rows before reference",
+			structuralEdit{axis: rowAxis, at: 1, count: 2}, "A2 + A1", "A4 + A1"},
+		{"insert rows inside range",
+			structuralEdit{axis: rowAxis, at: 4, count: 3}, "SUM(B2:C10)", "SUM(B2:C13)"},
+		{"insert rows at start of range",
+			structuralEdit{axis: rowAxis, at: 1, count: 1}, "SUM(B2:C10)", "SUM(B3:C11)"},
+		{"insert rows leaves whole columns alone",
+			structuralEdit{axis: rowAxis, at: 0, count: 5}, "SUM(B:C)", "SUM(B:C)"},
+		{"insert rows leaves other sheets alone",
+			structuralEdit{axis: rowAxis, at: 0, count: 5}, "Other!A1 + A1", "`Other`!A1 + A6"},
+		{"delete rows before reference",
+			structuralEdit{axis: rowAxis, at: 0, count: -2}, "A5", "A3"},
+		{"delete referenced row",
+			structuralEdit{axis: rowAxis, at: 4, count: -1}, "A5 + 1", "#REF + 1"},
+		{"delete rows inside range",
+			structuralEdit{axis: rowAxis, at: 3, count: -2}, "SUM(A2:A10)", "SUM(A2:A8)"},
+		{"delete start of range",
+			structuralEdit{axis: rowAxis, at: 0, count: -3}, "SUM(A2:A10)", "SUM(A1:A7)"},
+		{"delete end of range",
+			structuralEdit{axis: rowAxis, at: 8, count: -5}, "SUM(A2:A10)", "SUM(A2:A8)"},
+		{"delete entire range",
+			structuralEdit{axis: rowAxis, at: 1, count: -9}, "SUM(A2:A10)", "SUM(#REF)"},
+		{"insert columns",
+			structuralEdit{axis: colAxis, at: 1, count: 2}, "A1 * B1 + SUM(A2:C2)", "A1 * D1 + SUM(A2:E2)"},
+		{"delete columns",
+			structuralEdit{axis: colAxis, at: 1, count: -1}, "A1 * B1 + SUM(A2:C2)", "A1 * #REF + SUM(A2:B2)"},
+		{"delete columns leaves whole rows alone",
+			structuralEdit{axis: colAxis, at: 0, count: -1}, "SUM(2:4)", "SUM(2:4)"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := ParseFormula(tt.formula)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, tt.edit.adjustFormula(f, isLocal).String())
+		})
+	}
+}
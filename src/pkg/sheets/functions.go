@@ -0,0 +1,511 @@
+package sheets
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+var (
+	builtinFunctions = map[string]Function{
+		"AVERAGE":     FunctionFunc(fnAverage),
+		"CONCAT":      FunctionFunc(fnConcat),
+		"CONCATENATE": FunctionFunc(fnConcat),
+		"COUNT":       FunctionFunc(fnCount),
+		"COUNTA":      FunctionFunc(fnCountA),
+		"COUNTBLANK":  FunctionFunc(fnCountBlank),
+		"ISBLANK":     FunctionFunc(fnIsBlank),
+		"MATCH":       FunctionFunc(fnMatch),
+		"MAX":         FunctionFunc(fnMax),
+		"MIN":         FunctionFunc(fnMin),
+		"NOW":         Volatile(FunctionFunc(fnNow)),
+		"RAND":        Volatile(FunctionFunc(fnRand)),
+		"SUM":         FunctionFunc(fnSum),
+		"TEXT":        FunctionFunc(fnText),
+		"TODAY":       Volatile(FunctionFunc(fnToday)),
+		"TZCONVERT":   FunctionFunc(fnTZConvert),
+		"VALUE":       FunctionFunc(fnValue),
+	}
+)
+
+// BuiltinFunctions returns a copy of the built-in functions, keyed by name. Can
+// be used as the basis of a custom set of Evaluator functions.
+func BuiltinFunctions() map[string]Function {
+	functions := make(map[string]Function, len(builtinFunctions))
+	for name, fn := range builtinFunctions {
+		functions[name] = fn
+	}
+
+	return functions
+}
+
+func fnSum(ctx context.Context, ev *Evaluator, args []ValueIter) Value {
+	nums, err := numericArgs(ctx, ev.CalcSettings, args)
+	if err != nil {
+		return ErrorValue{err}
+	}
+
+	return Float64Value(ev.NumericMode.sum(nums))
+}
+
+func fnAverage(ctx context.Context, ev *Evaluator, args []ValueIter) Value {
+	nums, err := numericArgs(ctx, ev.CalcSettings, args)
+	if err != nil {
+		return ErrorValue{err}
+	}
+
+	if len(nums) == 0 {
+		return ErrorValue{ErrDivideByZero}
+	}
+
+	return Float64Value(ev.NumericMode.sum(nums) / float64(len(nums)))
+}
+
+func fnMin(ctx context.Context, ev *Evaluator, args []ValueIter) Value {
+	nums, err := numericArgs(ctx, ev.CalcSettings, args)
+	if err != nil {
+		return ErrorValue{err}
+	}
+
+	if len(nums) == 0 {
+		return Float64Value(0)
+	}
+
+	lowest := math.Inf(1)
+	for _, n := range nums {
+		lowest = math.Min(lowest, n)
+	}
+
+	return Float64Value(lowest)
+}
+
+func fnMax(ctx context.Context, ev *Evaluator, args []ValueIter) Value {
+	nums, err := numericArgs(ctx, ev.CalcSettings, args)
+	if err != nil {
+		return ErrorValue{err}
+	}
+
+	if len(nums) == 0 {
+		return Float64Value(0)
+	}
+
+	highest := math.Inf(-1)
+	for _, n := range nums {
+		highest = math.Max(highest, n)
+	}
+
+	return Float64Value(highest)
+}
+
+func fnCount(ctx context.Context, _ *Evaluator, args []ValueIter) Value {
+	count := 0
+	for _, arg := range args {
+		_, isRef := arg.(ValueRange)
+		for arg.Next(ctx) {
+			switch v := arg.Value().(type) {
+			case Float64Value, FormattedNumber, TimeValue:
+				count++
+			case BoolValue:
+				if !isRef {
+					count++
+				}
+			case StringValue:
+				if _, err := v.ToFloat64(); err == nil && !isRef {
+					count++
+				}
+			}
+		}
+
+		if err := arg.Err(); err != nil {
+			return ErrorValue{err}
+		}
+	}
+
+	return Float64Value(count)
+}
+
+// fnCountA counts the values that are not blank, including empty text and errors.
+func fnCountA(ctx context.Context, _ *Evaluator, args []ValueIter) Value {
+	count := 0
+	for _, arg := range args {
+		for arg.Next(ctx) {
+			if _, isBlank := arg.Value().(BlankValue); !isBlank {
+				count++
+			}
+		}
+
+		if err := arg.Err(); err != nil {
+			return ErrorValue{err}
+		}
+	}
+
+	return Float64Value(count)
+}
+
+// fnCountBlank counts the blank cells in a range. Following Excel, cells
+// containing empty text are also counted.
+func fnCountBlank(ctx context.Context, _ *Evaluator, args []ValueIter) Value {
+	if len(args) != 1 {
+		return ErrorValue{ValueErrorf("COUNTBLANK takes 1 argument")}
+	}
+
+	count, visited := 0, 0
+	for args[0].Next(ctx) {
+		visited++
+		switch v := args[0].Value().(type) {
+		case BlankValue:
+			count++
+		case StringValue:
+			if v == "" {
+				count++
+			}
+		}
+	}
+
+	if err := args[0].Err(); err != nil {
+		return ErrorValue{err}
+	}
+
+	// The cells of a bounded range that lie outside the populated area of the
+	// sheet are not visited, but are blank
+	if ref, ok := args[0].(*referenceRange); ok && ref.ref.EndRow != MaxRow && ref.ref.EndCol != MaxColumn {
+		count += ref.ref.NumCells() - visited
+	}
+
+	return Float64Value(count)
+}
+
+func fnIsBlank(ctx context.Context, _ *Evaluator, args []ValueIter) Value {
+	if len(args) != 1 {
+		return ErrorValue{ValueErrorf("ISBLANK takes 1 argument")}
+	}
+
+	v, err := scalarArg(ctx, args[0])
+	if err != nil {
+		return ErrorValue{err}
+	}
+
+	_, isBlank := v.(BlankValue)
+	return BoolValue(isBlank)
+}
+
+// fnConcat joins the text of its arguments, including every value in ranges.
+// Blank cells are treated as empty text.
+func fnConcat(ctx context.Context, ev *Evaluator, args []ValueIter) Value {
+	var sb strings.Builder
+	for _, arg := range args {
+		for arg.Next(ctx) {
+			v := arg.Value()
+			if errVal, ok := v.(ErrorValue); ok {
+				return errVal
+			}
+
+			sb.WriteString(ev.text(v))
+		}
+
+		if err := arg.Err(); err != nil {
+			return ErrorValue{err}
+		}
+	}
+
+	return StringValue(sb.String())
+}
+
+// fnValue converts text to a number, understanding the same forms as
+// ParseNumber as well as dates and times.
+func fnValue(ctx context.Context, ev *Evaluator, args []ValueIter) Value {
+	if len(args) != 1 {
+		return ErrorValue{ValueErrorf("VALUE takes 1 argument")}
+	}
+
+	v, err := scalarArg(ctx, args[0])
+	if err != nil {
+		return ErrorValue{err}
+	}
+
+	switch tv := v.(type) {
+	case ErrorValue:
+		return tv
+	case BoolValue:
+		return ErrorValue{ValueErrorf("'%s' is not a valid number", tv)}
+	case StringValue:
+		if tm, err := ParseTimeIn(string(tv), ev.location()); err == nil {
+			return Float64Value(ev.toExcelTime(tm))
+		}
+
+		n, _, err := ParseNumber(string(tv))
+		if err != nil {
+			return ErrorValue{err}
+		}
+
+		return Float64Value(n)
+	default:
+		n, err := ev.toFloat64(v)
+		if err != nil {
+			return ErrorValue{err}
+		}
+
+		return Float64Value(n)
+	}
+}
+
+// fnText formats a value using an Excel number format code. Text that looks
+// like a number or date is formatted as one.
+func fnText(ctx context.Context, ev *Evaluator, args []ValueIter) Value {
+	if len(args) != 2 {
+		return ErrorValue{ValueErrorf("TEXT takes 2 arguments")}
+	}
+
+	v, err := scalarArg(ctx, args[0])
+	if err != nil {
+		return ErrorValue{err}
+	}
+
+	code, err := scalarArg(ctx, args[1])
+	if err != nil {
+		return ErrorValue{err}
+	}
+
+	if errVal, ok := code.(ErrorValue); ok {
+		return errVal
+	}
+
+	if text, ok := v.(StringValue); ok {
+		switch converted := stringToValueIn(string(text), ev.location()).(type) {
+		case Float64Value, TimeValue:
+			v = converted
+		}
+	}
+
+	nf, err := ParseNumberFormat(code.String())
+	if err != nil {
+		return ErrorValue{err}
+	}
+
+	s, err := nf.FormatWith(v, ev.CalcSettings)
+	if err != nil {
+		return ErrorValue{err}
+	}
+
+	return StringValue(s)
+}
+
+func fnNow(_ context.Context, ev *Evaluator, args []ValueIter) Value {
+	if len(args) != 0 {
+		return ErrorValue{ValueErrorf("NOW takes no arguments")}
+	}
+
+	return TimeValue(time.Now().In(ev.location()).Truncate(time.Second))
+}
+
+// fnToday returns midnight of the current day in the evaluator's location.
+func fnToday(_ context.Context, ev *Evaluator, args []ValueIter) Value {
+	if len(args) != 0 {
+		return ErrorValue{ValueErrorf("TODAY takes no arguments")}
+	}
+
+	loc := ev.location()
+	year, month, day := time.Now().In(loc).Date()
+	return TimeValue(time.Date(year, month, day, 0, 0, 0, 0, loc))
+}
+
+// fnTZConvert implements TZCONVERT(datetime, from_zone, to_zone), converting
+// a date and time that is a wall-clock time in one time zone to the serial
+// number of the same instant as a wall-clock time in another. Zones are IANA
+// names or fixed offsets, as understood by LoadLocation. Times are first
+// converted to serial numbers in the evaluator's location.
+func fnTZConvert(ctx context.Context, ev *Evaluator, args []ValueIter) Value {
+	if len(args) != 3 {
+		return ErrorValue{ValueErrorf("TZCONVERT takes 3 arguments")}
+	}
+
+	var values [3]Value
+	for i, arg := range args {
+		v, err := scalarArg(ctx, arg)
+		if err != nil {
+			return ErrorValue{err}
+		}
+
+		if errVal, ok := v.(ErrorValue); ok {
+			return errVal
+		}
+
+		values[i] = v
+	}
+
+	n, err := ev.toFloat64(values[0])
+	if text, ok := values[0].(StringValue); ok {
+		if tm, parseErr := ParseTimeIn(string(text), ev.location()); parseErr == nil {
+			n, err = ev.toExcelTime(tm), nil
+		}
+	}
+
+	if err != nil {
+		return ErrorValue{err}
+	}
+
+	from, err := LoadLocation(values[1].String())
+	if err != nil {
+		return ErrorValue{err}
+	}
+
+	to, err := LoadLocation(values[2].String())
+	if err != nil {
+		return ErrorValue{err}
+	}
+
+	tm := ev.DateSystem.FromExcelTimeIn(n, from)
+	return Float64Value(ev.DateSystem.ToExcelTimeIn(tm, to))
+}
+
+func fnRand(_ context.Context, _ *Evaluator, args []ValueIter) Value {
+	if len(args) != 0 {
+		return ErrorValue{ValueErrorf("RAND takes no arguments")}
+	}
+
+	return Float64Value(rand.Float64())
+}
+
+// fnMatch implements MATCH(lookup_value, lookup_array, [match_type]), returning
+// the 1-based position of the lookup value in the array. A match type of 0
+// finds the first value equal to the lookup value, 1 (the default) finds the
+// largest value less than or equal to the lookup value in an array sorted in
+// ascending order, and -1 finds the smallest value greater than or equal to
+// the lookup value in an array sorted in descending order. Text is compared
+// using the evaluator's CalcSettings.
+func fnMatch(ctx context.Context, ev *Evaluator, args []ValueIter) Value {
+	if len(args) < 2 || len(args) > 3 {
+		return ErrorValue{ValueErrorf("MATCH takes 2 or 3 arguments")}
+	}
+
+	lookup, err := scalarArg(ctx, args[0])
+	if err != nil {
+		return ErrorValue{err}
+	}
+
+	if errVal, ok := lookup.(ErrorValue); ok {
+		return errVal
+	}
+
+	matchType := 1.0
+	if len(args) == 3 {
+		v, err := scalarArg(ctx, args[2])
+		if err != nil {
+			return ErrorValue{err}
+		}
+
+		if matchType, err = v.ToFloat64(); err != nil {
+			return ErrorValue{err}
+		}
+	}
+
+	var (
+		found    = -1
+		lookupIn = args[1]
+	)
+
+	for i := 0; lookupIn.Next(ctx); i++ {
+		v := lookupIn.Value()
+		if sortRank(v) != sortRank(lookup) {
+			// Approximate matches skip values of other types, and exact
+			// matches can never match them
+			continue
+		}
+
+		cmp, err := compareValues(v, lookup, ev.CalcSettings)
+		if err != nil {
+			continue
+		}
+
+		switch {
+		case matchType == 0:
+			if cmp == 0 {
+				return Float64Value(i + 1)
+			}
+		case matchType > 0:
+			if cmp > 0 {
+				return matchResult(found, lookup)
+			}
+
+			found = i
+		default:
+			if cmp < 0 {
+				return matchResult(found, lookup)
+			}
+
+			found = i
+		}
+	}
+
+	if err := lookupIn.Err(); err != nil {
+		return ErrorValue{err}
+	}
+
+	return matchResult(found, lookup)
+}
+
+func matchResult(found int, lookup Value) Value {
+	if found < 0 {
+		return ErrorValue{NotAvailableErrorf("no match for '%s'", lookup)}
+	}
+
+	return Float64Value(found + 1)
+}
+
+// scalarArg returns the single value of an argument, which is the first
+// value if the argument is a range.
+func scalarArg(ctx context.Context, arg ValueIter) (Value, error) {
+	if !arg.Next(ctx) {
+		if err := arg.Err(); err != nil {
+			return nil, err
+		}
+
+		return BlankValue{}, nil
+	}
+
+	return arg.Value(), nil
+}
+
+// numericArgs collects the numbers from a set of function arguments, following
+// the rules Excel uses for functions like SUM: values in referenced cells are only
+// included if they are numbers, while values passed directly are converted to
+// numbers if possible. Times are converted using the date system in the
+// settings. Errors in either are returned.
+func numericArgs(ctx context.Context, settings CalcSettings, args []ValueIter) ([]float64, error) {
+	var nums []float64
+	for _, arg := range args {
+		_, isRef := arg.(ValueRange)
+		for arg.Next(ctx) {
+			switch v := arg.Value().(type) {
+			case ErrorValue:
+				return nil, v.Err
+			case Float64Value, FormattedNumber, TimeValue:
+				n, err := settings.toFloat64(v)
+				if err != nil {
+					return nil, err
+				}
+
+				nums = append(nums, n)
+			default:
+				if isRef {
+					continue
+				}
+
+				n, err := settings.toFloat64(v)
+				if err != nil {
+					return nil, err
+				}
+
+				nums = append(nums, n)
+			}
+		}
+
+		if err := arg.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	return nums, nil
+}
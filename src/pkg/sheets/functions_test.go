@@ -0,0 +1,115 @@
+package sheets
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuiltinFunctions(t *testing.T) {
+	sheet, err := NewInMemorySheet([][]Value{
+		{Float64Value(4), StringValue("text"), BoolValue(true), Float64Value(-2)},
+		{Float64Value(8), StringValue("12"), Float64Value(6)},
+	})
+	require.NoError(t, err)
+
+	for _, tt := range []struct {
+		formula  string
+		expected Value
+	}{
+		{"SUM(A1:D2)", Float64Value(16)},
+		{"SUM(A1:D2, TRUE, \"3\")", Float64Value(20)},
+		{"SUM(A1, \"text\")", ErrorValue{ValueErrorf("unable to convert 'text' to float")}},
+		{"AVERAGE(A1:D2)", Float64Value(4)},
+		{"AVERAGE(B1)", ErrorValue{ErrDivideByZero}},
+		{"MIN(A1:D2)", Float64Value(-2)},
+		{"MAX(A1:D2)", Float64Value(8)},
+		{"MAX(B1:B2)", Float64Value(0)},
+		{"COUNT(A1:D2)", Float64Value(4)},
+		{"COUNT(A1:D2, TRUE, \"12\", \"text\")", Float64Value(6)},
+		{"COUNTA(A1:D2)", Float64Value(7)},
+		{"COUNTA(A1:E3, \"\")", Float64Value(8)},
+		{"COUNTBLANK(A1:D2)", Float64Value(1)},
+		{"COUNTBLANK(A1:D10)", Float64Value(33)},
+		{"COUNTBLANK(F5:G6)", Float64Value(4)},
+		{"COUNTBLANK(D:D)", Float64Value(1)},
+		{"COUNTBLANK(A1, B1)", ErrorValue{ValueErrorf("COUNTBLANK takes 1 argument")}},
+		{"ISBLANK(D2)", BoolValue(true)},
+		{"ISBLANK(Z99)", BoolValue(true)},
+		{"ISBLANK(A1)", BoolValue(false)},
+		{"ISBLANK(\"\")", BoolValue(false)},
+		{"CONCAT(A1:D1, D2, \"!\")", StringValue("4textTRUE-2!")},
+		{"CONCATENATE(B2, \" \", A2 / 3)", StringValue("12 2.66666666666667")},
+		{"CONCAT(A1 / 0)", ErrorValue{ErrDivideByZero}},
+		{"VALUE(\"$1,234.50\")", Float64Value(1234.5)},
+		{"VALUE(\"(15%)\")", Float64Value(-0.15)},
+		{"VALUE(B2)", Float64Value(12)},
+		{"VALUE(\"01/02/2024\")", Float64Value(45293)},
+		{"VALUE(B1)", ErrorValue{ValueErrorf("'text' is not a valid number")}},
+		{"VALUE(C1)", ErrorValue{ValueErrorf("'TRUE' is not a valid number")}},
+		{"TEXT(A2 / 3, \"0.00\")", StringValue("2.67")},
+		{"TEXT(1234.5, \"$#,##0.00\")", StringValue("$1,234.50")},
+		{"TEXT(B2, \"000\")", StringValue("012")},
+		{"TEXT(\"2024-03-05\", \"mmm d, yyyy\")", StringValue("Mar 5, 2024")},
+		{"TEXT(B1, \"0.00\")", StringValue("text")},
+		{"TEXT(A1 / 0, \"0.00\")", ErrorValue{ErrDivideByZero}},
+		{"TEXT(A1, \"0 \\\"units\")", ErrorValue{ValueErrorf("invalid number format '0 \"units': unterminated string")}},
+		{"TEXT(A1)", ErrorValue{ValueErrorf("TEXT takes 2 arguments")}},
+		{"TZCONVERT(45356.5, \"UTC\", \"America/New_York\")",
+			Float64Value(ToExcelTime(time.Date(2024, time.March, 5, 7, 0, 0, 0, time.UTC)))},
+		{"TZCONVERT(\"2024-07-01 09:00:00\", \"Europe/Berlin\", \"+05:30\")",
+			Float64Value(ToExcelTime(time.Date(2024, time.July, 1, 12, 30, 0, 0, time.UTC)))},
+		{"TZCONVERT(45356, \"Mars/Olympus\", \"UTC\")", ErrorValue{ValueErrorf("unknown time zone 'Mars/Olympus'")}},
+		{"TZCONVERT(45356, \"UTC\")", ErrorValue{ValueErrorf("TZCONVERT takes 3 arguments")}},
+		{"MATCH(\"TEXT\", A1:D1, 0)", Float64Value(2)},
+		{"MATCH(TRUE, A1:D1, 0)", Float64Value(3)},
+		{"MATCH(8, A1:A2, 0)", Float64Value(2)},
+		{"MATCH(5, A1:A2)", Float64Value(1)},
+		{"MATCH(\"other\", A1:D1, 0)", ErrorValue{NotAvailableErrorf("no match for 'other'")}},
+	} {
+		t.Run(tt.formula, func(t *testing.T) {
+			f, err := ParseFormula(tt.formula)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, (&Evaluator{}).Evaluate(context.TODO(), sheet, f))
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	sheet, err := NewInMemorySheet([][]Value{
+		{StringValue("apple"), Float64Value(10), Float64Value(50)},
+		{StringValue("Banana"), Float64Value(20), Float64Value(40)},
+		{StringValue("cherry"), Float64Value(30), Float64Value(30)},
+		{StringValue("Résumé"), Float64Value(40), Float64Value(20)},
+	})
+	require.NoError(t, err)
+
+	for _, tt := range []struct {
+		formula  string
+		collator Collator
+		expected Value
+	}{
+		{"MATCH(\"BANANA\", A1:A4, 0)", nil, Float64Value(2)},
+		{"MATCH(\"résumé\", A1:A4, 0)", nil, Float64Value(4)},
+		{"MATCH(\"resume\", A1:A4, 0)", nil, ErrorValue{NotAvailableErrorf("no match for 'resume'")}},
+		{"MATCH(\"BANANA\", A1:A4, 0)", BinaryCollator, ErrorValue{NotAvailableErrorf("no match for 'BANANA'")}},
+		{"MATCH(\"Blueberry\", A1:A4)", nil, Float64Value(2)},
+		{"MATCH(25, B1:B4, 1)", nil, Float64Value(2)},
+		{"MATCH(40, B1:B4, 1)", nil, Float64Value(4)},
+		{"MATCH(5, B1:B4, 1)", nil, ErrorValue{NotAvailableErrorf("no match for '5'")}},
+		{"MATCH(25, C1:C4, 0 - 1)", nil, Float64Value(3)},
+		{"MATCH(60, C1:C4, 0 - 1)", nil, ErrorValue{NotAvailableErrorf("no match for '60'")}},
+		{"MATCH(10)", nil, ErrorValue{ValueErrorf("MATCH takes 2 or 3 arguments")}},
+	} {
+		t.Run(tt.formula, func(t *testing.T) {
+			f, err := ParseFormula(tt.formula)
+			require.NoError(t, err)
+
+			ev := &Evaluator{CalcSettings: CalcSettings{Collator: tt.collator}}
+			assert.Equal(t, tt.expected, ev.Evaluate(context.TODO(), sheet, f))
+		})
+	}
+}
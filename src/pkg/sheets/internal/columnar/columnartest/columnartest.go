@@ -0,0 +1,66 @@
+// Package columnartest holds a sheet and helpers for testing the writers and
+// readers of columnar formats.
+package columnartest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/mmihic/sheets/src/pkg/sheets"
+)
+
+// NewSheet returns a sheet holding a header row and a column of each kind:
+// text, numbers, times, booleans and formulas computing text and errors,
+// followed by a column without a header. Each column has blanks.
+func NewSheet(t testing.TB) sheets.Sheet {
+	s, err := sheets.NewMutableSheet([][]sheets.Value{
+		{
+			sheets.StringValue("name"), sheets.StringValue("price"), sheets.StringValue("when"),
+			sheets.StringValue("ok"), sheets.StringValue("total"), sheets.BlankValue{},
+		},
+		{
+			sheets.StringValue("apple"), sheets.FormattedNumber{Number: 1.5, Format: "0.00"},
+			sheets.TimeValue(time.Date(2024, time.March, 5, 12, 30, 0, 0, time.UTC)),
+			sheets.BoolValue(true), sheets.StringValue("=B2*2"), sheets.BlankValue{},
+		},
+		{
+			sheets.BlankValue{}, sheets.Float64Value(-2.25), sheets.BlankValue{},
+			sheets.BoolValue(false), sheets.StringValue("=B3/0"), sheets.BlankValue{},
+		},
+		{
+			sheets.StringValue("pear"), sheets.BlankValue{},
+			sheets.TimeValue(time.Date(1969, time.July, 20, 20, 17, 40, 0, time.UTC)),
+			sheets.BlankValue{}, sheets.StringValue("=B4+1"), sheets.BlankValue{},
+		},
+	}, sheets.WithFormulas())
+	require.NoError(t, err)
+	return s
+}
+
+// ReadAll returns the values of every cell of a sheet, by row.
+func ReadAll(t testing.TB, s sheets.Sheet) [][]sheets.Value {
+	dims := s.Dimensions()
+	return ReadRange(t, s, sheets.Range{EndRow: dims.EndRow, EndCol: dims.EndCol})
+}
+
+// ReadRange returns the values of the cells of a range of a sheet, by row.
+func ReadRange(t testing.TB, s sheets.Sheet, r sheets.Range) [][]sheets.Value {
+	ctx := context.Background()
+	vr, err := s.Range(ctx, r)
+	require.NoError(t, err)
+
+	var rows [][]sheets.Value
+	for vr.Next(ctx) {
+		if vr.Pos().Col == r.StartCol {
+			rows = append(rows, nil)
+		}
+
+		rows[len(rows)-1] = append(rows[len(rows)-1], vr.Value())
+	}
+
+	require.NoError(t, vr.Err())
+	return rows
+}
@@ -0,0 +1,125 @@
+package columnar
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mmihic/sheets/src/pkg/sheets"
+)
+
+// A Kind is the kind of values held by a column written to a columnar file.
+type Kind int
+
+// The kinds of columns.
+const (
+	KindText Kind = iota
+	KindNumber
+	KindBool
+	KindTime
+)
+
+// A Column is a column of a sheet being written to a columnar file.
+type Column struct {
+	Name   string
+	Kind   Kind
+	Values []sheets.Value
+}
+
+// ReadColumns reads the columns of a sheet whose first row holds the names of
+// its columns, naming columns without a name by their letters. Formulas are
+// computed as the values are read.
+//
+// The kind of a column is that of its values, ignoring blanks: numbers, booleans
+// or times. Columns holding a mix of kinds, text or errors, or only blanks, are
+// text.
+func ReadColumns(ctx context.Context, s sheets.Sheet) ([]Column, error) {
+	dims := s.Dimensions()
+	if dims.EndRow < 0 || dims.EndCol < 0 {
+		return nil, nil
+	}
+
+	columns := make([]Column, dims.EndCol+1)
+	for col := range columns {
+		columns[col].Values = make([]sheets.Value, 0, dims.EndRow)
+	}
+
+	vr, err := s.Range(ctx, sheets.Range{EndRow: dims.EndRow, EndCol: dims.EndCol})
+	if err != nil {
+		return nil, err
+	}
+
+	for vr.Next(ctx) {
+		pos := vr.Pos()
+		if pos.Row == 0 {
+			if _, blank := vr.Value().(sheets.BlankValue); !blank {
+				columns[pos.Col].Name = strings.TrimSpace(vr.Value().String())
+			}
+
+			continue
+		}
+
+		columns[pos.Col].Values = append(columns[pos.Col].Values, vr.Value())
+	}
+
+	if err := vr.Err(); err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool, len(columns))
+	for i := range columns {
+		c := &columns[i]
+		if c.Name == "" {
+			c.Name = strings.TrimRight(sheets.Pos{Col: i}.String(), "0123456789")
+		}
+
+		if seen[c.Name] {
+			return nil, fmt.Errorf("duplicate column name '%s'", c.Name)
+		}
+
+		seen[c.Name] = true
+		c.Kind = kindOf(c.Values)
+	}
+
+	return columns, nil
+}
+
+func kindOf(values []sheets.Value) Kind {
+	kind, found := KindText, false
+	for _, v := range values {
+		var k Kind
+		switch v.(type) {
+		case sheets.BlankValue:
+			continue
+		case sheets.Float64Value, sheets.FormattedNumber:
+			k = KindNumber
+		case sheets.BoolValue:
+			k = KindBool
+		case sheets.TimeValue:
+			k = KindTime
+		default:
+			return KindText
+		}
+
+		if found && k != kind {
+			return KindText
+		}
+
+		kind, found = k, true
+	}
+
+	return kind
+}
+
+// Text returns a value as written to a text column. Errors are written as
+// their error codes, such as "#DIV/0!".
+func Text(v sheets.Value) string {
+	switch tv := v.(type) {
+	case sheets.StringValue:
+		return string(tv)
+	case sheets.ErrorValue:
+		return sheets.ErrorCode(tv.Err)
+	default:
+		return v.String()
+	}
+}
@@ -0,0 +1,60 @@
+package columnar
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mmihic/sheets/src/pkg/sheets"
+)
+
+func TestReadColumns(t *testing.T) {
+	when := sheets.TimeValue(time.Date(2024, time.March, 5, 0, 0, 0, 0, time.UTC))
+	s, err := sheets.NewMutableSheet([][]sheets.Value{
+		{
+			sheets.StringValue(" name "), sheets.StringValue("n"), sheets.BlankValue{},
+			sheets.StringValue("when"), sheets.StringValue("mixed"), sheets.StringValue("empty"),
+			sheets.StringValue("err"),
+		},
+		{
+			sheets.StringValue("a"), sheets.Float64Value(1), sheets.BoolValue(true),
+			when, sheets.Float64Value(1), sheets.BlankValue{}, sheets.StringValue("=1/0"),
+		},
+		{
+			sheets.BlankValue{}, sheets.FormattedNumber{Number: 2, Format: "0.0"}, sheets.BlankValue{},
+			sheets.BlankValue{}, sheets.BoolValue(false), sheets.BlankValue{}, sheets.Float64Value(1),
+		},
+	}, sheets.WithFormulas())
+	require.NoError(t, err)
+
+	columns, err := ReadColumns(context.Background(), s)
+	require.NoError(t, err)
+
+	var (
+		names []string
+		kinds []Kind
+	)
+
+	for _, c := range columns {
+		names = append(names, c.Name)
+		kinds = append(kinds, c.Kind)
+		assert.Len(t, c.Values, 2, c.Name)
+	}
+
+	assert.Equal(t, []string{"name", "n", "C", "when", "mixed", "empty", "err"}, names)
+	assert.Equal(t, []Kind{KindText, KindNumber, KindBool, KindTime, KindText, KindText, KindText}, kinds)
+	assert.Equal(t, "#DIV/0!", Text(columns[6].Values[0]))
+	assert.Equal(t, "a", Text(columns[0].Values[0]))
+	assert.Equal(t, "1", Text(columns[6].Values[1]))
+}
+
+func TestReadColumnsDuplicate(t *testing.T) {
+	s, err := sheets.NewMutableSheet([][]sheets.Value{{sheets.StringValue("B"), sheets.BlankValue{}}})
+	require.NoError(t, err)
+
+	_, err = ReadColumns(context.Background(), s)
+	assert.EqualError(t, err, "duplicate column name 'B'")
+}
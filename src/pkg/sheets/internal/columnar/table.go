@@ -0,0 +1,189 @@
+// Package columnar holds the parts shared by the readers and writers of
+// columnar formats, such as Parquet and Arrow, which store each column of a
+// table separately in chunks of rows.
+package columnar
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/mmihic/sheets/src/pkg/sheets"
+)
+
+// A LoadFunc loads the values of a column within a chunk of rows.
+type LoadFunc func(ctx context.Context, chunk, col int) ([]sheets.Value, error)
+
+// A Table is a sheet whose first row holds the names of its columns, and
+// whose following rows are stored in chunks, each column of which is loaded
+// when first needed. The most recently loaded chunk of each column is kept,
+// so that reading down a column loads each chunk once, and reading a single
+// column never loads the others.
+type Table struct {
+	columns []string
+	starts  []int
+	rows    int
+	load    LoadFunc
+
+	mu     sync.Mutex
+	loaded []loadedChunk
+}
+
+type loadedChunk struct {
+	chunk  int
+	values []sheets.Value
+}
+
+// NewTable returns a table with the given columns, whose rows are stored in
+// chunks of the given numbers of rows.
+func NewTable(columns []string, chunkRows []int, load LoadFunc) *Table {
+	t := &Table{
+		columns: columns,
+		starts:  make([]int, len(chunkRows)),
+		load:    load,
+		loaded:  make([]loadedChunk, len(columns)),
+	}
+
+	for i, n := range chunkRows {
+		t.starts[i] = t.rows
+		t.rows += n
+	}
+
+	for col := range t.loaded {
+		t.loaded[col].chunk = -1
+	}
+
+	return t
+}
+
+// Columns returns the names of the columns.
+func (t *Table) Columns() []string {
+	return t.columns
+}
+
+// NumRows returns the number of rows, not counting the header row.
+func (t *Table) NumRows() int {
+	return t.rows
+}
+
+// Dimensions returns the dimensions of the table, including the header row.
+func (t *Table) Dimensions() sheets.Dimensions {
+	return sheets.Dimensions{EndRow: t.rows, EndCol: len(t.columns) - 1}
+}
+
+// Get returns the value of a cell, loading the chunk of its column that holds
+// it if needed.
+func (t *Table) Get(ctx context.Context, pos sheets.Pos) (sheets.Value, error) {
+	if pos.Row < 0 || pos.Col < 0 || pos.Row > t.rows || pos.Col >= len(t.columns) {
+		return nil, sheets.InvalidPosError{Pos: pos}
+	}
+
+	if pos.Row == 0 {
+		return sheets.StringValue(t.columns[pos.Col]), nil
+	}
+
+	row := pos.Row - 1
+	chunk := sort.Search(len(t.starts), func(i int) bool { return t.starts[i] > row }) - 1
+	values, err := t.chunk(ctx, chunk, pos.Col)
+	if err != nil {
+		return nil, err
+	}
+
+	if i := row - t.starts[chunk]; i < len(values) {
+		return values[i], nil
+	}
+
+	return sheets.BlankValue{}, nil
+}
+
+// chunk returns the values of a column within a chunk, loading them if they
+// are not the chunk of the column most recently loaded.
+func (t *Table) chunk(ctx context.Context, chunk, col int) ([]sheets.Value, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.loaded[col].chunk == chunk {
+		return t.loaded[col].values, nil
+	}
+
+	values, err := t.load(ctx, chunk, col)
+	if err != nil {
+		return nil, err
+	}
+
+	t.loaded[col] = loadedChunk{chunk: chunk, values: values}
+	return values, nil
+}
+
+// Range returns the values of a range of cells. Only the columns within the
+// range are loaded.
+func (t *Table) Range(_ context.Context, r sheets.Range) (sheets.ValueRange, error) {
+	resolved, ok := r.Resolve(t.Dimensions())
+	if !ok {
+		return &valueRange{t: t, bounds: r, empty: true}, nil
+	}
+
+	return &valueRange{t: t, bounds: resolved, index: -1}, nil
+}
+
+// A valueRange iterates over a range of cells of a table.
+type valueRange struct {
+	t      *Table
+	bounds sheets.Range
+	empty  bool
+
+	pos   sheets.Pos
+	index int
+	value sheets.Value
+	err   error
+}
+
+var (
+	_ sheets.Sheet      = &Table{}
+	_ sheets.ValueRange = &valueRange{}
+)
+
+func (vr *valueRange) Next(ctx context.Context) bool {
+	if vr.empty || vr.err != nil {
+		return false
+	}
+
+	if vr.index < 0 {
+		vr.pos = vr.bounds.StartPos()
+	} else {
+		next, ok := vr.bounds.NextPos(vr.pos)
+		if !ok {
+			return false
+		}
+
+		vr.pos = next
+	}
+
+	vr.index++
+	vr.value, vr.err = vr.t.Get(ctx, vr.pos)
+	return vr.err == nil
+}
+
+func (vr *valueRange) Err() error {
+	return vr.err
+}
+
+func (vr *valueRange) Value() sheets.Value {
+	return vr.value
+}
+
+func (vr *valueRange) Index() int {
+	return vr.index
+}
+
+func (vr *valueRange) Len() int {
+	if vr.empty {
+		return 0
+	}
+
+	return vr.bounds.NumCells()
+}
+
+func (vr *valueRange) Pos() sheets.Pos {
+	return vr.pos
+}
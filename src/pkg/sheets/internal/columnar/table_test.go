@@ -0,0 +1,107 @@
+package columnar
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mmihic/sheets/src/pkg/sheets"
+)
+
+// newTestTable returns a table of two columns in chunks of 2, 0 and 3 rows,
+// whose values are their row and column, along with the loads made.
+func newTestTable() (*Table, *[][2]int) {
+	var loads [][2]int
+	sizes := []int{2, 0, 3}
+	t := NewTable([]string{"a", "b"}, sizes, func(_ context.Context, chunk, col int) ([]sheets.Value, error) {
+		loads = append(loads, [2]int{chunk, col})
+		start := 0
+		for _, n := range sizes[:chunk] {
+			start += n
+		}
+
+		values := make([]sheets.Value, sizes[chunk])
+		for i := range values {
+			values[i] = sheets.Float64Value((start+i)*10 + col)
+		}
+
+		return values, nil
+	})
+
+	return t, &loads
+}
+
+func TestTableGet(t *testing.T) {
+	ctx := context.Background()
+	table, loads := newTestTable()
+	assert.Equal(t, []string{"a", "b"}, table.Columns())
+	assert.Equal(t, 5, table.NumRows())
+	assert.Equal(t, sheets.Dimensions{EndRow: 5, EndCol: 1}, table.Dimensions())
+
+	v, err := table.Get(ctx, sheets.Pos{Col: 1})
+	require.NoError(t, err)
+	assert.Equal(t, sheets.StringValue("b"), v)
+	assert.Empty(t, *loads)
+
+	for _, tt := range []struct {
+		pos      sheets.Pos
+		expected sheets.Value
+	}{
+		{sheets.Pos{Row: 1, Col: 0}, sheets.Float64Value(0)},
+		{sheets.Pos{Row: 2, Col: 0}, sheets.Float64Value(10)},
+		{sheets.Pos{Row: 3, Col: 1}, sheets.Float64Value(21)},
+		{sheets.Pos{Row: 5, Col: 1}, sheets.Float64Value(41)},
+		{sheets.Pos{Row: 4, Col: 1}, sheets.Float64Value(31)},
+	} {
+		v, err := table.Get(ctx, tt.pos)
+		require.NoError(t, err)
+		assert.Equal(t, tt.expected, v, tt.pos.String())
+	}
+
+	assert.Equal(t, [][2]int{{0, 0}, {2, 1}}, *loads)
+
+	for _, pos := range []sheets.Pos{{Row: 6}, {Col: 2}, {Row: -1}} {
+		_, err := table.Get(ctx, pos)
+		assert.Equal(t, sheets.InvalidPosError{Pos: pos}, err)
+	}
+}
+
+func TestTableRange(t *testing.T) {
+	ctx := context.Background()
+	table, loads := newTestTable()
+
+	vr, err := table.Range(ctx, sheets.Range{StartRow: 2, EndRow: 4, StartCol: 1, EndCol: 1})
+	require.NoError(t, err)
+	assert.Equal(t, 3, vr.Len())
+
+	var values []sheets.Value
+	for vr.Next(ctx) {
+		assert.Equal(t, len(values), vr.Index())
+		values = append(values, vr.Value())
+	}
+
+	require.NoError(t, vr.Err())
+	assert.Equal(t, []sheets.Value{sheets.Float64Value(11), sheets.Float64Value(21), sheets.Float64Value(31)}, values)
+	assert.Equal(t, [][2]int{{0, 1}, {2, 1}}, *loads)
+
+	vr, err = table.Range(ctx, sheets.Range{StartRow: 7, EndRow: 9})
+	require.NoError(t, err)
+	assert.Equal(t, 0, vr.Len())
+	assert.False(t, vr.Next(ctx))
+}
+
+func TestTableLoadError(t *testing.T) {
+	ctx := context.Background()
+	table := NewTable([]string{"a"}, []int{1}, func(context.Context, int, int) ([]sheets.Value, error) {
+		return nil, errors.New("broken")
+	})
+
+	vr, err := table.Range(ctx, sheets.Range{EndRow: 1})
+	require.NoError(t, err)
+	assert.True(t, vr.Next(ctx))
+	assert.False(t, vr.Next(ctx))
+	assert.EqualError(t, vr.Err(), "broken")
+}
@@ -0,0 +1,74 @@
+package columnar
+
+import (
+	"math"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/mmihic/sheets/src/pkg/sheets"
+)
+
+// timeOfDayEpoch is the day of serial 0 in the Date1900 system, on which times
+// of day fall so that they are fractions of a day in formulas.
+var timeOfDayEpoch = time.Date(1899, time.December, 31, 0, 0, 0, 0, time.UTC)
+
+// Date returns the value of a date stored as days since the Unix epoch.
+func Date(days int64) sheets.Value {
+	return sheets.TimeValue(time.Unix(days*86400, 0).UTC())
+}
+
+// Timestamp returns the value of a timestamp stored as a number of units since
+// the Unix epoch, in UTC.
+func Timestamp(n int64, unit time.Duration) sheets.Value {
+	perSecond := int64(time.Second / unit)
+	secs, rem := n/perSecond, n%perSecond
+	if rem < 0 {
+		secs, rem = secs-1, rem+perSecond
+	}
+
+	return sheets.TimeValue(time.Unix(secs, rem*int64(unit)).UTC())
+}
+
+// TimeOfDay returns the value of a time of day stored as a number of units
+// since midnight.
+func TimeOfDay(n int64, unit time.Duration) sheets.Value {
+	return sheets.TimeValue(timeOfDayEpoch.Add(time.Duration(n) * unit))
+}
+
+// Float32 returns the value of a single-precision float, keeping its shortest
+// decimal form rather than its binary expansion, so that 0.1 remains 0.1.
+func Float32(f float32) sheets.Value {
+	n, _ := strconv.ParseFloat(strconv.FormatFloat(float64(f), 'g', -1, 32), 64)
+	return sheets.Float64Value(n)
+}
+
+// Float16 returns the value of a half-precision float.
+func Float16(h uint16) sheets.Value {
+	sign := 1.0
+	if h&0x8000 != 0 {
+		sign = -1
+	}
+
+	exp := int(h>>10) & 0x1f
+	frac := float64(h & 0x3ff)
+	switch exp {
+	case 0:
+		return sheets.Float64Value(sign * math.Ldexp(frac, -24))
+	case 0x1f:
+		if frac != 0 {
+			return sheets.Float64Value(math.NaN())
+		}
+
+		return sheets.Float64Value(math.Inf(int(sign)))
+	default:
+		return sheets.Float64Value(sign * math.Ldexp(1+frac/1024, exp-15))
+	}
+}
+
+// Decimal returns the value of a decimal stored as an unscaled integer, which
+// is the float nearest to the decimal.
+func Decimal(unscaled *big.Int, scale int) sheets.Value {
+	n, _ := strconv.ParseFloat(unscaled.String()+"e"+strconv.Itoa(-scale), 64)
+	return sheets.Float64Value(n)
+}
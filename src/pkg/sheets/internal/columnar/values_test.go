@@ -0,0 +1,76 @@
+package columnar
+
+import (
+	"math"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mmihic/sheets/src/pkg/sheets"
+)
+
+func TestDate(t *testing.T) {
+	assert.Equal(t, sheets.TimeValue(time.Date(1970, time.January, 1, 0, 0, 0, 0, time.UTC)), Date(0))
+	assert.Equal(t, sheets.TimeValue(time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC)), Date(19782))
+	assert.Equal(t, sheets.TimeValue(time.Date(1969, time.December, 31, 0, 0, 0, 0, time.UTC)), Date(-1))
+}
+
+func TestTimestamp(t *testing.T) {
+	when := time.Date(2024, time.March, 5, 12, 30, 15, 123456789, time.UTC)
+	assert.Equal(t, sheets.TimeValue(when), Timestamp(when.UnixNano(), time.Nanosecond))
+	assert.Equal(t, sheets.TimeValue(when.Truncate(time.Microsecond)), Timestamp(when.UnixMicro(), time.Microsecond))
+	assert.Equal(t, sheets.TimeValue(when.Truncate(time.Millisecond)), Timestamp(when.UnixMilli(), time.Millisecond))
+	assert.Equal(t, sheets.TimeValue(when.Truncate(time.Second)), Timestamp(when.Unix(), time.Second))
+
+	// Timestamps before the epoch round down
+	assert.Equal(t,
+		sheets.TimeValue(time.Date(1969, time.December, 31, 23, 59, 59, 999000000, time.UTC)),
+		Timestamp(-1, time.Millisecond))
+}
+
+func TestTimeOfDay(t *testing.T) {
+	assert.Equal(t,
+		sheets.TimeValue(time.Date(1899, time.December, 31, 18, 0, 0, 0, time.UTC)),
+		TimeOfDay(18*3600, time.Second))
+
+	f, _ := TimeOfDay(int64(6*time.Hour/time.Microsecond), time.Microsecond).ToFloat64()
+	assert.InDelta(t, 0.25, f, 1e-9)
+}
+
+func TestFloat32(t *testing.T) {
+	assert.Equal(t, sheets.Float64Value(0.1), Float32(0.1))
+	assert.Equal(t, sheets.Float64Value(-1234.5), Float32(-1234.5))
+	assert.Equal(t, sheets.Float64Value(math.Inf(1)), Float32(float32(math.Inf(1))))
+}
+
+func TestFloat16(t *testing.T) {
+	for _, tt := range []struct {
+		h        uint16
+		expected float64
+	}{
+		{0x0000, 0},
+		{0x3c00, 1},
+		{0xc000, -2},
+		{0x3555, 0.333251953125},
+		{0x7bff, 65504},
+		{0x0001, math.Ldexp(1, -24)},
+		{0x7c00, math.Inf(1)},
+		{0xfc00, math.Inf(-1)},
+	} {
+		assert.Equal(t, sheets.Float64Value(tt.expected), Float16(tt.h), "%#04x", tt.h)
+	}
+
+	f, _ := Float16(0x7e00).ToFloat64()
+	assert.True(t, math.IsNaN(f))
+}
+
+func TestDecimal(t *testing.T) {
+	assert.Equal(t, sheets.Float64Value(123.45), Decimal(big.NewInt(12345), 2))
+	assert.Equal(t, sheets.Float64Value(-0.001), Decimal(big.NewInt(-1), 3))
+	assert.Equal(t, sheets.Float64Value(1200), Decimal(big.NewInt(12), -2))
+
+	huge, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	assert.Equal(t, sheets.Float64Value(1.2345678901234568e+19), Decimal(huge, 10))
+}
@@ -3,7 +3,9 @@ package formula
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
+	"sync"
 
 	"github.com/alecthomas/participle/v2/lexer"
 
@@ -34,22 +36,55 @@ func (t Token) EOF() bool {
 	return t.eof
 }
 
-// LexString returns a new lexer for the given string.
+// Syntax describes the parts of the formula syntax that vary by locale.
+type Syntax struct {
+	DecimalSeparator     rune
+	ArgumentSeparator    rune
+	ArrayColumnSeparator rune
+	ArrayRowSeparator    rune
+	True                 string
+	False                string
+}
+
+// DefaultSyntax is the syntax used by English versions of Excel.
+var DefaultSyntax = Syntax{
+	DecimalSeparator:     '.',
+	ArgumentSeparator:    ',',
+	ArrayColumnSeparator: ',',
+	ArrayRowSeparator:    ';',
+	True:                 "TRUE",
+	False:                "FALSE",
+}
+
+// LexString returns a new lexer for the given string, using the DefaultSyntax.
 func LexString(text string) (*Lexer, error) {
-	l, err := lex.LexString("", text)
+	return LexStringWith(text, DefaultSyntax)
+}
+
+// LexStringWith returns a new lexer for the given string, using the given
+// syntax. Separators are returned as tokens whose type is the separator itself.
+func LexStringWith(text string, syntax Syntax) (*Lexer, error) {
+	def, err := definitionFor(syntax)
+	if err != nil {
+		return nil, err
+	}
+
+	l, err := def.lex.LexString("", text)
 	if err != nil {
 		return nil, err
 	}
 
 	return &Lexer{
-		lex: backtrack.EnableBacktracking(l),
+		lex:        backtrack.EnableBacktracking(l),
+		definition: def,
 	}, nil
 }
 
 // Lexer is a lexer for formulas.
 type Lexer struct {
-	lex  backtrack.Lexer
-	next []Token
+	lex        backtrack.Lexer
+	definition *definition
+	next       []Token
 }
 
 // Next returns the next token from the lexer.
@@ -65,9 +100,9 @@ func (l *Lexer) Next() (Token, error) {
 		return Token{}, err
 	}
 
-	if isStartOfString(lexTok) {
+	if l.isStartOfString(lexTok) {
 		l.lex.Push(lexTok)
-		text, err := consumeString(l.lex)
+		text, err := l.consumeString()
 		if err != nil {
 			return Token{}, err
 		}
@@ -79,9 +114,9 @@ func (l *Lexer) Next() (Token, error) {
 		}, nil
 	}
 
-	tokenType, ok := symbols[lexTok.Type]
+	tokenType, ok := l.definition.symbols[lexTok.Type]
 	if !ok {
-		for symbol, typ := range lex.Symbols() {
+		for symbol, typ := range l.definition.lex.Symbols() {
 			if typ == lexTok.Type {
 				return Token{}, fmt.Errorf("unknown token type: '%s'", symbol)
 			}
@@ -103,8 +138,8 @@ func (l *Lexer) Push(tokens ...Token) {
 	l.next = append(l.next, tokens...)
 }
 
-func isStartOfString(tok lexer.Token) bool {
-	switch symbols[tok.Type] {
+func (l *Lexer) isStartOfString(tok lexer.Token) bool {
+	switch l.definition.symbols[tok.Type] {
 	case "DoubleQuotes", "SingleQuotes", "TickQuotes":
 		return true
 	default:
@@ -112,34 +147,34 @@ func isStartOfString(tok lexer.Token) bool {
 	}
 }
 
-func consumeString(lex backtrack.Lexer) (string, error) {
-	tok, err := lex.Next()
+func (l *Lexer) consumeString() (string, error) {
+	tok, err := l.lex.Next()
 	if err != nil {
 		return "", err
 	}
 
-	switch symbols[tok.Type] {
+	switch l.definition.symbols[tok.Type] {
 	case "DoubleQuotes":
-		return consumeStringUsing(lex, "DoubleQuotedStringChars", "DoubleQuotes")
+		return l.consumeStringUsing("DoubleQuotedStringChars", "DoubleQuotes")
 	case "SingleQuotes":
-		return consumeStringUsing(lex, "SingleQuotedStringChars", "SingleQuotes")
+		return l.consumeStringUsing("SingleQuotedStringChars", "SingleQuotes")
 	case "TickQuotes":
-		return consumeStringUsing(lex, "TickQuotedStringChars", "TickQuotes")
+		return l.consumeStringUsing("TickQuotedStringChars", "TickQuotes")
 	default:
 		return "", ParseErrorf(tok.Pos, "expected one of [String], found '%s'", tok.Value)
 
 	}
 }
 
-func consumeStringUsing(lex backtrack.Lexer, charsToken, stopToken string) (string, error) {
+func (l *Lexer) consumeStringUsing(charsToken, stopToken string) (string, error) {
 	var elts []string
 	for {
-		tok, err := lex.Next()
+		tok, err := l.lex.Next()
 		if err != nil {
 			return "", nil
 		}
 
-		switch symbols[tok.Type] {
+		switch l.definition.symbols[tok.Type] {
 		case charsToken, "Char":
 			elts = append(elts, tok.Value)
 		case stopToken:
@@ -150,34 +185,74 @@ func consumeStringUsing(lex backtrack.Lexer, charsToken, stopToken string) (stri
 	}
 }
 
-var (
-	lex = lexer.MustStateful(lexer.Rules{
-		"Root": {
-			{`SingleQuotes`, `'`, lexer.Push("SingleQuotedString")},
-			{`DoubleQuotes`, `"`, lexer.Push("DoubleQuotedString")},
-			{"TickQuotes", "`", lexer.Push("TickQuotedString")},
-			{"True", `[Tt][Rr][Uu][Ee]`, nil},
-			{"False", `[Ff][Aa][Ll][Ss][Ee]`, nil},
-			{"CellRange", `([A-Za-z]{1,3})?(\d+)?\s*:\s*([A-Za-z]{1,3})?(\d+)?`, nil},
-			{"Ident", `[A-Za-z_][A-Za-z0-9_]*`, nil},
-			{">=", ">=", nil},
-			{"<=", "<=", nil},
-			{"<>", "<>", nil},
-			{"=", "=", nil},
-			{"!", "!", nil},
-			{":", ":", nil},
-			{",", ",", nil},
-			{"(", `\(`, nil},
-			{")", `\)`, nil},
-			{"+", `\+`, nil},
-			{"-", `\-`, nil},
-			{"/", `/`, nil},
-			{"*", `\*`, nil},
-			{"^", `\^`, nil},
-			{"&", `\&`, nil},
-			{"Number", `[0-9]+(\.[0-9]+)?`, nil},
-			{"whitespace", `[\s]+`, nil},
-		},
+// A definition is the lexer definition for a Syntax.
+type definition struct {
+	lex     *lexer.StatefulDefinition
+	symbols map[lexer.TokenType]string
+}
+
+var definitions sync.Map // Syntax -> *definition
+
+func definitionFor(syntax Syntax) (*definition, error) {
+	if def, ok := definitions.Load(syntax); ok {
+		return def.(*definition), nil
+	}
+
+	lex, err := lexer.New(rulesFor(syntax))
+	if err != nil {
+		return nil, fmt.Errorf("invalid formula syntax: %w", err)
+	}
+
+	def, _ := definitions.LoadOrStore(syntax, &definition{
+		lex:     lex,
+		symbols: lexer.SymbolsByRune(lex),
+	})
+
+	return def.(*definition), nil
+}
+
+func rulesFor(syntax Syntax) lexer.Rules {
+	root := []lexer.Rule{
+		{`SingleQuotes`, `'`, lexer.Push("SingleQuotedString")},
+		{`DoubleQuotes`, `"`, lexer.Push("DoubleQuotedString")},
+		{"TickQuotes", "`", lexer.Push("TickQuotedString")},
+		{"True", `(?i)` + regexp.QuoteMeta(syntax.True) + `\b`, nil},
+		{"False", `(?i)` + regexp.QuoteMeta(syntax.False) + `\b`, nil},
+		{"CellRange", `([A-Za-z]{1,3})?(\d+)?\s*:\s*([A-Za-z]{1,3})?(\d+)?`, nil},
+		{"Ident", `[\pL_][\pL0-9_]*(\.[\pL0-9_]+)*`, nil},
+		{">=", ">=", nil},
+		{"<=", "<=", nil},
+		{"<>", "<>", nil},
+		{"=", "=", nil},
+		{"!", "!", nil},
+		{":", ":", nil},
+	}
+
+	seen := make(map[rune]bool)
+	for _, sep := range []rune{syntax.ArgumentSeparator, syntax.ArrayColumnSeparator, syntax.ArrayRowSeparator} {
+		if !seen[sep] {
+			seen[sep] = true
+			root = append(root, lexer.Rule{Name: string(sep), Pattern: regexp.QuoteMeta(string(sep))})
+		}
+	}
+
+	root = append(root, []lexer.Rule{
+		{"(", `\(`, nil},
+		{")", `\)`, nil},
+		{"{", `\{`, nil},
+		{"}", `\}`, nil},
+		{"+", `\+`, nil},
+		{"-", `\-`, nil},
+		{"/", `/`, nil},
+		{"*", `\*`, nil},
+		{"^", `\^`, nil},
+		{"&", `\&`, nil},
+		{"Number", `[0-9]+(` + regexp.QuoteMeta(string(syntax.DecimalSeparator)) + `[0-9]+)?`, nil},
+		{"whitespace", `[\s]+`, nil},
+	}...)
+
+	return lexer.Rules{
+		"Root": root,
 		"SingleQuotedString": {
 			{"backslash", `\\`, lexer.Push("EscapedChar")},
 			{"SingleQuotes", `'`, lexer.Pop()},
@@ -196,7 +271,5 @@ var (
 		"EscapedChar": {
 			{"Char", `.`, lexer.Pop()},
 		},
-	})
-
-	symbols = lexer.SymbolsByRune(lex)
-)
+	}
+}
@@ -118,3 +118,79 @@ func TestLexer(t *testing.T) {
 		})
 	}
 }
+
+func TestLexStringWith(t *testing.T) {
+	german := Syntax{
+		DecimalSeparator:     ',',
+		ArgumentSeparator:    ';',
+		ArrayColumnSeparator: '.',
+		ArrayRowSeparator:    ';',
+		True:                 "WAHR",
+		False:                "FALSCH",
+	}
+
+	for _, tt := range []struct {
+		input    string
+		expected []expectedToken
+	}{
+		{"SUMME(A1; 1,5; wahr)", []expectedToken{
+			{"Ident", "SUMME"},
+			{"(", "("},
+			{"Ident", "A1"},
+			{";", ";"},
+			{"Number", "1,5"},
+			{";", ";"},
+			{"True", "wahr"},
+			{")", ")"},
+		}},
+		{"{1.2;3.4}", []expectedToken{
+			{"{", "{"},
+			{"Number", "1"},
+			{".", "."},
+			{"Number", "2"},
+			{";", ";"},
+			{"Number", "3"},
+			{".", "."},
+			{"Number", "4"},
+			{"}", "}"},
+		}},
+		{"FALSCH", []expectedToken{
+			{"False", "FALSCH"},
+		}},
+		{"TRUE", []expectedToken{
+			{"Ident", "TRUE"},
+		}},
+		{"NB.VIDE(A:A)", []expectedToken{
+			{"Ident", "NB.VIDE"},
+			{"(", "("},
+			{"CellRange", "A:A"},
+			{")", ")"},
+		}},
+	} {
+		t.Run(tt.input, func(t *testing.T) {
+			l, err := LexStringWith(tt.input, german)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			var tokens []expectedToken
+			for {
+				tok, err := l.Next()
+				if !assert.NoError(t, err) {
+					return
+				}
+
+				if tok.EOF() {
+					break
+				}
+
+				tokens = append(tokens, expectedToken{
+					Type:  tok.Type,
+					Value: tok.Value,
+				})
+			}
+
+			assert.Equal(t, tt.expected, tokens)
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package interop
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	apache "github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/ipc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mmihic/sheets/src/pkg/sheets"
+	"github.com/mmihic/sheets/src/pkg/sheets/arrow"
+	"github.com/mmihic/sheets/src/pkg/sheets/internal/columnar/columnartest"
+)
+
+// referenceFileRows reads a file with the IPC reader of the Apache Arrow Go
+// implementation.
+func referenceFileRows(t *testing.T, data []byte) [][]sheets.Value {
+	r, err := ipc.NewFileReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	defer r.Close()
+
+	var records []apache.Record
+	for i := 0; i < r.NumRecords(); i++ {
+		rec, err := r.Record(i)
+		require.NoError(t, err)
+		rec.Retain()
+		defer rec.Release()
+
+		records = append(records, rec)
+	}
+
+	rows, err := recordRows(r.Schema(), records...)
+	require.NoError(t, err)
+	return rows
+}
+
+// referenceStreamRows reads a stream with the IPC reader of the Apache Arrow
+// Go implementation.
+func referenceStreamRows(t *testing.T, data []byte) [][]sheets.Value {
+	r, err := ipc.NewReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	defer r.Release()
+
+	var records []apache.Record
+	for r.Next() {
+		rec := r.Record()
+		rec.Retain()
+		defer rec.Release()
+
+		records = append(records, rec)
+	}
+
+	require.NoError(t, r.Err())
+	rows, err := recordRows(r.Schema(), records...)
+	require.NoError(t, err)
+	return rows
+}
+
+func TestArrow_ReadReferenceFiles(t *testing.T) {
+	expected := [][]sheets.Value{
+		{
+			sheets.StringValue("id"), sheets.StringValue("name"), sheets.StringValue("when"),
+			sheets.StringValue("day"), sheets.StringValue("price"), sheets.StringValue("ok"),
+			sheets.StringValue("address.city"), sheets.StringValue("address.zip"),
+		},
+		{
+			sheets.Float64Value(1), sheets.StringValue("apple"),
+			sheets.TimeValue(time.Date(2024, time.March, 5, 12, 30, 0, 0, time.UTC)),
+			sheets.TimeValue(time.Date(2024, time.March, 5, 0, 0, 0, 0, time.UTC)),
+			sheets.Float64Value(1.5), sheets.BoolValue(true),
+			sheets.StringValue("Paris"), sheets.Float64Value(75001),
+		},
+		{
+			sheets.Float64Value(2), sheets.StringValue("pear"),
+			sheets.TimeValue(time.Date(1969, time.July, 20, 20, 17, 40, 0, time.UTC)),
+			sheets.TimeValue(time.Date(1969, time.July, 20, 0, 0, 0, 0, time.UTC)),
+			sheets.Float64Value(-2.25), sheets.BoolValue(false),
+			sheets.StringValue("Rome"), sheets.BlankValue{},
+		},
+		{
+			sheets.Float64Value(3), sheets.BlankValue{}, sheets.BlankValue{}, sheets.BlankValue{},
+			sheets.BlankValue{}, sheets.BlankValue{}, sheets.BlankValue{}, sheets.BlankValue{},
+		},
+		{
+			sheets.Float64Value(4), sheets.StringValue("apple"),
+			sheets.TimeValue(time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)),
+			sheets.TimeValue(time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)),
+			sheets.Float64Value(1234567.89), sheets.BoolValue(true),
+			sheets.BlankValue{}, sheets.Float64Value(10115),
+		},
+		{
+			sheets.Float64Value(5), sheets.StringValue("pear"),
+			sheets.TimeValue(time.Date(2024, time.February, 29, 23, 59, 59, 999999000, time.UTC)),
+			sheets.TimeValue(time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC)),
+			sheets.Float64Value(0.01), sheets.BoolValue(false),
+			sheets.BlankValue{}, sheets.BlankValue{},
+		},
+	}
+
+	t.Run("file", func(t *testing.T) {
+		data, err := os.ReadFile(filepath.Join("..", "..", "arrow", "testdata", "arrow_go.arrow"))
+		require.NoError(t, err)
+
+		s, err := arrow.Read(bytes.NewReader(data), int64(len(data)))
+		require.NoError(t, err)
+		assert.Equal(t, expected, columnartest.ReadAll(t, s))
+		assert.Equal(t, expected, referenceFileRows(t, data))
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		data, err := os.ReadFile(filepath.Join("..", "..", "arrow", "testdata", "arrow_go.arrows"))
+		require.NoError(t, err)
+
+		s, err := arrow.ReadStream(bytes.NewReader(data))
+		require.NoError(t, err)
+		assert.Equal(t, expected, columnartest.ReadAll(t, s))
+		assert.Equal(t, expected, referenceStreamRows(t, data))
+	})
+}
+
+func TestArrow_WriteReadByReference(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		opts []arrow.WriteOption
+	}{
+		{"default", nil},
+		{"batches", []arrow.WriteOption{arrow.WithBatchSize(2)}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, arrow.Write(context.Background(), &buf, columnartest.NewSheet(t), tt.opts...))
+			written, err := arrow.Read(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+			require.NoError(t, err)
+			assert.Equal(t, columnartest.ReadAll(t, written), referenceFileRows(t, buf.Bytes()))
+
+			buf.Reset()
+			require.NoError(t, arrow.WriteStream(context.Background(), &buf, columnartest.NewSheet(t), tt.opts...))
+			written, err = arrow.ReadStream(bytes.NewReader(buf.Bytes()))
+			require.NoError(t, err)
+			assert.Equal(t, columnartest.ReadAll(t, written), referenceStreamRows(t, buf.Bytes()))
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package interop
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"github.com/apache/arrow/go/v14/parquet/file"
+	"github.com/apache/arrow/go/v14/parquet/pqarrow"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mmihic/sheets/src/pkg/sheets"
+	"github.com/mmihic/sheets/src/pkg/sheets/internal/columnar"
+	"github.com/mmihic/sheets/src/pkg/sheets/internal/columnar/columnartest"
+	"github.com/mmihic/sheets/src/pkg/sheets/parquet"
+)
+
+// parquetReferenceRows reads a file with the Parquet reader of the Apache Arrow Go
+// implementation.
+func parquetReferenceRows(t *testing.T, data []byte) [][]sheets.Value {
+	rdr, err := file.NewParquetReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	defer rdr.Close()
+
+	fr, err := pqarrow.NewFileReader(rdr, pqarrow.ArrowReadProperties{}, memory.DefaultAllocator)
+	require.NoError(t, err)
+
+	tbl, err := fr.ReadTable(context.Background())
+	require.NoError(t, err)
+	defer tbl.Release()
+
+	rows, err := tableRows(tbl)
+	require.NoError(t, err)
+	return rows
+}
+
+func TestParquet_ReadReferenceFiles(t *testing.T) {
+	day := func(y int, m time.Month, d int) sheets.Value {
+		return sheets.TimeValue(time.Date(y, m, d, 0, 0, 0, 0, time.UTC))
+	}
+
+	for _, tt := range []struct {
+		name string
+
+		// cells holds the expected values of a few cells, keyed by address.
+		cells map[string]sheets.Value
+	}{
+		{"alltypes_plain.parquet", map[string]sheets.Value{
+			"A1":  sheets.StringValue("id"),
+			"A2":  sheets.Float64Value(4),
+			"B2":  sheets.BoolValue(true),
+			"F3":  sheets.Float64Value(10),
+			"G3":  columnar.Float32(1.1),
+			"J3":  sheets.StringValue("1"),
+			"K2":  sheets.TimeValue(time.Date(2009, time.March, 1, 0, 0, 0, 0, time.UTC)),
+			"A9":  sheets.Float64Value(1),
+			"A10": nil,
+		}},
+		{"alltypes_plain.snappy.parquet", map[string]sheets.Value{
+			"A2": sheets.Float64Value(6),
+			"A3": sheets.Float64Value(7),
+			"A4": nil,
+		}},
+		{"alltypes_dictionary.parquet", map[string]sheets.Value{
+			"A2": sheets.Float64Value(0),
+			"A3": sheets.Float64Value(1),
+			"I3": sheets.StringValue("01/01/09"),
+		}},
+		{"int32_decimal.parquet", map[string]sheets.Value{
+			"A1":  sheets.StringValue("value"),
+			"A2":  sheets.Float64Value(1),
+			"A25": sheets.Float64Value(24),
+		}},
+		{"int64_decimal.parquet", map[string]sheets.Value{
+			"A2":  sheets.Float64Value(1),
+			"A25": sheets.Float64Value(24),
+		}},
+		{"fixed_length_decimal.parquet", map[string]sheets.Value{
+			"A2":  sheets.Float64Value(1),
+			"A25": sheets.Float64Value(24),
+		}},
+		{"byte_array_decimal.parquet", map[string]sheets.Value{
+			"A2":  sheets.Float64Value(1),
+			"A25": sheets.Float64Value(24),
+		}},
+		{"nulls.snappy.parquet", map[string]sheets.Value{
+			"A1": sheets.StringValue("b_struct.b_c_int"),
+			"A2": sheets.BlankValue{},
+			"A9": sheets.BlankValue{},
+		}},
+		{"single_nan.parquet", map[string]sheets.Value{
+			"A1": sheets.StringValue("mycol"),
+			"A2": sheets.BlankValue{},
+		}},
+		{"arrow_go.snappy.parquet", map[string]sheets.Value{
+			"A1": sheets.StringValue("id"),
+			"G1": sheets.StringValue("address.city"),
+			"H1": sheets.StringValue("address.zip"),
+			"B2": sheets.StringValue("apple"),
+			"C2": sheets.TimeValue(time.Date(2024, time.March, 5, 12, 30, 0, 0, time.UTC)),
+			"D3": day(1969, time.July, 20),
+			"E3": sheets.Float64Value(-2.25),
+			"F3": sheets.BoolValue(false),
+			"B4": sheets.BlankValue{},
+			"G4": sheets.BlankValue{},
+			"E5": sheets.Float64Value(1234567.89),
+			"G5": sheets.BlankValue{},
+			"H5": sheets.Float64Value(10115),
+			"C6": sheets.TimeValue(time.Date(2024, time.February, 29, 23, 59, 59, 999000000, time.UTC)),
+			"G6": sheets.BlankValue{},
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("..", "..", "parquet", "testdata", tt.name))
+			require.NoError(t, err)
+
+			s, err := parquet.Read(bytes.NewReader(data), int64(len(data)))
+			require.NoError(t, err)
+
+			rows := columnartest.ReadAll(t, s)
+			assert.Equal(t, parquetReferenceRows(t, data), rows)
+
+			for addr, expected := range tt.cells {
+				pos, err := sheets.ParsePos(addr)
+				require.NoError(t, err)
+				if expected == nil {
+					assert.LessOrEqual(t, len(rows), pos.Row, addr)
+					continue
+				}
+
+				require.Greater(t, len(rows), pos.Row, addr)
+				assert.Equal(t, expected, rows[pos.Row][pos.Col], addr)
+			}
+		})
+	}
+}
+
+func TestParquet_WriteReadByReference(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		opts []parquet.WriteOption
+	}{
+		{"default", nil},
+		{"uncompressed", []parquet.WriteOption{parquet.WithCompression(parquet.Uncompressed)}},
+		{"gzip", []parquet.WriteOption{parquet.WithCompression(parquet.Gzip)}},
+		{"row groups", []parquet.WriteOption{parquet.WithRowGroupSize(2)}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, parquet.Write(context.Background(), &buf, columnartest.NewSheet(t), tt.opts...))
+
+			written, err := parquet.Read(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+			require.NoError(t, err)
+			assert.Equal(t, columnartest.ReadAll(t, written), parquetReferenceRows(t, buf.Bytes()))
+		})
+	}
+}
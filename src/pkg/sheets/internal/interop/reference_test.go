@@ -0,0 +1,177 @@
+// Package interop checks the Arrow and Parquet readers and writers against
+// the Apache Arrow Go implementation, converting the data it reads into
+// Values.
+package interop
+
+import (
+	"fmt"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/array"
+
+	"github.com/mmihic/sheets/src/pkg/sheets"
+	"github.com/mmihic/sheets/src/pkg/sheets/internal/columnar"
+)
+
+// A column is a column of values, named by its path.
+type column struct {
+	name   string
+	values []sheets.Value
+}
+
+// tableRows returns the rows of a table as they would be read as a sheet: a
+// row holding the names of the columns, with the fields of structs named by
+// their paths, followed by a row for each row of the table.
+func tableRows(tbl arrow.Table) ([][]sheets.Value, error) {
+	tr := array.NewTableReader(tbl, -1)
+	defer tr.Release()
+
+	var records []arrow.Record
+	for tr.Next() {
+		rec := tr.Record()
+		rec.Retain()
+		defer rec.Release()
+
+		records = append(records, rec)
+	}
+
+	return recordRows(tbl.Schema(), records...)
+}
+
+// recordRows returns the rows of a sequence of records with the given schema,
+// as they would be read as a sheet.
+func recordRows(schema *arrow.Schema, records ...arrow.Record) ([][]sheets.Value, error) {
+	var header []sheets.Value
+	for _, f := range schema.Fields() {
+		for _, name := range columnNames(f.Name, f.Type) {
+			header = append(header, sheets.StringValue(name))
+		}
+	}
+
+	rows := [][]sheets.Value{header}
+	for _, rec := range records {
+		var columns []column
+		for i, f := range schema.Fields() {
+			cols, err := flatten(f.Name, rec.Column(i), nil)
+			if err != nil {
+				return nil, err
+			}
+
+			columns = append(columns, cols...)
+		}
+
+		for i := 0; i < int(rec.NumRows()); i++ {
+			row := make([]sheets.Value, len(columns))
+			for j, c := range columns {
+				row[j] = c.values[i]
+			}
+
+			rows = append(rows, row)
+		}
+	}
+
+	return rows, nil
+}
+
+// columnNames returns the names of the columns of a field, which are the
+// paths of its leaves.
+func columnNames(name string, typ arrow.DataType) []string {
+	st, ok := typ.(*arrow.StructType)
+	if !ok {
+		return []string{name}
+	}
+
+	var names []string
+	for _, f := range st.Fields() {
+		names = append(names, columnNames(name+"."+f.Name, f.Type)...)
+	}
+
+	return names
+}
+
+// flatten returns the columns of an array, which are the leaves of structs.
+// Values are null where their parent is null.
+func flatten(name string, arr arrow.Array, parentNull func(i int) bool) ([]column, error) {
+	isNull := func(i int) bool {
+		return arr.IsNull(i) || (parentNull != nil && parentNull(i))
+	}
+
+	if st, ok := arr.(*array.Struct); ok {
+		var columns []column
+		for i, f := range st.DataType().(*arrow.StructType).Fields() {
+			cols, err := flatten(name+"."+f.Name, st.Field(i), isNull)
+			if err != nil {
+				return nil, err
+			}
+
+			columns = append(columns, cols...)
+		}
+
+		return columns, nil
+	}
+
+	values := make([]sheets.Value, arr.Len())
+	for i := range values {
+		if isNull(i) {
+			values[i] = sheets.BlankValue{}
+			continue
+		}
+
+		v, err := value(arr, i)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value in column '%s': %w", name, err)
+		}
+
+		values[i] = v
+	}
+
+	return []column{{name: name, values: values}}, nil
+}
+
+// value returns a value of an array, which is not null.
+func value(arr arrow.Array, i int) (sheets.Value, error) {
+	switch a := arr.(type) {
+	case *array.Int8:
+		return sheets.Float64Value(a.Value(i)), nil
+	case *array.Int16:
+		return sheets.Float64Value(a.Value(i)), nil
+	case *array.Int32:
+		return sheets.Float64Value(a.Value(i)), nil
+	case *array.Int64:
+		return sheets.Float64Value(a.Value(i)), nil
+	case *array.Uint8:
+		return sheets.Float64Value(a.Value(i)), nil
+	case *array.Uint16:
+		return sheets.Float64Value(a.Value(i)), nil
+	case *array.Uint32:
+		return sheets.Float64Value(a.Value(i)), nil
+	case *array.Uint64:
+		return sheets.Float64Value(a.Value(i)), nil
+	case *array.Float32:
+		return columnar.Float32(a.Value(i)), nil
+	case *array.Float64:
+		return sheets.Float64Value(a.Value(i)), nil
+	case *array.Boolean:
+		return sheets.BoolValue(a.Value(i)), nil
+	case *array.String:
+		return sheets.StringValue(a.Value(i)), nil
+	case *array.LargeString:
+		return sheets.StringValue(a.Value(i)), nil
+	case *array.Binary:
+		return sheets.StringValue(a.Value(i)), nil
+	case *array.Date32:
+		return columnar.Date(int64(a.Value(i))), nil
+	case *array.Date64:
+		return columnar.Timestamp(int64(a.Value(i)), arrow.Millisecond.Multiplier()), nil
+	case *array.Timestamp:
+		unit := a.DataType().(*arrow.TimestampType).Unit
+		return columnar.Timestamp(int64(a.Value(i)), unit.Multiplier()), nil
+	case *array.Decimal128:
+		scale := a.DataType().(*arrow.Decimal128Type).Scale
+		return columnar.Decimal(a.Value(i).BigInt(), int(scale)), nil
+	case *array.Dictionary:
+		return value(a.Dictionary(), a.GetValueIndex(i))
+	default:
+		return nil, fmt.Errorf("unsupported type %s", arr.DataType())
+	}
+}
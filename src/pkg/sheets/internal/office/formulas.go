@@ -0,0 +1,183 @@
+// Package office holds the parts of reading and writing spreadsheet files that
+// are shared by the XLSX and ODS formats.
+package office
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mmihic/sheets/src/pkg/sheets"
+)
+
+// A FormulaSyntax describes how a file format writes formulas.
+type FormulaSyntax struct {
+	// ArgumentSeparator separates the arguments of functions, and
+	// ColumnSeparator and RowSeparator the values of array constants.
+	ArgumentSeparator, ColumnSeparator, RowSeparator byte
+
+	// ReferenceStart and ReferenceEnd enclose references to cells.
+	ReferenceStart, ReferenceEnd string
+
+	// SheetMarker is written before the quoted name of the sheet of a
+	// reference, and SheetSeparator after it.
+	SheetMarker, SheetSeparator string
+
+	// CellMarker is written before each end of a reference.
+	CellMarker string
+
+	// FunctionPrefix is written before the names of the PrefixedFunctions.
+	FunctionPrefix    string
+	PrefixedFunctions map[string]bool
+
+	// True and False are written for booleans.
+	True, False string
+
+	// Time returns the text of a time constant.
+	Time func(t time.Time, settings sheets.CalcSettings) string
+}
+
+// Format returns the text of a formula, without a leading "=". Nested
+// expressions are always parenthesized, since formulas do not record the
+// parentheses they were written with.
+func (syn *FormulaSyntax) Format(f sheets.Formula, settings sheets.CalcSettings) string {
+	switch ft := f.(type) {
+	case *sheets.Constant:
+		return syn.constant(ft.Value, settings)
+	case *sheets.ArrayConstant:
+		var sb strings.Builder
+		sb.WriteByte('{')
+		for i, row := range ft.Rows {
+			if i != 0 {
+				sb.WriteByte(syn.RowSeparator)
+			}
+
+			for j, v := range row {
+				if j != 0 {
+					sb.WriteByte(syn.ColumnSeparator)
+				}
+
+				sb.WriteString(syn.constant(v, settings))
+			}
+		}
+
+		sb.WriteByte('}')
+		return sb.String()
+	case *sheets.CellReference:
+		return syn.ReferenceStart + syn.reference(ft.Sheet, ft.Pos.String()) + syn.ReferenceEnd
+	case *sheets.CellRangeReference:
+		return syn.ReferenceStart + syn.reference(ft.Sheet, ft.Range.String()) + syn.ReferenceEnd
+	case *sheets.FunctionCall:
+		var sb strings.Builder
+		if syn.PrefixedFunctions[ft.FunctionName] {
+			sb.WriteString(syn.FunctionPrefix)
+		}
+
+		sb.WriteString(ft.FunctionName)
+		sb.WriteByte('(')
+		for i, arg := range ft.Args {
+			if i != 0 {
+				sb.WriteByte(syn.ArgumentSeparator)
+			}
+
+			sb.WriteString(syn.Format(arg, settings))
+		}
+
+		sb.WriteByte(')')
+		return sb.String()
+	case *sheets.Expression:
+		return syn.operand(ft.Left, settings) + string(ft.Operator) + syn.operand(ft.Right, settings)
+	default:
+		return f.String()
+	}
+}
+
+func (syn *FormulaSyntax) operand(f sheets.Formula, settings sheets.CalcSettings) string {
+	if _, ok := f.(*sheets.Expression); ok {
+		return "(" + syn.Format(f, settings) + ")"
+	}
+
+	return syn.Format(f, settings)
+}
+
+func (syn *FormulaSyntax) constant(v sheets.Value, settings sheets.CalcSettings) string {
+	switch tv := v.(type) {
+	case sheets.StringValue:
+		return quoteText(string(tv), '"')
+	case sheets.Float64Value:
+		return strconv.FormatFloat(float64(tv), 'f', -1, 64)
+	case sheets.FormattedNumber:
+		return strconv.FormatFloat(tv.Number, 'f', -1, 64)
+	case sheets.TimeValue:
+		return syn.Time(time.Time(tv), settings)
+	case sheets.BoolValue:
+		if tv {
+			return syn.True
+		}
+
+		return syn.False
+	case sheets.ErrorValue:
+		return sheets.ErrorCode(tv.Err)
+	default:
+		return quoteText(v.String(), '"')
+	}
+}
+
+// reference returns a reference to the cells with the given address, such as
+// A1 or B2:C4, in the given sheet.
+func (syn *FormulaSyntax) reference(sheet, address string) string {
+	return syn.SheetPrefix(sheet) + syn.CellMarker +
+		strings.ReplaceAll(address, ":", ":"+syn.CellMarker)
+}
+
+// SheetPrefix returns the prefix of a reference to a cell in the given sheet,
+// or nothing for a reference to the current sheet.
+func (syn *FormulaSyntax) SheetPrefix(name string) string {
+	if name == "" {
+		return ""
+	}
+
+	return syn.SheetMarker + quoteText(name, '\'') + syn.SheetSeparator
+}
+
+// AbsoluteReference returns a reference to a range of cells in a sheet with
+// absolute rows and columns, such as 'Data'!$A$1:$B$4, as used by named
+// ranges.
+func (syn *FormulaSyntax) AbsoluteReference(sheet string, r sheets.Range) string {
+	parts := strings.Split(r.String(), ":")
+	for i, part := range parts {
+		split := strings.IndexAny(part, "0123456789")
+		if split <= 0 {
+			parts[i] = "$" + part
+		} else {
+			parts[i] = "$" + part[:split] + "$" + part[split:]
+		}
+	}
+
+	return syn.reference(sheet, strings.Join(parts, ":"))
+}
+
+func quoteText(s string, quote byte) string {
+	q := string(quote)
+	return q + strings.ReplaceAll(s, q, q+q) + q
+}
+
+// QuotedEnd returns the index following the quoted text starting at start,
+// where the quote is escaped by doubling it.
+func QuotedEnd(text string, start int) int {
+	quote := text[start]
+	for i := start + 1; i < len(text); i++ {
+		if text[i] != quote {
+			continue
+		}
+
+		if i+1 < len(text) && text[i+1] == quote {
+			i++
+			continue
+		}
+
+		return i + 1
+	}
+
+	return len(text)
+}
@@ -0,0 +1,100 @@
+package office
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mmihic/sheets/src/pkg/sheets"
+)
+
+// testSyntax uses a distinct marker for each part of the syntax, so that
+// each appears where it belongs.
+var testSyntax = &FormulaSyntax{
+	ArgumentSeparator: '~',
+	ColumnSeparator:   '^',
+	RowSeparator:      '|',
+	ReferenceStart:    "<",
+	ReferenceEnd:      ">",
+	SheetMarker:       "@",
+	SheetSeparator:    "#",
+	CellMarker:        "%",
+	FunctionPrefix:    "X.",
+	PrefixedFunctions: map[string]bool{"CONCAT": true},
+	True:              "yes",
+	False:             "no",
+	Time: func(t time.Time, settings sheets.CalcSettings) string {
+		return t.In(Location(settings)).Format("15:04")
+	},
+}
+
+func TestFormulaSyntax_Format(t *testing.T) {
+	for _, tt := range []struct {
+		text     string
+		expected string
+	}{
+		{"A1+B2", "<%A1>+<%B2>"},
+		{"SUM(A1:B4, 'My Sheet'!C3, `It's`!D:D)", "SUM(<%A1:%B4>~<@'My Sheet'#%C3>~<@'It''s'#%D:%D>)"},
+		{`CONCAT("say \"hi\"", TRUE, {1,2;3,FALSE})`, `X.CONCAT("say ""hi"""~yes~{1^2|3^no})`},
+		{"A1*B1+C1/2", "(<%A1>*<%B1>)+(<%C1>/2)"},
+		{"(A1+B1)*0.000001", "(<%A1>+<%B1>)*0.000001"},
+	} {
+		f, err := sheets.ParseFormula(tt.text)
+		require.NoError(t, err, tt.text)
+		assert.Equal(t, tt.expected, testSyntax.Format(f, sheets.CalcSettings{}), tt.text)
+	}
+}
+
+func TestFormulaSyntax_FormatConstants(t *testing.T) {
+	when := time.Date(2024, time.March, 5, 18, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		value    sheets.Value
+		settings sheets.CalcSettings
+		expected string
+	}{
+		{sheets.FormattedNumber{Number: 1234.5, Format: "#,##0.00"}, sheets.CalcSettings{}, "1234.5"},
+		{sheets.ErrorValue{Err: sheets.ErrDivideByZero}, sheets.CalcSettings{}, "#DIV/0!"},
+		{sheets.TimeValue(when), sheets.CalcSettings{}, "18:00"},
+		{sheets.TimeValue(when), sheets.CalcSettings{Location: time.FixedZone("", -6*3600)}, "12:00"},
+	} {
+		f := &sheets.Constant{Value: tt.value}
+		assert.Equal(t, tt.expected, testSyntax.Format(f, tt.settings), tt.expected)
+	}
+}
+
+func TestFormulaSyntax_AbsoluteReference(t *testing.T) {
+	for _, tt := range []struct {
+		sheet    string
+		r        string
+		expected string
+	}{
+		{"Data", "A1:B4", "@'Data'#%$A$1:%$B$4"},
+		{"It's", "C:C", "@'It''s'#%$C:%$C"},
+		{"Data", "2:5", "@'Data'#%$2:%$5"},
+		{"", "C2:C2", "%$C$2:%$C$2"},
+	} {
+		r, err := sheets.ParseRange(tt.r)
+		require.NoError(t, err)
+		assert.Equal(t, tt.expected, testSyntax.AbsoluteReference(tt.sheet, r), tt.r)
+	}
+
+	assert.Equal(t, "", testSyntax.SheetPrefix(""))
+	assert.Equal(t, "@'Data'#", testSyntax.SheetPrefix("Data"))
+}
+
+func TestQuotedEnd(t *testing.T) {
+	for _, tt := range []struct {
+		text     string
+		start    int
+		expected int
+	}{
+		{`'Data'!A1`, 0, 6},
+		{`'It''s'!A1`, 0, 7},
+		{`SUM("a""b", 1)`, 4, 10},
+		{`"unterminated`, 0, 13},
+	} {
+		assert.Equal(t, tt.expected, QuotedEnd(tt.text, tt.start), tt.text)
+	}
+}
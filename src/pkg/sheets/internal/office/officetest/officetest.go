@@ -0,0 +1,73 @@
+// Package officetest holds a workbook for testing the XLSX and ODS writers.
+package officetest
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/mmihic/sheets/src/pkg/sheets"
+)
+
+// NewWorkbook returns a workbook holding a "Data" sheet with values of every
+// type and formulas referring to them, and a "Q1's Summary" sheet with
+// formulas referring to the Data sheet and to the Totals named range. The
+// names in A2 and A3 of the Data sheet are the given text, which should be
+// text the format needs to escape; C4 concatenates the second.
+func NewWorkbook(t testing.TB, name, otherName string) *sheets.Workbook {
+	wb := sheets.NewWorkbook()
+
+	data, err := sheets.NewMutableSheet([][]sheets.Value{
+		{
+			sheets.StringValue("name"), sheets.StringValue("price"),
+			sheets.StringValue("when"), sheets.StringValue("ok"),
+		},
+		{
+			sheets.StringValue(name),
+			sheets.FormattedNumber{Number: 1.5, Format: "#,##0.00"},
+			sheets.TimeValue(time.Date(2024, time.March, 5, 0, 0, 0, 0, time.UTC)),
+			sheets.BoolValue(true),
+		},
+		{
+			sheets.StringValue(otherName),
+			sheets.FormattedNumber{Number: 2.25, Format: "0.000"},
+			sheets.TimeValue(time.Date(2024, time.March, 5, 12, 30, 0, 0, time.UTC)),
+			sheets.BoolValue(false),
+		},
+		{
+			sheets.ErrorValue{Err: sheets.ErrDivideByZero},
+			sheets.StringValue("=SUM(B2:B3)"),
+			sheets.StringValue(`=CONCAT(A3, "say \"hi\"")`),
+			sheets.StringValue("=(B2+B3)*2"),
+		},
+	}, sheets.WithFormulas())
+	require.NoError(t, err)
+	require.NoError(t, wb.AddSheet("Data", data))
+
+	summary, err := sheets.NewMutableSheet([][]sheets.Value{
+		{
+			sheets.StringValue("=SUM(Totals)"),
+			sheets.StringValue("='Data'!B4*2"),
+			sheets.Float64Value(math.NaN()),
+			sheets.BlankValue{},
+			sheets.BlankValue{},
+			sheets.Float64Value(1e-7),
+		},
+		{sheets.BlankValue{}},
+		{sheets.BlankValue{}},
+		{sheets.StringValue("end")},
+	}, sheets.WithFormulas())
+	require.NoError(t, err)
+	require.NoError(t, wb.AddSheet("Q1's Summary", summary))
+
+	totals, err := sheets.ParseRange("B2:B3")
+	require.NoError(t, err)
+	wb.SetNamedRange("Totals", sheets.NamedRange{Sheet: "Data", Range: totals})
+
+	elsewhere, err := sheets.ParseRange("A1:A1")
+	require.NoError(t, err)
+	wb.SetNamedRange("Elsewhere", sheets.NamedRange{Sheet: "Missing", Range: elsewhere})
+	return wb
+}
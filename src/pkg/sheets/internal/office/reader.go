@@ -0,0 +1,93 @@
+package office
+
+import (
+	"context"
+
+	"github.com/mmihic/sheets/src/pkg/sheets"
+)
+
+// A Workbook is a workbook read from a file. It is a sheets.Workbook whose
+// sheets are MutableSheets holding the values and formulas of each sheet of
+// the file, along with the sheets as they were when the file was saved.
+type Workbook struct {
+	*sheets.Workbook
+
+	cached        map[string]sheets.Sheet
+	formulaErrors map[sheets.CellAddress]error
+}
+
+// NewWorkbook returns an empty Workbook.
+func NewWorkbook() *Workbook {
+	return &Workbook{
+		Workbook:      sheets.NewWorkbook(),
+		cached:        make(map[string]sheets.Sheet),
+		formulaErrors: make(map[sheets.CellAddress]error),
+	}
+}
+
+// CachedSheet returns the sheet with the given name as it was when the file
+// was saved, with each formula cell holding its cached result. Returns nil if
+// there is no such sheet.
+func (wb *Workbook) CachedSheet(name string) sheets.Sheet {
+	return wb.cached[name]
+}
+
+// FormulaErrors returns the formula cells whose formulas could not be
+// translated or parsed, and the reason for each. These cells hold their
+// cached results as values.
+func (wb *Workbook) FormulaErrors() map[sheets.CellAddress]error {
+	return wb.formulaErrors
+}
+
+// AddSheetValues adds a sheet holding the given values, which are the values
+// and cached results read from the file, and the formulas at the given
+// positions. Each formula is converted with parse; those that fail are
+// recorded as formula errors and keep their cached results.
+func (wb *Workbook) AddSheetValues(name string, values [][]sheets.Value, formulas map[sheets.Pos]string, parse func(text string) (sheets.Formula, error)) error {
+	cached, err := sheets.NewInMemorySheet(values)
+	if err != nil {
+		return err
+	}
+
+	live, err := sheets.NewMutableSheet(values)
+	if err != nil {
+		return err
+	}
+
+	if err := wb.AddSheet(name, live); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	for pos, text := range formulas {
+		f, err := parse(text)
+		if err != nil {
+			wb.formulaErrors[sheets.CellAddress{Sheet: name, Pos: pos}] = err
+			continue
+		}
+
+		if err := live.SetFormula(ctx, pos, f); err != nil {
+			return err
+		}
+	}
+
+	wb.cached[name] = cached
+	return nil
+}
+
+// SetValue sets a value in a matrix, growing it as needed and filling any
+// gaps with blanks.
+func SetValue(values [][]sheets.Value, pos sheets.Pos, v sheets.Value) [][]sheets.Value {
+	for len(values) <= pos.Row {
+		values = append(values, nil)
+	}
+
+	row := values[pos.Row]
+	for len(row) <= pos.Col {
+		row = append(row, sheets.BlankValue{})
+	}
+
+	row[pos.Col] = v
+	values[pos.Row] = row
+	return values
+}
@@ -0,0 +1,63 @@
+package office
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mmihic/sheets/src/pkg/sheets"
+)
+
+func TestWorkbook_AddSheetValues(t *testing.T) {
+	ctx := context.Background()
+	wb := NewWorkbook()
+
+	values := [][]sheets.Value{{sheets.Float64Value(2), sheets.Float64Value(5), sheets.Float64Value(9)}}
+	formulas := map[sheets.Pos]string{
+		{Row: 0, Col: 1}: "A1*2",
+		{Row: 0, Col: 2}: "BAD(",
+	}
+
+	require.NoError(t, wb.AddSheetValues("Data", values, formulas, func(text string) (sheets.Formula, error) {
+		if text == "BAD(" {
+			return nil, errors.New("invalid formula")
+		}
+
+		return sheets.ParseFormula(text)
+	}))
+
+	assert.Equal(t, []string{"Data"}, wb.SheetNames())
+	assert.Equal(t, map[sheets.CellAddress]error{
+		{Sheet: "Data", Pos: sheets.Pos{Row: 0, Col: 2}}: errors.New("invalid formula"),
+	}, wb.FormulaErrors())
+
+	// The live sheet computes formulas, and keeps cached results where they
+	// cannot be parsed
+	live := wb.Sheet("Data")
+	for col, expected := range []sheets.Value{sheets.Float64Value(2), sheets.Float64Value(4), sheets.Float64Value(9)} {
+		v, err := live.Get(ctx, sheets.Pos{Row: 0, Col: col})
+		require.NoError(t, err)
+		assert.Equal(t, expected, v)
+	}
+
+	v, err := wb.CachedSheet("Data").Get(ctx, sheets.Pos{Row: 0, Col: 1})
+	require.NoError(t, err)
+	assert.Equal(t, sheets.Float64Value(5), v)
+	assert.Nil(t, wb.CachedSheet("Missing"))
+
+	assert.Error(t, wb.AddSheetValues("Data", values, nil, sheets.ParseFormula))
+}
+
+func TestSetValue(t *testing.T) {
+	var values [][]sheets.Value
+	values = SetValue(values, sheets.Pos{Row: 1, Col: 2}, sheets.StringValue("a"))
+	values = SetValue(values, sheets.Pos{Row: 0, Col: 0}, sheets.Float64Value(1))
+	values = SetValue(values, sheets.Pos{Row: 1, Col: 0}, sheets.BoolValue(true))
+	assert.Equal(t, [][]sheets.Value{
+		{sheets.Float64Value(1)},
+		{sheets.BoolValue(true), sheets.BlankValue{}, sheets.StringValue("a")},
+	}, values)
+}
@@ -0,0 +1,220 @@
+package office
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mmihic/sheets/src/pkg/sheets"
+)
+
+// WriteFile creates a file at the given path and writes its contents with
+// write.
+func WriteFile(name string, write func(w io.Writer) error) (err error) {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	return write(f)
+}
+
+// WriteOptions are the options shared by the writers of each format, which
+// embed them in their own options.
+type WriteOptions struct {
+	SheetNames []string
+}
+
+func (o *WriteOptions) writeOptions() *WriteOptions { return o }
+
+// WithSheets returns an option that writes the sheets with the given names, in
+// order, for a format whose options embed WriteOptions.
+func WithSheets[T any, P interface {
+	*T
+	writeOptions() *WriteOptions
+}](names ...string) func(opts P) {
+	return func(opts P) {
+		opts.writeOptions().SheetNames = names
+	}
+}
+
+// A NamedSheet is a sheet to write, along with its name.
+type NamedSheet struct {
+	Name  string
+	Sheet sheets.Sheet
+}
+
+// A SheetNaming holds the rules a format has for naming sheets, beyond
+// rejecting empty names, names containing any of []:*?/\ or starting with a
+// quote, and names that differ only in case.
+type SheetNaming struct {
+	// MaxLength is the greatest number of characters in a name, or 0 if
+	// names can be of any length.
+	MaxLength int
+
+	// NoTrailingQuote rejects names ending with a quote.
+	NoTrailingQuote bool
+}
+
+// Validate returns an error if a sheet name cannot be used, or is used more
+// than once.
+func (n SheetNaming) Validate(names []string) error {
+	if len(names) == 0 {
+		return errors.New("there must be at least one sheet")
+	}
+
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		if name == "" || (n.MaxLength > 0 && utf8.RuneCountInString(name) > n.MaxLength) ||
+			strings.ContainsAny(name, `[]:*?/\`) || strings.HasPrefix(name, "'") ||
+			(n.NoTrailingQuote && strings.HasSuffix(name, "'")) {
+			return fmt.Errorf("invalid sheet name '%s'", name)
+		}
+
+		folded := strings.ToUpper(name)
+		if seen[folded] {
+			return fmt.Errorf("duplicate sheet name '%s'", name)
+		}
+
+		seen[folded] = true
+	}
+
+	return nil
+}
+
+// A sheetLister is a DataSet that lists its sheets.
+type sheetLister interface {
+	SheetNames() []string
+}
+
+// Sheets returns the sheets of a DataSet with the given names, in order, or
+// all of its sheets if names is nil. The names must follow the naming rules
+// of the format.
+func Sheets(ds sheets.DataSet, names []string, naming SheetNaming) ([]NamedSheet, error) {
+	if names == nil {
+		lister, ok := ds.(sheetLister)
+		if !ok {
+			return nil, errors.New("unable to list sheets of data set: use WithSheets")
+		}
+
+		names = lister.SheetNames()
+	}
+
+	if err := naming.Validate(names); err != nil {
+		return nil, err
+	}
+
+	named := make([]NamedSheet, 0, len(names))
+	for _, name := range names {
+		s := ds.Sheet(name)
+		if s == nil {
+			return nil, fmt.Errorf("no such sheet '%s'", name)
+		}
+
+		named = append(named, NamedSheet{Name: name, Sheet: s})
+	}
+
+	return named, nil
+}
+
+// A singleSheet is a DataSet holding a single sheet.
+type singleSheet struct {
+	name  string
+	sheet sheets.Sheet
+}
+
+// SingleSheet returns a DataSet holding a single sheet with the given name.
+// The DataSet does not list its sheets, so it is written using WithSheets.
+func SingleSheet(name string, s sheets.Sheet) sheets.DataSet {
+	return singleSheet{name: name, sheet: s}
+}
+
+func (ss singleSheet) Sheet(name string) sheets.Sheet {
+	if name != ss.name {
+		return nil
+	}
+
+	return ss.sheet
+}
+
+// An evaluatorSource is a DataSet that computes formulas with an Evaluator.
+type evaluatorSource interface {
+	Evaluator() *sheets.Evaluator
+}
+
+// CalcSettings returns the settings used to compute the formulas of a
+// DataSet, which are the defaults unless it computes them with an Evaluator.
+func CalcSettings(ds sheets.DataSet) sheets.CalcSettings {
+	if ev, ok := ds.(evaluatorSource); ok && ev.Evaluator() != nil {
+		return ev.Evaluator().CalcSettings
+	}
+
+	return sheets.CalcSettings{}
+}
+
+// Location returns the time zone of the settings, which defaults to UTC.
+func Location(settings sheets.CalcSettings) *time.Location {
+	if settings.Location == nil {
+		return time.UTC
+	}
+
+	return settings.Location
+}
+
+// A NamedRange is a named range of a DataSet, along with its name.
+type NamedRange struct {
+	Name string
+	sheets.NamedRange
+}
+
+// A namedRangeLister is a DataSet that lists its named ranges.
+type namedRangeLister interface {
+	sheets.NamedRangeResolver
+	NamedRangeNames() []string
+}
+
+// NamedRanges returns the named ranges of a DataSet that refer to the sheets
+// being written, since names referring to other sheets would be invalid.
+func NamedRanges(ds sheets.DataSet, written []NamedSheet) []NamedRange {
+	lister, ok := ds.(namedRangeLister)
+	if !ok {
+		return nil
+	}
+
+	isWritten := make(map[string]bool, len(written))
+	for _, s := range written {
+		isWritten[s.Name] = true
+	}
+
+	var ranges []NamedRange
+	for _, name := range lister.NamedRangeNames() {
+		named, ok := lister.NamedRange(name)
+		if ok && isWritten[named.Sheet] {
+			ranges = append(ranges, NamedRange{Name: name, NamedRange: named})
+		}
+	}
+
+	return ranges
+}
+
+// WritePart writes a part of a zip package.
+func WritePart(z *zip.Writer, name, content string) error {
+	w, err := z.Create(name)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, content)
+	return err
+}
@@ -0,0 +1,130 @@
+package office
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mmihic/sheets/src/pkg/sheets"
+)
+
+func newTestWorkbook(t *testing.T) *sheets.Workbook {
+	wb := sheets.NewWorkbook()
+	for _, name := range []string{"Data", "Other"} {
+		s, err := sheets.NewMutableSheet([][]sheets.Value{{sheets.Float64Value(1)}})
+		require.NoError(t, err)
+		require.NoError(t, wb.AddSheet(name, s))
+	}
+
+	return wb
+}
+
+func TestSheets(t *testing.T) {
+	wb := newTestWorkbook(t)
+	toWrite, err := Sheets(wb, nil, SheetNaming{})
+	require.NoError(t, err)
+	assert.Equal(t, []NamedSheet{
+		{Name: "Data", Sheet: wb.Sheet("Data")},
+		{Name: "Other", Sheet: wb.Sheet("Other")},
+	}, toWrite)
+
+	toWrite, err = Sheets(wb, []string{"Other"}, SheetNaming{})
+	require.NoError(t, err)
+	assert.Equal(t, []NamedSheet{{Name: "Other", Sheet: wb.Sheet("Other")}}, toWrite)
+
+	_, err = Sheets(wb, []string{"Data", "Missing"}, SheetNaming{})
+	assert.EqualError(t, err, "no such sheet 'Missing'")
+
+	_, err = Sheets(wb, nil, SheetNaming{MaxLength: 4})
+	assert.EqualError(t, err, "invalid sheet name 'Other'")
+
+	single := SingleSheet("Only", wb.Sheet("Data"))
+	_, err = Sheets(single, nil, SheetNaming{})
+	assert.EqualError(t, err, "unable to list sheets of data set: use WithSheets")
+
+	toWrite, err = Sheets(single, []string{"Only"}, SheetNaming{})
+	require.NoError(t, err)
+	assert.Equal(t, []NamedSheet{{Name: "Only", Sheet: wb.Sheet("Data")}}, toWrite)
+}
+
+func TestSheetNaming_Validate(t *testing.T) {
+	for _, tt := range []struct {
+		naming  SheetNaming
+		names   []string
+		wantErr string
+	}{
+		{SheetNaming{}, []string{"Data", "Q1's", "Totals'"}, ""},
+		{SheetNaming{}, nil, "there must be at least one sheet"},
+		{SheetNaming{}, []string{""}, "invalid sheet name ''"},
+		{SheetNaming{}, []string{"a/b"}, "invalid sheet name 'a/b'"},
+		{SheetNaming{}, []string{"'Data"}, "invalid sheet name ''Data'"},
+		{SheetNaming{}, []string{"Data", "DATA"}, "duplicate sheet name 'DATA'"},
+		{SheetNaming{MaxLength: 4}, []string{"Data"}, ""},
+		{SheetNaming{MaxLength: 4}, []string{"Datum"}, "invalid sheet name 'Datum'"},
+		{SheetNaming{MaxLength: 4}, []string{"Daté"}, ""},
+		{SheetNaming{NoTrailingQuote: true}, []string{"Totals'"}, "invalid sheet name 'Totals''"},
+	} {
+		err := tt.naming.Validate(tt.names)
+		if tt.wantErr == "" {
+			assert.NoError(t, err, "%v", tt.names)
+		} else {
+			assert.EqualError(t, err, tt.wantErr, "%v", tt.names)
+		}
+	}
+}
+
+type testOptions struct {
+	WriteOptions
+	other bool
+}
+
+func TestWithSheets(t *testing.T) {
+	var opts testOptions
+	WithSheets[testOptions]("Data", "Other")(&opts)
+	assert.Equal(t, testOptions{WriteOptions: WriteOptions{SheetNames: []string{"Data", "Other"}}}, opts)
+}
+
+func TestNamedRanges(t *testing.T) {
+	wb := newTestWorkbook(t)
+	r, err := sheets.ParseRange("A1:A4")
+	require.NoError(t, err)
+	wb.SetNamedRange("Totals", sheets.NamedRange{Sheet: "Data", Range: r})
+	wb.SetNamedRange("Elsewhere", sheets.NamedRange{Sheet: "Other", Range: r})
+
+	toWrite, err := Sheets(wb, []string{"Data"}, SheetNaming{})
+	require.NoError(t, err)
+
+	named := NamedRanges(wb, toWrite)
+	require.Len(t, named, 1)
+	assert.Equal(t, "Totals", named[0].Name)
+	assert.Equal(t, sheets.NamedRange{Sheet: "Data", Range: r}, named[0].NamedRange)
+
+	assert.Empty(t, NamedRanges(SingleSheet("Data", wb.Sheet("Data")), toWrite))
+}
+
+func TestCalcSettings(t *testing.T) {
+	wb := newTestWorkbook(t)
+	wb.SetDateSystem(sheets.Date1904)
+	assert.Equal(t, sheets.Date1904, CalcSettings(wb).DateSystem)
+	assert.Equal(t, sheets.CalcSettings{}, CalcSettings(SingleSheet("Data", wb.Sheet("Data"))))
+}
+
+func TestWriteFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "out.txt")
+	require.NoError(t, WriteFile(name, func(w io.Writer) error {
+		_, err := io.WriteString(w, "contents")
+		return err
+	}))
+
+	contents, err := os.ReadFile(name)
+	require.NoError(t, err)
+	assert.Equal(t, "contents", string(contents))
+
+	err = WriteFile(name, func(io.Writer) error { return errors.New("failed") })
+	assert.EqualError(t, err, "failed")
+}
@@ -0,0 +1,532 @@
+package sheets
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReadJSON reads a sheet from a JSON array of objects. See ReadNDJSON for how
+// objects are converted into rows.
+func ReadJSON(r io.Reader, opts ...SheetOption) (MutableSheet, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+
+	if err := expectDelim(dec, '['); err != nil {
+		return nil, err
+	}
+
+	jr := newJSONReader(opts)
+	for dec.More() {
+		if err := jr.readObject(dec); err != nil {
+			return nil, fmt.Errorf("invalid object %d: %w", len(jr.rows)+1, err)
+		}
+	}
+
+	if err := expectDelim(dec, ']'); err != nil {
+		return nil, err
+	}
+
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("unexpected data after JSON array")
+	}
+
+	return jr.sheet(opts)
+}
+
+// ReadNDJSON reads a sheet from newline-delimited JSON, with one object on
+// each line. Blank lines are skipped.
+//
+// The first row of the sheet holds the column names, which are the union of
+// the keys of all of the objects, in the order they first appear. The keys of
+// nested objects are joined to the keys of their parents with dots, so that
+// {"address": {"city": "Paris"}} has the column address.city. Each object is
+// then a row holding the values of its keys, with blanks for missing keys.
+// Nested objects that are null in some objects are treated as missing. It is
+// an error for a key to hold an object in one object and another value in
+// another, or for the keys of an object to join to the same column name, as
+// "address.city" and {"address": {"city": "Paris"}} do, since neither could
+// be written back.
+//
+// JSON values are converted to Values by type: numbers to Float64Values,
+// booleans to BoolValues, null to blanks, and arrays to text holding their
+// JSON. Strings are read as text, unless a TypeInferrer is passed
+// WithTypeInferrer, which converts them as it would CSV fields; the column
+// names are row 0. Pass WithFormulas to treat strings that start with "=" as
+// formulas.
+func ReadNDJSON(r io.Reader, opts ...SheetOption) (MutableSheet, error) {
+	jr := newJSONReader(opts)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, math.MaxInt32)
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+
+		dec := json.NewDecoder(bytes.NewReader(text))
+		dec.UseNumber()
+		if err := jr.readObject(dec); err != nil {
+			return nil, fmt.Errorf("invalid object on line %d: %w", line, err)
+		}
+
+		if dec.More() {
+			return nil, fmt.Errorf("invalid object on line %d: unexpected data after object", line)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return jr.sheet(opts)
+}
+
+// A jsonReader collects the rows and columns of a set of JSON objects.
+type jsonReader struct {
+	inferrer *TypeInferrer
+	columns  []string
+	index    map[string]int
+	rows     [][]Value
+
+	// valued records the columns that hold a value other than null in some
+	// object, which cannot also be the parents of nested objects.
+	valued []bool
+}
+
+func newJSONReader(opts []SheetOption) *jsonReader {
+	var options sheetOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return &jsonReader{
+		inferrer: options.inferrer,
+		index:    make(map[string]int),
+	}
+}
+
+// readObject reads an object as the next row.
+func (jr *jsonReader) readObject(dec *json.Decoder) error {
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+
+	row := make([]Value, len(jr.columns))
+	if err := jr.readFields(dec, "", &row); err != nil {
+		if errors.Is(err, io.EOF) {
+			return io.ErrUnexpectedEOF
+		}
+
+		return err
+	}
+
+	jr.rows = append(jr.rows, row)
+	return nil
+}
+
+// readFields reads the fields of an object whose opening brace has been read,
+// adding each to the row under its key joined to the given prefix.
+func (jr *jsonReader) readFields(dec *json.Decoder, prefix string, row *[]Value) error {
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+
+		key := prefix + tok.(string)
+		tok, err = dec.Token()
+		if err != nil {
+			return err
+		}
+
+		var v Value
+		switch tt := tok.(type) {
+		case json.Delim:
+			if tt == '{' {
+				if err := jr.readFields(dec, key+".", row); err != nil {
+					return err
+				}
+
+				continue
+			}
+
+			// Arrays are kept as their JSON text
+			var raw []json.RawMessage
+			for dec.More() {
+				var elem json.RawMessage
+				if err := dec.Decode(&elem); err != nil {
+					return err
+				}
+
+				raw = append(raw, elem)
+			}
+
+			if _, err := dec.Token(); err != nil {
+				return err
+			}
+
+			text, err := json.Marshal(raw)
+			if err != nil {
+				return err
+			}
+
+			if raw == nil {
+				text = []byte("[]")
+			}
+
+			v = StringValue(text)
+		case json.Number:
+			n, err := tt.Float64()
+			if err != nil {
+				return fmt.Errorf("invalid number '%s' for '%s'", tt, key)
+			}
+
+			v = Float64Value(n)
+		case bool:
+			v = BoolValue(tt)
+		case string:
+			v = StringValue(tt)
+			if jr.inferrer != nil {
+				col := jr.column(key)
+				if v, err = jr.inferrer.Infer(Pos{Row: len(jr.rows) + 1, Col: col}, tt); err != nil {
+					return err
+				}
+			}
+		case nil:
+			v = BlankValue{}
+		}
+
+		col := jr.column(key)
+		for len(*row) <= col {
+			*row = append(*row, nil)
+		}
+
+		if (*row)[col] != nil {
+			return fmt.Errorf("key '%s' conflicts with another key", key)
+		}
+
+		(*row)[col] = v
+		if tok != nil {
+			jr.valued[col] = true
+		}
+	}
+
+	// Consume the closing brace
+	_, err := dec.Token()
+	return err
+}
+
+// column returns the index of the column with the given name, adding it if
+// it has not been seen before.
+func (jr *jsonReader) column(name string) int {
+	col, ok := jr.index[name]
+	if !ok {
+		col = len(jr.columns)
+		jr.columns = append(jr.columns, name)
+		jr.valued = append(jr.valued, false)
+		jr.index[name] = col
+	}
+
+	return col
+}
+
+// sheet returns a sheet holding the column names followed by the rows.
+// Columns whose keys are the parents of nested objects, and so only ever
+// null, are left out.
+func (jr *jsonReader) sheet(opts []SheetOption) (MutableSheet, error) {
+	parents := make(map[string]bool)
+	for _, name := range jr.columns {
+		for i := range name {
+			if name[i] == '.' {
+				parents[name[:i]] = true
+			}
+		}
+	}
+
+	var (
+		keep   []int
+		header []Value
+	)
+
+	for col, name := range jr.columns {
+		if !parents[name] {
+			keep = append(keep, col)
+			header = append(header, StringValue(name))
+			continue
+		}
+
+		if jr.valued[col] {
+			return nil, fmt.Errorf("key '%s' holds both an object and a value", name)
+		}
+	}
+
+	values := make([][]Value, 0, len(jr.rows)+1)
+	values = append(values, header)
+	for _, row := range jr.rows {
+		kept := make([]Value, len(keep))
+		for i, col := range keep {
+			kept[i] = BlankValue{}
+			if col < len(row) && row[col] != nil {
+				kept[i] = row[col]
+			}
+		}
+
+		values = append(values, kept)
+	}
+
+	return newInMemorySheet(values, opts...)
+}
+
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	if tok != delim {
+		return fmt.Errorf("expected '%s', found '%v'", delim, tok)
+	}
+
+	return nil
+}
+
+// A JSONWriteOption is an option for writing a sheet as JSON.
+type JSONWriteOption func(opts *jsonWriteOptions)
+
+type jsonWriteOptions struct {
+	flatKeys bool
+}
+
+// WithFlatKeys writes column names containing dots as keys of the row
+// objects, rather than as paths to nested objects.
+func WithFlatKeys() JSONWriteOption {
+	return func(opts *jsonWriteOptions) {
+		opts.flatKeys = true
+	}
+}
+
+// WriteJSON writes the rows of a sheet as a JSON array of objects. See
+// WriteNDJSON for how rows are converted into objects.
+func WriteJSON(ctx context.Context, w io.Writer, s Sheet, opts ...JSONWriteOption) error {
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+
+	first := true
+	err := writeJSONRows(ctx, s, opts, func(obj []byte) error {
+		sep := ",\n"
+		if first {
+			sep, first = "\n", false
+		}
+
+		if _, err := io.WriteString(w, sep); err != nil {
+			return err
+		}
+
+		_, err := w.Write(obj)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	end := "\n]\n"
+	if first {
+		end = "]\n"
+	}
+
+	_, err = io.WriteString(w, end)
+	return err
+}
+
+// WriteNDJSON writes the rows of a sheet as newline-delimited JSON, with one
+// object on each line.
+//
+// The first row of the sheet holds the column names, and each following row
+// is written as an object whose keys are the column names, in order. Column
+// names containing dots are paths to nested objects, as read by ReadNDJSON,
+// unless WithFlatKeys is passed. Columns without a name are named after their
+// column, such as "C".
+//
+// Values are written by type: numbers as numbers, booleans as booleans,
+// blanks as null, times as RFC 3339 text, and errors as their Excel error
+// code, such as "#DIV/0!". Cells with formulas are written as their computed
+// values.
+func WriteNDJSON(ctx context.Context, w io.Writer, s Sheet, opts ...JSONWriteOption) error {
+	return writeJSONRows(ctx, s, opts, func(obj []byte) error {
+		if _, err := w.Write(obj); err != nil {
+			return err
+		}
+
+		_, err := io.WriteString(w, "\n")
+		return err
+	})
+}
+
+// writeJSONRows converts each row of a sheet after the first into a JSON
+// object, and passes it to the given function.
+func writeJSONRows(ctx context.Context, s Sheet, opts []JSONWriteOption, fn func(obj []byte) error) error {
+	var options jsonWriteOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	dims := s.Dimensions()
+	get := func(row, col int) (Value, error) {
+		v, err := s.Get(ctx, Pos{Row: row, Col: col})
+		if err != nil {
+			var posErr InvalidPosError
+			if !errors.As(err, &posErr) {
+				return nil, err
+			}
+
+			v = BlankValue{}
+		}
+
+		return v, nil
+	}
+
+	root := &jsonObject{}
+	paths := make([][]string, dims.EndCol+1)
+	for col := range paths {
+		v, err := get(0, col)
+		if err != nil {
+			return err
+		}
+
+		name := displayValue(v, nil, calcSettingsOf(s))
+		if name == "" {
+			name = columnToString(col)
+		}
+
+		paths[col] = []string{name}
+		if !options.flatKeys {
+			paths[col] = strings.Split(name, ".")
+		}
+
+		if err := root.add(name, paths[col]); err != nil {
+			return err
+		}
+	}
+
+	for row := 1; row <= dims.EndRow; row++ {
+		values := make(map[string][]byte, len(paths))
+		for col, path := range paths {
+			v, err := get(row, col)
+			if err != nil {
+				return err
+			}
+
+			text, err := jsonValue(v)
+			if err != nil {
+				return fmt.Errorf("invalid value at %s: %w", Pos{Row: row, Col: col}, err)
+			}
+
+			values[strings.Join(path, "\x00")] = text
+		}
+
+		var buf bytes.Buffer
+		root.write(&buf, nil, values)
+		if err := fn(buf.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// A jsonObject is the layout of the objects written for each row, with the
+// keys in the order of their columns.
+type jsonObject struct {
+	keys     []string
+	children map[string]*jsonObject
+}
+
+// add adds the column with the given name and path to the object, returning
+// an error if a value and an object would have the same key.
+func (o *jsonObject) add(name string, path []string) error {
+	if o.children == nil {
+		o.children = make(map[string]*jsonObject)
+	}
+
+	key := path[0]
+	child, exists := o.children[key]
+	switch {
+	case exists && (len(path) == 1 || child == nil):
+		return fmt.Errorf("column '%s' conflicts with another column", name)
+	case len(path) == 1:
+		o.keys = append(o.keys, key)
+		o.children[key] = nil
+		return nil
+	case !exists:
+		child = &jsonObject{}
+		o.keys = append(o.keys, key)
+		o.children[key] = child
+	}
+
+	return child.add(name, path[1:])
+}
+
+// write writes the object, taking the value of each column from the values
+// keyed by its path.
+func (o *jsonObject) write(buf *bytes.Buffer, prefix []string, values map[string][]byte) {
+	buf.WriteByte('{')
+	for i, key := range o.keys {
+		if i != 0 {
+			buf.WriteByte(',')
+		}
+
+		name, _ := json.Marshal(key)
+		buf.Write(name)
+		buf.WriteByte(':')
+
+		path := append(prefix[:len(prefix):len(prefix)], key)
+		if child := o.children[key]; child != nil {
+			child.write(buf, path, values)
+			continue
+		}
+
+		buf.Write(values[strings.Join(path, "\x00")])
+	}
+
+	buf.WriteByte('}')
+}
+
+// jsonValue returns the JSON form of a value.
+func jsonValue(v Value) ([]byte, error) {
+	switch tv := v.(type) {
+	case BlankValue:
+		return []byte("null"), nil
+	case Float64Value:
+		return jsonNumber(float64(tv)), nil
+	case FormattedNumber:
+		return jsonNumber(tv.Number), nil
+	case BoolValue:
+		return []byte(strconv.FormatBool(bool(tv))), nil
+	case TimeValue:
+		return json.Marshal(time.Time(tv).Format(time.RFC3339Nano))
+	case ErrorValue:
+		return json.Marshal(ErrorCode(tv.Err))
+	default:
+		return json.Marshal(v.String())
+	}
+}
+
+// jsonNumber returns the JSON form of a number, which is written as a #NUM!
+// error if JSON cannot represent it.
+func jsonNumber(n float64) []byte {
+	if math.IsNaN(n) || math.IsInf(n, 0) {
+		return []byte(`"#NUM!"`)
+	}
+
+	return []byte(strconv.FormatFloat(n, 'g', -1, 64))
+}
@@ -0,0 +1,272 @@
+package sheets
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const ndjsonOrders = `{"id": 1, "customer": {"name": "Ann", "address": {"city": "Paris"}}, "total": 10.5, "paid": true}
+
+{"id": 2, "customer": {"name": "Bob"}, "total": 4, "tags": ["new", 2], "note": null}
+{"id": 3, "total": "=D2+D3", "paid": false, "shipped": "2024-03-05"}
+`
+
+func TestReadNDJSON(t *testing.T) {
+	s, err := ReadNDJSON(strings.NewReader(ndjsonOrders))
+	require.NoError(t, err)
+	assert.Equal(t, Dimensions{EndRow: 3, EndCol: 7}, s.Dimensions())
+	assertCells(t, s, map[string]Value{
+		"A1": StringValue("id"),
+		"B1": StringValue("customer.name"),
+		"C1": StringValue("customer.address.city"),
+		"D1": StringValue("total"),
+		"E1": StringValue("paid"),
+		"F1": StringValue("tags"),
+		"G1": StringValue("note"),
+		"H1": StringValue("shipped"),
+		"A2": Float64Value(1),
+		"B2": StringValue("Ann"),
+		"C2": StringValue("Paris"),
+		"D2": Float64Value(10.5),
+		"E2": BoolValue(true),
+		"F2": BlankValue{},
+		"C3": BlankValue{},
+		"F3": StringValue(`["new",2]`),
+		"G3": BlankValue{},
+		"B4": BlankValue{},
+		"D4": StringValue("=D2+D3"),
+		"E4": BoolValue(false),
+		"H4": StringValue("2024-03-05"),
+	})
+}
+
+func TestReadNDJSON_Options(t *testing.T) {
+	s, err := ReadNDJSON(strings.NewReader(ndjsonOrders),
+		WithFormulas(), WithTypeInferrer(&TypeInferrer{HeaderRows: 1}))
+	require.NoError(t, err)
+	assertCells(t, s, map[string]Value{
+		"B1": StringValue("customer.name"),
+		"D4": Float64Value(14.5),
+		"H4": TimeValue(time.Date(2024, time.March, 5, 0, 0, 0, 0, time.UTC)),
+	})
+}
+
+func TestReadNDJSON_Invalid(t *testing.T) {
+	for _, tt := range []struct {
+		data     string
+		expected string
+	}{
+		{"{\"a\": 1}\n[1]", "invalid object on line 2: expected '{', found '['"},
+		{`{"a": 1} {"a": 2}`, "invalid object on line 1: unexpected data after object"},
+		{`{"a": 1e999}`, "invalid object on line 1: invalid number '1e999' for 'a'"},
+		{`{"a": `, "invalid object on line 1: unexpected EOF"},
+	} {
+		_, err := ReadNDJSON(strings.NewReader(tt.data))
+		assert.EqualError(t, err, tt.expected, tt.data)
+	}
+}
+
+func TestReadJSON(t *testing.T) {
+	s, err := ReadJSON(strings.NewReader(`[
+		{"name": "a", "size": {"w": 1, "h": 2}},
+		{"size": {"h": 3}, "name": "b", "extra": []}
+	]`))
+	require.NoError(t, err)
+	assert.Equal(t, Dimensions{EndRow: 2, EndCol: 3}, s.Dimensions())
+	assertCells(t, s, map[string]Value{
+		"A1": StringValue("name"),
+		"B1": StringValue("size.w"),
+		"C1": StringValue("size.h"),
+		"D1": StringValue("extra"),
+		"A3": StringValue("b"),
+		"B3": BlankValue{},
+		"C3": Float64Value(3),
+		"D3": StringValue("[]"),
+	})
+
+	s, err = ReadJSON(strings.NewReader(`[]`))
+	require.NoError(t, err)
+	assert.Equal(t, Dimensions{EndRow: 0, EndCol: 0}, s.Dimensions())
+
+	for _, tt := range []struct {
+		data     string
+		expected string
+	}{
+		{`{"a": 1}`, "expected '[', found '{'"},
+		{`[{"a": 1}, 2]`, "invalid object 2: expected '{', found '2'"},
+		{`[{"a": 1}] []`, "unexpected data after JSON array"},
+	} {
+		_, err := ReadJSON(strings.NewReader(tt.data))
+		assert.EqualError(t, err, tt.expected, tt.data)
+	}
+}
+
+func TestReadJSON_NullObjects(t *testing.T) {
+	data := `[
+		{"id": 1, "address": null},
+		{"id": 2, "address": {"city": "Paris", "geo": null}},
+		{"id": 3, "address": {"city": "Rome", "geo": {"lat": 41.9}}}
+	]`
+
+	s, err := ReadJSON(strings.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, Dimensions{EndRow: 3, EndCol: 2}, s.Dimensions())
+	assertCells(t, s, map[string]Value{
+		"A1": StringValue("id"),
+		"B1": StringValue("address.city"),
+		"C1": StringValue("address.geo.lat"),
+		"B2": BlankValue{},
+		"B3": StringValue("Paris"),
+		"C3": BlankValue{},
+		"C4": Float64Value(41.9),
+	})
+
+	// The sheet can be written, and reads back as the same sheet
+	var sb strings.Builder
+	require.NoError(t, WriteJSON(context.TODO(), &sb, s))
+	assert.Equal(t, `[
+{"id":1,"address":{"city":null,"geo":{"lat":null}}},
+{"id":2,"address":{"city":"Paris","geo":{"lat":null}}},
+{"id":3,"address":{"city":"Rome","geo":{"lat":41.9}}}
+]
+`, sb.String())
+
+	read, err := ReadJSON(strings.NewReader(sb.String()))
+	require.NoError(t, err)
+	assert.Equal(t, s.Dimensions(), read.Dimensions())
+
+	var rewritten strings.Builder
+	require.NoError(t, WriteJSON(context.TODO(), &rewritten, read))
+	assert.Equal(t, sb.String(), rewritten.String())
+}
+
+func TestReadJSON_ConflictingKeys(t *testing.T) {
+	for _, tt := range []struct {
+		data     string
+		expected string
+	}{
+		{
+			`[{"address": {"city": "Paris"}, "address.city": "Rome"}]`,
+			"invalid object 1: key 'address.city' conflicts with another key",
+		},
+		{
+			`[{"address.city": "Rome", "address": {"city": "Paris"}}]`,
+			"invalid object 1: key 'address.city' conflicts with another key",
+		},
+		{
+			`[{"a": 1, "a": null}]`,
+			"invalid object 1: key 'a' conflicts with another key",
+		},
+		{
+			`[{"address": "Paris"}, {"address": {"city": "Rome"}}]`,
+			"key 'address' holds both an object and a value",
+		},
+		{
+			`[{"a": {"b": {"c": 1}}}, {"a": {"b": false}}]`,
+			"key 'a.b' holds both an object and a value",
+		},
+	} {
+		_, err := ReadJSON(strings.NewReader(tt.data))
+		assert.EqualError(t, err, tt.expected, tt.data)
+	}
+
+	// Dotted keys in different objects share a column, written as a nested
+	// object
+	s, err := ReadJSON(strings.NewReader(`[{"address": {"city": "Paris"}}, {"address.city": "Rome"}]`))
+	require.NoError(t, err)
+
+	var sb strings.Builder
+	require.NoError(t, WriteJSON(context.TODO(), &sb, s))
+	assert.Equal(t, "[\n{\"address\":{\"city\":\"Paris\"}},\n{\"address\":{\"city\":\"Rome\"}}\n]\n", sb.String())
+
+	read, err := ReadJSON(strings.NewReader(sb.String()))
+	require.NoError(t, err)
+	assert.Equal(t, s.Dimensions(), read.Dimensions())
+
+	var rewritten strings.Builder
+	require.NoError(t, WriteJSON(context.TODO(), &rewritten, read))
+	assert.Equal(t, sb.String(), rewritten.String())
+}
+
+func TestWriteNDJSON(t *testing.T) {
+	s, err := NewInMemorySheet([][]Value{
+		{
+			StringValue("id"), StringValue("customer.name"), StringValue("customer.address.city"),
+			StringValue("when"), BlankValue{}, StringValue("ok"),
+		},
+		{
+			Float64Value(1), StringValue(`Ann "A"`), StringValue("Paris"),
+			TimeValue(time.Date(2024, time.March, 5, 12, 30, 0, 0, time.UTC)),
+			FormattedNumber{Number: 1250, Format: "#,##0.00"}, BoolValue(true),
+		},
+		{
+			Float64Value(math.Inf(1)), BlankValue{}, BlankValue{},
+			ErrorValue{Err: ErrDivideByZero}, Float64Value(1e-7),
+		},
+		{StringValue("=A2*2")},
+	}, WithFormulas())
+	require.NoError(t, err)
+
+	var sb strings.Builder
+	require.NoError(t, WriteNDJSON(context.TODO(), &sb, s))
+	assert.Equal(t, strings.Join([]string{
+		`{"id":1,"customer":{"name":"Ann \"A\"","address":{"city":"Paris"}},"when":"2024-03-05T12:30:00Z","E":1250,"ok":true}`,
+		`{"id":"#NUM!","customer":{"name":null,"address":{"city":null}},"when":"#DIV/0!","E":1e-07,"ok":null}`,
+		`{"id":2,"customer":{"name":null,"address":{"city":null}},"when":null,"E":null,"ok":null}`,
+		"",
+	}, "\n"), sb.String())
+
+	// Nested objects read back as the same columns
+	read, err := ReadNDJSON(strings.NewReader(sb.String()))
+	require.NoError(t, err)
+	assertCells(t, read, map[string]Value{
+		"B1": StringValue("customer.name"),
+		"C1": StringValue("customer.address.city"),
+		"B2": StringValue(`Ann "A"`),
+	})
+
+	sb.Reset()
+	require.NoError(t, WriteNDJSON(context.TODO(), &sb, s, WithFlatKeys()))
+	assert.True(t, strings.HasPrefix(sb.String(),
+		`{"id":1,"customer.name":"Ann \"A\"","customer.address.city":"Paris",`), sb.String())
+}
+
+func TestWriteJSON(t *testing.T) {
+	s, err := ReadJSON(strings.NewReader(`[{"a": 1, "b": {"c": "x"}}, {"a": 2}]`))
+	require.NoError(t, err)
+
+	var sb strings.Builder
+	require.NoError(t, WriteJSON(context.TODO(), &sb, s))
+	assert.Equal(t, "[\n{\"a\":1,\"b\":{\"c\":\"x\"}},\n{\"a\":2,\"b\":{\"c\":null}}\n]\n", sb.String())
+
+	empty, err := NewInMemorySheet([][]Value{{StringValue("a")}})
+	require.NoError(t, err)
+
+	sb.Reset()
+	require.NoError(t, WriteJSON(context.TODO(), &sb, empty))
+	assert.Equal(t, "[]\n", sb.String())
+}
+
+func TestWriteJSON_ConflictingColumns(t *testing.T) {
+	for _, header := range [][]Value{
+		{StringValue("a"), StringValue("a.b")},
+		{StringValue("a.b"), StringValue("a")},
+		{StringValue("a.b"), StringValue("a.b")},
+	} {
+		s, err := NewInMemorySheet([][]Value{header})
+		require.NoError(t, err)
+
+		err = WriteJSON(context.TODO(), &strings.Builder{}, s)
+		assert.Error(t, err)
+
+		// Flat keys only conflict when they are the same
+		err = WriteJSON(context.TODO(), &strings.Builder{}, s, WithFlatKeys())
+		assert.Equal(t, header[0] == header[1], err != nil)
+	}
+}
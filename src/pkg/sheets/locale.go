@@ -0,0 +1,383 @@
+package sheets
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mmihic/sheets/src/pkg/sheets/internal/formula"
+)
+
+// A Locale describes how formulas and values are written in a particular
+// language: the separators used in numbers, function arguments and array
+// constants, the names of the boolean values, and the names of functions.
+//
+// Formulas are always stored with canonical (English) function names, so a
+// formula parsed in one locale can be printed in any other.
+type Locale struct {
+	Name                 string
+	DecimalSeparator     rune
+	GroupSeparator       rune
+	ArgumentSeparator    rune
+	ArrayColumnSeparator rune
+	ArrayRowSeparator    rune
+	True                 string
+	False                string
+
+	// Functions maps upper-case localized function names to their canonical
+	// names. Functions without an entry have the same name in every locale.
+	Functions map[string]string
+}
+
+var (
+	// EnglishLocale is the locale used by English versions of Excel, and is
+	// the default.
+	EnglishLocale = &Locale{
+		Name:                 "en",
+		DecimalSeparator:     '.',
+		GroupSeparator:       ',',
+		ArgumentSeparator:    ',',
+		ArrayColumnSeparator: ',',
+		ArrayRowSeparator:    ';',
+		True:                 "TRUE",
+		False:                "FALSE",
+	}
+
+	// GermanLocale is the locale used by German versions of Excel.
+	GermanLocale = &Locale{
+		Name:                 "de",
+		DecimalSeparator:     ',',
+		GroupSeparator:       '.',
+		ArgumentSeparator:    ';',
+		ArrayColumnSeparator: '.',
+		ArrayRowSeparator:    ';',
+		True:                 "WAHR",
+		False:                "FALSCH",
+		Functions: map[string]string{
+			"ANZAHL":            "COUNT",
+			"ANZAHL2":           "COUNTA",
+			"ANZAHLLEEREZELLEN": "COUNTBLANK",
+			"DATUM":             "DATE",
+			"HEUTE":             "TODAY",
+			"ISTLEER":           "ISBLANK",
+			"JAHR":              "YEAR",
+			"JETZT":             "NOW",
+			"LINKS":             "LEFT",
+			"LÄNGE":             "LEN",
+			"MITTELWERT":        "AVERAGE",
+			"MONAT":             "MONTH",
+			"NICHT":             "NOT",
+			"ODER":              "OR",
+			"RECHTS":            "RIGHT",
+			"RUNDEN":            "ROUND",
+			"SUMME":             "SUM",
+			"SUMMEWENN":         "SUMIF",
+			"SVERWEIS":          "VLOOKUP",
+			"TAG":               "DAY",
+			"TEXTKETTE":         "CONCAT",
+			"UND":               "AND",
+			"VERGLEICH":         "MATCH",
+			"VERKETTEN":         "CONCATENATE",
+			"WENN":              "IF",
+			"WENNFEHLER":        "IFERROR",
+			"WERT":              "VALUE",
+			"WVERWEIS":          "HLOOKUP",
+			"ZÄHLENWENN":        "COUNTIF",
+			"ZUFALLSZAHL":       "RAND",
+		},
+	}
+
+	// FrenchLocale is the locale used by French versions of Excel.
+	FrenchLocale = &Locale{
+		Name:                 "fr",
+		DecimalSeparator:     ',',
+		GroupSeparator:       '\u00a0',
+		ArgumentSeparator:    ';',
+		ArrayColumnSeparator: '.',
+		ArrayRowSeparator:    ';',
+		True:                 "VRAI",
+		False:                "FAUX",
+		Functions: map[string]string{
+			"ALEA":       "RAND",
+			"ANNEE":      "YEAR",
+			"ARRONDI":    "ROUND",
+			"AUJOURDHUI": "TODAY",
+			"CNUM":       "VALUE",
+			"CONCATENER": "CONCATENATE",
+			"DROITE":     "RIGHT",
+			"EQUIV":      "MATCH",
+			"ESTVIDE":    "ISBLANK",
+			"ET":         "AND",
+			"GAUCHE":     "LEFT",
+			"JOUR":       "DAY",
+			"MAINTENANT": "NOW",
+			"MOIS":       "MONTH",
+			"MOYENNE":    "AVERAGE",
+			"NB":         "COUNT",
+			"NB.SI":      "COUNTIF",
+			"NB.VIDE":    "COUNTBLANK",
+			"NBCAR":      "LEN",
+			"NBVAL":      "COUNTA",
+			"NON":        "NOT",
+			"OU":         "OR",
+			"RECHERCHEH": "HLOOKUP",
+			"RECHERCHEV": "VLOOKUP",
+			"SI":         "IF",
+			"SIERREUR":   "IFERROR",
+			"SOMME":      "SUM",
+			"SOMME.SI":   "SUMIF",
+			"TEXTE":      "TEXT",
+		},
+	}
+)
+
+// ParseFormulaIn parses a formula written for the given locale. Dates and
+// times in the formula without an explicit offset are in UTC.
+func ParseFormulaIn(s string, l *Locale) (Formula, error) {
+	return parseFormulaIn(s, l, time.UTC)
+}
+
+// parseFormulaIn parses a formula written for the given locale, treating dates
+// and times without an explicit offset as wall-clock times in the given time
+// zone.
+func parseFormulaIn(s string, l *Locale, tz *time.Location) (Formula, error) {
+	lex, err := formula.LexStringWith(s, l.syntax())
+	if err != nil {
+		return nil, err
+	}
+
+	return parseLexedFormula(lex, l, tz)
+}
+
+// FormatFormula returns a formula as it would be written in the locale.
+func (l *Locale) FormatFormula(f Formula) string {
+	var localized map[string]string
+	for name, canonical := range l.Functions {
+		if localized == nil {
+			localized = make(map[string]string, len(l.Functions))
+		}
+
+		localized[canonical] = name
+	}
+
+	return l.formatFormula(f, localized)
+}
+
+func (l *Locale) formatFormula(f Formula, localized map[string]string) string {
+	switch ft := f.(type) {
+	case *Constant:
+		return l.formatConstant(ft.Value)
+	case *ArrayConstant:
+		var sb strings.Builder
+		sb.WriteRune('{')
+		for i, row := range ft.Rows {
+			if i != 0 {
+				sb.WriteRune(l.ArrayRowSeparator)
+			}
+
+			for j, v := range row {
+				if j != 0 {
+					sb.WriteRune(l.ArrayColumnSeparator)
+				}
+
+				sb.WriteString(l.formatConstant(v))
+			}
+		}
+
+		sb.WriteRune('}')
+		return sb.String()
+	case *FunctionCall:
+		name := ft.FunctionName
+		if l, ok := localized[name]; ok {
+			name = l
+		}
+
+		var sb strings.Builder
+		sb.WriteString(name)
+		sb.WriteRune('(')
+		for i, arg := range ft.Args {
+			if i != 0 {
+				sb.WriteRune(l.ArgumentSeparator)
+				sb.WriteRune(' ')
+			}
+
+			sb.WriteString(l.formatFormula(arg, localized))
+		}
+
+		sb.WriteRune(')')
+		return sb.String()
+	case *Expression:
+		return fmt.Sprintf("%s %s %s",
+			l.formatOperand(ft.Left, localized), ft.Operator, l.formatOperand(ft.Right, localized))
+	default:
+		return f.String()
+	}
+}
+
+// formatOperand formats an operand of an expression, parenthesizing nested
+// expressions so that they keep their grouping when parsed.
+func (l *Locale) formatOperand(f Formula, localized map[string]string) string {
+	if _, ok := f.(*Expression); ok {
+		return "(" + l.formatFormula(f, localized) + ")"
+	}
+
+	return l.formatFormula(f, localized)
+}
+
+func (l *Locale) formatConstant(v Value) string {
+	switch tv := v.(type) {
+	case Float64Value:
+		return l.localizeNumber(strconv.FormatFloat(float64(tv), 'g', -1, 64))
+	case FormattedNumber:
+		return l.localizeNumber(strconv.FormatFloat(tv.Number, 'g', -1, 64))
+	case BoolValue:
+		return l.FormatValue(tv)
+	default:
+		return (&Constant{Value: v}).String()
+	}
+}
+
+// FormatValue returns a value as it would be displayed in the locale.
+func (l *Locale) FormatValue(v Value) string {
+	switch tv := v.(type) {
+	case Float64Value, FormattedNumber:
+		n, _ := tv.ToFloat64()
+		return l.localizeNumber(ExcelNumerics.Format(n))
+	case BoolValue:
+		if tv {
+			return l.True
+		}
+
+		return l.False
+	default:
+		return v.String()
+	}
+}
+
+// ParseValue converts text written in the locale into a Value, in the same way
+// as StringToValue.
+func (l *Locale) ParseValue(s string) Value {
+	return l.parseValue(s, DefaultTimeParser, time.UTC)
+}
+
+// parseValue converts text written in the locale into a Value, parsing times
+// with the given parser and treating those without an explicit offset as
+// wall-clock times in the given time zone.
+func (l *Locale) parseValue(s string, parser *TimeParser, tz *time.Location) Value {
+	if tm, err := parser.ParseIn(s, tz); err == nil {
+		return TimeValue(tm)
+	}
+
+	if b, err := l.parseBool(s); err == nil {
+		return BoolValue(b)
+	}
+
+	if n, _, err := l.ParseNumber(s); err == nil {
+		return Float64Value(n)
+	}
+
+	return StringValue(s)
+}
+
+// ParseNumber parses a number written in the locale, understanding the same
+// currency symbols, percentages, thousands separators and accounting negatives
+// as the package-level ParseNumber. The returned format is a canonical Excel
+// number format, which always uses "." and "," as its separators.
+func (l *Locale) ParseNumber(s string) (float64, string, error) {
+	canonical, ok := l.canonicalNumber(s)
+	if !ok {
+		return 0, "", ValueErrorf("'%s' is not a valid number", s)
+	}
+
+	if n, err := strconv.ParseFloat(canonical, 64); err == nil {
+		return n, "", nil
+	}
+
+	n, format, err := parseNumber(canonical)
+	if err != nil {
+		return 0, "", ValueErrorf("'%s' is not a valid number", s)
+	}
+
+	return n, format, nil
+}
+
+// canonicalNumber converts the decimal and group separators of a number
+// written in the locale to "." and ",". Returns false if the number uses a
+// separator that the locale does not.
+func (l *Locale) canonicalNumber(s string) (string, bool) {
+	if l.hasEnglishSeparators() {
+		return s, true
+	}
+
+	valid := true
+	canonical := strings.Map(func(r rune) rune {
+		switch r {
+		case l.DecimalSeparator:
+			return '.'
+		case l.GroupSeparator:
+			return ','
+		case '.', ',':
+			valid = false
+			return r
+		default:
+			return r
+		}
+	}, s)
+
+	return canonical, valid
+}
+
+func (l *Locale) hasEnglishSeparators() bool {
+	return l.DecimalSeparator == '.' && l.GroupSeparator == ','
+}
+
+// parseBool parses a boolean written in the locale, or in English.
+func (l *Locale) parseBool(s string) (bool, error) {
+	switch {
+	case strings.EqualFold(s, l.True):
+		return true, nil
+	case strings.EqualFold(s, l.False):
+		return false, nil
+	default:
+		return ParseBool(s)
+	}
+}
+
+func (l *Locale) localizeNumber(s string) string {
+	if l.DecimalSeparator == '.' {
+		return s
+	}
+
+	return strings.ReplaceAll(s, ".", string(l.DecimalSeparator))
+}
+
+// canonicalFunctionName returns the canonical name of a localized function.
+func (l *Locale) canonicalFunctionName(name string) string {
+	name = strings.ToUpper(name)
+	if canonical, ok := l.Functions[name]; ok {
+		return canonical
+	}
+
+	return name
+}
+
+func (l *Locale) syntax() formula.Syntax {
+	return formula.Syntax{
+		DecimalSeparator:     l.DecimalSeparator,
+		ArgumentSeparator:    l.ArgumentSeparator,
+		ArrayColumnSeparator: l.ArrayColumnSeparator,
+		ArrayRowSeparator:    l.ArrayRowSeparator,
+		True:                 l.True,
+		False:                l.False,
+	}
+}
+
+// localeOrDefault returns the locale, or the EnglishLocale if it is nil.
+func localeOrDefault(l *Locale) *Locale {
+	if l == nil {
+		return EnglishLocale
+	}
+
+	return l
+}
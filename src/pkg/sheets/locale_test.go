@@ -0,0 +1,240 @@
+package sheets
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFormulaIn(t *testing.T) {
+	for _, tt := range []struct {
+		locale      *Locale
+		input       string
+		expected    Formula
+		expectedErr string
+	}{
+		{GermanLocale, "SUMME(A1;B1;1,5)", &FunctionCall{
+			FunctionName: "SUM",
+			Args: []Formula{
+				&CellReference{Pos: mustParsePos(t, "A1")},
+				&CellReference{Pos: mustParsePos(t, "B1")},
+				&Constant{Float64Value(1.5)},
+			},
+		}, ""},
+		{GermanLocale, "wenn(A1 >= 0,5; Wahr; FALSCH)", &FunctionCall{
+			FunctionName: "IF",
+			Args: []Formula{
+				&Expression{
+					Left:     &CellReference{Pos: mustParsePos(t, "A1")},
+					Right:    &Constant{Float64Value(0.5)},
+					Operator: ">=",
+				},
+				&Constant{BoolValue(true)},
+				&Constant{BoolValue(false)},
+			},
+		}, ""},
+		{GermanLocale, "{1,5.2;3.-4}", &ArrayConstant{
+			Rows: [][]Value{
+				{Float64Value(1.5), Float64Value(2)},
+				{Float64Value(3), Float64Value(-4)},
+			},
+		}, ""},
+		{FrenchLocale, "NB.VIDE(A1:A3)", &FunctionCall{
+			FunctionName: "COUNTBLANK",
+			Args: []Formula{
+				&CellRangeReference{Range: mustParseRange(t, "A1:A3")},
+			},
+		}, ""},
+		{FrenchLocale, "VRAI", &Constant{BoolValue(true)}, ""},
+
+		// Functions without a translation keep their name
+		{GermanLocale, "MAX(1;2)", &FunctionCall{
+			FunctionName: "MAX",
+			Args: []Formula{
+				&Constant{Float64Value(1)},
+				&Constant{Float64Value(2)},
+			},
+		}, ""},
+
+		// English separators are not valid in other locales
+		{GermanLocale, "SUMME(A1, B1)", nil, `1:9: invalid input text ", B1)"`},
+		{GermanLocale, "SUMME(A1{B1)", nil, "error at 1:9: expected one of [;, )]: found '{' ({)"},
+	} {
+		t.Run(tt.locale.Name+"/"+tt.input, func(t *testing.T) {
+			f, err := ParseFormulaIn(tt.input, tt.locale)
+			if tt.expectedErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectedErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, f)
+		})
+	}
+}
+
+func TestLocale_FormatFormula(t *testing.T) {
+	for _, tt := range []struct {
+		german  string
+		english string
+	}{
+		{"SUMME(A1; B1; 1,5)", "SUM(A1, B1, 1.5)"},
+		{"WENN(A1 >= 0,25; WAHR; FALSCH)", "IF(A1 >= 0.25, TRUE, FALSE)"},
+		{"MITTELWERT({1,5.2;3.4}; Sheet1!A:A)", "AVERAGE({1.5,2;3,4}, `Sheet1`!A:A)"},
+		{`VERKETTEN("a;b"; MAX(1; 2))`, `CONCATENATE("a;b", MAX(1, 2))`},
+	} {
+		t.Run(tt.english, func(t *testing.T) {
+			fromGerman, err := ParseFormulaIn(tt.german, GermanLocale)
+			require.NoError(t, err)
+
+			fromEnglish, err := ParseFormula(tt.english)
+			require.NoError(t, err)
+
+			assert.Equal(t, fromEnglish, fromGerman)
+			assert.Equal(t, tt.english, EnglishLocale.FormatFormula(fromGerman))
+			assert.Equal(t, tt.english, fromGerman.String())
+
+			// The German form, with sheet names quoted, round trips
+			german := GermanLocale.FormatFormula(fromEnglish)
+			reparsed, err := ParseFormulaIn(german, GermanLocale)
+			require.NoError(t, err)
+			assert.Equal(t, fromEnglish, reparsed)
+			assert.Equal(t, german, GermanLocale.FormatFormula(reparsed))
+			assert.Equal(t, strings.ReplaceAll(tt.german, "Sheet1!", "`Sheet1`!"), german)
+		})
+	}
+}
+
+func TestLocale_FormatFormulaRoundTrip(t *testing.T) {
+	for _, tt := range []struct {
+		formula  string
+		expected string
+	}{
+		{"(1,5+2)*3", "(1,5 + 2) * 3"},
+		{"1,5+2*3", "1,5 + (2 * 3)"},
+		{"SUMME((A1-B1)/2; 4^(1/2))", "SUMME((A1 - B1) / 2; 4 ^ (1 / 2))"},
+		{"(A1+B1)>=\"x;y\"", "(A1 + B1) >= \"x;y\""},
+	} {
+		t.Run(tt.formula, func(t *testing.T) {
+			f, err := ParseFormulaIn(tt.formula, GermanLocale)
+			require.NoError(t, err)
+
+			formatted := GermanLocale.FormatFormula(f)
+			assert.Equal(t, tt.expected, formatted)
+
+			reparsed, err := ParseFormulaIn(formatted, GermanLocale)
+			require.NoError(t, err)
+			assert.Equal(t, f, reparsed)
+		})
+	}
+}
+
+func TestLocale_ParseValue(t *testing.T) {
+	for _, tt := range []struct {
+		locale   *Locale
+		input    string
+		expected Value
+	}{
+		{GermanLocale, "1,5", Float64Value(1.5)},
+		{GermanLocale, "1.234.567,89", Float64Value(1234567.89)},
+		{GermanLocale, "1.234,5 €", Float64Value(1234.5)},
+		{GermanLocale, "-12,5%", Float64Value(-0.125)},
+		{GermanLocale, "wahr", BoolValue(true)},
+		{GermanLocale, "false", BoolValue(false)},
+		{GermanLocale, "1.5", StringValue("1.5")},
+		{GermanLocale, "1.234", Float64Value(1234)},
+		{FrenchLocale, "1 234,5", Float64Value(1234.5)},
+		{FrenchLocale, "faux", BoolValue(false)},
+		{EnglishLocale, "1,234.5", Float64Value(1234.5)},
+		{EnglishLocale, "hello", StringValue("hello")},
+	} {
+		t.Run(tt.locale.Name+"/"+tt.input, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.locale.ParseValue(tt.input))
+		})
+	}
+}
+
+func TestLocale_ParseNumber(t *testing.T) {
+	n, format, err := GermanLocale.ParseNumber("(1.234,50)")
+	require.NoError(t, err)
+	assert.Equal(t, -1234.5, n)
+	assert.Equal(t, "#,##0.00;(#,##0.00)", format)
+
+	_, _, err = GermanLocale.ParseNumber("1,234.5")
+	assert.EqualError(t, err, "'1,234.5' is not a valid number")
+}
+
+func TestLocale_FormatValue(t *testing.T) {
+	for _, tt := range []struct {
+		locale   *Locale
+		value    Value
+		expected string
+	}{
+		{GermanLocale, Float64Value(1234.5), "1234,5"},
+		{GermanLocale, Float64Value(add(0.1, 0.2)), "0,3"},
+		{GermanLocale, BoolValue(true), "WAHR"},
+		{FrenchLocale, BoolValue(false), "FAUX"},
+		{FrenchLocale, StringValue("texte"), "texte"},
+		{EnglishLocale, Float64Value(1234.5), "1234.5"},
+	} {
+		t.Run(tt.locale.Name+"/"+tt.expected, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.locale.FormatValue(tt.value))
+		})
+	}
+}
+
+func TestWithLocale(t *testing.T) {
+	ctx := context.TODO()
+	s, err := ReadCSV(strings.NewReader(`"1,5","2,5","=SUMME(A1:B1;1)"
+wahr,1.000,"=MITTELWERT(A1;B1;ANZAHL(A1:B2);1)"
+`), WithFormulas(), WithLocale(GermanLocale))
+	require.NoError(t, err)
+
+	for _, tt := range []struct {
+		pos      string
+		expected Value
+	}{
+		{"A1", Float64Value(1.5)},
+		{"C1", Float64Value(5)},
+		{"A2", BoolValue(true)},
+		{"B2", Float64Value(1000)},
+		{"C2", Float64Value(2)},
+	} {
+		v, err := s.Get(ctx, mustParsePos(t, tt.pos))
+		require.NoError(t, err)
+		assert.Equal(t, tt.expected, v, tt.pos)
+	}
+}
+
+func TestWithLocale_InferredSchema(t *testing.T) {
+	ctx := context.TODO()
+	s, err := ReadCSV(strings.NewReader(`"1.234,5",wahr,"1,5"
+"-2,25",FALSCH,x
+`), WithTypeInferrer(&TypeInferrer{Locale: GermanLocale, SampleRows: 2}))
+	require.NoError(t, err)
+
+	for _, tt := range []struct {
+		pos      string
+		expected Value
+	}{
+		{"A1", Float64Value(1234.5)},
+		{"A2", Float64Value(-2.25)},
+		{"B1", BoolValue(true)},
+		{"B2", BoolValue(false)},
+		{"C1", StringValue("1,5")},
+	} {
+		v, err := s.Get(ctx, mustParsePos(t, tt.pos))
+		require.NoError(t, err)
+		assert.Equal(t, tt.expected, v, tt.pos)
+	}
+
+	assert.Equal(t, map[int]ColumnSpec{
+		0: {Type: NumberColumn},
+		1: {Type: BoolColumn},
+		2: {Type: TextColumn},
+	}, inferSchema([][]string{{"1.234,5", "wahr", "1,5"}, {"-2,25", "FALSCH", "x"}}, 0, 0, GermanLocale, DefaultTimeParser))
+}
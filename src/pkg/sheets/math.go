@@ -25,14 +25,82 @@ const (
 	Neq      Operator = "<>"
 )
 
+// CalcSettings control how values are compared and computed. The zero value
+// follows Excel.
+type CalcSettings struct {
+	// Collator is used to compare text. If nil, the ExcelCollator is used.
+	Collator Collator
+
+	// NumericMode controls how numbers are compared and computed.
+	NumericMode NumericMode
+
+	// DateSystem controls how times are converted to and from serial numbers.
+	DateSystem DateSystem
+
+	// Location is the time zone in which serial numbers are wall-clock times.
+	// If nil, UTC is used.
+	Location *time.Location
+}
+
+func (s CalcSettings) collator() Collator {
+	if s.Collator == nil {
+		return ExcelCollator
+	}
+
+	return s.Collator
+}
+
+func (s CalcSettings) location() *time.Location {
+	if s.Location == nil {
+		return time.UTC
+	}
+
+	return s.Location
+}
+
+// toExcelTime converts a time to a serial number in the date system and
+// location.
+func (s CalcSettings) toExcelTime(tm time.Time) float64 {
+	return s.DateSystem.ToExcelTimeIn(tm, s.location())
+}
+
+// toFloat64 converts a value to a number, converting times to serial numbers
+// in the date system and location.
+// text returns a value as text, displaying numbers in the numeric mode.
+func (s CalcSettings) text(v Value) string {
+	switch tv := v.(type) {
+	case Float64Value:
+		return s.NumericMode.Format(float64(tv))
+	case FormattedNumber:
+		return s.NumericMode.Format(tv.Number)
+	default:
+		return v.String()
+	}
+}
+
+func (s CalcSettings) toFloat64(v Value) (float64, error) {
+	if tm, ok := v.(TimeValue); ok {
+		return s.toExcelTime(time.Time(tm)), nil
+	}
+
+	return v.ToFloat64()
+}
+
 // Apply applies the operator to two values, returning the results of the operator.
+// Values are compared and computed using the default CalcSettings.
 func (op Operator) Apply(v1, v2 Value) Value {
+	return op.ApplyWith(v1, v2, CalcSettings{})
+}
+
+// ApplyWith applies the operator to two values, comparing and computing them
+// using the given settings.
+func (op Operator) ApplyWith(v1, v2 Value, settings CalcSettings) Value {
 	if ok := op.isArithmetic(); ok {
-		return op.applyArithmetic(v1, v2)
+		return op.applyArithmetic(v1, v2, settings)
 	}
 
 	if ok := op.isComparison(); ok {
-		cmpResult, err := op.compare(v1, v2)
+		cmpResult, err := compareValues(v1, v2, settings)
 		if err != nil {
 			return ErrorValue{err}
 		}
@@ -64,7 +132,7 @@ func (op Operator) applyComparison(cmpResult int) Value {
 	}
 }
 
-func (op Operator) applyArithmetic(v1, v2 Value) Value {
+func (op Operator) applyArithmetic(v1, v2 Value, settings CalcSettings) Value {
 	// If either value is an error, return the error
 	if errVal, ok := v1.(ErrorValue); ok {
 		return errVal
@@ -74,21 +142,22 @@ func (op Operator) applyArithmetic(v1, v2 Value) Value {
 		return errVal
 	}
 
-	n1, err := v1.ToFloat64()
+	n1, err := settings.toFloat64(v1)
 	if err != nil {
 		return ErrorValue{err}
 	}
 
-	n2, err := v2.ToFloat64()
+	n2, err := settings.toFloat64(v2)
 	if err != nil {
 		return ErrorValue{err}
 	}
 
+	mode := settings.NumericMode
 	switch op {
 	case Add:
-		return Float64Value(n1 + n2)
+		return Float64Value(mode.add(n1, n2))
 	case Subtract:
-		return Float64Value(n1 - n2)
+		return Float64Value(mode.add(n1, -n2))
 	case Divide:
 		if n2 == 0 {
 			return ErrorValue{ErrDivideByZero}
@@ -120,16 +189,27 @@ func (op Operator) isArithmetic() bool {
 	}
 }
 
-func (op Operator) compare(v1, v2 Value) (int, error) {
+func compareValues(v1, v2 Value, settings CalcSettings) (int, error) {
+	// Blank cells compare as the empty version of the value they're compared to
+	if _, ok := v1.(BlankValue); ok {
+		v1 = blankAs(v2)
+	}
+
+	if _, ok := v2.(BlankValue); ok {
+		v2 = blankAs(v1)
+	}
+
 	switch tv1 := v1.(type) {
 	case Float64Value:
-		return op.compareFloats(float64(tv1), v2)
+		return compareFloats(float64(tv1), v2, settings)
+	case FormattedNumber:
+		return compareFloats(tv1.Number, v2, settings)
 	case StringValue:
-		return op.compareStrings(string(tv1), v2)
+		return compareStrings(string(tv1), v2, settings.collator())
 	case TimeValue:
-		return op.compareFloats(ToExcelTime(time.Time(tv1)), v2)
+		return compareFloats(settings.toExcelTime(time.Time(tv1)), v2, settings)
 	case BoolValue:
-		return op.compareBools(bool(tv1), v2)
+		return compareBools(bool(tv1), v2)
 	case ErrorValue:
 		return 0, tv1.Err
 	default:
@@ -140,11 +220,19 @@ func (op Operator) compare(v1, v2 Value) (int, error) {
 	}
 }
 
-type ordered interface {
-	string | float64
+// blankAs returns the value a blank cell takes when compared to another value.
+func blankAs(other Value) Value {
+	switch other.(type) {
+	case StringValue:
+		return StringValue("")
+	case BoolValue:
+		return BoolValue(false)
+	default:
+		return Float64Value(0)
+	}
 }
 
-func compare[T ordered](v1, v2 T) int {
+func compare(v1, v2 float64) int {
 	if v1 == v2 {
 		return 0
 	}
@@ -156,14 +244,17 @@ func compare[T ordered](v1, v2 T) int {
 	return 1
 }
 
-func (op Operator) compareFloats(n1 float64, v2 Value) (int, error) {
+func compareFloats(n1 float64, v2 Value, settings CalcSettings) (int, error) {
+	mode := settings.NumericMode
 	switch tv2 := v2.(type) {
 	case StringValue, BoolValue:
 		return -1, nil
 	case TimeValue:
-		return compare(n1, ToExcelTime(time.Time(tv2))), nil
+		return mode.compare(n1, settings.toExcelTime(time.Time(tv2))), nil
 	case Float64Value:
-		return compare(n1, float64(tv2)), nil
+		return mode.compare(n1, float64(tv2)), nil
+	case FormattedNumber:
+		return mode.compare(n1, tv2.Number), nil
 	case ErrorValue:
 		return 0, tv2.Err
 	default:
@@ -174,14 +265,14 @@ func (op Operator) compareFloats(n1 float64, v2 Value) (int, error) {
 	}
 }
 
-func (op Operator) compareStrings(s1 string, v2 Value) (int, error) {
+func compareStrings(s1 string, v2 Value, c Collator) (int, error) {
 	switch tv2 := v2.(type) {
-	case Float64Value, TimeValue:
+	case Float64Value, FormattedNumber, TimeValue:
 		return 1, nil
 	case BoolValue:
 		return -1, nil
 	case StringValue:
-		return compare(s1, string(tv2)), nil
+		return c.Compare(s1, string(tv2)), nil
 	case ErrorValue:
 		return 0, tv2.Err
 	default:
@@ -192,9 +283,9 @@ func (op Operator) compareStrings(s1 string, v2 Value) (int, error) {
 	}
 }
 
-func (op Operator) compareBools(b1 bool, v2 Value) (int, error) {
+func compareBools(b1 bool, v2 Value) (int, error) {
 	switch tv2 := v2.(type) {
-	case Float64Value, StringValue, TimeValue:
+	case Float64Value, FormattedNumber, StringValue, TimeValue:
 		return 1, nil
 	case BoolValue:
 		b2 := bool(tv2)
@@ -52,6 +52,22 @@ func TestMath(t *testing.T) {
 				Subtract: ErrorValue{ValueErrorf("unable to convert 'A' to float")},
 				Divide:   ErrorValue{ValueErrorf("unable to convert 'A' to float")},
 			}},
+		{"string against same string in different case",
+			StringValue("apple"), StringValue("APPLE"), operators{
+				Eq:  BoolValue(true),
+				Neq: BoolValue(false),
+				Gt:  BoolValue(false),
+				Geq: BoolValue(true),
+				Lt:  BoolValue(false),
+				Leq: BoolValue(true),
+			}},
+		{"lower case string against greater upper case string",
+			StringValue("a"), StringValue("B"), operators{
+				Eq:  BoolValue(false),
+				Neq: BoolValue(true),
+				Gt:  BoolValue(false),
+				Lt:  BoolValue(true),
+			}},
 		{"string against float",
 			StringValue("A"), Float64Value(10.5), operators{
 				Eq:       BoolValue(false),
@@ -317,6 +333,49 @@ func TestMath(t *testing.T) {
 				Subtract: ErrorValue{ValueErrorf("bad!")},
 				Divide:   ErrorValue{ValueErrorf("bad!")},
 			}},
+		{"formatted number against float",
+			FormattedNumber{Number: 0.15, Format: "0%"}, Float64Value(0.15), operators{
+				Eq:       BoolValue(true),
+				Add:      Float64Value(0.3),
+				Multiply: Float64Value(0.0225),
+			}},
+		{"text number against float",
+			StringValue("$1,234.50"), Float64Value(2), operators{
+				Add:      Float64Value(1236.5),
+				Multiply: Float64Value(2469),
+			}},
+		{"blank against float",
+			BlankValue{}, Float64Value(2), operators{
+				Eq:       BoolValue(false),
+				Lt:       BoolValue(true),
+				Add:      Float64Value(2),
+				Multiply: Float64Value(0),
+				Subtract: Float64Value(-2),
+				Divide:   Float64Value(0),
+			}},
+		{"blank against zero",
+			BlankValue{}, Float64Value(0), operators{
+				Eq:     BoolValue(true),
+				Divide: ErrorValue{ErrDivideByZero},
+			}},
+		{"blank against empty string",
+			BlankValue{}, StringValue(""), operators{
+				Eq: BoolValue(true),
+			}},
+		{"string against blank",
+			StringValue("a"), BlankValue{}, operators{
+				Eq: BoolValue(false),
+				Gt: BoolValue(true),
+			}},
+		{"blank against false",
+			BlankValue{}, BoolValue(false), operators{
+				Eq: BoolValue(true),
+			}},
+		{"blank against blank",
+			BlankValue{}, BlankValue{}, operators{
+				Eq:  BoolValue(true),
+				Add: Float64Value(0),
+			}},
 		{
 			"error against bool",
 			ErrorValue{ValueErrorf("bad!")}, BoolValue(true), operators{
@@ -0,0 +1,237 @@
+package sheets
+
+import (
+	"context"
+	"fmt"
+	"slices"
+)
+
+// A MutableSheet is a Sheet whose cells can be modified.
+//
+// Structural edits (inserting or deleting rows and columns) rewrite the
+// references of every formula stored in the sheet in the same way as Excel:
+// references beyond the edit point move, ranges that span the edit point
+// grow or shrink, and references to deleted cells become #REF errors.
+type MutableSheet interface {
+	FormulaSheet
+
+	// Set sets the value of a cell, replacing any formula in that cell. If
+	// the sheet was created WithFormulas, text starting with "=" is parsed
+	// and set as the formula for the cell.
+	Set(ctx context.Context, pos Pos, v Value) error
+
+	// SetFormula sets the formula for a cell. The value of the cell is
+	// computed from the formula.
+	SetFormula(ctx context.Context, pos Pos, f Formula) error
+
+	// InsertRows inserts n empty rows before the given row.
+	InsertRows(ctx context.Context, row, n int) error
+
+	// DeleteRows deletes n rows, starting at the given row.
+	DeleteRows(ctx context.Context, row, n int) error
+
+	// InsertCols inserts n empty columns before the given column.
+	InsertCols(ctx context.Context, col, n int) error
+
+	// DeleteCols deletes n columns, starting at the given column.
+	DeleteCols(ctx context.Context, col, n int) error
+
+	// Clear removes the values and formulas from all cells in the range.
+	Clear(ctx context.Context, r Range) error
+}
+
+// NewMutableSheet creates an in-memory sheet that can be modified, starting
+// from a two-dimensional matrix of values. The values are copied, so changes
+// to the sheet are not reflected in the matrix.
+func NewMutableSheet(values [][]Value, opts ...SheetOption) (MutableSheet, error) {
+	copied := make([][]Value, len(values))
+	for i, row := range values {
+		copied[i] = slices.Clone(row)
+	}
+
+	s, err := newInMemorySheet(copied, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *inMemorySheet) Set(ctx context.Context, pos Pos, v Value) error {
+	if pos.Row < 0 || pos.Col < 0 {
+		return InvalidPosError{pos}
+	}
+
+	f, ok, err := s.parseFormulaValue(v)
+	if err != nil {
+		return fmt.Errorf("invalid formula at %s: %w", pos, err)
+	}
+
+	if ok {
+		return s.SetFormula(ctx, pos, f)
+	}
+
+	s.removeFormula(pos)
+	s.setValue(pos, v)
+	s.workbook.invalidate(CellAddress{Sheet: s.name, Pos: pos}, false)
+	return nil
+}
+
+func (s *inMemorySheet) SetFormula(_ context.Context, pos Pos, f Formula) error {
+	if pos.Row < 0 || pos.Col < 0 {
+		return InvalidPosError{pos}
+	}
+
+	if s.formulas == nil {
+		s.formulas = make(map[Pos]*formulaCell)
+	}
+
+	s.removeFormula(pos)
+
+	cell := &formulaCell{formula: f}
+	s.formulas[pos] = cell
+	s.setValue(pos, nil)
+	s.workbook.trackFormula(s, pos, cell)
+	return nil
+}
+
+func (s *inMemorySheet) Clear(_ context.Context, r Range) error {
+	r, ok := r.Resolve(s.dims)
+	if !ok {
+		return nil
+	}
+
+	for pos, hasNext := r.StartPos(), true; hasNext; pos, hasNext = r.NextPos(pos) {
+		s.removeFormula(pos)
+
+		if row := s.values[pos.Row]; pos.Col < len(row) {
+			row[pos.Col] = nil
+		}
+	}
+
+	for pos, hasNext := r.StartPos(), true; hasNext; pos, hasNext = r.NextPos(pos) {
+		s.workbook.invalidate(CellAddress{Sheet: s.name, Pos: pos}, false)
+	}
+
+	return nil
+}
+
+func (s *inMemorySheet) InsertRows(_ context.Context, row, n int) error {
+	if err := validateStructuralEdit("row", row, n); err != nil {
+		return err
+	}
+
+	if row < len(s.values) {
+		s.values = slices.Insert(s.values, row, make([][]Value, n)...)
+	}
+
+	s.applyStructuralEdit(structuralEdit{axis: rowAxis, at: row, count: n})
+	return nil
+}
+
+func (s *inMemorySheet) DeleteRows(_ context.Context, row, n int) error {
+	if err := validateStructuralEdit("row", row, n); err != nil {
+		return err
+	}
+
+	if row < len(s.values) {
+		s.values = slices.Delete(s.values, row, clampEnd(row+n, len(s.values)))
+	}
+
+	s.applyStructuralEdit(structuralEdit{axis: rowAxis, at: row, count: -n})
+	return nil
+}
+
+func (s *inMemorySheet) InsertCols(_ context.Context, col, n int) error {
+	if err := validateStructuralEdit("column", col, n); err != nil {
+		return err
+	}
+
+	for i, row := range s.values {
+		if col < len(row) {
+			s.values[i] = slices.Insert(row, col, make([]Value, n)...)
+		}
+	}
+
+	s.applyStructuralEdit(structuralEdit{axis: colAxis, at: col, count: n})
+	return nil
+}
+
+func (s *inMemorySheet) DeleteCols(_ context.Context, col, n int) error {
+	if err := validateStructuralEdit("column", col, n); err != nil {
+		return err
+	}
+
+	for i, row := range s.values {
+		if col < len(row) {
+			s.values[i] = slices.Delete(row, col, clampEnd(col+n, len(row)))
+		}
+	}
+
+	s.applyStructuralEdit(structuralEdit{axis: colAxis, at: col, count: -n})
+	return nil
+}
+
+// setValue sets the value at the given position, growing the sheet if needed.
+func (s *inMemorySheet) setValue(pos Pos, v Value) {
+	for len(s.values) <= pos.Row {
+		s.values = append(s.values, nil)
+	}
+
+	row := s.values[pos.Row]
+	for len(row) <= pos.Col {
+		row = append(row, nil)
+	}
+
+	row[pos.Col] = v
+	s.values[pos.Row] = row
+
+	if pos.Row > s.dims.EndRow {
+		s.dims.EndRow = pos.Row
+	}
+
+	if pos.Col > s.dims.EndCol {
+		s.dims.EndCol = pos.Col
+	}
+}
+
+// applyStructuralEdit updates the dimensions of the sheet after an edit, and
+// has the workbook move the formulas in the sheet and rewrite the references in
+// all formulas that refer to it.
+func (s *inMemorySheet) applyStructuralEdit(edit structuralEdit) {
+	s.updateDims()
+	s.workbook.applyStructuralEdit(s, edit)
+}
+
+// removeFormula removes the formula from a cell, if it has one.
+func (s *inMemorySheet) removeFormula(pos Pos) {
+	if _, ok := s.formulas[pos]; ok {
+		delete(s.formulas, pos)
+		s.workbook.untrackFormula(CellAddress{Sheet: s.name, Pos: pos})
+	}
+}
+
+// clampEnd clamps the end of a slice range to the length of the slice.
+func clampEnd(end, length int) int {
+	if end > length {
+		return length
+	}
+
+	return end
+}
+
+func validateStructuralEdit(kind string, at, n int) error {
+	if at < 0 {
+		return fmt.Errorf("invalid %s %d", kind, at)
+	}
+
+	if n < 0 {
+		return fmt.Errorf("invalid number of %ss %d", kind, n)
+	}
+
+	return nil
+}
+
+var (
+	_ MutableSheet = &inMemorySheet{}
+)
@@ -0,0 +1,192 @@
+package sheets
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMutableSheet_Set(t *testing.T) {
+	s, err := NewMutableSheet(matrix)
+	require.NoError(t, err)
+
+	require.NoError(t, s.Set(context.TODO(), mustParsePos(t, "C2"), StringValue("cherry")))
+	require.NoError(t, s.Set(context.TODO(), mustParsePos(t, "G5"), Float64Value(12)))
+	assert.Equal(t, Dimensions{EndRow: 4, EndCol: 6}, s.Dimensions())
+
+	assertCells(t, s, map[string]Value{
+		"C2": StringValue("cherry"),
+		"G5": Float64Value(12),
+		"F4": BlankValue{},
+		"A1": StringValue("cat"),
+	})
+
+	// The original matrix is left untouched
+	assert.Len(t, matrix[1], 2)
+
+	_, err = s.Get(context.TODO(), mustParsePos(t, "H5"))
+	require.Error(t, err)
+
+	err = s.Set(context.TODO(), Pos{Row: -1, Col: 2}, StringValue("bad"))
+	require.Error(t, err)
+}
+
+func TestMutableSheet_SetFormula(t *testing.T) {
+	s, err := NewMutableSheet([][]Value{
+		{Float64Value(2), Float64Value(3)},
+		{Float64Value(5), Float64Value(7)},
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, s.SetFormula(context.TODO(), mustParsePos(t, "C1"), mustParseFormula(t, "A1 * B1")))
+	require.NoError(t, s.SetFormula(context.TODO(), mustParsePos(t, "C2"), mustParseFormula(t, "A2 * B2")))
+	require.NoError(t, s.SetFormula(context.TODO(), mustParsePos(t, "C3"), mustParseFormula(t, "SUM(C1:C2)")))
+	assertCells(t, s, map[string]Value{
+		"C1": Float64Value(6),
+		"C2": Float64Value(35),
+		"C3": Float64Value(41),
+	})
+
+	// Changing an input is reflected in the computed values
+	require.NoError(t, s.Set(context.TODO(), mustParsePos(t, "A1"), Float64Value(4)))
+	assertCells(t, s, map[string]Value{
+		"C1": Float64Value(12),
+		"C3": Float64Value(47),
+	})
+
+	// Setting a value replaces the formula
+	require.NoError(t, s.Set(context.TODO(), mustParsePos(t, "C2"), Float64Value(1)))
+	assertCells(t, s, map[string]Value{
+		"C3": Float64Value(13),
+	})
+}
+
+func TestMutableSheet_StructuralEdits(t *testing.T) {
+	newSheet := func(t *testing.T) MutableSheet {
+		s, err := NewMutableSheet([][]Value{
+			{Float64Value(1), Float64Value(10)},
+			{Float64Value(2), Float64Value(20)},
+			{Float64Value(3), Float64Value(30)},
+		})
+		require.NoError(t, err)
+
+		require.NoError(t, s.SetFormula(context.TODO(), mustParsePos(t, "C1"), mustParseFormula(t, "A3 * B1")))
+		require.NoError(t, s.SetFormula(context.TODO(), mustParsePos(t, "A4"), mustParseFormula(t, "SUM(A1:A3)")))
+		require.NoError(t, s.SetFormula(context.TODO(), mustParsePos(t, "B4"), mustParseFormula(t, "SUM(B1:B3)")))
+		return s
+	}
+
+	t.Run("insert rows", func(t *testing.T) {
+		s := newSheet(t)
+		require.NoError(t, s.InsertRows(context.TODO(), 1, 2))
+		require.NoError(t, s.Set(context.TODO(), mustParsePos(t, "A2"), Float64Value(100)))
+		assert.Equal(t, Dimensions{EndRow: 5, EndCol: 2}, s.Dimensions())
+		assertCells(t, s, map[string]Value{
+			"A1": Float64Value(1),
+			"A4": Float64Value(2),
+			"C1": Float64Value(30),
+			"A6": Float64Value(106),
+			"B6": Float64Value(60),
+		})
+	})
+
+	t.Run("delete rows", func(t *testing.T) {
+		s := newSheet(t)
+		require.NoError(t, s.DeleteRows(context.TODO(), 1, 1))
+		assert.Equal(t, Dimensions{EndRow: 2, EndCol: 2}, s.Dimensions())
+		assertCells(t, s, map[string]Value{
+			"A2": Float64Value(3),
+			"C1": Float64Value(30),
+			"A3": Float64Value(4),
+			"B3": Float64Value(40),
+		})
+
+		require.NoError(t, s.DeleteRows(context.TODO(), 1, 1))
+		assertCells(t, s, map[string]Value{
+			"A2": Float64Value(1),
+			"B2": Float64Value(10),
+		})
+
+		v, err := s.Get(context.TODO(), mustParsePos(t, "C1"))
+		require.NoError(t, err)
+		assertRefError(t, v)
+	})
+
+	t.Run("insert columns", func(t *testing.T) {
+		s := newSheet(t)
+		require.NoError(t, s.InsertCols(context.TODO(), 1, 1))
+		assert.Equal(t, Dimensions{EndRow: 3, EndCol: 3}, s.Dimensions())
+		assertCells(t, s, map[string]Value{
+			"B1": BlankValue{},
+			"C1": Float64Value(10),
+			"D1": Float64Value(30),
+			"C4": Float64Value(60),
+		})
+	})
+
+	t.Run("delete columns", func(t *testing.T) {
+		s := newSheet(t)
+		require.NoError(t, s.DeleteCols(context.TODO(), 0, 1))
+		assert.Equal(t, Dimensions{EndRow: 3, EndCol: 1}, s.Dimensions())
+		assertCells(t, s, map[string]Value{
+			"A1": Float64Value(10),
+			"A4": Float64Value(60),
+		})
+
+		v, err := s.Get(context.TODO(), mustParsePos(t, "B1"))
+		require.NoError(t, err)
+		assertRefError(t, v)
+	})
+
+	t.Run("invalid edits", func(t *testing.T) {
+		s := newSheet(t)
+		require.Error(t, s.InsertRows(context.TODO(), -1, 1))
+		require.Error(t, s.DeleteCols(context.TODO(), 0, -1))
+	})
+}
+
+func TestMutableSheet_Clear(t *testing.T) {
+	s, err := NewMutableSheet([][]Value{
+		{Float64Value(1), Float64Value(10)},
+		{Float64Value(2), Float64Value(20)},
+	})
+	require.NoError(t, err)
+	require.NoError(t, s.SetFormula(context.TODO(), mustParsePos(t, "C2"), mustParseFormula(t, "SUM(A:B)")))
+
+	require.NoError(t, s.Clear(context.TODO(), mustParseRange(t, "B:B")))
+	assertCells(t, s, map[string]Value{
+		"B1": BlankValue{},
+		"B2": BlankValue{},
+		"C2": Float64Value(3),
+	})
+
+	require.NoError(t, s.Clear(context.TODO(), mustParseRange(t, "C1:Z10")))
+	assertCells(t, s, map[string]Value{
+		"C2": BlankValue{},
+	})
+}
+
+func assertCells(t *testing.T, s Sheet, expected map[string]Value) {
+	for posText, expectedValue := range expected {
+		v, err := s.Get(context.TODO(), mustParsePos(t, posText))
+		require.NoError(t, err)
+		assert.Equal(t, expectedValue, v, posText)
+	}
+}
+
+func assertRefError(t *testing.T, v Value) {
+	errVal, ok := v.(ErrorValue)
+	require.True(t, ok, "expected error, got %s", v)
+
+	sheetErr, ok := UnwrapError(errVal.Err)
+	require.True(t, ok)
+	assert.Equal(t, "#REF", sheetErr.TypeName())
+}
+
+func mustParseFormula(t *testing.T, s string) Formula {
+	f, err := ParseFormula(s)
+	require.NoError(t, err)
+	return f
+}
@@ -0,0 +1,1203 @@
+package sheets
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// A NumberFormat is a compiled Excel number format code, such as "#,##0.00",
+// "0.0%", "$#,##0;[Red]($#,##0)", "yyyy-mm-dd hh:mm", "[h]:mm:ss",
+// "0.00E+00", "# ?/?" or "@". NumberFormats display values exactly as Excel
+// does, with the General format fitting numbers into the 11 characters of a
+// cell of the standard width.
+//
+// A format code has up to four sections separated by ";". With one section,
+// it is used for all numbers. With two, the first is used for positive numbers
+// and zero and the second for negative numbers, which are displayed without
+// their sign. With three or more, the third is used for zero, and the fourth
+// is used for text. Sections can instead be selected by conditions such as
+// "[>=100]", which display negative numbers with their sign unless the
+// condition only matches numbers below zero, such as "[<0]". Colors such as
+// "[Red]" are accepted but have no effect.
+type NumberFormat struct {
+	code     string
+	sections []*formatSection
+}
+
+// ParseNumberFormat compiles an Excel number format code.
+func ParseNumberFormat(code string) (*NumberFormat, error) {
+	numberFormatsMu.RLock()
+	nf, ok := numberFormats[code]
+	numberFormatsMu.RUnlock()
+	if ok {
+		return nf, nil
+	}
+
+	sections, err := parseFormatSections(code)
+	if err != nil {
+		return nil, err
+	}
+
+	nf = &NumberFormat{
+		code:     code,
+		sections: sections,
+	}
+
+	numberFormatsMu.Lock()
+	defer numberFormatsMu.Unlock()
+	if len(numberFormats) >= maxCachedNumberFormats {
+		// Evict an arbitrary format, so that formats parsed from arbitrary
+		// text do not accumulate
+		for code := range numberFormats {
+			delete(numberFormats, code)
+			break
+		}
+	}
+
+	numberFormats[code] = nf
+	return nf, nil
+}
+
+// maxCachedNumberFormats is the number of compiled formats that are cached.
+const maxCachedNumberFormats = 1024
+
+var (
+	numberFormatsMu sync.RWMutex
+	numberFormats   = make(map[string]*NumberFormat) // code -> *NumberFormat
+)
+
+// String returns the format code.
+func (nf *NumberFormat) String() string {
+	return nf.code
+}
+
+// IsDateTime returns true if the format displays positive numbers as dates or
+// times, such as "yyyy-mm-dd" or "[h]:mm".
+func (nf *NumberFormat) IsDateTime() bool {
+	return nf.sections[0].kind == dateSection
+}
+
+// Format returns a value as Excel displays it with the format. Text is only
+// changed by formats with a text section, booleans are displayed as TRUE or
+// FALSE, and blanks are displayed as zero. Returns the error if the value is
+// an error, or if the value is a negative number and the format is a date.
+// Numbers are formatted as dates using the default CalcSettings.
+func (nf *NumberFormat) Format(v Value) (string, error) {
+	return nf.FormatWith(v, CalcSettings{})
+}
+
+// FormatWith returns a value as Excel displays it with the format, converting
+// between numbers and dates using the date system in the settings.
+func (nf *NumberFormat) FormatWith(v Value, settings CalcSettings) (string, error) {
+	switch tv := v.(type) {
+	case ErrorValue:
+		return "", tv.Err
+	case StringValue:
+		return nf.formatText(string(tv)), nil
+	case BoolValue:
+		return tv.String(), nil
+	case BlankValue:
+		return nf.formatNumber(0, settings.DateSystem)
+	default:
+		n, err := settings.toFloat64(v)
+		if err != nil {
+			return "", err
+		}
+
+		return nf.formatNumber(n, settings.DateSystem)
+	}
+}
+
+func (nf *NumberFormat) formatText(s string) string {
+	for i, section := range nf.sections {
+		if section.kind == textSection || i == 3 {
+			return section.formatText(s)
+		}
+	}
+
+	return s
+}
+
+func (nf *NumberFormat) formatNumber(n float64, ds DateSystem) (string, error) {
+	section, signed := nf.sectionFor(n)
+	if section == nil {
+		return "", ValueErrorf("no section of format '%s' applies to %s", nf.code, Float64Value(n))
+	}
+
+	return section.formatNumber(math.Abs(n), signed && n < 0, ds)
+}
+
+// sectionFor returns the section used to display a number, and whether the
+// number is displayed with its sign.
+func (nf *NumberFormat) sectionFor(n float64) (*formatSection, bool) {
+	var numeric []*formatSection
+	for i, section := range nf.sections {
+		if i < 3 && (section.kind != textSection || len(nf.sections) == 1) {
+			numeric = append(numeric, section)
+		}
+	}
+
+	if len(numeric) == 0 {
+		return nil, false
+	}
+
+	if numeric[0].condition != nil || (len(numeric) > 1 && numeric[1].condition != nil) {
+		for _, section := range numeric[:len(numeric)-1] {
+			if section.condition != nil && section.condition.matches(n) {
+				return section, !section.condition.negative()
+			}
+		}
+
+		last := numeric[len(numeric)-1]
+		if last.condition == nil || last.condition.matches(n) {
+			return last, last.condition == nil || !last.condition.negative()
+		}
+
+		return nil, false
+	}
+
+	switch {
+	case len(numeric) == 1:
+		return numeric[0], true
+	case n < 0:
+		return numeric[1], false
+	case n == 0 && len(numeric) > 2:
+		return numeric[2], false
+	default:
+		return numeric[0], false
+	}
+}
+
+type sectionKind int
+
+const (
+	numberSection sectionKind = iota
+	dateSection
+	textSection
+)
+
+type formatTokenKind int
+
+const (
+	literalToken         formatTokenKind = iota
+	generalToken                         // General
+	digitToken                           // 0, # or ? before the decimal point
+	decimalPointToken                    // .
+	fractionToken                        // 0, # or ? after the decimal point
+	exponentToken                        // E+ or E-
+	exponentDigitToken                   // 0 or # in the exponent
+	commaToken                           // , which is resolved into grouping, scaling or a literal
+	percentToken                         // %
+	textPlaceholderToken                 // @
+	dateToken                            // y, m, d, h, s and elapsed codes such as [h]
+	ampmToken                            // AM/PM or A/P
+	subsecondToken                       // .0, .00 or .000 following seconds
+	fractionBarToken                     // / between the numerator and denominator of a fraction
+	numeratorToken                       // 0, # or ? in the numerator of a fraction
+	denominatorToken                     // 0, # or ? in the denominator of a fraction, or a fixed denominator
+)
+
+type formatToken struct {
+	kind formatTokenKind
+	text string
+}
+
+// A formatSection is one of the ";"-separated sections of a format code.
+type formatSection struct {
+	kind      sectionKind
+	tokens    []formatToken
+	condition *formatCondition
+
+	// Number sections
+	digits    int  // The number of digit placeholders before the decimal point
+	fractions int  // The number of digit placeholders after the decimal point
+	grouping  bool // Whether thousands are separated with ","
+	scale     int  // The number of thousands the number is divided by
+	percents  int  // The number of times the number is multiplied by 100
+	exponent  bool
+
+	// Fractions
+	fraction     bool
+	numerators   int // The number of digit placeholders in the numerator
+	denominators int // The number of digit placeholders in the denominator
+	denominator  int // The fixed denominator, if any
+
+	// Date sections
+	subseconds int // The number of digits of fractional seconds
+	twelveHour bool
+}
+
+type formatCondition struct {
+	op    string
+	value float64
+}
+
+func (c *formatCondition) matches(n float64) bool {
+	switch c.op {
+	case "<":
+		return n < c.value
+	case "<=":
+		return n <= c.value
+	case ">":
+		return n > c.value
+	case ">=":
+		return n >= c.value
+	case "<>":
+		return n != c.value
+	default:
+		return n == c.value
+	}
+}
+
+// negative reports whether the condition only matches numbers that are not
+// above zero, whose section Excel displays without their sign.
+func (c *formatCondition) negative() bool {
+	return (c.op == "<" || c.op == "<=") && c.value <= 0
+}
+
+func parseFormatSections(code string) ([]*formatSection, error) {
+	var (
+		sections = []*formatSection{{}}
+		runes    = []rune(code)
+	)
+
+	invalid := func(msg string, args ...any) error {
+		return ValueErrorf("invalid number format '%s': %s", code, fmt.Sprintf(msg, args...))
+	}
+
+	for i := 0; i < len(runes); i++ {
+		section := sections[len(sections)-1]
+		r := runes[i]
+		switch {
+		case r == ';':
+			if len(sections) == 4 {
+				return nil, invalid("too many sections")
+			}
+
+			sections = append(sections, &formatSection{})
+		case r == '"':
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+
+			if end == len(runes) {
+				return nil, invalid("unterminated string")
+			}
+
+			section.literal(string(runes[i+1 : end]))
+			i = end
+		case r == '\\' || r == '_' || r == '*':
+			if i+1 == len(runes) {
+				return nil, invalid("'%c' must be followed by a character", r)
+			}
+
+			i++
+			switch r {
+			case '\\':
+				section.literal(string(runes[i]))
+			case '_':
+				// Padding the width of a character, which is a single space in text
+				section.literal(" ")
+			}
+		case r == '[':
+			end := i + 1
+			for end < len(runes) && runes[end] != ']' {
+				end++
+			}
+
+			if end == len(runes) {
+				return nil, invalid("unterminated '['")
+			}
+
+			if err := section.bracket(string(runes[i+1 : end])); err != nil {
+				return nil, invalid("%s", err)
+			}
+
+			i = end
+		case hasPrefixFold(runes[i:], "General"):
+			section.add(generalToken, "General")
+			i += len("General") - 1
+		case hasPrefixFold(runes[i:], "AM/PM"):
+			section.add(ampmToken, string(runes[i:i+5]))
+			i += 4
+		case hasPrefixFold(runes[i:], "A/P"):
+			section.add(ampmToken, string(runes[i:i+3]))
+			i += 2
+		case r == '0' || r == '#' || r == '?':
+			section.add(digitToken, string(r))
+		case r == '.':
+			section.add(decimalPointToken, ".")
+		case r == '/':
+			section.add(fractionBarToken, "/")
+		case r == ',':
+			section.add(commaToken, ",")
+		case r == '%':
+			section.add(percentToken, "%")
+		case r == '@':
+			section.add(textPlaceholderToken, "@")
+		case (r == 'E' || r == 'e') && i+1 < len(runes) && (runes[i+1] == '+' || runes[i+1] == '-'):
+			section.add(exponentToken, "E"+string(runes[i+1]))
+			i++
+		case strings.ContainsRune("ymdhs", unicode.ToLower(r)):
+			end := i
+			for end < len(runes) && unicode.ToLower(runes[end]) == unicode.ToLower(r) {
+				end++
+			}
+
+			section.add(dateToken, strings.ToLower(string(runes[i:end])))
+			i = end - 1
+		default:
+			section.literal(string(r))
+		}
+	}
+
+	for _, section := range sections {
+		section.resolve()
+	}
+
+	return sections, nil
+}
+
+func hasPrefixFold(runes []rune, prefix string) bool {
+	return len(runes) >= len(prefix) && strings.EqualFold(string(runes[:len(prefix)]), prefix)
+}
+
+func (s *formatSection) add(kind formatTokenKind, text string) {
+	s.tokens = append(s.tokens, formatToken{kind: kind, text: text})
+}
+
+func (s *formatSection) literal(text string) {
+	if n := len(s.tokens); n > 0 && s.tokens[n-1].kind == literalToken {
+		s.tokens[n-1].text += text
+		return
+	}
+
+	s.add(literalToken, text)
+}
+
+// bracket handles the contents of a [...] code: an elapsed time such as [h],
+// a currency or locale such as [$€-407], a condition such as [>=100], or a
+// color, which is ignored.
+func (s *formatSection) bracket(content string) error {
+	lower := strings.ToLower(content)
+	switch {
+	case lower == "":
+		return fmt.Errorf("empty '[]'")
+	case strings.Trim(lower, "h") == "" || strings.Trim(lower, "m") == "" || strings.Trim(lower, "s") == "":
+		s.add(dateToken, "["+lower+"]")
+	case content[0] == '$':
+		symbol, _, _ := strings.Cut(content[1:], "-")
+		s.literal(symbol)
+	case strings.ContainsRune("<>=", rune(content[0])):
+		op := strings.TrimRight(content, "+-.0123456789 ")
+		value, err := strconv.ParseFloat(strings.TrimSpace(content[len(op):]), 64)
+		if err != nil {
+			return fmt.Errorf("invalid condition '%s'", content)
+		}
+
+		switch op {
+		case "<", "<=", ">", ">=", "=", "<>":
+			s.condition = &formatCondition{op: op, value: value}
+		default:
+			return fmt.Errorf("invalid condition '%s'", content)
+		}
+	}
+
+	return nil
+}
+
+// resolve determines what kind of section this is, and the role of tokens
+// whose meaning depends on their context.
+func (s *formatSection) resolve() {
+	for _, tok := range s.tokens {
+		switch tok.kind {
+		case dateToken, ampmToken:
+			s.kind = dateSection
+		case textPlaceholderToken:
+			if s.kind == numberSection {
+				s.kind = textSection
+			}
+		}
+	}
+
+	switch s.kind {
+	case dateSection:
+		s.resolveDate()
+	case textSection:
+		for _, tok := range s.tokens {
+			if tok.kind == digitToken || tok.kind == generalToken {
+				s.kind = numberSection
+				s.resolveNumber()
+				return
+			}
+		}
+
+		s.literalize(textPlaceholderToken)
+	default:
+		s.resolveNumber()
+	}
+}
+
+// literalize turns the tokens that are not meaningful to the section into
+// literal text.
+func (s *formatSection) literalize(keep ...formatTokenKind) {
+	tokens := s.tokens
+	s.tokens = nil
+	for _, tok := range tokens {
+		if tok.kind == literalToken || !containsKind(keep, tok.kind) {
+			s.literal(tok.text)
+			continue
+		}
+
+		s.tokens = append(s.tokens, tok)
+	}
+}
+
+func containsKind(kinds []formatTokenKind, kind formatTokenKind) bool {
+	for _, k := range kinds {
+		if k == kind {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *formatSection) resolveNumber() {
+	s.resolveFraction()
+
+	var (
+		afterDecimal  bool
+		afterExponent bool
+		tokens        []formatToken
+	)
+
+	for i, tok := range s.tokens {
+		switch tok.kind {
+		case digitToken:
+			switch {
+			case afterExponent:
+				tok.kind = exponentDigitToken
+			case afterDecimal:
+				tok.kind = fractionToken
+				s.fractions++
+			default:
+				s.digits++
+			}
+		case decimalPointToken:
+			if afterDecimal || afterExponent {
+				tok.kind = literalToken
+			}
+
+			afterDecimal = true
+		case exponentToken:
+			if afterExponent || i+1 == len(s.tokens) || s.tokens[i+1].kind != digitToken {
+				tok.kind = literalToken
+			} else {
+				afterExponent, s.exponent = true, true
+			}
+		case commaToken:
+			// A comma between digit placeholders groups thousands, while
+			// commas following the digit placeholders scale by a thousand
+			prev, next := s.neighbouringDigits(i)
+			switch {
+			case prev && next && !afterDecimal && !afterExponent:
+				s.grouping = true
+				continue
+			case prev && !next:
+				s.scale++
+				continue
+			default:
+				tok.kind = literalToken
+			}
+		case percentToken:
+			s.percents++
+		case dateToken, ampmToken, textPlaceholderToken:
+			tok.kind = literalToken
+		}
+
+		tokens = append(tokens, tok)
+	}
+
+	s.tokens = nil
+	for _, tok := range tokens {
+		if tok.kind == literalToken {
+			s.literal(tok.text)
+		} else {
+			s.tokens = append(s.tokens, tok)
+		}
+	}
+}
+
+// resolveFraction finds a fraction such as "# ?/?" or "?/8", where the digit
+// placeholders directly before a "/" display the numerator, and the digit
+// placeholders or the number directly after it display the denominator. Any
+// other "/" is literal text, as is every "/" in a section with a decimal point
+// or an exponent.
+func (s *formatSection) resolveFraction() {
+	bar := -1
+	for i, tok := range s.tokens {
+		if tok.kind == decimalPointToken || tok.kind == exponentToken {
+			bar = -1
+			break
+		}
+
+		if tok.kind == fractionBarToken && bar == -1 && s.isFractionBar(i) {
+			bar = i
+		}
+	}
+
+	for i := range s.tokens {
+		if s.tokens[i].kind == fractionBarToken && i != bar {
+			s.tokens[i].kind = literalToken
+		}
+	}
+
+	if bar == -1 {
+		return
+	}
+
+	s.fraction = true
+	for i := bar - 1; i >= 0 && s.tokens[i].kind == digitToken; i-- {
+		s.tokens[i].kind = numeratorToken
+		s.numerators++
+	}
+
+	next := s.tokens[bar+1]
+	if next.kind == digitToken {
+		for i := bar + 1; i < len(s.tokens) && s.tokens[i].kind == digitToken; i++ {
+			s.tokens[i].kind = denominatorToken
+			s.denominators++
+		}
+
+		return
+	}
+
+	// A fixed denominator, which may be followed by other literal text. Zeros
+	// following the literal digits, as in "?/100", are parsed as placeholders.
+	digits := next.text[:len(next.text)-len(strings.TrimLeft(next.text, "0123456789"))]
+	rest, end := next.text[len(digits):], bar+2
+	for ; rest == "" && end < len(s.tokens) && s.tokens[end].kind == digitToken && s.tokens[end].text == "0"; end++ {
+		digits += "0"
+	}
+
+	s.denominator, _ = strconv.Atoi(digits)
+
+	tokens := append(s.tokens[:bar+1:bar+1], formatToken{kind: denominatorToken, text: digits})
+	if rest != "" {
+		tokens = append(tokens, formatToken{kind: literalToken, text: rest})
+	}
+
+	s.tokens = append(tokens, s.tokens[end:]...)
+}
+
+// isFractionBar returns true if the "/" at index i follows a digit placeholder
+// and precedes a digit placeholder or a fixed denominator.
+func (s *formatSection) isFractionBar(i int) bool {
+	if i == 0 || i+1 == len(s.tokens) || s.tokens[i-1].kind != digitToken {
+		return false
+	}
+
+	next := s.tokens[i+1]
+	return next.kind == digitToken || (next.kind == literalToken && next.text[0] >= '1' && next.text[0] <= '9')
+}
+
+// neighbouringDigits reports whether the comma at index i directly follows a
+// digit placeholder (possibly after other commas), and whether any digit
+// placeholder follows it.
+func (s *formatSection) neighbouringDigits(i int) (prev, next bool) {
+	for j := i - 1; j >= 0; j-- {
+		if s.tokens[j].kind == commaToken {
+			continue
+		}
+
+		prev = s.tokens[j].kind == digitToken
+		break
+	}
+
+	for _, tok := range s.tokens[i+1:] {
+		if tok.kind == digitToken {
+			next = true
+		}
+
+		if tok.kind == decimalPointToken || tok.kind == exponentToken {
+			break
+		}
+	}
+
+	return prev, next
+}
+
+func (s *formatSection) resolveDate() {
+	var dateTokens []int
+	for i, tok := range s.tokens {
+		switch tok.kind {
+		case dateToken:
+			dateTokens = append(dateTokens, i)
+		case ampmToken:
+			s.twelveHour = true
+		}
+	}
+
+	// "m" is minutes rather than months when it follows hours or precedes
+	// seconds
+	for j, i := range dateTokens {
+		code := s.tokens[i].text
+		if code[0] != 'm' || len(code) > 2 {
+			continue
+		}
+
+		var (
+			afterHours    = j > 0 && strings.Trim(s.tokens[dateTokens[j-1]].text, "[]")[0] == 'h'
+			beforeSeconds = j+1 < len(dateTokens) && strings.Trim(s.tokens[dateTokens[j+1]].text, "[]")[0] == 's'
+		)
+
+		if afterHours || beforeSeconds {
+			s.tokens[i].text = strings.Replace(code, "m", "n", -1)
+		}
+	}
+
+	// A decimal point followed by zeros after seconds displays fractions of
+	// a second
+	tokens := s.tokens
+	s.tokens = nil
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		if tok.kind == decimalPointToken && s.lastDateCode() == 's' {
+			end := i + 1
+			for end < len(tokens) && end-i <= 3 && tokens[end].kind == digitToken && tokens[end].text == "0" {
+				end++
+			}
+
+			if end > i+1 {
+				s.subseconds = end - i - 1
+				s.add(subsecondToken, strings.Repeat("0", s.subseconds))
+				i = end - 1
+				continue
+			}
+		}
+
+		switch tok.kind {
+		case dateToken, ampmToken:
+			s.tokens = append(s.tokens, tok)
+		default:
+			s.literal(tok.text)
+		}
+	}
+}
+
+// lastDateCode returns the letter of the last date code in the section.
+func (s *formatSection) lastDateCode() byte {
+	for i := len(s.tokens) - 1; i >= 0; i-- {
+		if s.tokens[i].kind == dateToken {
+			return strings.Trim(s.tokens[i].text, "[]")[0]
+		}
+	}
+
+	return 0
+}
+
+func (s *formatSection) formatText(text string) string {
+	var sb strings.Builder
+	for _, tok := range s.tokens {
+		switch tok.kind {
+		case textPlaceholderToken:
+			sb.WriteString(text)
+		case literalToken:
+			sb.WriteString(tok.text)
+		}
+	}
+
+	return sb.String()
+}
+
+// formatNumber formats the absolute value of a number, prefixed with a "-" if
+// negative is set.
+func (s *formatSection) formatNumber(n float64, negative bool, ds DateSystem) (string, error) {
+	switch s.kind {
+	case dateSection:
+		if negative {
+			return "", ValueErrorf("negative numbers cannot be formatted as dates or times")
+		}
+
+		if n >= ds.maxExcelDate()+1 {
+			return "", ValueErrorf("%s is too large to be formatted as a date or time", Float64Value(n))
+		}
+
+		return s.formatDate(n, ds), nil
+	case textSection:
+		return s.formatText(generalNumber(n, negative)), nil
+	}
+
+	for i := 0; i < s.percents; i++ {
+		n *= 100
+	}
+
+	for i := 0; i < s.scale; i++ {
+		n /= 1000
+	}
+
+	if s.fraction {
+		return s.formatFraction(n, negative), nil
+	}
+
+	exp := 0
+	if s.exponent {
+		n, exp = s.mantissa(n)
+	}
+
+	intDigits, fracDigits := decimalDigits(n, s.fractions)
+
+	var sb strings.Builder
+	if negative {
+		sb.WriteRune('-')
+	}
+
+	var (
+		digit    = 0 // The index of the next integer digit placeholder
+		fraction = 0 // The index of the next fraction digit placeholder
+		lastFrac = strings.TrimRight(fracDigits, "0")
+	)
+
+	for _, tok := range s.tokens {
+		switch tok.kind {
+		case literalToken, percentToken:
+			sb.WriteString(tok.text)
+		case generalToken:
+			sb.WriteString(generalNumber(n, false))
+		case digitToken:
+			writeDigits(&sb, intDigits, digit, s.digits, tok.text, s.grouping)
+			digit++
+		case decimalPointToken:
+			sb.WriteRune('.')
+		case fractionToken:
+			switch {
+			case fraction < len(lastFrac) || tok.text == "0":
+				sb.WriteByte(fracDigits[fraction])
+			case tok.text == "?":
+				sb.WriteRune(' ')
+			}
+
+			fraction++
+		case exponentToken:
+			sb.WriteRune('E')
+			if exp < 0 {
+				sb.WriteRune('-')
+			} else if tok.text == "E+" {
+				sb.WriteRune('+')
+			}
+
+			width := 0
+			for _, t := range s.tokens {
+				if t.kind == exponentDigitToken && t.text == "0" {
+					width++
+				}
+			}
+
+			e := strconv.Itoa(abs(exp))
+			if len(e) < width {
+				e = strings.Repeat("0", width-len(e)) + e
+			}
+
+			sb.WriteString(e)
+		}
+	}
+
+	return sb.String(), nil
+}
+
+// writeDigits writes the digits displayed by the index'th of count digit
+// placeholders. Each placeholder displays the digit of a whole number at its
+// position from the right, with the leftmost placeholder displaying any extra
+// digits. Thousands are separated if grouping is set.
+func writeDigits(sb *strings.Builder, digits string, index, count int, placeholder string, grouping bool) {
+	write := func(d byte, pos int) {
+		sb.WriteByte(d)
+		if grouping && pos > 0 && pos%3 == 0 {
+			sb.WriteRune(',')
+		}
+	}
+
+	pos := count - index - 1
+	if index == 0 {
+		for p := len(digits) - 1; p > pos; p-- {
+			write(digits[len(digits)-1-p], p)
+		}
+	}
+
+	switch {
+	case pos < len(digits):
+		write(digits[len(digits)-1-pos], pos)
+	case placeholder == "0":
+		write('0', pos)
+	case placeholder == "?":
+		sb.WriteRune(' ')
+	}
+}
+
+// formatFraction formats the absolute value of a number as a fraction,
+// prefixed with a "-" if negative is set. If the section has digit
+// placeholders before the numerator, the whole number is displayed there and
+// only the remainder as a fraction, which is left blank if it is zero.
+func (s *formatSection) formatFraction(n float64, negative bool) string {
+	whole, remainder := 0.0, n
+	if s.digits > 0 {
+		whole = math.Floor(n)
+		remainder = n - whole
+	}
+
+	var num, den int64
+	if s.denominator > 0 {
+		den = int64(s.denominator)
+		num = int64(math.Round(remainder * float64(den)))
+	} else {
+		num, den = closestFraction(remainder, int64(math.Pow10(s.denominators))-1)
+	}
+
+	if s.digits > 0 && num == den {
+		// The remainder rounds up to a whole number
+		whole, num = whole+1, 0
+	}
+
+	blank := s.digits > 0 && num == 0
+	intDigits := strings.TrimLeft(strconv.FormatFloat(whole, 'f', 0, 64), "0")
+	if blank && intDigits == "" {
+		intDigits = "0"
+	}
+
+	var (
+		numDigits = strconv.FormatInt(num, 10)
+		denDigits = strconv.FormatInt(den, 10)
+		digit     = 0 // The index of the next integer digit placeholder
+		numerator = 0 // The index of the next numerator digit placeholder
+		denom     = 0 // The index of the next denominator digit placeholder
+		sb        strings.Builder
+	)
+
+	if negative {
+		sb.WriteRune('-')
+	}
+
+	for _, tok := range s.tokens {
+		switch tok.kind {
+		case literalToken, percentToken:
+			sb.WriteString(tok.text)
+		case digitToken:
+			writeDigits(&sb, intDigits, digit, s.digits, tok.text, s.grouping)
+			digit++
+		case numeratorToken:
+			if blank {
+				sb.WriteRune(' ')
+			} else {
+				writeDigits(&sb, numDigits, numerator, s.numerators, tok.text, false)
+			}
+
+			numerator++
+		case fractionBarToken:
+			if blank {
+				sb.WriteRune(' ')
+			} else {
+				sb.WriteRune('/')
+			}
+		case denominatorToken:
+			// Denominators are aligned to the left
+			switch {
+			case blank:
+				sb.WriteString(strings.Repeat(" ", len(tok.text)))
+			case s.denominator > 0:
+				sb.WriteString(tok.text)
+			case denom < len(denDigits):
+				sb.WriteByte(denDigits[denom])
+			case tok.text == "0":
+				sb.WriteRune('0')
+			case tok.text == "?":
+				sb.WriteRune(' ')
+			}
+
+			denom++
+		}
+	}
+
+	return sb.String()
+}
+
+// closestFraction returns the fraction closest to a non-negative number with a
+// denominator no larger than maxDen, using the convergents of the continued
+// fraction of the number.
+func closestFraction(x float64, maxDen int64) (int64, int64) {
+	if x >= 1e15 {
+		return int64(math.Round(x)), 1
+	}
+
+	var (
+		p0, q0 int64 = 0, 1
+		p1, q1 int64 = 1, 0
+		rest         = x
+	)
+
+	for i := 0; i < 64; i++ {
+		a := math.Floor(rest)
+		p2, q2 := int64(a)*p1+p0, int64(a)*q1+q0
+		if q2 > maxDen {
+			// The closest fraction is either the last convergent, or the
+			// largest semiconvergent between it and the next one
+			k := (maxDen - q0) / q1
+			ps, qs := k*p1+p0, k*q1+q0
+			if math.Abs(x-float64(ps)/float64(qs)) < math.Abs(x-float64(p1)/float64(q1)) {
+				return ps, qs
+			}
+
+			return p1, q1
+		}
+
+		p0, q0, p1, q1 = p1, q1, p2, q2
+		if rest-a < 1e-9 {
+			break
+		}
+
+		rest = 1 / (rest - a)
+	}
+
+	return p1, q1
+}
+
+// mantissa splits a number into a mantissa and an exponent for scientific
+// notation. With more than one digit placeholder before the decimal point,
+// the exponent is a multiple of the number of placeholders.
+func (s *formatSection) mantissa(n float64) (float64, int) {
+	if n == 0 {
+		return 0, 0
+	}
+
+	step := s.digits
+	if step < 1 {
+		step = 1
+	}
+
+	exp := int(math.Floor(math.Log10(n)))
+	exp = int(math.Floor(float64(exp)/float64(step))) * step
+	mantissa := n / math.Pow10(exp)
+
+	// Rounding may carry the mantissa into another digit
+	if intDigits, _ := decimalDigits(mantissa, s.fractions); len(intDigits) > step {
+		exp += step
+		mantissa = n / math.Pow10(exp)
+	}
+
+	return mantissa, exp
+}
+
+func (s *formatSection) formatDate(n float64, ds DateSystem) string {
+	var (
+		unit    = math.Pow10(s.subseconds)
+		total   = math.Round(n * 86400 * unit)
+		seconds = math.Floor(total / unit)
+		sub     = int64(total - seconds*unit)
+		days    = math.Floor(seconds / 86400)
+		ofDay   = int64(seconds - days*86400)
+		weekday = ds.excelWeekday(days)
+		hour    = int(ofDay / 3600)
+		minute  = int(ofDay / 60 % 60)
+		second  = int(ofDay % 60)
+	)
+
+	// The date is found without a time.Time, which cannot represent serial 0
+	// or the phantom 1900-02-29 of the Date1900 system
+	day, month, year := ds.fromExcelDate(days)
+	if ds == Date1900 && days == 0 {
+		day, month, year = 0, time.January, 1900
+	}
+
+	var sb strings.Builder
+	for _, tok := range s.tokens {
+		switch tok.kind {
+		case literalToken:
+			sb.WriteString(tok.text)
+		case subsecondToken:
+			sb.WriteString(fmt.Sprintf(".%0*d", s.subseconds, sub))
+		case ampmToken:
+			am, pm := "AM", "PM"
+			if len(tok.text) == 3 {
+				am, pm = tok.text[:1], tok.text[2:]
+			} else if tok.text[0] == 'a' {
+				am, pm = "am", "pm"
+			}
+
+			if hour < 12 {
+				sb.WriteString(am)
+			} else {
+				sb.WriteString(pm)
+			}
+		case dateToken:
+			code := tok.text
+			switch {
+			case code[0] == '[':
+				elapsed := int64(seconds)
+				switch code[1] {
+				case 'h':
+					elapsed /= 3600
+				case 'm':
+					elapsed /= 60
+				}
+
+				sb.WriteString(fmt.Sprintf("%0*d", len(code)-2, elapsed))
+			case code[0] == 'y':
+				if len(code) <= 2 {
+					sb.WriteString(fmt.Sprintf("%02d", year%100))
+				} else {
+					sb.WriteString(fmt.Sprintf("%04d", year))
+				}
+			case code[0] == 'm':
+				switch len(code) {
+				case 1, 2:
+					sb.WriteString(fmt.Sprintf("%0*d", len(code), int(month)))
+				case 3:
+					sb.WriteString(month.String()[:3])
+				case 5:
+					sb.WriteString(month.String()[:1])
+				default:
+					sb.WriteString(month.String())
+				}
+			case code[0] == 'd':
+				switch len(code) {
+				case 1, 2:
+					sb.WriteString(fmt.Sprintf("%0*d", len(code), day))
+				case 3:
+					sb.WriteString(weekday.String()[:3])
+				default:
+					sb.WriteString(weekday.String())
+				}
+			case code[0] == 'h':
+				h := hour
+				if s.twelveHour {
+					h = (hour+11)%12 + 1
+				}
+
+				sb.WriteString(fmt.Sprintf("%0*d", twoDigits(code), h))
+			case code[0] == 'n':
+				sb.WriteString(fmt.Sprintf("%0*d", twoDigits(code), minute))
+			case code[0] == 's':
+				sb.WriteString(fmt.Sprintf("%0*d", twoDigits(code), second))
+			}
+		}
+	}
+
+	return sb.String()
+}
+
+func twoDigits(code string) int {
+	if len(code) > 2 {
+		return 2
+	}
+
+	return len(code)
+}
+
+// generalWidth is the number of characters, not counting the sign, in which
+// the General format displays numbers.
+const generalWidth = 11
+
+// generalNumber formats the absolute value of a number the way the General
+// format does, prefixed with a "-" if negative is set. Numbers are displayed
+// as decimals, rounded to fit in generalWidth characters, unless they are too
+// large to fit or are smaller than 0.0001, in which case they are displayed in
+// scientific notation with up to six significant digits.
+func generalNumber(n float64, negative bool) string {
+	sign := ""
+	if negative {
+		sign = "-"
+	}
+
+	n = roundSignificant(n, excelSignificantDigits)
+	if n == 0 {
+		return "0"
+	}
+
+	exp := int(math.Floor(math.Log10(n)))
+	if exp >= -4 && exp < generalWidth {
+		// Leave room for the integer digits, or the leading "0.", and the
+		// decimal point
+		decimals := generalWidth - 2
+		if exp >= 0 {
+			decimals = generalWidth - exp - 2
+		}
+
+		if decimals < 0 {
+			decimals = 0
+		}
+
+		s := strconv.FormatFloat(n, 'f', decimals, 64)
+		if strings.ContainsRune(s, '.') {
+			s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+		}
+
+		// Rounding can carry into another integer digit
+		if len(s) <= generalWidth && s != "0" {
+			return sign + s
+		}
+	}
+
+	// Leave room for the leading digit, the decimal point and the exponent
+	decimals := generalWidth - 6
+	if exp <= -100 || exp >= 100 {
+		decimals--
+	}
+
+	mantissa, e, _ := strings.Cut(strconv.FormatFloat(n, 'e', decimals, 64), "e")
+	if strings.ContainsRune(mantissa, '.') {
+		mantissa = strings.TrimRight(strings.TrimRight(mantissa, "0"), ".")
+	}
+
+	exp, _ = strconv.Atoi(e)
+	expSign := "+"
+	if exp < 0 {
+		expSign = "-"
+	}
+
+	return fmt.Sprintf("%s%sE%s%02d", sign, mantissa, expSign, abs(exp))
+}
+
+// decimalDigits rounds a non-negative number half away from zero to the given
+// number of decimals, as Excel does, and returns the digits before the decimal
+// point (without leading zeros) and the given number of digits after it.
+func decimalDigits(n float64, decimals int) (string, string) {
+	s := strconv.FormatFloat(roundSignificant(n, 15), 'f', -1, 64)
+	intPart, fracPart, _ := strings.Cut(s, ".")
+
+	if len(fracPart) > decimals {
+		roundUp := fracPart[decimals] >= '5'
+		digits := []byte(intPart + fracPart[:decimals])
+		if roundUp {
+			i := len(digits) - 1
+			for ; i >= 0 && digits[i] == '9'; i-- {
+				digits[i] = '0'
+			}
+
+			if i < 0 {
+				digits = append([]byte{'1'}, digits...)
+			} else {
+				digits[i]++
+			}
+		}
+
+		intPart, fracPart = string(digits[:len(digits)-decimals]), string(digits[len(digits)-decimals:])
+	}
+
+	intPart = strings.TrimLeft(intPart, "0")
+	fracPart += strings.Repeat("0", decimals-len(fracPart))
+	return intPart, fracPart
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+
+	return n
+}
@@ -0,0 +1,242 @@
+package sheets
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNumberFormat_Format(t *testing.T) {
+	dateTime := TimeValue(time.Date(2024, time.March, 5, 14, 7, 9, 0, time.UTC))
+
+	for _, tt := range []struct {
+		value    Value
+		code     string
+		expected string
+	}{
+		// Digit placeholders
+		{Float64Value(1234.567), "0", "1235"},
+		{Float64Value(1234.567), "0.00", "1234.57"},
+		{Float64Value(1234.567), "#,##0.00", "1,234.57"},
+		{Float64Value(1234567.891), "#,##0", "1,234,568"},
+		{Float64Value(0.5), "#.##", ".5"},
+		{Float64Value(0.5), "0.##", "0.5"},
+		{Float64Value(5), "0.##", "5."},
+		{Float64Value(5), "000", "005"},
+		{Float64Value(5), "0,000", "0,005"},
+		{Float64Value(1.5), "?.??", "1.5 "},
+		{Float64Value(12), "???", " 12"},
+		{Float64Value(0), "#,##0.00", "0.00"},
+		{Float64Value(2.675), "0.00", "2.68"},
+		{Float64Value(0.125), "0.00", "0.13"},
+		{Float64Value(9.999), "0.00", "10.00"},
+		{Float64Value(-1234.5), "#,##0.00", "-1,234.50"},
+		{Float64Value(12345678), "000-00-0000", "012-34-5678"},
+		{Float64Value(123456789), "000-00-0000", "123-45-6789"},
+		{Float64Value(5551234), "###-####", "555-1234"},
+
+		// Scaling, percentages and scientific notation
+		{Float64Value(1234567), "#,##0,", "1,235"},
+		{Float64Value(1234567), `0.0,,"M"`, "1.2M"},
+		{Float64Value(0.256), "0%", "26%"},
+		{Float64Value(0.256), "0.0%", "25.6%"},
+		{Float64Value(12345.678), "0.00E+00", "1.23E+04"},
+		{Float64Value(0.000123), "0.00E+00", "1.23E-04"},
+		{Float64Value(0.000123), "0.00E-00", "1.23E-04"},
+		{Float64Value(12345.678), "0.00E-00", "1.23E04"},
+		{Float64Value(99999), "0.0E+0", "1.0E+5"},
+		{Float64Value(12345), "##0.0E+0", "12.3E+3"},
+		{Float64Value(0), "0.00E+00", "0.00E+00"},
+
+		// Literal text and currency
+		{Float64Value(1234.5), "$#,##0.00", "$1,234.50"},
+		{Float64Value(-1234.5), "$#,##0.00", "-$1,234.50"},
+		{Float64Value(1234.5), `#,##0.00 "USD"`, "1,234.50 USD"},
+		{Float64Value(1234.5), `[$€-407]#,##0.00`, "€1,234.50"},
+		{Float64Value(3), `0 \k\g`, "3 kg"},
+		{Float64Value(3), `0_)`, "3 "},
+		{Float64Value(3), `*-0`, "3"},
+		{Float64Value(3), `"Total: "General`, "Total: 3"},
+		{Float64Value(-3.25), "General", "-3.25"},
+		{Float64Value(1e20), "General", "1E+20"},
+		{Float64Value(0.000001234), "General", "1.234E-06"},
+		{Float64Value(-123456789012), "General", "-1.23457E+11"},
+		{Float64Value(12345678901), "General", "12345678901"},
+		{Float64Value(99999999999.6), "General", "1E+11"},
+		{Float64Value(1234567.891234), "General", "1234567.891"},
+		{Float64Value(0.0001234), "General", "0.0001234"},
+		{Float64Value(1.0 / 3), "General", "0.333333333"},
+		{Float64Value(1.5e-100), "General", "1.5E-100"},
+		{Float64Value(0), "General", "0"},
+		{Float64Value(0.5), "0.0%;General", "50.0%"},
+		{Float64Value(12345678901234), `@"!"`, "1.23457E+13!"},
+
+		// Fractions
+		{Float64Value(2.5), "# ?/?", "2 1/2"},
+		{Float64Value(0.75), "# ?/?", " 3/4"},
+		{Float64Value(2), "# ?/?", "2    "},
+		{Float64Value(0.999), "# ?/?", "1    "},
+		{Float64Value(0), "# ?/?", "0    "},
+		{Float64Value(-1.25), "# ?/?", "-1 1/4"},
+		{Float64Value(3.14159), "# ??/??", "3 14/99"},
+		{Float64Value(2.5), "# ??/??", "2  1/2 "},
+		{Float64Value(3.14159), "?/???", "355/113"},
+		{Float64Value(2.5), "?/?", "5/2"},
+		{Float64Value(0), "?/?", "0/1"},
+		{Float64Value(0.3), "# ?/8", " 2/8"},
+		{Float64Value(1.37), "0 00/100", "1 37/100"},
+		{Float64Value(1.5), `# ?/? "cups"`, "1 1/2 cups"},
+		{Float64Value(1.5), "#,##0 ?/4", "1 2/4"},
+		{Float64Value(1234.5), "#,##0 ?/2", "1,234 1/2"},
+		{Float64Value(1.5), "0.0/1", "1.5/1"},
+		{Float64Value(1.5), "0/", "2/"},
+
+		// Sections
+		{Float64Value(1234), "$#,##0;[Red]($#,##0)", "$1,234"},
+		{Float64Value(-1234), "$#,##0;[Red]($#,##0)", "($1,234)"},
+		{Float64Value(0), `0.0;(0.0);"zero"`, "zero"},
+		{Float64Value(-2), `0.0;(0.0);"zero"`, "(2.0)"},
+		{Float64Value(-2), `0;;0`, ""},
+		{Float64Value(0), `0;-0;;@`, ""},
+		{StringValue("abc"), `0;-0;0;"Name: "@`, "Name: abc"},
+		{StringValue("abc"), `0.00`, "abc"},
+		{StringValue("abc"), `@" units"`, "abc units"},
+		{Float64Value(12), `@`, "12"},
+		{BoolValue(true), "0.00", "TRUE"},
+		{BlankValue{}, "0.00", "0.00"},
+		{FormattedNumber{Number: 0.5, Format: "0%"}, "0.00", "0.50"},
+
+		// Conditions
+		{Float64Value(50), "[<=100]0;[>100]0.0", "50"},
+		{Float64Value(500), "[<=100]0;[>100]0.0", "500.0"},
+		{Float64Value(5551234), "[<=9999999]###-####;(###) ###-####", "555-1234"},
+		{Float64Value(2125551234), "[<=9999999]###-####;(###) ###-####", "(212) 555-1234"},
+		{Float64Value(-5), "[Red][<=100]0;[Blue][>100]0", "-5"},
+		{Float64Value(5), `[>10]"big";[<0]"negative";"small"`, "small"},
+		{Float64Value(-5), `[>=100]"big";[<0]"neg";0`, "neg"},
+		{Float64Value(-5), `[>=100]0;[<0]0.0;0`, "5.0"},
+		{Float64Value(-5), `[>=100]0;[<=0]0.0;0`, "5.0"},
+		{Float64Value(-5), `[>=100]0;[<10]0.0;0`, "-5.0"},
+
+		// Dates and times
+		{dateTime, "yyyy-mm-dd", "2024-03-05"},
+		{dateTime, "yyyy-mm-dd hh:mm", "2024-03-05 14:07"},
+		{dateTime, "d/m/yy", "5/3/24"},
+		{dateTime, "dddd, mmmm d, yyyy", "Tuesday, March 5, 2024"},
+		{dateTime, "ddd mmm dd", "Tue Mar 05"},
+		{dateTime, "mmmmm", "M"},
+		{dateTime, "h:mm AM/PM", "2:07 PM"},
+		{dateTime, "h:mm a/p", "2:07 p"},
+		{dateTime, "hh:mm:ss", "14:07:09"},
+		{dateTime, "mm:ss", "07:09"},
+		{Float64Value(45356), "yyyy-mm-dd", "2024-03-05"},
+		{Float64Value(2958465.5), "yyyy-mm-dd hh:mm", "9999-12-31 12:00"},
+		{Float64Value(45356.999999), "yyyy-mm-dd hh:mm:ss", "2024-03-06 00:00:00"},
+		{Float64Value(0.5 + 1.6/86400), "hh:mm:ss.0", "12:00:01.6"},
+		{Float64Value(0.5 + 1.6/86400), "hh:mm:ss", "12:00:02"},
+		{Float64Value(1.5), "[h]:mm:ss", "36:00:00"},
+		{Float64Value(1.5), "[mm]", "2160"},
+		{Float64Value(0.25), "[h]:mm", "6:00"},
+		{Float64Value(0), "h:mm AM/PM", "12:00 AM"},
+	} {
+		t.Run(fmt.Sprintf("%s/%s", tt.code, tt.value), func(t *testing.T) {
+			nf, err := ParseNumberFormat(tt.code)
+			require.NoError(t, err)
+
+			s, err := nf.Format(tt.value)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, s)
+		})
+	}
+}
+
+func TestNumberFormat_Errors(t *testing.T) {
+	for _, tt := range []struct {
+		code        string
+		value       Value
+		expectedErr string
+	}{
+		{`0 "units`, nil, `invalid number format '0 "units': unterminated string`},
+		{`[Red0`, nil, `invalid number format '[Red0': unterminated '['`},
+		{`0;0;0;@;0`, nil, `invalid number format '0;0;0;@;0': too many sections`},
+		{`[>=abc]0`, nil, `invalid number format '[>=abc]0': invalid condition '>=abc'`},
+		{`0\`, nil, `invalid number format '0\': '\' must be followed by a character`},
+		{"yyyy-mm-dd", Float64Value(-1), "negative numbers cannot be formatted as dates or times"},
+		{"yyyy-mm-dd", Float64Value(2958466), "2958466 is too large to be formatted as a date or time"},
+		{"[h]:mm", Float64Value(1e7), "10000000 is too large to be formatted as a date or time"},
+		{"[>0]0", Float64Value(-1), "no section of format '[>0]0' applies to -1"},
+		{"0.00", ErrorValue{ErrDivideByZero}, ErrDivideByZero.Error()},
+	} {
+		t.Run(tt.code, func(t *testing.T) {
+			nf, err := ParseNumberFormat(tt.code)
+			if tt.value == nil {
+				assert.EqualError(t, err, tt.expectedErr)
+				return
+			}
+
+			require.NoError(t, err)
+			_, err = nf.Format(tt.value)
+			assert.EqualError(t, err, tt.expectedErr)
+		})
+	}
+}
+
+func TestNumberFormat_Cache(t *testing.T) {
+	nf1, err := ParseNumberFormat("0.000")
+	require.NoError(t, err)
+
+	nf2, err := ParseNumberFormat("0.000")
+	require.NoError(t, err)
+	assert.Same(t, nf1, nf2)
+
+	for i := 0; i < 2*maxCachedNumberFormats; i++ {
+		_, err := ParseNumberFormat(fmt.Sprintf(`0 "%d"`, i))
+		require.NoError(t, err)
+	}
+
+	numberFormatsMu.RLock()
+	defer numberFormatsMu.RUnlock()
+	assert.Len(t, numberFormats, maxCachedNumberFormats)
+}
+
+func TestNumberFormat_IsDateTime(t *testing.T) {
+	for code, expected := range map[string]bool{
+		"yyyy-mm-dd":         true,
+		"[h]:mm:ss":          true,
+		`[$-409]d-mmm-yy;@`:  true,
+		"General":            false,
+		"#,##0.00":           false,
+		`0.00 "days"`:        false,
+		"@":                  false,
+		`[Red]0;"d-m-y"`:     false,
+		`"Date: "yyyy-mm-dd`: true,
+		"0.00E+00":           false,
+	} {
+		nf, err := ParseNumberFormat(code)
+		require.NoError(t, err)
+		assert.Equal(t, expected, nf.IsDateTime(), code)
+	}
+}
+
+func TestNumberFormat_MaxDate(t *testing.T) {
+	nf, err := ParseNumberFormat("yyyy-mm-dd")
+	require.NoError(t, err)
+
+	for _, tt := range []struct {
+		ds   DateSystem
+		last float64
+	}{
+		{Date1900, 2958465},
+		{Date1904, 2957003},
+	} {
+		s, err := nf.FormatWith(Float64Value(tt.last), CalcSettings{DateSystem: tt.ds})
+		require.NoError(t, err, tt.ds)
+		assert.Equal(t, "9999-12-31", s, tt.ds)
+
+		_, err = nf.FormatWith(Float64Value(tt.last+1), CalcSettings{DateSystem: tt.ds})
+		assert.Error(t, err, tt.ds)
+	}
+}
@@ -0,0 +1,247 @@
+package sheets
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// currencySymbols are the currency symbols recognized by ParseNumber.
+var currencySymbols = []string{"$", "€", "£", "¥", "₹", "₩", "₽", "CHF"}
+
+// groupSpaces are the spaces that can be used as thousands separators.
+const groupSpaces = " \u00a0\u202f"
+
+var plainNumber = regexp.MustCompile(`^([0-9]+\.?[0-9]*|\.[0-9]+)([eE][-+]?[0-9]+)?$`)
+
+// ParseNumber parses text as a number the same way Excel's VALUE function does
+// in English, understanding currency symbols ("$1,234.50", "12 €"),
+// percentages ("15%"), thousands separators ("1,234,567" or "1 234 567") and
+// accounting negatives ("(200)"). The decimal separator is always "." and ","
+// is always a thousands separator, so text such as "1,5" or "12,34" whose
+// commas do not separate thousands is not a number. Use Locale.ParseNumber to
+// parse numbers written with "," as the decimal separator.
+//
+// Returns the number along with the Excel number format that displays it the
+// same way it was written, such as "$#,##0.00" or "0%", or an empty format if
+// the number was written plainly.
+func ParseNumber(s string) (float64, string, error) {
+	return parseNumber(s)
+}
+
+// parseNumber parses text as a number that uses "." as its decimal separator
+// and "," or spaces as its thousands separators.
+func parseNumber(s string) (float64, string, error) {
+	p := numberParser{text: strings.TrimSpace(s)}
+	n, err := p.parse()
+	if err != nil {
+		return 0, "", ValueErrorf("'%s' is not a valid number", s)
+	}
+
+	return n, p.format(), nil
+}
+
+type numberParser struct {
+	text string
+
+	negative    bool
+	parentheses bool
+	percent     bool
+	currency    string
+	suffix      bool // the currency symbol follows the number
+	grouped     bool
+	decimals    int
+}
+
+func (p *numberParser) parse() (float64, error) {
+	rest := p.text
+	if strings.HasPrefix(rest, "(") && strings.HasSuffix(rest, ")") {
+		p.parentheses = true
+		rest = strings.TrimSpace(rest[1 : len(rest)-1])
+	}
+
+	// The sign and currency symbol can come in either order before the number
+	for i := 0; i < 2; i++ {
+		switch {
+		case strings.HasPrefix(rest, "-") && !p.negative:
+			p.negative = true
+			rest = strings.TrimSpace(rest[1:])
+		case strings.HasPrefix(rest, "+") && !p.negative:
+			rest = strings.TrimSpace(rest[1:])
+		case p.currency == "":
+			if sym, ok := currencyPrefix(rest); ok {
+				p.currency = sym
+				rest = strings.TrimSpace(rest[len(sym):])
+			}
+		}
+	}
+
+	if strings.HasSuffix(rest, "%") {
+		p.percent = true
+		rest = strings.TrimSpace(rest[:len(rest)-1])
+	}
+
+	if p.currency == "" {
+		if sym, ok := currencySuffix(rest); ok {
+			p.currency, p.suffix = sym, true
+			rest = strings.TrimSpace(rest[:len(rest)-len(sym)])
+		}
+	}
+
+	if (p.negative && p.parentheses) || (p.percent && p.currency != "") {
+		return 0, errInvalidNumber
+	}
+
+	digits, err := p.normalize(rest)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := strconv.ParseFloat(digits, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	if p.percent {
+		n /= 100
+	}
+
+	if p.negative || p.parentheses {
+		n = -n
+	}
+
+	return n, nil
+}
+
+var errInvalidNumber = ValueErrorf("invalid number")
+
+// normalize removes the thousands separators from a number and converts its
+// decimal separator to ".".
+func (p *numberParser) normalize(s string) (string, error) {
+	if plainNumber.MatchString(s) && strings.Count(s, ".") <= 1 {
+		if !strings.ContainsAny(s, "eE") {
+			if i := strings.IndexByte(s, '.'); i >= 0 {
+				p.decimals = len(s) - i - 1
+			}
+		}
+
+		return s, nil
+	}
+
+	var (
+		hasSpace = strings.ContainsAny(s, groupSpaces)
+		decimal  = ""
+		group    = ","
+	)
+
+	if hasSpace && !strings.Contains(s, ",") {
+		group = " "
+	}
+
+	if strings.Contains(s, ".") {
+		decimal = "."
+	}
+
+	if hasSpace {
+		for _, r := range groupSpaces {
+			s = strings.ReplaceAll(s, string(r), " ")
+		}
+	}
+
+	intPart, fracPart := s, ""
+	if decimal != "" {
+		if strings.Count(s, decimal) != 1 {
+			return "", errInvalidNumber
+		}
+
+		i := strings.Index(s, decimal)
+		intPart, fracPart = s[:i], s[i+1:]
+		if !isDigits(fracPart) {
+			return "", errInvalidNumber
+		}
+	}
+
+	if group != "" && strings.Contains(intPart, group) {
+		groups := strings.Split(intPart, group)
+		for i, g := range groups {
+			if !isDigits(g) || (i == 0 && len(g) > 3) || (i > 0 && len(g) != 3) {
+				return "", errInvalidNumber
+			}
+		}
+
+		intPart = strings.Join(groups, "")
+		p.grouped = true
+	}
+
+	if !isDigits(intPart) || (intPart == "" && fracPart == "") {
+		return "", errInvalidNumber
+	}
+
+	p.decimals = len(fracPart)
+	if fracPart == "" {
+		return intPart, nil
+	}
+
+	return intPart + "." + fracPart, nil
+}
+
+// format returns the Excel number format for the number as it was written.
+func (p *numberParser) format() string {
+	if p.currency == "" && !p.percent && !p.grouped && !p.parentheses {
+		return ""
+	}
+
+	format := "0"
+	if p.grouped || p.currency != "" {
+		format = "#,##0"
+	}
+
+	if p.decimals > 0 {
+		format += "." + strings.Repeat("0", p.decimals)
+	}
+
+	switch {
+	case p.percent:
+		format += "%"
+	case p.currency != "" && p.suffix:
+		format += " " + p.currency
+	case p.currency != "":
+		format = p.currency + format
+	}
+
+	if p.parentheses {
+		format += ";(" + format + ")"
+	}
+
+	return format
+}
+
+func currencyPrefix(s string) (string, bool) {
+	for _, sym := range currencySymbols {
+		if strings.HasPrefix(s, sym) {
+			return sym, true
+		}
+	}
+
+	return "", false
+}
+
+func currencySuffix(s string) (string, bool) {
+	for _, sym := range currencySymbols {
+		if strings.HasSuffix(s, sym) {
+			return sym, true
+		}
+	}
+
+	return "", false
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,54 @@
+package sheets
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseNumber(t *testing.T) {
+	for _, tt := range []struct {
+		s      string
+		n      float64
+		format string
+	}{
+		{"1234.5", 1234.5, ""},
+		{"  42 ", 42, ""},
+		{"-.5", -0.5, ""},
+		{"1e5", 100000, ""},
+		{"1,234", 1234, "#,##0"},
+		{"1,234,567.25", 1234567.25, "#,##0.00"},
+		{"1 234.5", 1234.5, "#,##0.0"},
+		{"1 234 567", 1234567, "#,##0"},
+		{"$1,234.50", 1234.5, "$#,##0.00"},
+		{"$5", 5, "$#,##0"},
+		{"-$5", -5, "$#,##0"},
+		{"$-5", -5, "$#,##0"},
+		{"€12", 12, "€#,##0"},
+		{"12 €", 12, "#,##0 €"},
+		{"£0.99", 0.99, "£#,##0.00"},
+		{"15%", 0.15, "0%"},
+		{"-12.5%", -0.125, "0.0%"},
+		{"(200)", -200, "0;(0)"},
+		{"($1,234.50)", -1234.5, "$#,##0.00;($#,##0.00)"},
+	} {
+		t.Run(tt.s, func(t *testing.T) {
+			n, format, err := ParseNumber(tt.s)
+			require.NoError(t, err)
+			assert.InDelta(t, tt.n, n, 1e-9)
+			assert.Equal(t, tt.format, format)
+		})
+	}
+
+	for _, s := range []string{
+		"", "abc", "$", "%", "1,23,4", "1.2.3,4,5", "12,34,567", "-(200)", "$15%", "1 2345", "1..2", "--5", "0x10",
+		// "," is always a thousands separator, never a decimal separator
+		"1,5", "12,34", "1,2345", "1.234,5", "1 234,5", "1.234.567", "1.234,56 €",
+	} {
+		t.Run(s, func(t *testing.T) {
+			_, _, err := ParseNumber(s)
+			assert.EqualError(t, err, "'"+s+"' is not a valid number")
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package sheets
+
+import (
+	"math"
+	"strconv"
+)
+
+// A NumericMode controls how numbers are compared, computed and formatted.
+type NumericMode int
+
+const (
+	// ExcelNumerics follows Excel: numbers are displayed with at most 15
+	// significant digits, numbers that are equal to 15 significant digits
+	// compare as equal, and additions or subtractions whose result is
+	// smaller than the operands by more than 15 significant digits are
+	// treated as exactly zero. This is the default.
+	ExcelNumerics NumericMode = iota
+
+	// ExactNumerics uses IEEE 754 double precision arithmetic as-is, and
+	// displays numbers using the fewest digits needed to represent them
+	// exactly.
+	ExactNumerics
+)
+
+// excelSignificantDigits is the number of significant digits Excel keeps
+// when displaying and comparing numbers.
+const excelSignificantDigits = 15
+
+// excelZeroThreshold is how much smaller than its operands the result of an
+// addition or subtraction must be for Excel to treat it as zero.
+const excelZeroThreshold = 1e-15
+
+// Format formats a number for display.
+func (m NumericMode) Format(n float64) string {
+	if m == ExcelNumerics {
+		n = roundSignificant(n, excelSignificantDigits)
+	}
+
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
+
+// compare compares two numbers.
+func (m NumericMode) compare(n1, n2 float64) int {
+	if m == ExcelNumerics {
+		n1 = roundSignificant(n1, excelSignificantDigits)
+		n2 = roundSignificant(n2, excelSignificantDigits)
+	}
+
+	return compare(n1, n2)
+}
+
+// add adds two numbers, which are subtracted if n2 has been negated.
+func (m NumericMode) add(n1, n2 float64) float64 {
+	sum := n1 + n2
+	if m == ExcelNumerics && sum != 0 {
+		magnitude := math.Max(math.Abs(n1), math.Abs(n2))
+		if math.Abs(sum) < magnitude*excelZeroThreshold {
+			return 0
+		}
+	}
+
+	return sum
+}
+
+// sum adds a sequence of numbers.
+func (m NumericMode) sum(nums []float64) float64 {
+	var total float64
+	for _, n := range nums {
+		total = m.add(total, n)
+	}
+
+	return total
+}
+
+// roundSignificant rounds a number to the given number of significant digits.
+func roundSignificant(n float64, digits int) float64 {
+	if n == 0 || math.IsInf(n, 0) || math.IsNaN(n) {
+		return n
+	}
+
+	rounded, err := strconv.ParseFloat(strconv.FormatFloat(n, 'g', digits, 64), 64)
+	if err != nil {
+		return n
+	}
+
+	return rounded
+}
@@ -0,0 +1,66 @@
+package sheets
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// add adds at run time, since constant expressions are computed exactly.
+func add(n1, n2 float64) float64 {
+	return n1 + n2
+}
+
+func TestNumericMode_Format(t *testing.T) {
+	for _, tt := range []struct {
+		n            float64
+		excel, exact string
+	}{
+		{add(0.1, 0.2), "0.3", "0.30000000000000004"},
+		{1.0 / 3.0, "0.333333333333333", "0.3333333333333333"},
+		{123456789012345678, "123456789012346000", "123456789012345680"},
+		{-2.5, "-2.5", "-2.5"},
+		{0, "0", "0"},
+		{math.Inf(1), "+Inf", "+Inf"},
+	} {
+		t.Run(tt.exact, func(t *testing.T) {
+			assert.Equal(t, tt.excel, ExcelNumerics.Format(tt.n))
+			assert.Equal(t, tt.exact, ExactNumerics.Format(tt.n))
+		})
+	}
+}
+
+func TestNumericMode_Arithmetic(t *testing.T) {
+	for _, tt := range []struct {
+		name         string
+		op           Operator
+		v1, v2       Value
+		excel, exact Value
+	}{
+		{"nearly equal sums compare equal", Eq,
+			Float64Value(add(0.1, 0.2)), Float64Value(0.3),
+			BoolValue(true), BoolValue(false)},
+		{"nearly equal sums are not greater", Gt,
+			Float64Value(add(0.1, 0.2)), Float64Value(0.3),
+			BoolValue(false), BoolValue(true)},
+		{"differences in the 15th digit are significant", Eq,
+			Float64Value(0.123456789012345), Float64Value(0.123456789012346),
+			BoolValue(false), BoolValue(false)},
+		{"subtracting nearly equal numbers gives zero", Subtract,
+			Float64Value(add(0.1, 0.2)), Float64Value(0.3),
+			Float64Value(0), Float64Value(add(add(0.1, 0.2), -0.3))},
+		{"adding nearly opposite numbers gives zero", Add,
+			Float64Value(add(1.333, 1.225)), Float64Value(-2.558),
+			Float64Value(0), Float64Value(add(add(1.333, 1.225), -2.558))},
+		{"small differences in small numbers are kept", Subtract,
+			Float64Value(0.0003), Float64Value(0.0001),
+			Float64Value(add(0.0003, -0.0001)), Float64Value(add(0.0003, -0.0001))},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.excel, tt.op.Apply(tt.v1, tt.v2))
+			assert.Equal(t, tt.excel, tt.op.ApplyWith(tt.v1, tt.v2, CalcSettings{NumericMode: ExcelNumerics}))
+			assert.Equal(t, tt.exact, tt.op.ApplyWith(tt.v1, tt.v2, CalcSettings{NumericMode: ExactNumerics}))
+		})
+	}
+}
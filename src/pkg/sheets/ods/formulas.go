@@ -0,0 +1,235 @@
+package ods
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mmihic/sheets/src/pkg/sheets"
+	"github.com/mmihic/sheets/src/pkg/sheets/internal/office"
+)
+
+// openFormulaPrefix is the namespace prefix of formulas written in
+// OpenFormula, the formula syntax of OpenDocument.
+const openFormulaPrefix = "of:"
+
+// microsoftPrefix is the prefix of functions added by Excel that are not
+// part of OpenFormula, as written by LibreOffice.
+const microsoftPrefix = "COM.MICROSOFT."
+
+// microsoftFunctions are the functions written with the microsoftPrefix.
+var microsoftFunctions = map[string]bool{
+	"CONCAT":   true,
+	"IFS":      true,
+	"MAXIFS":   true,
+	"MINIFS":   true,
+	"SWITCH":   true,
+	"TEXTJOIN": true,
+	"XLOOKUP":  true,
+	"XMATCH":   true,
+}
+
+// fromOpenFormula converts a formula written in OpenFormula, such as
+// of:=SUM([.A1:.B4];[$Sheet2.C1]), into the form understood by
+// sheets.ParseFormula.
+func fromOpenFormula(text string) (string, error) {
+	body, ok := strings.CutPrefix(text, openFormulaPrefix)
+	if !ok {
+		return "", fmt.Errorf("unsupported formula syntax '%s'", text)
+	}
+
+	body = strings.TrimPrefix(body, "=")
+
+	var sb strings.Builder
+	for i := 0; i < len(body); {
+		c := body[i]
+		switch {
+		case c == '"':
+			end := office.QuotedEnd(body, i)
+			sb.WriteString(escapeQuoted(body[i:end], '"'))
+			i = end
+		case c == '[':
+			end := referenceEnd(body, i)
+			if end < 0 {
+				return "", fmt.Errorf("unterminated reference in '%s'", text)
+			}
+
+			ref, err := fromReference(body[i+1 : end])
+			if err != nil {
+				return "", err
+			}
+
+			sb.WriteString(ref)
+			i = end + 1
+		case c == ';':
+			// Separates function arguments, and columns of array constants
+			sb.WriteByte(',')
+			i++
+		case c == '|':
+			// Separates rows of array constants
+			sb.WriteByte(';')
+			i++
+		case isNameStart(c):
+			end := i
+			for end < len(body) && isNameChar(body[end]) {
+				end++
+			}
+
+			name := strings.TrimPrefix(body[i:end], microsoftPrefix)
+			if upper := strings.ToUpper(name); (upper == "TRUE" || upper == "FALSE") &&
+				strings.HasPrefix(body[end:], "()") {
+				// Booleans are written as calls to TRUE() and FALSE()
+				end += len("()")
+			}
+
+			sb.WriteString(name)
+			i = end
+		case c >= '0' && c <= '9':
+			// Numbers may contain letters in exponents, such as 1E+5
+			end := i
+			for end < len(body) && (isNameChar(body[end]) ||
+				((body[end] == '+' || body[end] == '-') && (body[end-1] == 'E' || body[end-1] == 'e'))) {
+				end++
+			}
+
+			sb.WriteString(body[i:end])
+			i = end
+		default:
+			sb.WriteByte(c)
+			i++
+		}
+	}
+
+	return sb.String(), nil
+}
+
+// fromReference converts the contents of an OpenFormula reference, such as
+// .A1, $Sheet1.$A$1:.$B$4 or $'My Sheet'.A1, into a reference understood by
+// sheets.ParseFormula.
+func fromReference(ref string) (string, error) {
+	var (
+		sheet string
+		cells []string
+	)
+
+	for i, part := range splitUnquoted(ref, ':') {
+		name, cell, err := splitSheet(part)
+		if err != nil {
+			return "", fmt.Errorf("invalid reference '[%s]': %w", ref, err)
+		}
+
+		if i == 0 {
+			sheet = name
+		}
+
+		cells = append(cells, strings.ReplaceAll(cell, "$", ""))
+	}
+
+	text := strings.Join(cells, ":")
+	if sheet == "" {
+		return text, nil
+	}
+
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(sheet) + "'!" + text, nil
+}
+
+// splitSheet splits one end of an OpenFormula reference into its sheet name,
+// which is empty for the current sheet, and its cell.
+func splitSheet(part string) (string, string, error) {
+	part = strings.TrimPrefix(part, "$")
+	if strings.HasPrefix(part, "'") {
+		end := office.QuotedEnd(part, 0)
+		if end >= len(part) || part[end] != '.' {
+			return "", "", fmt.Errorf("expected '.' after sheet name")
+		}
+
+		name := strings.ReplaceAll(part[1:end-1], "''", "'")
+		return name, part[end+1:], nil
+	}
+
+	i := strings.LastIndexByte(part, '.')
+	if i < 0 {
+		return "", part, nil
+	}
+
+	return part[:i], part[i+1:], nil
+}
+
+// splitUnquoted splits text at each separator outside of single quotes.
+func splitUnquoted(text string, sep byte) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '\'':
+			i = office.QuotedEnd(text, i) - 1
+		case sep:
+			parts = append(parts, text[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(parts, text[start:])
+}
+
+// referenceEnd returns the index of the "]" ending the reference that starts
+// at start, or -1 if there is none.
+func referenceEnd(text string, start int) int {
+	for i := start + 1; i < len(text); i++ {
+		switch text[i] {
+		case '\'':
+			i = office.QuotedEnd(text, i) - 1
+		case ']':
+			return i
+		}
+	}
+
+	return -1
+}
+
+// escapeQuoted converts quoted text whose quotes are escaped by doubling them
+// into quoted text whose quotes and backslashes are escaped by a backslash.
+func escapeQuoted(quoted string, quote byte) string {
+	q := string(quote)
+	inner := strings.TrimSuffix(strings.TrimPrefix(quoted, q), q)
+	inner = strings.ReplaceAll(inner, `\`, `\\`)
+	inner = strings.ReplaceAll(inner, q+q, `\`+q)
+	return q + inner + q
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+func isNameChar(c byte) bool {
+	return isNameStart(c) || c == '.' || ('0' <= c && c <= '9')
+}
+
+// openSyntax is the syntax of formulas in OpenFormula, with references in
+// brackets and sheet names in single quotes.
+var openSyntax = &office.FormulaSyntax{
+	ArgumentSeparator: ';',
+	ColumnSeparator:   ';',
+	RowSeparator:      '|',
+	ReferenceStart:    "[",
+	ReferenceEnd:      "]",
+	SheetMarker:       "$",
+	CellMarker:        ".",
+	FunctionPrefix:    microsoftPrefix,
+	PrefixedFunctions: microsoftFunctions,
+	True:              "TRUE()",
+	False:             "FALSE()",
+	Time:              openTime,
+}
+
+// toOpenFormula returns a formula written in OpenFormula, including its
+// namespace prefix.
+func toOpenFormula(f sheets.Formula, settings sheets.CalcSettings) string {
+	return openFormulaPrefix + "=" + openSyntax.Format(f, settings)
+}
+
+// openTime returns a time constant. OpenFormula has no time constants, but
+// converts text in this form to times.
+func openTime(t time.Time, _ sheets.CalcSettings) string {
+	return `"` + t.Format("2006-01-02 15:04:05") + `"`
+}
@@ -0,0 +1,77 @@
+package ods
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mmihic/sheets/src/pkg/sheets"
+)
+
+func TestFromOpenFormula(t *testing.T) {
+	for _, tt := range []struct {
+		text     string
+		expected string
+	}{
+		{"of:=[.A1]+[.B2]", "A1+B2"},
+		{"of:=SUM([$Sheet1.A1:.B4];[.$C$1])", "SUM('Sheet1'!A1:B4,C1)"},
+		{"of:=[$'My ''Sheet'''.A1]", `'My \'Sheet\''!A1`},
+		{`of:=COM.MICROSOFT.CONCAT("say ""hi"" \o/";TRUE())`, `CONCAT("say \"hi\" \\o/",TRUE)`},
+		{"of:=IF(FALSE();1E+5;{1;2|3;4})", "IF(FALSE,1E+5,{1,2;3,4})"},
+		{"of:=[Sheet1.A1]", "'Sheet1'!A1"},
+	} {
+		converted, err := fromOpenFormula(tt.text)
+		require.NoError(t, err, tt.text)
+		assert.Equal(t, tt.expected, converted, tt.text)
+	}
+
+	for _, text := range []string{"msoxl:=A1+B2", "of:=[.A1"} {
+		_, err := fromOpenFormula(text)
+		assert.Error(t, err, text)
+	}
+}
+
+func TestToOpenFormula(t *testing.T) {
+	for _, tt := range []struct {
+		text     string
+		expected string
+	}{
+		{"A1+B2", "of:=[.A1]+[.B2]"},
+		{"SUM(A1:B4, 'My Sheet'!C3, `It's`!D1:D4)", "of:=SUM([.A1:.B4];[$'My Sheet'.C3];[$'It''s'.D1:.D4])"},
+		{`CONCAT("say \"hi\"", TRUE, {1,2;3,4})`, `of:=COM.MICROSOFT.CONCAT("say ""hi""";TRUE();{1;2|3;4})`},
+		{"A1*B1+C1/2", "of:=([.A1]*[.B1])+([.C1]/2)"},
+	} {
+		f, err := sheets.ParseFormula(tt.text)
+		require.NoError(t, err, tt.text)
+		assert.Equal(t, tt.expected, toOpenFormula(f, sheets.CalcSettings{}), tt.text)
+
+		// Formulas read back as the same formula
+		converted, err := fromOpenFormula(tt.expected)
+		require.NoError(t, err, tt.expected)
+
+		parsed, err := sheets.ParseFormula(converted)
+		require.NoError(t, err, converted)
+		assert.Equal(t, f, parsed, tt.expected)
+	}
+}
+
+func TestAbsoluteAddress(t *testing.T) {
+	assert.Equal(t, "$'Data'.$A$1:.$B$4", openSyntax.AbsoluteReference("Data", mustParseRange(t, "A1:B4")))
+	assert.Equal(t, "$'It''s'.$C$2:.$C$2", openSyntax.AbsoluteReference("It's", mustParseRange(t, "C2:C2")))
+}
+
+func TestParseRangeAddress(t *testing.T) {
+	sheet, r, ok := parseRangeAddress("$'Data'.$A$1:.$B$4")
+	require.True(t, ok)
+	assert.Equal(t, "Data", sheet)
+	assert.Equal(t, mustParseRange(t, "A1:B4"), r)
+
+	sheet, r, ok = parseRangeAddress("$Sheet1.$C$2")
+	require.True(t, ok)
+	assert.Equal(t, "Sheet1", sheet)
+	assert.Equal(t, mustParseRange(t, "C2:C2"), r)
+
+	_, _, ok = parseRangeAddress(".A1")
+	assert.False(t, ok)
+}
@@ -0,0 +1,480 @@
+// Package ods reads and writes OpenDocument spreadsheets (.ods), as used by
+// LibreOffice, translating formulas between OpenFormula and the formulas of
+// the sheets package.
+package ods
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mmihic/sheets/src/pkg/sheets"
+	"github.com/mmihic/sheets/src/pkg/sheets/internal/office"
+)
+
+// The namespaces of the elements and attributes that are read and written.
+const (
+	nsOffice   = "urn:oasis:names:tc:opendocument:xmlns:office:1.0"
+	nsTable    = "urn:oasis:names:tc:opendocument:xmlns:table:1.0"
+	nsText     = "urn:oasis:names:tc:opendocument:xmlns:text:1.0"
+	nsStyle    = "urn:oasis:names:tc:opendocument:xmlns:style:1.0"
+	nsNumber   = "urn:oasis:names:tc:opendocument:xmlns:datastyle:1.0"
+	nsOF       = "urn:oasis:names:tc:opendocument:xmlns:of:1.2"
+	nsCalcExt  = "urn:org:documentfoundation:names:experimental:calc:xmlns:calcext:1.0"
+	nsManifest = "urn:oasis:names:tc:opendocument:xmlns:manifest:1.0"
+)
+
+// mimeType is the media type of an OpenDocument spreadsheet.
+const mimeType = "application/vnd.oasis.opendocument.spreadsheet"
+
+// nullDate is the date of serial number 0 in OpenDocument spreadsheets, unless
+// the document sets another.
+var nullDate = time.Date(1899, time.December, 30, 0, 0, 0, 0, time.UTC)
+
+// A Workbook is a workbook read from an ODS file. It is a sheets.Workbook
+// whose sheets are MutableSheets holding the values and formulas of each
+// table, and whose named ranges are the named ranges of the document.
+// Formulas are computed live; the results cached when the file was saved are
+// available from CachedSheet.
+type Workbook struct {
+	*office.Workbook
+}
+
+// Open reads the ODS file at the given path.
+func Open(name string) (*Workbook, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() { _ = f.Close() }()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	return Read(f, info.Size())
+}
+
+// Read reads an ODS file of the given size.
+func Read(r io.ReaderAt, size int64) (*Workbook, error) {
+	z, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ods file: %w", err)
+	}
+
+	for _, f := range z.File {
+		if f.Name != "content.xml" {
+			continue
+		}
+
+		content, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+
+		defer func() { _ = content.Close() }()
+		return ReadContent(content)
+	}
+
+	return nil, errors.New("invalid ods file: missing content.xml")
+}
+
+// ReadContent reads the content.xml of an ODS file, or a flat OpenDocument
+// spreadsheet (.fods), which holds the same elements.
+func ReadContent(r io.Reader) (*Workbook, error) {
+	rd := &reader{
+		dec: xml.NewDecoder(r),
+		wb:  &Workbook{Workbook: office.NewWorkbook()},
+	}
+
+	if err := rd.read(); err != nil {
+		return nil, fmt.Errorf("invalid ods content: %w", err)
+	}
+
+	return rd.wb, nil
+}
+
+// A reader reads the tables of a document as a stream of XML tokens.
+type reader struct {
+	dec *xml.Decoder
+	wb  *Workbook
+
+	dateSystem  sheets.DateSystem
+	namedRanges []namedRange
+}
+
+type namedRange struct {
+	name    string
+	address string
+}
+
+// A table is a table being read.
+type table struct {
+	name     string
+	values   [][]sheets.Value
+	formulas map[sheets.Pos]string
+	row      int
+}
+
+// A cell is a cell being read, which may be repeated across columns.
+type cell struct {
+	value   sheets.Value
+	formula string
+	repeat  int
+}
+
+func (rd *reader) read() error {
+	var t *table
+	for {
+		tok, err := rd.dec.Token()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+
+		switch tt := tok.(type) {
+		case xml.StartElement:
+			switch {
+			case is(tt.Name, nsTable, "null-date"):
+				if attr(tt, nsTable, "date-value") == "1904-01-01" {
+					rd.dateSystem = sheets.Date1904
+				}
+			case is(tt.Name, nsTable, "named-range"):
+				rd.namedRanges = append(rd.namedRanges, namedRange{
+					name:    attr(tt, nsTable, "name"),
+					address: attr(tt, nsTable, "cell-range-address"),
+				})
+			case is(tt.Name, nsTable, "table") && t == nil:
+				t = &table{name: attr(tt, nsTable, "name"), formulas: make(map[sheets.Pos]string)}
+			case is(tt.Name, nsTable, "table-row") && t != nil:
+				if err := rd.readRow(t, tt); err != nil {
+					return fmt.Errorf("table '%s': %w", t.name, err)
+				}
+			}
+		case xml.EndElement:
+			if is(tt.Name, nsTable, "table") && t != nil {
+				if err := rd.addTable(t); err != nil {
+					return fmt.Errorf("table '%s': %w", t.name, err)
+				}
+
+				t = nil
+			}
+		}
+	}
+
+	rd.wb.SetDateSystem(rd.dateSystem)
+	for _, nr := range rd.namedRanges {
+		if sheet, r, ok := parseRangeAddress(nr.address); ok {
+			rd.wb.SetNamedRange(nr.name, sheets.NamedRange{Sheet: sheet, Range: r})
+		}
+	}
+
+	return nil
+}
+
+// maxRepeat is the most times a row or cell with content is repeated.
+// Documents often end with a row or cell repeated to the maximum size of a
+// sheet, which is only expanded if it is empty.
+const maxRepeat = 1 << 16
+
+func (rd *reader) readRow(t *table, start xml.StartElement) error {
+	repeat := repeatCount(attr(start, nsTable, "number-rows-repeated"))
+
+	var cells []cell
+	for {
+		tok, err := rd.dec.Token()
+		if err != nil {
+			return err
+		}
+
+		switch tt := tok.(type) {
+		case xml.StartElement:
+			if is(tt.Name, nsTable, "table-cell") || is(tt.Name, nsTable, "covered-table-cell") {
+				c, err := rd.readCell(tt)
+				if err != nil {
+					return fmt.Errorf("row %d: %w", t.row+1, err)
+				}
+
+				cells = append(cells, c)
+			} else if err := rd.dec.Skip(); err != nil {
+				return err
+			}
+		case xml.EndElement:
+			return t.addRow(cells, repeat)
+		}
+	}
+}
+
+// addRow adds a row of cells to the table, repeated as many times as given.
+func (t *table) addRow(cells []cell, repeat int) error {
+	// Trailing blank cells are not stored
+	for len(cells) != 0 && isBlankCell(cells[len(cells)-1]) {
+		cells = cells[:len(cells)-1]
+	}
+
+	if len(cells) == 0 {
+		t.row += repeat
+		return nil
+	}
+
+	if repeat > maxRepeat {
+		return fmt.Errorf("row %d is repeated %d times", t.row+1, repeat)
+	}
+
+	for i := 0; i < repeat; i++ {
+		col := 0
+		for _, c := range cells {
+			if c.repeat > maxRepeat {
+				return fmt.Errorf("cell %s is repeated %d times", sheets.Pos{Row: t.row, Col: col}, c.repeat)
+			}
+
+			for j := 0; j < c.repeat; j++ {
+				pos := sheets.Pos{Row: t.row, Col: col}
+				if !isBlankCell(c) {
+					t.values = office.SetValue(t.values, pos, c.value)
+				}
+
+				if c.formula != "" {
+					t.formulas[pos] = c.formula
+				}
+
+				col++
+			}
+		}
+
+		t.row++
+	}
+
+	return nil
+}
+
+func isBlankCell(c cell) bool {
+	_, blank := c.value.(sheets.BlankValue)
+	return blank && c.formula == ""
+}
+
+// readCell reads a cell, returning its value or cached result and its
+// formula.
+func (rd *reader) readCell(start xml.StartElement) (cell, error) {
+	text, err := rd.readText()
+	if err != nil {
+		return cell{}, err
+	}
+
+	c := cell{
+		formula: attr(start, nsTable, "formula"),
+		repeat:  repeatCount(attr(start, nsTable, "number-columns-repeated")),
+	}
+
+	valueType := attr(start, nsOffice, "value-type")
+	if attr(start, nsCalcExt, "value-type") == "error" {
+		valueType = "error"
+	}
+
+	switch valueType {
+	case "float", "percentage", "currency":
+		n, err := strconv.ParseFloat(attr(start, nsOffice, "value"), 64)
+		if err != nil {
+			return cell{}, fmt.Errorf("invalid number '%s'", attr(start, nsOffice, "value"))
+		}
+
+		c.value = sheets.Float64Value(n)
+	case "date":
+		tm, err := parseDate(attr(start, nsOffice, "date-value"))
+		if err != nil {
+			return cell{}, err
+		}
+
+		c.value = sheets.TimeValue(tm)
+	case "time":
+		d, err := parseDuration(attr(start, nsOffice, "time-value"))
+		if err != nil {
+			return cell{}, err
+		}
+
+		c.value = sheets.TimeValue(nullDate.Add(d))
+	case "boolean":
+		c.value = sheets.BoolValue(attr(start, nsOffice, "boolean-value") == "true")
+	case "string":
+		if s, ok := attrOK(start, nsOffice, "string-value"); ok {
+			text = s
+		}
+
+		c.value = sheets.StringValue(text)
+	case "error":
+		c.value = sheets.ErrorValue{Err: sheets.ErrorForCode(strings.TrimSpace(text))}
+	default:
+		c.value = sheets.BlankValue{}
+		if text != "" {
+			c.value = sheets.StringValue(text)
+		}
+	}
+
+	return c, nil
+}
+
+// readText reads the paragraphs within a cell, joining them with newlines,
+// and consumes the end of the cell.
+func (rd *reader) readText() (string, error) {
+	var (
+		sb         strings.Builder
+		paragraphs int
+		depth      int
+	)
+
+	for {
+		tok, err := rd.dec.Token()
+		if err != nil {
+			return "", err
+		}
+
+		switch tt := tok.(type) {
+		case xml.StartElement:
+			switch {
+			case depth == 0 && is(tt.Name, nsText, "p"):
+				if paragraphs != 0 {
+					sb.WriteByte('\n')
+				}
+
+				paragraphs++
+			case depth == 0:
+				// Annotations and other content outside of paragraphs
+				if err := rd.dec.Skip(); err != nil {
+					return "", err
+				}
+
+				continue
+			case is(tt.Name, nsText, "s"):
+				sb.WriteString(strings.Repeat(" ", repeatCount(attr(tt, nsText, "c"))))
+			case is(tt.Name, nsText, "tab"):
+				sb.WriteByte('\t')
+			case is(tt.Name, nsText, "line-break"):
+				sb.WriteByte('\n')
+			}
+
+			depth++
+		case xml.EndElement:
+			if depth == 0 {
+				return sb.String(), nil
+			}
+
+			depth--
+		case xml.CharData:
+			if depth != 0 {
+				sb.Write(tt)
+			}
+		}
+	}
+}
+
+// addTable adds a table that has been read to the workbook.
+func (rd *reader) addTable(t *table) error {
+	return rd.wb.AddSheetValues(t.name, t.values, t.formulas, func(text string) (sheets.Formula, error) {
+		converted, err := fromOpenFormula(text)
+		if err != nil {
+			return nil, err
+		}
+
+		f, err := sheets.ParseFormula(converted)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse formula '%s': %w", text, err)
+		}
+
+		return f, nil
+	})
+}
+
+// parseRangeAddress parses the address of a named range, such as
+// $Sheet1.$A$1:.$B$4, into a sheet name and range.
+func parseRangeAddress(address string) (string, sheets.Range, bool) {
+	converted, err := fromReference(address)
+	if err != nil {
+		return "", sheets.Range{}, false
+	}
+
+	f, err := sheets.ParseFormula(converted)
+	if err != nil {
+		return "", sheets.Range{}, false
+	}
+
+	switch ft := f.(type) {
+	case *sheets.CellRangeReference:
+		return ft.Sheet, ft.Range, ft.Sheet != ""
+	case *sheets.CellReference:
+		return ft.Sheet, sheets.Range{
+			StartRow: ft.Pos.Row, StartCol: ft.Pos.Col,
+			EndRow: ft.Pos.Row, EndCol: ft.Pos.Col,
+		}, ft.Sheet != ""
+	default:
+		return "", sheets.Range{}, false
+	}
+}
+
+// parseDate parses a date value, which is either a date or a date and time
+// without a time zone.
+func parseDate(s string) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02T15:04:05.999999999", "2006-01-02"} {
+		if tm, err := time.Parse(layout, s); err == nil {
+			return tm, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid date '%s'", s)
+}
+
+// parseDuration parses a time value, which is an ISO 8601 duration such as
+// PT12H30M00S.
+func parseDuration(s string) (time.Duration, error) {
+	rest, ok := strings.CutPrefix(s, "PT")
+	if !ok {
+		return 0, fmt.Errorf("invalid time '%s'", s)
+	}
+
+	d, err := time.ParseDuration(strings.ToLower(rest))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time '%s'", s)
+	}
+
+	return d, nil
+}
+
+// repeatCount parses the number of times a row, cell or space is repeated,
+// which defaults to one.
+func repeatCount(s string) int {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 {
+		return 1
+	}
+
+	return n
+}
+
+func is(name xml.Name, space, local string) bool {
+	return name.Space == space && name.Local == local
+}
+
+func attr(start xml.StartElement, space, local string) string {
+	v, _ := attrOK(start, space, local)
+	return v
+}
+
+func attrOK(start xml.StartElement, space, local string) (string, bool) {
+	for _, a := range start.Attr {
+		if is(a.Name, space, local) {
+			return a.Value, true
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,169 @@
+package ods
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mmihic/sheets/src/pkg/sheets"
+)
+
+const testContent = `<?xml version="1.0" encoding="UTF-8"?>
+<office:document-content
+  xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0"
+  xmlns:table="urn:oasis:names:tc:opendocument:xmlns:table:1.0"
+  xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0"
+  xmlns:of="urn:oasis:names:tc:opendocument:xmlns:of:1.2"
+  xmlns:calcext="urn:org:documentfoundation:names:experimental:calc:xmlns:calcext:1.0">
+<office:body><office:spreadsheet>
+<table:calculation-settings><table:null-date table:date-value="1904-01-01"/></table:calculation-settings>
+<table:table table:name="Data">
+<table:table-column table:number-columns-repeated="1024"/>
+<table:table-row>
+<table:table-cell office:value-type="float" office:value="1.5"><text:p>1.5</text:p></table:table-cell>
+<table:table-cell office:value-type="percentage" office:value="0.25"><text:p>25%</text:p></table:table-cell>
+<table:table-cell office:value-type="date" office:date-value="2024-03-05"><text:p>03/05/24</text:p></table:table-cell>
+<table:table-cell office:value-type="date" office:date-value="2024-03-05T12:30:00"><text:p>03/05/24 12:30</text:p></table:table-cell>
+<table:table-cell office:value-type="time" office:time-value="PT06H15M00S"><text:p>06:15</text:p></table:table-cell>
+<table:table-cell table:number-columns-repeated="1019"/>
+</table:table-row>
+<table:table-row>
+<table:table-cell office:value-type="boolean" office:boolean-value="true"><text:p>TRUE</text:p></table:table-cell>
+<table:table-cell office:value-type="string"><text:p>two<text:s text:c="2"/><text:span>spaced</text:span></text:p><text:p>lines<text:tab/>tabbed</text:p></table:table-cell>
+<table:table-cell office:value-type="string" office:string-value="stored"><text:p>shown</text:p></table:table-cell>
+<table:covered-table-cell/>
+<table:table-cell office:value-type="float" office:value="0" calcext:value-type="error"><text:p>#DIV/0!</text:p></table:table-cell>
+</table:table-row>
+<table:table-row table:number-rows-repeated="2"><table:table-cell table:number-columns-repeated="1024"/></table:table-row>
+<table:table-row>
+<table:table-cell table:formula="of:=[.A1]*2" office:value-type="float" office:value="4"><text:p>4</text:p></table:table-cell>
+<table:table-cell table:number-columns-repeated="2" table:formula="of:=SUM([$Data.A1:.A1])" office:value-type="float" office:value="1.5"><text:p>1.5</text:p></table:table-cell>
+<table:table-cell table:formula="msoxl:=A1" office:value-type="float" office:value="1.5"><text:p>1.5</text:p></table:table-cell>
+<table:table-cell table:formula="of:=[.A1]+" office:value-type="float" office:value="7"><text:p>7</text:p></table:table-cell>
+</table:table-row>
+<table:table-row table:number-rows-repeated="1048570"><table:table-cell table:number-columns-repeated="1024"/></table:table-row>
+</table:table>
+<table:table table:name="Other Sheet">
+<table:table-row><table:table-cell office:value-type="float" office:value="10"><table:annotation><text:p>note</text:p></table:annotation><text:p>10</text:p></table:table-cell></table:table-row>
+<table:table-row table:number-rows-repeated="2"><table:table-cell office:value-type="string"><text:p>x</text:p></table:table-cell></table:table-row>
+</table:table>
+<table:named-expressions>
+<table:named-range table:name="Firsts" table:base-cell-address="$Data.$A$1" table:cell-range-address="$Data.$A$1:.$A$2"/>
+<table:named-range table:name="Single" table:base-cell-address="$'Other Sheet'.$A$1" table:cell-range-address="$'Other Sheet'.$A$1"/>
+</table:named-expressions>
+</office:spreadsheet></office:body>
+</office:document-content>`
+
+func zipContent(t *testing.T, content string) []byte {
+	var buf bytes.Buffer
+	z := zip.NewWriter(&buf)
+	w, err := z.Create("content.xml")
+	require.NoError(t, err)
+
+	_, err = w.Write([]byte(content))
+	require.NoError(t, err)
+	require.NoError(t, z.Close())
+	return buf.Bytes()
+}
+
+func TestRead(t *testing.T) {
+	data := zipContent(t, testContent)
+	wb, err := Read(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"Data", "Other Sheet"}, wb.SheetNames())
+	assert.Equal(t, sheets.Date1904, wb.Evaluator().DateSystem)
+
+	data1 := wb.Sheet("Data")
+	assert.Equal(t, sheets.Dimensions{EndRow: 4, EndCol: 4}, data1.Dimensions())
+	assertCells(t, data1, map[string]sheets.Value{
+		"A1": sheets.Float64Value(1.5),
+		"B1": sheets.Float64Value(0.25),
+		"C1": sheets.TimeValue(time.Date(2024, time.March, 5, 0, 0, 0, 0, time.UTC)),
+		"D1": sheets.TimeValue(time.Date(2024, time.March, 5, 12, 30, 0, 0, time.UTC)),
+		"E1": sheets.TimeValue(time.Date(1899, time.December, 30, 6, 15, 0, 0, time.UTC)),
+		"A2": sheets.BoolValue(true),
+		"B2": sheets.StringValue("two  spaced\nlines\ttabbed"),
+		"C2": sheets.StringValue("stored"),
+		"D2": sheets.BlankValue{},
+		"A3": sheets.BlankValue{},
+		"A5": sheets.Float64Value(3),
+		"B5": sheets.Float64Value(1.5),
+		"C5": sheets.Float64Value(1.5),
+		"D5": sheets.Float64Value(1.5),
+		"E5": sheets.Float64Value(7),
+	})
+
+	v, err := data1.Get(context.Background(), mustParsePos(t, "E2"))
+	require.NoError(t, err)
+	require.IsType(t, sheets.ErrorValue{}, v)
+	assert.ErrorIs(t, v.(sheets.ErrorValue).Err, sheets.ErrDivideByZero)
+
+	// The cached results are those saved with the file
+	assertCells(t, wb.CachedSheet("Data"), map[string]sheets.Value{
+		"A5": sheets.Float64Value(4),
+		"C5": sheets.Float64Value(1.5),
+	})
+
+	assert.Nil(t, wb.CachedSheet("Missing"))
+	assert.Len(t, wb.FormulaErrors(), 2)
+	assert.Contains(t, wb.FormulaErrors()[sheets.CellAddress{Sheet: "Data", Pos: mustParsePos(t, "D5")}].Error(),
+		"unsupported formula syntax")
+	assert.Contains(t, wb.FormulaErrors()[sheets.CellAddress{Sheet: "Data", Pos: mustParsePos(t, "E5")}].Error(),
+		"unable to parse formula 'of:=[.A1]+'")
+
+	other := wb.Sheet("Other Sheet")
+	assert.Equal(t, sheets.Dimensions{EndRow: 2, EndCol: 0}, other.Dimensions())
+	assertCells(t, other, map[string]sheets.Value{
+		"A1": sheets.Float64Value(10),
+		"A2": sheets.StringValue("x"),
+		"A3": sheets.StringValue("x"),
+	})
+
+	assert.Equal(t, []string{"Firsts", "Single"}, wb.NamedRangeNames())
+	named, ok := wb.NamedRange("Single")
+	require.True(t, ok)
+	assert.Equal(t, sheets.NamedRange{Sheet: "Other Sheet", Range: mustParseRange(t, "A1:A1")}, named)
+}
+
+func TestReadContent_Invalid(t *testing.T) {
+	for _, content := range []string{
+		`<office:document-content`,
+		strings.Replace(testContent, `office:value="1.5"><text:p>1.5`, `office:value="x"><text:p>1.5`, 1),
+		strings.Replace(testContent, `PT06H15M00S`, `06:15`, 1),
+		strings.Replace(testContent, `table:number-rows-repeated="2"><table:table-cell office`,
+			`table:number-rows-repeated="100000"><table:table-cell office`, 1),
+	} {
+		_, err := ReadContent(strings.NewReader(content))
+		assert.Error(t, err)
+	}
+
+	_, err := Read(bytes.NewReader([]byte("not a zip")), 9)
+	assert.Error(t, err)
+}
+
+func assertCells(t *testing.T, s sheets.Sheet, expected map[string]sheets.Value) {
+	for posText, expectedValue := range expected {
+		v, err := s.Get(context.TODO(), mustParsePos(t, posText))
+		require.NoError(t, err)
+		assert.Equal(t, expectedValue, v, posText)
+	}
+}
+
+func mustParsePos(t *testing.T, s string) sheets.Pos {
+	pos, err := sheets.ParsePos(s)
+	require.NoError(t, err)
+	return pos
+}
+
+func mustParseRange(t *testing.T, s string) sheets.Range {
+	r, err := sheets.ParseRange(s)
+	require.NoError(t, err)
+	return r
+}
@@ -0,0 +1,357 @@
+package ods
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mmihic/sheets/src/pkg/sheets"
+	"github.com/mmihic/sheets/src/pkg/sheets/internal/office"
+)
+
+// A WriteOption is an option for writing an ODS file.
+type WriteOption func(opts *writeOptions)
+
+type writeOptions struct {
+	office.WriteOptions
+}
+
+// WithSheets writes the sheets with the given names, in order. Required when
+// writing a DataSet that does not list its sheets with a SheetNames method.
+func WithSheets(names ...string) WriteOption {
+	return office.WithSheets[writeOptions](names...)
+}
+
+const (
+	dateStyle     = "ce1"
+	dateTimeStyle = "ce2"
+
+	xmlHeader = `<?xml version="1.0" encoding="UTF-8"?>` + "\n"
+)
+
+// WriteFile writes the sheets of a DataSet to an ODS file at the given path.
+func WriteFile(ctx context.Context, name string, ds sheets.DataSet, opts ...WriteOption) error {
+	return office.WriteFile(name, func(w io.Writer) error {
+		return Write(ctx, w, ds, opts...)
+	})
+}
+
+// WriteSheet writes a single sheet as an ODS file, giving the sheet the given
+// name.
+func WriteSheet(ctx context.Context, w io.Writer, name string, s sheets.Sheet, opts ...WriteOption) error {
+	opts = append([]WriteOption{WithSheets(name)}, opts...)
+	return Write(ctx, w, office.SingleSheet(name, s), opts...)
+}
+
+// Write writes the sheets of a DataSet as an ODS file.
+//
+// Values keep their types: numbers, booleans and text are written as such,
+// TimeValues are written as dates with a date style, and errors are written
+// as error cells in the form used by LibreOffice. FormattedNumbers are written
+// as plain numbers. Cells of a FormulaSheet that contain formulas are written
+// with the formula, in OpenFormula, along with its computed value.
+//
+// If the DataSet is a Workbook, its named ranges are written as named ranges,
+// and its date system and time zone are used for dates.
+func Write(ctx context.Context, w io.Writer, ds sheets.DataSet, opts ...WriteOption) error {
+	var options writeOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	toWrite, err := office.Sheets(ds, options.SheetNames, sheetNaming)
+	if err != nil {
+		return err
+	}
+
+	wr := &writer{z: zip.NewWriter(w), settings: office.CalcSettings(ds)}
+
+	var sb strings.Builder
+	sb.WriteString(xmlHeader)
+	fmt.Fprintf(&sb, `<office:document-content xmlns:office="%s" xmlns:table="%s" xmlns:text="%s" `+
+		`xmlns:style="%s" xmlns:number="%s" xmlns:of="%s" xmlns:calcext="%s" office:version="1.2">`,
+		nsOffice, nsTable, nsText, nsStyle, nsNumber, nsOF, nsCalcExt)
+	sb.WriteString(automaticStyles)
+	sb.WriteString(`<office:body><office:spreadsheet>`)
+	if wr.settings.DateSystem == sheets.Date1904 {
+		sb.WriteString(`<table:calculation-settings><table:null-date table:date-value="1904-01-01"/>` +
+			`</table:calculation-settings>`)
+	}
+
+	for _, s := range toWrite {
+		if err := wr.writeTable(ctx, &sb, s.Name, s.Sheet); err != nil {
+			return fmt.Errorf("unable to write sheet '%s': %w", s.Name, err)
+		}
+	}
+
+	writeNamedRanges(&sb, ds, toWrite)
+	sb.WriteString(`</office:spreadsheet></office:body></office:document-content>`)
+
+	// The media type must come first, uncompressed, so that the type of the
+	// file can be recognized from its first bytes
+	mime, err := wr.z.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(mime, mimeType); err != nil {
+		return err
+	}
+
+	if err := wr.writePart("META-INF/manifest.xml", xmlHeader+
+		`<manifest:manifest xmlns:manifest="`+nsManifest+`" manifest:version="1.2">`+
+		`<manifest:file-entry manifest:full-path="/" manifest:version="1.2" manifest:media-type="`+mimeType+`"/>`+
+		`<manifest:file-entry manifest:full-path="content.xml" manifest:media-type="text/xml"/>`+
+		`</manifest:manifest>`); err != nil {
+		return err
+	}
+
+	if err := wr.writePart("content.xml", sb.String()); err != nil {
+		return err
+	}
+
+	return wr.z.Close()
+}
+
+// automaticStyles holds the cell styles for dates and times.
+const automaticStyles = `<office:automatic-styles>` +
+	`<number:date-style style:name="N1">` +
+	`<number:year number:style="long"/><number:text>-</number:text>` +
+	`<number:month number:style="long"/><number:text>-</number:text>` +
+	`<number:day number:style="long"/>` +
+	`</number:date-style>` +
+	`<number:date-style style:name="N2">` +
+	`<number:year number:style="long"/><number:text>-</number:text>` +
+	`<number:month number:style="long"/><number:text>-</number:text>` +
+	`<number:day number:style="long"/><number:text> </number:text>` +
+	`<number:hours number:style="long"/><number:text>:</number:text>` +
+	`<number:minutes number:style="long"/><number:text>:</number:text>` +
+	`<number:seconds number:style="long"/>` +
+	`</number:date-style>` +
+	`<style:style style:name="` + dateStyle + `" style:family="table-cell" style:data-style-name="N1"/>` +
+	`<style:style style:name="` + dateTimeStyle + `" style:family="table-cell" style:data-style-name="N2"/>` +
+	`</office:automatic-styles>`
+
+// sheetNaming holds the rules for naming the tables of a document.
+var sheetNaming = office.SheetNaming{}
+
+// A writer writes the parts of an ODS file.
+type writer struct {
+	z        *zip.Writer
+	settings sheets.CalcSettings
+}
+
+func (wr *writer) writeTable(ctx context.Context, sb *strings.Builder, name string, s sheets.Sheet) error {
+	fs, _ := s.(sheets.FormulaSheet)
+	dims := s.Dimensions()
+
+	sb.WriteString(`<table:table table:name="`)
+	writeText(sb, name)
+	fmt.Fprintf(sb, `"><table:table-column table:number-columns-repeated="%d"/>`, dims.EndCol+1)
+
+	blankRows := 0
+	for row := 0; row <= dims.EndRow; row++ {
+		var (
+			cells  strings.Builder
+			blanks int
+		)
+
+		for col := 0; col <= dims.EndCol; col++ {
+			pos := sheets.Pos{Row: row, Col: col}
+			v, err := s.Get(ctx, pos)
+			if err != nil {
+				var posErr sheets.InvalidPosError
+				if !errors.As(err, &posErr) {
+					return err
+				}
+
+				v = sheets.BlankValue{}
+			}
+
+			var f sheets.Formula
+			if fs != nil {
+				f, _ = fs.Formula(pos)
+			}
+
+			if _, blank := v.(sheets.BlankValue); blank && f == nil {
+				blanks++
+				continue
+			}
+
+			writeBlankCells(&cells, blanks)
+			blanks = 0
+			wr.writeCell(&cells, v, f)
+		}
+
+		if cells.Len() == 0 {
+			blankRows++
+			continue
+		}
+
+		writeBlankRows(sb, blankRows)
+		blankRows = 0
+		sb.WriteString(`<table:table-row>`)
+		sb.WriteString(cells.String())
+		sb.WriteString(`</table:table-row>`)
+	}
+
+	sb.WriteString(`</table:table>`)
+	return nil
+}
+
+// writeBlankCells writes a run of blank cells as a single repeated cell.
+func writeBlankCells(sb *strings.Builder, n int) {
+	switch {
+	case n == 1:
+		sb.WriteString(`<table:table-cell/>`)
+	case n > 1:
+		fmt.Fprintf(sb, `<table:table-cell table:number-columns-repeated="%d"/>`, n)
+	}
+}
+
+// writeBlankRows writes a run of blank rows as a single repeated row.
+func writeBlankRows(sb *strings.Builder, n int) {
+	switch {
+	case n == 1:
+		sb.WriteString(`<table:table-row><table:table-cell/></table:table-row>`)
+	case n > 1:
+		fmt.Fprintf(sb, `<table:table-row table:number-rows-repeated="%d"><table:table-cell/></table:table-row>`, n)
+	}
+}
+
+// writeCell writes a cell holding a value, or a formula and its computed
+// value.
+func (wr *writer) writeCell(sb *strings.Builder, v sheets.Value, f sheets.Formula) {
+	sb.WriteString(`<table:table-cell`)
+	if f != nil {
+		sb.WriteString(` table:formula="`)
+		writeText(sb, toOpenFormula(f, wr.settings))
+		sb.WriteString(`"`)
+	}
+
+	var text string
+	switch tv := v.(type) {
+	case sheets.BlankValue:
+		sb.WriteString(`/>`)
+		return
+	case sheets.Float64Value:
+		text = wr.writeNumber(sb, float64(tv))
+	case sheets.FormattedNumber:
+		text = wr.writeNumber(sb, tv.Number)
+	case sheets.TimeValue:
+		tm := time.Time(tv).In(office.Location(wr.settings))
+		style, layout := dateTimeStyle, "2006-01-02T15:04:05"
+		if tm.Hour() == 0 && tm.Minute() == 0 && tm.Second() == 0 && tm.Nanosecond() == 0 {
+			style, layout = dateStyle, "2006-01-02"
+		}
+
+		text = strings.Replace(tm.Format(layout), "T", " ", 1)
+		fmt.Fprintf(sb, ` table:style-name="%s" office:value-type="date" office:date-value="%s"`,
+			style, tm.Format(layout))
+	case sheets.BoolValue:
+		text = strings.ToUpper(tv.String())
+		fmt.Fprintf(sb, ` office:value-type="boolean" office:boolean-value="%t"`, bool(tv))
+	case sheets.ErrorValue:
+		text = sheets.ErrorCode(tv.Err)
+		sb.WriteString(` office:value-type="string" calcext:value-type="error"`)
+	default:
+		text = v.String()
+		sb.WriteString(` office:value-type="string"`)
+	}
+
+	sb.WriteByte('>')
+	writeParagraphs(sb, text)
+	sb.WriteString(`</table:table-cell>`)
+}
+
+// writeNumber writes the attributes of a number, which is written as a #NUM!
+// error if it cannot be stored, returning the text it displays.
+func (wr *writer) writeNumber(sb *strings.Builder, n float64) string {
+	if math.IsNaN(n) || math.IsInf(n, 0) {
+		sb.WriteString(` office:value-type="string" calcext:value-type="error"`)
+		return "#NUM!"
+	}
+
+	fmt.Fprintf(sb, ` office:value-type="float" office:value="%s"`, strconv.FormatFloat(n, 'g', -1, 64))
+	return wr.settings.NumericMode.Format(n)
+}
+
+func (wr *writer) writePart(name, content string) error {
+	return office.WritePart(wr.z, name, content)
+}
+
+// writeNamedRanges writes the named ranges of a DataSet that refer to the
+// sheets being written.
+func writeNamedRanges(sb *strings.Builder, ds sheets.DataSet, toWrite []office.NamedSheet) {
+	named := office.NamedRanges(ds, toWrite)
+	if len(named) == 0 {
+		return
+	}
+
+	sb.WriteString(`<table:named-expressions>`)
+	for _, nr := range named {
+		sb.WriteString(`<table:named-range table:name="`)
+		writeText(sb, nr.Name)
+		sb.WriteString(`" table:base-cell-address="`)
+		writeText(sb, openSyntax.SheetPrefix(nr.Sheet)+".$A$1")
+		sb.WriteString(`" table:cell-range-address="`)
+		writeText(sb, openSyntax.AbsoluteReference(nr.Sheet, nr.Range))
+		sb.WriteString(`"/>`)
+	}
+
+	sb.WriteString(`</table:named-expressions>`)
+}
+
+// writeParagraphs writes text as a paragraph for each line, keeping the
+// spaces and tabs that XML would otherwise collapse.
+func writeParagraphs(sb *strings.Builder, text string) {
+	for _, line := range strings.Split(text, "\n") {
+		sb.WriteString(`<text:p>`)
+		spaces := 0
+		for i, r := range line {
+			if r == ' ' && i != 0 && i != len(line)-1 && line[i-1] != ' ' && line[i+1] != ' ' {
+				// Single spaces between words are kept as is
+				sb.WriteByte(' ')
+				continue
+			}
+
+			if r == ' ' {
+				spaces++
+				continue
+			}
+
+			writeSpaces(sb, spaces)
+			spaces = 0
+			if r == '\t' {
+				sb.WriteString(`<text:tab/>`)
+				continue
+			}
+
+			writeText(sb, string(r))
+		}
+
+		writeSpaces(sb, spaces)
+		sb.WriteString(`</text:p>`)
+	}
+}
+
+func writeSpaces(sb *strings.Builder, n int) {
+	switch {
+	case n == 1:
+		sb.WriteString(`<text:s/>`)
+	case n > 1:
+		fmt.Fprintf(sb, `<text:s text:c="%d"/>`, n)
+	}
+}
+
+// writeText writes text escaped for XML.
+func writeText(sb *strings.Builder, s string) {
+	_ = xml.EscapeText(sb, []byte(s))
+}
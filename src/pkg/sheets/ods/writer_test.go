@@ -0,0 +1,185 @@
+package ods
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mmihic/sheets/src/pkg/sheets"
+	"github.com/mmihic/sheets/src/pkg/sheets/internal/office"
+	"github.com/mmihic/sheets/src/pkg/sheets/internal/office/officetest"
+)
+
+func newTestWorkbook(t *testing.T) *sheets.Workbook {
+	return officetest.NewWorkbook(t, `a "quoted" <name> & more`, "  two  spaces\tand\nlines ")
+}
+
+func writeAndRead(t *testing.T, ds sheets.DataSet, opts ...WriteOption) (*Workbook, []byte) {
+	var buf bytes.Buffer
+	require.NoError(t, Write(context.Background(), &buf, ds, opts...))
+
+	wb, err := Read(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	return wb, buf.Bytes()
+}
+
+func TestWrite(t *testing.T) {
+	wb, _ := writeAndRead(t, newTestWorkbook(t))
+	assert.Equal(t, []string{"Data", "Q1's Summary"}, wb.SheetNames())
+	assert.Empty(t, wb.FormulaErrors())
+
+	assertCells(t, wb.Sheet("Data"), map[string]sheets.Value{
+		"A1": sheets.StringValue("name"),
+		"A2": sheets.StringValue(`a "quoted" <name> & more`),
+		"B2": sheets.Float64Value(1.5),
+		"C2": sheets.TimeValue(time.Date(2024, time.March, 5, 0, 0, 0, 0, time.UTC)),
+		"D2": sheets.BoolValue(true),
+		"A3": sheets.StringValue("  two  spaces\tand\nlines "),
+		"C3": sheets.TimeValue(time.Date(2024, time.March, 5, 12, 30, 0, 0, time.UTC)),
+		"D3": sheets.BoolValue(false),
+		"B4": sheets.Float64Value(3.75),
+		"C4": sheets.StringValue("  two  spaces\tand\nlines say \"hi\""),
+		"D4": sheets.Float64Value(7.5),
+	})
+
+	v, err := wb.Sheet("Data").Get(context.Background(), mustParsePos(t, "A4"))
+	require.NoError(t, err)
+	require.IsType(t, sheets.ErrorValue{}, v)
+	assert.ErrorIs(t, v.(sheets.ErrorValue).Err, sheets.ErrDivideByZero)
+
+	// Formulas are kept, and their computed values cached
+	fs := wb.Sheet("Data").(sheets.FormulaSheet)
+	f, ok := fs.Formula(mustParsePos(t, "D4"))
+	require.True(t, ok)
+	assert.Equal(t, "of:=([.B2]+[.B3])*2", toOpenFormula(f, sheets.CalcSettings{}))
+	assertCells(t, wb.CachedSheet("Data"), map[string]sheets.Value{
+		"B4": sheets.Float64Value(3.75),
+		"D4": sheets.Float64Value(7.5),
+	})
+
+	summary := wb.Sheet("Q1's Summary")
+	assert.Equal(t, sheets.Dimensions{EndRow: 3, EndCol: 5}, summary.Dimensions())
+	assertCells(t, summary, map[string]sheets.Value{
+		"A1": sheets.Float64Value(3.75),
+		"B1": sheets.Float64Value(7.5),
+		"D1": sheets.BlankValue{},
+		"F1": sheets.Float64Value(1e-7),
+		"A2": sheets.BlankValue{},
+		"A4": sheets.StringValue("end"),
+	})
+
+	v, err = summary.Get(context.Background(), mustParsePos(t, "C1"))
+	require.NoError(t, err)
+	assert.IsType(t, sheets.ErrorValue{}, v)
+
+	// Only names referring to the sheets being written are kept
+	assert.Equal(t, []string{"Totals"}, wb.NamedRangeNames())
+}
+
+func TestWrite_Package(t *testing.T) {
+	_, data := writeAndRead(t, newTestWorkbook(t))
+
+	z, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+
+	// The media type comes first, uncompressed
+	require.Len(t, z.File, 3)
+	assert.Equal(t, "mimetype", z.File[0].Name)
+	assert.Equal(t, zip.Store, z.File[0].Method)
+	assert.Equal(t, "META-INF/manifest.xml", z.File[1].Name)
+
+	content := readPart(t, z, "content.xml")
+	assert.Contains(t, content, `table:formula="of:=([.B2]+[.B3])*2"`)
+	assert.Contains(t, content, `table:formula="of:=[$&#39;Data&#39;.B4]*2"`)
+	assert.Contains(t, content, `<text:p><text:s text:c="2"/>two<text:s text:c="2"/>spaces<text:tab/>and</text:p>`+
+		`<text:p>lines<text:s/></text:p>`)
+	assert.Contains(t, content, `<table:table-cell table:number-columns-repeated="2"/>`)
+	assert.Contains(t, content, `<table:table-row table:number-rows-repeated="2"><table:table-cell/></table:table-row>`)
+	assert.Contains(t, content, `table:style-name="ce1" office:value-type="date" office:date-value="2024-03-05"`)
+	assert.Contains(t, content, `table:style-name="ce2" office:value-type="date" office:date-value="2024-03-05T12:30:00"`)
+	assert.Contains(t, content, `<table:named-range table:name="Totals" table:base-cell-address="$&#39;Data&#39;.$A$1" `+
+		`table:cell-range-address="$&#39;Data&#39;.$B$2:.$B$3"/>`)
+	assert.NotContains(t, content, "null-date")
+}
+
+func TestWrite_Settings(t *testing.T) {
+	wb := newTestWorkbook(t)
+	wb.SetDateSystem(sheets.Date1904)
+
+	loc := time.FixedZone("UTC+2", 2*60*60)
+	wb.Evaluator().Location = loc
+
+	read, data := writeAndRead(t, wb)
+	assert.Equal(t, sheets.Date1904, read.Evaluator().DateSystem)
+
+	z, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+
+	// Times are written in the wall-clock time of the evaluation time zone
+	content := readPart(t, z, "content.xml")
+	assert.Contains(t, content, `office:date-value="2024-03-05T02:00:00"`)
+	assert.Contains(t, content, `<table:null-date table:date-value="1904-01-01"/>`)
+}
+
+func TestWriteSheet(t *testing.T) {
+	s, err := sheets.NewInMemorySheet([][]sheets.Value{
+		{sheets.StringValue("a"), sheets.Float64Value(1)},
+	})
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteSheet(context.Background(), &buf, "Only", s))
+
+	wb, err := Read(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Only"}, wb.SheetNames())
+	assertCells(t, wb.Sheet("Only"), map[string]sheets.Value{
+		"A1": sheets.StringValue("a"),
+		"B1": sheets.Float64Value(1),
+	})
+}
+
+func TestWriteFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "test.ods")
+	require.NoError(t, WriteFile(context.Background(), name, newTestWorkbook(t), WithSheets("Data")))
+
+	wb, err := Open(name)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Data"}, wb.SheetNames())
+}
+
+func TestWrite_Invalid(t *testing.T) {
+	wb := newTestWorkbook(t)
+	for _, tt := range []struct {
+		ds       sheets.DataSet
+		opts     []WriteOption
+		expected string
+	}{
+		{office.SingleSheet("Data", nil), nil, "unable to list sheets of data set: use WithSheets"},
+		{sheets.NewWorkbook(), nil, "there must be at least one sheet"},
+		{wb, []WriteOption{WithSheets("Data", "data")}, "duplicate sheet name 'data'"},
+		{wb, []WriteOption{WithSheets("a/b")}, "invalid sheet name 'a/b'"},
+		{wb, []WriteOption{WithSheets("Missing")}, "no such sheet 'Missing'"},
+	} {
+		err := Write(context.Background(), io.Discard, tt.ds, tt.opts...)
+		assert.EqualError(t, err, tt.expected, tt.expected)
+	}
+}
+
+func readPart(t *testing.T, z *zip.Reader, name string) string {
+	f, err := z.Open(name)
+	require.NoError(t, err)
+
+	defer func() { _ = f.Close() }()
+
+	content, err := io.ReadAll(f)
+	require.NoError(t, err)
+	return string(content)
+}
@@ -0,0 +1,228 @@
+package parquet
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+)
+
+var errTruncatedPage = errors.New("truncated page")
+
+// decodeRLE decodes n values of the given bit width encoded with the hybrid
+// of run-length encoding and bit-packing used for levels, dictionary indices
+// and booleans.
+func decodeRLE(buf []byte, bitWidth, n int) ([]uint32, error) {
+	if bitWidth < 0 || bitWidth > 32 {
+		return nil, fmt.Errorf("invalid bit width %d", bitWidth)
+	}
+
+	values := make([]uint32, 0, n)
+	byteWidth := (bitWidth + 7) / 8
+	for len(values) < n {
+		header, size := binary.Uvarint(buf)
+		if size <= 0 {
+			return nil, errTruncatedPage
+		}
+
+		buf = buf[size:]
+		if header&1 == 0 {
+			// A run of a single repeated value
+			count := header >> 1
+			if len(buf) < byteWidth {
+				return nil, errTruncatedPage
+			}
+
+			var v uint32
+			for i := 0; i < byteWidth; i++ {
+				v |= uint32(buf[i]) << (8 * i)
+			}
+
+			buf = buf[byteWidth:]
+			for i := uint64(0); i < count && len(values) < n; i++ {
+				values = append(values, v)
+			}
+
+			continue
+		}
+
+		// Groups of eight bit-packed values, with the lowest bits first
+		groups := header >> 1
+		if bitWidth > 0 && groups > uint64(len(buf)) {
+			return nil, errTruncatedPage
+		}
+
+		count := n - len(values)
+		if groups < uint64(count+7)/8 {
+			count = int(groups) * 8
+		}
+
+		size = int(groups) * bitWidth
+		if size > len(buf) {
+			return nil, errTruncatedPage
+		}
+
+		mask := uint64(1)<<bitWidth - 1
+		for i := 0; i < count; i++ {
+			bit := i * bitWidth
+			var v uint64
+			for b := 0; b < bitWidth+8 && bit/8+b/8 < size; b += 8 {
+				v |= uint64(buf[bit/8+b/8]) << b
+			}
+
+			values = append(values, uint32((v>>(bit%8))&mask))
+		}
+
+		buf = buf[size:]
+	}
+
+	return values, nil
+}
+
+// appendRLE appends values of the given bit width with the hybrid of
+// run-length encoding and bit-packing, using runs for values repeated at least
+// eight times and bit-packing the rest.
+func appendRLE(buf []byte, values []uint32, bitWidth int) []byte {
+	runLength := func(i int) int {
+		j := i + 1
+		for j < len(values) && values[j] == values[i] {
+			j++
+		}
+
+		return j - i
+	}
+
+	for i := 0; i < len(values); {
+		if n := runLength(i); n >= 8 {
+			buf = binary.AppendUvarint(buf, uint64(n)<<1)
+			for b := 0; b < (bitWidth+7)/8; b++ {
+				buf = append(buf, byte(values[i]>>(8*b)))
+			}
+
+			i += n
+			continue
+		}
+
+		// Bit-pack groups of eight values until a run starts at the start of
+		// a group, padding the last group with zeros
+		start := i
+		for i += 8; i < len(values) && runLength(i) < 8; i += 8 {
+		}
+
+		if i > len(values) {
+			i = len(values)
+		}
+
+		groups := (i - start + 7) / 8
+		buf = binary.AppendUvarint(buf, uint64(groups)<<1|1)
+		packed := make([]byte, groups*bitWidth)
+		for j, v := range values[start:i] {
+			for b := 0; b < bitWidth; b++ {
+				if v&(1<<b) != 0 {
+					bit := j*bitWidth + b
+					packed[bit/8] |= 1 << (bit % 8)
+				}
+			}
+		}
+
+		buf = append(buf, packed...)
+	}
+
+	return buf
+}
+
+// bitWidth returns the number of bits needed for values up to max.
+func bitWidth(max int) int {
+	return bits.Len(uint(max))
+}
+
+// A plainDecoder decodes values of a physical type in the PLAIN encoding.
+type plainDecoder struct {
+	typ        int64
+	typeLength int
+	buf        []byte
+	bit        int
+}
+
+// next decodes the next value: a bool, int32, int64, float32, float64, or
+// []byte for byte arrays and INT96s.
+func (d *plainDecoder) next() (any, error) {
+	switch d.typ {
+	case typeBoolean:
+		if d.bit/8 >= len(d.buf) {
+			return nil, errTruncatedPage
+		}
+
+		v := d.buf[d.bit/8]&(1<<(d.bit%8)) != 0
+		d.bit++
+		return v, nil
+	case typeInt32:
+		b, err := d.take(4)
+		if err != nil {
+			return nil, err
+		}
+
+		return int32(binary.LittleEndian.Uint32(b)), nil
+	case typeInt64:
+		b, err := d.take(8)
+		if err != nil {
+			return nil, err
+		}
+
+		return int64(binary.LittleEndian.Uint64(b)), nil
+	case typeInt96:
+		return d.take(12)
+	case typeFloat:
+		b, err := d.take(4)
+		if err != nil {
+			return nil, err
+		}
+
+		return math.Float32frombits(binary.LittleEndian.Uint32(b)), nil
+	case typeDouble:
+		b, err := d.take(8)
+		if err != nil {
+			return nil, err
+		}
+
+		return math.Float64frombits(binary.LittleEndian.Uint64(b)), nil
+	case typeByteArray:
+		b, err := d.take(4)
+		if err != nil {
+			return nil, err
+		}
+
+		return d.take(int(binary.LittleEndian.Uint32(b)))
+	case typeFixedLenByteArray:
+		return d.take(d.typeLength)
+	default:
+		return nil, fmt.Errorf("unsupported physical type %d", d.typ)
+	}
+}
+
+func (d *plainDecoder) take(n int) ([]byte, error) {
+	if n < 0 || n > len(d.buf) {
+		return nil, errTruncatedPage
+	}
+
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b, nil
+}
+
+// appendPlain appends a value in the PLAIN encoding. Booleans are not
+// supported, since they are packed into bits.
+func appendPlain(buf []byte, v any) ([]byte, error) {
+	switch tv := v.(type) {
+	case int64:
+		return binary.LittleEndian.AppendUint64(buf, uint64(tv)), nil
+	case float64:
+		return binary.LittleEndian.AppendUint64(buf, math.Float64bits(tv)), nil
+	case string:
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(len(tv)))
+		return append(buf, tv...), nil
+	default:
+		return nil, fmt.Errorf("unsupported plain value %T", v)
+	}
+}
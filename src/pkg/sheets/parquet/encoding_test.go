@@ -0,0 +1,106 @@
+package parquet
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeRLE(t *testing.T) {
+	// The bit-packed example from the Parquet specification
+	values, err := decodeRLE([]byte{0x03, 0x88, 0xc6, 0xfa}, 3, 8)
+	require.NoError(t, err)
+	assert.Equal(t, []uint32{0, 1, 2, 3, 4, 5, 6, 7}, values)
+
+	// A run of five 0x0102 values
+	values, err = decodeRLE([]byte{0x0a, 0x02, 0x01}, 9, 5)
+	require.NoError(t, err)
+	assert.Equal(t, []uint32{0x102, 0x102, 0x102, 0x102, 0x102}, values)
+
+	// Values of width zero are all zero
+	values, err = decodeRLE([]byte{0x03}, 0, 3)
+	require.NoError(t, err)
+	assert.Equal(t, []uint32{0, 0, 0}, values)
+
+	_, err = decodeRLE([]byte{0x03, 0x88}, 3, 8)
+	assert.Equal(t, errTruncatedPage, err)
+
+	_, err = decodeRLE(nil, 1, 1)
+	assert.Equal(t, errTruncatedPage, err)
+
+	_, err = decodeRLE(nil, 33, 1)
+	assert.EqualError(t, err, "invalid bit width 33")
+}
+
+func TestAppendRLE(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		values []uint32
+		width  int
+	}{
+		{"empty", nil, 1},
+		{"packed", []uint32{1, 0, 1, 1, 0}, 1},
+		{"run", []uint32{5, 5, 5, 5, 5, 5, 5, 5, 5, 5}, 3},
+		{"mixed", []uint32{1, 2, 3, 7, 7, 7, 7, 7, 7, 7, 7, 7, 7, 7, 7, 7, 0, 1, 2, 3, 4, 5, 6, 7, 7}, 3},
+		{"wide", []uint32{math.MaxUint32, 0, 1 << 20}, 32},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := decodeRLE(appendRLE(nil, tt.values, tt.width), tt.width, len(tt.values))
+			require.NoError(t, err)
+			assert.Equal(t, append([]uint32{}, tt.values...), values)
+		})
+	}
+
+	assert.Equal(t, []byte{0x14, 0x05}, appendRLE(nil, []uint32{5, 5, 5, 5, 5, 5, 5, 5, 5, 5}, 3))
+}
+
+// mustAppendPlain appends a value whose type is known to be supported.
+func mustAppendPlain(buf []byte, v any) []byte {
+	buf, err := appendPlain(buf, v)
+	if err != nil {
+		panic(err)
+	}
+
+	return buf
+}
+
+func TestAppendPlain_Unsupported(t *testing.T) {
+	_, err := appendPlain(nil, true)
+	assert.EqualError(t, err, "unsupported plain value bool")
+}
+
+func TestPlainDecoder(t *testing.T) {
+	buf := mustAppendPlain(nil, int64(-2))
+	buf = mustAppendPlain(buf, 1.5)
+	buf = mustAppendPlain(buf, "hi")
+
+	d := &plainDecoder{typ: typeInt64, buf: buf}
+	v, err := d.next()
+	require.NoError(t, err)
+	assert.Equal(t, int64(-2), v)
+
+	d.typ = typeDouble
+	v, err = d.next()
+	require.NoError(t, err)
+	assert.Equal(t, 1.5, v)
+
+	d.typ = typeByteArray
+	v, err = d.next()
+	require.NoError(t, err)
+	assert.Equal(t, []byte("hi"), v)
+
+	_, err = d.next()
+	assert.Equal(t, errTruncatedPage, err)
+
+	d = &plainDecoder{typ: typeBoolean, buf: []byte{0x05}}
+	var bools []any
+	for i := 0; i < 3; i++ {
+		v, err := d.next()
+		require.NoError(t, err)
+		bools = append(bools, v)
+	}
+
+	assert.Equal(t, []any{true, false, true}, bools)
+}
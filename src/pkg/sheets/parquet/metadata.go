@@ -0,0 +1,238 @@
+package parquet
+
+import (
+	"fmt"
+)
+
+// The physical types of values.
+const (
+	typeBoolean           = 0
+	typeInt32             = 1
+	typeInt64             = 2
+	typeInt96             = 3
+	typeFloat             = 4
+	typeDouble            = 5
+	typeByteArray         = 6
+	typeFixedLenByteArray = 7
+)
+
+// The repetition of fields.
+const (
+	repetitionRequired = 0
+	repetitionOptional = 1
+	repetitionRepeated = 2
+)
+
+// The converted types, which annotate physical types in files written before
+// logical types were introduced.
+const (
+	convertedUTF8            = 0
+	convertedEnum            = 4
+	convertedDecimal         = 5
+	convertedDate            = 6
+	convertedTimeMillis      = 7
+	convertedTimeMicros      = 8
+	convertedTimestampMillis = 9
+	convertedTimestampMicros = 10
+	convertedUint8           = 11
+	convertedUint16          = 12
+	convertedUint32          = 13
+	convertedUint64          = 14
+	convertedJSON            = 19
+	convertedBSON            = 20
+)
+
+// The fields of the LogicalType union.
+const (
+	logicalString    = 1
+	logicalEnum      = 4
+	logicalDecimal   = 5
+	logicalDate      = 6
+	logicalTime      = 7
+	logicalTimestamp = 8
+	logicalInteger   = 10
+	logicalUnknown   = 11
+	logicalJSON      = 12
+	logicalBSON      = 13
+	logicalUUID      = 14
+	logicalFloat16   = 15
+)
+
+// The fields of the TimeUnit union.
+const (
+	unitMillis = 1
+	unitMicros = 2
+	unitNanos  = 3
+)
+
+// The encodings of values and levels.
+const (
+	encodingPlain           = 0
+	encodingPlainDictionary = 2
+	encodingRLE             = 3
+	encodingBitPacked       = 4
+	encodingRLEDictionary   = 8
+)
+
+var encodingNames = map[int64]string{
+	0: "PLAIN", 2: "PLAIN_DICTIONARY", 3: "RLE", 4: "BIT_PACKED", 5: "DELTA_BINARY_PACKED",
+	6: "DELTA_LENGTH_BYTE_ARRAY", 7: "DELTA_BYTE_ARRAY", 8: "RLE_DICTIONARY", 9: "BYTE_STREAM_SPLIT",
+}
+
+// The compression codecs of column chunks.
+const (
+	codecUncompressed = 0
+	codecSnappy       = 1
+	codecGzip         = 2
+)
+
+var codecNames = map[int64]string{
+	0: "UNCOMPRESSED", 1: "SNAPPY", 2: "GZIP", 3: "LZO", 4: "BROTLI", 5: "LZ4", 6: "ZSTD", 7: "LZ4_RAW",
+}
+
+// The types of pages.
+const (
+	pageData       = 0
+	pageIndex      = 1
+	pageDictionary = 2
+	pageDataV2     = 3
+)
+
+// A fileMetaData is the metadata in the footer of a file.
+type fileMetaData struct {
+	schema    []schemaElement
+	numRows   int64
+	rowGroups []rowGroup
+}
+
+// A schemaElement is an element of the flattened schema tree, which lists
+// each field followed by its children.
+type schemaElement struct {
+	typ           int64
+	typeLength    int64
+	repetition    int64
+	name          string
+	numChildren   int64
+	convertedType int64
+	scale         int64
+	logicalType   tstruct
+}
+
+// A rowGroup is a group of rows, holding a chunk of each column.
+type rowGroup struct {
+	numRows int64
+	columns []columnChunk
+}
+
+// A columnChunk is the chunk of a column within a row group.
+type columnChunk struct {
+	filePath             string
+	codec                int64
+	numValues            int64
+	totalCompressedSize  int64
+	dataPageOffset       int64
+	dictionaryPageOffset int64
+}
+
+// A pageHeader is the header of a page of a column chunk.
+type pageHeader struct {
+	typ              int64
+	uncompressedSize int64
+	compressedSize   int64
+
+	numValues int64
+	encoding  int64
+
+	// The encoding of definition levels in data pages
+	levelEncoding int64
+
+	// The lengths of the levels of version 2 data pages, which precede the
+	// values and are never compressed
+	repLevelsLength int64
+	defLevelsLength int64
+	compressed      bool
+}
+
+func decodeFileMetaData(buf []byte) (*fileMetaData, error) {
+	s, _, err := decodeStruct(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	md := &fileMetaData{numRows: s.int(3)}
+	for _, item := range s.list(2) {
+		e, _ := item.(tstruct)
+		md.schema = append(md.schema, schemaElement{
+			typ:           e.intOr(1, -1),
+			typeLength:    e.int(2),
+			repetition:    e.int(3),
+			name:          e.str(4),
+			numChildren:   e.int(5),
+			convertedType: e.intOr(6, -1),
+			scale:         e.int(7),
+			logicalType:   e.strct(10),
+		})
+	}
+
+	for _, item := range s.list(4) {
+		g, _ := item.(tstruct)
+		rg := rowGroup{numRows: g.int(3)}
+		for _, item := range g.list(1) {
+			c, _ := item.(tstruct)
+			cmd := c.strct(3)
+			if cmd == nil {
+				return nil, fmt.Errorf("missing metadata for column chunk %d of row group %d",
+					len(rg.columns), len(md.rowGroups))
+			}
+
+			rg.columns = append(rg.columns, columnChunk{
+				filePath:             c.str(1),
+				codec:                cmd.int(4),
+				numValues:            cmd.int(5),
+				totalCompressedSize:  cmd.int(7),
+				dataPageOffset:       cmd.int(9),
+				dictionaryPageOffset: cmd.int(11),
+			})
+		}
+
+		md.rowGroups = append(md.rowGroups, rg)
+	}
+
+	return md, nil
+}
+
+// decodePageHeader decodes the page header at the start of a buffer,
+// returning the header and its length.
+func decodePageHeader(buf []byte) (*pageHeader, int, error) {
+	s, n, err := decodeStruct(buf)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	h := &pageHeader{
+		typ:              s.intOr(1, -1),
+		uncompressedSize: s.int(2),
+		compressedSize:   s.int(3),
+	}
+
+	switch h.typ {
+	case pageData:
+		dph := s.strct(5)
+		h.numValues, h.encoding, h.levelEncoding = dph.int(1), dph.int(2), dph.int(3)
+	case pageDictionary:
+		dph := s.strct(7)
+		h.numValues, h.encoding = dph.int(1), dph.int(2)
+	case pageDataV2:
+		dph := s.strct(8)
+		h.numValues, h.encoding = dph.int(1), dph.int(4)
+		h.defLevelsLength, h.repLevelsLength = dph.int(5), dph.int(6)
+		h.levelEncoding = encodingRLE
+		h.compressed = !dph.has(7) || dph.bool(7)
+	}
+
+	if h.compressedSize < 0 || h.uncompressedSize < 0 || h.numValues < 0 {
+		return nil, 0, fmt.Errorf("invalid page header")
+	}
+
+	return h, n, nil
+}
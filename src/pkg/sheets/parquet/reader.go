@@ -0,0 +1,710 @@
+// Package parquet reads and writes Apache Parquet files as sheets, so that
+// formulas can be computed over columnar data.
+//
+// A file is read as a sheet whose first row holds the names of the file's
+// columns, and whose following rows hold the file's rows. Columns nested
+// within groups are named by their paths, such as "address.city". Column
+// chunks are read when first needed, so reading a range within a single
+// column reads only that column.
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/mmihic/sheets/src/pkg/sheets"
+	"github.com/mmihic/sheets/src/pkg/sheets/internal/columnar"
+)
+
+// magic starts and ends every Parquet file.
+const magic = "PAR1"
+
+// julianUnixDay is the Julian day of the Unix epoch, from which the days of
+// INT96 timestamps are counted.
+const julianUnixDay = 2440588
+
+// A Sheet is a sheet holding the rows of a Parquet file. Values are converted
+// from the types of their columns: numbers, including decimals, to
+// Float64Values; booleans to BoolValues; text, enums, JSON and other byte
+// arrays to StringValues; dates, times and timestamps to TimeValues in UTC;
+// and nulls to blanks. Times of day are TimeValues on 31 December 1899, so
+// that they are fractions of a day in formulas.
+type Sheet struct {
+	r      io.ReaderAt
+	size   int64
+	md     *fileMetaData
+	leaves []leaf
+	table  *columnar.Table
+}
+
+// A leaf is a column of a file, which is a leaf of its schema.
+type leaf struct {
+	name    string
+	elem    schemaElement
+	maxDef  int
+	maxRep  int
+	convert func(v any) sheets.Value
+}
+
+var _ sheets.Sheet = &Sheet{}
+
+// A File is a Sheet read from a file, which must be closed once the sheet is
+// no longer used.
+type File struct {
+	*Sheet
+	f *os.File
+}
+
+// Close closes the file.
+func (f *File) Close() error {
+	return f.f.Close()
+}
+
+// Open opens the Parquet file at the given path. The file is read as its
+// columns are used, and must be closed once the sheet is no longer used.
+func Open(name string) (*File, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	s, err := Read(f, info.Size())
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	return &File{Sheet: s, f: f}, nil
+}
+
+// Read reads a Parquet file of the given size. Only the file's metadata is
+// read immediately; column chunks are read from r as they are used.
+func Read(r io.ReaderAt, size int64) (*Sheet, error) {
+	s, err := read(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("invalid parquet file: %w", err)
+	}
+
+	return s, nil
+}
+
+func read(r io.ReaderAt, size int64) (*Sheet, error) {
+	if size < int64(2*len(magic)+4) {
+		return nil, errors.New("file is too short")
+	}
+
+	var tail [8]byte
+	if _, err := r.ReadAt(tail[:], size-8); err != nil {
+		return nil, err
+	}
+
+	switch string(tail[4:]) {
+	case magic:
+	case "PARE":
+		return nil, errors.New("encrypted files are not supported")
+	default:
+		return nil, errors.New("missing magic number")
+	}
+
+	footerLen := int64(binary.LittleEndian.Uint32(tail[:4]))
+	if footerLen > size-int64(2*len(magic)+4) {
+		return nil, errors.New("invalid footer length")
+	}
+
+	footer := make([]byte, footerLen)
+	if _, err := r.ReadAt(footer, size-8-footerLen); err != nil {
+		return nil, err
+	}
+
+	md, err := decodeFileMetaData(footer)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Sheet{r: r, size: size, md: md}
+	if err := s.readSchema(); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, len(s.leaves))
+	for i, l := range s.leaves {
+		names[i] = l.name
+	}
+
+	chunkRows := make([]int, len(md.rowGroups))
+	for i, rg := range md.rowGroups {
+		if len(rg.columns) != len(s.leaves) {
+			return nil, fmt.Errorf("row group %d has %d columns, expected %d", i, len(rg.columns), len(s.leaves))
+		}
+
+		if rg.numRows < 0 || rg.numRows > math.MaxInt32 {
+			return nil, fmt.Errorf("invalid number of rows in row group %d", i)
+		}
+
+		chunkRows[i] = int(rg.numRows)
+	}
+
+	s.table = columnar.NewTable(names, chunkRows, s.load)
+	return s, nil
+}
+
+// readSchema flattens the schema into its leaves, which are the columns of
+// the file.
+func (s *Sheet) readSchema() error {
+	schema := s.md.schema
+	if len(schema) == 0 {
+		return errors.New("missing schema")
+	}
+
+	var walk func(i int, prefix string, def, rep int) (int, error)
+	walk = func(i int, prefix string, def, rep int) (int, error) {
+		if i >= len(schema) {
+			return 0, errors.New("invalid schema")
+		}
+
+		e := schema[i]
+		switch e.repetition {
+		case repetitionOptional:
+			def++
+		case repetitionRepeated:
+			def++
+			rep++
+		}
+
+		name := e.name
+		if prefix != "" {
+			name = prefix + "." + e.name
+		}
+
+		if e.numChildren <= 0 {
+			s.leaves = append(s.leaves, leaf{
+				name:    name,
+				elem:    e,
+				maxDef:  def,
+				maxRep:  rep,
+				convert: converter(e),
+			})
+
+			return i + 1, nil
+		}
+
+		next := i + 1
+		for c := int64(0); c < e.numChildren; c++ {
+			var err error
+			if next, err = walk(next, name, def, rep); err != nil {
+				return 0, err
+			}
+		}
+
+		return next, nil
+	}
+
+	next := 1
+	for c := int64(0); c < schema[0].numChildren; c++ {
+		var err error
+		if next, err = walk(next, "", 0, 0); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Columns returns the names of the columns of the sheet.
+func (s *Sheet) Columns() []string {
+	return s.table.Columns()
+}
+
+// Dimensions returns the dimensions of the sheet, including the header row.
+func (s *Sheet) Dimensions() sheets.Dimensions {
+	return s.table.Dimensions()
+}
+
+// Get returns the value of a cell, reading the chunk of its column holding it
+// unless it was the chunk of the column most recently read.
+func (s *Sheet) Get(ctx context.Context, pos sheets.Pos) (sheets.Value, error) {
+	return s.table.Get(ctx, pos)
+}
+
+// Range returns the values of a range of cells, reading only the columns
+// within the range.
+func (s *Sheet) Range(ctx context.Context, r sheets.Range) (sheets.ValueRange, error) {
+	return s.table.Range(ctx, r)
+}
+
+// load reads the values of a column within a row group.
+func (s *Sheet) load(ctx context.Context, rg, col int) ([]sheets.Value, error) {
+	l := &s.leaves[col]
+	values, err := s.loadChunk(ctx, l, s.md.rowGroups[rg], col)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read column '%s' of row group %d: %w", l.name, rg, err)
+	}
+
+	return values, nil
+}
+
+func (s *Sheet) loadChunk(ctx context.Context, l *leaf, rg rowGroup, col int) ([]sheets.Value, error) {
+	if l.maxRep > 0 {
+		return nil, errors.New("repeated columns are not supported")
+	}
+
+	cc := rg.columns[col]
+	if cc.filePath != "" {
+		return nil, fmt.Errorf("column chunk is stored in another file '%s'", cc.filePath)
+	}
+
+	start := cc.dataPageOffset
+	if cc.dictionaryPageOffset > 0 && cc.dictionaryPageOffset < start {
+		start = cc.dictionaryPageOffset
+	}
+
+	if start < int64(len(magic)) || cc.totalCompressedSize < 0 || start+cc.totalCompressedSize > s.size-8 {
+		return nil, errors.New("invalid column chunk offsets")
+	}
+
+	buf := make([]byte, cc.totalCompressedSize)
+	if _, err := s.r.ReadAt(buf, start); err != nil {
+		return nil, err
+	}
+
+	var (
+		values = make([]sheets.Value, 0, rg.numRows)
+		dict   []sheets.Value
+	)
+
+	for len(buf) > 0 && int64(len(values)) < cc.numValues {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		h, n, err := decodePageHeader(buf)
+		if err != nil {
+			return nil, err
+		}
+
+		buf = buf[n:]
+		if h.compressedSize > int64(len(buf)) {
+			return nil, errTruncatedPage
+		}
+
+		body := buf[:h.compressedSize]
+		buf = buf[h.compressedSize:]
+		switch h.typ {
+		case pageDictionary:
+			data, err := decompress(cc.codec, body, h.uncompressedSize)
+			if err != nil {
+				return nil, err
+			}
+
+			if dict, err = l.decodeValues(encodingPlain, data, int(h.numValues), nil); err != nil {
+				return nil, err
+			}
+		case pageData:
+			data, err := decompress(cc.codec, body, h.uncompressedSize)
+			if err != nil {
+				return nil, err
+			}
+
+			defs, rest, err := l.decodeLevels(data, h)
+			if err != nil {
+				return nil, err
+			}
+
+			if values, err = l.appendValues(values, h, defs, rest, dict); err != nil {
+				return nil, err
+			}
+		case pageDataV2:
+			levelsLength := h.repLevelsLength + h.defLevelsLength
+			if h.repLevelsLength < 0 || h.defLevelsLength < 0 || levelsLength > int64(len(body)) {
+				return nil, errTruncatedPage
+			}
+
+			data := body[levelsLength:]
+			if h.compressed {
+				if data, err = decompress(cc.codec, data, h.uncompressedSize-levelsLength); err != nil {
+					return nil, err
+				}
+			}
+
+			var defs []uint32
+			if l.maxDef > 0 {
+				levels := body[h.repLevelsLength:levelsLength]
+				if defs, err = decodeRLE(levels, bitWidth(l.maxDef), int(h.numValues)); err != nil {
+					return nil, err
+				}
+			}
+
+			if values, err = l.appendValues(values, h, defs, data, dict); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if int64(len(values)) != rg.numRows {
+		return nil, fmt.Errorf("found %d values, expected %d", len(values), rg.numRows)
+	}
+
+	return values, nil
+}
+
+// decodeLevels decodes the definition levels at the start of a version 1
+// data page, returning the levels and the data following them. Returns nil
+// levels if every value is defined.
+func (l *leaf) decodeLevels(data []byte, h *pageHeader) ([]uint32, []byte, error) {
+	if l.maxDef == 0 {
+		return nil, data, nil
+	}
+
+	n := int(h.numValues)
+	width := bitWidth(l.maxDef)
+	switch h.levelEncoding {
+	case encodingRLE:
+		if len(data) < 4 {
+			return nil, nil, errTruncatedPage
+		}
+
+		size := binary.LittleEndian.Uint32(data)
+		if uint64(size) > uint64(len(data)-4) {
+			return nil, nil, errTruncatedPage
+		}
+
+		defs, err := decodeRLE(data[4:4+size], width, n)
+		return defs, data[4+size:], err
+	case encodingBitPacked:
+		// The deprecated packing of levels, with the highest bits first
+		size := (n*width + 7) / 8
+		if size > len(data) {
+			return nil, nil, errTruncatedPage
+		}
+
+		defs := make([]uint32, n)
+		for i := range defs {
+			for b := 0; b < width; b++ {
+				bit := i*width + b
+				defs[i] = defs[i]<<1 | uint32(data[bit/8]>>(7-bit%8)&1)
+			}
+		}
+
+		return defs, data[size:], nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported level encoding %s", encodingNames[h.levelEncoding])
+	}
+}
+
+// appendValues appends the values of a data page, which are blank where
+// their definition levels are below the maximum.
+func (l *leaf) appendValues(values []sheets.Value, h *pageHeader, defs []uint32, data []byte, dict []sheets.Value) ([]sheets.Value, error) {
+	n := int(h.numValues)
+	defined := n
+	if defs != nil {
+		defined = 0
+		for _, def := range defs {
+			if def == uint32(l.maxDef) {
+				defined++
+			}
+		}
+	}
+
+	decoded, err := l.decodeValues(h.encoding, data, defined, dict)
+	if err != nil {
+		return nil, err
+	}
+
+	next := 0
+	for i := 0; i < n; i++ {
+		if defs != nil && defs[i] != uint32(l.maxDef) {
+			values = append(values, sheets.BlankValue{})
+			continue
+		}
+
+		values = append(values, decoded[next])
+		next++
+	}
+
+	return values, nil
+}
+
+// decodeValues decodes n defined values.
+func (l *leaf) decodeValues(encoding int64, data []byte, n int, dict []sheets.Value) ([]sheets.Value, error) {
+	values := make([]sheets.Value, n)
+	switch encoding {
+	case encodingPlain:
+		d := &plainDecoder{typ: l.elem.typ, typeLength: int(l.elem.typeLength), buf: data}
+		for i := range values {
+			v, err := d.next()
+			if err != nil {
+				return nil, err
+			}
+
+			values[i] = l.convert(v)
+		}
+	case encodingPlainDictionary, encodingRLEDictionary:
+		if n == 0 {
+			return values, nil
+		}
+
+		if dict == nil {
+			return nil, errors.New("missing dictionary page")
+		}
+
+		if len(data) == 0 {
+			return nil, errTruncatedPage
+		}
+
+		indices, err := decodeRLE(data[1:], int(data[0]), n)
+		if err != nil {
+			return nil, err
+		}
+
+		for i, index := range indices {
+			if int(index) >= len(dict) {
+				return nil, fmt.Errorf("invalid dictionary index %d", index)
+			}
+
+			values[i] = dict[index]
+		}
+	case encodingRLE:
+		if l.elem.typ != typeBoolean || len(data) < 4 {
+			return nil, fmt.Errorf("unsupported encoding RLE")
+		}
+
+		size := binary.LittleEndian.Uint32(data)
+		if uint64(size) > uint64(len(data)-4) {
+			return nil, errTruncatedPage
+		}
+
+		bools, err := decodeRLE(data[4:4+size], 1, n)
+		if err != nil {
+			return nil, err
+		}
+
+		for i, b := range bools {
+			values[i] = l.convert(b == 1)
+		}
+	default:
+		name, ok := encodingNames[encoding]
+		if !ok {
+			name = strconv.FormatInt(encoding, 10)
+		}
+
+		return nil, fmt.Errorf("unsupported encoding %s", name)
+	}
+
+	return values, nil
+}
+
+// decompress decompresses the data of a page.
+func decompress(codec int64, data []byte, uncompressedSize int64) ([]byte, error) {
+	switch codec {
+	case codecUncompressed:
+		return data, nil
+	case codecSnappy:
+		return snappyDecode(data)
+	case codecGzip:
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+
+		out := bytes.NewBuffer(make([]byte, 0, uncompressedSize))
+		if _, err := io.Copy(out, zr); err != nil {
+			return nil, err
+		}
+
+		return out.Bytes(), nil
+	default:
+		name, ok := codecNames[codec]
+		if !ok {
+			name = strconv.FormatInt(codec, 10)
+		}
+
+		return nil, fmt.Errorf("unsupported compression codec %s", name)
+	}
+}
+
+// converter returns the function that converts the decoded values of a
+// column into Values, following the column's logical or converted type.
+func converter(e schemaElement) func(v any) sheets.Value {
+	lt := e.logicalType
+	switch {
+	case lt.has(logicalUnknown):
+		return func(any) sheets.Value { return sheets.BlankValue{} }
+	case lt.has(logicalString), lt.has(logicalEnum), lt.has(logicalJSON), lt.has(logicalBSON),
+		e.convertedType == convertedUTF8, e.convertedType == convertedEnum,
+		e.convertedType == convertedJSON, e.convertedType == convertedBSON:
+		return plainValue
+	case lt.has(logicalUUID):
+		return uuidValue
+	case lt.has(logicalDecimal):
+		return decimalConverter(int(lt.strct(logicalDecimal).int(1)))
+	case e.convertedType == convertedDecimal:
+		return decimalConverter(int(e.scale))
+	case lt.has(logicalDate), e.convertedType == convertedDate:
+		return dateValue
+	case lt.has(logicalTime):
+		return timeConverter(timeUnit(lt.strct(logicalTime).strct(2)), true)
+	case e.convertedType == convertedTimeMillis:
+		return timeConverter(time.Millisecond, true)
+	case e.convertedType == convertedTimeMicros:
+		return timeConverter(time.Microsecond, true)
+	case lt.has(logicalTimestamp):
+		return timeConverter(timeUnit(lt.strct(logicalTimestamp).strct(2)), false)
+	case e.convertedType == convertedTimestampMillis:
+		return timeConverter(time.Millisecond, false)
+	case e.convertedType == convertedTimestampMicros:
+		return timeConverter(time.Microsecond, false)
+	case lt.has(logicalInteger) && !lt.strct(logicalInteger).bool(2),
+		e.convertedType >= convertedUint8 && e.convertedType <= convertedUint64:
+		return unsignedValue
+	case lt.has(logicalFloat16):
+		return float16Value
+	case e.typ == typeInt96:
+		return int96Value
+	default:
+		return plainValue
+	}
+}
+
+// plainValue converts a decoded value according to its physical type.
+func plainValue(v any) sheets.Value {
+	switch tv := v.(type) {
+	case bool:
+		return sheets.BoolValue(tv)
+	case int32:
+		return sheets.Float64Value(tv)
+	case int64:
+		return sheets.Float64Value(tv)
+	case float32:
+		return columnar.Float32(tv)
+	case float64:
+		return sheets.Float64Value(tv)
+	case []byte:
+		return sheets.StringValue(tv)
+	default:
+		return sheets.BlankValue{}
+	}
+}
+
+func unsignedValue(v any) sheets.Value {
+	switch tv := v.(type) {
+	case int32:
+		return sheets.Float64Value(uint32(tv))
+	case int64:
+		return sheets.Float64Value(uint64(tv))
+	default:
+		return plainValue(v)
+	}
+}
+
+func dateValue(v any) sheets.Value {
+	days, ok := v.(int32)
+	if !ok {
+		return plainValue(v)
+	}
+
+	return columnar.Date(int64(days))
+}
+
+// timeUnit returns the duration of a unit of a TimeUnit union.
+func timeUnit(u tstruct) time.Duration {
+	switch {
+	case u.has(unitMillis):
+		return time.Millisecond
+	case u.has(unitNanos):
+		return time.Nanosecond
+	default:
+		return time.Microsecond
+	}
+}
+
+// timeConverter converts times of day, or timestamps, in the given unit.
+func timeConverter(unit time.Duration, timeOfDay bool) func(v any) sheets.Value {
+	return func(v any) sheets.Value {
+		var n int64
+		switch tv := v.(type) {
+		case int32:
+			n = int64(tv)
+		case int64:
+			n = tv
+		default:
+			return plainValue(v)
+		}
+
+		if timeOfDay {
+			return columnar.TimeOfDay(n, unit)
+		}
+
+		return columnar.Timestamp(n, unit)
+	}
+}
+
+// int96Value converts a legacy INT96 timestamp, which holds the nanoseconds
+// within a day followed by a Julian day.
+func int96Value(v any) sheets.Value {
+	b, ok := v.([]byte)
+	if !ok || len(b) != 12 {
+		return plainValue(v)
+	}
+
+	nanos := int64(binary.LittleEndian.Uint64(b))
+	day := int64(binary.LittleEndian.Uint32(b[8:]))
+	return sheets.TimeValue(time.Unix((day-julianUnixDay)*86400, nanos).UTC())
+}
+
+// decimalConverter converts decimals with the given scale, which are stored
+// as integers or as big-endian two's complement byte arrays.
+func decimalConverter(scale int) func(v any) sheets.Value {
+	return func(v any) sheets.Value {
+		switch tv := v.(type) {
+		case int32:
+			return columnar.Decimal(big.NewInt(int64(tv)), scale)
+		case int64:
+			return columnar.Decimal(big.NewInt(tv), scale)
+		case []byte:
+			n := new(big.Int).SetBytes(tv)
+			if len(tv) > 0 && tv[0]&0x80 != 0 {
+				n.Sub(n, new(big.Int).Lsh(big.NewInt(1), uint(8*len(tv))))
+			}
+
+			return columnar.Decimal(n, scale)
+		default:
+			return plainValue(v)
+		}
+	}
+}
+
+func uuidValue(v any) sheets.Value {
+	b, ok := v.([]byte)
+	if !ok || len(b) != 16 {
+		return plainValue(v)
+	}
+
+	return sheets.StringValue(fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]))
+}
+
+// float16Value converts a half-precision float, stored as two little-endian
+// bytes.
+func float16Value(v any) sheets.Value {
+	b, ok := v.([]byte)
+	if !ok || len(b) != 2 {
+		return plainValue(v)
+	}
+
+	return columnar.Float16(binary.LittleEndian.Uint16(b))
+}
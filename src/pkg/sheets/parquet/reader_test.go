@@ -0,0 +1,447 @@
+package parquet
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mmihic/sheets/src/pkg/sheets"
+	"github.com/mmihic/sheets/src/pkg/sheets/internal/columnar/columnartest"
+)
+
+// A testChunk is a column chunk of a file built by buildFile, holding an
+// optional dictionary page and the data pages that follow it.
+type testChunk struct {
+	codec     int64
+	numValues int64
+	dict      []byte
+	data      []byte
+}
+
+// buildFile builds a file with the given schema, following the root, and row
+// groups of chunks.
+func buildFile(schema [][]tfield, numRows []int64, groups ...[]testChunk) []byte {
+	var skip func(i int) int
+	skip = func(i int) int {
+		children := int32(0)
+		for _, f := range schema[i] {
+			if f.id == 5 {
+				children = f.value.(int32)
+			}
+		}
+
+		for i++; children > 0; children-- {
+			i = skip(i)
+		}
+
+		return i
+	}
+
+	top := int32(0)
+	for i := 0; i < len(schema); i = skip(i) {
+		top++
+	}
+
+	elems := []any{[]tfield{{4, "schema"}, {5, top}}}
+	for _, e := range schema {
+		elems = append(elems, e)
+	}
+
+	file := []byte(magic)
+	var rowGroups []any
+	for i, chunks := range groups {
+		var columns []any
+		for _, c := range chunks {
+			meta := []tfield{
+				{4, int32(c.codec)},
+				{5, c.numValues},
+				{7, int64(len(c.dict) + len(c.data))},
+				{9, int64(len(file) + len(c.dict))},
+			}
+
+			if c.dict != nil {
+				meta = append(meta, tfield{11, int64(len(file))})
+			}
+
+			columns = append(columns, []tfield{{2, int64(len(file))}, {3, meta}})
+			file = append(append(file, c.dict...), c.data...)
+		}
+
+		rowGroups = append(rowGroups, []tfield{{1, tlist{thriftStruct, columns}}, {3, numRows[i]}})
+	}
+
+	total := int64(0)
+	for _, n := range numRows {
+		total += n
+	}
+
+	md := mustAppendStruct(nil, []tfield{
+		{1, int32(1)},
+		{2, tlist{thriftStruct, elems}},
+		{3, total},
+		{4, tlist{thriftStruct, rowGroups}},
+	})
+
+	file = append(file, md...)
+	file = binary.LittleEndian.AppendUint32(file, uint32(len(md)))
+	return append(file, magic...)
+}
+
+// dataPage builds a version 1 data page holding definition levels, if given,
+// and values in the given encoding.
+func dataPage(n int, defs []uint32, encoding int64, values []byte) []byte {
+	var body []byte
+	if defs != nil {
+		levels := appendRLE(nil, defs, 1)
+		body = binary.LittleEndian.AppendUint32(body, uint32(len(levels)))
+		body = append(body, levels...)
+	}
+
+	body = append(body, values...)
+	header := mustAppendStruct(nil, []tfield{
+		{1, int32(pageData)},
+		{2, int32(len(body))},
+		{3, int32(len(body))},
+		{5, []tfield{{1, int32(n)}, {2, int32(encoding)}, {3, int32(encodingRLE)}, {4, int32(encodingRLE)}}},
+	})
+
+	return append(header, body...)
+}
+
+func dictionaryPage(n int, values []byte) []byte {
+	header := mustAppendStruct(nil, []tfield{
+		{1, int32(pageDictionary)},
+		{2, int32(len(values))},
+		{3, int32(len(values))},
+		{7, []tfield{{1, int32(n)}, {2, int32(encodingPlain)}}},
+	})
+
+	return append(header, values...)
+}
+
+func plainValues(values ...any) []byte {
+	var buf []byte
+	for _, v := range values {
+		switch tv := v.(type) {
+		case int32:
+			buf = binary.LittleEndian.AppendUint32(buf, uint32(tv))
+		case []byte:
+			buf = append(buf, tv...)
+		default:
+			buf = mustAppendPlain(buf, v)
+		}
+	}
+
+	return buf
+}
+
+func readBuf(t *testing.T, file []byte) *Sheet {
+	s, err := Read(bytes.NewReader(file), int64(len(file)))
+	require.NoError(t, err)
+	return s
+}
+
+func TestReadTypes(t *testing.T) {
+	day := time.Date(2024, time.March, 5, 0, 0, 0, 0, time.UTC)
+	int96 := binary.LittleEndian.AppendUint64(nil, uint64(12*time.Hour))
+	int96 = binary.LittleEndian.AppendUint32(int96, uint32(day.Unix()/86400+julianUnixDay))
+
+	required := int32(repetitionRequired)
+	file := buildFile([][]tfield{
+		{{1, int32(typeInt32)}, {3, required}, {4, "date"}, {6, int32(convertedDate)}},
+		{{1, int32(typeInt32)}, {3, required}, {4, "millis"}, {6, int32(convertedTimeMillis)}},
+		{{1, int32(typeInt96)}, {3, required}, {4, "legacy"}},
+		{
+			{1, int32(typeFixedLenByteArray)}, {2, int32(4)}, {3, required}, {4, "amount"},
+			{10, []tfield{{logicalDecimal, []tfield{{1, int32(2)}, {2, int32(9)}}}}},
+		},
+		{{1, int32(typeInt64)}, {3, required}, {4, "cents"}, {6, int32(convertedDecimal)}, {7, int32(2)}},
+		{
+			{1, int32(typeInt64)}, {3, required}, {4, "nanos"},
+			{10, []tfield{{logicalTimestamp, []tfield{{1, true}, {2, []tfield{{unitNanos, []tfield{}}}}}}}},
+		},
+		{
+			{1, int32(typeInt32)}, {3, required}, {4, "unsigned"},
+			{10, []tfield{{logicalInteger, []tfield{{1, int8(32)}, {2, false}}}}},
+		},
+		{
+			{1, int32(typeFixedLenByteArray)}, {2, int32(16)}, {3, required}, {4, "id"},
+			{10, []tfield{{logicalUUID, []tfield{}}}},
+		},
+		{
+			{1, int32(typeFixedLenByteArray)}, {2, int32(2)}, {3, required}, {4, "half"},
+			{10, []tfield{{logicalFloat16, []tfield{}}}},
+		},
+		{{1, int32(typeFloat)}, {3, required}, {4, "float"}},
+		{{1, int32(typeBoolean)}, {3, required}, {4, "flag"}},
+	}, []int64{1}, []testChunk{
+		{numValues: 1, data: dataPage(1, nil, encodingPlain, plainValues(int32(day.Unix()/86400)))},
+		{numValues: 1, data: dataPage(1, nil, encodingPlain, plainValues(int32(90*60*1000)))},
+		{numValues: 1, data: dataPage(1, nil, encodingPlain, plainValues(int96))},
+		{numValues: 1, data: dataPage(1, nil, encodingPlain, plainValues([]byte{0xff, 0xff, 0xcf, 0xc7}))},
+		{numValues: 1, data: dataPage(1, nil, encodingPlain, plainValues(int64(110)))},
+		{numValues: 1, data: dataPage(1, nil, encodingPlain, plainValues(int64(-1)))},
+		{numValues: 1, data: dataPage(1, nil, encodingPlain, plainValues(int32(-1)))},
+		{numValues: 1, data: dataPage(1, nil, encodingPlain, plainValues([]byte{
+			0x12, 0x34, 0x56, 0x78, 0x9a, 0xbc, 0xde, 0xf0, 0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef,
+		}))},
+		{numValues: 1, data: dataPage(1, nil, encodingPlain, plainValues([]byte{0x00, 0x3e}))},
+		{numValues: 1, data: dataPage(1, nil, encodingPlain, binary.LittleEndian.AppendUint32(nil, math.Float32bits(0.1)))},
+		{numValues: 1, data: dataPage(1, nil, encodingRLE, append(binary.LittleEndian.AppendUint32(nil, 2), 2, 1))},
+	})
+
+	s := readBuf(t, file)
+	assert.Equal(t, [][]sheets.Value{
+		{
+			sheets.StringValue("date"), sheets.StringValue("millis"), sheets.StringValue("legacy"),
+			sheets.StringValue("amount"), sheets.StringValue("cents"), sheets.StringValue("nanos"),
+			sheets.StringValue("unsigned"), sheets.StringValue("id"), sheets.StringValue("half"),
+			sheets.StringValue("float"), sheets.StringValue("flag"),
+		},
+		{
+			sheets.TimeValue(day),
+			sheets.TimeValue(time.Date(1899, time.December, 31, 1, 30, 0, 0, time.UTC)),
+			sheets.TimeValue(day.Add(12 * time.Hour)),
+			sheets.Float64Value(-123.45),
+			sheets.Float64Value(1.1),
+			sheets.TimeValue(time.Date(1969, time.December, 31, 23, 59, 59, 999999999, time.UTC)),
+			sheets.Float64Value(math.MaxUint32),
+			sheets.StringValue("12345678-9abc-def0-0123-456789abcdef"),
+			sheets.Float64Value(1.5),
+			sheets.Float64Value(0.1),
+			sheets.BoolValue(true),
+		},
+	}, columnartest.ReadAll(t, s))
+}
+
+func TestReadNested(t *testing.T) {
+	file := buildFile([][]tfield{
+		{{3, int32(repetitionOptional)}, {4, "address"}, {5, int32(2)}},
+		{{1, int32(typeByteArray)}, {3, int32(repetitionRequired)}, {4, "city"}, {6, int32(convertedUTF8)}},
+		{{1, int32(typeInt32)}, {3, int32(repetitionOptional)}, {4, "zip"}},
+		{{1, int32(typeDouble)}, {3, int32(repetitionRequired)}, {4, "score"}},
+	}, []int64{3}, []testChunk{
+		// Definition levels count both the optional group and an optional zip
+		{numValues: 3, data: dataPage(3, []uint32{1, 0, 1}, encodingPlain, plainValues("Paris", "Oslo"))},
+		{numValues: 3, data: func() []byte {
+			levels := appendRLE(nil, []uint32{2, 0, 1}, 2)
+			body := binary.LittleEndian.AppendUint32(nil, uint32(len(levels)))
+			body = append(append(body, levels...), plainValues(int32(75001))...)
+			header := mustAppendStruct(nil, []tfield{
+				{1, int32(pageData)}, {2, int32(len(body))}, {3, int32(len(body))},
+				{5, []tfield{{1, int32(3)}, {2, int32(encodingPlain)}, {3, int32(encodingRLE)}, {4, int32(encodingRLE)}}},
+			})
+
+			return append(header, body...)
+		}()},
+		{numValues: 3, data: dataPage(3, nil, encodingPlain, plainValues(1.5, 2.5, 3.5))},
+	})
+
+	s := readBuf(t, file)
+	assert.Equal(t, []string{"address.city", "address.zip", "score"}, s.Columns())
+	assert.Equal(t, [][]sheets.Value{
+		{sheets.StringValue("address.city"), sheets.StringValue("address.zip"), sheets.StringValue("score")},
+		{sheets.StringValue("Paris"), sheets.Float64Value(75001), sheets.Float64Value(1.5)},
+		{sheets.BlankValue{}, sheets.BlankValue{}, sheets.Float64Value(2.5)},
+		{sheets.StringValue("Oslo"), sheets.BlankValue{}, sheets.Float64Value(3.5)},
+	}, columnartest.ReadAll(t, s))
+}
+
+func TestReadDataPageV2(t *testing.T) {
+	values := plainValues(int64(10), int64(30))
+	compressed := snappyEncode(values)
+	levels := appendRLE(nil, []uint32{1, 0, 1}, 1)
+	body := append(append([]byte{}, levels...), compressed...)
+	page := mustAppendStruct(nil, []tfield{
+		{1, int32(pageDataV2)},
+		{2, int32(len(levels) + len(values))},
+		{3, int32(len(body))},
+		{8, []tfield{
+			{1, int32(3)}, {2, int32(1)}, {3, int32(3)}, {4, int32(encodingPlain)},
+			{5, int32(len(levels))}, {6, int32(0)}, {7, true},
+		}},
+	})
+
+	file := buildFile([][]tfield{
+		{{1, int32(typeInt64)}, {3, int32(repetitionOptional)}, {4, "n"}},
+	}, []int64{3}, []testChunk{{codec: codecSnappy, numValues: 3, data: append(page, body...)}})
+
+	s := readBuf(t, file)
+	assert.Equal(t, [][]sheets.Value{
+		{sheets.StringValue("n")},
+		{sheets.Float64Value(10)},
+		{sheets.BlankValue{}},
+		{sheets.Float64Value(30)},
+	}, columnartest.ReadAll(t, s))
+}
+
+func TestReadDictionary(t *testing.T) {
+	indices := appendRLE([]byte{1}, []uint32{1, 0, 1, 1}, 1)
+	file := buildFile([][]tfield{
+		{{1, int32(typeByteArray)}, {3, int32(repetitionOptional)}, {4, "color"}},
+	}, []int64{5}, []testChunk{{
+		numValues: 5,
+		dict:      dictionaryPage(2, plainValues("red", "blue")),
+		data:      dataPage(5, []uint32{1, 1, 0, 1, 1}, encodingPlainDictionary, indices),
+	}})
+
+	s := readBuf(t, file)
+	assert.Equal(t, [][]sheets.Value{
+		{sheets.StringValue("color")},
+		{sheets.StringValue("blue")},
+		{sheets.StringValue("red")},
+		{sheets.BlankValue{}},
+		{sheets.StringValue("blue")},
+		{sheets.StringValue("blue")},
+	}, columnartest.ReadAll(t, s))
+}
+
+// A countingReader counts the reads of each part of a file.
+type countingReader struct {
+	r     *bytes.Reader
+	mu    sync.Mutex
+	reads map[int64]int
+}
+
+func (r *countingReader) ReadAt(p []byte, off int64) (int, error) {
+	r.mu.Lock()
+	r.reads[off]++
+	r.mu.Unlock()
+	return r.r.ReadAt(p, off)
+}
+
+func TestReadColumnLazily(t *testing.T) {
+	rows := [][]sheets.Value{{sheets.StringValue("a"), sheets.StringValue("b"), sheets.StringValue("c")}}
+	for i := 0; i < 10; i++ {
+		rows = append(rows, []sheets.Value{
+			sheets.Float64Value(i), sheets.StringValue("x"), sheets.BoolValue(i%2 == 0),
+		})
+	}
+
+	ms, err := sheets.NewMutableSheet(rows)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, Write(context.Background(), &buf, ms, WithRowGroupSize(4)))
+
+	r := &countingReader{r: bytes.NewReader(buf.Bytes()), reads: make(map[int64]int)}
+	s, err := Read(r, int64(buf.Len()))
+	require.NoError(t, err)
+
+	footerReads := len(r.reads)
+	ctx := context.Background()
+	vr, err := s.Range(ctx, sheets.Range{StartRow: 1, EndRow: 10, StartCol: 2, EndCol: 2})
+	require.NoError(t, err)
+
+	var values []sheets.Value
+	for vr.Next(ctx) {
+		values = append(values, vr.Value())
+	}
+
+	require.NoError(t, vr.Err())
+	assert.Len(t, values, 10)
+	assert.Equal(t, sheets.BoolValue(false), values[9])
+
+	// Only the chunks of the third column were read, each once
+	assert.Len(t, r.reads, footerReads+3)
+	for _, rg := range s.md.rowGroups {
+		assert.Equal(t, 1, r.reads[rg.columns[2].dataPageOffset])
+		assert.Zero(t, r.reads[rg.columns[0].dataPageOffset])
+		assert.Zero(t, r.reads[rg.columns[1].dataPageOffset])
+		assert.Zero(t, r.reads[rg.columns[1].dictionaryPageOffset])
+	}
+
+	v, err := s.Get(ctx, sheets.Pos{Row: 5, Col: 0})
+	require.NoError(t, err)
+	assert.Equal(t, sheets.Float64Value(4), v)
+}
+
+func TestReadErrors(t *testing.T) {
+	valid := buildFile([][]tfield{
+		{{1, int32(typeInt64)}, {3, int32(repetitionRequired)}, {4, "n"}},
+	}, []int64{1}, []testChunk{{numValues: 1, data: dataPage(1, nil, encodingPlain, plainValues(int64(1)))}})
+
+	for _, tt := range []struct {
+		name     string
+		file     []byte
+		expected string
+	}{
+		{"too short", []byte("PAR1PAR1"), "invalid parquet file: file is too short"},
+		{"magic", append(append([]byte{}, valid[:len(valid)-4]...), "ABCD"...), "invalid parquet file: missing magic number"},
+		{"encrypted", append(append([]byte{}, valid[:len(valid)-4]...), "PARE"...),
+			"invalid parquet file: encrypted files are not supported"},
+		{"footer length", append(append([]byte("PAR1...."), 0xff, 0xff, 0, 0), magic...),
+			"invalid parquet file: invalid footer length"},
+		{"metadata", append(append([]byte("PAR1\x15\x80\x80\x80"), 4, 0, 0, 0), magic...),
+			"invalid parquet file: truncated metadata"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Read(bytes.NewReader(tt.file), int64(len(tt.file)))
+			assert.EqualError(t, err, tt.expected)
+		})
+	}
+}
+
+func TestReadChunkErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		schema   []tfield
+		chunk    testChunk
+		expected string
+	}{
+		{
+			"repeated",
+			[]tfield{{1, int32(typeInt64)}, {3, int32(repetitionRepeated)}, {4, "n"}},
+			testChunk{numValues: 1, data: dataPage(1, nil, encodingPlain, plainValues(int64(1)))},
+			"unable to read column 'n' of row group 0: repeated columns are not supported",
+		},
+		{
+			"encoding",
+			[]tfield{{1, int32(typeInt64)}, {3, int32(repetitionRequired)}, {4, "n"}},
+			testChunk{numValues: 1, data: dataPage(1, nil, 5, nil)},
+			"unable to read column 'n' of row group 0: unsupported encoding DELTA_BINARY_PACKED",
+		},
+		{
+			"codec",
+			[]tfield{{1, int32(typeInt64)}, {3, int32(repetitionRequired)}, {4, "n"}},
+			testChunk{codec: 6, numValues: 1, data: dataPage(1, nil, encodingPlain, plainValues(int64(1)))},
+			"unable to read column 'n' of row group 0: unsupported compression codec ZSTD",
+		},
+		{
+			"missing dictionary",
+			[]tfield{{1, int32(typeInt64)}, {3, int32(repetitionRequired)}, {4, "n"}},
+			testChunk{numValues: 1, data: dataPage(1, nil, encodingRLEDictionary, []byte{1, 2, 0})},
+			"unable to read column 'n' of row group 0: missing dictionary page",
+		},
+		{
+			"truncated",
+			[]tfield{{1, int32(typeInt64)}, {3, int32(repetitionRequired)}, {4, "n"}},
+			testChunk{numValues: 1, data: dataPage(1, nil, encodingPlain, []byte{1, 2})},
+			"unable to read column 'n' of row group 0: truncated page",
+		},
+		{
+			"missing values",
+			[]tfield{{1, int32(typeInt64)}, {3, int32(repetitionRequired)}, {4, "n"}},
+			testChunk{numValues: 2, data: dataPage(1, nil, encodingPlain, plainValues(int64(1)))},
+			"unable to read column 'n' of row group 0: found 1 values, expected 2",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s := readBuf(t, buildFile([][]tfield{tt.schema}, []int64{2}, []testChunk{tt.chunk}))
+			_, err := s.Get(context.Background(), sheets.Pos{Row: 1})
+			assert.EqualError(t, err, tt.expected)
+
+			// The header row needs no chunks
+			v, err := s.Get(context.Background(), sheets.Pos{})
+			require.NoError(t, err)
+			assert.Equal(t, sheets.StringValue("n"), v)
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package parquet
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// Pages compressed with Snappy hold a single block in the Snappy format: the
+// length of the uncompressed data, followed by literals and copies of earlier
+// data.
+
+var errCorruptSnappy = errors.New("corrupt snappy data")
+
+// The tags of Snappy elements.
+const (
+	snappyLiteral = 0
+	snappyCopy1   = 1
+	snappyCopy2   = 2
+	snappyCopy4   = 3
+)
+
+func snappyDecode(src []byte) ([]byte, error) {
+	n, size := binary.Uvarint(src)
+	if size <= 0 || n > uint64(len(src))*256 {
+		return nil, errCorruptSnappy
+	}
+
+	src = src[size:]
+	dst := make([]byte, 0, n)
+	for len(src) > 0 {
+		tag := src[0]
+		var length, offset int
+		switch tag & 3 {
+		case snappyLiteral:
+			length = int(tag >> 2)
+			src = src[1:]
+			if length >= 60 {
+				// The length follows in 1 to 4 bytes
+				extra := length - 59
+				if len(src) < extra {
+					return nil, errCorruptSnappy
+				}
+
+				length = 0
+				for i := 0; i < extra; i++ {
+					length |= int(src[i]) << (8 * i)
+				}
+
+				src = src[extra:]
+			}
+
+			length++
+			if length <= 0 || length > len(src) {
+				return nil, errCorruptSnappy
+			}
+
+			dst = append(dst, src[:length]...)
+			src = src[length:]
+			continue
+		case snappyCopy1:
+			if len(src) < 2 {
+				return nil, errCorruptSnappy
+			}
+
+			length = 4 + int(tag>>2)&7
+			offset = int(tag&0xe0)<<3 | int(src[1])
+			src = src[2:]
+		case snappyCopy2:
+			if len(src) < 3 {
+				return nil, errCorruptSnappy
+			}
+
+			length = 1 + int(tag>>2)
+			offset = int(binary.LittleEndian.Uint16(src[1:]))
+			src = src[3:]
+		case snappyCopy4:
+			if len(src) < 5 {
+				return nil, errCorruptSnappy
+			}
+
+			length = 1 + int(tag>>2)
+			offset = int(binary.LittleEndian.Uint32(src[1:]))
+			src = src[5:]
+		}
+
+		if offset <= 0 || offset > len(dst) || uint64(len(dst)+length) > n {
+			return nil, errCorruptSnappy
+		}
+
+		// Copies may overlap the data they produce
+		start := len(dst) - offset
+		for i := 0; i < length; i++ {
+			dst = append(dst, dst[start+i])
+		}
+	}
+
+	if uint64(len(dst)) != n {
+		return nil, errCorruptSnappy
+	}
+
+	return dst, nil
+}
+
+// snappyEncode compresses data into a Snappy block, replacing sequences of
+// four or more bytes seen within the previous 64KiB with copies.
+func snappyEncode(src []byte) []byte {
+	const (
+		tableBits  = 14
+		maxOffset  = 1 << 16
+		minMatch   = 4
+		maxCopyLen = 64
+	)
+
+	dst := binary.AppendUvarint(nil, uint64(len(src)))
+	var table [1 << tableBits]int
+	hash := func(i int) uint32 {
+		return (binary.LittleEndian.Uint32(src[i:]) * 0x1e35a7bd) >> (32 - tableBits)
+	}
+
+	literal := 0
+	for i := 0; i+minMatch <= len(src); {
+		h := hash(i)
+		candidate := table[h] - 1
+		table[h] = i + 1
+		if candidate < 0 || i-candidate >= maxOffset ||
+			binary.LittleEndian.Uint32(src[candidate:]) != binary.LittleEndian.Uint32(src[i:]) {
+			i++
+			continue
+		}
+
+		length := minMatch
+		for i+length < len(src) && src[candidate+length] == src[i+length] {
+			length++
+		}
+
+		dst = appendSnappyLiteral(dst, src[literal:i])
+		offset := i - candidate
+		for remaining := length; remaining > 0; {
+			n := remaining
+			if n > maxCopyLen {
+				n = maxCopyLen
+				if remaining-n < minMatch {
+					n = remaining - minMatch
+				}
+			}
+
+			dst = append(dst, byte(n-1)<<2|snappyCopy2, byte(offset), byte(offset>>8))
+			remaining -= n
+		}
+
+		i += length
+		literal = i
+	}
+
+	return appendSnappyLiteral(dst, src[literal:])
+}
+
+func appendSnappyLiteral(dst, lit []byte) []byte {
+	if len(lit) == 0 {
+		return dst
+	}
+
+	n := len(lit) - 1
+	switch {
+	case n < 60:
+		dst = append(dst, byte(n)<<2|snappyLiteral)
+	case n < 1<<8:
+		dst = append(dst, 60<<2|snappyLiteral, byte(n))
+	case n < 1<<16:
+		dst = append(dst, 61<<2|snappyLiteral, byte(n), byte(n>>8))
+	case n < 1<<24:
+		dst = append(dst, 62<<2|snappyLiteral, byte(n), byte(n>>8), byte(n>>16))
+	default:
+		dst = append(dst, 63<<2|snappyLiteral, byte(n), byte(n>>8), byte(n>>16), byte(n>>24))
+	}
+
+	return append(dst, lit...)
+}
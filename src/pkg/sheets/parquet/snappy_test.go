@@ -0,0 +1,55 @@
+package parquet
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnappyDecode(t *testing.T) {
+	// A literal "abcd" followed by an overlapping copy of eight bytes
+	b, err := snappyDecode([]byte{12, 0x0c, 'a', 'b', 'c', 'd', 0x11, 0x04})
+	require.NoError(t, err)
+	assert.Equal(t, "abcdabcdabcd", string(b))
+
+	for _, corrupt := range [][]byte{
+		nil,
+		{12, 0x0c, 'a', 'b', 'c', 'd', 0x11, 0x05},
+		{12, 0x0c, 'a', 'b', 'c'},
+		{13, 0x0c, 'a', 'b', 'c', 'd', 0x11, 0x04},
+		{4, 0x0e},
+	} {
+		_, err := snappyDecode(corrupt)
+		assert.Equal(t, errCorruptSnappy, err, "%v", corrupt)
+	}
+}
+
+func TestSnappyRoundTrip(t *testing.T) {
+	random := make([]byte, 5000)
+	rand.New(rand.NewSource(1)).Read(random)
+
+	for _, tt := range []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"short", []byte("abc")},
+		{"repeated", bytes.Repeat([]byte("abcdefgh"), 1000)},
+		{"zeros", make([]byte, 100000)},
+		{"random", random},
+		{"long literal", append(random, bytes.Repeat(random[:100], 3)...)},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded := snappyEncode(tt.data)
+			decoded, err := snappyDecode(encoded)
+			require.NoError(t, err)
+			assert.Equal(t, len(tt.data), len(decoded))
+			assert.True(t, bytes.Equal(tt.data, decoded))
+		})
+	}
+
+	assert.Less(t, len(snappyEncode(make([]byte, 100000))), 5000)
+}
@@ -0,0 +1,121 @@
+//go:build ignore
+
+// Generates arrow_go.snappy.parquet with the Parquet writer of the Apache Arrow
+// Go implementation, so that the reader is tested against files it did not
+// write. Run from the package directory with:
+//
+//	go run testdata/generate.go
+package main
+
+import (
+	"log"
+	"os"
+	"time"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/array"
+	"github.com/apache/arrow/go/v14/arrow/decimal128"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"github.com/apache/arrow/go/v14/parquet"
+	"github.com/apache/arrow/go/v14/parquet/compress"
+	"github.com/apache/arrow/go/v14/parquet/pqarrow"
+)
+
+func main() {
+	address := arrow.StructOf(
+		arrow.Field{Name: "city", Type: arrow.BinaryTypes.String, Nullable: true},
+		arrow.Field{Name: "zip", Type: arrow.PrimitiveTypes.Int32, Nullable: true},
+	)
+
+	schema := arrow.NewSchema([]arrow.Field{
+		{Name: "id", Type: arrow.PrimitiveTypes.Int64},
+		{Name: "name", Type: arrow.BinaryTypes.String, Nullable: true},
+		{Name: "when", Type: &arrow.TimestampType{Unit: arrow.Millisecond, TimeZone: "UTC"}, Nullable: true},
+		{Name: "day", Type: arrow.FixedWidthTypes.Date32, Nullable: true},
+		{Name: "price", Type: &arrow.Decimal128Type{Precision: 9, Scale: 2}, Nullable: true},
+		{Name: "ok", Type: arrow.FixedWidthTypes.Boolean, Nullable: true},
+		{Name: "address", Type: address, Nullable: true},
+	}, nil)
+
+	b := array.NewRecordBuilder(memory.DefaultAllocator, schema)
+	defer b.Release()
+
+	type row struct {
+		name    string
+		when    time.Time
+		price   int64
+		ok      bool
+		city    string
+		zip     int32
+		address bool
+	}
+
+	rows := []*row{
+		{"apple", time.Date(2024, time.March, 5, 12, 30, 0, 0, time.UTC), 150, true, "Paris", 75001, true},
+		{"pear", time.Date(1969, time.July, 20, 20, 17, 40, 0, time.UTC), -225, false, "Rome", 0, true},
+		nil,
+		{"apple", time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC), 123456789, true, "", 10115, true},
+		{"pear", time.Date(2024, time.February, 29, 23, 59, 59, 999000000, time.UTC), 1, false, "", 0, false},
+	}
+
+	st := b.Field(6).(*array.StructBuilder)
+	for i, r := range rows {
+		b.Field(0).(*array.Int64Builder).Append(int64(i + 1))
+		if r == nil {
+			for f := 1; f < len(schema.Fields()); f++ {
+				b.Field(f).AppendNull()
+			}
+
+			continue
+		}
+
+		b.Field(1).(*array.StringBuilder).Append(r.name)
+		b.Field(2).(*array.TimestampBuilder).Append(arrow.Timestamp(r.when.UnixMilli()))
+		b.Field(3).(*array.Date32Builder).Append(arrow.Date32FromTime(r.when))
+		b.Field(4).(*array.Decimal128Builder).Append(decimal128.FromI64(r.price))
+		b.Field(5).(*array.BooleanBuilder).Append(r.ok)
+		if !r.address {
+			st.AppendNull()
+			continue
+		}
+
+		st.Append(true)
+		if r.city == "" {
+			st.FieldBuilder(0).AppendNull()
+		} else {
+			st.FieldBuilder(0).(*array.StringBuilder).Append(r.city)
+		}
+
+		if r.zip == 0 {
+			st.FieldBuilder(1).AppendNull()
+		} else {
+			st.FieldBuilder(1).(*array.Int32Builder).Append(r.zip)
+		}
+	}
+
+	rec := b.NewRecord()
+	defer rec.Release()
+
+	f, err := os.Create("testdata/arrow_go.snappy.parquet")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	props := parquet.NewWriterProperties(
+		parquet.WithCompression(compress.Codecs.Snappy),
+		parquet.WithDictionaryDefault(true),
+		parquet.WithMaxRowGroupLength(3))
+	w, err := pqarrow.NewFileWriter(schema, f, props, pqarrow.DefaultWriterProps())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := w.Write(rec); err != nil {
+		log.Fatal(err)
+	}
+
+	// Closing the writer closes the file
+	if err := w.Close(); err != nil {
+		log.Fatal(err)
+	}
+}
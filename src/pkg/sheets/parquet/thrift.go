@@ -0,0 +1,404 @@
+package parquet
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// The metadata of Parquet files is encoded with the Thrift compact protocol.
+// Rather than generating code for the whole Parquet schema, structs are
+// decoded into maps of field IDs to values, from which the fields that are
+// used are read, and encoded from lists of fields.
+
+// The types of values in the compact protocol.
+const (
+	thriftTrue   = 1
+	thriftFalse  = 2
+	thriftByte   = 3
+	thriftI16    = 4
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftDouble = 7
+	thriftBinary = 8
+	thriftList   = 9
+	thriftSet    = 10
+	thriftMap    = 11
+	thriftStruct = 12
+)
+
+// maxThriftDepth limits the nesting of structs and lists, so that corrupt
+// metadata cannot exhaust the stack.
+const maxThriftDepth = 64
+
+var errTruncatedThrift = errors.New("truncated metadata")
+
+// A tstruct is a decoded Thrift struct. Integers of every width are decoded
+// as int64s, binary fields as []byte, lists and sets as []any, and nested
+// structs as tstructs. Maps are skipped, since Parquet does not use them.
+type tstruct map[int16]any
+
+func (s tstruct) has(id int16) bool {
+	_, ok := s[id]
+	return ok
+}
+
+func (s tstruct) int(id int16) int64 {
+	n, _ := s[id].(int64)
+	return n
+}
+
+// intOr returns an integer field, or a default if the field is not set.
+func (s tstruct) intOr(id int16, def int64) int64 {
+	if n, ok := s[id].(int64); ok {
+		return n
+	}
+
+	return def
+}
+
+func (s tstruct) bool(id int16) bool {
+	b, _ := s[id].(bool)
+	return b
+}
+
+func (s tstruct) str(id int16) string {
+	b, _ := s[id].([]byte)
+	return string(b)
+}
+
+func (s tstruct) strct(id int16) tstruct {
+	v, _ := s[id].(tstruct)
+	return v
+}
+
+func (s tstruct) list(id int16) []any {
+	v, _ := s[id].([]any)
+	return v
+}
+
+// A thriftDecoder decodes values in the compact protocol.
+type thriftDecoder struct {
+	buf   []byte
+	pos   int
+	depth int
+}
+
+// decodeStruct decodes a struct from the start of a buffer, returning the
+// struct and the number of bytes it occupies.
+func decodeStruct(buf []byte) (tstruct, int, error) {
+	d := &thriftDecoder{buf: buf}
+	s, err := d.readStruct()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return s, d.pos, nil
+}
+
+func (d *thriftDecoder) readByte() (byte, error) {
+	if d.pos >= len(d.buf) {
+		return 0, errTruncatedThrift
+	}
+
+	b := d.buf[d.pos]
+	d.pos++
+	return b, nil
+}
+
+func (d *thriftDecoder) readUvarint() (uint64, error) {
+	n, size := binary.Uvarint(d.buf[d.pos:])
+	if size <= 0 {
+		return 0, errTruncatedThrift
+	}
+
+	d.pos += size
+	return n, nil
+}
+
+func (d *thriftDecoder) readVarint() (int64, error) {
+	n, err := d.readUvarint()
+	if err != nil {
+		return 0, err
+	}
+
+	// Signed integers are zigzag encoded
+	return int64(n>>1) ^ -int64(n&1), nil
+}
+
+func (d *thriftDecoder) readBinary() ([]byte, error) {
+	n, err := d.readUvarint()
+	if err != nil {
+		return nil, err
+	}
+
+	if n > uint64(len(d.buf)-d.pos) {
+		return nil, errTruncatedThrift
+	}
+
+	b := d.buf[d.pos : d.pos+int(n)]
+	d.pos += int(n)
+	return b, nil
+}
+
+func (d *thriftDecoder) readStruct() (tstruct, error) {
+	if d.depth++; d.depth > maxThriftDepth {
+		return nil, errors.New("metadata is nested too deeply")
+	}
+
+	defer func() { d.depth-- }()
+
+	s := make(tstruct)
+	var id int16
+	for {
+		header, err := d.readByte()
+		if err != nil {
+			return nil, err
+		}
+
+		if header == 0 {
+			return s, nil
+		}
+
+		// Field IDs are deltas from the previous field, unless the delta is
+		// zero, in which case the ID follows
+		if delta := header >> 4; delta != 0 {
+			id += int16(delta)
+		} else {
+			n, err := d.readVarint()
+			if err != nil {
+				return nil, err
+			}
+
+			id = int16(n)
+		}
+
+		typ := header & 0x0f
+		switch typ {
+		case thriftTrue, thriftFalse:
+			s[id] = typ == thriftTrue
+		default:
+			v, err := d.readValue(typ)
+			if err != nil {
+				return nil, err
+			}
+
+			if v != nil {
+				s[id] = v
+			}
+		}
+	}
+}
+
+func (d *thriftDecoder) readValue(typ byte) (any, error) {
+	switch typ {
+	case thriftTrue, thriftFalse:
+		// Booleans within lists are encoded as a byte
+		b, err := d.readByte()
+		return b == thriftTrue, err
+	case thriftByte:
+		b, err := d.readByte()
+		return int64(int8(b)), err
+	case thriftI16, thriftI32, thriftI64:
+		return d.readVarint()
+	case thriftDouble:
+		if len(d.buf)-d.pos < 8 {
+			return nil, errTruncatedThrift
+		}
+
+		f := math.Float64frombits(binary.LittleEndian.Uint64(d.buf[d.pos:]))
+		d.pos += 8
+		return f, nil
+	case thriftBinary:
+		return d.readBinary()
+	case thriftList, thriftSet:
+		return d.readList()
+	case thriftMap:
+		return nil, d.skipMap()
+	case thriftStruct:
+		return d.readStruct()
+	default:
+		return nil, fmt.Errorf("invalid metadata type %d", typ)
+	}
+}
+
+func (d *thriftDecoder) readList() ([]any, error) {
+	if d.depth++; d.depth > maxThriftDepth {
+		return nil, errors.New("metadata is nested too deeply")
+	}
+
+	defer func() { d.depth-- }()
+
+	header, err := d.readByte()
+	if err != nil {
+		return nil, err
+	}
+
+	n := uint64(header >> 4)
+	if n == 15 {
+		if n, err = d.readUvarint(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Each element takes at least a byte
+	if n > uint64(len(d.buf)-d.pos) {
+		return nil, errTruncatedThrift
+	}
+
+	list := make([]any, n)
+	for i := range list {
+		if list[i], err = d.readValue(header & 0x0f); err != nil {
+			return nil, err
+		}
+	}
+
+	return list, nil
+}
+
+func (d *thriftDecoder) skipMap() error {
+	n, err := d.readUvarint()
+	if err != nil || n == 0 {
+		return err
+	}
+
+	types, err := d.readByte()
+	if err != nil {
+		return err
+	}
+
+	for i := uint64(0); i < n; i++ {
+		if _, err := d.readValue(types >> 4); err != nil {
+			return err
+		}
+
+		if _, err := d.readValue(types & 0x0f); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// A tfield is a field of a struct being encoded. Values are bools, int8s
+// (bytes), int16s, int32s, int64s, float64s, strings, []bytes, tlists and
+// []tfields (structs).
+type tfield struct {
+	id    int16
+	value any
+}
+
+// A tlist is a list being encoded, whose elements have the given type.
+type tlist struct {
+	elemType byte
+	items    []any
+}
+
+// appendStruct appends a struct in the compact protocol to a buffer. The
+// fields must be in order of their IDs.
+func appendStruct(buf []byte, fields []tfield) ([]byte, error) {
+	var last int16
+	for _, f := range fields {
+		typ, err := thriftType(f.value)
+		if err != nil {
+			return nil, err
+		}
+
+		if b, ok := f.value.(bool); ok && !b {
+			typ = thriftFalse
+		}
+
+		if delta := f.id - last; delta > 0 && delta <= 15 {
+			buf = append(buf, byte(delta)<<4|typ)
+		} else {
+			buf = append(buf, typ)
+			buf = appendVarint(buf, int64(f.id))
+		}
+
+		last = f.id
+		if typ != thriftTrue && typ != thriftFalse {
+			if buf, err = appendValue(buf, f.value); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return append(buf, 0), nil
+}
+
+func thriftType(v any) (byte, error) {
+	switch v.(type) {
+	case bool:
+		return thriftTrue, nil
+	case int8:
+		return thriftByte, nil
+	case int16:
+		return thriftI16, nil
+	case int32:
+		return thriftI32, nil
+	case int64:
+		return thriftI64, nil
+	case float64:
+		return thriftDouble, nil
+	case string, []byte:
+		return thriftBinary, nil
+	case tlist:
+		return thriftList, nil
+	case []tfield:
+		return thriftStruct, nil
+	default:
+		return 0, fmt.Errorf("unsupported metadata value %T", v)
+	}
+}
+
+func appendValue(buf []byte, v any) ([]byte, error) {
+	switch tv := v.(type) {
+	case bool:
+		if tv {
+			return append(buf, thriftTrue), nil
+		}
+
+		return append(buf, thriftFalse), nil
+	case int8:
+		return append(buf, byte(tv)), nil
+	case int16:
+		return appendVarint(buf, int64(tv)), nil
+	case int32:
+		return appendVarint(buf, int64(tv)), nil
+	case int64:
+		return appendVarint(buf, tv), nil
+	case float64:
+		return binary.LittleEndian.AppendUint64(buf, math.Float64bits(tv)), nil
+	case string:
+		buf = binary.AppendUvarint(buf, uint64(len(tv)))
+		return append(buf, tv...), nil
+	case []byte:
+		buf = binary.AppendUvarint(buf, uint64(len(tv)))
+		return append(buf, tv...), nil
+	case tlist:
+		if len(tv.items) < 15 {
+			buf = append(buf, byte(len(tv.items))<<4|tv.elemType)
+		} else {
+			buf = append(buf, 0xf0|tv.elemType)
+			buf = binary.AppendUvarint(buf, uint64(len(tv.items)))
+		}
+
+		for _, item := range tv.items {
+			var err error
+			if buf, err = appendValue(buf, item); err != nil {
+				return nil, err
+			}
+		}
+
+		return buf, nil
+	case []tfield:
+		return appendStruct(buf, tv)
+	default:
+		return nil, fmt.Errorf("unsupported metadata value %T", v)
+	}
+}
+
+func appendVarint(buf []byte, n int64) []byte {
+	return binary.AppendUvarint(buf, uint64(n<<1)^uint64(n>>63))
+}
@@ -0,0 +1,83 @@
+package parquet
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mustAppendStruct appends a struct whose values are known to be supported.
+func mustAppendStruct(buf []byte, fields []tfield) []byte {
+	buf, err := appendStruct(buf, fields)
+	if err != nil {
+		panic(err)
+	}
+
+	return buf
+}
+
+func TestThriftRoundTrip(t *testing.T) {
+	items := make([]any, 20)
+	for i := range items {
+		items[i] = int32(i)
+	}
+
+	buf := mustAppendStruct(nil, []tfield{
+		{1, true},
+		{2, false},
+		{3, int8(-3)},
+		{4, int16(300)},
+		{5, int32(-70000)},
+		{6, int64(1) << 40},
+		{7, 2.5},
+		{8, "name"},
+		{10, tlist{thriftI32, items}},
+		{30, []tfield{{1, "nested"}, {2, tlist{thriftBinary, []any{"a", []byte("b")}}}}},
+	})
+
+	s, n, err := decodeStruct(append(buf, 0xff))
+	require.NoError(t, err)
+	assert.Equal(t, len(buf), n)
+
+	assert.True(t, s.bool(1))
+	assert.True(t, s.has(2))
+	assert.False(t, s.bool(2))
+	assert.Equal(t, int64(-3), s.int(3))
+	assert.Equal(t, int64(300), s.int(4))
+	assert.Equal(t, int64(-70000), s.int(5))
+	assert.Equal(t, int64(1)<<40, s.int(6))
+	assert.Equal(t, 2.5, s[7])
+	assert.Equal(t, "name", s.str(8))
+	assert.Len(t, s.list(10), 20)
+	assert.Equal(t, int64(19), s.list(10)[19])
+	assert.Equal(t, "nested", s.strct(30).str(1))
+	assert.Equal(t, []any{[]byte("a"), []byte("b")}, s.strct(30).list(2))
+	assert.Equal(t, int64(-1), s.intOr(9, -1))
+	assert.Nil(t, s.strct(9))
+}
+
+func TestThriftErrors(t *testing.T) {
+	buf := mustAppendStruct(nil, []tfield{{1, "name"}, {2, int64(5)}})
+	for i := 0; i < len(buf); i++ {
+		_, _, err := decodeStruct(buf[:i])
+		assert.Equal(t, errTruncatedThrift, err, "truncated at %d", i)
+	}
+
+	_, _, err := decodeStruct([]byte{0x1d})
+	assert.EqualError(t, err, "invalid metadata type 13")
+
+	_, err = appendStruct(nil, []tfield{{1, "name"}, {2, uint32(5)}})
+	assert.EqualError(t, err, "unsupported metadata value uint32")
+
+	_, err = appendStruct(nil, []tfield{{1, tlist{thriftI32, []any{int32(1), uint8(2)}}}})
+	assert.EqualError(t, err, "unsupported metadata value uint8")
+
+	nested := make([]byte, 0, 2*maxThriftDepth)
+	for i := 0; i <= maxThriftDepth; i++ {
+		nested = append(nested, 0x1c)
+	}
+
+	_, _, err = decodeStruct(nested)
+	assert.EqualError(t, err, "metadata is nested too deeply")
+}
@@ -0,0 +1,437 @@
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/mmihic/sheets/src/pkg/sheets"
+	"github.com/mmihic/sheets/src/pkg/sheets/internal/columnar"
+)
+
+// A Codec is a compression codec for the pages of a file.
+type Codec int
+
+// The codecs supported by the writer.
+const (
+	Uncompressed Codec = codecUncompressed
+	Snappy       Codec = codecSnappy
+	Gzip         Codec = codecGzip
+)
+
+// DefaultRowGroupSize is the number of rows in each row group of a written
+// file, unless set with WithRowGroupSize.
+const DefaultRowGroupSize = 1 << 16
+
+// createdBy identifies the writer of files in their metadata.
+const createdBy = "github.com/mmihic/sheets"
+
+// A WriteOption is an option for writing a Parquet file.
+type WriteOption func(opts *writeOptions)
+
+type writeOptions struct {
+	codec        Codec
+	rowGroupSize int
+}
+
+// WithCompression compresses pages with the given codec. Pages are compressed
+// with Snappy by default.
+func WithCompression(codec Codec) WriteOption {
+	return func(opts *writeOptions) {
+		opts.codec = codec
+	}
+}
+
+// WithRowGroupSize sets the number of rows in each row group.
+func WithRowGroupSize(rows int) WriteOption {
+	return func(opts *writeOptions) {
+		opts.rowGroupSize = rows
+	}
+}
+
+// WriteFile writes a sheet to a Parquet file at the given path.
+func WriteFile(ctx context.Context, name string, s sheets.Sheet, opts ...WriteOption) (err error) {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	return Write(ctx, f, s, opts...)
+}
+
+// Write writes a sheet as a Parquet file. The first row of the sheet holds the
+// names of its columns, and formulas are computed as the sheet is read.
+//
+// Every column is optional, with blanks written as nulls. Columns of numbers
+// are written as doubles, columns of booleans as booleans, and columns of
+// times as timestamps in microseconds, adjusted to UTC. Every other column is
+// written as strings, with errors written as their error codes; columns with
+// many repeated strings are dictionary encoded.
+func Write(ctx context.Context, w io.Writer, s sheets.Sheet, opts ...WriteOption) error {
+	options := writeOptions{codec: Snappy, rowGroupSize: DefaultRowGroupSize}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	switch options.codec {
+	case Uncompressed, Snappy, Gzip:
+	default:
+		return fmt.Errorf("unsupported compression codec %d", options.codec)
+	}
+
+	if options.rowGroupSize <= 0 {
+		return fmt.Errorf("invalid row group size %d", options.rowGroupSize)
+	}
+
+	columns, err := columnar.ReadColumns(ctx, s)
+	if err != nil {
+		return err
+	}
+
+	wr := &writer{w: w, codec: options.codec}
+	if err := wr.write([]byte(magic)); err != nil {
+		return err
+	}
+
+	numRows := 0
+	if len(columns) > 0 {
+		numRows = len(columns[0].Values)
+	}
+
+	var rowGroups []any
+	for start := 0; start < numRows; start += options.rowGroupSize {
+		end := start + options.rowGroupSize
+		if end > numRows {
+			end = numRows
+		}
+
+		var (
+			chunks    []any
+			totalSize int64
+		)
+
+		for _, c := range columns {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			chunk, size, err := wr.writeChunk(c, c.Values[start:end])
+			if err != nil {
+				return fmt.Errorf("unable to write column '%s': %w", c.Name, err)
+			}
+
+			chunks = append(chunks, chunk)
+			totalSize += size
+		}
+
+		rowGroups = append(rowGroups, []tfield{
+			{1, tlist{thriftStruct, chunks}},
+			{2, totalSize},
+			{3, int64(end - start)},
+		})
+	}
+
+	md, err := appendStruct(nil, []tfield{
+		{1, int32(1)},
+		{2, tlist{thriftStruct, schema(columns)}},
+		{3, int64(numRows)},
+		{4, tlist{thriftStruct, rowGroups}},
+		{6, createdBy},
+	})
+	if err != nil {
+		return fmt.Errorf("unable to encode metadata: %w", err)
+	}
+
+	md = binary.LittleEndian.AppendUint32(md, uint32(len(md)))
+	return wr.write(append(md, magic...))
+}
+
+// schema returns the schema elements of the columns, following the root.
+func schema(columns []columnar.Column) []any {
+	elems := []any{[]tfield{
+		{4, "schema"},
+		{5, int32(len(columns))},
+	}}
+
+	for _, c := range columns {
+		var fields []tfield
+		switch c.Kind {
+		case columnar.KindNumber:
+			fields = []tfield{{1, int32(typeDouble)}, {3, int32(repetitionOptional)}, {4, c.Name}}
+		case columnar.KindBool:
+			fields = []tfield{{1, int32(typeBoolean)}, {3, int32(repetitionOptional)}, {4, c.Name}}
+		case columnar.KindTime:
+			fields = []tfield{
+				{1, int32(typeInt64)},
+				{3, int32(repetitionOptional)},
+				{4, c.Name},
+				{6, int32(convertedTimestampMicros)},
+				{10, []tfield{{logicalTimestamp, []tfield{
+					{1, true},
+					{2, []tfield{{unitMicros, []tfield{}}}},
+				}}}},
+			}
+		default:
+			fields = []tfield{
+				{1, int32(typeByteArray)},
+				{3, int32(repetitionOptional)},
+				{4, c.Name},
+				{6, int32(convertedUTF8)},
+				{10, []tfield{{logicalString, []tfield{}}}},
+			}
+		}
+
+		elems = append(elems, fields)
+	}
+
+	return elems
+}
+
+// A writer writes the pages of a file, tracking their offsets.
+type writer struct {
+	w      io.Writer
+	codec  Codec
+	offset int64
+}
+
+func (wr *writer) write(b []byte) error {
+	n, err := wr.w.Write(b)
+	wr.offset += int64(n)
+	return err
+}
+
+// writeChunk writes the chunk of a column within a row group as a single data
+// page, preceded by a dictionary page if the column is dictionary encoded.
+// Returns the ColumnChunk describing the chunk, and its uncompressed size.
+func (wr *writer) writeChunk(c columnar.Column, values []sheets.Value) ([]tfield, int64, error) {
+	defs := make([]uint32, len(values))
+	var defined []sheets.Value
+	for i, v := range values {
+		if _, blank := v.(sheets.BlankValue); !blank {
+			defs[i] = 1
+			defined = append(defined, v)
+		}
+	}
+
+	var (
+		typ      int64
+		data     []byte
+		dict     []byte
+		dictSize int
+		err      error
+		encoding = int64(encodingPlain)
+	)
+
+	switch c.Kind {
+	case columnar.KindNumber:
+		typ = typeDouble
+		for _, v := range defined {
+			n, _ := v.ToFloat64()
+			if data, err = appendPlain(data, n); err != nil {
+				return nil, 0, err
+			}
+		}
+	case columnar.KindBool:
+		typ = typeBoolean
+		data = make([]byte, (len(defined)+7)/8)
+		for i, v := range defined {
+			if v.(sheets.BoolValue) {
+				data[i/8] |= 1 << (i % 8)
+			}
+		}
+	case columnar.KindTime:
+		typ = typeInt64
+		for _, v := range defined {
+			if data, err = appendPlain(data, time.Time(v.(sheets.TimeValue)).UnixMicro()); err != nil {
+				return nil, 0, err
+			}
+		}
+	default:
+		typ = typeByteArray
+		if data, dict, dictSize, err = encodeText(defined); err != nil {
+			return nil, 0, err
+		}
+
+		if dict != nil {
+			encoding = encodingRLEDictionary
+		}
+	}
+
+	levels := appendRLE(nil, defs, 1)
+	page := binary.LittleEndian.AppendUint32(nil, uint32(len(levels)))
+	page = append(append(page, levels...), data...)
+
+	start := wr.offset
+	var (
+		dictOffset   int64
+		uncompressed int64
+		compressed   int64
+		encodings    = []any{int32(encodingRLE), int32(encoding)}
+	)
+
+	if dict != nil {
+		dictOffset = wr.offset
+		u, c, err := wr.writePage(dict, []tfield{
+			{1, int32(pageDictionary)},
+			{7, []tfield{{1, int32(dictSize)}, {2, int32(encodingPlainDictionary)}}},
+		})
+		if err != nil {
+			return nil, 0, err
+		}
+
+		uncompressed, compressed = uncompressed+u, compressed+c
+		encodings = append(encodings, int32(encodingPlain))
+	}
+
+	dataOffset := wr.offset
+	u, cs, err := wr.writePage(page, []tfield{
+		{1, int32(pageData)},
+		{5, []tfield{
+			{1, int32(len(values))},
+			{2, int32(encoding)},
+			{3, int32(encodingRLE)},
+			{4, int32(encodingRLE)},
+		}},
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	uncompressed, compressed = uncompressed+u, compressed+cs
+	meta := []tfield{
+		{1, int32(typ)},
+		{2, tlist{thriftI32, encodings}},
+		{3, tlist{thriftBinary, []any{c.Name}}},
+		{4, int32(wr.codec)},
+		{5, int64(len(values))},
+		{6, uncompressed},
+		{7, compressed},
+		{9, dataOffset},
+	}
+
+	if dict != nil {
+		meta = append(meta, tfield{11, dictOffset})
+	}
+
+	return []tfield{{2, start}, {3, meta}}, uncompressed, nil
+}
+
+// writePage compresses and writes a page with a header of the given type,
+// whose fields must have IDs following those of the page sizes. Returns the
+// uncompressed and compressed sizes of the page, including its header.
+func (wr *writer) writePage(data []byte, fields []tfield) (int64, int64, error) {
+	body, err := compress(wr.codec, data)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	header, err := appendStruct(nil, append([]tfield{
+		fields[0],
+		{2, int32(len(data))},
+		{3, int32(len(body))},
+	}, fields[1:]...))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if err := wr.write(header); err != nil {
+		return 0, 0, err
+	}
+
+	if err := wr.write(body); err != nil {
+		return 0, 0, err
+	}
+
+	return int64(len(header) + len(data)), int64(len(header) + len(body)), nil
+}
+
+// encodeText encodes strings, returning the data of their data page, along
+// with the data and size of their dictionary if they are repeated enough to be
+// worth one.
+func encodeText(values []sheets.Value) ([]byte, []byte, int, error) {
+	texts := make([]string, len(values))
+	indices := make(map[string]uint32)
+	for i, v := range values {
+		texts[i] = columnar.Text(v)
+		if _, ok := indices[texts[i]]; !ok {
+			indices[texts[i]] = uint32(len(indices))
+		}
+	}
+
+	if len(values) == 0 || 2*len(indices) > len(values) {
+		var (
+			data []byte
+			err  error
+		)
+
+		for _, t := range texts {
+			if data, err = appendPlain(data, t); err != nil {
+				return nil, nil, 0, err
+			}
+		}
+
+		return data, nil, 0, nil
+	}
+
+	ordered := make([]string, len(indices))
+	for t, i := range indices {
+		ordered[i] = t
+	}
+
+	var (
+		dict []byte
+		err  error
+	)
+
+	for _, t := range ordered {
+		if dict, err = appendPlain(dict, t); err != nil {
+			return nil, nil, 0, err
+		}
+	}
+
+	ids := make([]uint32, len(texts))
+	for i, t := range texts {
+		ids[i] = indices[t]
+	}
+
+	width := bitWidth(len(indices) - 1)
+	if width == 0 {
+		width = 1
+	}
+
+	data := appendRLE([]byte{byte(width)}, ids, width)
+	return data, dict, len(indices), nil
+}
+
+// compress compresses the data of a page.
+func compress(codec Codec, data []byte) ([]byte, error) {
+	switch codec {
+	case Snappy:
+		return snappyEncode(data), nil
+	case Gzip:
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(data); err != nil {
+			return nil, err
+		}
+
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+
+		return buf.Bytes(), nil
+	default:
+		return data, nil
+	}
+}
@@ -0,0 +1,161 @@
+package parquet
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mmihic/sheets/src/pkg/sheets"
+	"github.com/mmihic/sheets/src/pkg/sheets/internal/columnar/columnartest"
+)
+
+func writeAndRead(t *testing.T, s sheets.Sheet, opts ...WriteOption) *Sheet {
+	var buf bytes.Buffer
+	require.NoError(t, Write(context.Background(), &buf, s, opts...))
+
+	read, err := Read(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	return read
+}
+
+func TestWriteRoundTrip(t *testing.T) {
+	expected := [][]sheets.Value{
+		{
+			sheets.StringValue("name"), sheets.StringValue("price"), sheets.StringValue("when"),
+			sheets.StringValue("ok"), sheets.StringValue("total"), sheets.StringValue("F"),
+		},
+		{
+			sheets.StringValue("apple"), sheets.Float64Value(1.5),
+			sheets.TimeValue(time.Date(2024, time.March, 5, 12, 30, 0, 0, time.UTC)),
+			sheets.BoolValue(true), sheets.StringValue("3"), sheets.BlankValue{},
+		},
+		{
+			sheets.BlankValue{}, sheets.Float64Value(-2.25), sheets.BlankValue{},
+			sheets.BoolValue(false), sheets.StringValue("#DIV/0!"), sheets.BlankValue{},
+		},
+		{
+			sheets.StringValue("pear"), sheets.BlankValue{},
+			sheets.TimeValue(time.Date(1969, time.July, 20, 20, 17, 40, 0, time.UTC)),
+			sheets.BlankValue{}, sheets.StringValue("1"), sheets.BlankValue{},
+		},
+	}
+
+	for _, tt := range []struct {
+		name string
+		opts []WriteOption
+	}{
+		{"default", nil},
+		{"uncompressed", []WriteOption{WithCompression(Uncompressed)}},
+		{"gzip", []WriteOption{WithCompression(Gzip)}},
+		{"row groups", []WriteOption{WithRowGroupSize(2)}},
+		{"single rows", []WriteOption{WithRowGroupSize(1), WithCompression(Gzip)}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s := writeAndRead(t, columnartest.NewSheet(t), tt.opts...)
+			assert.Equal(t, []string{"name", "price", "when", "ok", "total", "F"}, s.Columns())
+			assert.Equal(t, sheets.Dimensions{EndRow: 3, EndCol: 5}, s.Dimensions())
+			assert.Equal(t, expected, columnartest.ReadAll(t, s))
+		})
+	}
+}
+
+func TestWriteSchema(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Write(context.Background(), &buf, columnartest.NewSheet(t), WithRowGroupSize(2)))
+
+	s, err := Read(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+
+	var types []int64
+	for _, l := range s.leaves {
+		types = append(types, l.elem.typ)
+		assert.Equal(t, int64(repetitionOptional), l.elem.repetition, l.name)
+	}
+
+	assert.Equal(t, []int64{typeByteArray, typeDouble, typeInt64, typeBoolean, typeByteArray, typeByteArray}, types)
+	assert.True(t, s.leaves[0].elem.logicalType.has(logicalString))
+	assert.Equal(t, int64(convertedTimestampMicros), s.leaves[2].elem.convertedType)
+	assert.True(t, s.leaves[2].elem.logicalType.strct(logicalTimestamp).bool(1))
+	assert.Equal(t, int64(3), s.md.numRows)
+	require.Len(t, s.md.rowGroups, 2)
+	assert.Equal(t, int64(2), s.md.rowGroups[0].numRows)
+	assert.Equal(t, int64(1), s.md.rowGroups[1].numRows)
+	assert.Equal(t, int64(codecSnappy), s.md.rowGroups[0].columns[0].codec)
+}
+
+func TestWriteDictionary(t *testing.T) {
+	rows := [][]sheets.Value{{sheets.StringValue("color"), sheets.StringValue("id")}}
+	colors := []string{"red", "green", "blue"}
+	for i := 0; i < 100; i++ {
+		rows = append(rows, []sheets.Value{
+			sheets.StringValue(colors[i%len(colors)]),
+			sheets.StringValue(string(rune('a'+i%26)) + string(rune('a'+i/26))),
+		})
+	}
+
+	rows[50][0] = sheets.BlankValue{}
+	ms, err := sheets.NewMutableSheet(rows)
+	require.NoError(t, err)
+
+	s := writeAndRead(t, ms)
+	cc := s.md.rowGroups[0].columns
+	assert.NotZero(t, cc[0].dictionaryPageOffset, "repeated strings are dictionary encoded")
+	assert.Zero(t, cc[1].dictionaryPageOffset, "distinct strings are not")
+
+	all := columnartest.ReadAll(t, s)
+	require.Len(t, all, 101)
+	assert.Equal(t, sheets.StringValue("red"), all[1][0])
+	assert.Equal(t, sheets.StringValue("blue"), all[3][0])
+	assert.Equal(t, sheets.BlankValue{}, all[50][0])
+	assert.Equal(t, sheets.StringValue("red"), all[100][0])
+	assert.Equal(t, sheets.StringValue("vd"), all[100][1])
+}
+
+func TestWriteEmpty(t *testing.T) {
+	ms, err := sheets.NewMutableSheet([][]sheets.Value{{sheets.StringValue("a"), sheets.StringValue("b")}})
+	require.NoError(t, err)
+
+	s := writeAndRead(t, ms)
+	assert.Equal(t, []string{"a", "b"}, s.Columns())
+	assert.Equal(t, sheets.Dimensions{EndRow: 0, EndCol: 1}, s.Dimensions())
+	assert.Empty(t, s.md.rowGroups)
+}
+
+func TestWriteFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "data.parquet")
+	require.NoError(t, WriteFile(context.Background(), name, columnartest.NewSheet(t)))
+
+	f, err := Open(name)
+	require.NoError(t, err)
+	defer func() { _ = f.Close() }()
+
+	v, err := f.Get(context.Background(), sheets.Pos{Row: 3, Col: 0})
+	require.NoError(t, err)
+	assert.Equal(t, sheets.StringValue("pear"), v)
+}
+
+func TestWriteErrors(t *testing.T) {
+	ms, err := sheets.NewMutableSheet([][]sheets.Value{{sheets.StringValue("a"), sheets.StringValue("a")}})
+	require.NoError(t, err)
+
+	for _, tt := range []struct {
+		name     string
+		s        sheets.Sheet
+		opts     []WriteOption
+		expected string
+	}{
+		{"duplicate column", ms, nil, "duplicate column name 'a'"},
+		{"codec", columnartest.NewSheet(t), []WriteOption{WithCompression(Codec(6))}, "unsupported compression codec 6"},
+		{"row group size", columnartest.NewSheet(t), []WriteOption{WithRowGroupSize(0)}, "invalid row group size 0"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			assert.EqualError(t, Write(context.Background(), &buf, tt.s, tt.opts...), tt.expected)
+		})
+	}
+}
@@ -3,6 +3,8 @@ package sheets
 import (
 	"context"
 	"fmt"
+	"strings"
+	"time"
 )
 
 // InvalidPosError is returned when attempting to access a position outside
@@ -28,6 +30,7 @@ type valueRange struct {
 	index      int
 	value      Value
 	sheet      Sheet
+	empty      bool
 	err        error
 }
 
@@ -38,6 +41,10 @@ func (v *valueRange) Next(ctx context.Context) bool {
 
 	if v.value == nil {
 		// This is the first retrieval
+		if v.empty {
+			return false
+		}
+
 		v.value, v.err = v.sheet.Get(ctx, v.currentPos)
 		return v.err == nil
 	}
@@ -66,6 +73,10 @@ func (v *valueRange) Index() int {
 }
 
 func (v *valueRange) Len() int {
+	if v.empty {
+		return 0
+	}
+
 	return v.bounds.NumCells()
 }
 
@@ -73,8 +84,29 @@ func (v *valueRange) Pos() Pos {
 	return v.currentPos
 }
 
+// newValueRange returns a ValueRange over the given range of a sheet,
+// resolving the range against the sheet's dimensions.
+func newValueRange(s Sheet, r Range) *valueRange {
+	resolved, ok := r.Resolve(s.Dimensions())
+	if !ok {
+		return &valueRange{
+			sheet:      s,
+			bounds:     r,
+			currentPos: r.StartPos(),
+			empty:      true,
+		}
+	}
+
+	return &valueRange{
+		sheet:      s,
+		bounds:     resolved,
+		currentPos: resolved.StartPos(),
+	}
+}
+
 var (
-	_ ValueRange = &valueRange{}
+	_ ValueRange   = &valueRange{}
+	_ FormulaSheet = &inMemorySheet{}
 )
 
 // Dimensions are the dimensions of a sheet.
@@ -83,70 +115,223 @@ type Dimensions struct {
 }
 
 // A Sheet is allows arbitrary access to a matrix of cells.
+//
+// Ranges passed to Range may be open-ended (e.g. "A:C" or "2:5") or may
+// extend past the populated area of the sheet; implementations resolve them
+// against the sheet's Dimensions (see Range.Resolve), so that only
+// populated cells are iterated.
 type Sheet interface {
 	Get(ctx context.Context, pos Pos) (Value, error)
 	Range(ctx context.Context, r Range) (ValueRange, error)
 	Dimensions() Dimensions
 }
 
+// A FormulaSheet is a Sheet whose cells may contain formulas. Get returns
+// the computed value of a formula cell, while Formula returns the formula
+// itself.
+type FormulaSheet interface {
+	Sheet
+
+	// Formula returns the formula in a cell, or false if the cell does not
+	// contain a formula.
+	Formula(pos Pos) (Formula, bool)
+}
+
+// A SheetOption is an option for creating an in-memory sheet.
+type SheetOption func(opts *sheetOptions)
+
+type sheetOptions struct {
+	formulas    bool
+	emptyAsText bool
+	inferrer    *TypeInferrer
+	locale      *Locale
+	location    *time.Location
+}
+
+// WithFormulas treats text values that start with "=" as formulas, so that the
+// sheet behaves like a live spreadsheet. The text following the "=" is parsed
+// in the sheet's locale (see WithLocale), and the value of the cell is
+// computed from the formula. Text read into the sheet that starts with "=" but
+// does not parse as a formula, such as a "=== Totals ===" banner, is kept as
+// text.
+func WithFormulas() SheetOption {
+	return func(opts *sheetOptions) {
+		opts.formulas = true
+	}
+}
+
+// WithEmptyFieldsAsText reads empty fields from sources such as CSV files as
+// empty text, rather than as blank cells.
+func WithEmptyFieldsAsText() SheetOption {
+	return func(opts *sheetOptions) {
+		opts.emptyAsText = true
+	}
+}
+
+// WithTypeInferrer converts text from sources such as CSV files into Values
+// using the given TypeInferrer, rather than StringToValue.
+func WithTypeInferrer(ti *TypeInferrer) SheetOption {
+	return func(opts *sheetOptions) {
+		opts.inferrer = ti
+	}
+}
+
+// WithLocale parses formulas, and text from sources such as CSV files, as
+// written in the given locale rather than the EnglishLocale. The locale of a
+// TypeInferrer passed to WithTypeInferrer takes precedence for text.
+func WithLocale(l *Locale) SheetOption {
+	return func(opts *sheetOptions) {
+		opts.locale = l
+	}
+}
+
+// WithLocation treats dates and times without an explicit offset, in formulas
+// and in text from sources such as CSV files, as wall-clock times in the given
+// time zone rather than in UTC. Formulas in a sheet that does not belong to a
+// Workbook are also computed in the time zone. The Location of a TypeInferrer
+// passed to WithTypeInferrer takes precedence for text.
+func WithLocation(tz *time.Location) SheetOption {
+	return func(opts *sheetOptions) {
+		opts.location = tz
+	}
+}
+
 // NewInMemorySheet creates a sheet that wraps a two-dimensional matrix.
-func NewInMemorySheet(values [][]Value) (Sheet, error) {
-	endCol := 0
-	for _, row := range values {
-		if len(row) >= endCol {
-			endCol = len(row) - 1
-		}
+func NewInMemorySheet(values [][]Value, opts ...SheetOption) (Sheet, error) {
+	s, err := newInMemorySheet(values, opts...)
+	if err != nil {
+		return nil, err
 	}
 
-	return &inMemorySheet{
-		dims: Dimensions{
-			EndRow: len(values) - 1,
-			EndCol: endCol,
-		},
+	return s, nil
+}
+
+func newInMemorySheet(values [][]Value, opts ...SheetOption) (*inMemorySheet, error) {
+	s := &inMemorySheet{
 		values: values,
-	}, nil
+	}
+
+	for _, opt := range opts {
+		opt(&s.opts)
+	}
+
+	if s.opts.formulas {
+		for row := range values {
+			for col, v := range values[row] {
+				f, ok, err := s.parseFormulaValue(v)
+				if err != nil || !ok {
+					continue
+				}
+
+				if s.formulas == nil {
+					s.formulas = make(map[Pos]*formulaCell)
+				}
+
+				s.formulas[Pos{Row: row, Col: col}] = &formulaCell{formula: f}
+			}
+		}
+	}
+
+	s.updateDims()
+	newAnonymousWorkbook(s)
+	return s, nil
 }
 
+// An inMemorySheet is a sheet backed by a matrix of values. Every in-memory
+// sheet belongs to a Workbook, which computes the values of its formulas;
+// sheets that have not been added to a workbook belong to an anonymous
+// workbook of their own.
 type inMemorySheet struct {
-	dims   Dimensions
-	values [][]Value
+	opts     sheetOptions
+	name     string
+	workbook *Workbook
+	dims     Dimensions
+	values   [][]Value
+	formulas map[Pos]*formulaCell
 }
 
 func (s *inMemorySheet) Dimensions() Dimensions {
 	return s.dims
 }
 
-func (s *inMemorySheet) Get(_ context.Context, pos Pos) (Value, error) {
+func (s *inMemorySheet) Get(ctx context.Context, pos Pos) (Value, error) {
 	if !s.fullRange().Contains(pos) {
 		return nil, InvalidPosError{pos}
 	}
 
+	if cell, ok := s.formulas[pos]; ok {
+		return s.workbook.value(ctx, s, pos, cell), nil
+	}
+
 	row := s.values[pos.Row]
-	if pos.Col >= len(row) {
-		return StringValue(""), nil
+	if pos.Col >= len(row) || row[pos.Col] == nil {
+		return BlankValue{}, nil
 	}
 
 	return row[pos.Col], nil
 }
 
 func (s *inMemorySheet) Range(_ context.Context, r Range) (ValueRange, error) {
-	fullRange := s.fullRange()
-	if !fullRange.Contains(r.StartPos()) {
-		return nil, InvalidPosError{r.StartPos()}
+	return newValueRange(s, r), nil
+}
+
+func (s *inMemorySheet) Formula(pos Pos) (Formula, bool) {
+	if cell, ok := s.formulas[pos]; ok {
+		return cell.formula, true
 	}
 
-	if !fullRange.Contains(r.EndPos()) {
-		return nil, InvalidPosError{r.EndPos()}
+	return nil, false
+}
+
+// timeZone returns the time zone of dates and times without an explicit
+// offset.
+func (opts sheetOptions) timeZone() *time.Location {
+	if opts.location == nil {
+		return time.UTC
 	}
 
-	return &valueRange{
-		sheet:  s,
-		bounds: r,
-		currentPos: Pos{
-			Row: r.StartRow,
-			Col: r.StartCol,
-		},
-	}, nil
+	return opts.location
+}
+
+// calcSettingsOf returns the settings used to compute and display the values
+// of a sheet, which are those of its Workbook if it belongs to one.
+func calcSettingsOf(s Sheet) CalcSettings {
+	if ims, ok := s.(*inMemorySheet); ok && ims.workbook != nil {
+		return ims.workbook.evaluator.CalcSettings
+	}
+
+	return CalcSettings{}
+}
+
+// parseFormulaValue parses a value as a formula if the sheet treats text
+// starting with "=" as formulas. Returns false if the value is not a formula.
+func (s *inMemorySheet) parseFormulaValue(v Value) (Formula, bool, error) {
+	text, ok := v.(StringValue)
+	if !ok || !s.opts.formulas || !strings.HasPrefix(string(text), "=") {
+		return nil, false, nil
+	}
+
+	f, err := parseFormulaIn(string(text[1:]), localeOrDefault(s.opts.locale), s.opts.timeZone())
+	if err != nil {
+		return nil, false, err
+	}
+
+	return f, true, nil
+}
+
+// updateDims recomputes the dimensions of the sheet from its values.
+func (s *inMemorySheet) updateDims() {
+	endCol := 0
+	for _, row := range s.values {
+		if len(row)-1 > endCol {
+			endCol = len(row) - 1
+		}
+	}
+
+	s.dims = Dimensions{
+		EndRow: len(s.values) - 1,
+		EndCol: endCol,
+	}
 }
 
 func (s *inMemorySheet) fullRange() Range {
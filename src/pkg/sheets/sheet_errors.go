@@ -3,6 +3,7 @@ package sheets
 import (
 	"errors"
 	"fmt"
+	"strings"
 )
 
 // Error is a sheet-specific error.
@@ -109,8 +110,99 @@ func NotAvailableErrorf(msg string, args ...any) *NotAvailableError {
 	}
 }
 
+// A RefError occurs when a formula refers to a cell that is not valid,
+// most commonly because the cell was deleted or pasted over, or because
+// the formula refers to a sheet that does not exist.
+type RefError struct {
+	Message string
+}
+
+func (e RefError) Error() string {
+	return e.Message
+}
+
+func (e RefError) TypeName() string {
+	return "#REF"
+}
+
+// RefErrorf creates a new RefError with a formatted message.
+func RefErrorf(msg string, args ...any) *RefError {
+	return &RefError{
+		Message: fmt.Sprintf(msg, args...),
+	}
+}
+
+// A CircularReferenceError occurs when a formula refers, directly or
+// indirectly, to its own cell. Cycle holds the cells that make up the cycle,
+// in the order they refer to each other.
+type CircularReferenceError struct {
+	Cycle []CellAddress
+}
+
+func (e CircularReferenceError) Error() string {
+	var sb strings.Builder
+	sb.WriteString("circular reference: ")
+	for _, addr := range e.Cycle {
+		sb.WriteString(addr.String())
+		sb.WriteString(" -> ")
+	}
+
+	if len(e.Cycle) != 0 {
+		sb.WriteString(e.Cycle[0].String())
+	}
+
+	return sb.String()
+}
+
+func (e CircularReferenceError) TypeName() string {
+	return "#CIRCULAR"
+}
+
+// errorCodes are the codes Excel displays for errors, keyed by the type name
+// of the corresponding sheet error.
+var errorCodes = map[string]string{
+	"#DIV/0": "#DIV/0!",
+	"#N/A":   "#N/A",
+	"#NAME":  "#NAME?",
+	"#REF":   "#REF!",
+	"#VALUE": "#VALUE!",
+}
+
+// ErrorCode returns the code Excel displays for an error, such as "#DIV/0!",
+// as stored in spreadsheet files. Errors that Excel does not have, such as
+// circular references, are #VALUE!.
+func ErrorCode(err error) string {
+	if sheetErr, ok := UnwrapError(err); ok {
+		if code, ok := errorCodes[sheetErr.TypeName()]; ok {
+			return code
+		}
+	}
+
+	return "#VALUE!"
+}
+
+// ErrorForCode returns an error for an Excel error code, such as "#N/A".
+// Codes without a corresponding sheet error, such as "#NUM!", are returned as
+// ValueErrors.
+func ErrorForCode(code string) Error {
+	switch code {
+	case "#DIV/0!":
+		return ErrDivideByZero
+	case "#N/A":
+		return NotAvailableErrorf("%s", code)
+	case "#NAME?":
+		return NameErrorf("%s", code)
+	case "#REF!":
+		return RefErrorf("%s", code)
+	default:
+		return ValueErrorf("%s", code)
+	}
+}
+
 var (
 	_ Error = &ValueError{}
+	_ Error = &CircularReferenceError{}
+	_ Error = &RefError{}
 	_ Error = &NotAvailableError{}
 	_ Error = &NameError{}
 	_ error = (Error)(nil)
@@ -0,0 +1,34 @@
+package sheets
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorCode(t *testing.T) {
+	for _, tt := range []struct {
+		err      error
+		expected string
+	}{
+		{ErrDivideByZero, "#DIV/0!"},
+		{NotAvailableErrorf("missing"), "#N/A"},
+		{NameErrorf("unknown"), "#NAME?"},
+		{RefErrorf("deleted"), "#REF!"},
+		{ValueErrorf("bad"), "#VALUE!"},
+		{fmt.Errorf("wrapped: %w", RefErrorf("deleted")), "#REF!"},
+		{&CircularReferenceError{}, "#VALUE!"},
+		{fmt.Errorf("not a sheet error"), "#VALUE!"},
+	} {
+		assert.Equal(t, tt.expected, ErrorCode(tt.err), tt.err.Error())
+	}
+}
+
+func TestErrorForCode(t *testing.T) {
+	for _, code := range []string{"#DIV/0!", "#N/A", "#NAME?", "#REF!", "#VALUE!"} {
+		assert.Equal(t, code, ErrorCode(ErrorForCode(code)), code)
+	}
+
+	assert.Equal(t, ValueErrorf("#NUM!"), ErrorForCode("#NUM!"))
+}
@@ -48,27 +48,43 @@ func TestInMemorySheet_Range(t *testing.T) {
 	assert.Equal(t, []string{"B1", "C1", "B2", "C2", "B3", "C3"}, positions)
 	assert.Equal(t, []Value{
 		StringValue("hat"), StringValue("mat"),
-		StringValue("banana"), StringValue(""),
+		StringValue("banana"), BlankValue{},
 		StringValue("bork"), StringValue("rork")},
 		values)
 	assert.Equal(t, 6, iter.Len())
 
-	// invalid end of range
-	_, err = s.Range(context.TODO(), mustParseRange(t, "A1:Z3"))
-	require.Error(t, err)
-	assert.Contains(t, "position 'Z3' outside of bounds", err.Error())
-
-	_, ok := err.(InvalidPosError)
-	require.True(t, ok)
-
-	// invalid start of range
-	_, err = s.Range(context.TODO(), mustParseRange(t, "A4:C4"))
-	require.Error(t, err)
-	assert.Contains(t, "position 'A4' outside of bounds", err.Error())
+}
 
-	_, ok = err.(InvalidPosError)
-	require.True(t, ok)
+func TestInMemorySheet_RangeResolution(t *testing.T) {
+	s, err := NewInMemorySheet(matrix)
+	require.NoError(t, err)
 
+	for _, tt := range []struct {
+		input     string
+		positions []string
+	}{
+		{"B:B", []string{"B1", "B2", "B3"}},
+		{"2:2", []string{"A2", "B2", "C2", "D2", "E2"}},
+		{"D2:F", []string{"D2", "E2", "D3", "E3"}},
+		{"A2:B99", []string{"A2", "B2", "A3", "B3"}},
+		{"D3:Z3", []string{"D3", "E3"}},
+		{"A4:C4", nil},
+		{"G:H", nil},
+	} {
+		t.Run(tt.input, func(t *testing.T) {
+			iter, err := s.Range(context.TODO(), mustParseRange(t, tt.input))
+			require.NoError(t, err)
+			assert.Equal(t, len(tt.positions), iter.Len())
+
+			var positions []string
+			for iter.Next(context.TODO()) {
+				positions = append(positions, iter.Pos().String())
+			}
+
+			require.NoError(t, iter.Err())
+			assert.Equal(t, tt.positions, positions)
+		})
+	}
 }
 
 func TestInMemorySheet_Get(t *testing.T) {
@@ -82,7 +98,7 @@ func TestInMemorySheet_Get(t *testing.T) {
 	// Doesn't explicitly exist
 	v, err = s.Get(context.TODO(), mustParsePos(t, "C2"))
 	require.NoError(t, err)
-	require.Equal(t, StringValue(""), v)
+	require.Equal(t, BlankValue{}, v)
 
 	v, err = s.Get(context.TODO(), mustParsePos(t, "E3"))
 	require.NoError(t, err)
@@ -97,8 +113,55 @@ func TestInMemorySheet_Get(t *testing.T) {
 	require.True(t, ok)
 }
 
+func TestNewInMemorySheet_Dimensions(t *testing.T) {
+	s, err := NewInMemorySheet([][]Value{
+		{StringValue("a"), StringValue("b"), StringValue("c")},
+		{StringValue("d"), StringValue("e")},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, Dimensions{EndRow: 1, EndCol: 2}, s.Dimensions())
+}
+
 func mustParsePos(t *testing.T, s string) Pos {
 	pos, err := ParsePos(s)
 	require.NoError(t, err)
 	return pos
 }
+
+func TestInMemorySheet_WithFormulas(t *testing.T) {
+	values := [][]Value{
+		{Float64Value(2), Float64Value(3), StringValue("=A1^B1")},
+		{StringValue("=no formula here"), StringValue("plain text")},
+	}
+
+	// Text that does not parse as a formula is kept as text
+	s, err := NewInMemorySheet(values, WithFormulas())
+	require.NoError(t, err)
+	assertCells(t, s, map[string]Value{
+		"C1": Float64Value(8),
+		"A2": StringValue("=no formula here"),
+	})
+
+	values[1][0] = StringValue("=C1-1")
+	s, err = NewInMemorySheet(values, WithFormulas())
+	require.NoError(t, err)
+	assertCells(t, s, map[string]Value{
+		"C1": Float64Value(8),
+		"A2": Float64Value(7),
+		"B2": StringValue("plain text"),
+	})
+
+	fs, ok := s.(FormulaSheet)
+	require.True(t, ok)
+
+	f, ok := fs.Formula(mustParsePos(t, "C1"))
+	require.True(t, ok)
+	assert.Equal(t, "A1 ^ B1", f.String())
+
+	// Without the option, the text is left as-is
+	s, err = NewInMemorySheet(values)
+	require.NoError(t, err)
+	assertCells(t, s, map[string]Value{
+		"C1": StringValue("=A1^B1"),
+	})
+}
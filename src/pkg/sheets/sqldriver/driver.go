@@ -0,0 +1,334 @@
+// Package sqldriver provides a database/sql driver that queries the sheets of
+// a DataSet as tables, so that sheets can be read with the same code as
+// databases. Queries are run in-process, and support SELECT statements with
+// joins, filtering, grouping and ordering.
+//
+// Each sheet is a table named after the sheet. The first row of the sheet
+// holds the names of its columns, and columns without a name are named by
+// their letters. Values are returned as Go types: numbers as float64, text as
+// string, booleans as bool, times as time.Time, errors as their error code,
+// such as "#DIV/0!", and blanks as NULL.
+//
+// A DataSet can be opened directly with OpenDB, or registered by name so that
+// it can be opened with sql.Open:
+//
+//	sqldriver.Register("budget", wb)
+//	db, err := sql.Open("sheets", "budget")
+package sqldriver
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mmihic/sheets/src/pkg/sheets"
+)
+
+// errNotSelect is returned for statements other than SELECT.
+var errNotSelect = errors.New("only SELECT statements are supported")
+
+// DriverName is the name the driver is registered under.
+const DriverName = "sheets"
+
+func init() {
+	sql.Register(DriverName, &sheetsDriver{})
+}
+
+// An Option is an option for querying a DataSet.
+type Option func(opts *options)
+
+type options struct {
+	noHeaders bool
+}
+
+// WithoutHeaders treats the first row of each sheet as data, naming every
+// column by its letters.
+func WithoutHeaders() Option {
+	return func(opts *options) {
+		opts.noHeaders = true
+	}
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]*Connector)
+)
+
+// Register registers a DataSet under a name, so that it can be opened with
+// sql.Open(DriverName, name). Registering a name again replaces the DataSet.
+func Register(name string, ds sheets.DataSet, opts ...Option) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	registry[name] = NewConnector(ds, opts...)
+}
+
+// Unregister removes a DataSet registered under a name.
+func Unregister(name string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	delete(registry, name)
+}
+
+// OpenDB opens a DataSet as a database.
+func OpenDB(ds sheets.DataSet, opts ...Option) *sql.DB {
+	return sql.OpenDB(NewConnector(ds, opts...))
+}
+
+// A Connector connects to a DataSet.
+type Connector struct {
+	ds   sheets.DataSet
+	opts options
+}
+
+// NewConnector returns a Connector for a DataSet, for use with sql.OpenDB.
+func NewConnector(ds sheets.DataSet, opts ...Option) *Connector {
+	c := &Connector{ds: ds}
+	for _, opt := range opts {
+		opt(&c.opts)
+	}
+
+	return c
+}
+
+// Connect returns a connection to the DataSet.
+func (c *Connector) Connect(context.Context) (driver.Conn, error) {
+	return &conn{connector: c}, nil
+}
+
+// Driver returns the driver.
+func (c *Connector) Driver() driver.Driver {
+	return &sheetsDriver{}
+}
+
+// The sheetsDriver opens DataSets registered by name.
+type sheetsDriver struct{}
+
+func (d *sheetsDriver) Open(name string) (driver.Conn, error) {
+	c, err := d.OpenConnector(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.Connect(context.Background())
+}
+
+func (d *sheetsDriver) OpenConnector(name string) (driver.Connector, error) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	c, ok := registry[name]
+	if !ok {
+		return nil, fmt.Errorf("no data set registered as '%s'", name)
+	}
+
+	return c, nil
+}
+
+// A conn is a connection to a DataSet.
+type conn struct {
+	connector *Connector
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *conn) PrepareContext(_ context.Context, query string) (driver.Stmt, error) {
+	parsed, err := parseStatement(query)
+	if err != nil {
+		return nil, err
+	}
+
+	return &stmt{conn: c, stmt: parsed}, nil
+}
+
+func (c *conn) Close() error {
+	return nil
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions are not supported")
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	parsed, err := parseStatement(query)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.query(ctx, parsed, args)
+}
+
+// CheckNamedValue accepts the argument types that can be compared with the
+// values of sheets.
+func (c *conn) CheckNamedValue(nv *driver.NamedValue) error {
+	if nv.Name != "" {
+		return fmt.Errorf("named parameter '%s' is not supported", nv.Name)
+	}
+
+	switch v := nv.Value.(type) {
+	case nil, float64, bool, string, time.Time:
+		return nil
+	case int64:
+		nv.Value = float64(v)
+		return nil
+	case []byte:
+		nv.Value = string(v)
+		return nil
+	}
+
+	v, err := driver.DefaultParameterConverter.ConvertValue(nv.Value)
+	if err != nil {
+		return err
+	}
+
+	nv.Value = v
+	return c.CheckNamedValue(nv)
+}
+
+func (c *conn) query(ctx context.Context, parsed *selectStmt, args []driver.NamedValue) (driver.Rows, error) {
+	if len(args) != parsed.params {
+		return nil, fmt.Errorf("expected %d parameters, got %d", parsed.params, len(args))
+	}
+
+	values := make([]any, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+
+	// Each table is loaded once per query, however often it is joined
+	loaded := make(map[string]*table)
+	tables := func(name string) (*table, error) {
+		key := strings.ToLower(name)
+		if t, ok := loaded[key]; ok {
+			return t, nil
+		}
+
+		s := c.lookup(name)
+		if s == nil {
+			return nil, fmt.Errorf("no such table '%s'", name)
+		}
+
+		t, err := loadTable(ctx, s, !c.connector.opts.noHeaders)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read table '%s': %w", name, err)
+		}
+
+		loaded[key] = t
+		return t, nil
+	}
+
+	res, err := execute(parsed, tables, values)
+	if err != nil {
+		return nil, err
+	}
+
+	return &rows{result: res}, nil
+}
+
+// lookup returns the sheet with a name, matching the names of data sets that
+// list their sheets without regard to case.
+func (c *conn) lookup(name string) sheets.Sheet {
+	ds := c.connector.ds
+	if s := ds.Sheet(name); s != nil {
+		return s
+	}
+
+	lister, ok := ds.(interface{ SheetNames() []string })
+	if !ok {
+		return nil
+	}
+
+	for _, sheetName := range lister.SheetNames() {
+		if strings.EqualFold(sheetName, name) {
+			return ds.Sheet(sheetName)
+		}
+	}
+
+	return nil
+}
+
+// parseStatement parses a query, rejecting statements other than SELECT.
+func parseStatement(query string) (*selectStmt, error) {
+	tokens, err := lex(query)
+	if err != nil {
+		return nil, err
+	}
+
+	if first := tokens[0]; first.kind == tokenKeyword && first.text != "SELECT" || first.kind == tokenIdent {
+		return nil, errNotSelect
+	}
+
+	return parse(query)
+}
+
+// A stmt is a prepared query.
+type stmt struct {
+	conn *conn
+	stmt *selectStmt
+}
+
+func (s *stmt) Close() error {
+	return nil
+}
+
+func (s *stmt) NumInput() int {
+	return s.stmt.params
+}
+
+func (s *stmt) Exec([]driver.Value) (driver.Result, error) {
+	return nil, errNotSelect
+}
+
+func (s *stmt) ExecContext(context.Context, []driver.NamedValue) (driver.Result, error) {
+	return nil, errNotSelect
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	named := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+
+	return s.QueryContext(context.Background(), named)
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.conn.query(ctx, s.stmt, args)
+}
+
+// The rows of a query's result.
+type rows struct {
+	result *result
+	next   int
+}
+
+func (r *rows) Columns() []string {
+	return r.result.columns
+}
+
+func (r *rows) Close() error {
+	r.next = len(r.result.rows)
+	return nil
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	if r.next >= len(r.result.rows) {
+		return io.EOF
+	}
+
+	for i, v := range r.result.rows[r.next] {
+		dest[i] = v
+	}
+
+	r.next++
+	return nil
+}
@@ -0,0 +1,175 @@
+package sqldriver
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mmihic/sheets/src/pkg/sheets"
+)
+
+func newTestWorkbook(t *testing.T) *sheets.Workbook {
+	wb := sheets.NewWorkbook()
+
+	products, err := sheets.NewMutableSheet([][]sheets.Value{
+		{sheets.StringValue("Name"), sheets.StringValue("Price"), sheets.StringValue("Added"), sheets.BlankValue{}},
+		{sheets.StringValue("apple"), sheets.Float64Value(0.5),
+			sheets.TimeValue(time.Date(2024, time.May, 1, 0, 0, 0, 0, time.UTC)), sheets.BoolValue(true)},
+		{sheets.StringValue("pear"), sheets.Float64Value(0.75), sheets.BlankValue{}, sheets.BoolValue(false)},
+		{sheets.StringValue("plum"), sheets.StringValue("=B2/0"), sheets.BlankValue{}, sheets.BlankValue{}},
+	}, sheets.WithFormulas())
+	require.NoError(t, err)
+	require.NoError(t, wb.AddSheet("Products", products))
+
+	sales, err := sheets.NewMutableSheet([][]sheets.Value{
+		{sheets.StringValue("Product"), sheets.StringValue("Qty")},
+		{sheets.StringValue("apple"), sheets.Float64Value(3)},
+		{sheets.StringValue("pear"), sheets.Float64Value(2)},
+		{sheets.StringValue("apple"), sheets.Float64Value(4)},
+	})
+	require.NoError(t, err)
+	require.NoError(t, wb.AddSheet("Sales Q1", sales))
+
+	return wb
+}
+
+func TestOpenDB(t *testing.T) {
+	db := OpenDB(newTestWorkbook(t))
+	t.Cleanup(func() { _ = db.Close() })
+
+	rows, err := db.Query("SELECT * FROM products")
+	require.NoError(t, err)
+
+	columns, err := rows.Columns()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Name", "Price", "Added", "D"}, columns)
+
+	var got [][]any
+	for rows.Next() {
+		var name, price, added, flag any
+		require.NoError(t, rows.Scan(&name, &price, &added, &flag))
+		got = append(got, []any{name, price, added, flag})
+	}
+
+	require.NoError(t, rows.Err())
+	assert.Equal(t, [][]any{
+		{"apple", 0.5, time.Date(2024, time.May, 1, 0, 0, 0, 0, time.UTC), true},
+		{"pear", 0.75, nil, false},
+		{"plum", "#DIV/0!", nil, nil},
+	}, got)
+}
+
+func TestOpenDB_JoinGroupOrder(t *testing.T) {
+	db := OpenDB(newTestWorkbook(t))
+	t.Cleanup(func() { _ = db.Close() })
+
+	rows, err := db.Query(`
+		SELECT p.Name, SUM(s.Qty) AS qty, SUM(s.Qty * p.Price) AS total
+		FROM "Sales Q1" s JOIN Products p ON p.Name = s.Product
+		WHERE s.Qty >= ?
+		GROUP BY p.Name
+		ORDER BY total DESC`, 2)
+	require.NoError(t, err)
+
+	type line struct {
+		name  string
+		qty   float64
+		total float64
+	}
+
+	var got []line
+	for rows.Next() {
+		var l line
+		require.NoError(t, rows.Scan(&l.name, &l.qty, &l.total))
+		got = append(got, l)
+	}
+
+	require.NoError(t, rows.Err())
+	assert.Equal(t, []line{{"apple", 7, 3.5}, {"pear", 2, 1.5}}, got)
+
+	var count int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM `sales q1` WHERE product = ?", "apple").Scan(&count))
+	assert.Equal(t, 2, count)
+}
+
+func TestOpenDB_Prepared(t *testing.T) {
+	db := OpenDB(newTestWorkbook(t))
+	t.Cleanup(func() { _ = db.Close() })
+
+	stmt, err := db.Prepare("SELECT Name FROM Products WHERE Price > ? ORDER BY Name LIMIT 1")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = stmt.Close() })
+
+	var name string
+	require.NoError(t, stmt.QueryRow(0.6).Scan(&name))
+	assert.Equal(t, "pear", name)
+
+	require.NoError(t, stmt.QueryRow(0).Scan(&name))
+	assert.Equal(t, "apple", name)
+
+	assert.ErrorIs(t, stmt.QueryRow(1).Scan(&name), sql.ErrNoRows)
+
+	_, err = stmt.Query()
+	assert.EqualError(t, err, "sql: expected 1 arguments, got 0")
+}
+
+func TestOpenDB_WithoutHeaders(t *testing.T) {
+	db := OpenDB(newTestWorkbook(t), WithoutHeaders())
+	t.Cleanup(func() { _ = db.Close() })
+
+	var names []string
+	rows, err := db.Query("SELECT A FROM Products WHERE B IS NOT NULL")
+	require.NoError(t, err)
+	for rows.Next() {
+		var name string
+		require.NoError(t, rows.Scan(&name))
+		names = append(names, name)
+	}
+
+	require.NoError(t, rows.Err())
+	assert.Equal(t, []string{"Name", "apple", "pear", "plum"}, names)
+}
+
+func TestOpenDB_Errors(t *testing.T) {
+	db := OpenDB(newTestWorkbook(t))
+	t.Cleanup(func() { _ = db.Close() })
+
+	_, err := db.Query("SELECT * FROM Missing")
+	assert.EqualError(t, err, "no such table 'Missing'")
+
+	_, err = db.Exec("DELETE FROM Products")
+	assert.EqualError(t, err, "only SELECT statements are supported")
+
+	_, err = db.Exec("SELECT * FROM Products")
+	assert.EqualError(t, err, "only SELECT statements are supported")
+
+	_, err = db.Begin()
+	assert.EqualError(t, err, "transactions are not supported")
+
+	_, err = db.Query("SELECT * FROM Products WHERE Name = :name", sql.Named("name", "apple"))
+	assert.Error(t, err)
+
+	_, err = db.Query("SELECT * FROM Products WHERE Name = ?", sql.Named("name", "apple"))
+	assert.ErrorContains(t, err, "named parameter 'name' is not supported")
+}
+
+func TestRegister(t *testing.T) {
+	Register("driver-test", newTestWorkbook(t))
+	t.Cleanup(func() { Unregister("driver-test") })
+
+	db, err := sql.Open(DriverName, "driver-test")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	var total float64
+	require.NoError(t, db.QueryRowContext(context.Background(),
+		`SELECT SUM(Qty) FROM "Sales Q1"`).Scan(&total))
+	assert.Equal(t, 9.0, total)
+
+	_, err = sql.Open(DriverName, "missing")
+	assert.EqualError(t, err, "no data set registered as 'missing'")
+}
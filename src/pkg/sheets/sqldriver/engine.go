@@ -0,0 +1,1177 @@
+package sqldriver
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mmihic/sheets/src/pkg/sheets"
+)
+
+// A table is the contents of a sheet, converted into Go values.
+type table struct {
+	columns []string
+	rows    [][]any
+}
+
+// loadTable reads a sheet as a table, taking the column names from the first
+// row if headers is set, and otherwise naming columns by their letters.
+func loadTable(ctx context.Context, s sheets.Sheet, headers bool) (*table, error) {
+	dims := s.Dimensions()
+	t := &table{columns: make([]string, dims.EndCol+1)}
+	for col := range t.columns {
+		t.columns[col] = columnLetters(col)
+	}
+
+	if dims.EndRow < 0 || dims.EndCol < 0 {
+		return t, nil
+	}
+
+	vr, err := s.Range(ctx, sheets.Range{EndRow: dims.EndRow, EndCol: dims.EndCol})
+	if err != nil {
+		return nil, err
+	}
+
+	var row []any
+	for vr.Next(ctx) {
+		pos := vr.Pos()
+		if headers && pos.Row == 0 {
+			if name := strings.TrimSpace(vr.Value().String()); name != "" {
+				if _, blank := vr.Value().(sheets.BlankValue); !blank {
+					t.columns[pos.Col] = name
+				}
+			}
+
+			continue
+		}
+
+		row = append(row, goValue(vr.Value()))
+		if pos.Col == dims.EndCol {
+			t.rows = append(t.rows, row)
+			row = nil
+		}
+	}
+
+	if err := vr.Err(); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// goValue converts a Value into the Go value returned for it.
+func goValue(v sheets.Value) any {
+	switch tv := v.(type) {
+	case sheets.BlankValue:
+		return nil
+	case sheets.Float64Value:
+		return float64(tv)
+	case sheets.FormattedNumber:
+		return tv.Number
+	case sheets.BoolValue:
+		return bool(tv)
+	case sheets.TimeValue:
+		return time.Time(tv)
+	case sheets.StringValue:
+		return string(tv)
+	case sheets.ErrorValue:
+		return sheets.ErrorCode(tv.Err)
+	default:
+		return v.String()
+	}
+}
+
+func columnLetters(col int) string {
+	return strings.TrimRight(sheets.Pos{Col: col}.String(), "0123456789")
+}
+
+// A column is a column of the rows being queried.
+type column struct {
+	table string
+	name  string
+}
+
+// A scope holds what an expression is evaluated against: the columns of the
+// joined tables, the current row, the rows of the current group for
+// aggregates, and the query parameters.
+type scope struct {
+	columns []column
+	row     []any
+	grouped bool
+	group   [][]any
+	args    []any
+}
+
+func (sc *scope) withRow(row []any) *scope {
+	next := *sc
+	next.row = row
+	return &next
+}
+
+// resolve returns the index of a referenced column.
+func (sc *scope) resolve(ref *columnRef) (int, error) {
+	found := -1
+	for i, col := range sc.columns {
+		if !strings.EqualFold(col.name, ref.name) || (ref.table != "" && !strings.EqualFold(col.table, ref.table)) {
+			continue
+		}
+
+		if found >= 0 && !strings.EqualFold(sc.columns[found].table, col.table) {
+			return 0, fmt.Errorf("ambiguous column '%s'", ref.name)
+		}
+
+		if found < 0 {
+			found = i
+		}
+	}
+
+	if found < 0 {
+		if ref.table != "" {
+			return 0, fmt.Errorf("no such column '%s.%s'", ref.table, ref.name)
+		}
+
+		return 0, fmt.Errorf("no such column '%s'", ref.name)
+	}
+
+	return found, nil
+}
+
+// A result is the result of a query.
+type result struct {
+	columns []string
+	rows    [][]any
+}
+
+// execute runs a query against the sheets of a data set.
+func execute(stmt *selectStmt, tables func(name string) (*table, error), args []any) (*result, error) {
+	sc := &scope{args: args}
+	rows, err := sc.from(stmt, tables)
+	if err != nil {
+		return nil, err
+	}
+
+	if stmt.where != nil {
+		var filtered [][]any
+		for _, row := range rows {
+			ok, err := sc.withRow(row).test(stmt.where)
+			if err != nil {
+				return nil, err
+			}
+
+			if ok {
+				filtered = append(filtered, row)
+			}
+		}
+
+		rows = filtered
+	}
+
+	res := &result{}
+	items, err := sc.expandItems(stmt.items)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := sc.checkColumns(stmt, items); err != nil {
+		return nil, err
+	}
+
+	for _, item := range items {
+		res.columns = append(res.columns, item.name)
+	}
+
+	// Each output row keeps the scope it was computed in, for ordering
+	var (
+		scopes []*scope
+		groups = stmt.groupBy != nil || hasAggregate(stmt)
+	)
+
+	if groups {
+		if err := sc.checkGrouped(stmt, items); err != nil {
+			return nil, err
+		}
+
+		scopes, err = sc.groupRows(stmt, rows)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		for _, row := range rows {
+			scopes = append(scopes, sc.withRow(row))
+		}
+	}
+
+	for _, rowScope := range scopes {
+		out := make([]any, len(items))
+		for i, item := range items {
+			if out[i], err = rowScope.eval(item.expr); err != nil {
+				return nil, err
+			}
+		}
+
+		res.rows = append(res.rows, out)
+	}
+
+	if err := sc.order(stmt, items, res, scopes); err != nil {
+		return nil, err
+	}
+
+	if stmt.distinct {
+		res.rows = distinctRows(res.rows)
+	}
+
+	return res, sc.limit(stmt, res)
+}
+
+// from returns the rows of the joined tables.
+func (sc *scope) from(stmt *selectStmt, tables func(name string) (*table, error)) ([][]any, error) {
+	first, err := tables(stmt.from.name)
+	if err != nil {
+		return nil, err
+	}
+
+	sc.columns = tableColumns(stmt.from.alias, first)
+	rows := first.rows
+	for _, j := range stmt.joins {
+		t, err := tables(j.table.name)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, existing := range sc.columns {
+			if strings.EqualFold(existing.table, j.table.alias) {
+				return nil, fmt.Errorf("duplicate table alias '%s'", j.table.alias)
+			}
+		}
+
+		sc.columns = append(sc.columns, tableColumns(j.table.alias, t)...)
+		if err := sc.checkExpr(j.on); err != nil {
+			return nil, err
+		}
+
+		var joined [][]any
+		for _, l := range rows {
+			matched := false
+			for _, r := range t.rows {
+				row := append(append(make([]any, 0, len(l)+len(r)), l...), r...)
+				if j.on != nil {
+					ok, err := sc.withRow(row).test(j.on)
+					if err != nil {
+						return nil, err
+					}
+
+					if !ok {
+						continue
+					}
+				}
+
+				joined = append(joined, row)
+				matched = true
+			}
+
+			if j.left && !matched {
+				joined = append(joined, append(append(make([]any, 0, len(l)+len(t.columns)), l...),
+					make([]any, len(t.columns))...))
+			}
+		}
+
+		rows = joined
+	}
+
+	return rows, nil
+}
+
+func tableColumns(alias string, t *table) []column {
+	columns := make([]column, len(t.columns))
+	for i, name := range t.columns {
+		columns[i] = column{table: alias, name: name}
+	}
+
+	return columns
+}
+
+// checkColumns checks that the columns referred to by a query exist, since
+// expressions are otherwise only evaluated when there are rows. ORDER BY
+// expressions are not checked, since they may refer to result columns.
+func (sc *scope) checkColumns(stmt *selectStmt, items []selectItem) error {
+	exprs := []expr{stmt.where, stmt.having}
+	exprs = append(exprs, stmt.groupBy...)
+	for _, item := range items {
+		exprs = append(exprs, item.expr)
+	}
+
+	for _, e := range exprs {
+		if err := sc.checkExpr(e); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (sc *scope) checkExpr(e expr) error {
+	if ref, ok := e.(*columnRef); ok {
+		_, err := sc.resolve(ref)
+		return err
+	}
+
+	for _, child := range children(e) {
+		if err := sc.checkExpr(child); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkGrouped checks that the select list, HAVING and ORDER BY clauses of a
+// grouped query only refer to columns within aggregates or GROUP BY
+// expressions, since other columns have no single value for a group.
+func (sc *scope) checkGrouped(stmt *selectStmt, items []selectItem) error {
+	exprs := []expr{stmt.having}
+	for _, item := range items {
+		exprs = append(exprs, item.expr)
+	}
+
+	for _, item := range stmt.orderBy {
+		if !refersToResult(item.expr, items) {
+			exprs = append(exprs, item.expr)
+		}
+	}
+
+	for _, e := range exprs {
+		if err := sc.checkGroupedExpr(stmt, e); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (sc *scope) checkGroupedExpr(stmt *selectStmt, e expr) error {
+	for _, g := range stmt.groupBy {
+		if sc.sameExpr(e, g) {
+			return nil
+		}
+	}
+
+	switch et := e.(type) {
+	case *columnRef:
+		return fmt.Errorf("column '%s' must appear in GROUP BY or be used in an aggregate", et.name)
+	case *callExpr:
+		if aggregates[et.name] {
+			return nil
+		}
+	}
+
+	for _, child := range children(e) {
+		if err := sc.checkGroupedExpr(stmt, child); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sameExpr returns true if two expressions compute the same value, treating
+// references to the same column as equal whether or not they name its table.
+func (sc *scope) sameExpr(a, b expr) bool {
+	aRef, aOK := a.(*columnRef)
+	bRef, bOK := b.(*columnRef)
+	if aOK && bOK {
+		i, aErr := sc.resolve(aRef)
+		j, bErr := sc.resolve(bRef)
+		return aErr == nil && bErr == nil && i == j
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+// refersToResult returns true if an ORDER BY expression refers to a column of
+// the result by its position or name.
+func refersToResult(e expr, items []selectItem) bool {
+	switch et := e.(type) {
+	case *literal:
+		_, ok := et.value.(float64)
+		return ok
+	case *columnRef:
+		if et.table != "" {
+			return false
+		}
+
+		for _, item := range items {
+			if strings.EqualFold(item.name, et.name) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// children returns the expressions an expression is computed from.
+func children(e expr) []expr {
+	switch et := e.(type) {
+	case *unaryExpr:
+		return []expr{et.x}
+	case *binaryExpr:
+		return []expr{et.l, et.r}
+	case *isNullExpr:
+		return []expr{et.x}
+	case *inExpr:
+		return append([]expr{et.x}, et.list...)
+	case *betweenExpr:
+		return []expr{et.x, et.lo, et.hi}
+	case *callExpr:
+		return et.args
+	default:
+		return nil
+	}
+}
+
+// expandItems replaces stars in the select list with the columns they
+// select.
+func (sc *scope) expandItems(items []selectItem) ([]selectItem, error) {
+	var expanded []selectItem
+	for _, item := range items {
+		if !item.star {
+			expanded = append(expanded, item)
+			continue
+		}
+
+		found := false
+		for _, col := range sc.columns {
+			if item.table == "" || strings.EqualFold(item.table, col.table) {
+				expanded = append(expanded, selectItem{
+					expr: &columnRef{table: col.table, name: col.name},
+					name: col.name,
+				})
+				found = true
+			}
+		}
+
+		if !found {
+			return nil, fmt.Errorf("no such table '%s'", item.table)
+		}
+	}
+
+	return expanded, nil
+}
+
+// groupRows groups rows by the GROUP BY expressions, returning a scope for each
+// group that passes the HAVING condition. A query with aggregates but no
+// GROUP BY has a single group holding every row.
+func (sc *scope) groupRows(stmt *selectStmt, rows [][]any) ([]*scope, error) {
+	var (
+		keys   []string
+		groups = make(map[string][][]any)
+	)
+
+	for _, row := range rows {
+		var key strings.Builder
+		for _, e := range stmt.groupBy {
+			v, err := sc.withRow(row).eval(e)
+			if err != nil {
+				return nil, err
+			}
+
+			fmt.Fprintf(&key, "%T:%v\x00", v, v)
+		}
+
+		k := key.String()
+		if _, ok := groups[k]; !ok {
+			keys = append(keys, k)
+		}
+
+		groups[k] = append(groups[k], row)
+	}
+
+	if stmt.groupBy == nil && len(keys) == 0 {
+		keys = append(keys, "")
+	}
+
+	var scopes []*scope
+	for _, k := range keys {
+		group := groups[k]
+		groupScope := sc.withRow(make([]any, len(sc.columns)))
+		if len(group) != 0 {
+			groupScope.row = group[0]
+		}
+
+		groupScope.grouped = true
+		groupScope.group = group
+		if stmt.having != nil {
+			ok, err := groupScope.test(stmt.having)
+			if err != nil {
+				return nil, err
+			}
+
+			if !ok {
+				continue
+			}
+		}
+
+		scopes = append(scopes, groupScope)
+	}
+
+	return scopes, nil
+}
+
+// order sorts the result by the ORDER BY expressions, which may refer to the
+// names or positions of the result's columns.
+func (sc *scope) order(stmt *selectStmt, items []selectItem, res *result, scopes []*scope) error {
+	if len(stmt.orderBy) == 0 {
+		return nil
+	}
+
+	keys := make([][]any, len(res.rows))
+	for i := range res.rows {
+		keys[i] = make([]any, len(stmt.orderBy))
+		for j, item := range stmt.orderBy {
+			v, err := sc.orderKey(item.expr, items, res.rows[i], scopes[i])
+			if err != nil {
+				return err
+			}
+
+			keys[i][j] = v
+		}
+	}
+
+	index := make([]int, len(res.rows))
+	for i := range index {
+		index[i] = i
+	}
+
+	sort.SliceStable(index, func(a, b int) bool {
+		for j, item := range stmt.orderBy {
+			c := compareForSort(keys[index[a]][j], keys[index[b]][j])
+			if c == 0 {
+				continue
+			}
+
+			if item.desc {
+				return c > 0
+			}
+
+			return c < 0
+		}
+
+		return false
+	})
+
+	sorted := make([][]any, len(res.rows))
+	for i, j := range index {
+		sorted[i] = res.rows[j]
+	}
+
+	res.rows = sorted
+	return nil
+}
+
+func (sc *scope) orderKey(e expr, items []selectItem, row []any, rowScope *scope) (any, error) {
+	switch et := e.(type) {
+	case *literal:
+		if n, ok := et.value.(float64); ok {
+			if n < 1 || int(n) > len(row) || n != math.Trunc(n) {
+				return nil, fmt.Errorf("invalid column position %s in ORDER BY", strconv.FormatFloat(n, 'f', -1, 64))
+			}
+
+			return row[int(n)-1], nil
+		}
+	case *columnRef:
+		if et.table == "" {
+			for i, item := range items {
+				if strings.EqualFold(item.name, et.name) {
+					return row[i], nil
+				}
+			}
+		}
+	}
+
+	return rowScope.eval(e)
+}
+
+func (sc *scope) limit(stmt *selectStmt, res *result) error {
+	count := func(e expr, what string) (int, error) {
+		v, err := sc.eval(e)
+		if err != nil {
+			return 0, err
+		}
+
+		n, ok := toNumber(v)
+		if !ok || n < 0 || n != math.Trunc(n) {
+			return 0, fmt.Errorf("invalid %s '%v'", what, v)
+		}
+
+		return int(n), nil
+	}
+
+	if stmt.offset != nil {
+		n, err := count(stmt.offset, "OFFSET")
+		if err != nil {
+			return err
+		}
+
+		if n > len(res.rows) {
+			n = len(res.rows)
+		}
+
+		res.rows = res.rows[n:]
+	}
+
+	if stmt.limit != nil {
+		n, err := count(stmt.limit, "LIMIT")
+		if err != nil {
+			return err
+		}
+
+		if n < len(res.rows) {
+			res.rows = res.rows[:n]
+		}
+	}
+
+	return nil
+}
+
+func distinctRows(rows [][]any) [][]any {
+	seen := make(map[string]bool, len(rows))
+	var distinct [][]any
+	for _, row := range rows {
+		key := fmt.Sprintf("%#v", row)
+		if !seen[key] {
+			seen[key] = true
+			distinct = append(distinct, row)
+		}
+	}
+
+	return distinct
+}
+
+// hasAggregate returns true if the select list, HAVING or ORDER BY clauses
+// call aggregate functions.
+func hasAggregate(stmt *selectStmt) bool {
+	for _, item := range stmt.items {
+		if containsAggregate(item.expr) {
+			return true
+		}
+	}
+
+	for _, item := range stmt.orderBy {
+		if containsAggregate(item.expr) {
+			return true
+		}
+	}
+
+	return stmt.having != nil
+}
+
+func containsAggregate(e expr) bool {
+	switch et := e.(type) {
+	case *callExpr:
+		if aggregates[et.name] {
+			return true
+		}
+
+		for _, arg := range et.args {
+			if containsAggregate(arg) {
+				return true
+			}
+		}
+	case *unaryExpr:
+		return containsAggregate(et.x)
+	case *binaryExpr:
+		return containsAggregate(et.l) || containsAggregate(et.r)
+	case *isNullExpr:
+		return containsAggregate(et.x)
+	case *inExpr:
+		if containsAggregate(et.x) {
+			return true
+		}
+
+		for _, item := range et.list {
+			if containsAggregate(item) {
+				return true
+			}
+		}
+	case *betweenExpr:
+		return containsAggregate(et.x) || containsAggregate(et.lo) || containsAggregate(et.hi)
+	}
+
+	return false
+}
+
+// test evaluates a condition, treating NULL as false.
+func (sc *scope) test(e expr) (bool, error) {
+	v, err := sc.eval(e)
+	if err != nil {
+		return false, err
+	}
+
+	b, _ := v.(bool)
+	return b, nil
+}
+
+// eval evaluates an expression. NULL is nil, and propagates through
+// operators and comparisons as in SQL.
+func (sc *scope) eval(e expr) (any, error) {
+	switch et := e.(type) {
+	case *literal:
+		return et.value, nil
+	case *param:
+		if et.index >= len(sc.args) {
+			return nil, fmt.Errorf("missing parameter %d", et.index+1)
+		}
+
+		return sc.args[et.index], nil
+	case *columnRef:
+		i, err := sc.resolve(et)
+		if err != nil {
+			return nil, err
+		}
+
+		return sc.row[i], nil
+	case *unaryExpr:
+		x, err := sc.eval(et.x)
+		if err != nil || x == nil {
+			return nil, err
+		}
+
+		if et.op == "NOT" {
+			b, ok := x.(bool)
+			if !ok {
+				return nil, fmt.Errorf("NOT requires a boolean, not '%v'", x)
+			}
+
+			return !b, nil
+		}
+
+		n, ok := toNumber(x)
+		if !ok {
+			return nil, fmt.Errorf("cannot negate '%v'", x)
+		}
+
+		return -n, nil
+	case *binaryExpr:
+		return sc.evalBinary(et)
+	case *isNullExpr:
+		x, err := sc.eval(et.x)
+		if err != nil {
+			return nil, err
+		}
+
+		return (x == nil) != et.not, nil
+	case *inExpr:
+		x, err := sc.eval(et.x)
+		if err != nil || x == nil {
+			return nil, err
+		}
+
+		found := false
+		for _, item := range et.list {
+			v, err := sc.eval(item)
+			if err != nil {
+				return nil, err
+			}
+
+			if v != nil && compare(x, v) == 0 {
+				found = true
+				break
+			}
+		}
+
+		return found != et.not, nil
+	case *betweenExpr:
+		x, err := sc.eval(et.x)
+		if err != nil {
+			return nil, err
+		}
+
+		lo, err := sc.eval(et.lo)
+		if err != nil {
+			return nil, err
+		}
+
+		hi, err := sc.eval(et.hi)
+		if err != nil {
+			return nil, err
+		}
+
+		if x == nil || lo == nil || hi == nil {
+			return nil, nil
+		}
+
+		return (compare(x, lo) >= 0 && compare(x, hi) <= 0) != et.not, nil
+	case *callExpr:
+		if aggregates[et.name] {
+			return sc.evalAggregate(et)
+		}
+
+		return sc.evalCall(et)
+	default:
+		return nil, fmt.Errorf("unsupported expression %T", e)
+	}
+}
+
+func (sc *scope) evalBinary(e *binaryExpr) (any, error) {
+	l, err := sc.eval(e.l)
+	if err != nil {
+		return nil, err
+	}
+
+	// AND and OR follow three-valued logic, and stop once the result is known
+	switch e.op {
+	case "AND", "OR":
+		lb, lok := l.(bool)
+		if l != nil && !lok {
+			return nil, fmt.Errorf("%s requires booleans, not '%v'", e.op, l)
+		}
+
+		if lok && lb == (e.op == "OR") {
+			return lb, nil
+		}
+
+		r, err := sc.eval(e.r)
+		if err != nil {
+			return nil, err
+		}
+
+		rb, rok := r.(bool)
+		if r != nil && !rok {
+			return nil, fmt.Errorf("%s requires booleans, not '%v'", e.op, r)
+		}
+
+		switch {
+		case rok && rb == (e.op == "OR"):
+			return rb, nil
+		case l == nil || r == nil:
+			return nil, nil
+		default:
+			return rb, nil
+		}
+	}
+
+	r, err := sc.eval(e.r)
+	if err != nil {
+		return nil, err
+	}
+
+	if l == nil || r == nil {
+		return nil, nil
+	}
+
+	switch e.op {
+	case "=":
+		return compare(l, r) == 0, nil
+	case "<>":
+		return compare(l, r) != 0, nil
+	case "<":
+		return compare(l, r) < 0, nil
+	case "<=":
+		return compare(l, r) <= 0, nil
+	case ">":
+		return compare(l, r) > 0, nil
+	case ">=":
+		return compare(l, r) >= 0, nil
+	case "||":
+		return toText(l) + toText(r), nil
+	case "LIKE":
+		return like(toText(l), toText(r)), nil
+	}
+
+	ln, lok := toNumber(l)
+	rn, rok := toNumber(r)
+	if !lok || !rok {
+		return nil, fmt.Errorf("cannot apply '%s' to '%v' and '%v'", e.op, l, r)
+	}
+
+	switch e.op {
+	case "+":
+		return ln + rn, nil
+	case "-":
+		return ln - rn, nil
+	case "*":
+		return ln * rn, nil
+	case "/":
+		if rn == 0 {
+			return nil, nil
+		}
+
+		return ln / rn, nil
+	case "%":
+		if rn == 0 {
+			return nil, nil
+		}
+
+		return math.Mod(ln, rn), nil
+	default:
+		return nil, fmt.Errorf("unsupported operator '%s'", e.op)
+	}
+}
+
+// aggregates are the aggregate functions.
+var aggregates = map[string]bool{
+	"AVG":   true,
+	"COUNT": true,
+	"MAX":   true,
+	"MIN":   true,
+	"SUM":   true,
+}
+
+func (sc *scope) evalAggregate(e *callExpr) (any, error) {
+	if !sc.grouped {
+		return nil, fmt.Errorf("aggregate %s used outside of a group", e.name)
+	}
+
+	if e.star {
+		if e.name != "COUNT" {
+			return nil, fmt.Errorf("%s(*) is not supported", e.name)
+		}
+
+		return int64(len(sc.group)), nil
+	}
+
+	if len(e.args) != 1 {
+		return nil, fmt.Errorf("%s requires a single argument", e.name)
+	}
+
+	var (
+		values []any
+		seen   = make(map[string]bool)
+	)
+
+	for _, row := range sc.group {
+		v, err := sc.withRow(row).withoutGroup().eval(e.args[0])
+		if err != nil {
+			return nil, err
+		}
+
+		if v == nil {
+			continue
+		}
+
+		if e.distinct {
+			key := fmt.Sprintf("%T:%v", v, v)
+			if seen[key] {
+				continue
+			}
+
+			seen[key] = true
+		}
+
+		values = append(values, v)
+	}
+
+	switch e.name {
+	case "COUNT":
+		return int64(len(values)), nil
+	case "MIN", "MAX":
+		var best any
+		for _, v := range values {
+			if best == nil || (e.name == "MIN" && compare(v, best) < 0) || (e.name == "MAX" && compare(v, best) > 0) {
+				best = v
+			}
+		}
+
+		return best, nil
+	default:
+		if len(values) == 0 {
+			return nil, nil
+		}
+
+		var sum float64
+		for _, v := range values {
+			n, ok := toNumber(v)
+			if !ok {
+				return nil, fmt.Errorf("%s requires numbers, not '%v'", e.name, v)
+			}
+
+			sum += n
+		}
+
+		if e.name == "AVG" {
+			return sum / float64(len(values)), nil
+		}
+
+		return sum, nil
+	}
+}
+
+// withoutGroup returns the scope of a single row within a group, in which
+// aggregates cannot be nested.
+func (sc *scope) withoutGroup() *scope {
+	next := *sc
+	next.grouped = false
+	next.group = nil
+	return &next
+}
+
+func (sc *scope) evalCall(e *callExpr) (any, error) {
+	args := make([]any, len(e.args))
+	for i, arg := range e.args {
+		v, err := sc.eval(arg)
+		if err != nil {
+			return nil, err
+		}
+
+		args[i] = v
+	}
+
+	if e.name == "COALESCE" || e.name == "IFNULL" {
+		for _, v := range args {
+			if v != nil {
+				return v, nil
+			}
+		}
+
+		return nil, nil
+	}
+
+	if len(args) == 0 || (len(args) > 1 && e.name != "ROUND") || len(args) > 2 {
+		return nil, fmt.Errorf("wrong number of arguments to %s", e.name)
+	}
+
+	if args[0] == nil {
+		return nil, nil
+	}
+
+	switch e.name {
+	case "UPPER":
+		return strings.ToUpper(toText(args[0])), nil
+	case "LOWER":
+		return strings.ToLower(toText(args[0])), nil
+	case "TRIM":
+		return strings.TrimSpace(toText(args[0])), nil
+	case "LENGTH":
+		return float64(len([]rune(toText(args[0])))), nil
+	case "ABS", "ROUND":
+		n, ok := toNumber(args[0])
+		if !ok {
+			return nil, fmt.Errorf("%s requires a number, not '%v'", e.name, args[0])
+		}
+
+		if e.name == "ABS" {
+			return math.Abs(n), nil
+		}
+
+		places := 0.0
+		if len(args) == 2 {
+			if places, ok = toNumber(args[1]); !ok {
+				return nil, fmt.Errorf("ROUND requires a number of places, not '%v'", args[1])
+			}
+		}
+
+		scale := math.Pow(10, math.Trunc(places))
+		return math.Round(n*scale) / scale, nil
+	default:
+		return nil, fmt.Errorf("no such function '%s'", e.name)
+	}
+}
+
+// compare compares two values that are not NULL, returning a negative
+// number, zero, or a positive number. Values of different types are compared
+// as numbers if both can be read as numbers, and as text otherwise.
+func compare(a, b any) int {
+	switch at := a.(type) {
+	case string:
+		if bt, ok := b.(string); ok {
+			return strings.Compare(at, bt)
+		}
+	case time.Time:
+		if bt, ok := b.(time.Time); ok {
+			return at.Compare(bt)
+		}
+	case bool:
+		if bt, ok := b.(bool); ok {
+			switch {
+			case at == bt:
+				return 0
+			case bt:
+				return -1
+			default:
+				return 1
+			}
+		}
+	}
+
+	an, aok := toNumber(a)
+	bn, bok := toNumber(b)
+	if aok && bok {
+		switch {
+		case an < bn:
+			return -1
+		case an > bn:
+			return 1
+		default:
+			return 0
+		}
+	}
+
+	return strings.Compare(toText(a), toText(b))
+}
+
+// compareForSort compares values for sorting, with NULLs first.
+func compareForSort(a, b any) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	default:
+		return compare(a, b)
+	}
+}
+
+func toNumber(v any) (float64, bool) {
+	switch tv := v.(type) {
+	case float64:
+		return tv, true
+	case int64:
+		return float64(tv), true
+	case string:
+		n, err := strconv.ParseFloat(strings.TrimSpace(tv), 64)
+		return n, err == nil
+	default:
+		return 0, false
+	}
+}
+
+func toText(v any) string {
+	switch tv := v.(type) {
+	case string:
+		return tv
+	case float64:
+		return strconv.FormatFloat(tv, 'f', -1, 64)
+	case time.Time:
+		return tv.Format(time.RFC3339)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// like matches text against a LIKE pattern, where % matches any text and _
+// matches any character, without regard to case.
+func like(text, pattern string) bool {
+	var sb strings.Builder
+	sb.WriteString("(?is)^")
+	for _, r := range pattern {
+		switch r {
+		case '%':
+			sb.WriteString(".*")
+		case '_':
+			sb.WriteString(".")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+
+	sb.WriteString("$")
+	return regexp.MustCompile(sb.String()).MatchString(text)
+}
@@ -0,0 +1,204 @@
+package sqldriver
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mmihic/sheets/src/pkg/sheets"
+)
+
+var testTables = map[string]*table{
+	"orders": {
+		columns: []string{"id", "customer", "product", "qty", "price"},
+		rows: [][]any{
+			{1.0, 1.0, "apple", 3.0, 0.5},
+			{2.0, 2.0, "pear", 1.0, 0.75},
+			{3.0, 1.0, "pear", 2.0, 0.75},
+			{4.0, 3.0, "plum", 10.0, 0.25},
+			{5.0, 9.0, "apple", 4.0, nil},
+		},
+	},
+	"customers": {
+		columns: []string{"id", "name", "region"},
+		rows: [][]any{
+			{1.0, "Ann", "north"},
+			{2.0, "Bob", "south"},
+			{3.0, "Cy", "north"},
+			{4.0, "Di", nil},
+		},
+	},
+}
+
+func runQuery(t *testing.T, query string, args ...any) (*result, error) {
+	stmt, err := parse(query)
+	require.NoError(t, err, query)
+
+	return execute(stmt, func(name string) (*table, error) {
+		if tbl, ok := testTables[name]; ok {
+			return tbl, nil
+		}
+
+		return nil, fmt.Errorf("no such table '%s'", name)
+	}, args)
+}
+
+func TestExecute(t *testing.T) {
+	for _, tt := range []struct {
+		query   string
+		args    []any
+		columns []string
+		rows    [][]any
+	}{
+		{
+			query:   "SELECT * FROM customers WHERE region = 'north'",
+			columns: []string{"id", "name", "region"},
+			rows:    [][]any{{1.0, "Ann", "north"}, {3.0, "Cy", "north"}},
+		},
+		{
+			query:   "SELECT name FROM customers WHERE region IS NULL OR name LIKE 'b%'",
+			columns: []string{"name"},
+			rows:    [][]any{{"Bob"}, {"Di"}},
+		},
+		{
+			query:   "SELECT product, qty * price AS total FROM orders WHERE qty BETWEEN ? AND ? ORDER BY total DESC",
+			args:    []any{2.0, 4.0},
+			columns: []string{"product", "total"},
+			rows:    [][]any{{"apple", 1.5}, {"pear", 1.5}, {"apple", nil}},
+		},
+		{
+			query:   "SELECT o.id, c.name FROM orders o JOIN customers c ON c.id = o.customer ORDER BY c.name, o.id DESC",
+			columns: []string{"id", "name"},
+			rows:    [][]any{{3.0, "Ann"}, {1.0, "Ann"}, {2.0, "Bob"}, {4.0, "Cy"}},
+		},
+		{
+			query:   "SELECT o.id, c.name FROM orders o LEFT JOIN customers c ON c.id = o.customer WHERE o.id > 3",
+			columns: []string{"id", "name"},
+			rows:    [][]any{{4.0, "Cy"}, {5.0, nil}},
+		},
+		{
+			query: `SELECT c.region, COUNT(*) AS orders, SUM(o.qty) AS qty, AVG(o.price), MIN(o.product), MAX(o.product)
+				FROM orders o JOIN customers c ON c.id = o.customer
+				GROUP BY c.region ORDER BY 1`,
+			columns: []string{"region", "orders", "qty", "AVG(o.price)", "MIN(o.product)", "MAX(o.product)"},
+			rows: [][]any{
+				{"north", int64(3), 15.0, 0.5, "apple", "plum"},
+				{"south", int64(1), 1.0, 0.75, "pear", "pear"},
+			},
+		},
+		{
+			query:   "SELECT product FROM orders GROUP BY product HAVING SUM(qty) > 3 ORDER BY SUM(qty) DESC",
+			columns: []string{"product"},
+			rows:    [][]any{{"plum"}, {"apple"}},
+		},
+		{
+			query:   "SELECT orders.product, UPPER(product), qty * 2, COUNT(*) AS n FROM orders GROUP BY product, qty * 2 ORDER BY n, product",
+			columns: []string{"product", "UPPER(product)", "qty * 2", "n"},
+			rows: [][]any{
+				{"apple", "APPLE", 6.0, int64(1)},
+				{"apple", "APPLE", 8.0, int64(1)},
+				{"pear", "PEAR", 2.0, int64(1)},
+				{"pear", "PEAR", 4.0, int64(1)},
+				{"plum", "PLUM", 20.0, int64(1)},
+			},
+		},
+		{
+			query:   "SELECT COUNT(*), COUNT(price), COUNT(DISTINCT product), SUM(price) FROM orders WHERE id > ?",
+			args:    []any{100.0},
+			columns: []string{"COUNT(*)", "COUNT(price)", "COUNT(DISTINCT product)", "SUM(price)"},
+			rows:    [][]any{{int64(0), int64(0), int64(0), nil}},
+		},
+		{
+			query:   "SELECT DISTINCT product FROM orders ORDER BY product LIMIT 2 OFFSET 1",
+			columns: []string{"product"},
+			rows:    [][]any{{"pear"}, {"plum"}},
+		},
+		{
+			query:   "SELECT UPPER(name) || '-' || LENGTH(name), COALESCE(region, 'none'), ROUND(id / 3, 2) FROM customers WHERE id IN (1, 4)",
+			columns: []string{"UPPER(name) || '-' || LENGTH(name)", "COALESCE(region, 'none')", "ROUND(id / 3, 2)"},
+			rows:    [][]any{{"ANN-3", "north", 0.33}, {"DI-2", "none", 1.33}},
+		},
+		{
+			query:   "SELECT COUNT(*) FROM customers a CROSS JOIN customers b WHERE a.id < b.id",
+			columns: []string{"COUNT(*)"},
+			rows:    [][]any{{int64(6)}},
+		},
+	} {
+		res, err := runQuery(t, tt.query, tt.args...)
+		require.NoError(t, err, tt.query)
+		assert.Equal(t, tt.columns, res.columns, tt.query)
+		assert.Equal(t, tt.rows, res.rows, tt.query)
+	}
+}
+
+func TestExecute_Errors(t *testing.T) {
+	for query, expected := range map[string]string{
+		"SELECT * FROM missing":                                      "no such table 'missing'",
+		"SELECT nope FROM customers":                                 "no such column 'nope'",
+		"SELECT x.id FROM customers":                                 "no such column 'x.id'",
+		"SELECT id FROM orders JOIN customers ON name = product":     "ambiguous column 'id'",
+		"SELECT nope FROM customers WHERE FALSE":                     "no such column 'nope'",
+		"SELECT * FROM orders JOIN customers c ON c.nope = 1":        "no such column 'c.nope'",
+		"SELECT * FROM orders o JOIN customers o ON o.id = 1":        "duplicate table alias 'o'",
+		"SELECT x.* FROM customers":                                  "no such table 'x'",
+		"SELECT name FROM customers WHERE SUM(id) > 1":               "aggregate SUM used outside of a group",
+		"SELECT name FROM customers ORDER BY 3":                      "invalid column position 3 in ORDER BY",
+		"SELECT product, qty FROM orders GROUP BY product":           "column 'qty' must appear in GROUP BY or be used in an aggregate",
+		"SELECT name, COUNT(*) FROM customers":                       "column 'name' must appear in GROUP BY or be used in an aggregate",
+		"SELECT product FROM orders GROUP BY product HAVING qty > 1": "column 'qty' must appear in GROUP BY or be used in an aggregate",
+		"SELECT product FROM orders GROUP BY product ORDER BY id":    "column 'id' must appear in GROUP BY or be used in an aggregate",
+		"SELECT qty * 3 FROM orders GROUP BY qty * 2":                "column 'qty' must appear in GROUP BY or be used in an aggregate",
+		"SELECT name FROM customers LIMIT -1":                        "invalid LIMIT '-1'",
+		"SELECT SUM(name) FROM customers":                            "SUM requires numbers, not 'Ann'",
+		"SELECT NOPE(name) FROM customers":                           "no such function 'NOPE'",
+		"SELECT name + 1 FROM customers":                             "cannot apply '+' to 'Ann' and '1'",
+		"SELECT name FROM customers WHERE name AND TRUE":             "AND requires booleans, not 'Ann'",
+		"SELECT name FROM customers WHERE id = ?":                    "missing parameter 1",
+	} {
+		_, err := runQuery(t, query)
+		assert.EqualError(t, err, expected, query)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	when := time.Date(2024, time.May, 1, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, 0, compare(1.0, "1"))
+	assert.Equal(t, -1, compare(2.0, 10.0))
+	assert.Equal(t, 1, compare("b", "a"))
+	assert.Equal(t, 1, compare("b", 10.0))
+	assert.Equal(t, -1, compare(false, true))
+	assert.Equal(t, -1, compare(when, when.Add(time.Hour)))
+	assert.Equal(t, -1, compareForSort(nil, 1.0))
+
+	assert.True(t, like("Banana", "b_n%"))
+	assert.False(t, like("Banana", "b_n"))
+	assert.False(t, like("a.c", "a\\c"))
+}
+
+func TestLoadTable(t *testing.T) {
+	ctx := context.Background()
+	when := time.Date(2024, time.May, 1, 0, 0, 0, 0, time.UTC)
+	s, err := sheets.NewMutableSheet([][]sheets.Value{
+		{sheets.StringValue("name"), sheets.BlankValue{}, sheets.StringValue(" when ")},
+		{sheets.StringValue("a"), sheets.Float64Value(1), sheets.TimeValue(when)},
+		{sheets.BoolValue(true), sheets.BlankValue{}, sheets.ErrorValue{Err: sheets.ErrorForCode("#DIV/0!")}},
+	})
+	require.NoError(t, err)
+
+	tbl, err := loadTable(ctx, s, true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"name", "B", "when"}, tbl.columns)
+	assert.Equal(t, [][]any{
+		{"a", 1.0, when},
+		{true, nil, "#DIV/0!"},
+	}, tbl.rows)
+
+	tbl, err = loadTable(ctx, s, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"A", "B", "C"}, tbl.columns)
+	assert.Len(t, tbl.rows, 3)
+}
@@ -0,0 +1,162 @@
+package sqldriver
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// The types of tokens.
+const (
+	tokenEOF = iota
+	tokenIdent
+	tokenKeyword
+	tokenQuotedIdent
+	tokenString
+	tokenNumber
+	tokenParam
+	tokenSymbol
+)
+
+// A token is a lexical token of a query.
+type token struct {
+	kind  int
+	text  string
+	start int
+	end   int
+}
+
+func (t token) String() string {
+	if t.kind == tokenEOF {
+		return "end of query"
+	}
+
+	return "'" + t.text + "'"
+}
+
+// keywords are the words that cannot be used as unquoted identifiers.
+var keywords = map[string]bool{
+	"ALL": true, "AND": true, "AS": true, "ASC": true, "BETWEEN": true, "BY": true,
+	"CROSS": true, "DESC": true, "DISTINCT": true, "FALSE": true, "FROM": true,
+	"GROUP": true, "HAVING": true, "IN": true, "INNER": true, "IS": true,
+	"JOIN": true, "LEFT": true, "LIKE": true, "LIMIT": true, "NOT": true,
+	"NULL": true, "OFFSET": true, "ON": true, "OR": true, "ORDER": true,
+	"OUTER": true, "SELECT": true, "TRUE": true, "WHERE": true,
+}
+
+// symbols are the operators and punctuation, longest first.
+var symbols = []string{"<>", "!=", "<=", ">=", "||", "=", "<", ">", "+", "-", "*", "/", "%", "(", ")", ",", ".", ";"}
+
+// lex splits a query into tokens. Keywords are returned in upper case.
+func lex(query string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(query); {
+		c := rune(query[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case strings.HasPrefix(query[i:], "--"):
+			// Comments run to the end of the line
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				end = len(query) - i
+			}
+
+			i += end
+		case c == '\'' || c == '"' || c == '`':
+			end, text, err := lexQuoted(query, i)
+			if err != nil {
+				return nil, err
+			}
+
+			kind := tokenQuotedIdent
+			if c == '\'' {
+				kind = tokenString
+			}
+
+			tokens = append(tokens, token{kind: kind, text: text, start: i, end: end})
+			i = end
+		case c == '?':
+			tokens = append(tokens, token{kind: tokenParam, text: "?", start: i, end: i + 1})
+			i++
+		case c >= '0' && c <= '9' || (c == '.' && i+1 < len(query) && query[i+1] >= '0' && query[i+1] <= '9'):
+			end := i
+			for end < len(query) && (isDigit(query[end]) || query[end] == '.') {
+				end++
+			}
+
+			if end < len(query) && (query[end] == 'e' || query[end] == 'E') {
+				end++
+				if end < len(query) && (query[end] == '+' || query[end] == '-') {
+					end++
+				}
+
+				for end < len(query) && isDigit(query[end]) {
+					end++
+				}
+			}
+
+			tokens = append(tokens, token{kind: tokenNumber, text: query[i:end], start: i, end: end})
+			i = end
+		case c == '_' || unicode.IsLetter(c) || c >= 0x80:
+			end := i
+			for end < len(query) && (query[end] == '_' || isDigit(query[end]) ||
+				unicode.IsLetter(rune(query[end])) || query[end] >= 0x80) {
+				end++
+			}
+
+			word := query[i:end]
+			if upper := strings.ToUpper(word); keywords[upper] {
+				tokens = append(tokens, token{kind: tokenKeyword, text: upper, start: i, end: end})
+			} else {
+				tokens = append(tokens, token{kind: tokenIdent, text: word, start: i, end: end})
+			}
+
+			i = end
+		default:
+			matched := false
+			for _, sym := range symbols {
+				if strings.HasPrefix(query[i:], sym) {
+					tokens = append(tokens, token{kind: tokenSymbol, text: sym, start: i, end: i + len(sym)})
+					i += len(sym)
+					matched = true
+					break
+				}
+			}
+
+			if !matched {
+				return nil, fmt.Errorf("unexpected character '%c' at offset %d", c, i)
+			}
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF, start: len(query), end: len(query)}), nil
+}
+
+// lexQuoted reads text quoted by the character at start, where the quote is
+// escaped by doubling it. Returns the offset following the text and the text
+// without its quotes.
+func lexQuoted(query string, start int) (int, string, error) {
+	quote := query[start]
+	var sb strings.Builder
+	for i := start + 1; i < len(query); i++ {
+		if query[i] != quote {
+			sb.WriteByte(query[i])
+			continue
+		}
+
+		if i+1 < len(query) && query[i+1] == quote {
+			sb.WriteByte(quote)
+			i++
+			continue
+		}
+
+		return i + 1, sb.String(), nil
+	}
+
+	return 0, "", fmt.Errorf("unterminated quoted text at offset %d", start)
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
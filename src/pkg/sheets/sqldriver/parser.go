@@ -0,0 +1,710 @@
+package sqldriver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A selectStmt is a parsed SELECT statement.
+//
+// The supported grammar is:
+//
+//	Select   := SELECT [DISTINCT | ALL] <Item> {"," <Item>}
+//	            FROM <Table> {<Join>}
+//	            [WHERE <Expr>]
+//	            [GROUP BY <Expr> {"," <Expr>}] [HAVING <Expr>]
+//	            [ORDER BY <Expr> [ASC | DESC] {"," <Expr> [ASC | DESC]}]
+//	            [LIMIT <Expr> [OFFSET <Expr>]] [";"]
+//	Item     := "*" | NAME "." "*" | <Expr> [[AS] NAME]
+//	Table    := NAME [[AS] NAME]
+//	Join     := [INNER | LEFT [OUTER] | CROSS] JOIN <Table> [ON <Expr>]
+//	Expr     := <And> {OR <And>}
+//	And      := <Not> {AND <Not>}
+//	Not      := NOT <Not> | <Compare>
+//	Compare  := <Sum> [("=" | "<>" | "!=" | "<" | "<=" | ">" | ">=") <Sum>
+//	                  | IS [NOT] NULL | [NOT] LIKE <Sum> | [NOT] IN "(" <Expr> {"," <Expr>} ")"
+//	                  | [NOT] BETWEEN <Sum> AND <Sum>]
+//	Sum      := <Product> {("+" | "-" | "||") <Product>}
+//	Product  := <Unary> {("*" | "/" | "%") <Unary>}
+//	Unary    := "-" <Unary> | <Primary>
+//	Primary  := NUMBER | STRING | TRUE | FALSE | NULL | "?" | "(" <Expr> ")"
+//	          | NAME "(" [DISTINCT] ["*" | <Expr> {"," <Expr>}] ")" | NAME ["." NAME]
+//
+// NAMEs are identifiers, or text quoted with double quotes or backticks, and
+// are matched without regard to case.
+type selectStmt struct {
+	distinct bool
+	items    []selectItem
+	from     tableRef
+	joins    []join
+	where    expr
+	groupBy  []expr
+	having   expr
+	orderBy  []orderItem
+	limit    expr
+	offset   expr
+	params   int
+}
+
+// A selectItem is an expression in the select list, or a star selecting all
+// of the columns of a table, or of every table if table is empty.
+type selectItem struct {
+	expr  expr
+	name  string
+	star  bool
+	table string
+}
+
+type tableRef struct {
+	name  string
+	alias string
+}
+
+// A join joins a table to those before it. Cross joins have no condition.
+type join struct {
+	left  bool
+	table tableRef
+	on    expr
+}
+
+type orderItem struct {
+	expr expr
+	desc bool
+}
+
+// An expr is an expression.
+type expr interface{}
+
+type (
+	literal struct {
+		value any
+	}
+
+	param struct {
+		index int
+	}
+
+	columnRef struct {
+		table string
+		name  string
+	}
+
+	unaryExpr struct {
+		op string
+		x  expr
+	}
+
+	binaryExpr struct {
+		op   string
+		l, r expr
+	}
+
+	isNullExpr struct {
+		x   expr
+		not bool
+	}
+
+	inExpr struct {
+		x    expr
+		list []expr
+		not  bool
+	}
+
+	betweenExpr struct {
+		x, lo, hi expr
+		not       bool
+	}
+
+	callExpr struct {
+		name     string
+		args     []expr
+		star     bool
+		distinct bool
+	}
+)
+
+// A parser parses a query from its tokens.
+type parser struct {
+	query  string
+	tokens []token
+	pos    int
+	params int
+}
+
+// parse parses a SELECT statement.
+func parse(query string) (*selectStmt, error) {
+	tokens, err := lex(query)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{query: query, tokens: tokens}
+	stmt, err := p.parseSelect()
+	if err != nil {
+		return nil, err
+	}
+
+	p.acceptSymbol(";")
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, p.unexpected(tok, "end of query")
+	}
+
+	stmt.params = p.params
+	return stmt, nil
+}
+
+func (p *parser) parseSelect() (*selectStmt, error) {
+	if err := p.expectKeyword("SELECT"); err != nil {
+		return nil, err
+	}
+
+	stmt := &selectStmt{}
+	if p.acceptKeyword("DISTINCT") {
+		stmt.distinct = true
+	} else {
+		p.acceptKeyword("ALL")
+	}
+
+	for {
+		item, err := p.parseItem()
+		if err != nil {
+			return nil, err
+		}
+
+		stmt.items = append(stmt.items, item)
+		if !p.acceptSymbol(",") {
+			break
+		}
+	}
+
+	if err := p.expectKeyword("FROM"); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if stmt.from, err = p.parseTable(); err != nil {
+		return nil, err
+	}
+
+	for {
+		j, ok, err := p.parseJoin()
+		if err != nil {
+			return nil, err
+		}
+
+		if !ok {
+			break
+		}
+
+		stmt.joins = append(stmt.joins, j)
+	}
+
+	if p.acceptKeyword("WHERE") {
+		if stmt.where, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.acceptKeyword("GROUP") {
+		if err := p.expectKeyword("BY"); err != nil {
+			return nil, err
+		}
+
+		if stmt.groupBy, err = p.parseExprList(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.acceptKeyword("HAVING") {
+		if stmt.having, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.acceptKeyword("ORDER") {
+		if err := p.expectKeyword("BY"); err != nil {
+			return nil, err
+		}
+
+		for {
+			e, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+
+			item := orderItem{expr: e}
+			if p.acceptKeyword("DESC") {
+				item.desc = true
+			} else {
+				p.acceptKeyword("ASC")
+			}
+
+			stmt.orderBy = append(stmt.orderBy, item)
+			if !p.acceptSymbol(",") {
+				break
+			}
+		}
+	}
+
+	if p.acceptKeyword("LIMIT") {
+		if stmt.limit, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+
+		if p.acceptKeyword("OFFSET") {
+			if stmt.offset, err = p.parseExpr(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return stmt, nil
+}
+
+func (p *parser) parseItem() (selectItem, error) {
+	if p.acceptSymbol("*") {
+		return selectItem{star: true}, nil
+	}
+
+	// A table's columns, such as t.*
+	if tok := p.peek(); isName(tok) && p.peekAt(1).text == "." && p.peekAt(2).text == "*" {
+		p.pos += 3
+		return selectItem{star: true, table: tok.text}, nil
+	}
+
+	start := p.peek().start
+	e, err := p.parseExpr()
+	if err != nil {
+		return selectItem{}, err
+	}
+
+	item := selectItem{expr: e, name: strings.TrimSpace(p.query[start:p.tokens[p.pos-1].end])}
+	if col, ok := e.(*columnRef); ok {
+		item.name = col.name
+	}
+
+	if p.acceptKeyword("AS") {
+		tok := p.next()
+		if !isName(tok) {
+			return selectItem{}, p.unexpected(tok, "column name")
+		}
+
+		item.name = tok.text
+	} else if tok := p.peek(); isName(tok) {
+		p.pos++
+		item.name = tok.text
+	}
+
+	return item, nil
+}
+
+func (p *parser) parseTable() (tableRef, error) {
+	tok := p.next()
+	if !isName(tok) {
+		return tableRef{}, p.unexpected(tok, "table name")
+	}
+
+	ref := tableRef{name: tok.text, alias: tok.text}
+	if p.acceptKeyword("AS") {
+		tok := p.next()
+		if !isName(tok) {
+			return tableRef{}, p.unexpected(tok, "table alias")
+		}
+
+		ref.alias = tok.text
+	} else if tok := p.peek(); isName(tok) {
+		p.pos++
+		ref.alias = tok.text
+	}
+
+	return ref, nil
+}
+
+// parseJoin parses a join, returning false if there is none.
+func (p *parser) parseJoin() (join, bool, error) {
+	var j join
+	cross := false
+	switch {
+	case p.acceptKeyword("INNER"):
+	case p.acceptKeyword("LEFT"):
+		p.acceptKeyword("OUTER")
+		j.left = true
+	case p.acceptKeyword("CROSS"):
+		cross = true
+	case p.acceptSymbol(","):
+		// A comma is a cross join
+		table, err := p.parseTable()
+		return join{table: table}, err == nil, err
+	case p.peek().kind == tokenKeyword && p.peek().text == "JOIN":
+	default:
+		return join{}, false, nil
+	}
+
+	if err := p.expectKeyword("JOIN"); err != nil {
+		return join{}, false, err
+	}
+
+	var err error
+	if j.table, err = p.parseTable(); err != nil {
+		return join{}, false, err
+	}
+
+	if cross {
+		return j, true, nil
+	}
+
+	if err := p.expectKeyword("ON"); err != nil {
+		return join{}, false, err
+	}
+
+	if j.on, err = p.parseExpr(); err != nil {
+		return join{}, false, err
+	}
+
+	return j, true, nil
+}
+
+func (p *parser) parseExprList() ([]expr, error) {
+	var list []expr
+	for {
+		e, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+
+		list = append(list, e)
+		if !p.acceptSymbol(",") {
+			return list, nil
+		}
+	}
+}
+
+func (p *parser) parseExpr() (expr, error) {
+	l, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.acceptKeyword("OR") {
+		r, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+
+		l = &binaryExpr{op: "OR", l: l, r: r}
+	}
+
+	return l, nil
+}
+
+func (p *parser) parseAnd() (expr, error) {
+	l, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.acceptKeyword("AND") {
+		r, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+
+		l = &binaryExpr{op: "AND", l: l, r: r}
+	}
+
+	return l, nil
+}
+
+func (p *parser) parseNot() (expr, error) {
+	if p.acceptKeyword("NOT") {
+		x, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+
+		return &unaryExpr{op: "NOT", x: x}, nil
+	}
+
+	return p.parseCompare()
+}
+
+func (p *parser) parseCompare() (expr, error) {
+	l, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+
+	if tok := p.peek(); tok.kind == tokenSymbol {
+		switch tok.text {
+		case "=", "<>", "!=", "<", "<=", ">", ">=":
+			p.pos++
+			r, err := p.parseSum()
+			if err != nil {
+				return nil, err
+			}
+
+			op := tok.text
+			if op == "!=" {
+				op = "<>"
+			}
+
+			return &binaryExpr{op: op, l: l, r: r}, nil
+		}
+	}
+
+	if p.acceptKeyword("IS") {
+		not := p.acceptKeyword("NOT")
+		if err := p.expectKeyword("NULL"); err != nil {
+			return nil, err
+		}
+
+		return &isNullExpr{x: l, not: not}, nil
+	}
+
+	not := p.acceptKeyword("NOT")
+	switch {
+	case p.acceptKeyword("LIKE"):
+		r, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+
+		var e expr = &binaryExpr{op: "LIKE", l: l, r: r}
+		if not {
+			e = &unaryExpr{op: "NOT", x: e}
+		}
+
+		return e, nil
+	case p.acceptKeyword("IN"):
+		if err := p.expectSymbol("("); err != nil {
+			return nil, err
+		}
+
+		list, err := p.parseExprList()
+		if err != nil {
+			return nil, err
+		}
+
+		if err := p.expectSymbol(")"); err != nil {
+			return nil, err
+		}
+
+		return &inExpr{x: l, list: list, not: not}, nil
+	case p.acceptKeyword("BETWEEN"):
+		lo, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+
+		if err := p.expectKeyword("AND"); err != nil {
+			return nil, err
+		}
+
+		hi, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+
+		return &betweenExpr{x: l, lo: lo, hi: hi, not: not}, nil
+	case not:
+		return nil, p.unexpected(p.peek(), "LIKE", "IN", "BETWEEN")
+	}
+
+	return l, nil
+}
+
+func (p *parser) parseSum() (expr, error) {
+	l, err := p.parseProduct()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		tok := p.peek()
+		if tok.kind != tokenSymbol || (tok.text != "+" && tok.text != "-" && tok.text != "||") {
+			return l, nil
+		}
+
+		p.pos++
+		r, err := p.parseProduct()
+		if err != nil {
+			return nil, err
+		}
+
+		l = &binaryExpr{op: tok.text, l: l, r: r}
+	}
+}
+
+func (p *parser) parseProduct() (expr, error) {
+	l, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		tok := p.peek()
+		if tok.kind != tokenSymbol || (tok.text != "*" && tok.text != "/" && tok.text != "%") {
+			return l, nil
+		}
+
+		p.pos++
+		r, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		l = &binaryExpr{op: tok.text, l: l, r: r}
+	}
+}
+
+func (p *parser) parseUnary() (expr, error) {
+	if p.acceptSymbol("-") {
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		return &unaryExpr{op: "-", x: x}, nil
+	}
+
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (expr, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokenNumber:
+		n, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number '%s'", tok.text)
+		}
+
+		return &literal{value: n}, nil
+	case tokenString:
+		return &literal{value: tok.text}, nil
+	case tokenParam:
+		p.params++
+		return &param{index: p.params - 1}, nil
+	case tokenKeyword:
+		switch tok.text {
+		case "TRUE":
+			return &literal{value: true}, nil
+		case "FALSE":
+			return &literal{value: false}, nil
+		case "NULL":
+			return &literal{}, nil
+		}
+	case tokenSymbol:
+		if tok.text == "(" {
+			e, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+
+			return e, p.expectSymbol(")")
+		}
+	case tokenIdent, tokenQuotedIdent:
+		if tok.kind == tokenIdent && p.acceptSymbol("(") {
+			return p.parseCall(tok.text)
+		}
+
+		if p.acceptSymbol(".") {
+			name := p.next()
+			if !isName(name) {
+				return nil, p.unexpected(name, "column name")
+			}
+
+			return &columnRef{table: tok.text, name: name.text}, nil
+		}
+
+		return &columnRef{name: tok.text}, nil
+	}
+
+	return nil, p.unexpected(tok, "expression")
+}
+
+// parseCall parses the arguments of a function call, whose opening
+// parenthesis has been read.
+func (p *parser) parseCall(name string) (expr, error) {
+	call := &callExpr{name: strings.ToUpper(name)}
+	switch {
+	case p.acceptSymbol("*"):
+		call.star = true
+	case p.acceptSymbol(")"):
+		return call, nil
+	default:
+		call.distinct = p.acceptKeyword("DISTINCT")
+		args, err := p.parseExprList()
+		if err != nil {
+			return nil, err
+		}
+
+		call.args = args
+	}
+
+	return call, p.expectSymbol(")")
+}
+
+func (p *parser) peek() token {
+	return p.peekAt(0)
+}
+
+func (p *parser) peekAt(n int) token {
+	if p.pos+n >= len(p.tokens) {
+		return p.tokens[len(p.tokens)-1]
+	}
+
+	return p.tokens[p.pos+n]
+}
+
+func (p *parser) next() token {
+	tok := p.peek()
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+
+	return tok
+}
+
+func (p *parser) acceptKeyword(word string) bool {
+	if tok := p.peek(); tok.kind == tokenKeyword && tok.text == word {
+		p.pos++
+		return true
+	}
+
+	return false
+}
+
+func (p *parser) acceptSymbol(sym string) bool {
+	if tok := p.peek(); tok.kind == tokenSymbol && tok.text == sym {
+		p.pos++
+		return true
+	}
+
+	return false
+}
+
+func (p *parser) expectKeyword(word string) error {
+	if !p.acceptKeyword(word) {
+		return p.unexpected(p.peek(), word)
+	}
+
+	return nil
+}
+
+func (p *parser) expectSymbol(sym string) error {
+	if !p.acceptSymbol(sym) {
+		return p.unexpected(p.peek(), "'"+sym+"'")
+	}
+
+	return nil
+}
+
+func (p *parser) unexpected(tok token, expected ...string) error {
+	return fmt.Errorf("unexpected %s at offset %d, expected %s", tok, tok.start, strings.Join(expected, " or "))
+}
+
+func isName(tok token) bool {
+	return tok.kind == tokenIdent || tok.kind == tokenQuotedIdent
+}
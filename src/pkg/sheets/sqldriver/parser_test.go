@@ -0,0 +1,118 @@
+package sqldriver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLex(t *testing.T) {
+	tokens, err := lex(`select "Unit Price", 'it''s' -- comment
+		FROM t WHERE x >= 1.5e3 AND y != ?`)
+	require.NoError(t, err)
+
+	var texts []string
+	for _, tok := range tokens {
+		texts = append(texts, tok.text)
+	}
+
+	assert.Equal(t, []string{
+		"SELECT", "Unit Price", ",", "it's", "FROM", "t", "WHERE", "x", ">=", "1.5e3",
+		"AND", "y", "!=", "?", "",
+	}, texts)
+	assert.Equal(t, tokenQuotedIdent, tokens[1].kind)
+	assert.Equal(t, tokenString, tokens[3].kind)
+	assert.Equal(t, tokenIdent, tokens[5].kind)
+	assert.Equal(t, tokenParam, tokens[13].kind)
+
+	_, err = lex("SELECT 'open")
+	assert.EqualError(t, err, "unterminated quoted text at offset 7")
+
+	_, err = lex("SELECT #")
+	assert.EqualError(t, err, "unexpected character '#' at offset 7")
+}
+
+func TestParse(t *testing.T) {
+	stmt, err := parse(`SELECT DISTINCT o.region AS r, COUNT(*), SUM(o.amount) total
+		FROM Orders o LEFT JOIN Customers c ON c.id = o.customer
+		WHERE o.amount BETWEEN ? AND 100 AND c.name NOT LIKE 'x%'
+		GROUP BY o.region HAVING COUNT(*) > 1
+		ORDER BY 2 DESC, r
+		LIMIT 10 OFFSET ?;`)
+	require.NoError(t, err)
+
+	assert.True(t, stmt.distinct)
+	assert.Equal(t, 2, stmt.params)
+	assert.Equal(t, tableRef{name: "Orders", alias: "o"}, stmt.from)
+	require.Len(t, stmt.joins, 1)
+	assert.True(t, stmt.joins[0].left)
+	assert.Equal(t, tableRef{name: "Customers", alias: "c"}, stmt.joins[0].table)
+
+	var names []string
+	for _, item := range stmt.items {
+		names = append(names, item.name)
+	}
+
+	assert.Equal(t, []string{"r", "COUNT(*)", "total"}, names)
+	assert.Equal(t, &callExpr{name: "COUNT", star: true}, stmt.items[1].expr)
+	assert.Equal(t, &callExpr{name: "SUM", args: []expr{&columnRef{table: "o", name: "amount"}}},
+		stmt.items[2].expr)
+
+	assert.Equal(t, &binaryExpr{
+		op: "AND",
+		l:  &betweenExpr{x: &columnRef{table: "o", name: "amount"}, lo: &param{index: 0}, hi: &literal{value: 100.0}},
+		r: &unaryExpr{op: "NOT", x: &binaryExpr{
+			op: "LIKE", l: &columnRef{table: "c", name: "name"}, r: &literal{value: "x%"},
+		}},
+	}, stmt.where)
+
+	assert.Equal(t, []orderItem{
+		{expr: &literal{value: 2.0}, desc: true},
+		{expr: &columnRef{name: "r"}},
+	}, stmt.orderBy)
+	assert.Equal(t, &literal{value: 10.0}, stmt.limit)
+	assert.Equal(t, &param{index: 1}, stmt.offset)
+}
+
+func TestParse_Precedence(t *testing.T) {
+	stmt, err := parse("SELECT 1 + 2 * -x, a || b FROM t WHERE NOT a = 1 OR b IS NOT NULL AND c IN (1, 2)")
+	require.NoError(t, err)
+
+	assert.Equal(t, &binaryExpr{
+		op: "+",
+		l:  &literal{value: 1.0},
+		r: &binaryExpr{op: "*", l: &literal{value: 2.0},
+			r: &unaryExpr{op: "-", x: &columnRef{name: "x"}}},
+	}, stmt.items[0].expr)
+	assert.Equal(t, "1 + 2 * -x", stmt.items[0].name)
+	assert.Equal(t, "a || b", stmt.items[1].name)
+
+	assert.Equal(t, &binaryExpr{
+		op: "OR",
+		l: &unaryExpr{op: "NOT", x: &binaryExpr{
+			op: "=", l: &columnRef{name: "a"}, r: &literal{value: 1.0},
+		}},
+		r: &binaryExpr{
+			op: "AND",
+			l:  &isNullExpr{x: &columnRef{name: "b"}, not: true},
+			r: &inExpr{x: &columnRef{name: "c"},
+				list: []expr{&literal{value: 1.0}, &literal{value: 2.0}}},
+		},
+	}, stmt.where)
+}
+
+func TestParse_Errors(t *testing.T) {
+	for query, expected := range map[string]string{
+		"SELECT":                     "unexpected end of query at offset 6, expected expression",
+		"SELECT a":                   "unexpected end of query at offset 8, expected FROM",
+		"SELECT a FROM":              "unexpected end of query at offset 13, expected table name",
+		"SELECT a FROM t WHERE":      "unexpected end of query at offset 21, expected expression",
+		"SELECT a FROM t JOIN u":     "unexpected end of query at offset 22, expected ON",
+		"SELECT (a FROM t":           "unexpected 'FROM' at offset 10, expected ')'",
+		"SELECT a FROM t extra junk": "unexpected 'junk' at offset 22, expected end of query",
+	} {
+		_, err := parse(query)
+		assert.EqualError(t, err, expected, query)
+	}
+}
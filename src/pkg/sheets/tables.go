@@ -0,0 +1,477 @@
+package sheets
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// ellipsis marks text, rows and columns left out of a table.
+const ellipsis = "…"
+
+// A TableOption is an option for rendering a sheet as a Markdown, HTML or
+// plain-text table.
+type TableOption func(opts *tableOptions)
+
+type tableOptions struct {
+	rng           *Range
+	coordinates   bool
+	maxRows       int
+	maxCols       int
+	maxCellWidth  int
+	columnFormats map[int]string
+}
+
+// WithTableRange renders only the cells within a range of the sheet, such as
+// one returned by ParseRange. Open-ended ranges are resolved against the
+// sheet's dimensions.
+func WithTableRange(r Range) TableOption {
+	return func(opts *tableOptions) {
+		opts.rng = &r
+	}
+}
+
+// WithCoordinates renders the letters of the columns above the table, and the
+// numbers of the rows to its left, as they are written in a Pos such as "B3".
+// Every row of the range is then rendered as a row of values, rather than the
+// first being rendered as the table's header.
+func WithCoordinates() TableOption {
+	return func(opts *tableOptions) {
+		opts.coordinates = true
+	}
+}
+
+// WithMaxRows renders at most the given number of rows of the range, followed
+// by a row of ellipses if rows were left out.
+func WithMaxRows(n int) TableOption {
+	return func(opts *tableOptions) {
+		opts.maxRows = n
+	}
+}
+
+// WithMaxColumns renders at most the given number of columns of the range,
+// followed by a column of ellipses if columns were left out.
+func WithMaxColumns(n int) TableOption {
+	return func(opts *tableOptions) {
+		opts.maxCols = n
+	}
+}
+
+// WithMaxCellWidth truncates the text of cells to the given number of
+// characters, ending truncated text with an ellipsis.
+func WithMaxCellWidth(n int) TableOption {
+	return func(opts *tableOptions) {
+		opts.maxCellWidth = n
+	}
+}
+
+// WithTableColumnFormat renders the values in a column of the sheet using an
+// Excel number format code, in the same way as WithColumnFormat does for
+// WriteCSV.
+func WithTableColumnFormat(col int, format string) TableOption {
+	return func(opts *tableOptions) {
+		if opts.columnFormats == nil {
+			opts.columnFormats = make(map[int]string)
+		}
+
+		opts.columnFormats[col] = format
+	}
+}
+
+// WriteMarkdown writes the values of a sheet as a GitHub-flavored Markdown
+// table. The first row of the sheet is the table's header, unless
+// WithCoordinates is passed. Values are written as they are by WriteCSV, with
+// Markdown syntax escaped and line breaks written as <br>. Columns holding
+// only numbers and times are aligned to the right, and columns holding only
+// booleans and errors are centered.
+func WriteMarkdown(ctx context.Context, w io.Writer, s Sheet, opts ...TableOption) error {
+	t, err := newTable(ctx, s, opts)
+	if err != nil || t.header == nil {
+		return err
+	}
+
+	t.escape(markdownEscaper.Replace)
+	widths, aligns := t.widths(), t.columnAlignments()
+	for i := range widths {
+		if widths[i] < 3 {
+			widths[i] = 3
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	writeRow := func(cells []tableCell) {
+		_, _ = bw.WriteString("|")
+		for i, c := range cells {
+			_, _ = bw.WriteString(" " + pad(c.text, widths[i], aligns[i]) + " |")
+		}
+
+		_, _ = bw.WriteString("\n")
+	}
+
+	writeRow(t.header)
+	_, _ = bw.WriteString("|")
+	for i, align := range aligns {
+		rule := strings.Repeat("-", widths[i])
+		switch align {
+		case alignRight:
+			rule = rule[1:] + ":"
+		case alignCenter:
+			rule = ":" + rule[2:] + ":"
+		}
+
+		_, _ = bw.WriteString(" " + rule + " |")
+	}
+
+	_, _ = bw.WriteString("\n")
+	for _, row := range t.rows {
+		writeRow(row)
+	}
+
+	return bw.Flush()
+}
+
+// markdownEscaper escapes the characters of text that would otherwise be read
+// as Markdown syntax within a table cell, and replaces its line breaks.
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "|", `\|`, "`", "\\`", "*", `\*`, "_", `\_`,
+	"[", `\[`, "]", `\]`, "<", `\<`, ">", `\>`,
+	"\r\n", "<br>", "\r", "<br>", "\n", "<br>",
+)
+
+// htmlLineBreaks replaces the line breaks of escaped HTML text.
+var htmlLineBreaks = strings.NewReplacer("\r\n", "<br>", "\r", "<br>", "\n", "<br>")
+
+// textWhitespace replaces the line breaks and tabs of plain text, which would
+// break the alignment of a grid.
+var textWhitespace = strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ", "\t", " ")
+
+// WriteHTML writes the values of a sheet as an HTML table, with the header in
+// a <thead> and the remaining rows in a <tbody>. The first row of the sheet is
+// the table's header, unless WithCoordinates is passed, in which case the
+// numbers of the rows are also header cells. Values are written as they are by
+// WriteCSV, with HTML escaped and line breaks written as <br>. Cells holding
+// numbers and times are aligned to the right, and cells holding booleans and
+// errors are centered.
+func WriteHTML(ctx context.Context, w io.Writer, s Sheet, opts ...TableOption) error {
+	t, err := newTable(ctx, s, opts)
+	if err != nil {
+		return err
+	}
+
+	t.escape(func(text string) string {
+		return htmlLineBreaks.Replace(html.EscapeString(text))
+	})
+
+	bw := bufio.NewWriter(w)
+	writeRow := func(cells []tableCell, tag string) {
+		_, _ = bw.WriteString("<tr>")
+		for _, c := range cells {
+			cellTag := tag
+			if c.heading {
+				cellTag = "th"
+			}
+
+			_, _ = bw.WriteString("<" + cellTag)
+			switch c.align {
+			case alignRight:
+				_, _ = bw.WriteString(` style="text-align: right"`)
+			case alignCenter:
+				_, _ = bw.WriteString(` style="text-align: center"`)
+			}
+
+			_, _ = bw.WriteString(">" + c.text + "</" + cellTag + ">")
+		}
+
+		_, _ = bw.WriteString("</tr>\n")
+	}
+
+	_, _ = bw.WriteString("<table>\n")
+	if t.header != nil {
+		_, _ = bw.WriteString("<thead>\n")
+		writeRow(t.header, "th")
+		_, _ = bw.WriteString("</thead>\n")
+	}
+
+	if len(t.rows) > 0 {
+		_, _ = bw.WriteString("<tbody>\n")
+		for _, row := range t.rows {
+			writeRow(row, "td")
+		}
+
+		_, _ = bw.WriteString("</tbody>\n")
+	}
+
+	_, _ = bw.WriteString("</table>\n")
+	return bw.Flush()
+}
+
+// WriteText writes the values of a sheet as a plain-text grid, with the
+// columns padded to the same width and the header ruled off from the other
+// rows. The first row of the sheet is the grid's header, unless
+// WithCoordinates is passed. Values are written as they are by WriteCSV, with
+// line breaks and tabs written as spaces. Numbers and times are aligned to the
+// right, and booleans and errors are centered, as Excel displays them.
+func WriteText(ctx context.Context, w io.Writer, s Sheet, opts ...TableOption) error {
+	t, err := newTable(ctx, s, opts)
+	if err != nil || t.header == nil {
+		return err
+	}
+
+	t.escape(textWhitespace.Replace)
+	widths := t.widths()
+	var rule strings.Builder
+	rule.WriteString("+")
+	for _, width := range widths {
+		rule.WriteString(strings.Repeat("-", width+2) + "+")
+	}
+
+	rule.WriteString("\n")
+
+	bw := bufio.NewWriter(w)
+	writeRow := func(cells []tableCell) {
+		_, _ = bw.WriteString("|")
+		for i, c := range cells {
+			_, _ = bw.WriteString(" " + pad(c.text, widths[i], c.align) + " |")
+		}
+
+		_, _ = bw.WriteString("\n")
+	}
+
+	_, _ = bw.WriteString(rule.String())
+	writeRow(t.header)
+	_, _ = bw.WriteString(rule.String())
+	for _, row := range t.rows {
+		writeRow(row)
+	}
+
+	if len(t.rows) > 0 {
+		_, _ = bw.WriteString(rule.String())
+	}
+
+	return bw.Flush()
+}
+
+// An alignment is the horizontal alignment of the text of a cell.
+type alignment int
+
+const (
+	alignLeft alignment = iota
+	alignRight
+	alignCenter
+)
+
+// alignmentOf returns the alignment Excel gives a value by default.
+func alignmentOf(v Value) alignment {
+	switch v.(type) {
+	case Float64Value, FormattedNumber, TimeValue:
+		return alignRight
+	case BoolValue, ErrorValue:
+		return alignCenter
+	default:
+		return alignLeft
+	}
+}
+
+// A tableCell is the text of a cell of a table, along with its alignment.
+// Heading cells hold the coordinates of the table's rows and columns, and
+// empty cells are blank or mark cells that were left out.
+type tableCell struct {
+	text    string
+	align   alignment
+	heading bool
+	empty   bool
+}
+
+// A table is the text of a range of a sheet, ready to be rendered. Every row,
+// including the header, has the same number of cells. A table without a
+// header has no rows.
+type table struct {
+	header []tableCell
+	rows   [][]tableCell
+}
+
+func newTable(ctx context.Context, s Sheet, opts []TableOption) (*table, error) {
+	var options tableOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	for _, limit := range []struct {
+		name string
+		n    int
+	}{
+		{"rows", options.maxRows}, {"columns", options.maxCols}, {"cell width", options.maxCellWidth},
+	} {
+		if limit.n < 0 {
+			return nil, fmt.Errorf("invalid maximum %s %d", limit.name, limit.n)
+		}
+	}
+
+	formats := make(map[int]*NumberFormat, len(options.columnFormats))
+	for col, code := range options.columnFormats {
+		nf, err := ParseNumberFormat(code)
+		if err != nil {
+			return nil, fmt.Errorf("invalid format for column %s: %w", columnToString(col), err)
+		}
+
+		formats[col] = nf
+	}
+
+	settings := calcSettingsOf(s)
+	dims := s.Dimensions()
+	r := Range{EndRow: dims.EndRow, EndCol: dims.EndCol}
+	if options.rng != nil {
+		var ok bool
+		if r, ok = options.rng.Resolve(dims); !ok {
+			return &table{}, nil
+		}
+	}
+
+	endRow, endCol := r.EndRow, r.EndCol
+	moreRows := options.maxRows > 0 && endRow-r.StartRow+1 > options.maxRows
+	if moreRows {
+		endRow = r.StartRow + options.maxRows - 1
+	}
+
+	moreCols := options.maxCols > 0 && endCol-r.StartCol+1 > options.maxCols
+	if moreCols {
+		endCol = r.StartCol + options.maxCols - 1
+	}
+
+	truncate := func(text string) string {
+		if options.maxCellWidth > 0 && utf8.RuneCountInString(text) > options.maxCellWidth {
+			runes := []rune(text)
+			return string(runes[:options.maxCellWidth-1]) + ellipsis
+		}
+
+		return text
+	}
+
+	heading := func(text string, align alignment) tableCell {
+		return tableCell{text: text, align: align, heading: true}
+	}
+
+	var rows [][]tableCell
+	if options.coordinates {
+		header := []tableCell{heading("", alignLeft)}
+		for col := r.StartCol; col <= endCol; col++ {
+			header = append(header, heading(columnToString(col), alignCenter))
+		}
+
+		rows = append(rows, header)
+	}
+
+	for row := r.StartRow; row <= endRow; row++ {
+		var cells []tableCell
+		if options.coordinates {
+			cells = append(cells, heading(rowToString(row), alignRight))
+		}
+
+		for col := r.StartCol; col <= endCol; col++ {
+			v, err := s.Get(ctx, Pos{Row: row, Col: col})
+			if err != nil {
+				var posErr InvalidPosError
+				if !errors.As(err, &posErr) {
+					return nil, err
+				}
+
+				v = BlankValue{}
+			}
+
+			_, blank := v.(BlankValue)
+			cells = append(cells, tableCell{
+				text:  truncate(displayValue(v, formats[col], settings)),
+				align: alignmentOf(v),
+				empty: blank,
+			})
+		}
+
+		rows = append(rows, cells)
+	}
+
+	if moreCols {
+		for i := range rows {
+			rows[i] = append(rows[i], tableCell{
+				text: ellipsis, align: alignCenter, heading: options.coordinates && i == 0, empty: true,
+			})
+		}
+	}
+
+	if moreRows {
+		more := make([]tableCell, len(rows[0]))
+		for i := range more {
+			more[i] = tableCell{text: ellipsis, align: alignCenter, heading: options.coordinates && i == 0, empty: true}
+		}
+
+		rows = append(rows, more)
+	}
+
+	return &table{header: rows[0], rows: rows[1:]}, nil
+}
+
+// escape replaces the text of every cell with its escaped form.
+func (t *table) escape(fn func(text string) string) {
+	for _, row := range append([][]tableCell{t.header}, t.rows...) {
+		for i := range row {
+			row[i].text = fn(row[i].text)
+		}
+	}
+}
+
+// widths returns the width of the widest text in each column.
+func (t *table) widths() []int {
+	widths := make([]int, len(t.header))
+	for _, row := range append([][]tableCell{t.header}, t.rows...) {
+		for i, c := range row {
+			if n := utf8.RuneCountInString(c.text); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+
+	return widths
+}
+
+// columnAlignments returns the alignment of each column: the alignment shared
+// by every cell of the column below the header that is not empty, or
+// alignLeft if there is none.
+func (t *table) columnAlignments() []alignment {
+	aligns := make([]alignment, len(t.header))
+	for i := range aligns {
+		found := false
+		for _, row := range t.rows {
+			if c := row[i]; c.empty {
+				continue
+			} else if !found {
+				aligns[i], found = c.align, true
+			} else if c.align != aligns[i] {
+				aligns[i] = alignLeft
+				break
+			}
+		}
+	}
+
+	return aligns
+}
+
+// pad pads text with spaces to the given width.
+func pad(text string, width int, align alignment) string {
+	n := width - utf8.RuneCountInString(text)
+	if n <= 0 {
+		return text
+	}
+
+	switch align {
+	case alignRight:
+		return strings.Repeat(" ", n) + text
+	case alignCenter:
+		return strings.Repeat(" ", n/2) + text + strings.Repeat(" ", n-n/2)
+	default:
+		return text + strings.Repeat(" ", n)
+	}
+}
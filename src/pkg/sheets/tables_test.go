@@ -0,0 +1,171 @@
+package sheets
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTableTestSheet(t *testing.T) MutableSheet {
+	ctx := context.TODO()
+	s, err := ReadCSV(strings.NewReader(`item,quantity,price,total,sold
+apples,3,0.5,=B2*C2,2024-03-05
+pears,2,"$1,250.00",=B3*C3,2024-03-06
+`), WithFormulas(), WithTypeInferrer(&TypeInferrer{KeepNumberFormats: true}))
+	require.NoError(t, err)
+
+	require.NoError(t, s.Set(ctx, mustParsePos(t, "F4"), StringValue("=1/0")))
+	require.NoError(t, s.Set(ctx, mustParsePos(t, "A4"), StringValue("a|*b*\nc")))
+	return s
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	var sb strings.Builder
+	require.NoError(t, WriteMarkdown(context.TODO(), &sb, newTableTestSheet(t),
+		WithTableColumnFormat(3, "#,##0.00"),
+		WithTableColumnFormat(4, "dd/mm/yyyy")))
+
+	assert.Equal(t, `| item          | quantity |     price |    total |       sold |         |
+| ------------- | -------: | --------: | -------: | ---------: | :-----: |
+| apples        |        3 |       0.5 |     1.50 | 05/03/2024 |         |
+| pears         |        2 | $1,250.00 | 2,500.00 | 06/03/2024 |         |
+| a\|\*b\*<br>c |          |           |          |            | #DIV/0! |
+`, sb.String())
+}
+
+func TestWriteMarkdown_Coordinates(t *testing.T) {
+	r, err := ParseRange("B2:D")
+	require.NoError(t, err)
+
+	var sb strings.Builder
+	require.NoError(t, WriteMarkdown(context.TODO(), &sb, newTableTestSheet(t),
+		WithTableRange(r), WithCoordinates()))
+
+	assert.Equal(t, `|     |   B |         C |    D |
+| --: | --: | --------: | ---: |
+|   2 |   3 |       0.5 |  1.5 |
+|   3 |   2 | $1,250.00 | 2500 |
+|   4 |     |           |      |
+`, sb.String())
+}
+
+func TestWriteHTML(t *testing.T) {
+	var sb strings.Builder
+	require.NoError(t, WriteHTML(context.TODO(), &sb, newTableTestSheet(t),
+		WithTableColumnFormat(3, "#,##0.00"), WithMaxColumns(4)))
+
+	assert.Equal(t, `<table>
+<thead>
+<tr><th>item</th><th>quantity</th><th>price</th><th>total</th><th style="text-align: center">…</th></tr>
+</thead>
+<tbody>
+<tr><td>apples</td><td style="text-align: right">3</td><td style="text-align: right">0.5</td>`+
+		`<td style="text-align: right">1.50</td><td style="text-align: center">…</td></tr>
+<tr><td>pears</td><td style="text-align: right">2</td><td style="text-align: right">$1,250.00</td>`+
+		`<td style="text-align: right">2,500.00</td><td style="text-align: center">…</td></tr>
+<tr><td>a|*b*<br>c</td><td></td><td></td><td></td><td style="text-align: center">…</td></tr>
+</tbody>
+</table>
+`, sb.String())
+}
+
+func TestWriteHTML_Coordinates(t *testing.T) {
+	s, err := NewInMemorySheet([][]Value{
+		{StringValue("<b>&</b>"), BoolValue(true)},
+		{Float64Value(1), Float64Value(2)},
+		{Float64Value(3), Float64Value(4)},
+	})
+	require.NoError(t, err)
+
+	var sb strings.Builder
+	require.NoError(t, WriteHTML(context.TODO(), &sb, s, WithCoordinates(), WithMaxRows(2)))
+
+	assert.Equal(t, `<table>
+<thead>
+<tr><th></th><th style="text-align: center">A</th><th style="text-align: center">B</th></tr>
+</thead>
+<tbody>
+<tr><th style="text-align: right">1</th><td>&lt;b&gt;&amp;&lt;/b&gt;</td>`+
+		`<td style="text-align: center">TRUE</td></tr>
+<tr><th style="text-align: right">2</th><td style="text-align: right">1</td>`+
+		`<td style="text-align: right">2</td></tr>
+<tr><th style="text-align: center">…</th><td style="text-align: center">…</td>`+
+		`<td style="text-align: center">…</td></tr>
+</tbody>
+</table>
+`, sb.String())
+}
+
+func TestWriteText(t *testing.T) {
+	var sb strings.Builder
+	require.NoError(t, WriteText(context.TODO(), &sb, newTableTestSheet(t),
+		WithTableColumnFormat(4, "yyyy-mm-dd"), WithMaxCellWidth(6)))
+
+	assert.Equal(t, `+--------+--------+--------+-------+--------+--------+
+| item   | quant… | price  | total | sold   |        |
++--------+--------+--------+-------+--------+--------+
+| apples |      3 |    0.5 |   1.5 | 2024-… |        |
+| pears  |      2 | $1,25… |  2500 | 2024-… |        |
+| a|*b*… |        |        |       |        | #DIV/… |
++--------+--------+--------+-------+--------+--------+
+`, sb.String())
+}
+
+func TestWriteText_Coordinates(t *testing.T) {
+	r, err := ParseRange("A1:B2")
+	require.NoError(t, err)
+
+	var sb strings.Builder
+	require.NoError(t, WriteText(context.TODO(), &sb, newTableTestSheet(t),
+		WithTableRange(r), WithCoordinates()))
+
+	assert.Equal(t, `+---+--------+----------+
+|   |   A    |    B     |
++---+--------+----------+
+| 1 | item   | quantity |
+| 2 | apples |        3 |
++---+--------+----------+
+`, sb.String())
+}
+
+func TestWriteTable_Empty(t *testing.T) {
+	ctx := context.TODO()
+	s := newTableTestSheet(t)
+	outside := Range{StartRow: 10, EndRow: 12, StartCol: 0, EndCol: 2}
+
+	var sb strings.Builder
+	require.NoError(t, WriteMarkdown(ctx, &sb, s, WithTableRange(outside)))
+	require.NoError(t, WriteText(ctx, &sb, s, WithTableRange(outside)))
+	assert.Empty(t, sb.String())
+
+	require.NoError(t, WriteHTML(ctx, &sb, s, WithTableRange(outside)))
+	assert.Equal(t, "<table>\n</table>\n", sb.String())
+
+	// Rows and columns left out are marked with ellipses
+	sb.Reset()
+	require.NoError(t, WriteText(ctx, &sb, s, WithMaxRows(1), WithMaxColumns(1)))
+	assert.Equal(t, `+------+---+
+| item | … |
++------+---+
+|  …   | … |
++------+---+
+`, sb.String())
+}
+
+func TestWriteTable_InvalidOptions(t *testing.T) {
+	ctx := context.TODO()
+	s := newTableTestSheet(t)
+
+	err := WriteText(ctx, &strings.Builder{}, s, WithTableColumnFormat(1, `"unterminated`))
+	assert.EqualError(t, err,
+		`invalid format for column B: invalid number format '"unterminated': unterminated string`)
+
+	err = WriteMarkdown(ctx, &strings.Builder{}, s, WithMaxRows(-1))
+	assert.EqualError(t, err, "invalid maximum rows -1")
+
+	err = WriteHTML(ctx, &strings.Builder{}, s, WithMaxCellWidth(-2))
+	assert.EqualError(t, err, "invalid maximum cell width -2")
+}
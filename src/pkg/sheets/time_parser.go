@@ -0,0 +1,299 @@
+package sheets
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A DateOrder is the order of the day and month in numeric dates such as
+// 01/02/2006.
+type DateOrder int
+
+// Various DateOrders
+const (
+	// MonthFirst reads 01/02/2006 as January 2, as in the United States.
+	MonthFirst DateOrder = iota
+
+	// DayFirst reads 01/02/2006 as February 1, as in most of Europe.
+	DayFirst
+)
+
+// String returns the name of the date order.
+func (o DateOrder) String() string {
+	switch o {
+	case MonthFirst:
+		return "month-first"
+	case DayFirst:
+		return "day-first"
+	default:
+		return fmt.Sprintf("DateOrder(%d)", int(o))
+	}
+}
+
+// Pseudo-layouts for times that cannot be described by a time.Parse layout.
+// They can be used as the Layout of a ColumnSpec or in TimeParser.Layouts.
+const (
+	// ISOWeekLayout parses ISO 8601 week dates such as 2024-W10-2 (the
+	// Tuesday of the tenth week of 2024), 2024W102, or 2024-W10 (the Monday
+	// of the week).
+	ISOWeekLayout = "ISOWeek"
+
+	// UnixSecondsLayout parses the number of seconds since 1970-01-01 UTC,
+	// which may include a fraction.
+	UnixSecondsLayout = "UnixSeconds"
+)
+
+var (
+	// neutralTimeLayouts are the built-in layouts that do not depend on the
+	// DateOrder, tried first.
+	neutralTimeLayouts = []string{
+		time.RFC3339,
+		"2006-01-02T15:04:05",
+		"2006-01-02 15:04:05Z07:00",
+		"2006-01-02 15:04:05 -0700",
+		time.DateOnly,
+		time.DateTime,
+		"2006-01-02T15:04Z07:00",
+		"2006-01-02T15:04",
+		"2006-01-02 15:04",
+		ISOWeekLayout,
+		time.UnixDate,
+		time.RFC1123Z,
+		time.Kitchen,
+		"2006/01/02",
+		"2006/01/02 15:04:05",
+		"Jan 2, 2006",
+		"January 2, 2006",
+		"Mon, Jan 2, 2006",
+		"2 Jan 2006",
+		"2 January 2006",
+		"02-Jan-2006",
+		"02-Jan-06",
+		"02.01.2006",
+	}
+
+	// monthFirstTimeLayouts and dayFirstTimeLayouts are the built-in layouts
+	// of numeric dates, each of which is the same as the layout at the same
+	// index in the other list with the day and month swapped.
+	monthFirstTimeLayouts = []string{
+		"1/2/2006",
+		"1/2/06",
+		"1/2/2006 15:04:05",
+		"1/2/2006 15:04",
+		"1/2/2006 3:04 PM",
+		"1-2-2006",
+	}
+
+	dayFirstTimeLayouts = []string{
+		"2/1/2006",
+		"2/1/06",
+		"2/1/2006 15:04:05",
+		"2/1/2006 15:04",
+		"2/1/2006 3:04 PM",
+		"2-1-2006",
+	}
+
+	// trailingTimeLayouts are tried after the numeric dates.
+	trailingTimeLayouts = []string{
+		"01/06",
+	}
+)
+
+// A TimeParser parses text as dates and times by trying a list of layouts in
+// order, returning the result of the first that matches. Custom layouts are
+// tried first, then the built-in layouts, with numeric dates tried in the
+// preferred DateOrder before the other. Fractional seconds are accepted after
+// the seconds of any layout.
+type TimeParser struct {
+	// Order is the preferred order of the day and month in numeric dates.
+	// Dates that are only valid in the other order, such as 13/01/2024 when
+	// MonthFirst is preferred, are read in that order.
+	Order DateOrder
+
+	// Layouts are custom time.Parse layouts, or the pseudo-layouts
+	// ISOWeekLayout and UnixSecondsLayout, tried in order before the built-in
+	// layouts.
+	Layouts []string
+}
+
+// DefaultTimeParser is the TimeParser used by ParseTime, StringToValue, and
+// TypeInferrers without a TimeParser of their own. Layouts registered with it
+// apply to all of these; it must not be changed while text is being parsed.
+var DefaultTimeParser = &TimeParser{}
+
+// RegisterLayout adds custom layouts, which are tried after any that were
+// registered before them but before the built-in layouts.
+func (p *TimeParser) RegisterLayout(layouts ...string) {
+	p.Layouts = append(p.Layouts, layouts...)
+}
+
+// Parse parses text as a time. Times without an explicit offset are in UTC.
+func (p *TimeParser) Parse(s string) (time.Time, error) {
+	return p.ParseIn(s, time.UTC)
+}
+
+// ParseIn parses text as a time. Times with an explicit offset keep that
+// offset, while times without one are wall-clock times in the given location.
+func (p *TimeParser) ParseIn(s string, loc *time.Location) (time.Time, error) {
+	for _, layout := range p.layouts() {
+		if tm, err := parseTimeLayout(layout, s, loc); err == nil {
+			return tm, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("'%s' cannot be parsed as a date or time", s)
+}
+
+// IsAmbiguous returns true if the text is a numeric date that is valid, and
+// different, with the day and month in either order, such as 01/02/2006.
+func (p *TimeParser) IsAmbiguous(s string) bool {
+	for i, layout := range monthFirstTimeLayouts {
+		monthFirst, err := time.Parse(layout, s)
+		if err != nil {
+			continue
+		}
+
+		dayFirst, err := time.Parse(dayFirstTimeLayouts[i], s)
+		return err == nil && !dayFirst.Equal(monthFirst)
+	}
+
+	return false
+}
+
+// inferLayout returns the first layout that can parse every field, and whether
+// the fields are ambiguous: every field can also be parsed with the same layout
+// with the day and month swapped, and at least one is read differently.
+func (p *TimeParser) inferLayout(fields []string) (layout string, ambiguous bool, ok bool) {
+	for _, layout := range p.layouts() {
+		if layout == UnixSecondsLayout {
+			continue
+		}
+
+		if !all(fields, func(s string) bool { _, err := parseTimeLayout(layout, s, time.UTC); return err == nil }) {
+			continue
+		}
+
+		swapped := swappedTimeLayout(layout)
+		if swapped == "" {
+			return layout, false, true
+		}
+
+		differs := false
+		for _, field := range fields {
+			tm, _ := time.Parse(layout, field)
+			other, err := time.Parse(swapped, field)
+			if err != nil {
+				return layout, false, true
+			}
+
+			differs = differs || !other.Equal(tm)
+		}
+
+		return layout, differs, true
+	}
+
+	return "", false, false
+}
+
+// layouts returns the layouts to try, in order.
+func (p *TimeParser) layouts() []string {
+	preferred, other := monthFirstTimeLayouts, dayFirstTimeLayouts
+	if p.Order == DayFirst {
+		preferred, other = other, preferred
+	}
+
+	layouts := make([]string, 0, len(p.Layouts)+len(neutralTimeLayouts)+
+		len(preferred)+len(other)+len(trailingTimeLayouts))
+	layouts = append(layouts, p.Layouts...)
+	layouts = append(layouts, neutralTimeLayouts...)
+	layouts = append(layouts, preferred...)
+	layouts = append(layouts, other...)
+	return append(layouts, trailingTimeLayouts...)
+}
+
+// swappedTimeLayout returns the built-in numeric date layout with the day and
+// month swapped, or "" if the layout is not one.
+func swappedTimeLayout(layout string) string {
+	for i := range monthFirstTimeLayouts {
+		switch layout {
+		case monthFirstTimeLayouts[i]:
+			return dayFirstTimeLayouts[i]
+		case dayFirstTimeLayouts[i]:
+			return monthFirstTimeLayouts[i]
+		}
+	}
+
+	return ""
+}
+
+// parseTimeLayout parses text using a time.Parse layout or one of the
+// pseudo-layouts.
+func parseTimeLayout(layout, s string, loc *time.Location) (time.Time, error) {
+	switch layout {
+	case ISOWeekLayout:
+		return parseISOWeek(s, loc)
+	case UnixSecondsLayout:
+		return parseUnixSeconds(s, loc)
+	default:
+		return time.ParseInLocation(layout, s, loc)
+	}
+}
+
+var isoWeekDate = regexp.MustCompile(`^(\d{4})-?W(\d{2})(?:-?([1-7]))?$`)
+
+// parseISOWeek parses an ISO 8601 week date. Week 1 is the week containing the
+// first Thursday of the year, and weeks start on Monday.
+func parseISOWeek(s string, loc *time.Location) (time.Time, error) {
+	m := isoWeekDate.FindStringSubmatch(s)
+	if m == nil {
+		return time.Time{}, fmt.Errorf("'%s' is not an ISO week date", s)
+	}
+
+	year, _ := strconv.Atoi(m[1])
+	week, _ := strconv.Atoi(m[2])
+	day := 1
+	if m[3] != "" {
+		day, _ = strconv.Atoi(m[3])
+	}
+
+	// January 4th is always in week 1
+	jan4 := time.Date(year, time.January, 4, 0, 0, 0, 0, loc)
+	week1 := jan4.AddDate(0, 0, -((int(jan4.Weekday()) + 6) % 7))
+	tm := week1.AddDate(0, 0, (week-1)*7+day-1)
+
+	if isoYear, isoWeek := tm.ISOWeek(); isoYear != year || isoWeek != week {
+		return time.Time{}, fmt.Errorf("'%s' is not a valid ISO week date", s)
+	}
+
+	return tm, nil
+}
+
+var unixSeconds = regexp.MustCompile(`^(-?[0-9]+)(?:\.([0-9]{1,9}))?$`)
+
+// parseUnixSeconds parses a number of seconds since the Unix epoch, returning
+// the time in the given location. Fractions are parsed exactly, to the
+// nanosecond.
+func parseUnixSeconds(s string, loc *time.Location) (time.Time, error) {
+	m := unixSeconds.FindStringSubmatch(s)
+	if m == nil {
+		return time.Time{}, fmt.Errorf("'%s' is not a number of seconds", s)
+	}
+
+	secs, err := strconv.ParseInt(m[1], 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("'%s' is not a number of seconds", s)
+	}
+
+	var nanos int64
+	if m[2] != "" {
+		nanos, _ = strconv.ParseInt(m[2]+strings.Repeat("0", 9-len(m[2])), 10, 64)
+		if strings.HasPrefix(m[1], "-") {
+			nanos = -nanos
+		}
+	}
+
+	return time.Unix(secs, nanos).In(loc), nil
+}
@@ -0,0 +1,137 @@
+package sheets
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimeParser_Parse(t *testing.T) {
+	date := func(year int, month time.Month, day, hour, minute, second, nanos int) time.Time {
+		return time.Date(year, month, day, hour, minute, second, nanos, time.UTC)
+	}
+
+	for _, tt := range []struct {
+		order    DateOrder
+		input    string
+		expected time.Time
+	}{
+		{MonthFirst, "2024-03-05", date(2024, time.March, 5, 0, 0, 0, 0)},
+		{MonthFirst, "2024-03-05T08:15", date(2024, time.March, 5, 8, 15, 0, 0)},
+		{MonthFirst, "2024-03-05 08:15", date(2024, time.March, 5, 8, 15, 0, 0)},
+		{MonthFirst, "2024-03-05 08:15:30.25", date(2024, time.March, 5, 8, 15, 30, 250000000)},
+		{MonthFirst, "2024-03-05T08:15:30.123456Z", date(2024, time.March, 5, 8, 15, 30, 123456000)},
+		{MonthFirst, "Mar 5, 2024", date(2024, time.March, 5, 0, 0, 0, 0)},
+		{MonthFirst, "March 5, 2024", date(2024, time.March, 5, 0, 0, 0, 0)},
+		{MonthFirst, "5 March 2024", date(2024, time.March, 5, 0, 0, 0, 0)},
+		{MonthFirst, "05-Mar-2024", date(2024, time.March, 5, 0, 0, 0, 0)},
+		{MonthFirst, "2024-W10-2", date(2024, time.March, 5, 0, 0, 0, 0)},
+		{MonthFirst, "2024W102", date(2024, time.March, 5, 0, 0, 0, 0)},
+		{MonthFirst, "2024-W10", date(2024, time.March, 4, 0, 0, 0, 0)},
+		{MonthFirst, "2020-W53-5", date(2021, time.January, 1, 0, 0, 0, 0)},
+		{MonthFirst, "2025-W01-1", date(2024, time.December, 30, 0, 0, 0, 0)},
+
+		// Numeric dates are read in the preferred order if possible
+		{MonthFirst, "03/05/2024", date(2024, time.March, 5, 0, 0, 0, 0)},
+		{DayFirst, "03/05/2024", date(2024, time.May, 3, 0, 0, 0, 0)},
+		{MonthFirst, "3/5/24", date(2024, time.March, 5, 0, 0, 0, 0)},
+		{DayFirst, "3/5/24", date(2024, time.May, 3, 0, 0, 0, 0)},
+		{MonthFirst, "13/05/2024", date(2024, time.May, 13, 0, 0, 0, 0)},
+		{DayFirst, "05/13/2024", date(2024, time.May, 13, 0, 0, 0, 0)},
+		{DayFirst, "03/05/2024 17:45", date(2024, time.May, 3, 17, 45, 0, 0)},
+		{MonthFirst, "3/5/2024 5:45 PM", date(2024, time.March, 5, 17, 45, 0, 0)},
+		{MonthFirst, "05.03.2024", date(2024, time.March, 5, 0, 0, 0, 0)},
+	} {
+		t.Run(tt.order.String()+"/"+tt.input, func(t *testing.T) {
+			tm, err := (&TimeParser{Order: tt.order}).Parse(tt.input)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, tm)
+		})
+	}
+}
+
+func TestTimeParser_Errors(t *testing.T) {
+	p := &TimeParser{}
+	for _, input := range []string{
+		"2024-W54-1",
+		"2024-W10-8",
+		"13/13/2024",
+		"1709596800",
+		"text",
+	} {
+		t.Run(input, func(t *testing.T) {
+			_, err := p.Parse(input)
+			assert.EqualError(t, err, "'"+input+"' cannot be parsed as a date or time")
+		})
+	}
+}
+
+func TestTimeParser_Layouts(t *testing.T) {
+	p := &TimeParser{}
+	p.RegisterLayout("20060102", UnixSecondsLayout)
+
+	tm, err := p.Parse("20240305")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, time.March, 5, 0, 0, 0, 0, time.UTC), tm)
+
+	tm, err = p.Parse("1709596800.5")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, time.March, 5, 0, 0, 0, 500000000, time.UTC), tm)
+
+	tm, err = p.Parse("-1.25")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(1969, time.December, 31, 23, 59, 58, 750000000, time.UTC), tm)
+
+	// Custom layouts are tried before the built-in layouts
+	p = &TimeParser{Layouts: []string{"06-01-02"}}
+	tm, err = p.Parse("24-03-05")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, time.March, 5, 0, 0, 0, 0, time.UTC), tm)
+}
+
+func TestTimeParser_IsAmbiguous(t *testing.T) {
+	p := &TimeParser{}
+	assert.True(t, p.IsAmbiguous("03/05/2024"))
+	assert.True(t, p.IsAmbiguous("3/5/2024 10:30"))
+	assert.False(t, p.IsAmbiguous("05/05/2024"))
+	assert.False(t, p.IsAmbiguous("13/05/2024"))
+	assert.False(t, p.IsAmbiguous("2024-03-05"))
+}
+
+func TestInferSchema_AmbiguousDates(t *testing.T) {
+	records := [][]string{
+		{"01/02/2024", "01/02/2024", "01/02/2024", "2024-W01"},
+		{"03/05/2024", "03/05/2024", "03/15/2024", "2024-W02-3"},
+		{"04/04/2024", "25/05/2024", "04/04/2024", "2024-W52-7"},
+	}
+
+	assert.Equal(t, map[int]ColumnSpec{
+		0: {Type: TimeColumn, Layout: "1/2/2006", Ambiguous: true},
+		1: {Type: TimeColumn, Layout: "2/1/2006"},
+		2: {Type: TimeColumn, Layout: "1/2/2006"},
+		3: {Type: TimeColumn, Layout: ISOWeekLayout},
+	}, InferSchema(records, 0, 0))
+
+	assert.Equal(t, map[int]ColumnSpec{
+		0: {Type: TimeColumn, Layout: "2/1/2006", Ambiguous: true},
+		1: {Type: TimeColumn, Layout: "2/1/2006"},
+		2: {Type: TimeColumn, Layout: "1/2/2006"},
+		3: {Type: TimeColumn, Layout: ISOWeekLayout},
+	}, inferSchema(records, 0, 0, EnglishLocale, &TimeParser{Order: DayFirst}))
+}
+
+func TestReadCSV_WithTimeParser(t *testing.T) {
+	s, err := ReadCSV(strings.NewReader("03/05/2024,13/05/2024,2024-W10-2\n"), WithTypeInferrer(&TypeInferrer{
+		TimeParser: &TimeParser{Order: DayFirst},
+	}))
+	require.NoError(t, err)
+
+	assertCells(t, s, map[string]Value{
+		"A1": TimeValue(time.Date(2024, time.May, 3, 0, 0, 0, 0, time.UTC)),
+		"B1": TimeValue(time.Date(2024, time.May, 13, 0, 0, 0, 0, time.UTC)),
+		"C1": TimeValue(time.Date(2024, time.March, 5, 0, 0, 0, 0, time.UTC)),
+	})
+}
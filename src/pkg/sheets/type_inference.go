@@ -0,0 +1,326 @@
+package sheets
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// A ColumnType is the type of the values in a column.
+type ColumnType int
+
+// Various ColumnTypes
+const (
+	// AutoColumn infers the type of each value in the column.
+	AutoColumn ColumnType = iota
+	TextColumn
+	NumberColumn
+	BoolColumn
+	TimeColumn
+)
+
+// String returns the name of the column type.
+func (t ColumnType) String() string {
+	switch t {
+	case AutoColumn:
+		return "auto"
+	case TextColumn:
+		return "text"
+	case NumberColumn:
+		return "number"
+	case BoolColumn:
+		return "bool"
+	case TimeColumn:
+		return "time"
+	default:
+		return fmt.Sprintf("ColumnType(%d)", int(t))
+	}
+}
+
+// A ColumnSpec describes how the text in a column is converted into Values.
+type ColumnSpec struct {
+	Type ColumnType
+
+	// Layout is the time.Parse layout, or TimeParser pseudo-layout, used for
+	// TimeColumns. If empty, the layouts of the TypeInferrer's TimeParser are
+	// tried in turn.
+	Layout string
+
+	// Ambiguous is set by InferSchema for TimeColumns whose dates can be read
+	// with the day and month in either order, such as 01/02/2006. The Layout
+	// reads them in the TimeParser's preferred DateOrder.
+	Ambiguous bool
+}
+
+// A TypeInferrer converts text, such as the fields of a CSV file, into Values.
+// By default it behaves like StringToValue, trying times, then booleans, then
+// numbers, and falling back to text.
+type TypeInferrer struct {
+	// Strict disables guessing: text is never inferred to be a time, and is
+	// only inferred to be a number if it is written as a plain decimal without
+	// leading zeros or an exponent, or with the currency symbols, percentages,
+	// thousands separators or accounting negatives understood by ParseNumber.
+	// Values such as zip codes ("02134") and identifiers ("1e5") are kept as
+	// text.
+	Strict bool
+
+	// HeaderRows is the number of rows at the start of the data that are
+	// always read as text.
+	HeaderRows int
+
+	// Columns overrides the type of specific columns, keyed by column index.
+	Columns map[int]ColumnSpec
+
+	// KeepNumberFormats converts numbers written with currency symbols,
+	// percentages, thousands separators or accounting negatives into
+	// FormattedNumbers that record how they were written, rather than into
+	// plain Float64Values.
+	KeepNumberFormats bool
+
+	// SampleRows, if positive, infers a ColumnSpec for each column that does
+	// not have one in Columns when a sheet is loaded, by passing that many rows
+	// following the header rows to InferSchema.
+	SampleRows int
+
+	// Locale is the locale in which numbers and booleans are written. If nil,
+	// the EnglishLocale is used.
+	Locale *Locale
+
+	// Location is the time zone of times written without an explicit offset.
+	// If nil, UTC is used.
+	Location *time.Location
+
+	// TimeParser parses text as times. If nil, the DefaultTimeParser is used.
+	TimeParser *TimeParser
+}
+
+// strictNumber matches the numbers that are inferred in strict mode.
+var strictNumber = regexp.MustCompile(`^[-+]?(0|[1-9][0-9]*)(\.[0-9]+)?$`)
+
+// Infer converts the text at the given position into a Value. Returns an error
+// if the column has a ColumnSpec and the text cannot be converted to its type.
+func (ti *TypeInferrer) Infer(pos Pos, s string) (Value, error) {
+	if pos.Row < ti.HeaderRows {
+		return StringValue(s), nil
+	}
+
+	loc := localeOrDefault(ti.Locale)
+	spec := ti.Columns[pos.Col]
+	v, err := spec.convert(s, ti)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value at %s: %w", pos, err)
+	}
+
+	if _, isNumber := v.(Float64Value); isNumber && ti.KeepNumberFormats {
+		if n, format, err := loc.ParseNumber(s); err == nil && format != "" {
+			return FormattedNumber{Number: n, Format: format}, nil
+		}
+	}
+
+	return v, nil
+}
+
+// values converts a set of records into Values, inferring column types from a
+// sample of the records if requested. Empty fields are converted to blanks
+// unless emptyAsText is set.
+func (ti *TypeInferrer) values(records [][]string, emptyAsText bool) ([][]Value, error) {
+	if ti.SampleRows > 0 {
+		inferred := inferSchema(records, ti.HeaderRows, ti.SampleRows, localeOrDefault(ti.Locale), ti.timeParser())
+		for col, spec := range ti.Columns {
+			inferred[col] = spec
+		}
+
+		withSchema := *ti
+		withSchema.Columns = inferred
+		ti = &withSchema
+	}
+
+	values := make([][]Value, len(records))
+	for i, record := range records {
+		values[i] = make([]Value, len(record))
+		for j, field := range record {
+			if field == "" && !emptyAsText {
+				values[i][j] = BlankValue{}
+				continue
+			}
+
+			v, err := ti.Infer(Pos{Row: i, Col: j}, field)
+			if err != nil {
+				return nil, err
+			}
+
+			values[i][j] = v
+		}
+	}
+
+	return values, nil
+}
+
+func (ti *TypeInferrer) location() *time.Location {
+	if ti.Location == nil {
+		return time.UTC
+	}
+
+	return ti.Location
+}
+
+func (ti *TypeInferrer) timeParser() *TimeParser {
+	if ti.TimeParser == nil {
+		return DefaultTimeParser
+	}
+
+	return ti.TimeParser
+}
+
+func (spec ColumnSpec) convert(s string, ti *TypeInferrer) (Value, error) {
+	loc, tz := localeOrDefault(ti.Locale), ti.location()
+	switch spec.Type {
+	case TextColumn:
+		return StringValue(s), nil
+	case NumberColumn:
+		n, _, err := loc.ParseNumber(s)
+		if err != nil {
+			return nil, err
+		}
+
+		return Float64Value(n), nil
+	case BoolColumn:
+		b, err := loc.parseBool(s)
+		if err != nil {
+			return nil, err
+		}
+
+		return BoolValue(b), nil
+	case TimeColumn:
+		if spec.Layout == "" {
+			tm, err := ti.timeParser().ParseIn(s, tz)
+			if err != nil {
+				return nil, err
+			}
+
+			return TimeValue(tm), nil
+		}
+
+		tm, err := parseTimeLayout(spec.Layout, s, tz)
+		if err != nil {
+			return nil, fmt.Errorf("'%s' does not match the time layout '%s'", s, spec.Layout)
+		}
+
+		return TimeValue(tm), nil
+	default:
+		if !ti.Strict {
+			return loc.parseValue(s, ti.timeParser(), tz), nil
+		}
+
+		if b, err := loc.parseBool(s); err == nil {
+			return BoolValue(b), nil
+		}
+
+		if canonical, ok := loc.canonicalNumber(s); ok {
+			if n, ok := parseStrictNumber(canonical); ok {
+				return Float64Value(n), nil
+			}
+		}
+
+		return StringValue(s), nil
+	}
+}
+
+// parseStrictNumber parses numbers that are written as plain decimals without
+// leading zeros or an exponent, or that have the currency symbols, percentages,
+// thousands separators or accounting negatives understood by ParseNumber.
+func parseStrictNumber(s string) (float64, bool) {
+	if strictNumber.MatchString(s) {
+		n, err := strconv.ParseFloat(s, 64)
+		return n, err == nil
+	}
+
+	if n, format, err := ParseNumber(s); err == nil && format != "" {
+		return n, true
+	}
+
+	return 0, false
+}
+
+// InferSchema proposes a ColumnSpec for each column by sampling up to
+// sampleRows records following the header rows, or all of them if sampleRows
+// is not positive. A column is proposed as a BoolColumn or NumberColumn if
+// every sampled value is a boolean or a strict number (see
+// TypeInferrer.Strict), and as a TimeColumn if every sampled value can be
+// parsed with the same layout of the DefaultTimeParser. Otherwise it is a
+// TextColumn. Columns with no non-empty sampled values are omitted.
+func InferSchema(records [][]string, headerRows, sampleRows int) map[int]ColumnSpec {
+	return inferSchema(records, headerRows, sampleRows, EnglishLocale, DefaultTimeParser)
+}
+
+// inferSchema proposes a ColumnSpec for each column, recognizing booleans and
+// numbers as they are written in the given locale.
+func inferSchema(records [][]string, headerRows, sampleRows int, loc *Locale, parser *TimeParser) map[int]ColumnSpec {
+	if headerRows > len(records) {
+		headerRows = len(records)
+	}
+
+	sample := records[headerRows:]
+	if sampleRows > 0 && sampleRows < len(sample) {
+		sample = sample[:sampleRows]
+	}
+
+	var columns [][]string
+	for _, record := range sample {
+		for col, field := range record {
+			for len(columns) <= col {
+				columns = append(columns, nil)
+			}
+
+			if field != "" {
+				columns[col] = append(columns[col], field)
+			}
+		}
+	}
+
+	schema := make(map[int]ColumnSpec, len(columns))
+	for col, fields := range columns {
+		if len(fields) != 0 {
+			schema[col] = inferColumnSpec(fields, loc, parser)
+		}
+	}
+
+	return schema
+}
+
+func inferColumnSpec(fields []string, loc *Locale, parser *TimeParser) ColumnSpec {
+	if all(fields, func(s string) bool { _, err := loc.parseBool(s); return err == nil }) {
+		return ColumnSpec{Type: BoolColumn}
+	}
+
+	isNumber := func(s string) bool {
+		canonical, ok := loc.canonicalNumber(s)
+		if !ok {
+			return false
+		}
+
+		_, ok = parseStrictNumber(canonical)
+		return ok
+	}
+
+	if all(fields, isNumber) {
+		return ColumnSpec{Type: NumberColumn}
+	}
+
+	if layout, ambiguous, ok := parser.inferLayout(fields); ok {
+		return ColumnSpec{Type: TimeColumn, Layout: layout, Ambiguous: ambiguous}
+	}
+
+	return ColumnSpec{Type: TextColumn}
+}
+
+func all(fields []string, fn func(s string) bool) bool {
+	for _, field := range fields {
+		if !fn(field) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,157 @@
+package sheets
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mmihic/golib/src/pkg/timex"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTypeInferrer_Infer(t *testing.T) {
+	ti := &TypeInferrer{
+		HeaderRows: 1,
+		Columns: map[int]ColumnSpec{
+			1: {Type: TextColumn},
+			3: {Type: TimeColumn, Layout: "02/01/2006"},
+			4: {Type: NumberColumn},
+			5: {Type: BoolColumn},
+		},
+	}
+
+	strict := &TypeInferrer{Strict: true, Columns: ti.Columns}
+
+	for _, tt := range []struct {
+		pos            string
+		s              string
+		inferred       Value
+		strictInferred Value
+	}{
+		{"A2", "01/06", TimeValue(timex.MustParseTime(time.RFC3339, "2006-01-01T00:00:00Z")), StringValue("01/06")},
+		{"A2", "02134", Float64Value(2134), StringValue("02134")},
+		{"A2", "1e5", Float64Value(100000), StringValue("1e5")},
+		{"A2", "-12.5", Float64Value(-12.5), Float64Value(-12.5)},
+		{"A2", "0.25", Float64Value(0.25), Float64Value(0.25)},
+		{"A2", "true", BoolValue(true), BoolValue(true)},
+		{"A2", "apple", StringValue("apple"), StringValue("apple")},
+		{"B2", "02134", StringValue("02134"), StringValue("02134")},
+		{"D2", "02/01/2006", TimeValue(timex.MustParseTime(time.RFC3339, "2006-01-02T00:00:00Z")),
+			TimeValue(timex.MustParseTime(time.RFC3339, "2006-01-02T00:00:00Z"))},
+		{"E2", "1e5", Float64Value(100000), Float64Value(100000)},
+		{"F2", "FALSE", BoolValue(false), BoolValue(false)},
+	} {
+		t.Run(tt.pos+" "+tt.s, func(t *testing.T) {
+			pos := mustParsePos(t, tt.pos)
+
+			v, err := ti.Infer(pos, tt.s)
+			require.NoError(t, err)
+			assert.Equal(t, tt.inferred, v)
+
+			v, err = strict.Infer(pos, tt.s)
+			require.NoError(t, err)
+			assert.Equal(t, tt.strictInferred, v)
+		})
+	}
+
+	// Header rows are always text
+	v, err := ti.Infer(mustParsePos(t, "E1"), "amount")
+	require.NoError(t, err)
+	assert.Equal(t, StringValue("amount"), v)
+
+	// Values that don't match the column type are errors
+	for _, tt := range []struct {
+		pos, s, err string
+	}{
+		{"D2", "2006-01-02", "invalid value at D2: '2006-01-02' does not match the time layout '02/01/2006'"},
+		{"E3", "ten", "invalid value at E3: 'ten' is not a valid number"},
+		{"F4", "yes", "invalid value at F4: 'yes' is not a valid boolean value"},
+	} {
+		_, err := ti.Infer(mustParsePos(t, tt.pos), tt.s)
+		assert.EqualError(t, err, tt.err)
+	}
+}
+
+func TestTypeInferrer_KeepNumberFormats(t *testing.T) {
+	ti := &TypeInferrer{KeepNumberFormats: true, Columns: map[int]ColumnSpec{1: {Type: NumberColumn}}}
+
+	for _, tt := range []struct {
+		pos      string
+		s        string
+		expected Value
+	}{
+		{"A1", "$1,234.50", FormattedNumber{Number: 1234.5, Format: "$#,##0.00"}},
+		{"A1", "15%", FormattedNumber{Number: 0.15, Format: "0%"}},
+		{"A1", "12.5", Float64Value(12.5)},
+		{"B1", "(200)", FormattedNumber{Number: -200, Format: "0;(0)"}},
+		{"B1", "200", Float64Value(200)},
+	} {
+		v, err := ti.Infer(mustParsePos(t, tt.pos), tt.s)
+		require.NoError(t, err)
+		assert.Equal(t, tt.expected, v, tt.s)
+	}
+
+	// Formatted numbers behave like numbers in formulas
+	s, err := ReadCSV(strings.NewReader("$1.50,25%,=A1*B1\n"), WithFormulas(), WithTypeInferrer(ti))
+	require.NoError(t, err)
+	assertCells(t, s, map[string]Value{
+		"A1": FormattedNumber{Number: 1.5, Format: "$#,##0.00"},
+		"C1": Float64Value(0.375),
+	})
+}
+
+func TestInferSchema(t *testing.T) {
+	records := [][]string{
+		{"zip", "count", "active", "joined", "id", "notes", "empty"},
+		{"02134", "$12", "TRUE", "2024-01-05", "1e5", "first"},
+		{"94110", "1,207.5", "false", "2024-02-29", "2e7", ""},
+		{"10001", "-3", "TRUE", "2024-03-01", "7a3", "2"},
+		{"x", "bad", "bad", "bad", "bad", "bad"},
+	}
+
+	assert.Equal(t, map[int]ColumnSpec{
+		0: {Type: TextColumn},
+		1: {Type: NumberColumn},
+		2: {Type: BoolColumn},
+		3: {Type: TimeColumn, Layout: time.DateOnly},
+		4: {Type: TextColumn},
+		5: {Type: TextColumn},
+	}, InferSchema(records, 1, 3))
+
+	// Only the sampled rows are considered
+	assert.Equal(t, map[int]ColumnSpec{
+		0: {Type: NumberColumn},
+		1: {Type: NumberColumn},
+	}, InferSchema([][]string{{"94110", "1"}, {"10001", "2"}, {"zip"}}, 0, 2))
+
+	assert.Empty(t, InferSchema(records, 10, 0))
+}
+
+func TestReadCSV_WithTypeInferrer(t *testing.T) {
+	const data = `zip,amount,when
+02134,12,01/06/2024
+94110,7.5,15/06/2024
+`
+
+	s, err := ReadCSV(strings.NewReader(data), WithTypeInferrer(&TypeInferrer{
+		HeaderRows: 1,
+		SampleRows: 10,
+		Columns: map[int]ColumnSpec{
+			2: {Type: TimeColumn, Layout: "02/01/2006"},
+		},
+	}))
+	require.NoError(t, err)
+	assertCells(t, s, map[string]Value{
+		"A1": StringValue("zip"),
+		"A2": StringValue("02134"),
+		"B2": Float64Value(12),
+		"C2": TimeValue(timex.MustParseTime(time.RFC3339, "2024-06-01T00:00:00Z")),
+		"C3": TimeValue(timex.MustParseTime(time.RFC3339, "2024-06-15T00:00:00Z")),
+	})
+
+	_, err = ReadCSV(strings.NewReader(data), WithTypeInferrer(&TypeInferrer{
+		Columns: map[int]ColumnSpec{1: {Type: NumberColumn}},
+	}))
+	assert.EqualError(t, err, "invalid value at B1: 'amount' is not a valid number")
+}
@@ -25,10 +25,31 @@ type (
 	BoolValue    bool
 )
 
+// FormattedNumber is a number along with the Excel number format it should be
+// displayed with, such as a currency amount or percentage parsed from text.
+// It behaves like a Float64Value in formulas.
+type FormattedNumber struct {
+	Number float64
+	Format string
+}
+
+// BlankValue is the value of a cell that has never been given a value, which
+// is distinct from a cell containing empty text. Following Excel, blank cells
+// are treated as 0 in arithmetic and as "" when used as text, and are skipped
+// by most functions that take ranges.
+type BlankValue struct{}
+
 // StringToValue converts a string into a Value, using the most optimal Value
-// representation.
+// representation. Use a TypeInferrer for more control over the conversion.
 func StringToValue(s string) Value {
-	if tm, err := ParseTime(s); err == nil {
+	return stringToValueIn(s, time.UTC)
+}
+
+// stringToValueIn converts a string into a Value in the same way as
+// StringToValue, treating times without an explicit offset as wall-clock times
+// in the given location.
+func stringToValueIn(s string, loc *time.Location) Value {
+	if tm, err := ParseTimeIn(s, loc); err == nil {
 		return TimeValue(tm)
 	}
 
@@ -40,11 +61,17 @@ func StringToValue(s string) Value {
 		return Float64Value(n)
 	}
 
+	if n, _, err := ParseNumber(s); err == nil {
+		return Float64Value(n)
+	}
+
 	return StringValue(s)
 }
 
+// String returns the number as Excel would display it, with at most 15
+// significant digits. Use NumericMode.Format to display it in another mode.
 func (v Float64Value) String() string {
-	return strconv.FormatFloat(float64(v), 'f', -1, 64)
+	return ExcelNumerics.Format(float64(v))
 }
 
 func (v Float64Value) valueMarker() {}
@@ -54,6 +81,17 @@ func (v Float64Value) ToFloat64() (float64, error) {
 	return float64(v), nil
 }
 
+func (v FormattedNumber) valueMarker() {}
+
+// ToFloat64 converts the value to a float64.
+func (v FormattedNumber) ToFloat64() (float64, error) {
+	return v.Number, nil
+}
+
+func (v FormattedNumber) String() string {
+	return Float64Value(v.Number).String()
+}
+
 func (v TimeValue) valueMarker() {}
 
 // ToFloat64 converts the value to a float64.
@@ -69,18 +107,32 @@ func (v StringValue) valueMarker() {}
 
 // ToFloat64 converts the value to a float64.
 func (v StringValue) ToFloat64() (float64, error) {
-	n, err := strconv.ParseFloat(string(v), 64)
-	if err != nil {
-		return 0, ValueErrorf("unable to convert '%s' to float", v)
+	if n, err := strconv.ParseFloat(string(v), 64); err == nil {
+		return n, nil
 	}
 
-	return n, nil
+	if n, _, err := ParseNumber(string(v)); err == nil {
+		return n, nil
+	}
+
+	return 0, ValueErrorf("unable to convert '%s' to float", v)
 }
 
 func (v StringValue) String() string {
 	return string(v)
 }
 
+func (v BlankValue) valueMarker() {}
+
+// ToFloat64 converts the value to a float64, which is always 0.
+func (v BlankValue) ToFloat64() (float64, error) {
+	return 0, nil
+}
+
+func (v BlankValue) String() string {
+	return ""
+}
+
 func (v BoolValue) valueMarker() {}
 
 // ToFloat64 converts the value to a float64.
@@ -214,6 +266,8 @@ var (
 	_ Value = Float64Value(100)
 	_ Value = TimeValue(time.Time{})
 	_ Value = StringValue("")
+	_ Value = BlankValue{}
+	_ Value = FormattedNumber{}
 	_ Value = ErrorValue{errors.New("foo")}
 
 	_ ValueIter = &sliceValueIter{}
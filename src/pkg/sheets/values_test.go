@@ -25,6 +25,15 @@ func TestStringToValue(t *testing.T) {
 		{"0", Float64Value(0)},
 		{"3.1462", Float64Value(3.1462)},
 		{"-0.03784", Float64Value(-0.03784)},
+		{"$1,234.50", Float64Value(1234.5)},
+		{"15%", Float64Value(0.15)},
+		{"(200)", Float64Value(-200)},
+		{"1 234.5", Float64Value(1234.5)},
+		{"1,234", Float64Value(1234)},
+		{"1,5", StringValue("1,5")},
+		{"12,34", StringValue("12,34")},
+		{"1 234,5", StringValue("1 234,5")},
+		{"€12", Float64Value(12)},
 		{"12/31/24", TimeValue(timex.MustParseTime(time.RFC3339, "2024-12-31T00:00:00Z"))},
 		{"12/24", TimeValue(timex.MustParseTime(time.RFC3339, "2024-12-01T00:00:00Z"))},
 		{"2024-09-13T12:36:45Z", TimeValue(timex.MustParseTime(time.RFC3339, "2024-09-13T12:36:45Z"))},
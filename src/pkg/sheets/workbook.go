@@ -0,0 +1,682 @@
+package sheets
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+)
+
+// A Workbook is a DataSet made up of named sheets and named ranges. Formula
+// cells in the in-memory sheets of a workbook may refer to cells in any other
+// sheet of the workbook.
+//
+// The workbook tracks the dependencies between formula cells and the cells
+// they refer to, and caches the computed value of each formula cell. Changing
+// a cell only marks the formula cells downstream of it as dirty; dirty cells
+// are recomputed on demand, or in dependency order by Recalculate. Formulas
+// that call volatile functions (such as NOW or RAND) are marked dirty by every
+// Recalculate.
+//
+// Formulas that refer to their own cell, directly or indirectly, evaluate to a
+// CircularReferenceError describing the cycle, unless iterative calculation
+// has been enabled with SetIterativeCalculation.
+type Workbook struct {
+	names       []string
+	sheets      map[string]Sheet
+	namedRanges map[string]NamedRange
+	evaluator   *Evaluator
+	anonymous   bool
+	iterative   IterativeCalculation
+
+	graph    *dependencyGraph
+	dirty    map[CellAddress]struct{}
+	volatile map[CellAddress]struct{}
+
+	// The formula cells currently being computed, used to detect cycles
+	computing  []CellAddress
+	inProgress map[CellAddress]int
+	cyclic     map[CellAddress]struct{}
+	iterating  bool
+	cycleErr   error
+}
+
+// IterativeCalculation controls Excel-style iterative calculation, which allows
+// formulas with circular references to be computed by recomputing the cells
+// in the cycle until their values converge.
+type IterativeCalculation struct {
+	// Enabled turns on iterative calculation.
+	Enabled bool
+
+	// MaxIterations is the maximum number of times the cells in a cycle are
+	// recomputed. Defaults to 100.
+	MaxIterations int
+
+	// MaxChange is the largest change in the value of any cell in a cycle
+	// between iterations for the cycle to be considered converged. Defaults
+	// to 0.001.
+	MaxChange float64
+}
+
+const (
+	defaultMaxIterations = 100
+	defaultMaxChange     = 0.001
+)
+
+// NewWorkbook creates a new, empty Workbook.
+func NewWorkbook() *Workbook {
+	wb := &Workbook{
+		sheets:      make(map[string]Sheet),
+		namedRanges: make(map[string]NamedRange),
+		graph:       newDependencyGraph(),
+		dirty:       make(map[CellAddress]struct{}),
+		volatile:    make(map[CellAddress]struct{}),
+		inProgress:  make(map[CellAddress]int),
+		cyclic:      make(map[CellAddress]struct{}),
+	}
+
+	wb.evaluator = &Evaluator{DataSet: wb}
+	return wb
+}
+
+// newAnonymousWorkbook creates the workbook used by a standalone in-memory sheet.
+func newAnonymousWorkbook(s *inMemorySheet) *Workbook {
+	wb := NewWorkbook()
+	wb.anonymous = true
+	wb.evaluator.Location = s.opts.location
+	wb.attach("", s)
+	return wb
+}
+
+// AddSheet adds a sheet to the workbook under the given name. Sheets created
+// by NewInMemorySheet, NewMutableSheet, or ReadCSV join the workbook, so that
+// their formulas can refer to other sheets in the workbook and are
+// recalculated along with the rest of the workbook; such a sheet can only
+// belong to a single workbook. Any other Sheet is added as a read-only source
+// of data.
+func (wb *Workbook) AddSheet(name string, sheet Sheet) error {
+	if name == "" {
+		return fmt.Errorf("sheet name cannot be empty")
+	}
+
+	if _, exists := wb.sheets[name]; exists {
+		return fmt.Errorf("sheet '%s' already exists", name)
+	}
+
+	if s, ok := sheet.(*inMemorySheet); ok {
+		if !s.workbook.anonymous {
+			return fmt.Errorf("sheet is already part of a workbook as '%s'", s.name)
+		}
+
+		wb.attach(name, s)
+		return nil
+	}
+
+	wb.names = append(wb.names, name)
+	wb.sheets[name] = sheet
+	return nil
+}
+
+// attach makes an in-memory sheet part of the workbook.
+func (wb *Workbook) attach(name string, s *inMemorySheet) {
+	wb.names = append(wb.names, name)
+	wb.sheets[name] = s
+
+	s.name, s.workbook = name, wb
+	for pos, cell := range s.formulas {
+		wb.trackFormula(s, pos, cell)
+	}
+}
+
+// Sheet returns the sheet with the given name, or nil if there is no such sheet.
+func (wb *Workbook) Sheet(name string) Sheet {
+	return wb.sheets[name]
+}
+
+// SheetNames returns the names of the sheets in the workbook, in the order
+// they were added.
+func (wb *Workbook) SheetNames() []string {
+	return append([]string(nil), wb.names...)
+}
+
+// SetNamedRange defines a named range. The formulas that refer to the name are
+// recomputed on the next access.
+func (wb *Workbook) SetNamedRange(name string, r NamedRange) {
+	wb.namedRanges[name] = r
+	for _, addr := range wb.graph.nameDependentsOf(name) {
+		if s, cell, ok := wb.formulaCell(addr); ok {
+			wb.registerFormula(s, addr.Pos, cell)
+			wb.invalidate(addr, true)
+		}
+	}
+}
+
+// NamedRange returns the named range with the given name.
+func (wb *Workbook) NamedRange(name string) (NamedRange, bool) {
+	r, ok := wb.namedRanges[name]
+	return r, ok
+}
+
+// NamedRangeNames returns the names of the named ranges in the workbook, in
+// sorted order.
+func (wb *Workbook) NamedRangeNames() []string {
+	names := make([]string, 0, len(wb.namedRanges))
+	for name := range wb.namedRanges {
+		names = append(names, name)
+	}
+
+	slices.Sort(names)
+	return names
+}
+
+// Evaluator returns the Evaluator used to compute the formulas in the
+// workbook. Call RecalculateAll after changing it.
+func (wb *Workbook) Evaluator() *Evaluator {
+	return wb.evaluator
+}
+
+// SetIterativeCalculation enables or disables iterative calculation. All
+// formulas are recomputed on the next access.
+func (wb *Workbook) SetIterativeCalculation(iterative IterativeCalculation) {
+	if iterative.MaxIterations <= 0 {
+		iterative.MaxIterations = defaultMaxIterations
+	}
+
+	if iterative.MaxChange <= 0 {
+		iterative.MaxChange = defaultMaxChange
+	}
+
+	wb.iterative = iterative
+	wb.invalidateAll()
+}
+
+// SetCollator sets the collator used to compare text in formulas. All formulas
+// are recomputed on the next access.
+func (wb *Workbook) SetCollator(c Collator) {
+	wb.evaluator.Collator = c
+	wb.invalidateAll()
+}
+
+// SetNumericMode sets how numbers are compared and computed in formulas. All
+// formulas are recomputed on the next access.
+func (wb *Workbook) SetNumericMode(mode NumericMode) {
+	wb.evaluator.NumericMode = mode
+	wb.invalidateAll()
+}
+
+// SetDateSystem sets how times are converted to and from serial numbers in
+// formulas. All formulas are recomputed on the next access.
+func (wb *Workbook) SetDateSystem(ds DateSystem) {
+	wb.evaluator.DateSystem = ds
+	wb.invalidateAll()
+}
+
+// SetLocation sets the time zone in which serial numbers are wall-clock times
+// in formulas. All formulas are recomputed on the next access.
+func (wb *Workbook) SetLocation(tz *time.Location) {
+	wb.evaluator.Location = tz
+	wb.invalidateAll()
+}
+
+// Precedents returns the cells and ranges that a formula cell directly refers
+// to, or nil if the cell does not contain a formula.
+func (wb *Workbook) Precedents(addr CellAddress) []RangeAddress {
+	return append([]RangeAddress(nil), wb.graph.precedentsOf(addr)...)
+}
+
+// Dependents returns the formula cells that directly refer to a cell.
+func (wb *Workbook) Dependents(addr CellAddress) []CellAddress {
+	return wb.graph.dependentsOf(addr)
+}
+
+// Recalculate recomputes every dirty formula cell, along with the formula
+// cells that call volatile functions, in dependency order. Returns a
+// CircularReferenceError if any of the recomputed formulas are part of a
+// cycle and iterative calculation is not enabled.
+func (wb *Workbook) Recalculate(ctx context.Context) error {
+	for addr := range wb.volatile {
+		wb.invalidate(addr, true)
+	}
+
+	wb.cycleErr = nil
+	for _, addr := range wb.dirtyInDependencyOrder() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if s, cell, ok := wb.formulaCell(addr); ok && cell.dirty {
+			wb.value(ctx, s, addr.Pos, cell)
+		}
+	}
+
+	return wb.cycleErr
+}
+
+// RecalculateAll marks every formula cell as dirty and recomputes them all.
+func (wb *Workbook) RecalculateAll(ctx context.Context) error {
+	wb.invalidateAll()
+	return wb.Recalculate(ctx)
+}
+
+// A formulaCell is a cell whose value is computed from a formula.
+type formulaCell struct {
+	formula Formula
+	value   Value
+	dirty   bool
+}
+
+// value returns the value of a formula cell, computing it if it is dirty.
+func (wb *Workbook) value(ctx context.Context, s *inMemorySheet, pos Pos, cell *formulaCell) Value {
+	addr := CellAddress{Sheet: s.name, Pos: pos}
+	if idx, ok := wb.inProgress[addr]; ok {
+		// The cell refers to itself, directly or indirectly
+		cycle := append([]CellAddress(nil), wb.computing[idx:]...)
+		for _, c := range cycle {
+			wb.cyclic[c] = struct{}{}
+		}
+
+		if wb.iterative.Enabled {
+			// Use the value from the previous iteration
+			if cell.value == nil {
+				return Float64Value(0)
+			}
+
+			return cell.value
+		}
+
+		err := &CircularReferenceError{Cycle: cycle}
+		if wb.cycleErr == nil {
+			wb.cycleErr = err
+		}
+
+		return ErrorValue{err}
+	}
+
+	// Converging a cycle can leave cells that depend on it dirty, in which
+	// case they are computed again using the converged values
+	for cell.dirty {
+		wb.compute(ctx, s, pos, cell)
+	}
+
+	return cell.value
+}
+
+func (wb *Workbook) compute(ctx context.Context, s *inMemorySheet, pos Pos, cell *formulaCell) {
+	addr := CellAddress{Sheet: s.name, Pos: pos}
+	wb.inProgress[addr] = len(wb.computing)
+	wb.computing = append(wb.computing, addr)
+
+	cell.value = wb.evaluator.Evaluate(ctx, s, cell.formula)
+	if _, isBlank := cell.value.(BlankValue); isBlank {
+		// As in Excel, a formula that refers to a blank cell is 0
+		cell.value = Float64Value(0)
+	}
+	cell.dirty = false
+	delete(wb.dirty, addr)
+
+	wb.computing = wb.computing[:len(wb.computing)-1]
+	delete(wb.inProgress, addr)
+
+	if len(wb.computing) == 0 && len(wb.cyclic) != 0 {
+		cyclic := wb.cyclic
+		wb.cyclic = make(map[CellAddress]struct{})
+		if wb.iterative.Enabled && !wb.iterating {
+			wb.iterate(ctx, cyclic)
+		}
+	}
+}
+
+// iterate repeatedly recomputes the cells that make up one or more cycles until
+// their values converge, or the maximum number of iterations is reached. The
+// cells that depend on the cycles are then marked dirty, so that they pick up
+// the converged values.
+func (wb *Workbook) iterate(ctx context.Context, cyclic map[CellAddress]struct{}) {
+	wb.iterating = true
+	defer func() { wb.iterating = false }()
+
+	cells := make([]CellAddress, 0, len(cyclic))
+	for addr := range cyclic {
+		cells = append(cells, addr)
+	}
+
+	sortCellAddresses(cells)
+
+	for i := 0; i < wb.iterative.MaxIterations; i++ {
+		maxChange := 0.0
+		for _, addr := range cells {
+			s, cell, ok := wb.formulaCell(addr)
+			if !ok {
+				continue
+			}
+
+			prev := cell.value
+			wb.compute(ctx, s, addr.Pos, cell)
+			maxChange = math.Max(maxChange, valueChange(prev, cell.value))
+		}
+
+		if maxChange <= wb.iterative.MaxChange {
+			break
+		}
+	}
+
+	var queue []CellAddress
+	for _, addr := range cells {
+		queue = append(queue, wb.graph.dependentsOf(addr)...)
+	}
+
+	for len(queue) != 0 {
+		next := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+
+		if _, isCyclic := cyclic[next]; isCyclic {
+			continue
+		}
+
+		_, cell, ok := wb.formulaCell(next)
+		if !ok || cell.dirty {
+			continue
+		}
+
+		cell.dirty = true
+		wb.dirty[next] = struct{}{}
+		queue = append(queue, wb.graph.dependentsOf(next)...)
+	}
+}
+
+// valueChange returns how much a value has changed between iterations.
+func valueChange(prev, next Value) float64 {
+	if prev == nil || next == nil {
+		return math.Inf(1)
+	}
+
+	n1, err1 := prev.ToFloat64()
+	n2, err2 := next.ToFloat64()
+	if err1 == nil && err2 == nil {
+		return math.Abs(n2 - n1)
+	}
+
+	if prev == next {
+		return 0
+	}
+
+	return math.Inf(1)
+}
+
+func (wb *Workbook) formulaCell(addr CellAddress) (*inMemorySheet, *formulaCell, bool) {
+	s, ok := wb.sheets[addr.Sheet].(*inMemorySheet)
+	if !ok {
+		return nil, nil, false
+	}
+
+	cell, ok := s.formulas[addr.Pos]
+	return s, cell, ok
+}
+
+// trackFormula adds a formula cell to the dependency graph and marks it, and
+// everything downstream of it, as dirty.
+func (wb *Workbook) trackFormula(s *inMemorySheet, pos Pos, cell *formulaCell) {
+	addr := wb.registerFormula(s, pos, cell)
+	wb.invalidate(addr, true)
+}
+
+// registerFormula adds a formula cell to the dependency graph.
+func (wb *Workbook) registerFormula(s *inMemorySheet, pos Pos, cell *formulaCell) CellAddress {
+	addr := CellAddress{Sheet: s.name, Pos: pos}
+	precedents, names := wb.references(s.name, cell.formula)
+	wb.graph.set(addr, precedents, names)
+
+	delete(wb.volatile, addr)
+	if wb.evaluator.isVolatile(cell.formula) {
+		wb.volatile[addr] = struct{}{}
+	}
+
+	return addr
+}
+
+// untrackFormula removes a formula cell from the dependency graph.
+func (wb *Workbook) untrackFormula(addr CellAddress) {
+	wb.graph.remove(addr)
+	delete(wb.volatile, addr)
+	delete(wb.dirty, addr)
+}
+
+// invalidate marks the formula cells downstream of a cell as dirty, along with the
+// cell itself if it is a formula cell and includeSelf is set.
+func (wb *Workbook) invalidate(addr CellAddress, includeSelf bool) {
+	if _, cell, ok := wb.formulaCell(addr); ok && includeSelf {
+		cell.dirty = true
+		wb.dirty[addr] = struct{}{}
+	}
+
+	queue := wb.graph.dependentsOf(addr)
+	for len(queue) != 0 {
+		next := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+
+		_, cell, ok := wb.formulaCell(next)
+		if !ok || cell.dirty {
+			// Not a formula, or already dirty, in which case everything downstream
+			// of it is also dirty
+			continue
+		}
+
+		cell.dirty = true
+		wb.dirty[next] = struct{}{}
+		queue = append(queue, wb.graph.dependentsOf(next)...)
+	}
+}
+
+// invalidateAll marks every formula cell as dirty. Which formulas call volatile
+// functions is also refreshed, since the functions may have changed.
+func (wb *Workbook) invalidateAll() {
+	wb.volatile = make(map[CellAddress]struct{})
+	for _, name := range wb.names {
+		if s, ok := wb.sheets[name].(*inMemorySheet); ok {
+			for pos, cell := range s.formulas {
+				addr := CellAddress{Sheet: s.name, Pos: pos}
+				cell.dirty = true
+				wb.dirty[addr] = struct{}{}
+				if wb.evaluator.isVolatile(cell.formula) {
+					wb.volatile[addr] = struct{}{}
+				}
+			}
+		}
+	}
+}
+
+// dirtyInDependencyOrder returns the dirty formula cells, ordered so that each
+// cell comes after all of the dirty cells it depends on.
+func (wb *Workbook) dirtyInDependencyOrder() []CellAddress {
+	numPrecedents := make(map[CellAddress]int, len(wb.dirty))
+	dependents := make(map[CellAddress][]CellAddress, len(wb.dirty))
+	for addr := range wb.dirty {
+		numPrecedents[addr] += 0
+		for _, dependent := range wb.graph.dependentsOf(addr) {
+			if _, isDirty := wb.dirty[dependent]; isDirty {
+				dependents[addr] = append(dependents[addr], dependent)
+				numPrecedents[dependent]++
+			}
+		}
+	}
+
+	var ready []CellAddress
+	for addr, n := range numPrecedents {
+		if n == 0 {
+			ready = append(ready, addr)
+		}
+	}
+
+	sortCellAddresses(ready)
+
+	ordered := make([]CellAddress, 0, len(numPrecedents))
+	for len(ready) != 0 {
+		next := ready[0]
+		ready = ready[1:]
+		ordered = append(ordered, next)
+
+		for _, dependent := range dependents[next] {
+			numPrecedents[dependent]--
+			if numPrecedents[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+	}
+
+	if len(ordered) != len(numPrecedents) {
+		// Cells that are part of a cycle never become ready; append them so
+		// that they are computed on demand
+		var remaining []CellAddress
+		for addr, n := range numPrecedents {
+			if n > 0 {
+				remaining = append(remaining, addr)
+			}
+		}
+
+		sortCellAddresses(remaining)
+		ordered = append(ordered, remaining...)
+	}
+
+	return ordered
+}
+
+// applyStructuralEdit updates the formulas and named ranges in the workbook to
+// account for rows or columns being inserted into, or deleted from, a sheet.
+//
+// Only the formula cells affected by the edit are updated in the dependency
+// graph and marked dirty: those that move, and those that refer to cells in
+// the edited sheet at or beyond the edit point, whose references are
+// rewritten or whose contents have shifted.
+func (wb *Workbook) applyStructuralEdit(edited *inMemorySheet, edit structuralEdit) {
+	shifted := RangeAddress{Sheet: edited.name, Range: edit.shiftedRange()}
+
+	type affectedFormula struct {
+		s    *inMemorySheet
+		pos  Pos
+		cell *formulaCell
+	}
+
+	var affected []affectedFormula
+	for _, name := range wb.names {
+		s, ok := wb.sheets[name].(*inMemorySheet)
+		if !ok || len(s.formulas) == 0 {
+			continue
+		}
+
+		for pos, cell := range s.formulas {
+			addr := CellAddress{Sheet: s.name, Pos: pos}
+			newPos, moved := pos, false
+			if s == edited {
+				var ok bool
+				if newPos, ok = edit.adjustPos(pos); !ok {
+					// The formula itself was deleted
+					delete(s.formulas, pos)
+					wb.untrackFormula(addr)
+					continue
+				}
+
+				moved = newPos != pos
+			}
+
+			if moved || wb.refersTo(addr, shifted) {
+				affected = append(affected, affectedFormula{s: s, pos: newPos, cell: cell})
+				delete(s.formulas, pos)
+				wb.untrackFormula(addr)
+			}
+		}
+	}
+
+	for name, named := range wb.namedRanges {
+		if named.Sheet != edited.name {
+			continue
+		}
+
+		if r, ok := edit.adjustRange(named.Range); ok {
+			wb.namedRanges[name] = NamedRange{Sheet: named.Sheet, Range: r}
+		} else {
+			delete(wb.namedRanges, name)
+		}
+	}
+
+	for _, f := range affected {
+		isTarget := func(sheet string) bool {
+			if f.s == edited && sheet == "" {
+				return true
+			}
+
+			return edited.name != "" && sheet == edited.name
+		}
+
+		f.cell.formula = edit.adjustFormula(f.cell.formula, isTarget)
+		f.s.formulas[f.pos] = f.cell
+		wb.registerFormula(f.s, f.pos, f.cell)
+	}
+
+	for _, f := range affected {
+		wb.invalidate(CellAddress{Sheet: f.s.name, Pos: f.pos}, true)
+	}
+}
+
+// refersTo returns true if any of the precedents of a formula cell overlap
+// the given range.
+func (wb *Workbook) refersTo(addr CellAddress, r RangeAddress) bool {
+	for _, precedent := range wb.graph.precedentsOf(addr) {
+		if precedent.Overlaps(r) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// references returns the cells and ranges referred to by a formula in the
+// given sheet, in the order they appear in the formula, along with the names
+// of the named ranges it refers to.
+func (wb *Workbook) references(sheetName string, f Formula) ([]RangeAddress, []string) {
+	var (
+		precedents []RangeAddress
+		names      []string
+	)
+
+	visitFormula(f, func(node Formula) {
+		switch nt := node.(type) {
+		case *CellReference:
+			precedents = append(precedents, RangeAddress{
+				Sheet: referencedSheet(sheetName, nt.Sheet),
+				Range: Range{
+					StartRow: nt.Pos.Row, EndRow: nt.Pos.Row,
+					StartCol: nt.Pos.Col, EndCol: nt.Pos.Col,
+				},
+			})
+		case *CellRangeReference:
+			precedents = append(precedents, RangeAddress{
+				Sheet: referencedSheet(sheetName, nt.Sheet),
+				Range: nt.Range,
+			})
+		case *NamedRangeReference:
+			names = append(names, nt.NamedRange)
+			if named, ok := wb.namedRanges[nt.NamedRange]; ok {
+				precedents = append(precedents, RangeAddress{
+					Sheet: named.Sheet,
+					Range: named.Range,
+				})
+			}
+		}
+	})
+
+	slices.Sort(names)
+	return slices.Compact(precedents), slices.Compact(names)
+}
+
+func referencedSheet(current, referenced string) string {
+	if referenced == "" {
+		return current
+	}
+
+	return referenced
+}
+
+var (
+	_ DataSet            = &Workbook{}
+	_ NamedRangeResolver = &Workbook{}
+)
@@ -0,0 +1,544 @@
+package sheets
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkbook_CrossSheetFormulas(t *testing.T) {
+	wb := NewWorkbook()
+
+	prices, err := NewMutableSheet([][]Value{
+		{Float64Value(2.5)},
+		{Float64Value(4)},
+	})
+	require.NoError(t, err)
+	require.NoError(t, wb.AddSheet("Prices", prices))
+
+	orders, err := NewMutableSheet([][]Value{
+		{Float64Value(10), StringValue("=A1 * Prices!A1")},
+		{Float64Value(3), StringValue("=A2 * Prices!A2")},
+		{nil, StringValue("=SUM(B1:B2)")},
+	}, WithFormulas())
+	require.NoError(t, err)
+	require.NoError(t, wb.AddSheet("Orders", orders))
+
+	assert.Equal(t, []string{"Prices", "Orders"}, wb.SheetNames())
+	assert.Equal(t, prices, wb.Sheet("Prices"))
+	assert.Nil(t, wb.Sheet("Missing"))
+
+	assertCells(t, orders, map[string]Value{
+		"B1": Float64Value(25),
+		"B2": Float64Value(12),
+		"B3": Float64Value(37),
+	})
+
+	require.NoError(t, prices.Set(context.TODO(), mustParsePos(t, "A2"), Float64Value(5)))
+	assertCells(t, orders, map[string]Value{
+		"B2": Float64Value(15),
+		"B3": Float64Value(40),
+	})
+
+	// Inserting rows into the referenced sheet rewrites references from other sheets
+	require.NoError(t, prices.InsertRows(context.TODO(), 0, 1))
+	f, ok := orders.Formula(mustParsePos(t, "B2"))
+	require.True(t, ok)
+	assert.Equal(t, "A2 * `Prices`!A3", f.String())
+	assertCells(t, orders, map[string]Value{
+		"B3": Float64Value(40),
+	})
+}
+
+func TestWorkbook_AddSheet(t *testing.T) {
+	wb := NewWorkbook()
+	s, err := NewInMemorySheet([][]Value{{Float64Value(1)}})
+	require.NoError(t, err)
+
+	require.Error(t, wb.AddSheet("", s))
+	require.NoError(t, wb.AddSheet("First", s))
+	require.Error(t, wb.AddSheet("First", s))
+
+	err = NewWorkbook().AddSheet("Other", s)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "already part of a workbook as 'First'")
+}
+
+func TestWorkbook_NamedRanges(t *testing.T) {
+	wb := NewWorkbook()
+
+	data, err := NewMutableSheet([][]Value{
+		{Float64Value(1), Float64Value(2), Float64Value(3)},
+		{Float64Value(4), Float64Value(5), Float64Value(6)},
+	})
+	require.NoError(t, err)
+	require.NoError(t, wb.AddSheet("Data", data))
+
+	summary, err := NewMutableSheet([][]Value{{StringValue("=SUM(Totals)")}}, WithFormulas())
+	require.NoError(t, err)
+	require.NoError(t, wb.AddSheet("Summary", summary))
+
+	v, err := summary.Get(context.TODO(), mustParsePos(t, "A1"))
+	require.NoError(t, err)
+	assert.Equal(t, ErrorValue{NameErrorf("unknown named range 'Totals'")}, v)
+
+	wb.SetNamedRange("Totals", NamedRange{Sheet: "Data", Range: mustParseRange(t, "A2:C2")})
+	assertCells(t, summary, map[string]Value{"A1": Float64Value(15)})
+
+	require.NoError(t, data.Set(context.TODO(), mustParsePos(t, "B2"), Float64Value(50)))
+	assertCells(t, summary, map[string]Value{"A1": Float64Value(60)})
+
+	// Named ranges move with structural edits
+	require.NoError(t, data.InsertRows(context.TODO(), 0, 2))
+	named, ok := wb.NamedRange("Totals")
+	require.True(t, ok)
+	assert.Equal(t, "A4:C4", named.Range.String())
+	assertCells(t, summary, map[string]Value{"A1": Float64Value(60)})
+
+	wb.SetNamedRange("Firsts", NamedRange{Sheet: "Data", Range: mustParseRange(t, "A:A")})
+	assert.Equal(t, []string{"Firsts", "Totals"}, wb.NamedRangeNames())
+}
+
+func TestWorkbook_IncrementalRecalculation(t *testing.T) {
+	wb := NewWorkbook()
+
+	evaluations := map[string]int{}
+	functions := BuiltinFunctions()
+	functions["TRACK"] = FunctionFunc(func(ctx context.Context, _ *Evaluator, args []ValueIter) Value {
+		args[0].Next(ctx)
+		args[1].Next(ctx)
+		evaluations[args[0].Value().String()]++
+		return args[1].Value()
+	})
+	wb.Evaluator().Functions = functions
+
+	s, err := NewMutableSheet([][]Value{
+		{Float64Value(1), Float64Value(10), StringValue(`=TRACK("C1", A1 * 2)`)},
+		{Float64Value(2), Float64Value(20), StringValue(`=TRACK("C2", B2 * 2)`)},
+		{nil, nil, StringValue(`=TRACK("C3", C1 + 1)`)},
+	}, WithFormulas())
+	require.NoError(t, err)
+	require.NoError(t, wb.AddSheet("Sheet1", s))
+	require.NoError(t, wb.Recalculate(context.TODO()))
+	assert.Equal(t, map[string]int{"C1": 1, "C2": 1, "C3": 1}, evaluations)
+
+	// Nothing has changed, so nothing is recomputed
+	require.NoError(t, wb.Recalculate(context.TODO()))
+	assertCells(t, s, map[string]Value{"C3": Float64Value(3)})
+	assert.Equal(t, map[string]int{"C1": 1, "C2": 1, "C3": 1}, evaluations)
+
+	// Only cells downstream of A1 are recomputed
+	require.NoError(t, s.Set(context.TODO(), mustParsePos(t, "A1"), Float64Value(5)))
+	require.NoError(t, wb.Recalculate(context.TODO()))
+	assert.Equal(t, map[string]int{"C1": 2, "C2": 1, "C3": 2}, evaluations)
+	assertCells(t, s, map[string]Value{"C3": Float64Value(11)})
+
+	// Recalculating everything recomputes all cells
+	require.NoError(t, wb.RecalculateAll(context.TODO()))
+	assert.Equal(t, map[string]int{"C1": 3, "C2": 2, "C3": 3}, evaluations)
+}
+
+func TestWorkbook_VolatileFunctions(t *testing.T) {
+	wb := NewWorkbook()
+
+	calls := 0
+	functions := BuiltinFunctions()
+	functions["TICK"] = Volatile(FunctionFunc(func(_ context.Context, _ *Evaluator, _ []ValueIter) Value {
+		calls++
+		return Float64Value(calls)
+	}))
+	wb.Evaluator().Functions = functions
+
+	s, err := NewMutableSheet([][]Value{
+		{StringValue("=TICK()"), StringValue("=A1 * 10"), Float64Value(7), StringValue("=C1 + 1")},
+	}, WithFormulas())
+	require.NoError(t, err)
+	require.NoError(t, wb.AddSheet("Sheet1", s))
+
+	for i := 1; i <= 3; i++ {
+		require.NoError(t, wb.Recalculate(context.TODO()))
+		assert.Equal(t, i, calls)
+		assertCells(t, s, map[string]Value{
+			"A1": Float64Value(i),
+			"B1": Float64Value(i * 10),
+			"D1": Float64Value(8),
+		})
+	}
+}
+
+func TestWorkbook_PrecedentsAndDependents(t *testing.T) {
+	wb := NewWorkbook()
+
+	inputs, err := NewMutableSheet([][]Value{
+		{Float64Value(1), Float64Value(2)},
+	})
+	require.NoError(t, err)
+	require.NoError(t, wb.AddSheet("Inputs", inputs))
+	wb.SetNamedRange("Rates", NamedRange{Sheet: "Inputs", Range: mustParseRange(t, "B1:B5")})
+
+	calc, err := NewMutableSheet([][]Value{
+		{StringValue("=Inputs!A1 + A2"), StringValue("=SUM(A:A) * SUM(Rates)")},
+		{Float64Value(3), StringValue("=A1 + A1")},
+	}, WithFormulas())
+	require.NoError(t, err)
+	require.NoError(t, wb.AddSheet("Calc", calc))
+
+	addr := func(sheet, pos string) CellAddress {
+		return CellAddress{Sheet: sheet, Pos: mustParsePos(t, pos)}
+	}
+
+	assert.Equal(t, []RangeAddress{
+		{Sheet: "Inputs", Range: mustParseRange(t, "A1:A1")},
+		{Sheet: "Calc", Range: mustParseRange(t, "A2:A2")},
+	}, wb.Precedents(addr("Calc", "A1")))
+
+	assert.Equal(t, []RangeAddress{
+		{Sheet: "Calc", Range: mustParseRange(t, "A:A")},
+		{Sheet: "Inputs", Range: mustParseRange(t, "B1:B5")},
+	}, wb.Precedents(addr("Calc", "B1")))
+
+	assert.Equal(t, []RangeAddress{
+		{Sheet: "Calc", Range: mustParseRange(t, "A1:A1")},
+	}, wb.Precedents(addr("Calc", "B2")))
+
+	assert.Nil(t, wb.Precedents(addr("Calc", "A2")))
+
+	assert.Equal(t, []CellAddress{addr("Calc", "A1")}, wb.Dependents(addr("Inputs", "A1")))
+	assert.Equal(t, []CellAddress{addr("Calc", "B1")}, wb.Dependents(addr("Inputs", "B3")))
+	assert.Equal(t, []CellAddress{addr("Calc", "B1"), addr("Calc", "B2")}, wb.Dependents(addr("Calc", "A1")))
+	assert.Equal(t, []CellAddress{addr("Calc", "A1"), addr("Calc", "B1")}, wb.Dependents(addr("Calc", "A2")))
+	assert.Empty(t, wb.Dependents(addr("Calc", "B2")))
+}
+
+func TestWorkbook_RecalculateLongChain(t *testing.T) {
+	const numRows = 20_000
+
+	values := make([][]Value, numRows)
+	values[0] = []Value{Float64Value(1)}
+	for i := 1; i < numRows; i++ {
+		values[i] = []Value{StringValue("=A" + rowToString(i-1) + " + 1")}
+	}
+
+	s, err := NewMutableSheet(values, WithFormulas())
+	require.NoError(t, err)
+
+	wb := NewWorkbook()
+	require.NoError(t, wb.AddSheet("Chain", s))
+	require.NoError(t, wb.Recalculate(context.TODO()))
+	assertCells(t, s, map[string]Value{"A20000": Float64Value(numRows)})
+
+	require.NoError(t, s.Set(context.TODO(), mustParsePos(t, "A1"), Float64Value(100)))
+	require.NoError(t, wb.Recalculate(context.TODO()))
+	assertCells(t, s, map[string]Value{"A20000": Float64Value(numRows + 99)})
+}
+
+func TestWorkbook_RecalculateLargeSheet(t *testing.T) {
+	const numRows = 50_000
+
+	ctx := context.TODO()
+	values := make([][]Value, numRows)
+	for i := range values {
+		row := rowToString(i)
+		values[i] = []Value{
+			Float64Value(1), Float64Value(2),
+			StringValue("=SUM(A" + row + ":B" + row + ")"),
+			StringValue("=C" + row + " * 2"),
+		}
+	}
+
+	values[0] = append(values[0], StringValue("=SUM(D:D)"), StringValue("=SUM(Inputs)"))
+
+	s, err := NewMutableSheet(values, WithFormulas())
+	require.NoError(t, err)
+
+	wb := NewWorkbook()
+	require.NoError(t, wb.AddSheet("Data", s))
+	wb.SetNamedRange("Inputs", NamedRange{Sheet: "Data", Range: mustParseRange(t, "A1:A10")})
+	require.NoError(t, wb.Recalculate(ctx))
+	assertCells(t, s, map[string]Value{"E1": Float64Value(numRows * 6), "F1": Float64Value(10)})
+
+	require.NoError(t, s.Set(ctx, mustParsePos(t, "B1000"), Float64Value(5)))
+	require.NoError(t, s.InsertRows(ctx, 100_000, 1))
+	require.NoError(t, s.Set(ctx, mustParsePos(t, "C100001"), StringValue("=SUM(A1:B10)")))
+	wb.SetNamedRange("Inputs", NamedRange{Sheet: "Data", Range: mustParseRange(t, "A1:B10")})
+	wb.SetNumericMode(ExactNumerics)
+	require.NoError(t, wb.Recalculate(ctx))
+	assertCells(t, s, map[string]Value{
+		"E1": Float64Value(numRows*6 + 6),
+		"F1": Float64Value(30),
+	})
+}
+
+func TestWorkbook_StructuralEditsRecalculateAffectedCells(t *testing.T) {
+	wb := NewWorkbook()
+
+	evaluations := map[string]int{}
+	functions := BuiltinFunctions()
+	functions["TRACK"] = FunctionFunc(func(ctx context.Context, _ *Evaluator, args []ValueIter) Value {
+		args[0].Next(ctx)
+		args[1].Next(ctx)
+		evaluations[args[0].Value().String()]++
+		return args[1].Value()
+	})
+	wb.Evaluator().Functions = functions
+
+	s, err := NewMutableSheet([][]Value{
+		{Float64Value(1), StringValue(`=TRACK("B1", A1 * 2)`)},
+		{Float64Value(2), StringValue(`=TRACK("B2", SUM(A1:A2))`)},
+		{Float64Value(3), StringValue(`=TRACK("B3", A3 + 1)`)},
+		{Float64Value(4), StringValue(`=TRACK("B4", SUM(A:A))`)},
+	}, WithFormulas())
+	require.NoError(t, err)
+	require.NoError(t, wb.AddSheet("Sheet1", s))
+
+	other, err := NewMutableSheet([][]Value{
+		{StringValue(`=TRACK("Other!A1", Sheet1!A1)`), StringValue(`=TRACK("Other!B1", Sheet1!A4)`)},
+	}, WithFormulas())
+	require.NoError(t, err)
+	require.NoError(t, wb.AddSheet("Other", other))
+	require.NoError(t, wb.Recalculate(context.TODO()))
+
+	// Formulas that move, or that refer to cells beyond the edit point, are
+	// recomputed; those before it keep their values
+	require.NoError(t, s.InsertRows(context.TODO(), 2, 1))
+	require.NoError(t, wb.Recalculate(context.TODO()))
+	assert.Equal(t, map[string]int{
+		"B1": 1, "B2": 1, "B3": 2, "B4": 2, "Other!A1": 1, "Other!B1": 2,
+	}, evaluations)
+	assertCells(t, s, map[string]Value{
+		"B1": Float64Value(2), "B2": Float64Value(3), "B4": Float64Value(4), "B5": Float64Value(10),
+	})
+	assertCells(t, other, map[string]Value{"A1": Float64Value(1), "B1": Float64Value(4)})
+
+	assert.Equal(t, []RangeAddress{{Sheet: "Sheet1", Range: mustParseRange(t, "A4:A4")}},
+		wb.Precedents(CellAddress{Sheet: "Sheet1", Pos: mustParsePos(t, "B4")}))
+	assert.Equal(t, []CellAddress{
+		{Sheet: "Sheet1", Pos: mustParsePos(t, "B4")},
+		{Sheet: "Sheet1", Pos: mustParsePos(t, "B5")},
+	}, wb.Dependents(CellAddress{Sheet: "Sheet1", Pos: mustParsePos(t, "A4")}))
+	assert.Equal(t, []CellAddress{{Sheet: "Sheet1", Pos: mustParsePos(t, "B5")}},
+		wb.Dependents(CellAddress{Sheet: "Sheet1", Pos: mustParsePos(t, "A3")}))
+
+	// Deleting the row restores the original layout
+	require.NoError(t, s.DeleteRows(context.TODO(), 2, 1))
+	require.NoError(t, wb.Recalculate(context.TODO()))
+	assert.Equal(t, map[string]int{
+		"B1": 1, "B2": 1, "B3": 3, "B4": 3, "Other!A1": 1, "Other!B1": 3,
+	}, evaluations)
+	assertCells(t, s, map[string]Value{"B3": Float64Value(4), "B4": Float64Value(10)})
+}
+
+func TestWorkbook_CircularReferences(t *testing.T) {
+	wb := NewWorkbook()
+
+	s, err := NewMutableSheet([][]Value{
+		{StringValue("=B1 + 1"), StringValue("=A1 * 2"), StringValue("=A1")},
+		{Float64Value(1), Float64Value(2), StringValue("=SUM(A1:C2)")},
+	}, WithFormulas())
+	require.NoError(t, err)
+	require.NoError(t, wb.AddSheet("Cycle", s))
+
+	err = wb.Recalculate(context.TODO())
+	require.Error(t, err)
+
+	var circErr *CircularReferenceError
+	require.ErrorAs(t, err, &circErr)
+	assert.Equal(t, []CellAddress{
+		{Sheet: "Cycle", Pos: mustParsePos(t, "A1")},
+		{Sheet: "Cycle", Pos: mustParsePos(t, "B1")},
+	}, circErr.Cycle)
+	assert.Equal(t, "circular reference: `Cycle`!A1 -> `Cycle`!B1 -> `Cycle`!A1", circErr.Error())
+
+	// Every cell in or depending on the cycle reports the error
+	for _, ref := range []string{"A1", "B1", "C1"} {
+		v, err := s.Get(context.TODO(), mustParsePos(t, ref))
+		require.NoError(t, err)
+		require.IsType(t, ErrorValue{}, v, ref)
+		assert.ErrorAs(t, v.(ErrorValue).Err, &circErr, ref)
+	}
+
+	// A range that includes the formula's own cell is also a cycle
+	v, err := s.Get(context.TODO(), mustParsePos(t, "C2"))
+	require.NoError(t, err)
+	require.IsType(t, ErrorValue{}, v)
+	require.ErrorAs(t, v.(ErrorValue).Err, &circErr)
+	assert.Equal(t, "#CIRCULAR", circErr.TypeName())
+
+	// Breaking the cycle clears the error
+	require.NoError(t, s.Set(context.TODO(), mustParsePos(t, "B1"), Float64Value(4)))
+	require.NoError(t, s.Clear(context.TODO(), Range{StartRow: 1, EndRow: 1, StartCol: 2, EndCol: 2}))
+	require.NoError(t, wb.Recalculate(context.TODO()))
+	assertCells(t, s, map[string]Value{
+		"A1": Float64Value(5),
+		"C1": Float64Value(5),
+	})
+}
+
+func TestWorkbook_IterativeCalculation(t *testing.T) {
+	wb := NewWorkbook()
+	wb.SetIterativeCalculation(IterativeCalculation{Enabled: true, MaxChange: 0.000001})
+
+	// A1 = 100 + A1/2 converges on 200
+	s, err := NewMutableSheet([][]Value{
+		{StringValue("=100 + B1"), StringValue("=A1 * 0.5"), StringValue("=A1 + 1")},
+	}, WithFormulas())
+	require.NoError(t, err)
+	require.NoError(t, wb.AddSheet("Loop", s))
+
+	require.NoError(t, wb.Recalculate(context.TODO()))
+	for ref, expected := range map[string]float64{"A1": 200, "B1": 100, "C1": 201} {
+		v, err := s.Get(context.TODO(), mustParsePos(t, ref))
+		require.NoError(t, err)
+
+		n, err := v.ToFloat64()
+		require.NoError(t, err, ref)
+		assert.InDelta(t, expected, n, 0.00001, ref)
+	}
+
+	// Cycles that don't converge stop after the maximum number of iterations,
+	// each of which adds 101 to A1 starting from its previous value
+	wb.SetIterativeCalculation(IterativeCalculation{Enabled: true, MaxIterations: 10})
+	require.NoError(t, s.Set(context.TODO(), mustParsePos(t, "B1"), StringValue("=A1 + 1")))
+	require.NoError(t, wb.Recalculate(context.TODO()))
+
+	v, err := s.Get(context.TODO(), mustParsePos(t, "A1"))
+	require.NoError(t, err)
+	n, err := v.ToFloat64()
+	require.NoError(t, err)
+	assert.InDelta(t, 200+10*101, n, 0.0001)
+
+	// Disabling iterative calculation reports the cycle
+	wb.SetIterativeCalculation(IterativeCalculation{})
+	var circErr *CircularReferenceError
+	require.ErrorAs(t, wb.Recalculate(context.TODO()), &circErr)
+}
+
+func TestWorkbook_SetCollator(t *testing.T) {
+	wb := NewWorkbook()
+
+	s, err := NewMutableSheet([][]Value{
+		{StringValue("apple"), StringValue("APPLE"), StringValue("=A1 = B1")},
+	}, WithFormulas())
+	require.NoError(t, err)
+	require.NoError(t, wb.AddSheet("Fruit", s))
+
+	assertCells(t, s, map[string]Value{"C1": BoolValue(true)})
+
+	wb.SetCollator(BinaryCollator)
+	assertCells(t, s, map[string]Value{"C1": BoolValue(false)})
+}
+
+func TestWorkbook_SetNumericMode(t *testing.T) {
+	wb := NewWorkbook()
+
+	s, err := NewMutableSheet([][]Value{
+		{
+			Float64Value(0.1), Float64Value(0.2), StringValue("=A1 + B1"), StringValue("=C1 = 0.3"),
+			StringValue("=SUM(A1, B1, 0-0.3)"), StringValue("=AVERAGE(A1, B1, 0-0.3)"), StringValue("=CONCAT(C1)"),
+		},
+	}, WithFormulas())
+	require.NoError(t, err)
+	require.NoError(t, wb.AddSheet("Numbers", s))
+
+	assertCells(t, s, map[string]Value{
+		"D1": BoolValue(true),
+		"E1": Float64Value(0),
+		"F1": Float64Value(0),
+		"G1": StringValue("0.3"),
+	})
+	v, err := s.Get(context.TODO(), mustParsePos(t, "C1"))
+	require.NoError(t, err)
+	assert.Equal(t, "0.3", v.String())
+
+	var sb strings.Builder
+	require.NoError(t, WriteCSV(context.TODO(), &sb, s))
+	assert.Equal(t, "0.1,0.2,0.3,TRUE,0,0,0.3\n", sb.String())
+
+	// Computed at run time, as constant expressions are exact
+	a, b := 0.1, 0.2
+	wb.SetNumericMode(ExactNumerics)
+	assertCells(t, s, map[string]Value{
+		"D1": BoolValue(false),
+		"E1": Float64Value(a + b - 0.3),
+		"F1": Float64Value((a + b - 0.3) / 3),
+		"G1": StringValue("0.30000000000000004"),
+	})
+
+	sb.Reset()
+	require.NoError(t, WriteCSV(context.TODO(), &sb, s))
+	assert.Equal(t, "0.1,0.2,0.30000000000000004,FALSE,0.00000000000000005551115123125783,"+
+		"0.00000000000000001850371707708594,0.30000000000000004\n", sb.String())
+}
+
+func TestWorkbook_SetDateSystem(t *testing.T) {
+	wb := NewWorkbook()
+
+	s, err := NewMutableSheet([][]Value{
+		{
+			TimeValue(time.Date(2024, time.March, 5, 0, 0, 0, 0, time.UTC)),
+			StringValue("=A1 + 1"),
+			StringValue(`=TEXT(45356, "yyyy-mm-dd")`),
+			StringValue(`=TEXT(60, "yyyy-mm-dd ddd")`),
+		},
+	}, WithFormulas())
+	require.NoError(t, err)
+	require.NoError(t, wb.AddSheet("Dates", s))
+
+	assertCells(t, s, map[string]Value{
+		"B1": Float64Value(45357),
+		"C1": StringValue("2024-03-05"),
+		"D1": StringValue("1900-02-29 Wed"),
+	})
+
+	wb.SetDateSystem(Date1904)
+	assertCells(t, s, map[string]Value{
+		"B1": Float64Value(43895),
+		"C1": StringValue("2028-03-06"),
+		"D1": StringValue("1904-03-01 Tue"),
+	})
+}
+
+func TestWorkbook_SetLocation(t *testing.T) {
+	wb := NewWorkbook()
+
+	s, err := NewMutableSheet([][]Value{
+		{
+			TimeValue(time.Date(2024, time.March, 5, 2, 30, 0, 0, time.UTC)),
+			StringValue(`=TEXT(A1, "yyyy-mm-dd hh:mm")`),
+			StringValue(`=A1 >= "2024-03-05"`),
+		},
+	}, WithFormulas())
+	require.NoError(t, err)
+	require.NoError(t, wb.AddSheet("Times", s))
+
+	assertCells(t, s, map[string]Value{
+		"B1": StringValue("2024-03-05 02:30"),
+		"C1": BoolValue(true),
+	})
+
+	// The date constant is midnight UTC, which is still before A1 when both
+	// are converted to wall-clock times in New York
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+	wb.SetLocation(newYork)
+	assertCells(t, s, map[string]Value{
+		"B1": StringValue("2024-03-04 21:30"),
+		"C1": BoolValue(true),
+	})
+}
+
+func TestWorkbook_BlankCells(t *testing.T) {
+	s, err := ReadCSV(strings.NewReader("10,=A1\n,=A2\n30,=AVERAGE(A1:A3)\n"), WithFormulas())
+	require.NoError(t, err)
+
+	// Averages skip blank cells, and formulas that refer to blank cells are 0
+	assertCells(t, s, map[string]Value{
+		"B1": Float64Value(10),
+		"B2": Float64Value(0),
+		"B3": Float64Value(20),
+	})
+}
@@ -0,0 +1,230 @@
+package xlsx
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mmihic/sheets/src/pkg/sheets"
+	"github.com/mmihic/sheets/src/pkg/sheets/internal/office"
+)
+
+// cellRef matches a reference to a single cell, with optional "$" markers for
+// absolute columns and rows.
+var cellRef = regexp.MustCompile(`^(\$?)([A-Za-z]{1,3})(\$?)([0-9]+)`)
+
+// shiftFormula moves the relative cell references in the text of a formula by
+// the given number of rows and columns, as Excel does when copying the master
+// formula of a shared formula to the other cells that share it.
+func shiftFormula(text string, rows, cols int) string {
+	if rows == 0 && cols == 0 {
+		return text
+	}
+
+	return rewriteUnquoted(text, func(s string, tokenStart bool) (string, int) {
+		if !tokenStart {
+			return "", 0
+		}
+
+		m := cellRef.FindStringSubmatch(s)
+		if m == nil || !endsReference(s[len(m[0]):]) {
+			return "", 0
+		}
+
+		pos, err := sheets.ParsePos(m[2] + m[4])
+		if err != nil {
+			return "", 0
+		}
+
+		if m[1] == "" {
+			pos.Col += cols
+		}
+
+		if m[3] == "" {
+			pos.Row += rows
+		}
+
+		if pos.Row < 0 || pos.Col < 0 {
+			return "#REF!", len(m[0])
+		}
+
+		shifted := pos.String()
+		split := strings.IndexAny(shifted, "0123456789")
+		return m[1] + shifted[:split] + m[3] + shifted[split:], len(m[0])
+	})
+}
+
+// normalizeFormula converts the text of a formula as it is stored in a
+// workbook into the form understood by sheets.ParseFormula, removing the "$"
+// markers of absolute references and the prefixes of newer functions, and
+// escaping quotes within quoted text with a backslash rather than by doubling
+// them.
+func normalizeFormula(text string) string {
+	return escapeQuoted(rewriteUnquoted(text, func(s string, tokenStart bool) (string, int) {
+		switch {
+		case s[0] == '$':
+			return "", 1
+		case !tokenStart:
+			return "", 0
+		case strings.HasPrefix(s, "_xlfn."):
+			return "", len("_xlfn.")
+		case strings.HasPrefix(s, "_xlws."):
+			return "", len("_xlws.")
+		default:
+			return "", 0
+		}
+	}))
+}
+
+// escapeQuoted rewrites the quoted text and sheet names in a formula so that
+// quotes are escaped by a backslash rather than by doubling them, escaping any
+// backslashes as well.
+func escapeQuoted(text string) string {
+	if !strings.ContainsAny(text, `"'`) {
+		return text
+	}
+
+	var sb strings.Builder
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote == 0:
+			if c == '"' || c == '\'' {
+				quote = c
+			}
+		case c == '\\':
+			sb.WriteByte('\\')
+		case c == quote && i+1 < len(text) && text[i+1] == quote:
+			sb.WriteByte('\\')
+			i++
+		case c == quote:
+			quote = 0
+		}
+
+		sb.WriteByte(c)
+	}
+
+	return sb.String()
+}
+
+// rewriteUnquoted calls rewrite at each character of a formula that is outside
+// of quoted text and sheet names, with whether the character starts a token.
+// If rewrite returns a positive length, that many bytes are replaced by the
+// returned text.
+func rewriteUnquoted(text string, rewrite func(s string, tokenStart bool) (string, int)) string {
+	var sb strings.Builder
+	tokenStart := true
+	for i := 0; i < len(text); {
+		if c := text[i]; c == '"' || c == '\'' {
+			end := office.QuotedEnd(text, i)
+			sb.WriteString(text[i:end])
+			i, tokenStart = end, false
+			continue
+		}
+
+		if replacement, n := rewrite(text[i:], tokenStart); n > 0 {
+			sb.WriteString(replacement)
+			i += n
+			continue
+		}
+
+		tokenStart = !isNameChar(text[i])
+		sb.WriteByte(text[i])
+		i++
+	}
+
+	return sb.String()
+}
+
+// endsReference returns true if the text following a possible cell reference
+// shows that it is one, rather than part of a function or defined name.
+func endsReference(rest string) bool {
+	return rest == "" || (!isNameChar(rest[0]) && rest[0] != '(')
+}
+
+func isNameChar(c byte) bool {
+	return c == '_' || c == '.' || c == '\\' ||
+		('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
+
+// parseSharedIndex returns the index of a shared formula.
+func parseSharedIndex(f *xmlFormula) (int, bool) {
+	if f == nil || f.Type != "shared" || f.Index == nil {
+		return 0, false
+	}
+
+	return *f.Index, true
+}
+
+// sheetReference parses the reference of a defined name, such as
+// Sheet1!$A$1:$B$4 or 'My Sheet'!$A:$A, into a sheet name and range.
+func sheetReference(ref string) (string, sheets.Range, bool) {
+	i := strings.LastIndexByte(ref, '!')
+	if i < 0 {
+		return "", sheets.Range{}, false
+	}
+
+	name, text := ref[:i], strings.ReplaceAll(ref[i+1:], "$", "")
+	if strings.HasPrefix(name, "'") && strings.HasSuffix(name, "'") && len(name) >= 2 {
+		name = strings.ReplaceAll(name[1:len(name)-1], "''", "'")
+	}
+
+	if !strings.Contains(text, ":") {
+		pos, err := sheets.ParsePos(text)
+		if err != nil {
+			return "", sheets.Range{}, false
+		}
+
+		text = pos.String() + ":" + pos.String()
+	}
+
+	r, err := sheets.ParseRange(text)
+	if err != nil {
+		return "", sheets.Range{}, false
+	}
+
+	return name, r, true
+}
+
+// futureFunctions are the functions added to Excel after the original XLSX
+// format, whose names are stored with an "_xlfn." prefix.
+var futureFunctions = map[string]bool{
+	"CONCAT":   true,
+	"IFS":      true,
+	"MAXIFS":   true,
+	"MINIFS":   true,
+	"SWITCH":   true,
+	"TEXTJOIN": true,
+	"XLOOKUP":  true,
+	"XMATCH":   true,
+}
+
+// excelSyntax is the syntax of formulas as they are stored in a workbook: in
+// the canonical English form, with sheet names in single quotes and quotes in
+// text escaped by doubling them.
+var excelSyntax = &office.FormulaSyntax{
+	ArgumentSeparator: ',',
+	ColumnSeparator:   ',',
+	RowSeparator:      ';',
+	SheetSeparator:    "!",
+	FunctionPrefix:    "_xlfn.",
+	PrefixedFunctions: futureFunctions,
+	True:              "TRUE",
+	False:             "FALSE",
+	Time:              excelTime,
+}
+
+// excelFormula returns the text of a formula as it is stored in a workbook,
+// without the leading "=".
+func excelFormula(f sheets.Formula, settings sheets.CalcSettings) string {
+	return excelSyntax.Format(f, settings)
+}
+
+// excelTime returns a time constant, which Excel does not have, as a serial
+// number using the date system and time zone in the settings.
+func excelTime(t time.Time, settings sheets.CalcSettings) string {
+	serial := settings.DateSystem.ToExcelTimeIn(t, office.Location(settings))
+	return strconv.FormatFloat(serial, 'f', -1, 64)
+}
@@ -0,0 +1,110 @@
+package xlsx
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mmihic/sheets/src/pkg/sheets"
+)
+
+func TestShiftFormula(t *testing.T) {
+	for _, tt := range []struct {
+		text       string
+		rows, cols int
+		expected   string
+	}{
+		{"A1*B2", 0, 0, "A1*B2"},
+		{"A1*B2", 2, 1, "B3*C4"},
+		{"$A1*B$2+$C$3", 2, 1, "$A3*C$2+$C$3"},
+		{"SUM(A1:A4)", 1, 0, "SUM(A2:A5)"},
+		{`LOG10(A1)&"A1"`, 1, 0, `LOG10(A2)&"A1"`},
+		{"'Sheet A1'!A1+Sheet1!B1", 1, 0, "'Sheet A1'!A2+Sheet1!B2"},
+		{"Z1+AZ1", 0, 1, "AA1+BA1"},
+		{"A1", -1, 0, "#REF!"},
+	} {
+		assert.Equal(t, tt.expected, shiftFormula(tt.text, tt.rows, tt.cols), tt.text)
+	}
+}
+
+func TestNormalizeFormula(t *testing.T) {
+	for _, tt := range []struct {
+		text     string
+		expected string
+	}{
+		{"$A$1+A$2+$B3", "A1+A2+B3"},
+		{`"$5"&$A$1`, `"$5"&A1`},
+		{"_xlfn.CONCAT(A1,_xlfn._xlws.FILTER(B1:B4,C1:C4))", "CONCAT(A1,FILTER(B1:B4,C1:C4))"},
+		{"'$ Sheet'!$A$1", "'$ Sheet'!A1"},
+		{"MY_xlfn.X(1)", "MY_xlfn.X(1)"},
+		{`"say ""hi"" \o/"&'It''s'!A1`, `"say \"hi\" \\o/"&'It\'s'!A1`},
+	} {
+		assert.Equal(t, tt.expected, normalizeFormula(tt.text), tt.text)
+	}
+}
+
+func TestSheetReference(t *testing.T) {
+	for _, tt := range []struct {
+		ref      string
+		sheet    string
+		expected string
+	}{
+		{"Sheet1!$A$1:$B$4", "Sheet1", "A1:B4"},
+		{"'My ''Sheet'''!$C$2", "My 'Sheet'", "C2:C2"},
+	} {
+		name, r, ok := sheetReference(tt.ref)
+		require.True(t, ok, tt.ref)
+		assert.Equal(t, tt.sheet, name, tt.ref)
+
+		expected, err := sheets.ParseRange(tt.expected)
+		require.NoError(t, err)
+		assert.Equal(t, expected, r, tt.ref)
+	}
+
+	for _, ref := range []string{"0.5", "Sheet1!#REF!"} {
+		_, _, ok := sheetReference(ref)
+		assert.False(t, ok, ref)
+	}
+}
+
+func TestExcelFormula(t *testing.T) {
+	for _, tt := range []struct {
+		text     string
+		expected string
+	}{
+		{"SUM(A1:B4, 'My Sheet'!C3, `It's`!D:D)", "SUM(A1:B4,'My Sheet'!C3,'It''s'!D:D)"},
+		{`CONCAT("say \"hi\"", TRUE, {1,2;3,4})`, `_xlfn.CONCAT("say ""hi""",TRUE,{1,2;3,4})`},
+		{"(A1+B1)*(C1-2)", "(A1+B1)*(C1-2)"},
+		{"A1*B1+C1/2", "(A1*B1)+(C1/2)"},
+		{"Totals >= 0.000001", "Totals>=0.000001"},
+	} {
+		f, err := sheets.ParseFormula(tt.text)
+		require.NoError(t, err, tt.text)
+		assert.Equal(t, tt.expected, excelFormula(f, sheets.CalcSettings{}), tt.text)
+
+		// Formulas read back as the same formula
+		parsed, err := sheets.ParseFormula(normalizeFormula(tt.expected))
+		require.NoError(t, err, tt.expected)
+		assert.Equal(t, f, parsed, tt.expected)
+	}
+}
+
+func TestExcelFormula_Times(t *testing.T) {
+	f := &sheets.Expression{
+		Left:     &sheets.CellReference{Pos: sheets.Pos{}},
+		Operator: sheets.Gt,
+		Right:    &sheets.Constant{Value: sheets.TimeValue(time.Date(2024, time.March, 5, 18, 0, 0, 0, time.UTC))},
+	}
+
+	assert.Equal(t, "A1>45356.75", excelFormula(f, sheets.CalcSettings{}))
+	assert.Equal(t, "A1>43894.75", excelFormula(f, sheets.CalcSettings{DateSystem: sheets.Date1904}))
+	assert.Equal(t, "A1>45356.5", excelFormula(f, sheets.CalcSettings{Location: time.FixedZone("", -6*3600)}))
+}
+
+func TestAbsoluteReference(t *testing.T) {
+	assert.Equal(t, "'Data'!$A$1:$B$4", excelSyntax.AbsoluteReference("Data", mustParseRange(t, "A1:B4")))
+	assert.Equal(t, "'My Sheet'!$C:$C", excelSyntax.AbsoluteReference("My Sheet", mustParseRange(t, "C:C")))
+	assert.Equal(t, "'Data'!$2:$5", excelSyntax.AbsoluteReference("Data", mustParseRange(t, "2:5")))
+}
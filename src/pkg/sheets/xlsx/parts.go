@@ -0,0 +1,118 @@
+package xlsx
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// The XML parts of a workbook, covering only the elements and attributes
+// that are read. Relationship IDs are matched by local name, so that both the
+// transitional and strict namespaces are understood.
+
+type xmlRelationships struct {
+	Relationships []xmlRelationship `xml:"Relationship"`
+}
+
+type xmlRelationship struct {
+	ID     string `xml:"Id,attr"`
+	Type   string `xml:"Type,attr"`
+	Target string `xml:"Target,attr"`
+}
+
+type xmlWorkbook struct {
+	Properties struct {
+		Date1904 bool `xml:"date1904,attr"`
+	} `xml:"workbookPr"`
+	Sheets       []xmlSheet       `xml:"sheets>sheet"`
+	DefinedNames []xmlDefinedName `xml:"definedNames>definedName"`
+}
+
+type xmlSheet struct {
+	Name string `xml:"name,attr"`
+	RID  string `xml:"id,attr"`
+}
+
+type xmlDefinedName struct {
+	Name         string `xml:"name,attr"`
+	LocalSheetID *int   `xml:"localSheetId,attr"`
+	Hidden       bool   `xml:"hidden,attr"`
+	Value        string `xml:",chardata"`
+}
+
+type xmlSharedStrings struct {
+	Items []xmlRichText `xml:"si"`
+}
+
+// An xmlRichText is a shared or inline string, which is either plain text or
+// a set of runs of formatted text. Phonetic runs are ignored.
+type xmlRichText struct {
+	Text *string `xml:"t"`
+	Runs []struct {
+		Text string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (rt *xmlRichText) String() string {
+	if rt.Text != nil {
+		return unescapeText(*rt.Text)
+	}
+
+	var sb strings.Builder
+	for _, run := range rt.Runs {
+		sb.WriteString(run.Text)
+	}
+
+	return unescapeText(sb.String())
+}
+
+type xmlStyleSheet struct {
+	NumFmts []struct {
+		ID   int    `xml:"numFmtId,attr"`
+		Code string `xml:"formatCode,attr"`
+	} `xml:"numFmts>numFmt"`
+	CellXfs []struct {
+		NumFmtID int `xml:"numFmtId,attr"`
+	} `xml:"cellXfs>xf"`
+}
+
+type xmlWorksheet struct {
+	Rows []xmlRow `xml:"sheetData>row"`
+}
+
+type xmlRow struct {
+	Ref   int       `xml:"r,attr"`
+	Cells []xmlCell `xml:"c"`
+}
+
+type xmlCell struct {
+	Ref     string       `xml:"r,attr"`
+	Style   int          `xml:"s,attr"`
+	Type    string       `xml:"t,attr"`
+	Formula *xmlFormula  `xml:"f"`
+	Value   *string      `xml:"v"`
+	Inline  *xmlRichText `xml:"is"`
+}
+
+type xmlFormula struct {
+	Type  string `xml:"t,attr"`
+	Ref   string `xml:"ref,attr"`
+	Index *int   `xml:"si,attr"`
+	Text  string `xml:",chardata"`
+}
+
+// escapedChar matches the _xHHHH_ escapes used for characters that cannot be
+// written in XML.
+var escapedChar = regexp.MustCompile(`_x([0-9A-Fa-f]{4})_`)
+
+// unescapeText decodes the _xHHHH_ escapes in text.
+func unescapeText(s string) string {
+	if !strings.Contains(s, "_x") {
+		return s
+	}
+
+	return escapedChar.ReplaceAllStringFunc(s, func(m string) string {
+		r, _ := strconv.ParseUint(m[2:6], 16, 16)
+		return string(rune(r))
+	})
+}
@@ -0,0 +1,387 @@
+// Package xlsx reads and writes Excel workbooks stored in the Office Open XML
+// (.xlsx) format, using only the standard library.
+package xlsx
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/mmihic/sheets/src/pkg/sheets"
+	"github.com/mmihic/sheets/src/pkg/sheets/internal/office"
+)
+
+// A Workbook is a workbook read from an XLSX file. It is a sheets.Workbook
+// whose sheets are MutableSheets holding the values and formulas of each
+// worksheet, and whose named ranges are the defined names of the workbook that
+// refer to ranges of cells. Formulas are computed live; the results Excel
+// cached when the file was saved are available from CachedSheet.
+type Workbook struct {
+	*office.Workbook
+}
+
+// Open reads the XLSX file at the given path.
+func Open(name string) (*Workbook, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() { _ = f.Close() }()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	return Read(f, info.Size())
+}
+
+// Read reads an XLSX file of the given size.
+func Read(r io.ReaderAt, size int64) (*Workbook, error) {
+	z, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("invalid xlsx file: %w", err)
+	}
+
+	rd := &reader{files: make(map[string]*zip.File, len(z.File))}
+	for _, f := range z.File {
+		rd.files[strings.TrimPrefix(f.Name, "/")] = f
+	}
+
+	return rd.read()
+}
+
+// A reader reads the parts of an XLSX file.
+type reader struct {
+	files         map[string]*zip.File
+	sharedStrings []string
+	styles        []cellStyle
+	dateSystem    sheets.DateSystem
+}
+
+const (
+	relTypeOfficeDocument = "/officeDocument"
+	relTypeWorksheet      = "/worksheet"
+	relTypeSharedStrings  = "/sharedStrings"
+	relTypeStyles         = "/styles"
+)
+
+func (rd *reader) read() (*Workbook, error) {
+	rootRels, err := rd.relationships("")
+	if err != nil {
+		return nil, err
+	}
+
+	workbookPath := "xl/workbook.xml"
+	for _, rel := range rootRels {
+		if strings.HasSuffix(rel.Type, relTypeOfficeDocument) {
+			workbookPath = rel.target("")
+		}
+	}
+
+	var xwb xmlWorkbook
+	if err := rd.decode(workbookPath, &xwb, true); err != nil {
+		return nil, err
+	}
+
+	if xwb.Properties.Date1904 {
+		rd.dateSystem = sheets.Date1904
+	}
+
+	rels, err := rd.relationships(workbookPath)
+	if err != nil {
+		return nil, err
+	}
+
+	relsByID := make(map[string]xmlRelationship, len(rels))
+	for _, rel := range rels {
+		relsByID[rel.ID] = rel
+		switch {
+		case strings.HasSuffix(rel.Type, relTypeSharedStrings):
+			if err := rd.readSharedStrings(rel.target(workbookPath)); err != nil {
+				return nil, err
+			}
+		case strings.HasSuffix(rel.Type, relTypeStyles):
+			var ss xmlStyleSheet
+			if err := rd.decode(rel.target(workbookPath), &ss, true); err != nil {
+				return nil, err
+			}
+
+			rd.styles = cellStyles(&ss)
+		}
+	}
+
+	wb := &Workbook{Workbook: office.NewWorkbook()}
+
+	wb.SetDateSystem(rd.dateSystem)
+	for _, xs := range xwb.Sheets {
+		rel, ok := relsByID[xs.RID]
+		if !ok || !strings.HasSuffix(rel.Type, relTypeWorksheet) {
+			// Chart sheets and dialog sheets have no cells
+			continue
+		}
+
+		if err := rd.readWorksheet(wb, xs.Name, rel.target(workbookPath)); err != nil {
+			return nil, fmt.Errorf("invalid sheet '%s': %w", xs.Name, err)
+		}
+	}
+
+	for _, dn := range xwb.DefinedNames {
+		rd.defineName(wb, &xwb, dn)
+	}
+
+	return wb, nil
+}
+
+// defineName adds a defined name that refers to a range of cells as a named
+// range. Names local to a sheet are added unless there is a global name with
+// the same name. Built-in names such as print areas, and names that refer to
+// constants or formulas, are skipped.
+func (rd *reader) defineName(wb *Workbook, xwb *xmlWorkbook, dn xmlDefinedName) {
+	if strings.HasPrefix(dn.Name, "_xlnm.") {
+		return
+	}
+
+	sheetName, r, ok := sheetReference(strings.TrimSpace(dn.Value))
+	if !ok {
+		return
+	}
+
+	if dn.LocalSheetID != nil {
+		for _, other := range xwb.DefinedNames {
+			if other.LocalSheetID == nil && strings.EqualFold(other.Name, dn.Name) {
+				return
+			}
+		}
+	}
+
+	wb.SetNamedRange(dn.Name, sheets.NamedRange{Sheet: sheetName, Range: r})
+}
+
+func (rd *reader) readSharedStrings(name string) error {
+	var sst xmlSharedStrings
+	if err := rd.decode(name, &sst, true); err != nil {
+		return err
+	}
+
+	rd.sharedStrings = make([]string, len(sst.Items))
+	for i := range sst.Items {
+		rd.sharedStrings[i] = sst.Items[i].String()
+	}
+
+	return nil
+}
+
+// A sharedFormula is the master formula of a set of cells that share it.
+type sharedFormula struct {
+	text string
+	pos  sheets.Pos
+}
+
+func (rd *reader) readWorksheet(wb *Workbook, name, partName string) error {
+	var ws xmlWorksheet
+	if err := rd.decode(partName, &ws, true); err != nil {
+		return err
+	}
+
+	var (
+		values   [][]sheets.Value
+		formulas = make(map[sheets.Pos]string)
+		shared   = make(map[int]sharedFormula)
+	)
+
+	row := -1
+	for _, xr := range ws.Rows {
+		row++
+		if xr.Ref > 0 {
+			row = xr.Ref - 1
+		}
+
+		col := -1
+		for _, xc := range xr.Cells {
+			col++
+			pos := sheets.Pos{Row: row, Col: col}
+			if xc.Ref != "" {
+				p, err := sheets.ParsePos(xc.Ref)
+				if err != nil {
+					return fmt.Errorf("invalid cell reference '%s'", xc.Ref)
+				}
+
+				pos, col = p, p.Col
+			}
+
+			// Cells that are only styled hold nothing, and are left out so
+			// that formatted but empty areas do not extend the sheet
+			if xc.Value == nil && xc.Formula == nil && xc.Type != "inlineStr" {
+				continue
+			}
+
+			v, err := rd.cellValue(&xc)
+			if err != nil {
+				return fmt.Errorf("invalid cell %s: %w", pos, err)
+			}
+
+			values = office.SetValue(values, pos, v)
+			if text, ok := cellFormula(&xc, pos, shared); ok {
+				formulas[pos] = text
+			}
+		}
+	}
+
+	return wb.AddSheetValues(name, values, formulas, func(text string) (sheets.Formula, error) {
+		f, err := sheets.ParseFormula(normalizeFormula(text))
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse formula '%s': %w", text, err)
+		}
+
+		return f, nil
+	})
+}
+
+// cellFormula returns the text of the formula in a cell, expanding shared
+// formulas from their master cell.
+func cellFormula(xc *xmlCell, pos sheets.Pos, shared map[int]sharedFormula) (string, bool) {
+	f := xc.Formula
+	if f == nil {
+		return "", false
+	}
+
+	si, isShared := parseSharedIndex(f)
+	if !isShared {
+		return f.Text, f.Text != ""
+	}
+
+	if f.Text != "" {
+		shared[si] = sharedFormula{text: f.Text, pos: pos}
+		return f.Text, true
+	}
+
+	master, ok := shared[si]
+	if !ok {
+		return "", false
+	}
+
+	return shiftFormula(master.text, pos.Row-master.pos.Row, pos.Col-master.pos.Col), true
+}
+
+// cellValue returns the value of a cell, or its cached result if it contains a
+// formula.
+func (rd *reader) cellValue(xc *xmlCell) (sheets.Value, error) {
+	if xc.Type == "inlineStr" {
+		if xc.Inline == nil {
+			return sheets.StringValue(""), nil
+		}
+
+		return sheets.StringValue(xc.Inline.String()), nil
+	}
+
+	if xc.Value == nil {
+		return sheets.BlankValue{}, nil
+	}
+
+	text := *xc.Value
+	switch xc.Type {
+	case "s":
+		i, err := strconv.Atoi(strings.TrimSpace(text))
+		if err != nil || i < 0 || i >= len(rd.sharedStrings) {
+			return nil, fmt.Errorf("invalid shared string index '%s'", text)
+		}
+
+		return sheets.StringValue(rd.sharedStrings[i]), nil
+	case "str":
+		return sheets.StringValue(unescapeText(text)), nil
+	case "b":
+		return sheets.BoolValue(strings.TrimSpace(text) == "1"), nil
+	case "e":
+		return sheets.ErrorValue{Err: sheets.ErrorForCode(strings.TrimSpace(text))}, nil
+	case "d":
+		tm, err := sheets.ParseTime(strings.TrimSpace(text))
+		if err != nil {
+			return nil, err
+		}
+
+		return sheets.TimeValue(tm), nil
+	case "", "n":
+		n, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number '%s'", text)
+		}
+
+		return rd.numberValue(n, xc.Style), nil
+	default:
+		return nil, fmt.Errorf("unknown cell type '%s'", xc.Type)
+	}
+}
+
+// numberValue returns the value of a number in a cell with the given style.
+// Numbers with date and time formats are TimeValues, while numbers with other
+// formats are FormattedNumbers.
+func (rd *reader) numberValue(n float64, style int) sheets.Value {
+	if style < 0 || style >= len(rd.styles) || rd.styles[style].format == "" {
+		return sheets.Float64Value(n)
+	}
+
+	st := rd.styles[style]
+	switch {
+	case st.isDateTime && n >= 0:
+		return sheets.TimeValue(rd.dateSystem.FromExcelTime(n))
+	case st.isDateTime || st.format == "@":
+		return sheets.Float64Value(n)
+	default:
+		return sheets.FormattedNumber{Number: n, Format: st.format}
+	}
+}
+
+// relationships returns the relationships of a part, or of the package if the
+// part name is empty. Parts without relationships have none.
+func (rd *reader) relationships(partName string) ([]xmlRelationship, error) {
+	dir, base := path.Split(partName)
+	var rels xmlRelationships
+	if err := rd.decode(path.Join(dir, "_rels", base+".rels"), &rels, false); err != nil {
+		return nil, err
+	}
+
+	return rels.Relationships, nil
+}
+
+// target returns the name of the part that a relationship of the given part
+// refers to.
+func (rel xmlRelationship) target(partName string) string {
+	if strings.HasPrefix(rel.Target, "/") {
+		return strings.TrimPrefix(rel.Target, "/")
+	}
+
+	return path.Join(path.Dir(partName), rel.Target)
+}
+
+// decode decodes an XML part. Returns an error if the part is missing and
+// required.
+func (rd *reader) decode(name string, v any, required bool) error {
+	f, ok := rd.files[name]
+	if !ok {
+		if required {
+			return fmt.Errorf("invalid xlsx file: missing %s", name)
+		}
+
+		return nil
+	}
+
+	r, err := f.Open()
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = r.Close() }()
+
+	if err := xml.NewDecoder(r).Decode(v); err != nil {
+		return fmt.Errorf("invalid xlsx file: %s: %w", name, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,297 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mmihic/sheets/src/pkg/sheets"
+)
+
+const (
+	testRootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+</Relationships>`
+
+	testWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="/xl/worksheets/sheet2.xml"/>
+<Relationship Id="rId3" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/sharedStrings" Target="sharedStrings.xml"/>
+<Relationship Id="rId4" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>
+</Relationships>`
+
+	testWorkbook = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<workbookPr/>
+<sheets>
+<sheet name="Data" sheetId="1" r:id="rId1"/>
+<sheet name="My Sheet" sheetId="2" r:id="rId2"/>
+</sheets>
+<definedNames>
+<definedName name="_xlnm.Print_Area" localSheetId="0">Data!$A$1:$D$5</definedName>
+<definedName name="Prices">Data!$B$2:$B$4</definedName>
+<definedName name="Prices" localSheetId="1">'My Sheet'!$A$1</definedName>
+<definedName name="Base">'My Sheet'!$A$1</definedName>
+<definedName name="Rate">0.5</definedName>
+</definedNames>
+</workbook>`
+
+	testSharedStrings = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" count="7" uniqueCount="7">
+<si><t>item</t></si>
+<si><t>price</t></si>
+<si><t>qty</t></si>
+<si><t>total</t></si>
+<si><r><t>app</t></r><r><rPr><b/></rPr><t>les</t></r></si>
+<si><t xml:space="preserve">pears_x000D_ </t></si>
+<si><t>=not a formula</t></si>
+</sst>`
+
+	testStyles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<numFmts count="1"><numFmt numFmtId="164" formatCode="yyyy\-mm\-dd hh:mm"/></numFmts>
+<cellXfs count="6">
+<xf numFmtId="0"/>
+<xf numFmtId="14" applyNumberFormat="1"/>
+<xf numFmtId="164" applyNumberFormat="1"/>
+<xf numFmtId="4" applyNumberFormat="1"/>
+<xf numFmtId="9" applyNumberFormat="1"/>
+<xf numFmtId="49" applyNumberFormat="1"/>
+</cellXfs>
+</styleSheet>`
+
+	testSheet1 = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<sheetData>
+<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c><c r="C1" t="s"><v>2</v></c><c r="D1" t="s"><v>3</v></c></row>
+<row r="2"><c r="A2" t="s"><v>4</v></c><c r="B2" s="3"><v>0.5</v></c><c r="C2"><v>3</v></c><c r="D2"><f t="shared" ref="D2:D4" si="0">$B2*C2</f><v>1.5</v></c></row>
+<row r="3"><c r="A3" t="s"><v>5</v></c><c r="B3" s="3"><v>1.25</v></c><c r="C3"><v>2</v></c><c r="D3"><f t="shared" si="0"/><v>2.5</v></c></row>
+<row r="4"><c r="A4" t="inlineStr"><is><t>plums</t></is></c><c r="B4" s="3"><v>2</v></c><c r="C4"><v>1</v></c><c r="D4"><f t="shared" si="0"/><v>2</v></c></row>
+<row r="5"><c r="A5" t="b"><v>1</v></c><c r="B5" s="1"><v>45356</v></c><c r="C5" s="2"><v>45356.5</v></c><c r="D5"><f>SUM($D$2:D4)</f><v>6</v></c></row>
+<row r="6"><c r="A6" t="e"><v>#DIV/0!</v></c><c r="B6" t="str"><f>_xlfn.CONCAT(A2," $ ",A4)</f><v>apples $ plums</v></c><c r="C6"><f>NOTAFUNC(</f><v>7</v></c><c><f>SUM(Prices)</f><v>3.75</v></c><c><f>'My Sheet'!A1*2</f><v>20</v></c></row>
+<row r="8"><c r="A8" s="4"><v>0.25</v></c><c r="B8" s="5"><v>12</v></c><c r="C8" s="3"/><c r="H8" s="3"/></row>
+<row r="1000"><c r="A1000" s="3"/><c r="XFD1000" s="1"/></row>
+</sheetData>
+</worksheet>`
+
+	testSheet2 = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<sheetData>
+<row><c><v>10</v></c><c t="s"><v>6</v></c></row>
+</sheetData>
+</worksheet>`
+)
+
+// buildXLSX returns an XLSX file made up of the given parts.
+func buildXLSX(t *testing.T, parts map[string]string) []byte {
+	var buf bytes.Buffer
+	z := zip.NewWriter(&buf)
+	for name, content := range parts {
+		w, err := z.Create(name)
+		require.NoError(t, err)
+
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, z.Close())
+	return buf.Bytes()
+}
+
+func testParts() map[string]string {
+	return map[string]string{
+		"_rels/.rels":                testRootRels,
+		"xl/_rels/workbook.xml.rels": testWorkbookRels,
+		"xl/workbook.xml":            testWorkbook,
+		"xl/sharedStrings.xml":       testSharedStrings,
+		"xl/styles.xml":              testStyles,
+		"xl/worksheets/sheet1.xml":   testSheet1,
+		"xl/worksheets/sheet2.xml":   testSheet2,
+	}
+}
+
+func readTestWorkbook(t *testing.T, parts map[string]string) *Workbook {
+	data := buildXLSX(t, parts)
+	wb, err := Read(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	return wb
+}
+
+func TestRead(t *testing.T) {
+	wb := readTestWorkbook(t, testParts())
+	assert.Equal(t, []string{"Data", "My Sheet"}, wb.SheetNames())
+
+	// Cells that are only styled do not extend the sheet
+	data := wb.Sheet("Data")
+	require.NotNil(t, data)
+	assert.Equal(t, sheets.Dimensions{EndRow: 7, EndCol: 4}, data.Dimensions())
+
+	assertCells(t, data, map[string]sheets.Value{
+		"A1": sheets.StringValue("item"),
+		"A2": sheets.StringValue("apples"),
+		"A3": sheets.StringValue("pears\r "),
+		"A4": sheets.StringValue("plums"),
+		"B2": sheets.FormattedNumber{Number: 0.5, Format: "#,##0.00"},
+		"C2": sheets.Float64Value(3),
+		"D2": sheets.Float64Value(1.5),
+		"D3": sheets.Float64Value(2.5),
+		"D4": sheets.Float64Value(2),
+		"A5": sheets.BoolValue(true),
+		"B5": sheets.TimeValue(time.Date(2024, time.March, 5, 0, 0, 0, 0, time.UTC)),
+		"C5": sheets.TimeValue(time.Date(2024, time.March, 5, 12, 0, 0, 0, time.UTC)),
+		"D5": sheets.Float64Value(6),
+		"A6": sheets.ErrorValue{Err: sheets.ErrDivideByZero},
+		"B6": sheets.StringValue("apples $ plums"),
+		"C6": sheets.Float64Value(7),
+		"D6": sheets.Float64Value(3.75),
+		"E6": sheets.Float64Value(20),
+		"A7": sheets.BlankValue{},
+		"A8": sheets.FormattedNumber{Number: 0.25, Format: "0%"},
+		"B8": sheets.Float64Value(12),
+		"C8": sheets.BlankValue{},
+	})
+
+	// Text starting with "=" is not a formula
+	assertCells(t, wb.Sheet("My Sheet"), map[string]sheets.Value{
+		"A1": sheets.Float64Value(10),
+		"B1": sheets.StringValue("=not a formula"),
+	})
+}
+
+func TestRead_Formulas(t *testing.T) {
+	wb := readTestWorkbook(t, testParts())
+	data := wb.Sheet("Data").(sheets.MutableSheet)
+
+	for posText, expected := range map[string]string{
+		"D2": "B2*C2",
+		"D3": "B3*C3",
+		"D4": "B4*C4",
+		"D5": "SUM(D2:D4)",
+		"B6": `CONCAT(A2," $ ",A4)`,
+		"D6": "SUM(Prices)",
+		"E6": "'My Sheet'!A1*2",
+	} {
+		f, ok := data.Formula(mustParsePos(t, posText))
+		require.True(t, ok, posText)
+
+		expectedFormula, err := sheets.ParseFormula(expected)
+		require.NoError(t, err)
+		assert.Equal(t, expectedFormula, f, posText)
+	}
+
+	_, ok := data.Formula(mustParsePos(t, "C6"))
+	assert.False(t, ok)
+
+	require.Len(t, wb.FormulaErrors(), 1)
+	assert.Contains(t, wb.FormulaErrors()[sheets.CellAddress{Sheet: "Data", Pos: mustParsePos(t, "C6")}].Error(),
+		"unable to parse formula 'NOTAFUNC('")
+
+	// Formulas are computed live, while the cached results are unchanged
+	ctx := context.Background()
+	require.NoError(t, data.Set(ctx, mustParsePos(t, "C2"), sheets.Float64Value(10)))
+	assertCells(t, data, map[string]sheets.Value{
+		"D2": sheets.Float64Value(5),
+		"D5": sheets.Float64Value(9.5),
+	})
+
+	assertCells(t, wb.CachedSheet("Data"), map[string]sheets.Value{
+		"C2": sheets.Float64Value(3),
+		"D2": sheets.Float64Value(1.5),
+		"D5": sheets.Float64Value(6),
+		"C6": sheets.Float64Value(7),
+	})
+
+	assert.Nil(t, wb.CachedSheet("Missing"))
+}
+
+func TestRead_DefinedNames(t *testing.T) {
+	wb := readTestWorkbook(t, testParts())
+
+	named, ok := wb.NamedRange("Prices")
+	require.True(t, ok)
+	assert.Equal(t, sheets.NamedRange{Sheet: "Data", Range: mustParseRange(t, "B2:B4")}, named)
+
+	named, ok = wb.NamedRange("Base")
+	require.True(t, ok)
+	assert.Equal(t, sheets.NamedRange{Sheet: "My Sheet", Range: mustParseRange(t, "A1:A1")}, named)
+
+	_, ok = wb.NamedRange("Rate")
+	assert.False(t, ok)
+
+	_, ok = wb.NamedRange("_xlnm.Print_Area")
+	assert.False(t, ok)
+}
+
+func TestRead_Date1904(t *testing.T) {
+	parts := testParts()
+	parts["xl/workbook.xml"] = `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<workbookPr date1904="1"/>
+<sheets><sheet name="Data" sheetId="1" r:id="rId1"/></sheets>
+</workbook>`
+
+	wb := readTestWorkbook(t, parts)
+	assert.Equal(t, sheets.Date1904, wb.Evaluator().DateSystem)
+	assertCells(t, wb.Sheet("Data"), map[string]sheets.Value{
+		"B5": sheets.TimeValue(time.Date(2028, time.March, 6, 0, 0, 0, 0, time.UTC)),
+	})
+}
+
+func TestRead_Errors(t *testing.T) {
+	_, err := Read(bytes.NewReader([]byte("not a zip")), 9)
+	assert.ErrorContains(t, err, "invalid xlsx file")
+
+	parts := testParts()
+	delete(parts, "xl/workbook.xml")
+	data := buildXLSX(t, parts)
+	_, err = Read(bytes.NewReader(data), int64(len(data)))
+	assert.EqualError(t, err, "invalid xlsx file: missing xl/workbook.xml")
+
+	parts = testParts()
+	parts["xl/worksheets/sheet2.xml"] = `<worksheet><sheetData><row><c t="s"><v>99</v></c></row></sheetData></worksheet>`
+	data = buildXLSX(t, parts)
+	_, err = Read(bytes.NewReader(data), int64(len(data)))
+	assert.EqualError(t, err, "invalid sheet 'My Sheet': invalid cell A1: invalid shared string index '99'")
+}
+
+func TestOpen(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "test.xlsx")
+	require.NoError(t, os.WriteFile(name, buildXLSX(t, testParts()), 0o600))
+
+	wb, err := Open(name)
+	require.NoError(t, err)
+	assertCells(t, wb.Sheet("Data"), map[string]sheets.Value{
+		"D5": sheets.Float64Value(6),
+	})
+
+	_, err = Open(filepath.Join(t.TempDir(), "missing.xlsx"))
+	assert.Error(t, err)
+}
+
+func assertCells(t *testing.T, s sheets.Sheet, expected map[string]sheets.Value) {
+	for posText, expectedValue := range expected {
+		v, err := s.Get(context.TODO(), mustParsePos(t, posText))
+		require.NoError(t, err)
+		assert.Equal(t, expectedValue, v, posText)
+	}
+}
+
+func mustParsePos(t *testing.T, s string) sheets.Pos {
+	pos, err := sheets.ParsePos(s)
+	require.NoError(t, err)
+	return pos
+}
+
+func mustParseRange(t *testing.T, s string) sheets.Range {
+	r, err := sheets.ParseRange(s)
+	require.NoError(t, err)
+	return r
+}
@@ -0,0 +1,94 @@
+package xlsx
+
+import (
+	"github.com/mmihic/sheets/src/pkg/sheets"
+)
+
+// builtinNumFmts are the codes of the number formats that are built into
+// Excel, keyed by ID, which are not written to the styles of a workbook.
+var builtinNumFmts = map[int]string{
+	0:  "General",
+	1:  "0",
+	2:  "0.00",
+	3:  "#,##0",
+	4:  "#,##0.00",
+	5:  `"$"#,##0_);("$"#,##0)`,
+	6:  `"$"#,##0_);[Red]("$"#,##0)`,
+	7:  `"$"#,##0.00_);("$"#,##0.00)`,
+	8:  `"$"#,##0.00_);[Red]("$"#,##0.00)`,
+	9:  "0%",
+	10: "0.00%",
+	11: "0.00E+00",
+	12: "# ?/?",
+	13: "# ??/??",
+	14: "mm-dd-yy",
+	15: "d-mmm-yy",
+	16: "d-mmm",
+	17: "mmm-yy",
+	18: "h:mm AM/PM",
+	19: "h:mm:ss AM/PM",
+	20: "h:mm",
+	21: "h:mm:ss",
+	22: "m/d/yy h:mm",
+	37: "#,##0 ;(#,##0)",
+	38: "#,##0 ;[Red](#,##0)",
+	39: "#,##0.00;(#,##0.00)",
+	40: "#,##0.00;[Red](#,##0.00)",
+	45: "mm:ss",
+	46: "[h]:mm:ss",
+	47: "mmss.0",
+	48: "##0.0E+0",
+	49: "@",
+}
+
+// isLocalizedDateFmt returns true for the IDs of the built-in formats that
+// display dates in a way that depends on the locale of Excel, whose codes are
+// not defined.
+func isLocalizedDateFmt(id int) bool {
+	return (id >= 27 && id <= 36) || (id >= 50 && id <= 58)
+}
+
+// A cellStyle is how the number in a cell is displayed.
+type cellStyle struct {
+	format     string
+	isDateTime bool
+}
+
+// cellStyles returns the style of each cell format in the stylesheet, in the
+// order they are referenced by cells.
+func cellStyles(ss *xmlStyleSheet) []cellStyle {
+	custom := make(map[int]string, len(ss.NumFmts))
+	for _, numFmt := range ss.NumFmts {
+		custom[numFmt.ID] = numFmt.Code
+	}
+
+	styles := make([]cellStyle, len(ss.CellXfs))
+	for i, xf := range ss.CellXfs {
+		styles[i] = styleForNumFmt(xf.NumFmtID, custom)
+	}
+
+	return styles
+}
+
+func styleForNumFmt(id int, custom map[int]string) cellStyle {
+	if isLocalizedDateFmt(id) {
+		return cellStyle{format: builtinNumFmts[14], isDateTime: true}
+	}
+
+	code, ok := custom[id]
+	if !ok {
+		code, ok = builtinNumFmts[id]
+	}
+
+	if !ok || code == "General" {
+		return cellStyle{}
+	}
+
+	nf, err := sheets.ParseNumberFormat(code)
+	if err != nil {
+		// Formats that cannot be understood are treated as General
+		return cellStyle{}
+	}
+
+	return cellStyle{format: code, isDateTime: nf.IsDateTime()}
+}
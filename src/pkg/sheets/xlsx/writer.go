@@ -0,0 +1,558 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mmihic/sheets/src/pkg/sheets"
+	"github.com/mmihic/sheets/src/pkg/sheets/internal/office"
+)
+
+// A WriteOption is an option for writing an XLSX file.
+type WriteOption func(opts *writeOptions)
+
+type writeOptions struct {
+	office.WriteOptions
+
+	header       bool
+	columnWidths map[int]float64
+}
+
+// WithSheets writes the sheets with the given names, in order. Required when
+// writing a DataSet that does not list its sheets with a SheetNames method.
+func WithSheets(names ...string) WriteOption {
+	return office.WithSheets[writeOptions](names...)
+}
+
+// WithHeader treats the first row of each sheet as a header, displaying it in
+// bold and keeping it in view when scrolling.
+func WithHeader() WriteOption {
+	return func(opts *writeOptions) {
+		opts.header = true
+	}
+}
+
+// WithColumnWidth sets the width of a column in every sheet, in characters.
+// Columns without a width are sized to fit their values.
+func WithColumnWidth(col int, width float64) WriteOption {
+	return func(opts *writeOptions) {
+		if opts.columnWidths == nil {
+			opts.columnWidths = make(map[int]float64)
+		}
+
+		opts.columnWidths[col] = width
+	}
+}
+
+const (
+	minColumnWidth = 8
+	maxColumnWidth = 60
+
+	dateFormat     = "yyyy-mm-dd"
+	dateTimeFormat = "yyyy-mm-dd hh:mm:ss"
+
+	// firstCustomNumFmt is the ID of the first number format that is not
+	// built into Excel.
+	firstCustomNumFmt = 164
+)
+
+// WriteFile writes the sheets of a DataSet to an XLSX file at the given path.
+func WriteFile(ctx context.Context, name string, ds sheets.DataSet, opts ...WriteOption) error {
+	return office.WriteFile(name, func(w io.Writer) error {
+		return Write(ctx, w, ds, opts...)
+	})
+}
+
+// WriteSheet writes a single sheet as an XLSX file, giving the sheet the
+// given name.
+func WriteSheet(ctx context.Context, w io.Writer, name string, s sheets.Sheet, opts ...WriteOption) error {
+	opts = append([]WriteOption{WithSheets(name)}, opts...)
+	return Write(ctx, w, office.SingleSheet(name, s), opts...)
+}
+
+// Write writes the sheets of a DataSet as an XLSX file.
+//
+// Values keep their types: numbers, booleans and text are written as such,
+// FormattedNumbers keep their number format, TimeValues are written as serial
+// numbers with a date format, and errors are written as error cells. Cells of
+// a FormulaSheet that contain formulas are written with the formula, in its
+// canonical English form, along with its computed value as the cached result.
+//
+// If the DataSet is a Workbook, its named ranges are written as defined names,
+// and its date system and time zone are used to convert times to serial
+// numbers.
+func Write(ctx context.Context, w io.Writer, ds sheets.DataSet, opts ...WriteOption) error {
+	var options writeOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	toWrite, err := office.Sheets(ds, options.SheetNames, sheetNaming)
+	if err != nil {
+		return err
+	}
+
+	wr := &writer{
+		z:           zip.NewWriter(w),
+		options:     options,
+		stringIndex: make(map[string]int),
+		numFmtIDs:   make(map[string]int),
+		styleIndex:  map[cellStyleKey]int{{}: 0},
+		styles:      []cellStyleKey{{}},
+		settings:    office.CalcSettings(ds),
+	}
+
+	for i, s := range toWrite {
+		if err := wr.writeWorksheet(ctx, i, s.Sheet); err != nil {
+			return fmt.Errorf("unable to write sheet '%s': %w", s.Name, err)
+		}
+	}
+
+	if err := wr.writeWorkbook(ds, toWrite); err != nil {
+		return err
+	}
+
+	if err := wr.writeSharedStrings(); err != nil {
+		return err
+	}
+
+	if err := wr.writeStyles(); err != nil {
+		return err
+	}
+
+	if err := wr.writePackage(len(toWrite)); err != nil {
+		return err
+	}
+
+	return wr.z.Close()
+}
+
+// sheetNaming holds the rules Excel has for naming sheets.
+var sheetNaming = office.SheetNaming{MaxLength: 31, NoTrailingQuote: true}
+
+// A cellStyleKey identifies a cell format written to the stylesheet.
+type cellStyleKey struct {
+	numFmtID int
+	bold     bool
+}
+
+// A writer writes the parts of an XLSX file.
+type writer struct {
+	z        *zip.Writer
+	options  writeOptions
+	settings sheets.CalcSettings
+
+	strings     []string
+	stringIndex map[string]int
+	numFmts     []string
+	numFmtIDs   map[string]int
+	styles      []cellStyleKey
+	styleIndex  map[cellStyleKey]int
+}
+
+const (
+	nsMain          = "http://schemas.openxmlformats.org/spreadsheetml/2006/main"
+	nsRelationships = "http://schemas.openxmlformats.org/officeDocument/2006/relationships"
+	nsPackageRels   = "http://schemas.openxmlformats.org/package/2006/relationships"
+	xmlHeader       = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n"
+)
+
+func (wr *writer) writeWorksheet(ctx context.Context, index int, s sheets.Sheet) error {
+	fs, _ := s.(sheets.FormulaSheet)
+	dims := s.Dimensions()
+	widths := make([]int, dims.EndCol+1)
+
+	var data strings.Builder
+	for row := 0; row <= dims.EndRow; row++ {
+		bold := wr.options.header && row == 0
+
+		var cells strings.Builder
+		for col := 0; col <= dims.EndCol; col++ {
+			pos := sheets.Pos{Row: row, Col: col}
+			v, err := s.Get(ctx, pos)
+			if err != nil {
+				var posErr sheets.InvalidPosError
+				if !errors.As(err, &posErr) {
+					return err
+				}
+
+				v = sheets.BlankValue{}
+			}
+
+			var f sheets.Formula
+			if fs != nil {
+				f, _ = fs.Formula(pos)
+			}
+
+			if n := wr.writeCell(&cells, pos, v, f, bold); n > widths[col] {
+				widths[col] = n
+			}
+		}
+
+		if cells.Len() != 0 {
+			fmt.Fprintf(&data, `<row r="%d">%s</row>`, row+1, cells.String())
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString(xmlHeader)
+	fmt.Fprintf(&sb, `<worksheet xmlns="%s" xmlns:r="%s">`, nsMain, nsRelationships)
+	fmt.Fprintf(&sb, `<dimension ref="%s"/>`, sheets.Range{EndRow: dims.EndRow, EndCol: dims.EndCol})
+	if wr.options.header {
+		sb.WriteString(`<sheetViews><sheetView workbookViewId="0">` +
+			`<pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/>` +
+			`</sheetView></sheetViews>`)
+	}
+
+	sb.WriteString(`<sheetFormatPr defaultRowHeight="15"/><cols>`)
+	for col, chars := range widths {
+		width, ok := wr.options.columnWidths[col]
+		if !ok {
+			width = float64(chars + 2)
+			width = math.Max(minColumnWidth, math.Min(maxColumnWidth, width))
+		}
+
+		fmt.Fprintf(&sb, `<col min="%d" max="%d" width="%s" customWidth="1"/>`,
+			col+1, col+1, strconv.FormatFloat(width, 'f', -1, 64))
+	}
+
+	sb.WriteString(`</cols><sheetData>`)
+	sb.WriteString(data.String())
+	sb.WriteString(`</sheetData></worksheet>`)
+	return wr.writePart(fmt.Sprintf("xl/worksheets/sheet%d.xml", index+1), sb.String())
+}
+
+// writeCell writes a cell, returning the number of characters it displays.
+// Blank cells without a formula or style are not written.
+func (wr *writer) writeCell(sb *strings.Builder, pos sheets.Pos, v sheets.Value, f sheets.Formula, bold bool) int {
+	var (
+		cellType string
+		text     string
+		display  string
+		numFmt   string
+	)
+
+	switch tv := v.(type) {
+	case sheets.BlankValue:
+		if f == nil && !bold {
+			return 0
+		}
+	case sheets.Float64Value:
+		cellType, text, display = wr.numberCell(float64(tv))
+	case sheets.FormattedNumber:
+		cellType, text, display = wr.numberCell(tv.Number)
+		if cellType == "" {
+			numFmt = tv.Format
+			if nf, err := sheets.ParseNumberFormat(tv.Format); err == nil {
+				if s, err := nf.Format(tv); err == nil {
+					display = s
+				}
+			}
+		}
+	case sheets.TimeValue:
+		serial := wr.settings.DateSystem.ToExcelTimeIn(time.Time(tv), office.Location(wr.settings))
+		if serial < 0 {
+			// Times before the start of the date system can only be text
+			cellType, text = "str", time.Time(tv).Format(time.RFC3339)
+			display = text
+			break
+		}
+
+		cellType, text, _ = wr.numberCell(serial)
+		numFmt = dateTimeFormat
+		if serial == math.Trunc(serial) {
+			numFmt = dateFormat
+		}
+
+		display = numFmt
+	case sheets.BoolValue:
+		cellType, text = "b", "0"
+		if tv {
+			text = "1"
+		}
+
+		display = tv.String()
+	case sheets.ErrorValue:
+		cellType, text = "e", sheets.ErrorCode(tv.Err)
+		display = text
+	default:
+		cellType, text = "str", v.String()
+		display = text
+	}
+
+	if cellType == "str" && f == nil {
+		cellType, text = "s", strconv.Itoa(wr.sharedString(text))
+	}
+
+	fmt.Fprintf(sb, `<c r="%s"`, pos)
+	if style := wr.style(numFmt, bold); style != 0 {
+		fmt.Fprintf(sb, ` s="%d"`, style)
+	}
+
+	if cellType != "" {
+		fmt.Fprintf(sb, ` t="%s"`, cellType)
+	}
+
+	sb.WriteByte('>')
+	if f != nil {
+		sb.WriteString("<f>")
+		writeText(sb, excelFormula(f, wr.settings))
+		sb.WriteString("</f>")
+	}
+
+	if text != "" || cellType == "str" {
+		sb.WriteString("<v>")
+		writeText(sb, text)
+		sb.WriteString("</v>")
+	}
+
+	sb.WriteString("</c>")
+	return utf8.RuneCountInString(display)
+}
+
+// numberCell returns the type, value and displayed text of a number, which is
+// written as a #NUM! error if it cannot be stored.
+func (wr *writer) numberCell(n float64) (cellType, text, display string) {
+	if math.IsNaN(n) || math.IsInf(n, 0) {
+		return "e", "#NUM!", "#NUM!"
+	}
+
+	text = strconv.FormatFloat(n, 'g', -1, 64)
+	return "", text, wr.settings.NumericMode.Format(n)
+}
+
+// sharedString returns the index of a string in the shared strings table.
+func (wr *writer) sharedString(s string) int {
+	if i, ok := wr.stringIndex[s]; ok {
+		return i
+	}
+
+	i := len(wr.strings)
+	wr.strings = append(wr.strings, s)
+	wr.stringIndex[s] = i
+	return i
+}
+
+// style returns the index of the cell format with the given number format and
+// font weight.
+func (wr *writer) style(numFmt string, bold bool) int {
+	key := cellStyleKey{numFmtID: wr.numFmtID(numFmt), bold: bold}
+	if i, ok := wr.styleIndex[key]; ok {
+		return i
+	}
+
+	i := len(wr.styles)
+	wr.styles = append(wr.styles, key)
+	wr.styleIndex[key] = i
+	return i
+}
+
+// numFmtID returns the ID of a number format, using the built-in format with
+// the same code if there is one.
+func (wr *writer) numFmtID(code string) int {
+	if code == "" || code == "General" {
+		return 0
+	}
+
+	if id, ok := wr.numFmtIDs[code]; ok {
+		return id
+	}
+
+	id := -1
+	for builtinID, builtinCode := range builtinNumFmts {
+		if builtinCode == code {
+			id = builtinID
+		}
+	}
+
+	if id < 0 {
+		id = firstCustomNumFmt + len(wr.numFmts)
+		wr.numFmts = append(wr.numFmts, code)
+	}
+
+	wr.numFmtIDs[code] = id
+	return id
+}
+
+func (wr *writer) writeWorkbook(ds sheets.DataSet, toWrite []office.NamedSheet) error {
+	var sb strings.Builder
+	sb.WriteString(xmlHeader)
+	fmt.Fprintf(&sb, `<workbook xmlns="%s" xmlns:r="%s">`, nsMain, nsRelationships)
+	if wr.settings.DateSystem == sheets.Date1904 {
+		sb.WriteString(`<workbookPr date1904="1"/>`)
+	} else {
+		sb.WriteString(`<workbookPr/>`)
+	}
+
+	sb.WriteString(`<bookViews><workbookView/></bookViews><sheets>`)
+	for i, s := range toWrite {
+		sb.WriteString(`<sheet name="`)
+		writeText(&sb, s.Name)
+		fmt.Fprintf(&sb, `" sheetId="%d" r:id="rId%d"/>`, i+1, i+1)
+	}
+
+	sb.WriteString(`</sheets>`)
+	if named := office.NamedRanges(ds, toWrite); len(named) != 0 {
+		sb.WriteString(`<definedNames>`)
+		for _, nr := range named {
+			sb.WriteString(`<definedName name="`)
+			writeText(&sb, nr.Name)
+			sb.WriteString(`">`)
+			writeText(&sb, excelSyntax.AbsoluteReference(nr.Sheet, nr.Range))
+			sb.WriteString(`</definedName>`)
+		}
+
+		sb.WriteString(`</definedNames>`)
+	}
+
+	sb.WriteString(`</workbook>`)
+	if err := wr.writePart("xl/workbook.xml", sb.String()); err != nil {
+		return err
+	}
+
+	var rels strings.Builder
+	rels.WriteString(xmlHeader)
+	fmt.Fprintf(&rels, `<Relationships xmlns="%s">`, nsPackageRels)
+	for i := range toWrite {
+		fmt.Fprintf(&rels, `<Relationship Id="rId%d" Type="%s%s" Target="worksheets/sheet%d.xml"/>`,
+			i+1, nsRelationships, relTypeWorksheet, i+1)
+	}
+
+	fmt.Fprintf(&rels, `<Relationship Id="rId%d" Type="%s%s" Target="sharedStrings.xml"/>`,
+		len(toWrite)+1, nsRelationships, relTypeSharedStrings)
+	fmt.Fprintf(&rels, `<Relationship Id="rId%d" Type="%s%s" Target="styles.xml"/>`,
+		len(toWrite)+2, nsRelationships, relTypeStyles)
+	rels.WriteString(`</Relationships>`)
+	return wr.writePart("xl/_rels/workbook.xml.rels", rels.String())
+}
+
+func (wr *writer) writeSharedStrings() error {
+	var sb strings.Builder
+	sb.WriteString(xmlHeader)
+	fmt.Fprintf(&sb, `<sst xmlns="%s" count="%d" uniqueCount="%d">`, nsMain, len(wr.strings), len(wr.strings))
+	for _, s := range wr.strings {
+		if strings.TrimSpace(s) != s {
+			sb.WriteString(`<si><t xml:space="preserve">`)
+		} else {
+			sb.WriteString(`<si><t>`)
+		}
+
+		writeText(&sb, s)
+		sb.WriteString(`</t></si>`)
+	}
+
+	sb.WriteString(`</sst>`)
+	return wr.writePart("xl/sharedStrings.xml", sb.String())
+}
+
+func (wr *writer) writeStyles() error {
+	var sb strings.Builder
+	sb.WriteString(xmlHeader)
+	fmt.Fprintf(&sb, `<styleSheet xmlns="%s">`, nsMain)
+	if len(wr.numFmts) != 0 {
+		fmt.Fprintf(&sb, `<numFmts count="%d">`, len(wr.numFmts))
+		for i, code := range wr.numFmts {
+			fmt.Fprintf(&sb, `<numFmt numFmtId="%d" formatCode="`, firstCustomNumFmt+i)
+			writeText(&sb, code)
+			sb.WriteString(`"/>`)
+		}
+
+		sb.WriteString(`</numFmts>`)
+	}
+
+	sb.WriteString(`<fonts count="2">` +
+		`<font><sz val="11"/><name val="Calibri"/><family val="2"/></font>` +
+		`<font><b/><sz val="11"/><name val="Calibri"/><family val="2"/></font>` +
+		`</fonts>` +
+		`<fills count="2"><fill><patternFill patternType="none"/></fill>` +
+		`<fill><patternFill patternType="gray125"/></fill></fills>` +
+		`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+		`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>`)
+
+	fmt.Fprintf(&sb, `<cellXfs count="%d">`, len(wr.styles))
+	for _, key := range wr.styles {
+		fontID := 0
+		if key.bold {
+			fontID = 1
+		}
+
+		fmt.Fprintf(&sb, `<xf numFmtId="%d" fontId="%d" fillId="0" borderId="0" xfId="0"`, key.numFmtID, fontID)
+		if key.numFmtID != 0 {
+			sb.WriteString(` applyNumberFormat="1"`)
+		}
+
+		if key.bold {
+			sb.WriteString(` applyFont="1"`)
+		}
+
+		sb.WriteString(`/>`)
+	}
+
+	sb.WriteString(`</cellXfs>` +
+		`<cellStyles count="1"><cellStyle name="Normal" xfId="0" builtinId="0"/></cellStyles>` +
+		`</styleSheet>`)
+	return wr.writePart("xl/styles.xml", sb.String())
+}
+
+// writePackage writes the content types and relationships of the package.
+func (wr *writer) writePackage(numSheets int) error {
+	var sb strings.Builder
+	sb.WriteString(xmlHeader)
+	sb.WriteString(`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ` +
+		`ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/sharedStrings.xml" ` +
+		`ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sharedStrings+xml"/>` +
+		`<Override PartName="/xl/styles.xml" ` +
+		`ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>`)
+	for i := 0; i < numSheets; i++ {
+		fmt.Fprintf(&sb, `<Override PartName="/xl/worksheets/sheet%d.xml" `+
+			`ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, i+1)
+	}
+
+	sb.WriteString(`</Types>`)
+	if err := wr.writePart("[Content_Types].xml", sb.String()); err != nil {
+		return err
+	}
+
+	return wr.writePart("_rels/.rels", xmlHeader+
+		`<Relationships xmlns="`+nsPackageRels+`">`+
+		`<Relationship Id="rId1" Type="`+nsRelationships+relTypeOfficeDocument+`" Target="xl/workbook.xml"/>`+
+		`</Relationships>`)
+}
+
+func (wr *writer) writePart(name, content string) error {
+	return office.WritePart(wr.z, name, content)
+}
+
+// writeText writes text escaped for XML. Characters that cannot be written in
+// XML are written as _xHHHH_ escapes, as is any text that would otherwise be
+// read as one.
+func writeText(sb *strings.Builder, s string) {
+	if strings.Contains(s, "_x") {
+		s = escapedChar.ReplaceAllString(s, "_x005F$0")
+	}
+
+	var escaped strings.Builder
+	for _, r := range s {
+		if r < 0x20 && r != '\t' && r != '\n' && r != '\r' {
+			fmt.Fprintf(&escaped, "_x%04X_", r)
+			continue
+		}
+
+		escaped.WriteRune(r)
+	}
+
+	_ = xml.EscapeText(sb, []byte(escaped.String()))
+}
@@ -0,0 +1,208 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mmihic/sheets/src/pkg/sheets"
+	"github.com/mmihic/sheets/src/pkg/sheets/internal/office"
+	"github.com/mmihic/sheets/src/pkg/sheets/internal/office/officetest"
+)
+
+func newTestWorkbook(t *testing.T) *sheets.Workbook {
+	return officetest.NewWorkbook(t, `a "quoted" _x0041_ name`, " padded\x01 ")
+}
+
+func writeAndRead(t *testing.T, ds sheets.DataSet, opts ...WriteOption) (*Workbook, []byte) {
+	var buf bytes.Buffer
+	require.NoError(t, Write(context.Background(), &buf, ds, opts...))
+
+	wb, err := Read(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	return wb, buf.Bytes()
+}
+
+func TestWrite(t *testing.T) {
+	wb, _ := writeAndRead(t, newTestWorkbook(t))
+	assert.Equal(t, []string{"Data", "Q1's Summary"}, wb.SheetNames())
+	assert.Empty(t, wb.FormulaErrors())
+
+	assertCells(t, wb.Sheet("Data"), map[string]sheets.Value{
+		"A1": sheets.StringValue("name"),
+		"A2": sheets.StringValue(`a "quoted" _x0041_ name`),
+		"A3": sheets.StringValue(" padded\x01 "),
+		"B2": sheets.FormattedNumber{Number: 1.5, Format: "#,##0.00"},
+		"B3": sheets.FormattedNumber{Number: 2.25, Format: "0.000"},
+		"C2": sheets.TimeValue(time.Date(2024, time.March, 5, 0, 0, 0, 0, time.UTC)),
+		"C3": sheets.TimeValue(time.Date(2024, time.March, 5, 12, 30, 0, 0, time.UTC)),
+		"D2": sheets.BoolValue(true),
+		"D3": sheets.BoolValue(false),
+		"A4": sheets.ErrorValue{Err: sheets.ErrDivideByZero},
+		"B4": sheets.Float64Value(3.75),
+		"C4": sheets.StringValue(` padded` + "\x01" + ` say "hi"`),
+		"D4": sheets.Float64Value(7.5),
+	})
+
+	assertCells(t, wb.Sheet("Q1's Summary"), map[string]sheets.Value{
+		"A1": sheets.Float64Value(3.75),
+		"B1": sheets.Float64Value(7.5),
+		"C1": sheets.ErrorValue{Err: sheets.ValueErrorf("#NUM!")},
+		"D1": sheets.BlankValue{},
+		"F1": sheets.Float64Value(1e-7),
+		"A4": sheets.StringValue("end"),
+	})
+
+	// The cached results are those computed when writing
+	assertCells(t, wb.CachedSheet("Data"), map[string]sheets.Value{
+		"B4": sheets.Float64Value(3.75),
+		"D4": sheets.Float64Value(7.5),
+	})
+
+	named, ok := wb.NamedRange("Totals")
+	require.True(t, ok)
+	assert.Equal(t, sheets.NamedRange{Sheet: "Data", Range: mustParseRange(t, "B2:B3")}, named)
+
+	_, ok = wb.NamedRange("Elsewhere")
+	assert.False(t, ok)
+}
+
+func TestWrite_Parts(t *testing.T) {
+	_, data := writeAndRead(t, newTestWorkbook(t), WithHeader(), WithColumnWidth(1, 20))
+
+	z, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+
+	part := func(name string) string {
+		f, err := z.Open(name)
+		require.NoError(t, err)
+
+		b, err := io.ReadAll(f)
+		require.NoError(t, err)
+		return string(b)
+	}
+
+	sheet1 := part("xl/worksheets/sheet1.xml")
+	assert.Contains(t, sheet1, `<dimension ref="A1:D4"/>`)
+	assert.Contains(t, sheet1, `<pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/>`)
+	assert.Contains(t, sheet1, `<col min="1" max="1" width="25" customWidth="1"/>`)
+	assert.Contains(t, sheet1, `<col min="2" max="2" width="20" customWidth="1"/>`)
+	assert.Contains(t, sheet1, `<col min="4" max="4" width="8" customWidth="1"/>`)
+	assert.Contains(t, sheet1, `<c r="A1" s="1" t="s"><v>0</v></c>`)
+	assert.Contains(t, sheet1, `<c r="A4" t="e"><v>#DIV/0!</v></c>`)
+	assert.Contains(t, sheet1, `<c r="B4"><f>SUM(B2:B3)</f><v>3.75</v></c>`)
+	assert.Contains(t, sheet1,
+		`<c r="C4" t="str"><f>_xlfn.CONCAT(A3,&#34;say &#34;&#34;hi&#34;&#34;&#34;)</f>`)
+	assert.Contains(t, sheet1, `<c r="D4"><f>(B2+B3)*2</f><v>7.5</v></c>`)
+
+	sheet2 := part("xl/worksheets/sheet2.xml")
+	assert.Contains(t, sheet2, `<f>&#39;Data&#39;!B4*2</f>`)
+	assert.Contains(t, sheet2, `<c r="C1" s="1" t="e"><v>#NUM!</v></c>`)
+	assert.Contains(t, sheet2, `<c r="D1" s="1"></c>`)
+
+	workbook := part("xl/workbook.xml")
+	assert.Contains(t, workbook, `<workbookPr/>`)
+	assert.Contains(t, workbook, `<sheet name="Q1&#39;s Summary" sheetId="2" r:id="rId2"/>`)
+	assert.Contains(t, workbook,
+		`<definedNames><definedName name="Totals">&#39;Data&#39;!$B$2:$B$3</definedName></definedNames>`)
+
+	strings := part("xl/sharedStrings.xml")
+	assert.Contains(t, strings, `<si><t>a &#34;quoted&#34; _x005F_x0041_ name</t></si>`)
+	assert.Contains(t, strings, `<si><t xml:space="preserve"> padded_x0001_ </t></si>`)
+
+	styles := part("xl/styles.xml")
+	assert.Contains(t, styles, `<numFmt numFmtId="165" formatCode="0.000"/>`)
+	assert.Contains(t, styles, `<numFmt numFmtId="164" formatCode="yyyy-mm-dd"/>`)
+	assert.Contains(t, styles, `<xf numFmtId="4" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>`)
+}
+
+func TestWrite_DateSystemAndLocation(t *testing.T) {
+	wb := newTestWorkbook(t)
+	wb.SetDateSystem(sheets.Date1904)
+	wb.SetLocation(time.FixedZone("UTC-5", -5*60*60))
+
+	read, data := writeAndRead(t, wb)
+	assert.Equal(t, sheets.Date1904, read.Evaluator().DateSystem)
+	assertCells(t, read.Sheet("Data"), map[string]sheets.Value{
+		"C2": sheets.TimeValue(time.Date(2024, time.March, 4, 19, 0, 0, 0, time.UTC)),
+	})
+
+	z, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+
+	f, err := z.Open("xl/workbook.xml")
+	require.NoError(t, err)
+
+	b, err := io.ReadAll(f)
+	require.NoError(t, err)
+	assert.Contains(t, string(b), `<workbookPr date1904="1"/>`)
+}
+
+func TestWriteSheet(t *testing.T) {
+	s, err := sheets.NewInMemorySheet([][]sheets.Value{
+		{sheets.StringValue("a"), sheets.Float64Value(1)},
+		{sheets.StringValue("b"), sheets.TimeValue(time.Date(1850, time.January, 1, 0, 0, 0, 0, time.UTC))},
+	})
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteSheet(context.Background(), &buf, "Only", s))
+
+	wb, err := Read(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Only"}, wb.SheetNames())
+	assertCells(t, wb.Sheet("Only"), map[string]sheets.Value{
+		"A1": sheets.StringValue("a"),
+		"B1": sheets.Float64Value(1),
+		"B2": sheets.StringValue("1850-01-01T00:00:00Z"),
+	})
+}
+
+func TestWriteFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "out.xlsx")
+	require.NoError(t, WriteFile(context.Background(), name, newTestWorkbook(t)))
+
+	wb, err := Open(name)
+	require.NoError(t, err)
+	assertCells(t, wb.Sheet("Data"), map[string]sheets.Value{
+		"B4": sheets.Float64Value(3.75),
+	})
+}
+
+func TestWrite_Errors(t *testing.T) {
+	ctx := context.Background()
+	s, err := sheets.NewInMemorySheet([][]sheets.Value{{sheets.Float64Value(1)}})
+	require.NoError(t, err)
+
+	for _, tt := range []struct {
+		name     string
+		ds       sheets.DataSet
+		opts     []WriteOption
+		expected string
+	}{
+		{"unlisted sheets", office.SingleSheet("A", s), nil,
+			"unable to list sheets of data set: use WithSheets"},
+		{"no sheets", sheets.NewWorkbook(), nil,
+			"there must be at least one sheet"},
+		{"missing sheet", newTestWorkbook(t), []WriteOption{WithSheets("Data", "Other")},
+			"no such sheet 'Other'"},
+		{"invalid name", office.SingleSheet("A/B", s), []WriteOption{WithSheets("A/B")},
+			"invalid sheet name 'A/B'"},
+		{"long name", newTestWorkbook(t), []WriteOption{WithSheets("ThisNameIsLongerThanThirtyOneChars")},
+			"invalid sheet name 'ThisNameIsLongerThanThirtyOneChars'"},
+		{"duplicate name", newTestWorkbook(t), []WriteOption{WithSheets("Data", "DATA")},
+			"duplicate sheet name 'DATA'"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Write(ctx, io.Discard, tt.ds, tt.opts...)
+			assert.EqualError(t, err, tt.expected)
+		})
+	}
+}